@@ -0,0 +1,60 @@
+// Command loadgen drives concurrent simulated flows through an in-process
+// engine and reports throughput and latency percentiles per operation.
+//
+//	go run ./cmd/loadgen -flows 50 -costs 500
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/loadtest"
+)
+
+func main() {
+	var cfg loadtest.Config
+	flag.IntVar(&cfg.Flows, "flows", 10, "number of concurrent flows")
+	flag.IntVar(&cfg.CostEvents, "costs", 200, "cost events reported by each flow")
+	flag.IntVar(&cfg.Intents, "intents", 50, "intent locks acquired and released by each flow")
+	flag.BoolVar(&cfg.Batch, "batch", false, "charge cost events through a cost batcher")
+	flag.IntVar(&cfg.TaskCache, "task-cache", 0, "cache up to this many flow states (0 disables)")
+	flag.StringVar(&cfg.DBPath, "db", "", "scratch database path, or :memory: (default: a temporary file)")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	rep, err := loadtest.Run(ctx, cfg)
+	if err != nil {
+		log.Fatalf("loadgen: %v", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			log.Fatalf("loadgen: %v", err)
+		}
+		return
+	}
+
+	fmt.Printf("%d flows in %s\n\n", rep.Flows, rep.Elapsed.Round(time.Millisecond))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "op\tcount\terrors\tops/s\tp50\tp99\tmax\t")
+	for _, s := range rep.Ops {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.0f\t%s\t%s\t%s\t\n",
+			s.Op, s.Count, s.Errors, s.PerSec, round(s.P50), round(s.P99), round(s.Max))
+	}
+	tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
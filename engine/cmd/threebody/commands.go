@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/anthropics/three-body-engine/internal/backup"
+	"github.com/anthropics/three-body-engine/internal/bundle"
+	"github.com/anthropics/three-body-engine/internal/config"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/mock"
+	"github.com/anthropics/three-body-engine/internal/secrets"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/pkg/client"
+)
+
+// runCommand runs a maintenance subcommand named by args[0], if any, and
+// reports whether it did.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "backup":
+		runBackup(args[1:])
+	case "restore":
+		runRestore(args[1:])
+	case "export":
+		runExport(args[1:])
+	case "import":
+		runImport(args[1:])
+	case "config":
+		runConfig(args[1:])
+	case "secrets":
+		runSecrets(args[1:])
+	case "mock-agent":
+		runMockAgent(args[1:])
+	case "encryption":
+		runEncryption(args[1:])
+	case "audit":
+		runAudit(args[1:])
+	case "purge":
+		runPurge(args[1:])
+	default:
+		return false
+	}
+	return true
+}
+
+// runBackup handles `threebody backup [--out snapshot.db]`. Without --out the
+// snapshot goes to the configured backup directory.
+func runBackup(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to configuration file (JSON, YAML, or TOML)")
+	overrides := overrideFlags(fs)
+	out := fs.String("out", "", "snapshot file to write (default: a timestamped file in backup.dir)")
+	fs.Parse(args)
+
+	cfg, _ := loadConfig(*configPath, configOverrides(overrides()))
+	db, err := store.NewDB(cfg.DBPath)
+	if err != nil {
+		fatal(fmt.Sprintf("open database: %v", err))
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	path := *out
+	if path == "" {
+		s := backup.NewScheduler(db, cfg.Backup.Dir, 0, cfg.Backup.Keep)
+		path, err = s.Run(ctx)
+	} else {
+		err = store.Backup(ctx, db, path)
+	}
+	if err != nil {
+		fatal(err.Error())
+	}
+	fmt.Printf("backup written to %s\n", path)
+}
+
+// runRestore handles `threebody restore --from snapshot.db`. The engine must
+// be stopped first; the restored database is migrated to the current schema.
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to configuration file (JSON, YAML, or TOML)")
+	overrides := overrideFlags(fs)
+	from := fs.String("from", "", "snapshot file to restore")
+	fs.Parse(args)
+
+	if *from == "" {
+		fmt.Fprintln(os.Stderr, "restore: --from is required")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, _ := loadConfig(*configPath, configOverrides(overrides()))
+	if err := store.Restore(context.Background(), cfg.DBPath, *from); err != nil {
+		fatal(err.Error())
+	}
+	db, err := store.NewDB(cfg.DBPath)
+	if err != nil {
+		fatal(fmt.Sprintf("migrate restored database: %v", err))
+	}
+	db.Close()
+	fmt.Printf("restored %s from %s\n", cfg.DBPath, *from)
+}
+
+// runExport handles `threebody export --task ID [--out file.json]`. Without
+// --out the bundle is written to stdout.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to configuration file (JSON, YAML, or TOML)")
+	overrides := overrideFlags(fs)
+	taskID := fs.String("task", "", "task to export")
+	out := fs.String("out", "", "bundle file to write (default: stdout)")
+	fs.Parse(args)
+
+	if *taskID == "" {
+		fmt.Fprintln(os.Stderr, "export: --task is required")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, _ := loadConfig(*configPath, configOverrides(overrides()))
+	installKeyring(cfg)
+	db, err := store.NewDB(cfg.DBPath)
+	if err != nil {
+		fatal(fmt.Sprintf("open database: %v", err))
+	}
+	defer db.Close()
+
+	b, err := bundle.New(db).Export(context.Background(), *taskID)
+	if err != nil {
+		fatal(fmt.Sprintf("export: %v", err))
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		fatal(fmt.Sprintf("export: %v", err))
+	}
+	if *out == "" {
+		os.Stdout.Write(append(data, '\n'))
+		return
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		fatal(fmt.Sprintf("export: %v", err))
+	}
+	fmt.Printf("exported %s to %s\n", *taskID, *out)
+}
+
+// runImport handles `threebody import --in file.json`.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to configuration file (JSON, YAML, or TOML)")
+	overrides := overrideFlags(fs)
+	in := fs.String("in", "", "bundle file to import")
+	fs.Parse(args)
+
+	if *in == "" {
+		fmt.Fprintln(os.Stderr, "import: --in is required")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		fatal(fmt.Sprintf("import: %v", err))
+	}
+	var b domain.TaskBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		fatal(fmt.Sprintf("import: parse bundle: %v", err))
+	}
+
+	cfg, _ := loadConfig(*configPath, configOverrides(overrides()))
+	installKeyring(cfg)
+	db, err := store.NewDB(cfg.DBPath)
+	if err != nil {
+		fatal(fmt.Sprintf("open database: %v", err))
+	}
+	defer db.Close()
+
+	if err := bundle.New(db).Import(context.Background(), &b); err != nil {
+		fatal(fmt.Sprintf("import: %v", err))
+	}
+	fmt.Printf("imported %s\n", b.Task.TaskID)
+}
+
+// runConfig handles `threebody config validate [--config file]`. It prints
+// every problem in the file, with overrides applied, and exits non-zero if
+// there are any.
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: threebody config validate [--config file]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to configuration file (JSON, YAML, or TOML)")
+	overrides := overrideFlags(fs)
+	fs.Parse(args[1:])
+
+	path := resolveConfigPath(*configPath)
+	problems, err := config.Check(path, configOverrides(overrides()))
+	if err != nil {
+		fatal(err.Error())
+	}
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, p)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("%s: ok\n", path)
+}
+
+// runSecrets handles `threebody secrets keygen` and `threebody secrets
+// list|set|delete [name] [--config file]` on the configured secrets file.
+// set reads the value from stdin so that it stays out of shell history.
+func runSecrets(args []string) {
+	const usage = "usage: threebody secrets keygen | list | set NAME | delete NAME [--config file]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if args[0] == "keygen" {
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			fatal(err.Error())
+		}
+		fmt.Println(key)
+		return
+	}
+
+	fs := flag.NewFlagSet("secrets "+args[0], flag.ExitOnError)
+	configPath := fs.String("config", "", "path to configuration file (JSON, YAML, or TOML)")
+	overrides := overrideFlags(fs)
+	fs.Parse(args[1:])
+	name := fs.Arg(0)
+
+	cfg, _ := loadConfig(*configPath, configOverrides(overrides()))
+	if cfg.Secrets.File == "" {
+		fatal("secrets: secrets.file is not configured")
+	}
+	f, err := openSecretsFile(cfg.Secrets)
+	if err != nil {
+		fatal(fmt.Sprintf("secrets: %v", err))
+	}
+
+	switch {
+	case args[0] == "list":
+		for _, n := range f.Names() {
+			fmt.Println(n)
+		}
+	case args[0] == "set" && name != "":
+		value, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fatal(fmt.Sprintf("secrets: read value: %v", err))
+		}
+		if err := f.Set(name, strings.TrimRight(string(value), "\r\n")); err != nil {
+			fatal(fmt.Sprintf("secrets: %v", err))
+		}
+		fmt.Printf("secret %s stored; reference it as ${file:%s}\n", name, name)
+	case args[0] == "delete" && name != "":
+		if err := f.Delete(name); err != nil {
+			fatal(fmt.Sprintf("secrets: %v", err))
+		}
+		fmt.Printf("secret %s deleted\n", name)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// runEncryption handles `threebody encryption rotate [--config file]`. It
+// reseals every encrypted payload not sealed with encryption.key: those
+// under encryption.previous_keys and those stored before encryption was
+// enabled. Without encryption.key it decrypts them instead.
+func runEncryption(args []string) {
+	const usage = "usage: threebody encryption rotate [--config file]"
+	if len(args) == 0 || args[0] != "rotate" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("encryption rotate", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to configuration file (JSON, YAML, or TOML)")
+	overrides := overrideFlags(fs)
+	fs.Parse(args[1:])
+
+	cfg, _ := loadConfig(*configPath, configOverrides(overrides()))
+	if !cfg.Encryption.Enabled() {
+		fatal("encryption: neither encryption.key nor encryption.previous_keys is configured")
+	}
+	installKeyring(cfg)
+	db, err := store.NewDB(cfg.DBPath)
+	if err != nil {
+		fatal(fmt.Sprintf("open database: %v", err))
+	}
+	defer db.Close()
+
+	stats, err := store.Reseal(context.Background(), db)
+	for _, table := range []string{"audit_records", "phase_snapshots", "session_events"} {
+		fmt.Printf("%s: %d rows resealed\n", table, stats[table])
+	}
+	if err != nil {
+		fatal(fmt.Sprintf("encryption: %v", err))
+	}
+	if cfg.Encryption.Key == "" {
+		fmt.Println("payloads decrypted; encryption.previous_keys can now be removed")
+	} else if len(cfg.Encryption.PreviousKeys) > 0 {
+		fmt.Println("payloads resealed; encryption.previous_keys can now be removed")
+	}
+}
+
+// runAudit handles `threebody audit verify [--config file] [--task id]`,
+// checking the audit chain of one task, or of every task with audit
+// records, and exiting 1 if any chain is broken.
+func runAudit(args []string) {
+	const usage = "usage: threebody audit verify [--config file] [--task id]"
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to configuration file (JSON, YAML, or TOML)")
+	taskID := fs.String("task", "", "task to verify (default: every task)")
+	overrides := overrideFlags(fs)
+	fs.Parse(args[1:])
+
+	cfg, _ := loadConfig(*configPath, configOverrides(overrides()))
+	installKeyring(cfg)
+	db, err := store.NewDB(cfg.DBPath)
+	if err != nil {
+		fatal(fmt.Sprintf("open database: %v", err))
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &store.AuditRepo{}
+	tasks := []string{*taskID}
+	if *taskID == "" {
+		if tasks, err = repo.ListChainedTasks(ctx, db); err != nil {
+			fatal(err.Error())
+		}
+	}
+	if len(tasks) == 0 {
+		fmt.Println("no audit records")
+	}
+	broken := 0
+	for _, id := range tasks {
+		report, err := repo.Verify(ctx, db, id)
+		if err != nil {
+			fatal(err.Error())
+		}
+		if !report.Valid {
+			broken++
+			fmt.Printf("%s: BROKEN (%d records)\n", id, report.Records)
+			for _, p := range report.Problems {
+				fmt.Printf("  seq %d %s: %s\n", p.Seq, p.RecordID, p.Problem)
+			}
+			continue
+		}
+		fmt.Printf("%s: ok (%d records, head %d %s)\n", id, report.Records, report.HeadSeq, report.HeadHash)
+		if report.Unchained > 0 {
+			fmt.Printf("  %d records predate chaining and were not checked\n", report.Unchained)
+		}
+	}
+	if broken > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d audit chains are broken\n", broken, len(tasks))
+		os.Exit(1)
+	}
+}
+
+// runPurge handles `threebody purge --task id [--actor name] [--dry-run]
+// [--config file]`, erasing a finished task's rows and files, or listing
+// them with --dry-run. The report is printed as JSON.
+func runPurge(args []string) {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to configuration file (JSON, YAML, or TOML)")
+	taskID := fs.String("task", "", "task to purge")
+	actor := fs.String("actor", "", "who requested the purge, for the audit record (default: $USER)")
+	dryRun := fs.Bool("dry-run", false, "list what would be removed without removing it")
+	overrides := overrideFlags(fs)
+	fs.Parse(args)
+	if *taskID == "" {
+		fmt.Fprintln(os.Stderr, "usage: threebody purge --task id [--actor name] [--dry-run] [--config file]")
+		os.Exit(2)
+	}
+	if *actor == "" {
+		*actor = os.Getenv("USER")
+	}
+	if *actor == "" {
+		*actor = "cli"
+	}
+
+	cfg, _ := loadConfig(*configPath, configOverrides(overrides()))
+	installKeyring(cfg)
+	db, err := store.NewDB(cfg.DBPath)
+	if err != nil {
+		fatal(fmt.Sprintf("open database: %v", err))
+	}
+	defer db.Close()
+
+	report, err := newPurger(db, cfg).Purge(context.Background(), *taskID, *actor, *dryRun)
+	if err != nil {
+		fatal(fmt.Sprintf("purge: %v", err))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+}
+
+// runMockAgent handles `threebody mock-agent --scenario file --task T
+// --phase P --role R [--api URL]`, the session process of a mock provider. It
+// plays the scenario's steps for the role and phase as canonical events on
+// stdout and submits review steps to the engine at --api.
+func runMockAgent(args []string) {
+	fs := flag.NewFlagSet("mock-agent", flag.ExitOnError)
+	scenario := fs.String("scenario", "", "scenario file to replay")
+	sess := mock.Session{}
+	fs.StringVar(&sess.TaskID, "task", "", "task the session works on")
+	fs.StringVar(&sess.Phase, "phase", "", "phase the session runs in")
+	fs.StringVar(&sess.Role, "role", "", "role of the session's worker")
+	api := fs.String("api", "", "engine API base URL for review steps")
+	fs.Parse(args)
+
+	sc, err := mock.Load(*scenario)
+	if err != nil {
+		fatal(err.Error())
+	}
+	if *api != "" {
+		sess.Engine = client.New(*api)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := mock.Play(ctx, os.Stdout, sess, sc.StepsFor(sess.Phase, sess.Role)); err != nil {
+		fatal(fmt.Sprintf("mock-agent: %v", err))
+	}
+}
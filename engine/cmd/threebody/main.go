@@ -4,26 +4,58 @@ package main
 import (
 	"bufio"
 	"context"
+	"database/sql"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/anthropics/three-body-engine/internal/artifact"
+	"github.com/anthropics/three-body-engine/internal/backup"
 	"github.com/anthropics/three-body-engine/internal/bridge"
+	"github.com/anthropics/three-body-engine/internal/bundle"
+	"github.com/anthropics/three-body-engine/internal/chaos"
+	"github.com/anthropics/three-body-engine/internal/ci"
 	"github.com/anthropics/three-body-engine/internal/config"
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+	"github.com/anthropics/three-body-engine/internal/git"
 	"github.com/anthropics/three-body-engine/internal/guard"
+	"github.com/anthropics/three-body-engine/internal/health"
+	"github.com/anthropics/three-body-engine/internal/hooks"
+	"github.com/anthropics/three-body-engine/internal/instance"
 	"github.com/anthropics/three-body-engine/internal/ipc"
+	"github.com/anthropics/three-body-engine/internal/leader"
 	"github.com/anthropics/three-body-engine/internal/mcp"
+	"github.com/anthropics/three-body-engine/internal/mock"
+	"github.com/anthropics/three-body-engine/internal/notify"
+	"github.com/anthropics/three-body-engine/internal/orchestrator"
+	"github.com/anthropics/three-body-engine/internal/pullrequest"
+	"github.com/anthropics/three-body-engine/internal/purge"
+	"github.com/anthropics/three-body-engine/internal/redact"
+	"github.com/anthropics/three-body-engine/internal/report"
+	"github.com/anthropics/three-body-engine/internal/retention"
+	"github.com/anthropics/three-body-engine/internal/review"
+	"github.com/anthropics/three-body-engine/internal/sandbox"
+	"github.com/anthropics/three-body-engine/internal/seal"
+	"github.com/anthropics/three-body-engine/internal/secrets"
+	"github.com/anthropics/three-body-engine/internal/shutdown"
 	"github.com/anthropics/three-body-engine/internal/store"
 	"github.com/anthropics/three-body-engine/internal/team"
+	"github.com/anthropics/three-body-engine/internal/tracker"
 	"github.com/anthropics/three-body-engine/internal/workflow"
+	"github.com/anthropics/three-body-engine/internal/workspace"
 )
 
 var (
@@ -33,8 +65,14 @@ var (
 )
 
 func main() {
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
 	showVersion := flag.Bool("version", false, "print version and exit")
-	configPath := flag.String("config", "", "path to configuration JSON file")
+	configPath := flag.String("config", "", "path to configuration file (JSON, YAML, or TOML)")
+	compact := flag.Bool("compact", false, "apply retention policies, vacuum the database, and exit")
+	flagOverrides := overrideFlags(flag.CommandLine)
 	flag.Parse()
 
 	if *showVersion {
@@ -42,52 +80,192 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Resolve config path: --config flag > TB_CONFIG env > auto-discover next to exe.
-	path := *configPath
-	if path == "" {
-		path = os.Getenv("TB_CONFIG")
-	}
-	if path == "" {
-		path = discoverConfig()
-	}
-	if path == "" {
-		fatal("no config found. Place config.json next to the exe, use --config <path>, or set TB_CONFIG.")
-	}
+	overrides := configOverrides(flagOverrides())
+	cfg, cfgPath := loadConfig(*configPath, overrides)
 
-	cfg, err := config.Load(path)
+	// Mask secrets in payloads before anything is written.
+	redactor, err := newRedactor(cfg.Redaction)
 	if err != nil {
-		fatal(fmt.Sprintf("load config: %v", err))
+		log.Fatalf("redaction: %v", err)
 	}
+	store.SetRedactor(redactor)
 
-	db, err := store.NewDB(cfg.DBPath)
+	// Keep a second engine on this machine off the database: launching
+	// again opens the running engine in the browser instead.
+	// An in-memory database is private to this process, so there is nothing
+	// to share and no lock file to write.
+	var lock *instance.Lock
+	if !cfg.MultiInstance && !cfg.InMemory() {
+		var running *instance.Holder
+		lock, running, err = instance.Acquire(cfg.DBPath+".lock", instance.Holder{PID: os.Getpid(), StartedAt: time.Now().Unix()})
+		if err != nil {
+			fatal(fmt.Sprintf("instance lock: %v", err))
+		}
+		if running != nil {
+			switch {
+			case *compact:
+				fatal(fmt.Sprintf("%s is in use by the engine with pid %d; stop it first", cfg.DBPath, running.PID))
+			case running.URL == "":
+				log.Printf("three-body engine is already starting (pid %d)", running.PID)
+			default:
+				log.Printf("three-body engine is already running at %s (pid %d); opening it", running.URL, running.PID)
+				if err := instance.OpenBrowser(running.URL); err != nil {
+					log.Printf("open browser: %v", err)
+				}
+			}
+			return
+		}
+		defer lock.Release()
+	}
+
+	installKeyring(cfg)
+	var monkey *chaos.Monkey
+	var dbOpts store.DBOptions
+	if cfg.Chaos.Enabled {
+		monkey = newMonkey(cfg.Chaos)
+		dbOpts.BeforeWrite = monkey.DelayWrite
+	}
+	db, err := store.NewDBWith(cfg.DBPath, dbOpts)
 	if err != nil {
 		log.Fatalf("open database: %v", err)
 	}
 	defer db.Close()
+	var taskCache *store.TaskCache
+	if cfg.TaskCacheSize > 0 {
+		taskCache = store.CacheTasks(db, cfg.TaskCacheSize)
+	}
+
+	retainer := retention.NewManager(db, retentionPolicies(cfg.Retention), cfg.Retention.ArchiveDir)
+	retainer.Interval = time.Duration(cfg.Retention.IntervalSec) * time.Second
+	if *compact {
+		if err := runCompact(db, retainer); err != nil {
+			fatal(fmt.Sprintf("compact: %v", err))
+		}
+		return
+	}
+
+	readDB := db
+	if !cfg.InMemory() {
+		readDB, err = store.NewReadDB(cfg.DBPath, cfg.ReadPoolSize)
+		if err != nil {
+			log.Fatalf("open read pool: %v", err)
+		}
+		defer readDB.Close()
+	}
 
 	// Wire workflow engine.
 	engine := workflow.NewEngine(db)
+	engine.ReadDB = readDB
+	engine.RetryAttempts = cfg.AdvanceRetryAttempts
+	engine.RetryBackoff = time.Duration(cfg.AdvanceRetryBackoffMS) * time.Millisecond
+	engine.DefaultWorkspace = cfg.Workspace
+	engine.WorkspaceConflict = cfg.WorkspaceConflict
+	engine.NamespaceBudgets = make(map[string]float64, len(cfg.Namespaces))
+	for ns, n := range cfg.Namespaces {
+		engine.NamespaceBudgets[ns] = n.BudgetUSD
+	}
+	engine.PhaseSLA = make(map[domain.Phase]time.Duration, len(cfg.PhaseSLASec))
+	for phase, sec := range cfg.PhaseSLASec {
+		engine.PhaseSLA[domain.Phase(phase)] = time.Duration(sec) * time.Second
+	}
+	for t, schema := range cfg.CustomEvents {
+		domain.CustomEventSchemas[domain.EventType(t)] = schema
+	}
 	gov := workflow.NewBudgetGovernor(db)
+	gov.SetThresholds(cfg.BudgetWarnRatio, cfg.BudgetHaltRatio)
+	gov.SetAlerts(budgetAlertRules(cfg.BudgetAlerts))
+	if tg := cfg.TestGate; tg.Command != "" {
+		inner, _ := engine.GateRegistry.Get(domain.PhaseE)
+		engine.GateRegistry.Register(domain.PhaseE, &workflow.TestGate{
+			Inner:           inner,
+			DB:              db,
+			ArtifactRepo:    &store.ArtifactRepo{},
+			Blobs:           artifact.NewBlobs(cfg.ArtifactDir),
+			Command:         tg.Command,
+			Args:            tg.Args,
+			Workspace:       cfg.Workspace,
+			Timeout:         time.Duration(tg.TimeoutSec) * time.Second,
+			MinCoverage:     tg.MinCoverage,
+			CoveragePattern: regexp.MustCompile(tg.CoveragePattern),
+		})
+	}
+	for phase, sg := range cfg.ScriptGates {
+		src, err := sg.Source()
+		if err != nil {
+			log.Fatalf("script gate %s: %v", phase, err)
+		}
+		inner, _ := engine.GateRegistry.Get(domain.Phase(phase))
+		gate, err := workflow.NewScriptGate(db, inner, src)
+		if err != nil {
+			log.Fatalf("script gate %s: %v", phase, err)
+		}
+		gate.Message = sg.Message
+		if sg.Events > 0 {
+			gate.Events = sg.Events
+		}
+		engine.GateRegistry.Register(domain.Phase(phase), gate)
+	}
+	var ciGate *workflow.CIGate
+	if cg := cfg.CIGate; cg.Provider != "" {
+		inner, _ := engine.GateRegistry.Get(domain.PhaseE)
+		ciGate = workflow.NewCIGate(db, inner, func(taskID string) string { return cfg.Git.BranchPrefix + taskID })
+		ciGate.Required = cg.RequiredChecks
+		if cg.Provider == "github" {
+			ciGate.Source = &ci.GitHub{APIURL: cg.APIURL, Repo: cg.Repo, Token: os.Getenv(cg.TokenEnv)}
+		}
+		engine.GateRegistry.Register(domain.PhaseE, ciGate)
+	}
+	approvalPhases := make([]domain.Phase, len(cfg.Approvals.Phases))
+	for i, p := range cfg.Approvals.Phases {
+		approvalPhases[i] = domain.Phase(p)
+	}
+	approvals := workflow.NewApprovals(engine, approvalPhases)
+
+	// Wire per-task workspaces.
+	if cfg.Workspaces.Root != "" {
+		workspaces := workspace.NewManager(db, cfg.Workspaces.Root)
+		workspaces.Repo = cfg.Workspace
+		workspaces.Worktree = cfg.Workspaces.Worktree
+		workspaces.OnComplete = cfg.Workspaces.OnComplete
+		workspaces.ArchiveDir = cfg.Workspaces.ArchiveDir
+		workspaces.Start(engine)
+		engine.Workspaces = workspaces
+	}
 
 	// Wire team management.
 	broker := team.NewPermissionBroker(db)
+	broker.Policy = cfg.Policy.For
 	wm := team.NewWorkerManager(db, cfg.MaxConcurrentWorkers)
+	wm.SetLimits(cfg.MaxConcurrentWorkers, cfg.WorkerPoolSize, cfg.ReservedPrioritySlots)
+	wm.SetRoleLimits(cfg.WorkerRoleLimits)
+	wm.Queue = cfg.QueueWorkers
 	supervisor := team.NewSupervisor(db, wm, team.SupervisorConfig{
 		CheckIntervalSec: cfg.CheckIntervalSec,
 		HeartbeatMaxAge:  cfg.HeartbeatMaxAge,
+		MaxReplacements:  cfg.MaxReplacements,
+		ProgressMaxAge:   cfg.ProgressMaxAge,
 	})
 
 	// Wire provider registry.
 	registry := mcp.NewProviderRegistry()
-	for name, pc := range cfg.Providers {
-		if err := registry.Register(mcp.ProviderSpec{
-			Name:    domain.Provider(name),
-			Command: pc.Command,
-			Args:    pc.Args,
-			Env:     pc.Env,
-		}); err != nil {
-			log.Fatalf("register provider %s: %v", name, err)
-		}
+	checkScenarios(cfg)
+	registry.Replace(providerSpecs(cfg.Providers))
+	for ns, n := range cfg.Namespaces {
+		registry.SetNamespace(ns, providerSpecs(n.Providers))
+	}
+	registered, err := (&store.ProviderRepo{}).List(context.Background(), db)
+	if err != nil {
+		log.Fatalf("load providers: %v", err)
+	}
+	for _, p := range registered {
+		registry.SetRuntime(mcp.ProviderSpec{Name: p.Name, Command: p.Command, Args: p.Args, Env: p.Env, Adapter: p.Adapter}, mcp.ProviderStatus{})
+	}
+	providerCheck := mcp.ProviderCheck{
+		SmokeArgs: cfg.ProviderChecks.SmokeArgs,
+		Timeout:   time.Duration(cfg.ProviderChecks.TimeoutSec) * time.Second,
+	}
+	if cfg.ProviderChecks.Enabled {
+		checkProviders(registry, providerCheck, cfg.ProviderChecks.Required)
 	}
 
 	// Shared repos.
@@ -95,32 +273,277 @@ func main() {
 	auditRepo := &store.AuditRepo{}
 	eventRepo := &store.EventRepo{}
 	workerRepo := &store.WorkerRepo{}
+	intentRepo := &store.IntentRepo{}
 	scoreCardRepo := &store.ScoreCardRepo{}
 	taskRepo := &store.TaskRepo{}
+	sessionEventRepo := &store.SessionEventRepo{}
+	consensus := review.NewConsensusEngineWith(cfg.Consensus)
+	consensus.Policies = make(map[domain.Phase]domain.VerdictPolicy)
+	for phase, policy := range cfg.VerdictPolicies {
+		consensus.Policies[domain.Phase(phase)] = policy
+	}
 
 	// Wire session manager, guard, and bridge.
 	sessions := mcp.NewSessionManager(registry)
-	g := guard.NewGuard(db, gov, broker, guard.GuardConfig{
-		MaxRounds:          cfg.MaxRounds,
-		RateLimitPerMinute: cfg.RateLimitPerMinute,
-	})
+	if sessions.Secrets, err = newSecrets(cfg.Secrets); err != nil {
+		log.Fatalf("secrets: %v", err)
+	}
+	sessions.Secrets.OnResolve = redactor.AddSecret
+	if gov.Notifier, err = newNotifier(cfg, sessions.Secrets); err != nil {
+		log.Fatalf("notifications: %v", err)
+	}
+	workflow.AnnounceFlows(engine, gov.Notifier)
+	flowHooks, err := newHooks(engine, cfg.Hooks, sessions.Secrets)
+	if err != nil {
+		log.Fatalf("hooks: %v", err)
+	}
+	g := guard.NewGuard(db, gov, broker, guardConfig(cfg))
+	g.CacheTTL = time.Duration(cfg.GuardCacheTTLMS) * time.Millisecond
+	if bh := cfg.GuardRules.BusinessHours; bh != nil {
+		start, end, days, loc, _ := bh.Window() // validated by Load
+		g.Register(&guard.BusinessHours{Start: start, End: end, Days: days, Location: loc})
+	}
+	if cfg.RateLimitPersist {
+		g.RateRepo = &store.RateBucketRepo{}
+	}
 
 	b := bridge.NewBridge(sessions, g, gov, costDeltaRepo, auditRepo, db)
+	b.TranscriptRepo = sessionEventRepo
+	// Keep session output no adapter could parse instead of dropping it.
+	sessions.OnDeadLetter = b.RecordDeadLetter
+	sessions.Chaos = monkey
+
+	// Batch cost writes from chatty sessions.
+	costBatcher := workflow.NewCostBatcher(gov, costDeltaRepo)
+	costBatcher.MaxBatch = cfg.CostBatchSize
+	costBatcher.Interval = time.Duration(cfg.CostFlushIntervalMS) * time.Millisecond
+	b.CostBatcher = costBatcher
+
+	// Wire the phase orchestrator that drives sessions on phase entry.
+	digests := team.NewDigestBuilder(db)
+	digests.StandardsFile = cfg.CodingStandards
+	if digests.Objectives, err = team.ParseObjectiveTemplates(cfg.ObjectiveTemplates); err != nil {
+		log.Fatalf("objective templates: %v", err)
+	}
+	orch := orchestrator.New(engine, wm, b, digests, workerPlans(cfg), cfg.Workspace)
+	orch.DigestFormat = cfg.DigestFormat
+	orch.Permissions = broker
+	orch.Chaos = monkey
+
+	// Compact context on phase entry, before the orchestrator builds the
+	// digests of the phase's workers.
+	engine.AddListener(team.NewCompactor(db).OnTransition)
+
+	runCtx, stopRun := context.WithCancel(context.Background())
+	defer stopRun()
+	orch.Start(runCtx)
+	costBatcher.Start(runCtx)
+	backups := backup.NewScheduler(db, cfg.Backup.Dir, time.Duration(cfg.Backup.IntervalSec)*time.Second, cfg.Backup.Keep)
+
+	// Wire the scheduler that starts queued flows as slots free up.
+	engine.MaxConcurrentFlows = cfg.MaxConcurrentFlows
+	scheduler := workflow.NewScheduler(engine, cfg.MaxConcurrentFlows)
+	scheduler.Preempt = cfg.PreemptFlows
+
+	// Reload safe-to-change settings when the config file changes or on SIGHUP.
+	reload := &reloader{
+		Registry:  registry,
+		Guard:     g,
+		Governor:  gov,
+		Workers:   wm,
+		Orch:      orch,
+		AuditRepo: auditRepo,
+		DB:        db,
+		current:   cfg,
+	}
+	watcher := config.NewWatcher(cfgPath, time.Duration(cfg.WatchIntervalSec)*time.Second, reload.apply)
+	watcher.OnError = reload.fail
+	watcher.Overrides = overrides
+	watcher.Start(runCtx)
+
+	// Wire auto-advance for flows created with auto_advance enabled.
+	bus := eventbus.New()
+	workflow.NewAutoAdvancer(engine, bus).Start(runCtx)
+
+	// Announce flow writes so the guard's snapshot cache stays fresh.
+	engine.Bus = bus
+	gov.Bus = bus
+	g.Watch(runCtx, bus)
+
+	// Run the configured hooks as flows enter and leave phases.
+	if len(cfg.Hooks) > 0 {
+		flowHooks.Start(runCtx, bus)
+	}
+
+	// Mirror flows into tracker issues; the leader sends the queued changes.
+	var issues *tracker.Syncer
+	if cfg.IssueSync.Provider != "" {
+		issues = newIssueSyncer(db, engine, cfg.IssueSync)
+		issues.Consensus = consensus
+		issues.Start(runCtx, bus)
+	}
+
+	// Advance flows blocked on a gate once their blockers clear.
+	if ciGate != nil {
+		ciGate.Poll(runCtx, bus, time.Duration(cfg.CIGate.PollIntervalSec)*time.Second)
+	}
+	workflow.NewUnblockManager(engine, bus).Start(runCtx)
+
+	// Let the supervisor block flows whose workers keep timing out.
+	supervisor.Flows = engine
+	supervisor.Bus = bus
+	supervisor.Chaos = monkey
+
+	// Let the supervisor reap expired intent leases. Flows sharing a
+	// workspace may not lock the same file.
+	shared := team.NewSharedWorkspaces(db, cfg.Workspace)
+	supervisor.Intents = &team.IntentResolver{
+		DB:              db,
+		IntentRepo:      intentRepo,
+		WorkerRepo:      workerRepo,
+		AuditRepo:       auditRepo,
+		Bus:             bus,
+		Events:          engine,
+		Queue:           cfg.IntentQueue.Enabled,
+		QueueTimeoutSec: cfg.IntentQueue.TimeoutSec,
+		Shared:          shared,
+	}
+
+	// Let the supervisor resolve conflicting intents per task strategy.
+	conflicts := team.NewConflictDetector(db)
+	conflicts.Strategy = cfg.Conflicts.StrategyFor
+	conflicts.Shared = shared
+	supervisor.Conflicts = conflicts
+
+	// Run the maintenance loops only on the instance holding the engine
+	// lease, so instances sharing the database do not replace the same
+	// worker or start the same queued flow twice. Every instance serves
+	// the API.
+	elector := leader.New(db, leader.DefaultHolder(), time.Duration(cfg.LeaderLeaseSec)*time.Second)
+	elector.Run(runCtx, func(ctx context.Context) {
+		log.Printf("leading background loops as %s", elector.Holder)
+		if _, err := orch.Recover(ctx); err != nil {
+			log.Printf("recover running flows: %v", err)
+		}
+		retainer.Start(ctx)
+		backups.Start(ctx)
+		scheduler.Start(ctx)
+		supervisor.StartMonitoring(ctx)
+		workflow.NewSLAMonitor(engine, time.Duration(cfg.CheckIntervalSec)*time.Second).Start(ctx)
+		if issues != nil {
+			issues.Run(ctx)
+		}
+	})
 
 	// Wire IPC handler.
 	handler := &ipc.Handler{
-		Engine:        engine,
-		Bridge:        b,
-		Guard:         g,
-		DB:            db,
-		EventRepo:     eventRepo,
-		WorkerRepo:    workerRepo,
-		ScoreCardRepo: scoreCardRepo,
-		CostDeltaRepo: costDeltaRepo,
-		TaskRepo:      taskRepo,
+		Engine:           engine,
+		Scheduler:        scheduler,
+		Bridge:           b,
+		Guard:            g,
+		DB:               db,
+		ReadDB:           readDB,
+		EventRepo:        eventRepo,
+		WorkerRepo:       workerRepo,
+		IntentRepo:       intentRepo,
+		DecisionRepo:     &store.DecisionRepo{},
+		ArtifactRepo:     &store.ArtifactRepo{},
+		ConstraintRepo:   &store.ConstraintRepo{},
+		CIChecks:         &store.CICheckRepo{},
+		RiskRepo:         &store.RiskRepo{},
+		ScoreCardRepo:    scoreCardRepo,
+		IssueRepo:        &store.IssueRepo{},
+		RoundRepo:        &store.ReviewRoundRepo{},
+		Consensus:        consensus,
+		CostDeltaRepo:    costDeltaRepo,
+		BudgetAlertRepo:  gov.AlertRepo,
+		TaskRepo:         taskRepo,
+		SessionEventRepo: sessionEventRepo,
+		Bus:              bus,
+		Bundler:          bundle.New(db),
+		Conflicts:        conflicts,
+		Workers:          wm,
+		Blobs:            artifact.NewBlobs(cfg.ArtifactDir),
+		Digests:          digests,
+		Approvals:        approvals,
+		Leader:           elector,
+		Readiness:        health.NewChecker(db, registry.List, cfg.Workspace, uint64(cfg.MinFreeDiskMB)<<20),
+	}
+	handler.Reports = report.New(db, engine, handler.Blobs)
+	// Store a run report for every flow that completes or fails.
+	handler.Reports.Start(runCtx, bus)
+	handler.Exec = sandbox.NewExecutor(db, g, handler.Blobs, cfg.Exec.AllowedCommands, cfg.Workspace)
+	handler.Exec.Timeout = time.Duration(cfg.Exec.TimeoutSec) * time.Second
+	handler.Exec.MaxOutput = cfg.Exec.MaxOutputBytes
+	handler.Redactor = redactor
+	handler.TaskCache = taskCache
+	handler.Providers = registry
+	handler.Chaos = monkey
+	handler.Purger = newPurger(db, cfg)
+	handler.ProviderCheck = providerCheck
+	if cfg.CIGate.Provider == "webhook" {
+		secret, err := sessions.Secrets.Resolve(context.Background(), cfg.CIGate.WebhookSecret)
+		if err != nil {
+			fatal(fmt.Sprintf("ci_gate.webhook_secret: %v", err))
+		}
+		handler.CISecret = []byte(secret)
+		handler.CIBranchPrefix = cfg.Git.BranchPrefix
+	}
+	handler.HTTP = ipc.HTTPConfig{
+		ReadTimeout:    time.Duration(cfg.HTTP.ReadTimeoutSec) * time.Second,
+		IdleTimeout:    time.Duration(cfg.HTTP.IdleTimeoutSec) * time.Second,
+		RequestTimeout: time.Duration(cfg.HTTP.RequestTimeoutSec) * time.Second,
+		RouteTimeouts:  make(map[string]time.Duration, len(cfg.HTTP.RouteTimeoutsSec)),
+		MaxBodyBytes:   cfg.HTTP.MaxBodyBytes,
+		LogRequests:    cfg.HTTP.LogRequests,
 	}
+	for route, sec := range cfg.HTTP.RouteTimeoutsSec {
+		handler.HTTP.RouteTimeouts[route] = time.Duration(sec) * time.Second
+	}
+	handler.Files = sandbox.NewFiles(db, broker, supervisor.Intents, cfg.Workspace)
+	handler.Files.Guard = g
+	if cfg.Git.Enabled {
+		gm := git.New(db, cfg.Workspace)
+		gm.BranchPrefix = cfg.Git.BranchPrefix
+		gm.AuthorName = cfg.Git.AuthorName
+		gm.AuthorEmail = cfg.Git.AuthorEmail
+		gm.Start(engine)
+		handler.Git = gm
 
-	srv := ipc.NewServer(handler, cfg.ListenAddr)
+		if pr := cfg.PullRequests; pr.Provider != "" {
+			token := os.Getenv(pr.TokenEnv)
+			var client pullrequest.Client = &pullrequest.GitHub{APIURL: pr.APIURL, Repo: pr.Repo, Token: token}
+			if pr.Provider == "gitlab" {
+				client = &pullrequest.GitLab{APIURL: pr.APIURL, Project: pr.Repo, Token: token}
+			}
+			opener := pullrequest.New(db, engine, gm, client)
+			opener.Remote = pr.Remote
+			opener.BaseBranch = pr.BaseBranch
+			opener.Consensus = consensus
+			opener.Start(runCtx)
+		}
+	}
+
+	// Take the next free port when the configured one is in use.
+	ln, listenAddr, err := instance.Listen(cfg.ListenAddr, cfg.ListenPortTries)
+	if err != nil {
+		fatal(fmt.Sprintf("listen on %s: %v", cfg.ListenAddr, err))
+	}
+	if listenAddr != cfg.ListenAddr {
+		log.Printf("%s is in use; listening on %s instead", cfg.ListenAddr, listenAddr)
+	}
+	// Name the port actually bound, which differs from listenAddr's when it
+	// asks for port 0, under the configured host.
+	host, _, _ := net.SplitHostPort(listenAddr)
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	url := ipc.FormatListenURL(net.JoinHostPort(host, port))
+	sessions.SetAPIURL(url)
+	if lock != nil {
+		if err := lock.SetURL(url); err != nil {
+			log.Printf("instance lock: %v", err)
+		}
+	}
+	srv := ipc.NewServer(handler, listenAddr)
 
 	// Graceful shutdown on interrupt.
 	sigCh := make(chan os.Signal, 1)
@@ -131,7 +554,19 @@ func main() {
 		log.Println("shutting down...")
 
 		supervisor.StopMonitoring()
-		sessions.StopAll()
+		if err := elector.Resign(context.Background()); err != nil {
+			log.Printf("resign leadership: %v", err)
+		}
+		// Pause the flows whose sessions run here and record why, stop the
+		// sessions and their workers, and write pending costs.
+		drain := shutdown.New(engine, sessions, wm, costBatcher)
+		drain.Instance = elector.Holder
+		drain.Grace = time.Duration(cfg.ShutdownGraceSec) * time.Second
+		if err := drain.Drain(context.Background()); err != nil {
+			log.Printf("drain: %v", err)
+		}
+		orch.Stop()
+		gov.Notifier.Wait()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
@@ -140,29 +575,436 @@ func main() {
 		}
 	}()
 
-	url := ipc.FormatListenURL(cfg.ListenAddr)
 	log.Printf("three-body engine listening on %s", url)
 
-	_ = supervisor
 	_ = wm
 
-	if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
 		fatal(fmt.Sprintf("server error: %v", err))
 	}
 }
 
-// discoverConfig looks for config.json next to the executable, then in the cwd.
+// checkProviders validates every provider and reports the broken ones all
+// at once, exiting when required is set.
+func checkProviders(registry *mcp.ProviderRegistry, check mcp.ProviderCheck, required bool) {
+	var broken []string
+	for _, st := range registry.Validate(context.Background(), check) {
+		if st.OK {
+			continue
+		}
+		name := string(st.Name)
+		if st.Namespace != "" {
+			name = st.Namespace + "/" + name
+		}
+		broken = append(broken, fmt.Sprintf("  %s: %s", name, st.Error))
+	}
+	if len(broken) == 0 {
+		return
+	}
+	msg := "broken providers:\n" + strings.Join(broken, "\n")
+	if required {
+		fatal(msg)
+	}
+	log.Print(msg)
+}
+
+// newMonkey returns the fault injector of cfg, warning that it is on.
+func newMonkey(cfg config.ChaosConfig) *chaos.Monkey {
+	log.Printf("WARNING: chaos testing is enabled: sessions killed at %.2f, events malformed at %.2f, heartbeats dropped at %.2f, writes delayed %dms at %.2f",
+		cfg.KillSessionRate, cfg.MalformedEventRate, cfg.DropHeartbeatRate, cfg.WriteDelayMS, cfg.WriteDelayRate)
+	return chaos.New(chaos.Config{
+		KillSessionRate:    cfg.KillSessionRate,
+		MalformedEventRate: cfg.MalformedEventRate,
+		DropHeartbeatRate:  cfg.DropHeartbeatRate,
+		WriteDelayRate:     cfg.WriteDelayRate,
+		WriteDelay:         time.Duration(cfg.WriteDelayMS) * time.Millisecond,
+		Seed:               int64(cfg.Seed),
+	})
+}
+
+// checkScenarios loads the scenario of every mock provider, so a broken one
+// stops the engine instead of failing each of its sessions.
+func checkScenarios(cfg *config.Config) {
+	check := func(name string, pc config.ProviderConfig) {
+		if pc.Scenario == "" {
+			return
+		}
+		if _, err := mock.Load(pc.Scenario); err != nil {
+			fatal(fmt.Sprintf("provider %s: %v", name, err))
+		}
+	}
+	for name, pc := range cfg.Providers {
+		check(name, pc)
+	}
+	for ns, n := range cfg.Namespaces {
+		for name, pc := range n.Providers {
+			check(ns+"/"+name, pc)
+		}
+	}
+}
+
+// newRedactor builds the payload redactor from the built-in rules plus the
+// configured ones, or returns nil when redaction is disabled.
+func newRedactor(cfg config.RedactionConfig) (*redact.Redactor, error) {
+	if cfg.Disabled {
+		return nil, nil
+	}
+	patterns := make(map[string]string, len(redact.DefaultPatterns)+len(cfg.Patterns))
+	for name, p := range redact.DefaultPatterns {
+		patterns[name] = p
+	}
+	for name, p := range cfg.Patterns {
+		patterns[name] = p
+	}
+	keywords := append(append([]string{}, redact.DefaultKeywords...), cfg.Keywords...)
+	return redact.New(patterns, keywords)
+}
+
+// newSecrets creates the resolver for provider env secret references, with
+// the keychain and, when configured, the encrypted secrets file.
+func newSecrets(cfg config.SecretsConfig) (*secrets.Resolver, error) {
+	r := secrets.NewResolver()
+	r.Register("keychain", secrets.NewKeychain(cfg.KeychainService))
+	if cfg.File != "" {
+		f, err := openSecretsFile(cfg)
+		if err != nil {
+			return nil, err
+		}
+		r.Register("file", f)
+	}
+	return r, nil
+}
+
+// newNotifier creates the dispatcher for the configured notification
+// channels, resolving secret references in webhook headers and SMTP
+// passwords, and routes flow lifecycle events to the channels listing them.
+func newNotifier(cfg *config.Config, r *secrets.Resolver) (*notify.Dispatcher, error) {
+	channels := make(map[string]notify.Channel, len(cfg.Notifications))
+	routes := make(map[string][]string)
+	for name, ch := range cfg.Notifications {
+		switch ch.Type {
+		case "webhook":
+			headers, err := r.ResolveEnv(context.Background(), ch.Headers)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			channels[name] = &notify.Webhook{URL: ch.URL, Headers: headers}
+		case "log":
+			channels[name] = notify.Log{}
+		case "email":
+			email, err := newEmail(ch, r)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			channels[name] = email
+		}
+		for _, event := range ch.Events {
+			routes[event] = append(routes[event], name)
+		}
+	}
+	d := notify.NewDispatcher(channels)
+	d.SetRoutes(routes)
+	return d, nil
+}
+
+// newEmail creates an email channel, parsing its templates.
+func newEmail(ch config.ChannelConfig, r *secrets.Resolver) (*notify.Email, error) {
+	password, err := r.Resolve(context.Background(), ch.Password)
+	if err != nil {
+		return nil, err
+	}
+	email := &notify.Email{
+		Addr:        net.JoinHostPort(ch.Host, strconv.Itoa(ch.Port)),
+		Username:    ch.Username,
+		Password:    password,
+		ImplicitTLS: ch.TLS,
+		From:        ch.From,
+		To:          ch.To,
+		Templates:   make(map[string]*notify.Template, len(ch.Templates)),
+		Batch:       make(map[string]bool, len(ch.Batch)),
+		BatchWindow: time.Duration(ch.BatchWindowSec) * time.Second,
+	}
+	for event, t := range ch.Templates {
+		if email.Templates[event], err = notify.ParseTemplate(t.Subject, t.Body); err != nil {
+			return nil, fmt.Errorf("template %s: %w", event, err)
+		}
+	}
+	for _, event := range ch.Batch {
+		email.Batch[event] = true
+	}
+	return email, nil
+}
+
+// newHooks creates the registry of configured hooks, resolving secret
+// references in webhook headers and loading plugins.
+func newHooks(engine *workflow.Engine, configured []config.HookConfig, r *secrets.Resolver) (*hooks.Registry, error) {
+	registry := hooks.NewRegistry(engine)
+	for _, h := range configured {
+		reg := hooks.Registration{
+			Name:     h.Name,
+			Points:   h.Points,
+			Timeout:  time.Duration(h.TimeoutSec) * time.Second,
+			FailOpen: h.FailOpen,
+		}
+		for _, p := range h.Phases {
+			reg.Phases = append(reg.Phases, domain.Phase(p))
+		}
+		switch h.Type {
+		case "webhook":
+			headers, err := r.ResolveEnv(context.Background(), h.Headers)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", h.Name, err)
+			}
+			reg.Hook = &hooks.Webhook{URL: h.URL, Headers: headers}
+		case "exec":
+			reg.Hook = &hooks.Exec{Command: h.Command, Args: h.Args, Dir: h.Dir}
+		case "plugin":
+			hook, err := hooks.OpenPlugin(h.Path, h.Symbol)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", h.Name, err)
+			}
+			reg.Hook = hook
+		}
+		registry.Register(reg)
+	}
+	return registry, nil
+}
+
+// budgetAlertRules converts the configured budget alerts.
+func budgetAlertRules(alerts []config.BudgetAlertConfig) []workflow.BudgetAlertRule {
+	rules := make([]workflow.BudgetAlertRule, len(alerts))
+	for i, a := range alerts {
+		rules[i] = workflow.BudgetAlertRule{Name: a.Name, Ratio: a.Ratio, Channels: a.Channels}
+	}
+	return rules
+}
+
+// newPurger returns a Purger that erases a task's files wherever cfg puts
+// them: artifact content, per-task workspaces, digests, and archives.
+func newPurger(db *sql.DB, cfg *config.Config) *purge.Purger {
+	p := purge.NewPurger(db)
+	p.Blobs = artifact.NewBlobs(cfg.ArtifactDir)
+	p.Workspace = cfg.Workspace
+	p.ArchiveDir = cfg.Retention.ArchiveDir
+	if cfg.Workspaces.Root != "" {
+		p.Workspaces = workspace.NewManager(db, cfg.Workspaces.Root)
+		p.Workspaces.Repo = cfg.Workspace
+		p.Workspaces.Worktree = cfg.Workspaces.Worktree
+		p.Workspaces.ArchiveDir = cfg.Workspaces.ArchiveDir
+	}
+	return p
+}
+
+// newIssueSyncer builds the tracker client and syncer for cfg.
+func newIssueSyncer(db *sql.DB, engine *workflow.Engine, cfg config.IssueSyncConfig) *tracker.Syncer {
+	token := os.Getenv(cfg.TokenEnv)
+	var client tracker.Client = &tracker.Jira{
+		URL:            cfg.URL,
+		Email:          cfg.Email,
+		Token:          token,
+		IssueType:      cfg.IssueType,
+		Labels:         cfg.Labels,
+		DoneTransition: cfg.DoneState,
+	}
+	if cfg.Provider == "linear" {
+		client = &tracker.Linear{APIURL: cfg.URL, Token: token, LabelIDs: cfg.Labels, DoneStateID: cfg.DoneState}
+	}
+	s := tracker.New(db, engine, client, cfg.Project)
+	s.Projects = cfg.NamespaceProjects
+	s.Interval = time.Duration(cfg.IntervalSec) * time.Second
+	s.MaxBackoff = time.Duration(cfg.RetryMaxSec) * time.Second
+	s.MaxAttempts = cfg.MaxAttempts
+	return s
+}
+
+// newKeyring resolves the configured payload encryption keys through the
+// secrets backends, or returns nil when encryption is off.
+func newKeyring(cfg *config.Config) (*seal.Keyring, error) {
+	if !cfg.Encryption.Enabled() {
+		return nil, nil
+	}
+	r, err := newSecrets(cfg.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	resolve := func(ref string) ([]byte, error) {
+		v, err := r.Resolve(context.Background(), ref)
+		if err != nil {
+			return nil, err
+		}
+		key, err := secrets.ParseKey(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		return key, nil
+	}
+	var current []byte
+	if cfg.Encryption.Key != "" {
+		if current, err = resolve(cfg.Encryption.Key); err != nil {
+			return nil, err
+		}
+	}
+	previous := make([][]byte, 0, len(cfg.Encryption.PreviousKeys))
+	for _, ref := range cfg.Encryption.PreviousKeys {
+		key, err := resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+	return seal.NewKeyring(current, previous...)
+}
+
+// installKeyring makes the store seal and open payloads with the configured
+// keys.
+func installKeyring(cfg *config.Config) {
+	k, err := newKeyring(cfg)
+	if err != nil {
+		fatal(fmt.Sprintf("encryption: %v", err))
+	}
+	store.SetKeyring(k)
+}
+
+// openSecretsFile opens the configured secrets file with the key from the
+// environment.
+func openSecretsFile(cfg config.SecretsConfig) (*secrets.File, error) {
+	encoded := os.Getenv(cfg.KeyEnv)
+	if encoded == "" {
+		return nil, fmt.Errorf("%s must hold the key of %s", cfg.KeyEnv, cfg.File)
+	}
+	key, err := secrets.ParseKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return secrets.OpenFile(cfg.File, key)
+}
+
+// retentionPolicies converts the configured per-table limits, in table order.
+func retentionPolicies(cfg config.RetentionConfig) []store.RetentionPolicy {
+	tables := make([]string, 0, len(cfg.Tables))
+	for table := range cfg.Tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	policies := make([]store.RetentionPolicy, 0, len(tables))
+	for _, table := range tables {
+		t := cfg.Tables[table]
+		policies = append(policies, store.RetentionPolicy{
+			Table:          table,
+			MaxAgeSec:      int64(t.MaxAgeDays) * 24 * 3600,
+			MaxRowsPerTask: t.MaxRowsPerTask,
+		})
+	}
+	return policies
+}
+
+// runCompact applies the retention policies once and reclaims the freed space.
+func runCompact(db *sql.DB, retainer *retention.Manager) error {
+	ctx := context.Background()
+	reports, err := retainer.Run(ctx)
+	for _, r := range reports {
+		if r.Archive != "" {
+			fmt.Printf("%s: deleted %d rows (archived to %s)\n", r.Table, r.Deleted, r.Archive)
+		} else {
+			fmt.Printf("%s: deleted %d rows\n", r.Table, r.Deleted)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	return nil
+}
+
+// loadConfig resolves the config path (--config flag > TB_CONFIG env >
+// auto-discover next to exe) and loads it with overrides applied, exiting on
+// failure. It returns the config and the path it was read from.
+func loadConfig(flagPath string, overrides map[string]string) (*config.Config, string) {
+	path := resolveConfigPath(flagPath)
+	cfg, err := config.LoadWithOverrides(path, overrides)
+	if err != nil {
+		fatal(fmt.Sprintf("load config: %v", err))
+	}
+	return cfg, path
+}
+
+// resolveConfigPath picks the config file to read, exiting if none is found.
+func resolveConfigPath(flagPath string) string {
+	path := flagPath
+	if path == "" {
+		path = os.Getenv("TB_CONFIG")
+	}
+	if path == "" {
+		path = discoverConfig()
+	}
+	if path == "" {
+		fatal("no config found. Place config.json next to the exe, use --config <path>, or set TB_CONFIG.")
+	}
+	return path
+}
+
+// overrideFlags registers a flag on fs for every overridable config key and
+// alias. The returned function reports the flags given on the command line,
+// keyed by the name config.Override accepts.
+func overrideFlags(fs *flag.FlagSet) func() map[string]string {
+	names := make(map[string]string)
+	for _, key := range config.Keys() {
+		names[config.FlagName(key)] = key
+		fs.String(config.FlagName(key), "", "override config "+key)
+	}
+	for alias, key := range config.Aliases {
+		names[config.FlagName(alias)] = alias
+		fs.String(config.FlagName(alias), "", "shorthand for --"+config.FlagName(key))
+	}
+	return func() map[string]string {
+		set := make(map[string]string)
+		fs.Visit(func(f *flag.Flag) {
+			if name, ok := names[f.Name]; ok {
+				set[name] = f.Value.String()
+			}
+		})
+		return set
+	}
+}
+
+// configOverrides merges TB_* environment overrides with the given flag
+// overrides. Precedence is flags > environment > config file > defaults.
+func configOverrides(flags map[string]string) map[string]string {
+	overrides := config.EnvOverrides(os.Environ())
+	for name, v := range flags {
+		if key, ok := config.Aliases[name]; ok {
+			if _, full := flags[key]; full {
+				continue
+			}
+			name = key
+		}
+		overrides[name] = v
+	}
+	return overrides
+}
+
+// configNames are the file names discoverConfig looks for, in order.
+var configNames = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// discoverConfig looks for a config file next to the executable, then in the cwd.
 func discoverConfig() string {
+	var dirs []string
 	// Next to executable.
 	if exe, err := os.Executable(); err == nil {
-		candidate := filepath.Join(filepath.Dir(exe), "config.json")
-		if _, err := os.Stat(candidate); err == nil {
-			return candidate
-		}
+		dirs = append(dirs, filepath.Dir(exe))
 	}
 	// Current working directory.
-	if _, err := os.Stat("config.json"); err == nil {
-		return "config.json"
+	dirs = append(dirs, "")
+	for _, dir := range dirs {
+		for _, name := range configNames {
+			candidate := filepath.Join(dir, name)
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate
+			}
+		}
 	}
 	return ""
 }
@@ -177,4 +1019,3 @@ func fatal(msg string) {
 	}
 	os.Exit(1)
 }
-
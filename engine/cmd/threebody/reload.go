@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/config"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/guard"
+	"github.com/anthropics/three-body-engine/internal/mcp"
+	"github.com/anthropics/three-body-engine/internal/orchestrator"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/team"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// reloader applies the reloadable part of a changed config to the running
+// components and records a config_reloaded audit entry.
+type reloader struct {
+	Registry  *mcp.ProviderRegistry
+	Guard     *guard.Guard
+	Governor  *workflow.BudgetGovernor
+	Workers   *team.WorkerManager
+	Orch      *orchestrator.Orchestrator
+	AuditRepo *store.AuditRepo
+	DB        *sql.DB
+
+	mu      sync.Mutex
+	current *config.Config
+}
+
+// apply swaps in next's reloadable fields. Fields that need a restart are
+// logged and listed in the audit record but otherwise ignored.
+func (r *reloader) apply(next *config.Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var applied, ignored []string
+	for _, key := range config.Changed(r.current, next) {
+		if config.Reloadable[key] {
+			applied = append(applied, key)
+		} else {
+			ignored = append(ignored, key)
+		}
+	}
+	if len(applied) == 0 && len(ignored) == 0 {
+		return
+	}
+
+	r.Registry.Replace(providerSpecs(next.Providers))
+	gc := guardConfig(next)
+	gc.Rules = r.current.GuardRules.For // rule registration needs a restart
+	r.Guard.SetConfig(gc)
+	r.Governor.SetThresholds(next.BudgetWarnRatio, next.BudgetHaltRatio)
+	r.Governor.SetAlerts(budgetAlertRules(next.BudgetAlerts))
+	r.Workers.SetLimits(next.MaxConcurrentWorkers, next.WorkerPoolSize, next.ReservedPrioritySlots)
+	r.Workers.SetRoleLimits(next.WorkerRoleLimits)
+	r.Orch.SetPlans(workerPlans(next))
+
+	// Restart-only fields keep their running values, so they are reported on
+	// every reload until the engine restarts.
+	merged := *r.current
+	merged.Providers = next.Providers
+	merged.RateLimitPerMinute = next.RateLimitPerMinute
+	merged.RateLimitBurst = next.RateLimitBurst
+	merged.RateLimits = next.RateLimits
+	merged.MaxRounds = next.MaxRounds
+	merged.BudgetWarnRatio = next.BudgetWarnRatio
+	merged.BudgetHaltRatio = next.BudgetHaltRatio
+	merged.BudgetAlerts = next.BudgetAlerts
+	merged.MaxConcurrentWorkers = next.MaxConcurrentWorkers
+	merged.WorkerPoolSize = next.WorkerPoolSize
+	merged.ReservedPrioritySlots = next.ReservedPrioritySlots
+	merged.WorkerRoleLimits = next.WorkerRoleLimits
+	merged.Phases = next.Phases
+	r.current = &merged
+
+	if len(ignored) > 0 {
+		log.Printf("config reloaded; restart required for: %v", ignored)
+	} else {
+		log.Printf("config reloaded: %v", applied)
+	}
+	r.audit("info", map[string]interface{}{"applied": applied, "restart_required": ignored})
+}
+
+// fail records a reload that was rejected by validation.
+func (r *reloader) fail(err error) {
+	log.Printf("config reload rejected: %v", err)
+	r.audit("warning", map[string]interface{}{"error": err.Error()})
+}
+
+func (r *reloader) audit(severity string, detail map[string]interface{}) {
+	data, _ := json.Marshal(detail)
+	now := time.Now()
+	_ = r.AuditRepo.Record(context.Background(), r.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-config-%d", now.UnixNano()),
+		Category:     "config",
+		Actor:        "system",
+		Action:       "config_reloaded",
+		DecisionJSON: string(data),
+		Severity:     severity,
+		CreatedAt:    now.Unix(),
+	})
+}
+
+// providerSpecs converts configured providers to registry specs. A provider
+// with a scenario runs this binary's mock-agent subcommand.
+func providerSpecs(providers map[string]config.ProviderConfig) []mcp.ProviderSpec {
+	specs := make([]mcp.ProviderSpec, 0, len(providers))
+	for name, pc := range providers {
+		spec := mcp.ProviderSpec{
+			Name:    domain.Provider(name),
+			Command: pc.Command,
+			Args:    pc.Args,
+			Env:     pc.Env,
+			Adapter: pc.Adapter,
+		}
+		if pc.Scenario != "" {
+			spec.Command, spec.Args = mockAgentCommand(pc.Scenario)
+			spec.Adapter = string(domain.ProviderMock)
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// mockAgentCommand returns the command line that replays scenario for one
+// session.
+func mockAgentCommand(scenario string) (string, []string) {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+	if abs, err := filepath.Abs(scenario); err == nil {
+		scenario = abs
+	}
+	return exe, []string{"mock-agent",
+		"--scenario", scenario,
+		"--task", "{{task_id}}",
+		"--phase", "{{phase}}",
+		"--role", "{{role}}",
+		"--api", "{{api_url}}",
+	}
+}
+
+// guardConfig converts the configured round and rate limits for the guard.
+func guardConfig(cfg *config.Config) guard.GuardConfig {
+	gc := guard.GuardConfig{
+		MaxRounds:          cfg.MaxRounds,
+		RateLimitPerMinute: cfg.RateLimitPerMinute,
+		RateBurst:          cfg.RateLimitBurst,
+		Operations:         make(map[string]guard.RateLimit, len(cfg.RateLimits.Operations)),
+		Providers:          make(map[string]guard.RateLimit, len(cfg.RateLimits.Providers)),
+		Rules:              cfg.GuardRules.For,
+	}
+	for op, l := range cfg.RateLimits.Operations {
+		gc.Operations[op] = guard.RateLimit{PerMinute: l.PerMinute, Burst: l.Burst}
+	}
+	for name, l := range cfg.RateLimits.Providers {
+		gc.Providers[name] = guard.RateLimit{PerMinute: l.PerMinute, Burst: l.Burst}
+	}
+	for _, step := range cfg.RateLimits.Adaptive.StepsFor(cfg.BudgetWarnRatio) {
+		gc.Adaptive = append(gc.Adaptive, guard.RateStep{BudgetRatio: step.BudgetRatio, Factor: step.RateFactor})
+	}
+	return gc
+}
+
+// workerPlans converts the configured phase workers to orchestrator plans.
+func workerPlans(cfg *config.Config) map[domain.Phase][]orchestrator.WorkerPlan {
+	plans := make(map[domain.Phase][]orchestrator.WorkerPlan, len(cfg.Phases))
+	for phase, workers := range cfg.Phases {
+		for _, w := range workers {
+			plans[domain.Phase(phase)] = append(plans[domain.Phase(phase)], orchestrator.WorkerPlan{
+				Role:           w.Role,
+				Provider:       domain.Provider(w.Provider),
+				Count:          w.Count,
+				SoftTimeoutSec: w.SoftTimeoutSec,
+				HardTimeoutSec: w.HardTimeoutSec,
+				Partition:      w.Partition,
+			})
+		}
+	}
+	roles := make([]string, 0, len(cfg.Reviewers))
+	for role := range cfg.Reviewers {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		r := cfg.Reviewers[role]
+		plans[domain.PhaseF] = append(plans[domain.PhaseF], orchestrator.WorkerPlan{
+			Role:           role,
+			Provider:       domain.Provider(r.Provider),
+			Count:          1,
+			SoftTimeoutSec: r.SoftTimeoutSec,
+			HardTimeoutSec: r.HardTimeoutSec,
+			Reviewer:       true,
+		})
+	}
+	return plans
+}
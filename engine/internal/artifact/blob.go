@@ -0,0 +1,106 @@
+// Package artifact stores the content of worker artifacts on disk, addressed
+// by SHA-256 hash so identical content is kept once however often it is
+// submitted.
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// Blobs is a content-addressed store rooted at Dir. A blob with hash h lives
+// at Dir/h[:2]/h.
+type Blobs struct {
+	Dir string
+}
+
+// NewBlobs creates a Blobs rooted at dir. The directory is created on the
+// first Put.
+func NewBlobs(dir string) *Blobs {
+	return &Blobs{Dir: dir}
+}
+
+// Hash returns the hex SHA-256 of content, the key it is stored under.
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Put stores content and returns its hash. Content that is already stored is
+// not written again.
+func (b *Blobs) Put(content []byte) (string, error) {
+	hash := Hash(content)
+	p, err := b.path(hash)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(p); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", fmt.Errorf("create blob dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".blob-*")
+	if err != nil {
+		return "", fmt.Errorf("create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("close blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return "", fmt.Errorf("store blob: %w", err)
+	}
+	return hash, nil
+}
+
+// Open returns the blob stored under hash, or domain.ErrArtifactNotFound if
+// there is none.
+func (b *Blobs) Open(hash string) (*os.File, error) {
+	p, err := b.path(hash)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, domain.ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open blob: %w", err)
+	}
+	return f, nil
+}
+
+// Remove deletes the blob stored under hash. Removing a blob that is not
+// stored is not an error.
+func (b *Blobs) Remove(hash string) error {
+	p, err := b.path(hash)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove blob: %w", err)
+	}
+	return nil
+}
+
+// path maps hash to its file, rejecting anything that is not a hex SHA-256
+// so a hash can never name a file outside Dir.
+func (b *Blobs) path(hash string) (string, error) {
+	if len(hash) != sha256.Size*2 {
+		return "", domain.ErrArtifactNotFound
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", domain.ErrArtifactNotFound
+	}
+	return filepath.Join(b.Dir, hash[:2], hash), nil
+}
@@ -0,0 +1,66 @@
+package artifact
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestBlobs_PutAndOpen(t *testing.T) {
+	b := NewBlobs(filepath.Join(t.TempDir(), "blobs"))
+	content := []byte("# Design\n")
+
+	hash, err := b.Put(content)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if hash != Hash(content) {
+		t.Errorf("hash = %q, want %q", hash, Hash(content))
+	}
+	if again, err := b.Put(content); err != nil || again != hash {
+		t.Errorf("second Put = %q, %v; want %q", again, err, hash)
+	}
+	entries, _ := os.ReadDir(filepath.Join(b.Dir, hash[:2]))
+	if len(entries) != 1 {
+		t.Errorf("expected one stored blob, got %d", len(entries))
+	}
+
+	f, err := b.Open(hash)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	got, _ := io.ReadAll(f)
+	if string(got) != string(content) {
+		t.Errorf("content = %q, want %q", got, content)
+	}
+}
+
+func TestBlobs_OpenMissing(t *testing.T) {
+	b := NewBlobs(t.TempDir())
+	for _, hash := range []string{Hash([]byte("absent")), "../../etc/passwd", ""} {
+		if _, err := b.Open(hash); err != domain.ErrArtifactNotFound {
+			t.Errorf("Open(%q) = %v, want ErrArtifactNotFound", hash, err)
+		}
+	}
+}
+
+func TestBlobs_Remove(t *testing.T) {
+	b := NewBlobs(t.TempDir())
+	hash, _ := b.Put([]byte("secret notes"))
+	if err := b.Remove(hash); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := b.Open(hash); err != domain.ErrArtifactNotFound {
+		t.Errorf("Open after Remove = %v, want ErrArtifactNotFound", err)
+	}
+	if err := b.Remove(hash); err != nil {
+		t.Errorf("second Remove = %v, want nil", err)
+	}
+	if err := b.Remove("../../etc/passwd"); err != domain.ErrArtifactNotFound {
+		t.Errorf("Remove(traversal) = %v, want ErrArtifactNotFound", err)
+	}
+}
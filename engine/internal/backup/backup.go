@@ -0,0 +1,110 @@
+// Package backup takes periodic online snapshots of the engine database and
+// prunes old ones.
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// filePrefix and fileSuffix bracket the timestamp in snapshot file names.
+const (
+	filePrefix = "threebody-"
+	fileSuffix = ".db"
+)
+
+// Scheduler writes a snapshot to Dir every Interval and keeps the newest Keep.
+type Scheduler struct {
+	DB       *sql.DB
+	Dir      string
+	Interval time.Duration
+	// Keep bounds how many snapshots are retained; zero keeps them all.
+	Keep int
+}
+
+// NewScheduler creates a Scheduler writing into dir.
+func NewScheduler(db *sql.DB, dir string, interval time.Duration, keep int) *Scheduler {
+	return &Scheduler{DB: db, Dir: dir, Interval: interval, Keep: keep}
+}
+
+// Start takes a snapshot every Interval until ctx is cancelled. A zero
+// Interval disables the loop.
+func (s *Scheduler) Start(ctx context.Context) {
+	if s.Interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_, _ = s.Run(ctx)
+			}
+		}
+	}()
+}
+
+// Run takes one snapshot, prunes old ones, and returns the new file's path.
+func (s *Scheduler) Run(ctx context.Context) (string, error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("create backup dir: %w", err)
+	}
+	name := filePrefix + time.Now().UTC().Format("20060102T150405.000000000") + fileSuffix
+	path := filepath.Join(s.Dir, name)
+	if err := store.Backup(ctx, s.DB, path); err != nil {
+		return "", err
+	}
+	if err := s.prune(); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// List returns the snapshots in Dir, oldest first.
+func (s *Scheduler) List() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), filePrefix) || !strings.HasSuffix(e.Name(), fileSuffix) {
+			continue
+		}
+		paths = append(paths, filepath.Join(s.Dir, e.Name()))
+	}
+	// Timestamps sort lexically.
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (s *Scheduler) prune() error {
+	if s.Keep <= 0 {
+		return nil
+	}
+	paths, err := s.List()
+	if err != nil {
+		return err
+	}
+	for len(paths) > s.Keep {
+		if err := os.Remove(paths[0]); err != nil {
+			return fmt.Errorf("prune backup: %w", err)
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
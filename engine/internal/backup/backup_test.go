@@ -0,0 +1,43 @@
+package backup
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+func TestScheduler_RunKeepsNewest(t *testing.T) {
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	s := NewScheduler(db, filepath.Join(t.TempDir(), "backups"), 0, 2)
+	ctx := context.Background()
+
+	var last string
+	for i := 0; i < 3; i++ {
+		path, err := s.Run(ctx)
+		if err != nil {
+			t.Fatalf("Run %d: %v", i, err)
+		}
+		last = path
+	}
+
+	paths, err := s.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("kept %d snapshots, want 2", len(paths))
+	}
+	if paths[1] != last {
+		t.Errorf("newest snapshot = %q, want %q", paths[1], last)
+	}
+	if err := store.CheckIntegrity(ctx, last); err != nil {
+		t.Errorf("snapshot integrity: %v", err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
 	"github.com/anthropics/three-body-engine/internal/guard"
 	"github.com/anthropics/three-body-engine/internal/mcp"
@@ -18,12 +19,19 @@ import (
 
 // Bridge is the integration layer between the engine and code agent sessions.
 type Bridge struct {
-	Sessions      *mcp.SessionManager
-	Guard         *guard.Guard
-	Governor      *workflow.BudgetGovernor
-	CostDeltaRepo *store.CostDeltaRepo
-	AuditRepo     *store.AuditRepo
-	DB            *sql.DB
+	Sessions       *mcp.SessionManager
+	Guard          *guard.Guard
+	Governor       *workflow.BudgetGovernor
+	CostDeltaRepo  *store.CostDeltaRepo
+	AuditRepo      *store.AuditRepo
+	TranscriptRepo *store.SessionEventRepo
+	TaskRepo       *store.TaskRepo
+	DeadLetterRepo *store.DeadLetterRepo
+	// CostBatcher, if set, buffers cost events instead of writing each one.
+	CostBatcher *workflow.CostBatcher
+	DB          *sql.DB
+	// Clock dates audit records, cost deltas, and requeues.
+	Clock clock.Clock
 }
 
 // NewBridge creates a Bridge with all required dependencies.
@@ -36,16 +44,21 @@ func NewBridge(
 	db *sql.DB,
 ) *Bridge {
 	return &Bridge{
-		Sessions:      sessions,
-		Guard:         g,
-		Governor:      gov,
-		CostDeltaRepo: costDeltaRepo,
-		AuditRepo:     auditRepo,
-		DB:            db,
+		Sessions:       sessions,
+		Guard:          g,
+		Governor:       gov,
+		CostDeltaRepo:  costDeltaRepo,
+		AuditRepo:      auditRepo,
+		TranscriptRepo: &store.SessionEventRepo{},
+		TaskRepo:       &store.TaskRepo{},
+		DeadLetterRepo: &store.DeadLetterRepo{},
+		DB:             db,
+		Clock:          clock.System,
 	}
 }
 
-// StartSession checks the budget guard, creates a code agent session, and logs an audit record.
+// StartSession checks the budget guard and the session rate limits, creates a code agent
+// session, and logs an audit record.
 func (b *Bridge) StartSession(ctx context.Context, worker domain.WorkerRef, cfg domain.SessionConfig) (string, error) {
 	action, err := b.Guard.CheckBudget(ctx, worker.TaskID)
 	if err != nil {
@@ -55,17 +68,44 @@ func (b *Bridge) StartSession(ctx context.Context, worker domain.WorkerRef, cfg
 		return "", domain.ErrBudgetExceeded
 	}
 
-	sessionID, err := b.Sessions.Create(ctx, domain.Provider(worker.Role), cfg)
+	// Workers whose role doubles as the provider name need no explicit provider.
+	provider := cfg.Provider
+	if provider == "" {
+		provider = domain.Provider(worker.Role)
+	}
+	if err := b.Guard.CheckRate(ctx, worker.TaskID, provider, guard.OpSession); err != nil {
+		return "", err
+	}
+	if cfg.WorkerID == "" {
+		cfg.WorkerID = worker.WorkerID
+	}
+	// Sessions launch with the provider overrides of the flow's namespace,
+	// and its phase and remaining budget for env templates.
+	if b.TaskRepo != nil {
+		if state, err := b.TaskRepo.GetByID(ctx, b.DB, worker.TaskID); err == nil {
+			if cfg.Namespace == "" {
+				cfg.Namespace = state.Namespace
+			}
+			if cfg.Phase == "" {
+				cfg.Phase = state.CurrentPhase
+			}
+			if remaining := state.BudgetCapUSD - state.BudgetUsedUSD; remaining > 0 {
+				cfg.BudgetRemainingUSD = remaining
+			}
+		}
+	}
+
+	sessionID, err := b.Sessions.Create(ctx, provider, cfg)
 	if err != nil {
 		return "", fmt.Errorf("bridge start session: create: %w", err)
 	}
 
 	_ = b.AuditRepo.Record(ctx, b.DB, domain.AuditRecord{
-		ID:        fmt.Sprintf("aud-start-%s-%d", sessionID, time.Now().UnixNano()),
-		TaskID:    worker.TaskID,
-		Category:  "session",
-		Actor:     "bridge",
-		Action:    "start_session",
+		ID:       fmt.Sprintf("aud-start-%s-%d", sessionID, time.Now().UnixNano()),
+		TaskID:   worker.TaskID,
+		Category: "session",
+		Actor:    "bridge",
+		Action:   "start_session",
 		RequestJSON: mustJSON(map[string]string{
 			"session_id": sessionID,
 			"worker_id":  worker.WorkerID,
@@ -73,7 +113,7 @@ func (b *Bridge) StartSession(ctx context.Context, worker domain.WorkerRef, cfg
 		}),
 		DecisionJSON: mustJSON(map[string]string{"result": "started"}),
 		Severity:     "info",
-		CreatedAt:    time.Now().Unix(),
+		CreatedAt:    b.Clock.Now().Unix(),
 	})
 
 	return sessionID, nil
@@ -96,24 +136,25 @@ func (b *Bridge) StopSession(ctx context.Context, sessionID string) error {
 	_ = b.Sessions.Stop(sessionID)
 
 	_ = b.AuditRepo.Record(ctx, b.DB, domain.AuditRecord{
-		ID:        fmt.Sprintf("aud-stop-%s-%d", sessionID, time.Now().UnixNano()),
-		TaskID:    taskID,
-		Category:  "session",
-		Actor:     "bridge",
-		Action:    "stop_session",
+		ID:       fmt.Sprintf("aud-stop-%s-%d", sessionID, time.Now().UnixNano()),
+		TaskID:   taskID,
+		Category: "session",
+		Actor:    "bridge",
+		Action:   "stop_session",
 		RequestJSON: mustJSON(map[string]string{
 			"session_id": sessionID,
 		}),
 		DecisionJSON: mustJSON(map[string]string{"result": "stopped"}),
 		Severity:     "info",
-		CreatedAt:    time.Now().Unix(),
+		CreatedAt:    b.Clock.Now().Unix(),
 	})
 
 	return nil
 }
 
 // StreamEvents returns a channel that forwards events from a session.
-// Cost events (Type=="cost") are automatically recorded via the BudgetGovernor and CostDeltaRepo.
+// Every event is appended to the session transcript, and cost events
+// (Type=="cost") are automatically recorded via the BudgetGovernor and CostDeltaRepo.
 func (b *Bridge) StreamEvents(ctx context.Context, sessionID string) (<-chan domain.NormalizedEvent, error) {
 	sess, err := b.Sessions.Get(sessionID)
 	if err != nil {
@@ -131,9 +172,7 @@ func (b *Bridge) StreamEvents(ctx context.Context, sessionID string) (<-chan dom
 				if !ok {
 					return
 				}
-				if ev.Type == "cost" {
-					b.processCostEvent(ctx, sess.Config.TaskID, ev)
-				}
+				b.processEvent(ctx, sess.Config, ev)
 				select {
 				case out <- ev:
 				case <-ctx.Done():
@@ -146,17 +185,109 @@ func (b *Bridge) StreamEvents(ctx context.Context, sessionID string) (<-chan dom
 	return out, nil
 }
 
-// processCostEvent extracts a CostDelta from the event payload and records it.
-func (b *Bridge) processCostEvent(ctx context.Context, taskID string, ev domain.NormalizedEvent) {
+// processEvent appends ev to the session transcript and records the cost
+// of cost events.
+func (b *Bridge) processEvent(ctx context.Context, cfg domain.SessionConfig, ev domain.NormalizedEvent) {
+	b.recordTranscript(ctx, cfg.TaskID, ev)
+	if ev.Type == mcp.EventCost {
+		b.processCostEvent(ctx, cfg, ev)
+	}
+}
+
+// ProcessEvent handles ev as if the session configured by cfg had produced
+// it. Load tests use it to drive the bridge without provider processes.
+func (b *Bridge) ProcessEvent(ctx context.Context, cfg domain.SessionConfig, ev domain.NormalizedEvent) {
+	b.processEvent(ctx, cfg, ev)
+}
+
+// RecordDeadLetter stores a line of session output that could not be
+// parsed. It is meant for mcp.SessionManager.OnDeadLetter.
+func (b *Bridge) RecordDeadLetter(d domain.DeadLetterEvent) {
+	_, _ = b.DeadLetterRepo.Create(context.Background(), b.DB, d)
+}
+
+// RequeueDeadLetter parses a dead letter again with its session's adapter
+// and, if that now succeeds, processes the resulting events as if the
+// session had produced them and marks the dead letter requeued. A line that
+// still cannot be parsed keeps its dead letter, with the new error, and
+// returns ErrDeadLetterInvalid.
+func (b *Bridge) RequeueDeadLetter(ctx context.Context, id int64) ([]domain.NormalizedEvent, error) {
+	d, err := b.DeadLetterRepo.GetByID(ctx, b.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if d.RequeuedAt != 0 {
+		return nil, domain.NewEngineError(domain.ErrDeadLetterInvalid.Code,
+			fmt.Sprintf("dead letter %d was already requeued", id))
+	}
+	events, err := mcp.ParseLine([]byte(d.Raw), d.Adapter, d.Provider, d.SessionID)
+	if err != nil {
+		_ = b.DeadLetterRepo.SetError(ctx, b.DB, id, err.Error())
+		return nil, domain.WrapEngineError(domain.ErrDeadLetterInvalid.Code, domain.ErrDeadLetterInvalid.Message, err)
+	}
+	cfg := domain.SessionConfig{TaskID: d.TaskID, WorkerID: d.WorkerID}
+	for _, ev := range events {
+		b.processEvent(ctx, cfg, ev)
+	}
+	if err := b.DeadLetterRepo.MarkRequeued(ctx, b.DB, id, b.Clock.Now().Unix()); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// processCostEvent extracts a CostDelta from the event payload and records it
+// against the session's task and worker.
+func (b *Bridge) processCostEvent(ctx context.Context, cfg domain.SessionConfig, ev domain.NormalizedEvent) {
+	taskID := cfg.TaskID
 	var delta domain.CostDelta
 	if err := json.Unmarshal(ev.Payload, &delta); err != nil {
 		return
 	}
+	// Sessions turn cumulative totals into deltas. A total that arrives as
+	// is was parsed outside its session, from a dead letter, and the spend
+	// it adds is unknown.
+	var mode mcp.CostPayload
+	_ = json.Unmarshal(ev.Payload, &mode)
+	if mode.Cumulative {
+		return
+	}
+	if delta.Key != "" {
+		delta.Key = ev.SessionID + "/" + delta.Key
+	}
 	delta.Provider = ev.Provider
-	delta.CreatedAt = time.Now().Unix()
+	delta.WorkerID = cfg.WorkerID
+	delta.CreatedAt = b.Clock.Now().Unix()
+	// Providers rarely know the phase, so spend is charged to the phase the
+	// task is in; GetByID serves it from the task cache when there is one.
+	if delta.Phase == "" && b.TaskRepo != nil {
+		if state, err := b.TaskRepo.GetByID(ctx, b.DB, taskID); err == nil {
+			delta.Phase = state.CurrentPhase
+		}
+	}
+
+	if b.CostBatcher != nil {
+		_ = b.CostBatcher.Add(ctx, taskID, delta)
+		return
+	}
 
 	_, _ = b.Governor.RecordUsage(ctx, taskID, delta)
-	_ = b.CostDeltaRepo.Create(ctx, b.DB, taskID, delta)
+}
+
+// recordTranscript persists an event to the session transcript. Payloads that
+// are not valid JSON are stored as a JSON string so the column stays parseable.
+func (b *Bridge) recordTranscript(ctx context.Context, taskID string, ev domain.NormalizedEvent) {
+	payload := string(ev.Payload)
+	if !json.Valid(ev.Payload) {
+		payload = mustJSON(payload)
+	}
+	_, _ = b.TranscriptRepo.Append(ctx, b.DB, domain.SessionEvent{
+		SessionID:   ev.SessionID,
+		TaskID:      taskID,
+		EventType:   ev.Type,
+		Provider:    ev.Provider,
+		PayloadJSON: payload,
+		CreatedAt:   b.Clock.Now().Unix(),
+	})
 }
 
 // mustJSON marshals v to a JSON string, returning "{}" on error.
@@ -2,7 +2,8 @@ package bridge
 
 import (
 	"context"
-	"path/filepath"
+	"errors"
+	"fmt"
 	"runtime"
 	"testing"
 	"time"
@@ -21,11 +22,10 @@ type testHarness struct {
 	DB     *store.TaskRepo
 }
 
-func newHarness(t *testing.T) *testHarness {
+func newHarness(t testing.TB) *testHarness {
 	t.Helper()
 
-	dir := t.TempDir()
-	db, err := store.NewDB(filepath.Join(dir, "test.db"))
+	db, err := store.NewDB(store.MemoryPath)
 	if err != nil {
 		t.Fatalf("NewDB: %v", err)
 	}
@@ -57,7 +57,7 @@ func newHarness(t *testing.T) *testHarness {
 }
 
 // createTask inserts a task with a budget into the test DB.
-func (h *testHarness) createTask(t *testing.T, taskID string, budgetCap float64) {
+func (h *testHarness) createTask(t testing.TB, taskID string, budgetCap float64) {
 	t.Helper()
 	tx, err := h.Bridge.DB.Begin()
 	if err != nil {
@@ -140,6 +140,31 @@ func TestStartSession_GuardDenied(t *testing.T) {
 	}
 }
 
+func TestStartSession_ProviderRateLimited(t *testing.T) {
+	h := newHarness(t)
+	h.createTask(t, "task-a", 100.0)
+	h.createTask(t, "task-b", 100.0)
+	h.Bridge.Guard.SetConfig(guard.GuardConfig{
+		MaxRounds:          10,
+		RateLimitPerMinute: 100,
+		Providers:          map[string]guard.RateLimit{"claude": {PerMinute: 1}},
+	})
+
+	ctx := context.Background()
+	start := func(taskID string) error {
+		worker := domain.WorkerRef{WorkerID: "w-" + taskID, TaskID: taskID, Role: string(domain.ProviderClaude)}
+		_, err := h.Bridge.StartSession(ctx, worker, domain.SessionConfig{TaskID: taskID, Workspace: t.TempDir()})
+		return err
+	}
+	if err := start("task-a"); err != nil {
+		t.Fatalf("first StartSession: %v", err)
+	}
+	// The provider quota is shared, so another task's start is refused.
+	if err := start("task-b"); !errors.Is(err, domain.ErrRateLimitExceeded) {
+		t.Fatalf("second StartSession = %v, want ErrRateLimitExceeded", err)
+	}
+}
+
 func TestStartSession_AuditsAction(t *testing.T) {
 	h := newHarness(t)
 	h.createTask(t, "task-audit-start", 100.0)
@@ -312,3 +337,144 @@ func TestStreamEvents_NotFound(t *testing.T) {
 		t.Fatal("expected error for nonexistent session, got nil")
 	}
 }
+
+func TestStreamEvents_PersistsTranscript(t *testing.T) {
+	h := newHarness(t)
+	h.createTask(t, "task-transcript", 100.0)
+
+	ctx := context.Background()
+	worker := domain.WorkerRef{WorkerID: "w-tr", TaskID: "task-transcript", Role: string(domain.ProviderClaude)}
+	cfg := domain.SessionConfig{TaskID: "task-transcript", Role: string(domain.ProviderClaude), Workspace: t.TempDir()}
+
+	sessionID, err := h.Bridge.StartSession(ctx, worker, cfg)
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	ch, err := h.Bridge.StreamEvents(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("StreamEvents: %v", err)
+	}
+	for range ch {
+	}
+
+	events, err := h.Bridge.TranscriptRepo.ListBySession(ctx, h.Bridge.DB, sessionID, 0)
+	if err != nil {
+		t.Fatalf("ListBySession: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 transcript event, got %d", len(events))
+	}
+	if events[0].TaskID != "task-transcript" || events[0].EventType != "result" || events[0].SeqNo != 1 {
+		t.Errorf("unexpected transcript event: %+v", events[0])
+	}
+}
+
+func TestProcessCostEvent_ChargesWorker(t *testing.T) {
+	h := newHarness(t)
+	h.createTask(t, "task-cost", 100.0)
+	ctx := context.Background()
+
+	cfg := domain.SessionConfig{TaskID: "task-cost", WorkerID: "w-cost"}
+	h.Bridge.processCostEvent(ctx, cfg, domain.NormalizedEvent{
+		Type:     mcp.EventCost,
+		Provider: domain.ProviderClaude,
+		Payload:  []byte(`{"inputTokens":10,"outputTokens":5,"amountUsd":0.25}`),
+	})
+
+	deltas, err := h.Bridge.CostDeltaRepo.ListByTask(ctx, h.Bridge.DB, "task-cost")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(deltas) != 1 || deltas[0].WorkerID != "w-cost" || deltas[0].AmountUSD != 0.25 || deltas[0].Provider != domain.ProviderClaude {
+		t.Errorf("deltas = %+v, want one 0.25 delta charged to w-cost", deltas)
+	}
+}
+
+func TestProcessCostEvent_StampsPhase(t *testing.T) {
+	h := newHarness(t)
+	h.createTask(t, "task-phase", 100.0)
+	ctx := context.Background()
+
+	cfg := domain.SessionConfig{TaskID: "task-phase", WorkerID: "w1"}
+	for _, payload := range []string{
+		`{"amountUsd":0.25}`,
+		`{"amountUsd":0.5,"phase":"C"}`,
+	} {
+		h.Bridge.processCostEvent(ctx, cfg, domain.NormalizedEvent{
+			Type:     mcp.EventCost,
+			Provider: domain.ProviderClaude,
+			Payload:  []byte(payload),
+		})
+	}
+
+	deltas, err := h.Bridge.CostDeltaRepo.ListByTask(ctx, h.Bridge.DB, "task-phase")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(deltas) != 2 || deltas[0].Phase != domain.PhaseA || deltas[1].Phase != domain.PhaseC {
+		t.Errorf("deltas = %+v, want the task's phase A stamped and the reported phase C kept", deltas)
+	}
+}
+
+func TestRequeueDeadLetter(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	repo := h.Bridge.DeadLetterRepo
+
+	h.Bridge.RecordDeadLetter(domain.DeadLetterEvent{
+		SessionID: "ses-dl", TaskID: "task-dl", Provider: domain.ProviderClaude, Adapter: domain.ProviderClaude,
+		Raw: `{"type":"result","data":"late"}`, Error: "truncated line", CreatedAt: 1,
+	})
+	h.Bridge.RecordDeadLetter(domain.DeadLetterEvent{
+		SessionID: "ses-dl", TaskID: "task-dl", Provider: domain.ProviderClaude, Adapter: domain.ProviderClaude,
+		Raw: "still not json", Error: "bad line", CreatedAt: 2,
+	})
+	letters, err := repo.List(ctx, h.Bridge.DB, store.DeadLetterFilter{})
+	if err != nil || len(letters) != 2 {
+		t.Fatalf("List = %+v, %v, want 2", letters, err)
+	}
+	fixable, broken := letters[1], letters[0]
+	isInvalid := func(err error) bool {
+		var engErr *domain.EngineError
+		return errors.As(err, &engErr) && engErr.Code == domain.ErrDeadLetterInvalid.Code
+	}
+
+	events, err := h.Bridge.RequeueDeadLetter(ctx, fixable.ID)
+	if err != nil {
+		t.Fatalf("RequeueDeadLetter: %v", err)
+	}
+	if len(events) != 1 {
+		t.Errorf("events = %+v, want 1", events)
+	}
+	transcript, err := h.Bridge.TranscriptRepo.ListBySession(ctx, h.Bridge.DB, "ses-dl", 0)
+	if err != nil || len(transcript) != 1 || transcript[0].TaskID != "task-dl" {
+		t.Errorf("transcript = %+v, %v, want the requeued event", transcript, err)
+	}
+	if _, err := h.Bridge.RequeueDeadLetter(ctx, fixable.ID); !isInvalid(err) {
+		t.Errorf("second requeue err = %v, want ErrDeadLetterInvalid", err)
+	}
+
+	if _, err := h.Bridge.RequeueDeadLetter(ctx, broken.ID); !isInvalid(err) {
+		t.Errorf("unparseable requeue err = %v, want ErrDeadLetterInvalid", err)
+	}
+	if n, _ := repo.CountPending(ctx, h.Bridge.DB); n != 1 {
+		t.Errorf("pending = %d, want 1", n)
+	}
+}
+
+func BenchmarkBridge_ProcessCostEvent(b *testing.B) {
+	h := newHarness(b)
+	h.createTask(b, "task-bench", 1e9)
+	ctx := context.Background()
+	cfg := domain.SessionConfig{TaskID: "task-bench", WorkerID: "w-bench"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Bridge.ProcessEvent(ctx, cfg, domain.NormalizedEvent{
+			Type:      mcp.EventCost,
+			Provider:  domain.ProviderClaude,
+			SessionID: "ses-bench",
+			Payload:   []byte(fmt.Sprintf(`{"inputTokens":10,"outputTokens":5,"amountUsd":0.001,"key":"%d"}`, i)),
+		})
+	}
+}
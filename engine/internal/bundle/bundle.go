@@ -0,0 +1,323 @@
+// Package bundle exports a task's complete recorded state as a single JSON
+// document and imports it into another engine instance.
+package bundle
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// FormatVersion is written to every bundle; Import rejects other versions.
+const FormatVersion = 1
+
+// unbundledTables lists the per-task tables Export leaves out, because
+// Import rebuilds them or they describe the exporting instance rather than
+// the task. Every other table in store.TaskTables travels in the bundle.
+var unbundledTables = map[string]string{
+	"audit_chains":    "rebuilt by AuditRepo.RecordTx as the audit records are imported",
+	"budget_alerts":   "delivery state of the exporting instance's alerts",
+	"ci_checks":       "re-polled from CI",
+	"issue_links":     "issue tracker sync of the exporting instance",
+	"issue_sync_ops":  "issue tracker sync of the exporting instance",
+	"phase_durations": "SLA timers of the exporting instance",
+}
+
+// Bundler reads and writes TaskBundles.
+type Bundler struct {
+	DB               *sql.DB
+	Clock            clock.Clock
+	TaskRepo         *store.TaskRepo
+	EventRepo        *store.EventRepo
+	SnapshotRepo     *store.SnapshotRepo
+	IntentRepo       *store.IntentRepo
+	WorkerRepo       *store.WorkerRepo
+	ScoreCardRepo    *store.ScoreCardRepo
+	CostDeltaRepo    *store.CostDeltaRepo
+	AuditRepo        *store.AuditRepo
+	SessionEventRepo *store.SessionEventRepo
+	DeadLetterRepo   *store.DeadLetterRepo
+	ArtifactRepo     *store.ArtifactRepo
+	DecisionRepo     *store.DecisionRepo
+	ConstraintRepo   *store.ConstraintRepo
+	RiskRepo         *store.RiskRepo
+	ReviewRoundRepo  *store.ReviewRoundRepo
+	IssueRepo        *store.IssueRepo
+	GateDecisionRepo *store.GateDecisionRepo
+	ApprovalRepo     *store.ApprovalRepo
+}
+
+// New creates a Bundler with default repositories.
+func New(db *sql.DB) *Bundler {
+	return &Bundler{
+		DB:               db,
+		Clock:            clock.System,
+		TaskRepo:         &store.TaskRepo{},
+		EventRepo:        &store.EventRepo{},
+		SnapshotRepo:     &store.SnapshotRepo{},
+		IntentRepo:       &store.IntentRepo{},
+		WorkerRepo:       &store.WorkerRepo{},
+		ScoreCardRepo:    &store.ScoreCardRepo{},
+		CostDeltaRepo:    &store.CostDeltaRepo{},
+		AuditRepo:        &store.AuditRepo{},
+		SessionEventRepo: &store.SessionEventRepo{},
+		DeadLetterRepo:   &store.DeadLetterRepo{},
+		ArtifactRepo:     &store.ArtifactRepo{},
+		DecisionRepo:     &store.DecisionRepo{},
+		ConstraintRepo:   &store.ConstraintRepo{},
+		RiskRepo:         &store.RiskRepo{},
+		ReviewRoundRepo:  &store.ReviewRoundRepo{},
+		IssueRepo:        &store.IssueRepo{},
+		GateDecisionRepo: &store.GateDecisionRepo{},
+		ApprovalRepo:     &store.ApprovalRepo{},
+	}
+}
+
+// Export collects everything recorded for a task.
+func (b *Bundler) Export(ctx context.Context, taskID string) (*domain.TaskBundle, error) {
+	task, err := b.TaskRepo.GetByID(ctx, b.DB, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &domain.TaskBundle{
+		FormatVersion: FormatVersion,
+		ExportedAt:    clock.Or(b.Clock).Now().Unix(),
+		Task:          *task,
+	}
+	if out.Events, err = b.EventRepo.ListByTask(ctx, b.DB, taskID, 0); err != nil {
+		return nil, err
+	}
+	if out.Snapshots, err = b.SnapshotRepo.ListByTask(ctx, b.DB, taskID); err != nil {
+		return nil, err
+	}
+	if out.Intents, err = b.IntentRepo.ListByTask(ctx, b.DB, taskID); err != nil {
+		return nil, err
+	}
+	if out.Workers, err = b.WorkerRepo.ListByTask(ctx, b.DB, taskID); err != nil {
+		return nil, err
+	}
+	if out.ScoreCards, err = b.ScoreCardRepo.ListByTask(ctx, b.DB, taskID); err != nil {
+		return nil, err
+	}
+	if out.CostDeltas, err = b.CostDeltaRepo.ListByTask(ctx, b.DB, taskID); err != nil {
+		return nil, err
+	}
+	if out.Audit, err = b.AuditRepo.ListByTask(ctx, b.DB, taskID); err != nil {
+		return nil, err
+	}
+	if out.SessionEvents, err = b.SessionEventRepo.ListByTask(ctx, b.DB, taskID); err != nil {
+		return nil, err
+	}
+	if out.DeadLetters, err = b.DeadLetterRepo.ListByTask(ctx, b.DB, taskID); err != nil {
+		return nil, err
+	}
+	if out.Artifacts, err = b.ArtifactRepo.ListByTask(ctx, b.DB, taskID); err != nil {
+		return nil, err
+	}
+	if out.Decisions, err = b.DecisionRepo.ListByTask(ctx, b.DB, taskID, ""); err != nil {
+		return nil, err
+	}
+	if out.Constraints, err = b.ConstraintRepo.ListByTask(ctx, b.DB, taskID, ""); err != nil {
+		return nil, err
+	}
+	if out.Risks, err = b.RiskRepo.ListByTask(ctx, b.DB, taskID, ""); err != nil {
+		return nil, err
+	}
+	if out.ReviewRounds, err = b.ReviewRoundRepo.ListByTask(ctx, b.DB, taskID); err != nil {
+		return nil, err
+	}
+	if out.ReviewIssues, err = b.IssueRepo.ListByTask(ctx, b.DB, taskID, ""); err != nil {
+		return nil, err
+	}
+	if out.GateDecisions, err = b.GateDecisionRepo.ListByTask(ctx, b.DB, taskID, "", 0); err != nil {
+		return nil, err
+	}
+	// Gate decisions list newest first; the bundle keeps recording order.
+	for i, j := 0, len(out.GateDecisions)-1; i < j; i, j = i+1, j-1 {
+		out.GateDecisions[i], out.GateDecisions[j] = out.GateDecisions[j], out.GateDecisions[i]
+	}
+	if out.Approvals, err = b.ApprovalRepo.ListByTask(ctx, b.DB, taskID); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Import writes a bundle into the database in a single transaction. The task
+// must not already exist. Workers that were still active when exported are
+// recorded as replaced, since their sessions do not exist on this instance.
+func (b *Bundler) Import(ctx context.Context, bundle *domain.TaskBundle) error {
+	if err := validate(bundle); err != nil {
+		return err
+	}
+	taskID := bundle.Task.TaskID
+
+	if _, err := b.TaskRepo.GetByID(ctx, b.DB, taskID); err == nil {
+		return domain.ErrDuplicateTask
+	} else if err != domain.ErrFlowNotFound {
+		return err
+	}
+
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin import tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := b.TaskRepo.CreateTx(ctx, tx, bundle.Task); err != nil {
+		return err
+	}
+	for _, ev := range bundle.Events {
+		ev.TaskID = taskID
+		if err := b.EventRepo.AppendTx(ctx, tx, ev); err != nil {
+			return err
+		}
+	}
+	for _, snap := range bundle.Snapshots {
+		snap.TaskID = taskID
+		if err := b.SnapshotRepo.SaveTx(ctx, tx, snap); err != nil {
+			return err
+		}
+	}
+	for _, intent := range bundle.Intents {
+		intent.TaskID = taskID
+		if err := b.IntentRepo.UpsertTx(ctx, tx, intent); err != nil {
+			return err
+		}
+	}
+	for _, w := range bundle.Workers {
+		if w == nil {
+			continue
+		}
+		worker := *w
+		worker.TaskID = taskID
+		if worker.State == domain.WorkerCreated || worker.State == domain.WorkerRunning {
+			worker.State = domain.WorkerReplaced
+		}
+		if err := b.WorkerRepo.CreateTx(ctx, tx, worker); err != nil {
+			return err
+		}
+	}
+	for _, card := range bundle.ScoreCards {
+		card.TaskID = taskID
+		if err := b.ScoreCardRepo.CreateTx(ctx, tx, card); err != nil {
+			return err
+		}
+	}
+	if err := b.CostDeltaRepo.CreateBatchTx(ctx, tx, taskID, bundle.CostDeltas); err != nil {
+		return err
+	}
+	for _, rec := range bundle.Audit {
+		rec.TaskID = taskID
+		if err := b.AuditRepo.RecordTx(ctx, tx, rec); err != nil {
+			return err
+		}
+	}
+	for _, ev := range bundle.SessionEvents {
+		ev.TaskID = taskID
+		if err := b.SessionEventRepo.CreateTx(ctx, tx, ev); err != nil {
+			return err
+		}
+	}
+	for _, d := range bundle.DeadLetters {
+		d.TaskID = taskID
+		if err := b.DeadLetterRepo.CreateTx(ctx, tx, d); err != nil {
+			return err
+		}
+	}
+	for _, a := range bundle.Artifacts {
+		a.TaskID = taskID
+		if err := b.ArtifactRepo.CreateTx(ctx, tx, a); err != nil {
+			return err
+		}
+	}
+	for _, d := range bundle.Decisions {
+		d.TaskID = taskID
+		if err := b.DecisionRepo.CreateTx(ctx, tx, d); err != nil {
+			return err
+		}
+	}
+	for _, c := range bundle.Constraints {
+		c.TaskID = taskID
+		if err := b.ConstraintRepo.CreateTx(ctx, tx, c); err != nil {
+			return err
+		}
+	}
+	for _, k := range bundle.Risks {
+		k.TaskID = taskID
+		if err := b.RiskRepo.CreateTx(ctx, tx, k); err != nil {
+			return err
+		}
+	}
+	for _, round := range bundle.ReviewRounds {
+		round.TaskID = taskID
+		if err := b.ReviewRoundRepo.CreateTx(ctx, tx, round); err != nil {
+			return err
+		}
+	}
+	for _, issue := range bundle.ReviewIssues {
+		issue.TaskID = taskID
+		if err := b.IssueRepo.CreateTx(ctx, tx, issue); err != nil {
+			return err
+		}
+	}
+	for _, g := range bundle.GateDecisions {
+		g.TaskID = taskID
+		if _, err := b.GateDecisionRepo.CreateTx(ctx, tx, g); err != nil {
+			return err
+		}
+	}
+	for _, a := range bundle.Approvals {
+		a.TaskID = taskID
+		if _, err := b.ApprovalRepo.CreateTx(ctx, tx, a); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit import tx: %w", err)
+	}
+	return nil
+}
+
+// validate checks the parts of a bundle Import relies on.
+func validate(bundle *domain.TaskBundle) error {
+	invalid := func(reason string) error {
+		return &domain.EngineError{
+			Code:    domain.ErrBundleInvalid.Code,
+			Message: fmt.Sprintf("%s: %s", domain.ErrBundleInvalid.Message, reason),
+		}
+	}
+	if bundle == nil {
+		return invalid("empty bundle")
+	}
+	if bundle.FormatVersion != FormatVersion {
+		return invalid(fmt.Sprintf("unsupported format version %d", bundle.FormatVersion))
+	}
+	if bundle.Task.TaskID == "" {
+		return invalid("task id is required")
+	}
+	if ns := bundle.Task.Namespace; ns != "" && !domain.ValidNamespace(ns) {
+		return invalid(fmt.Sprintf("invalid namespace %q", ns))
+	}
+	switch bundle.Task.CurrentPhase {
+	case domain.PhaseA, domain.PhaseB, domain.PhaseC, domain.PhaseD, domain.PhaseE, domain.PhaseF, domain.PhaseG:
+	default:
+		return invalid(fmt.Sprintf("unknown phase %q", bundle.Task.CurrentPhase))
+	}
+	seen := make(map[int64]bool, len(bundle.Events))
+	for _, ev := range bundle.Events {
+		if ev.SeqNo > bundle.Task.LastEventSeq {
+			return invalid(fmt.Sprintf("event seq %d beyond task's last_event_seq %d", ev.SeqNo, bundle.Task.LastEventSeq))
+		}
+		if seen[ev.SeqNo] {
+			return invalid(fmt.Sprintf("duplicate event seq %d", ev.SeqNo))
+		}
+		seen[ev.SeqNo] = true
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package bundle
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+func newDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// seed creates a flow with events, a snapshot, a worker, a score card, a cost
+// delta, an audit record, an intent and a row in every other bundled table.
+func seed(t *testing.T, db *sql.DB) {
+	t.Helper()
+	ctx := context.Background()
+	engine := workflow.NewEngine(db)
+	if err := engine.StartFlow(ctx, "t1", 10); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	if err := engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "test"}); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if err := (&store.WorkerRepo{}).Create(ctx, db, domain.WorkerRef{
+		WorkerID: "w1", TaskID: "t1", Phase: domain.PhaseB, Role: "explorer", State: domain.WorkerRunning,
+	}); err != nil {
+		t.Fatalf("create worker: %v", err)
+	}
+	if err := (&store.ScoreCardRepo{}).Create(ctx, db, domain.ScoreCard{
+		ReviewID: "r1", TaskID: "t1", Reviewer: "codex", Verdict: "approve", CreatedAt: 1,
+		Issues: []domain.Issue{}, Alternatives: []string{},
+	}); err != nil {
+		t.Fatalf("create score card: %v", err)
+	}
+	if err := (&store.CostDeltaRepo{}).Create(ctx, db, "t1", domain.CostDelta{AmountUSD: 0.5, Provider: domain.ProviderClaude, CreatedAt: 1}); err != nil {
+		t.Fatalf("create cost delta: %v", err)
+	}
+	if err := (&store.AuditRepo{}).Record(ctx, db, domain.AuditRecord{ID: "a1", TaskID: "t1", Category: "test", Action: "x", CreatedAt: 1}); err != nil {
+		t.Fatalf("record audit: %v", err)
+	}
+
+	if _, err := (&store.SessionEventRepo{}).Append(ctx, db, domain.SessionEvent{
+		SessionID: "s1", TaskID: "t1", EventType: "output", Provider: domain.ProviderClaude, PayloadJSON: `{"text":"hi"}`, CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("append session event: %v", err)
+	}
+	if _, err := (&store.DeadLetterRepo{}).Create(ctx, db, domain.DeadLetterEvent{
+		SessionID: "s1", TaskID: "t1", Provider: domain.ProviderClaude, Adapter: domain.ProviderClaude, Raw: "garbled", Error: "bad json", CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("create dead letter: %v", err)
+	}
+	if _, err := (&store.ArtifactRepo{}).Create(ctx, db, domain.ArtifactRef{
+		ID: "art1", TaskID: "t1", Phase: domain.PhaseB, Type: "doc", Path: "plan.md", Hash: "h1", CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("create artifact: %v", err)
+	}
+	if err := (&store.DecisionRepo{}).Create(ctx, db, domain.Decision{
+		DecisionID: "d1", TaskID: "t1", Kind: "choice", SubjectJSON: "{}", Status: "pending", CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("create decision: %v", err)
+	}
+	if err := (&store.ConstraintRepo{}).Create(ctx, db, domain.Constraint{ConstraintID: "c1", TaskID: "t1", Text: "no deps", CreatedAt: 1}); err != nil {
+		t.Fatalf("create constraint: %v", err)
+	}
+	if err := (&store.RiskRepo{}).Create(ctx, db, domain.Risk{RiskID: "k1", TaskID: "t1", Phase: domain.PhaseB, Text: "flaky", CreatedAt: 1}); err != nil {
+		t.Fatalf("create risk: %v", err)
+	}
+	if _, err := (&store.GateDecisionRepo{}).Create(ctx, db, domain.GateRecord{TaskID: "t1", Phase: domain.PhaseB, Gate: "exit", Allow: true, CreatedAt: 1}); err != nil {
+		t.Fatalf("create gate decision: %v", err)
+	}
+	if _, err := (&store.ApprovalRepo{}).Create(ctx, db, domain.Approval{TaskID: "t1", Phase: domain.PhaseB, Actor: "op", Decision: "approve", CreatedAt: 1}); err != nil {
+		t.Fatalf("create approval: %v", err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	defer tx.Rollback()
+	if err := (&store.ReviewRoundRepo{}).StartTx(ctx, tx, domain.ReviewRound{TaskID: "t1", Round: 1, Phase: domain.PhaseB, StartedAt: 1}); err != nil {
+		t.Fatalf("start review round: %v", err)
+	}
+	if err := (&store.IntentRepo{}).UpsertTx(ctx, tx, domain.Intent{
+		IntentID: "in1", TaskID: "t1", WorkerID: "w1", TargetFile: "main.go", Operation: "modify", Status: "done", CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("upsert intent: %v", err)
+	}
+	if err := (&store.IssueRepo{}).CreateTx(ctx, tx, domain.ReviewIssue{
+		IssueID: "i1", TaskID: "t1", ReviewID: "r1", Reviewer: "codex", Round: 1, CreatedAt: 1,
+		Issue: domain.Issue{Severity: "P1", Description: "bug"},
+	}); err != nil {
+		t.Fatalf("create review issue: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	src := newDB(t)
+	seed(t, src)
+	ctx := context.Background()
+
+	exported, err := New(src).Export(ctx, "t1")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if exported.Task.CurrentPhase != domain.PhaseB || len(exported.Events) != 2 || len(exported.Snapshots) != 1 {
+		t.Fatalf("unexpected export: phase=%s events=%d snapshots=%d", exported.Task.CurrentPhase, len(exported.Events), len(exported.Snapshots))
+	}
+
+	// Bundles travel as JSON.
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded domain.TaskBundle
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	dst := newDB(t)
+	if err := New(dst).Import(ctx, &decoded); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	imported, err := New(dst).Export(ctx, "t1")
+	if err != nil {
+		t.Fatalf("Export after import: %v", err)
+	}
+	if imported.Task.StateVersion != exported.Task.StateVersion || imported.Task.BudgetUsedUSD != exported.Task.BudgetUsedUSD {
+		t.Errorf("task = %+v, want %+v", imported.Task, exported.Task)
+	}
+	if len(imported.Events) != 2 || len(imported.Snapshots) != 1 || len(imported.ScoreCards) != 1 ||
+		len(imported.CostDeltas) != 1 || len(imported.Audit) != 1 || len(imported.Workers) != 1 {
+		t.Fatalf("imported bundle is incomplete: %+v", imported)
+	}
+	if imported.Workers[0].State != domain.WorkerReplaced {
+		t.Errorf("running worker imported as %q, want replaced", imported.Workers[0].State)
+	}
+
+	// The imported flow keeps working on the new instance.
+	engine := workflow.NewEngine(dst)
+	if err := engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "test"}); err != nil {
+		t.Fatalf("Advance after import: %v", err)
+	}
+}
+
+func TestExportImport_CoversTaskTables(t *testing.T) {
+	src := newDB(t)
+	seed(t, src)
+	ctx := context.Background()
+
+	exporter := New(src)
+	exporter.Clock = clock.NewFake(time.Unix(1_700_000_000, 0))
+	exported, err := exporter.Export(ctx, "t1")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if exported.ExportedAt != 1_700_000_000 {
+		t.Errorf("ExportedAt = %d, want the clock's time", exported.ExportedAt)
+	}
+	if len(exported.SessionEvents) != 1 || exported.SessionEvents[0].PayloadJSON != `{"text":"hi"}` {
+		t.Errorf("SessionEvents = %+v", exported.SessionEvents)
+	}
+
+	dst := newDB(t)
+	if err := New(dst).Import(ctx, exported); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	purge := &store.PurgeRepo{}
+	want, err := purge.Count(ctx, src, "t1")
+	if err != nil {
+		t.Fatalf("Count src: %v", err)
+	}
+	got, err := purge.Count(ctx, dst, "t1")
+	if err != nil {
+		t.Fatalf("Count dst: %v", err)
+	}
+
+	tables := make(map[string]bool)
+	for _, table := range store.TaskTables() {
+		tables[table] = true
+		if _, skipped := unbundledTables[table]; skipped {
+			continue
+		}
+		if want[table] == 0 {
+			t.Errorf("seed leaves %s empty; seed it or list it in unbundledTables", table)
+		} else if got[table] != want[table] {
+			t.Errorf("%s: imported %d rows, want %d", table, got[table], want[table])
+		}
+	}
+	for table := range unbundledTables {
+		if !tables[table] {
+			t.Errorf("unbundledTables names %s, which holds no task rows", table)
+		}
+	}
+}
+
+func TestImport_RejectsExistingTask(t *testing.T) {
+	db := newDB(t)
+	seed(t, db)
+	ctx := context.Background()
+
+	b, err := New(db).Export(ctx, "t1")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if err := New(db).Import(ctx, b); !errors.Is(err, domain.ErrDuplicateTask) {
+		t.Fatalf("Import error = %v, want ErrDuplicateTask", err)
+	}
+}
+
+func TestImport_RejectsInvalidBundle(t *testing.T) {
+	db := newDB(t)
+	tests := []struct {
+		name   string
+		bundle domain.TaskBundle
+	}{
+		{"version", domain.TaskBundle{FormatVersion: 99, Task: domain.FlowState{TaskID: "t1", CurrentPhase: domain.PhaseA}}},
+		{"task id", domain.TaskBundle{FormatVersion: FormatVersion, Task: domain.FlowState{CurrentPhase: domain.PhaseA}}},
+		{"phase", domain.TaskBundle{FormatVersion: FormatVersion, Task: domain.FlowState{TaskID: "t1", CurrentPhase: "Z"}}},
+		{"seq", domain.TaskBundle{
+			FormatVersion: FormatVersion,
+			Task:          domain.FlowState{TaskID: "t1", CurrentPhase: domain.PhaseA, LastEventSeq: 1},
+			Events:        []domain.WorkflowEvent{{SeqNo: 2}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(db).Import(context.Background(), &tt.bundle)
+			var engErr *domain.EngineError
+			if !errors.As(err, &engErr) || engErr.Code != domain.ErrBundleInvalid.Code {
+				t.Fatalf("Import error = %v, want ErrBundleInvalid", err)
+			}
+		})
+	}
+}
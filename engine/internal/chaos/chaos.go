@@ -0,0 +1,138 @@
+// Package chaos injects faults into a running engine at configured rates:
+// it kills sessions, malforms their output, drops worker heartbeats, and
+// delays database writes, so the supervisor and recovery paths can be
+// exercised under failure. It is for test and staging deployments only.
+//
+// Components take a *Monkey and ask it whether to fail at each
+// opportunity; a nil Monkey never does.
+package chaos
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config sets the rate of each fault as a probability per opportunity,
+// from 0 (never) to 1 (always).
+type Config struct {
+	// KillSessionRate is the chance a session is killed at each line of
+	// output it prints.
+	KillSessionRate float64
+	// MalformedEventRate is the chance a line of session output is
+	// truncated before it is parsed.
+	MalformedEventRate float64
+	// DropHeartbeatRate is the chance a worker heartbeat is not recorded.
+	DropHeartbeatRate float64
+	// WriteDelayRate is the chance a database write waits WriteDelay first.
+	WriteDelayRate float64
+	WriteDelay     time.Duration
+	// Seed seeds the fault sequence; 0 seeds it from the time.
+	Seed int64
+}
+
+// Stats counts the faults a Monkey has injected.
+type Stats struct {
+	SessionsKilled    int64 `json:"sessionsKilled"`
+	EventsMalformed   int64 `json:"eventsMalformed"`
+	HeartbeatsDropped int64 `json:"heartbeatsDropped"`
+	WritesDelayed     int64 `json:"writesDelayed"`
+}
+
+// Monkey decides when to inject faults. It is safe for concurrent use.
+type Monkey struct {
+	cfg Config
+
+	mu  sync.Mutex
+	rng *rand.Rand
+
+	killed    atomic.Int64
+	malformed atomic.Int64
+	dropped   atomic.Int64
+	delayed   atomic.Int64
+}
+
+// New returns a Monkey injecting faults at cfg's rates.
+func New(cfg Config) *Monkey {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Monkey{cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+// roll reports whether a fault of the given rate happens now.
+func (m *Monkey) roll(rate float64) bool {
+	if m == nil || rate <= 0 {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rng.Float64() < rate
+}
+
+// KillSession reports whether to kill a session now.
+func (m *Monkey) KillSession() bool {
+	if !m.roll(m.config().KillSessionRate) {
+		return false
+	}
+	m.killed.Add(1)
+	return true
+}
+
+// Malform returns line, or a truncated copy of it when an event is to be
+// malformed now.
+func (m *Monkey) Malform(line []byte) []byte {
+	if len(line) == 0 || !m.roll(m.config().MalformedEventRate) {
+		return line
+	}
+	m.malformed.Add(1)
+	return append([]byte(nil), line[:len(line)/2]...)
+}
+
+// DropHeartbeat reports whether to drop a worker heartbeat now.
+func (m *Monkey) DropHeartbeat() bool {
+	if !m.roll(m.config().DropHeartbeatRate) {
+		return false
+	}
+	m.dropped.Add(1)
+	return true
+}
+
+// DelayWrite waits WriteDelay, or until ctx ends, when a write is to be
+// delayed now. It fits store.DBOptions.BeforeWrite.
+func (m *Monkey) DelayWrite(ctx context.Context) {
+	cfg := m.config()
+	if cfg.WriteDelay <= 0 || !m.roll(cfg.WriteDelayRate) {
+		return
+	}
+	m.delayed.Add(1)
+	t := time.NewTimer(cfg.WriteDelay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// Stats returns the faults injected so far. A nil Monkey reports zeros.
+func (m *Monkey) Stats() Stats {
+	if m == nil {
+		return Stats{}
+	}
+	return Stats{
+		SessionsKilled:    m.killed.Load(),
+		EventsMalformed:   m.malformed.Load(),
+		HeartbeatsDropped: m.dropped.Load(),
+		WritesDelayed:     m.delayed.Load(),
+	}
+}
+
+func (m *Monkey) config() Config {
+	if m == nil {
+		return Config{}
+	}
+	return m.cfg
+}
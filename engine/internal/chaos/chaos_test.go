@@ -0,0 +1,76 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNilMonkey(t *testing.T) {
+	var m *Monkey
+	line := []byte(`{"type":"result"}`)
+	if m.KillSession() || m.DropHeartbeat() || string(m.Malform(line)) != string(line) {
+		t.Error("nil Monkey injected a fault")
+	}
+	m.DelayWrite(context.Background())
+	if m.Stats() != (Stats{}) {
+		t.Errorf("Stats = %+v, want zeros", m.Stats())
+	}
+}
+
+func TestMonkey_Rates(t *testing.T) {
+	always := New(Config{KillSessionRate: 1, MalformedEventRate: 1, DropHeartbeatRate: 1, WriteDelayRate: 1, WriteDelay: time.Millisecond})
+	never := New(Config{WriteDelay: time.Hour})
+
+	line := []byte(`{"type":"result","text":"done"}`)
+	for i := 0; i < 10; i++ {
+		if !always.KillSession() || !always.DropHeartbeat() {
+			t.Fatal("rate 1 did not inject a fault")
+		}
+		if got := always.Malform(line); len(got) >= len(line) {
+			t.Fatalf("Malform = %s, want a truncated line", got)
+		}
+		always.DelayWrite(context.Background())
+
+		if never.KillSession() || never.DropHeartbeat() || string(never.Malform(line)) != string(line) {
+			t.Fatal("rate 0 injected a fault")
+		}
+		never.DelayWrite(context.Background()) // Would hang for an hour.
+	}
+	want := Stats{SessionsKilled: 10, EventsMalformed: 10, HeartbeatsDropped: 10, WritesDelayed: 10}
+	if got := always.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+	if string(line) != `{"type":"result","text":"done"}` {
+		t.Error("Malform changed its input")
+	}
+}
+
+func TestMonkey_Seeded(t *testing.T) {
+	a := New(Config{KillSessionRate: 0.5, Seed: 42})
+	b := New(Config{KillSessionRate: 0.5, Seed: 42})
+	for i := 0; i < 100; i++ {
+		if a.KillSession() != b.KillSession() {
+			t.Fatalf("roll %d differs between monkeys with the same seed", i)
+		}
+	}
+	if n := a.Stats().SessionsKilled; n == 0 || n == 100 {
+		t.Errorf("rate 0.5 killed %d of 100 sessions", n)
+	}
+}
+
+func TestMonkey_DelayWriteHonoursContext(t *testing.T) {
+	m := New(Config{WriteDelayRate: 1, WriteDelay: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		m.DelayWrite(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("DelayWrite ignored a cancelled context")
+	}
+}
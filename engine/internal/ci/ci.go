@@ -0,0 +1,150 @@
+// Package ci reads the results of CI checks on a flow's branch, either by
+// polling the GitHub Checks API or from signed webhook reports, for the
+// phase E CI gate.
+package ci
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// GitHub polls the check runs of a ref through the GitHub REST API.
+type GitHub struct {
+	// APIURL defaults to https://api.github.com.
+	APIURL string
+	// Repo is the repository as owner/name.
+	Repo  string
+	Token string
+	HTTP  *http.Client
+}
+
+// checkRun is the part of a GitHub check run the gate reads.
+type checkRun struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	HTMLURL    string `json:"html_url"`
+}
+
+// Checks returns the latest run of every check on ref.
+func (g *GitHub) Checks(ctx context.Context, ref string) ([]domain.CICheck, error) {
+	client := g.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	api := g.APIURL
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	endpoint := api + "/repos/" + g.Repo + "/commits/" + url.PathEscape(ref) + "/check-runs?filter=latest&per_page=100"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if g.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.Token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("github: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("github: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var body struct {
+		CheckRuns []checkRun `json:"check_runs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("github: decode check runs: %w", err)
+	}
+
+	checks := make([]domain.CICheck, len(body.CheckRuns))
+	for i, run := range body.CheckRuns {
+		checks[i] = domain.CICheck{Name: run.Name, Status: runStatus(run), URL: run.HTMLURL}
+	}
+	return checks, nil
+}
+
+// runStatus maps a check run to a check status. Neutral and skipped runs
+// pass; runs that have not completed are pending.
+func runStatus(run checkRun) string {
+	if run.Status != "completed" {
+		return domain.CIPending
+	}
+	switch run.Conclusion {
+	case "success", "neutral", "skipped":
+		return domain.CISuccess
+	}
+	return domain.CIFailure
+}
+
+// Report is the body of a CI webhook: the results of one or more checks on
+// a flow's branch. TaskID, when set, names the flow; otherwise Branch does.
+type Report struct {
+	TaskID string        `json:"taskId,omitempty"`
+	Branch string        `json:"branch,omitempty"`
+	Checks []ReportCheck `json:"checks"`
+}
+
+// ReportCheck is one check result in a Report. Status is success, failure,
+// or pending.
+type ReportCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	URL    string `json:"url,omitempty"`
+}
+
+// Validate checks that r names a flow and holds well-formed checks.
+func (r Report) Validate() error {
+	if r.TaskID == "" && r.Branch == "" {
+		return fmt.Errorf("taskId or branch is required")
+	}
+	if len(r.Checks) == 0 {
+		return fmt.Errorf("at least one check is required")
+	}
+	for i, c := range r.Checks {
+		if c.Name == "" {
+			return fmt.Errorf("checks[%d].name is required", i)
+		}
+		switch c.Status {
+		case domain.CISuccess, domain.CIFailure, domain.CIPending:
+		default:
+			return fmt.Errorf("checks[%d].status must be success, failure, or pending, got %q", i, c.Status)
+		}
+	}
+	return nil
+}
+
+// SignatureHeader carries a webhook's signature: "sha256=" and the hex
+// HMAC-SHA256 of the body under the shared secret. GitHub's
+// X-Hub-Signature-256 uses the same format and is accepted too.
+const SignatureHeader = "X-Threebody-Signature"
+
+// Sign returns the signature of body under secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is body's signature under secret.
+func Verify(secret, body []byte, signature string) bool {
+	if len(secret) == 0 || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
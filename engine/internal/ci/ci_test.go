@@ -0,0 +1,84 @@
+package ci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestGitHub_Checks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/repos/acme/app/commits/threebody%2Ft1/check-runs" || r.URL.Query().Get("filter") != "latest" {
+			t.Errorf("request = %s", r.URL)
+		}
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, `{"message": "Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"total_count": 4, "check_runs": [
+			{"name": "unit", "status": "completed", "conclusion": "success", "html_url": "https://ci/1"},
+			{"name": "lint", "status": "completed", "conclusion": "failure"},
+			{"name": "e2e", "status": "in_progress", "conclusion": null},
+			{"name": "docs", "status": "completed", "conclusion": "skipped"}
+		]}`))
+	}))
+	defer srv.Close()
+
+	g := &GitHub{APIURL: srv.URL, Repo: "acme/app", Token: "tok"}
+	checks, err := g.Checks(context.Background(), "threebody/t1")
+	if err != nil {
+		t.Fatalf("Checks: %v", err)
+	}
+	want := map[string]string{"unit": domain.CISuccess, "lint": domain.CIFailure, "e2e": domain.CIPending, "docs": domain.CISuccess}
+	if len(checks) != len(want) || checks[0].URL != "https://ci/1" {
+		t.Fatalf("checks = %+v", checks)
+	}
+	for _, c := range checks {
+		if c.Status != want[c.Name] {
+			t.Errorf("%s = %s, want %s", c.Name, c.Status, want[c.Name])
+		}
+	}
+
+	g.Token = "wrong"
+	if _, err := g.Checks(context.Background(), "threebody/t1"); err == nil || !strings.Contains(err.Error(), "Bad credentials") {
+		t.Errorf("Checks with a bad token = %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("s3cret")
+	body := []byte(`{"taskId": "t1"}`)
+	sig := Sign(secret, body)
+	if !Verify(secret, body, sig) {
+		t.Error("Verify rejected a valid signature")
+	}
+	if Verify(secret, []byte(`{"taskId": "t2"}`), sig) {
+		t.Error("Verify accepted a signature over another body")
+	}
+	if Verify([]byte("other"), body, sig) || Verify(nil, body, Sign(nil, body)) || Verify(secret, body, strings.TrimPrefix(sig, "sha256=")) {
+		t.Error("Verify accepted a wrong secret, an empty secret, or an unprefixed signature")
+	}
+}
+
+func TestReport_Validate(t *testing.T) {
+	tests := []struct {
+		report Report
+		want   string
+	}{
+		{Report{TaskID: "t1", Checks: []ReportCheck{{Name: "unit", Status: "success"}}}, ""},
+		{Report{Checks: []ReportCheck{{Name: "unit", Status: "success"}}}, "taskId or branch is required"},
+		{Report{Branch: "threebody/t1"}, "at least one check"},
+		{Report{TaskID: "t1", Checks: []ReportCheck{{Status: "success"}}}, "checks[0].name is required"},
+		{Report{TaskID: "t1", Checks: []ReportCheck{{Name: "unit", Status: "green"}}}, "checks[0].status must be"},
+	}
+	for _, tt := range tests {
+		err := tt.report.Validate()
+		if (tt.want == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.report, err, tt.want)
+		}
+	}
+}
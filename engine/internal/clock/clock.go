@@ -0,0 +1,106 @@
+// Package clock abstracts the current time for the subsystems that keep
+// timeouts, leases, and rate windows, so tests can step time instead of
+// sleeping and a simulation can fast-forward it.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits on it.
+type Clock interface {
+	Now() time.Time
+	// After sends the time on the returned channel once d has passed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// System is the wall clock.
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Or returns c, or System when c is nil, for types whose zero value is
+// usable without a clock.
+func Or(c Clock) Clock {
+	if c == nil {
+		return System
+	}
+	return c
+}
+
+// Fake is a Clock that stands still until Advance or Set moves it. Waiters
+// from After fire once the fake time reaches their deadline.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFake returns a Fake reading start.
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+// Now implements Clock.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After implements Clock. A non-positive d fires at once.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, waiter{at: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the time forward by d.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	t := f.now.Add(d)
+	f.mu.Unlock()
+	f.Set(t)
+}
+
+// Set moves the time to t and fires every waiter due by then, earliest
+// first. Setting an earlier time fires nothing.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t
+	sort.SliceStable(f.waiters, func(i, j int) bool { return f.waiters[i].at.Before(f.waiters[j].at) })
+	n := 0
+	for _, w := range f.waiters {
+		if w.at.After(t) {
+			break
+		}
+		w.ch <- t
+		n++
+	}
+	f.waiters = f.waiters[n:]
+}
+
+// Waiters returns the number of After calls still waiting, so a test can
+// tell a goroutine has started waiting before it advances the clock.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
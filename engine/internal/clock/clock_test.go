@@ -0,0 +1,59 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	f := NewFake(start)
+	if !f.Now().Equal(start) {
+		t.Fatalf("Now = %v, want %v", f.Now(), start)
+	}
+
+	late := f.After(2 * time.Second)
+	early := f.After(time.Second)
+	select {
+	case <-f.After(0):
+	default:
+		t.Error("After(0) did not fire at once")
+	}
+	if f.Waiters() != 2 {
+		t.Errorf("Waiters = %d, want 2", f.Waiters())
+	}
+
+	f.Advance(time.Second)
+	select {
+	case got := <-early:
+		if !got.Equal(start.Add(time.Second)) {
+			t.Errorf("early fired at %v", got)
+		}
+	default:
+		t.Error("waiter due after 1s did not fire")
+	}
+	select {
+	case <-late:
+		t.Error("waiter due after 2s fired early")
+	default:
+	}
+
+	f.Set(start) // Going back fires nothing.
+	if f.Waiters() != 1 {
+		t.Errorf("Waiters = %d, want 1", f.Waiters())
+	}
+	f.Advance(time.Hour)
+	if _, ok := <-late; !ok || f.Waiters() != 0 {
+		t.Error("waiter due after 2s did not fire")
+	}
+}
+
+func TestOr(t *testing.T) {
+	if Or(nil) != System {
+		t.Error("Or(nil) is not the system clock")
+	}
+	f := NewFake(time.Time{})
+	if Or(f) != f {
+		t.Error("Or(f) did not return f")
+	}
+}
@@ -3,9 +3,21 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net/mail"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/expr-lang/expr"
 
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/mcp"
+	"github.com/anthropics/three-body-engine/internal/notify"
 )
 
 // ProviderConfig defines how to launch a code agent provider process.
@@ -13,44 +25,1039 @@ type ProviderConfig struct {
 	Command string            `json:"command"`
 	Args    []string          `json:"args"`
 	Env     map[string]string `json:"env"`
+	Adapter string            `json:"adapter,omitempty"`
+	// Scenario makes the provider the built-in mock, which replays the
+	// scripted sessions of this scenario file instead of running an agent.
+	// It excludes Command.
+	Scenario string `json:"scenario,omitempty"`
+}
+
+// ProviderChecksConfig validates providers at startup: each command is
+// resolved on PATH and run with SmokeArgs.
+type ProviderChecksConfig struct {
+	Enabled bool `json:"enabled"`
+	// SmokeArgs default to ["--version"]; an empty list only resolves the
+	// command.
+	SmokeArgs  []string `json:"smoke_args"`
+	TimeoutSec int      `json:"timeout_sec"`
+	// Required refuses to start while any provider is broken.
+	Required bool `json:"required"`
+}
+
+// PhaseWorkerConfig describes a group of workers the orchestrator spawns when
+// a flow enters a phase.
+type PhaseWorkerConfig struct {
+	Role           string `json:"role"`
+	Provider       string `json:"provider"`
+	Count          int    `json:"count"`
+	SoftTimeoutSec int    `json:"soft_timeout_sec"`
+	HardTimeoutSec int    `json:"hard_timeout_sec"`
+	// Partition assigns each worker a non-overlapping share of the
+	// workspace's files as its FileOwnership.
+	Partition bool `json:"partition"`
+}
+
+// ReviewerConfig maps a reviewer role to the provider that runs it in phase F.
+type ReviewerConfig struct {
+	Provider       string `json:"provider"`
+	SoftTimeoutSec int    `json:"soft_timeout_sec"`
+	HardTimeoutSec int    `json:"hard_timeout_sec"`
+}
+
+// reviewerRoles are the role keys accepted in the reviewers map.
+var reviewerRoles = map[string]bool{
+	"primary":   true,
+	"secondary": true,
+	"lead":      true,
+}
+
+// TableRetentionConfig bounds how much history one table keeps.
+type TableRetentionConfig struct {
+	MaxAgeDays     int `json:"max_age_days"`
+	MaxRowsPerTask int `json:"max_rows_per_task"`
+}
+
+// RetentionConfig controls pruning of the event, cost, and audit tables.
+// Expired rows are archived to gzip-compressed JSONL files in ArchiveDir
+// before deletion.
+type RetentionConfig struct {
+	ArchiveDir  string                          `json:"archive_dir"`
+	IntervalSec int                             `json:"interval_sec"`
+	Tables      map[string]TableRetentionConfig `json:"tables"`
+}
+
+// BackupConfig controls scheduled online snapshots of the database.
+type BackupConfig struct {
+	Dir         string `json:"dir"`
+	IntervalSec int    `json:"interval_sec"`
+	Keep        int    `json:"keep"`
+}
+
+// ChaosConfig injects faults at the given rates, each a probability from 0
+// to 1 per opportunity, to exercise recovery under failure. Never enable it
+// in production.
+type ChaosConfig struct {
+	Enabled            bool    `json:"enabled"`
+	KillSessionRate    float64 `json:"kill_session_rate"`
+	MalformedEventRate float64 `json:"malformed_event_rate"`
+	DropHeartbeatRate  float64 `json:"drop_heartbeat_rate"`
+	WriteDelayRate     float64 `json:"write_delay_rate"`
+	WriteDelayMS       int     `json:"write_delay_ms"`
+	// Seed makes the fault sequence repeatable; 0 picks one at startup.
+	Seed int `json:"seed"`
+}
+
+// HTTPConfig sets the API server's timeouts and request body limit.
+type HTTPConfig struct {
+	ReadTimeoutSec    int `json:"read_timeout_sec"`
+	IdleTimeoutSec    int `json:"idle_timeout_sec"`
+	RequestTimeoutSec int `json:"request_timeout_sec"`
+	// RouteTimeoutsSec overrides RequestTimeoutSec for routes keyed by
+	// pattern, such as "GET /api/v1/flow/{taskID}/report"; 0 = no timeout.
+	RouteTimeoutsSec map[string]int `json:"route_timeouts_sec"`
+	MaxBodyBytes     int64          `json:"max_body_bytes"`
+	LogRequests      bool           `json:"log_requests"`
+}
+
+// WorkspacesConfig enables per-task workspaces. With Root empty every flow
+// shares the top-level workspace.
+type WorkspacesConfig struct {
+	Root       string `json:"root"`
+	Worktree   bool   `json:"worktree"`
+	OnComplete string `json:"on_complete"`
+	ArchiveDir string `json:"archive_dir"`
+}
+
+// GitConfig enables branch-per-flow commits in the workspace repository.
+type GitConfig struct {
+	Enabled      bool   `json:"enabled"`
+	BranchPrefix string `json:"branch_prefix"`
+	AuthorName   string `json:"author_name"`
+	AuthorEmail  string `json:"author_email"`
+}
+
+// PullRequestsConfig opens a pull request when a flow reaches phase G with a
+// passing consensus. The API token is read from the TokenEnv variable.
+type PullRequestsConfig struct {
+	Provider   string `json:"provider"`
+	Repo       string `json:"repo"`
+	APIURL     string `json:"api_url"`
+	TokenEnv   string `json:"token_env"`
+	Remote     string `json:"remote"`
+	BaseBranch string `json:"base_branch"`
+}
+
+// IntentQueueConfig controls whether conflicting intents wait for the file
+// instead of failing.
+type IntentQueueConfig struct {
+	Enabled bool `json:"enabled"`
+	// TimeoutSec cancels intents still queued after this long; 0 = no limit.
+	TimeoutSec int `json:"timeout_sec"`
+}
+
+// TestGateConfig runs the project's tests in the flow's workspace before a
+// flow leaves phase E. The gate is off while Command is empty.
+type TestGateConfig struct {
+	Command    string   `json:"command"`
+	Args       []string `json:"args"`
+	TimeoutSec int      `json:"timeout_sec"`
+	// MinCoverage is the lowest acceptable coverage percentage, read from
+	// the output with CoveragePattern's first group; 0 = no check.
+	MinCoverage     float64 `json:"min_coverage"`
+	CoveragePattern string  `json:"coverage_pattern"`
+}
+
+// CIGateConfig holds flows in phase E until CI reports success for the
+// task branch. The "github" provider polls the Checks API of Repo with the
+// token read from TokenEnv; the "webhook" provider accepts reports signed
+// with WebhookSecret, which may be a secret reference. RequiredChecks names
+// the checks that must pass; empty means every check reported.
+type CIGateConfig struct {
+	Provider        string   `json:"provider"`
+	Repo            string   `json:"repo"`
+	APIURL          string   `json:"api_url"`
+	TokenEnv        string   `json:"token_env"`
+	WebhookSecret   string   `json:"webhook_secret"`
+	RequiredChecks  []string `json:"required_checks"`
+	PollIntervalSec int      `json:"poll_interval_sec"`
+}
+
+// IssueSyncConfig mirrors each flow into a Jira or Linear issue: created
+// when the flow starts in Project (or the project NamespaceProjects maps
+// the flow's namespace to), commented on at each phase transition and
+// review consensus, and closed on phase G. For Jira, Project is a project
+// key, Labels are label names, and DoneState names the closing transition;
+// for Linear, Project is a team ID, Labels are label IDs, and DoneState is
+// a workflow state ID. The API token is read from the TokenEnv variable.
+type IssueSyncConfig struct {
+	Provider          string            `json:"provider"`
+	URL               string            `json:"url"`
+	Email             string            `json:"email"`
+	TokenEnv          string            `json:"token_env"`
+	Project           string            `json:"project"`
+	NamespaceProjects map[string]string `json:"namespace_projects"`
+	IssueType         string            `json:"issue_type"`
+	Labels            []string          `json:"labels"`
+	DoneState         string            `json:"done_state"`
+	// IntervalSec is how often queued changes are sent; failed ones are
+	// retried with backoff up to RetryMaxSec, and dropped after MaxAttempts
+	// (0 = never).
+	IntervalSec int `json:"interval_sec"`
+	RetryMaxSec int `json:"retry_max_sec"`
+	MaxAttempts int `json:"max_attempts"`
+}
+
+// ScriptGateConfig holds a flow in its phase until an expr-lang
+// expression over the flow's state, recent events, and cost allows it to
+// leave: Expr inline, or File holding it. Message is the blocker reported
+// when the expression is false; Events is how many recent events it sees.
+type ScriptGateConfig struct {
+	Expr    string `json:"expr"`
+	File    string `json:"file"`
+	Message string `json:"message"`
+	Events  int    `json:"events"`
+}
+
+// Source returns the gate's expression, reading File if set.
+func (s ScriptGateConfig) Source() (string, error) {
+	if s.File == "" {
+		return s.Expr, nil
+	}
+	data, err := os.ReadFile(s.File)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ApprovalsConfig lists the phases a flow may only leave with a human
+// approval, recorded through the approvals endpoint. A rejection sends the
+// flow back for rework.
+type ApprovalsConfig struct {
+	Phases []string `json:"phases"`
+}
+
+// NamespaceConfig holds the settings of one team's namespace. BudgetUSD
+// limits the budget its flows may hold at once: the caps of its unfinished
+// flows and the spend of its completed ones; zero means unlimited.
+// Providers replace the launch settings of global providers for its
+// sessions.
+type NamespaceConfig struct {
+	BudgetUSD float64                   `json:"budget_usd"`
+	Providers map[string]ProviderConfig `json:"providers"`
+}
+
+// SecretsConfig locates the stores behind "${backend:key}" references in
+// provider env values: the OS keychain service, and the encrypted secrets
+// File whose base64 key is read from the environment variable KeyEnv.
+type SecretsConfig struct {
+	File            string `json:"file"`
+	KeyEnv          string `json:"key_env"`
+	KeychainService string `json:"keychain_service"`
+}
+
+// EncryptionConfig seals session transcripts, snapshots, and audit payloads
+// at rest with AES-256-GCM under per-task keys. Key and PreviousKeys are
+// secret references, such as "${file:payload-key}", to base64 keys of 32
+// bytes. Payloads sealed with a previous key stay readable until `threebody
+// encryption rotate` reseals them with Key; with PreviousKeys but no Key,
+// rotating decrypts them.
+type EncryptionConfig struct {
+	Key          string   `json:"key"`
+	PreviousKeys []string `json:"previous_keys"`
+}
+
+// Enabled reports whether any key is configured.
+func (e EncryptionConfig) Enabled() bool {
+	return e.Key != "" || len(e.PreviousKeys) > 0
+}
+
+// ChannelConfig is a notification channel: "webhook" posts each
+// notification as JSON to URL with Headers, whose values may be secret
+// references; "log" writes notifications to the engine log; "email" mails
+// them through the SMTP server at Host:Port (default 587, upgraded with
+// STARTTLS when offered, or over TLS when TLS is set), authenticating with
+// Username and Password, which may be a secret reference. Templates render
+// the subject and body per event, and the "digest" template the digest of
+// the events in Batch, which are collected for BatchWindowSec (default 300)
+// before one email is sent. Events lists the flow lifecycle events —
+// flow_blocked, flow_completed, and flow_failed — announced on the channel.
+type ChannelConfig struct {
+	Type           string                         `json:"type"`
+	URL            string                         `json:"url"`
+	Headers        map[string]string              `json:"headers"`
+	Host           string                         `json:"host"`
+	Port           int                            `json:"port"`
+	Username       string                         `json:"username"`
+	Password       string                         `json:"password"`
+	TLS            bool                           `json:"tls"`
+	From           string                         `json:"from"`
+	To             []string                       `json:"to"`
+	Templates      map[string]EmailTemplateConfig `json:"templates"`
+	Batch          []string                       `json:"batch"`
+	BatchWindowSec int                            `json:"batch_window_sec"`
+	Events         []string                       `json:"events"`
+}
+
+// EmailTemplateConfig is a text/template subject and body for an email.
+type EmailTemplateConfig struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// FlowEvents are the flow lifecycle events a channel can be announced.
+var FlowEvents = []string{"flow_blocked", "flow_completed", "flow_failed"}
+
+// BudgetAlertConfig announces on Channels, once per budget cap, that a
+// task has spent Ratio of its budget.
+type BudgetAlertConfig struct {
+	Name     string   `json:"name"`
+	Ratio    float64  `json:"ratio"`
+	Channels []string `json:"channels"`
+}
+
+// HookConfig runs a hook at Points (default all) for Phases (default
+// all). Type "webhook" posts each call to URL with Headers, whose values
+// may be secret references; "exec" runs Command with Args in Dir (default
+// the flow's workspace); "plugin" loads Symbol from the Go plugin at Path.
+// A failing hook blocks the flow unless FailOpen is set.
+type HookConfig struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	URL        string            `json:"url"`
+	Headers    map[string]string `json:"headers"`
+	Command    string            `json:"command"`
+	Args       []string          `json:"args"`
+	Dir        string            `json:"dir"`
+	Path       string            `json:"path"`
+	Symbol     string            `json:"symbol"`
+	Points     []string          `json:"points"`
+	Phases     []string          `json:"phases"`
+	TimeoutSec int               `json:"timeout_sec"`
+	FailOpen   bool              `json:"fail_open"`
+}
+
+// hookPoints are the points accepted in hooks[].points.
+var hookPoints = map[string]bool{
+	"phase_enter":    true,
+	"phase_exit":     true,
+	"gate_blocked":   true,
+	"flow_completed": true,
+}
+
+// secretRef matches a provider env value that references a secret.
+var secretRef = regexp.MustCompile(`^\$\{([a-z]+):([^}]+)\}$`)
+
+// RedactionConfig masks secrets in event, audit, and transcript payloads.
+// Patterns are named regexes added to the built-in credential formats;
+// Keywords add key names whose assigned values are masked.
+type RedactionConfig struct {
+	Disabled bool              `json:"disabled"`
+	Patterns map[string]string `json:"patterns"`
+	Keywords []string          `json:"keywords"`
+}
+
+// ExecConfig controls the command execution endpoint. Workers may run only
+// the programs in AllowedCommands; with none, every command is refused.
+type ExecConfig struct {
+	AllowedCommands []string `json:"allowed_commands"`
+	TimeoutSec      int      `json:"timeout_sec"`
+	MaxOutputBytes  int      `json:"max_output_bytes"`
+}
+
+// RateLimitConfig is a token bucket refilled at PerMinute holding at most
+// Burst tokens (default PerMinute).
+type RateLimitConfig struct {
+	PerMinute int `json:"per_minute"`
+	Burst     int `json:"burst"`
+}
+
+// RateLimitsConfig adds rate limits beyond rate_limit_per_minute: per
+// operation class ("session", "file", "exec") for each task, and per
+// provider across all tasks for session starts. Adaptive slows each task's
+// own limits as its budget runs down.
+type RateLimitsConfig struct {
+	Operations map[string]RateLimitConfig `json:"operations"`
+	Providers  map[string]RateLimitConfig `json:"providers"`
+	Adaptive   AdaptiveRateConfig         `json:"adaptive"`
+}
+
+// AdaptiveRateConfig throttles a task as it spends its budget instead of
+// letting it run at full speed into the halt: once the task's spend reaches
+// a step's BudgetRatio of its cap, rate_limit_per_minute and the operation
+// limits refill at the step's RateFactor of their rate. The highest step
+// reached applies. Enabled without Steps halves the rate past
+// budget_warn_ratio.
+type AdaptiveRateConfig struct {
+	Enabled bool               `json:"enabled"`
+	Steps   []AdaptiveRateStep `json:"steps"`
+}
+
+// AdaptiveRateStep is one budget threshold of an adaptive rate limit.
+type AdaptiveRateStep struct {
+	BudgetRatio float64 `json:"budget_ratio"`
+	RateFactor  float64 `json:"rate_factor"`
+}
+
+// StepsFor returns the steps in force given the warn ratio: none unless
+// Enabled, and by default half rate past warnRatio.
+func (a AdaptiveRateConfig) StepsFor(warnRatio float64) []AdaptiveRateStep {
+	if !a.Enabled {
+		return nil
+	}
+	if len(a.Steps) == 0 {
+		return []AdaptiveRateStep{{BudgetRatio: warnRatio, RateFactor: 0.5}}
+	}
+	return a.Steps
+}
+
+var rateLimitOperations = map[string]bool{
+	"session": true,
+	"file":    true,
+	"exec":    true,
+}
+
+// GuardRulesConfig selects the guard rules each task's actions pass
+// through, in order. Default applies to tasks without a Tasks entry
+// (matched as in ConflictsConfig.StrategyFor); with neither, every rule
+// runs. The built-in rules are budget, permission, rate, and rounds;
+// business_hours is available when BusinessHours is set.
+type GuardRulesConfig struct {
+	Default       []string             `json:"default"`
+	Tasks         map[string][]string  `json:"tasks"`
+	BusinessHours *BusinessHoursConfig `json:"business_hours"`
+}
+
+// For returns the rule names for taskID, or nil for every rule.
+func (c GuardRulesConfig) For(taskID string) []string {
+	if rules, ok := c.Tasks[taskID]; ok {
+		return rules
+	}
+	rules, best, bestKey := c.Default, -1, ""
+	for pattern, r := range c.Tasks {
+		if ok, _ := path.Match(pattern, taskID); ok && (len(pattern) > best || len(pattern) == best && pattern < bestKey) {
+			rules, best, bestKey = r, len(pattern), pattern
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return rules
+}
+
+// BusinessHoursConfig is the daily window of the business_hours rule:
+// Start and End as "HH:MM" in Timezone (default UTC), on Days ("mon" to
+// "sun"; default every day). A window ending before it starts spans
+// midnight.
+type BusinessHoursConfig struct {
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Days     []string `json:"days"`
+	Timezone string   `json:"timezone"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Window parses the window into offsets from midnight, weekdays, and a
+// location.
+func (b BusinessHoursConfig) Window() (start, end time.Duration, days []time.Weekday, loc *time.Location, err error) {
+	if start, err = parseClock(b.Start); err != nil {
+		return 0, 0, nil, nil, fmt.Errorf("start: %w", err)
+	}
+	if end, err = parseClock(b.End); err != nil {
+		return 0, 0, nil, nil, fmt.Errorf("end: %w", err)
+	}
+	for _, d := range b.Days {
+		wd, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return 0, 0, nil, nil, fmt.Errorf("days: unknown day %q", d)
+		}
+		days = append(days, wd)
+	}
+	loc = time.UTC
+	if b.Timezone != "" {
+		if loc, err = time.LoadLocation(b.Timezone); err != nil {
+			return 0, 0, nil, nil, fmt.Errorf("timezone: %w", err)
+		}
+	}
+	return start, end, days, loc, nil
+}
+
+// parseClock parses "HH:MM" as an offset from midnight.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("want HH:MM, got %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// builtinGuardRules are the rules every guard has.
+var builtinGuardRules = map[string]bool{
+	"budget":     true,
+	"permission": true,
+	"rate":       true,
+	"rounds":     true,
+}
+
+// ConflictsConfig selects how the supervisor resolves conflicting intents:
+// "fail", "phase-priority", "first-acquired", or "escalate". Tasks overrides
+// Strategy per task ID or path.Match pattern of task IDs.
+type ConflictsConfig struct {
+	Strategy string            `json:"strategy"`
+	Tasks    map[string]string `json:"tasks"`
+}
+
+// StrategyFor returns the conflict strategy for taskID: an exact Tasks entry,
+// else the matching pattern with the longest text, else Strategy.
+func (c ConflictsConfig) StrategyFor(taskID string) string {
+	if s, ok := c.Tasks[taskID]; ok {
+		return s
+	}
+	strategy, best := c.Strategy, -1
+	for pattern, s := range c.Tasks {
+		if ok, _ := path.Match(pattern, taskID); ok && (len(pattern) > best || len(pattern) == best && s < strategy) {
+			strategy, best = s, len(pattern)
+		}
+	}
+	return strategy
+}
+
+// PolicyConfig sets the capability policy applied to every worker. Denied
+// patterns add to the built-in ones (.env, *.key, .git/*) and can never be
+// re-allowed. AllowedPaths and AllowedCommands, if set, bound the paths and
+// commands any sheet may grant; commands cover both the file verbs read and
+// write and exec program names. Tasks overrides the
+// policy per task ID or path.Match pattern of task IDs: its denied patterns
+// add to the global ones, its allowed lists replace them.
+type PolicyConfig struct {
+	DeniedPatterns  []string              `json:"denied_patterns"`
+	AllowedPaths    []string              `json:"allowed_paths"`
+	AllowedCommands []string              `json:"allowed_commands"`
+	Tasks           map[string]TaskPolicy `json:"tasks"`
+}
+
+// TaskPolicy is the policy override for matching tasks.
+type TaskPolicy struct {
+	DeniedPatterns  []string `json:"denied_patterns"`
+	AllowedPaths    []string `json:"allowed_paths"`
+	AllowedCommands []string `json:"allowed_commands"`
+}
+
+// For returns the configured policy for taskID, merging the Tasks entry
+// chosen as in ConflictsConfig.StrategyFor into the global policy.
+func (c PolicyConfig) For(taskID string) domain.CapabilityPolicy {
+	policy := domain.CapabilityPolicy{
+		TaskID:          taskID,
+		DeniedPatterns:  append([]string(nil), c.DeniedPatterns...),
+		AllowedPaths:    c.AllowedPaths,
+		AllowedCommands: c.AllowedCommands,
+	}
+	task, ok := c.Tasks[taskID]
+	if !ok {
+		best, bestKey := -1, ""
+		for pattern, t := range c.Tasks {
+			if m, _ := path.Match(pattern, taskID); m && (len(pattern) > best || len(pattern) == best && pattern < bestKey) {
+				task, ok, best, bestKey = t, true, len(pattern), pattern
+			}
+		}
+	}
+	if !ok {
+		return policy
+	}
+	policy.DeniedPatterns = append(policy.DeniedPatterns, task.DeniedPatterns...)
+	if len(task.AllowedPaths) > 0 {
+		policy.AllowedPaths = task.AllowedPaths
+	}
+	if len(task.AllowedCommands) > 0 {
+		policy.AllowedCommands = task.AllowedCommands
+	}
+	return policy
+}
+
+// policyProblems validates the denied patterns and allowed paths one policy
+// level adds, reporting under prefix any allowed path that a pattern in
+// denied would always deny.
+func policyProblems(prefix string, newDenied, allowed, denied []string) []string {
+	var problems []string
+	for _, p := range append(append([]string(nil), newDenied...), allowed...) {
+		if _, err := path.Match(strings.TrimSuffix(p, "/"), ""); err != nil || p == "" || path.IsAbs(p) {
+			problems = append(problems, fmt.Sprintf("%s: malformed pattern %q", prefix, p))
+		}
+	}
+	for _, p := range domain.PolicyConflicts(denied, allowed) {
+		problems = append(problems, prefix+": "+p)
+	}
+	return problems
+}
+
+var conflictStrategies = map[string]bool{
+	"fail":           true,
+	"phase-priority": true,
+	"first-acquired": true,
+	"escalate":       true,
 }
 
 // Config holds the engine's runtime configuration.
 type Config struct {
-	DBPath               string                    `json:"db_path"`
-	Workspace            string                    `json:"workspace"`
-	BudgetCapUSD         float64                   `json:"budget_cap_usd"`
-	Providers            map[string]ProviderConfig `json:"providers"`
-	CheckIntervalSec     int                       `json:"check_interval_sec"`
-	HeartbeatMaxAge      int                       `json:"heartbeat_max_age"`
-	MaxConcurrentWorkers int                       `json:"max_concurrent_workers"`
-	ListenAddr           string                    `json:"listen_addr"`
-	MaxRounds            int                       `json:"max_rounds"`
-	RateLimitPerMinute   int                       `json:"rate_limit_per_minute"`
-}
-
-// Load reads a JSON config file, applies defaults, and validates.
+	DBPath                string                         `json:"db_path"`
+	Workspace             string                         `json:"workspace"`
+	BudgetCapUSD          float64                        `json:"budget_cap_usd"`
+	BudgetWarnRatio       float64                        `json:"budget_warn_ratio"`
+	BudgetHaltRatio       float64                        `json:"budget_halt_ratio"`
+	BudgetAlerts          []BudgetAlertConfig            `json:"budget_alerts"`
+	Providers             map[string]ProviderConfig      `json:"providers"`
+	ProviderChecks        ProviderChecksConfig           `json:"provider_checks"`
+	CheckIntervalSec      int                            `json:"check_interval_sec"`
+	HeartbeatMaxAge       int                            `json:"heartbeat_max_age"`
+	MaxReplacements       int                            `json:"max_replacements"`
+	ProgressMaxAge        int                            `json:"progress_max_age"`
+	MaxConcurrentWorkers  int                            `json:"max_concurrent_workers"`
+	MaxConcurrentFlows    int                            `json:"max_concurrent_flows"`
+	PreemptFlows          bool                           `json:"preempt_flows"`
+	WorkerPoolSize        int                            `json:"worker_pool_size"`
+	ReservedPrioritySlots int                            `json:"reserved_priority_slots"`
+	WorkerRoleLimits      map[string]int                 `json:"worker_role_limits"`
+	QueueWorkers          bool                           `json:"queue_workers"`
+	AdvanceRetryAttempts  int                            `json:"advance_retry_attempts"`
+	AdvanceRetryBackoffMS int                            `json:"advance_retry_backoff_ms"`
+	CostBatchSize         int                            `json:"cost_batch_size"`
+	CostFlushIntervalMS   int                            `json:"cost_flush_interval_ms"`
+	ReadPoolSize          int                            `json:"read_pool_size"`
+	ListenAddr            string                         `json:"listen_addr"`
+	ListenPortTries       int                            `json:"listen_port_tries"`
+	MultiInstance         bool                           `json:"multi_instance"`
+	MaxRounds             int                            `json:"max_rounds"`
+	RateLimitPerMinute    int                            `json:"rate_limit_per_minute"`
+	RateLimitBurst        int                            `json:"rate_limit_burst"`
+	RateLimitPersist      bool                           `json:"rate_limit_persist"`
+	RateLimits            RateLimitsConfig               `json:"rate_limits"`
+	GuardCacheTTLMS       int                            `json:"guard_cache_ttl_ms"`
+	TaskCacheSize         int                            `json:"task_cache_size"`
+	GuardRules            GuardRulesConfig               `json:"guard_rules"`
+	Phases                map[string][]PhaseWorkerConfig `json:"phases"`
+	Retention             RetentionConfig                `json:"retention"`
+	Backup                BackupConfig                   `json:"backup"`
+	HTTP                  HTTPConfig                     `json:"http"`
+	ArtifactDir           string                         `json:"artifact_dir"`
+	DigestFormat          string                         `json:"digest_format"`
+	CodingStandards       string                         `json:"coding_standards"`
+	ObjectiveTemplates    map[string]string              `json:"objective_templates"`
+	PhaseSLASec           map[string]int                 `json:"phase_sla_sec"`
+	Workspaces            WorkspacesConfig               `json:"workspaces"`
+	Git                   GitConfig                      `json:"git"`
+	PullRequests          PullRequestsConfig             `json:"pull_requests"`
+	IntentQueue           IntentQueueConfig              `json:"intent_queue"`
+	Conflicts             ConflictsConfig                `json:"conflicts"`
+	TestGate              TestGateConfig                 `json:"test_gate"`
+	ScriptGates           map[string]ScriptGateConfig    `json:"script_gates"`
+	Approvals             ApprovalsConfig                `json:"approvals"`
+	Namespaces            map[string]NamespaceConfig     `json:"namespaces"`
+	Exec                  ExecConfig                     `json:"exec"`
+	Policy                PolicyConfig                   `json:"policy"`
+	Redaction             RedactionConfig                `json:"redaction"`
+	Secrets               SecretsConfig                  `json:"secrets"`
+	Encryption            EncryptionConfig               `json:"encryption"`
+	Notifications         map[string]ChannelConfig       `json:"notifications"`
+	Hooks                 []HookConfig                   `json:"hooks"`
+	Consensus             domain.ConsensusSettings       `json:"consensus"`
+	WatchIntervalSec      int                            `json:"watch_interval_sec"`
+	LeaderLeaseSec        int                            `json:"leader_lease_sec"`
+	ShutdownGraceSec      int                            `json:"shutdown_grace_sec"`
+	MinFreeDiskMB         int                            `json:"min_free_disk_mb"`
+	Chaos                 ChaosConfig                    `json:"chaos"`
+
+	// VerdictPolicies sets the consensus verdict policy per phase key.
+	VerdictPolicies map[string]domain.VerdictPolicy `json:"verdict_policies"`
+	// Reviewers are spawned read-only on entering phase F, keyed by role.
+	Reviewers map[string]ReviewerConfig `json:"reviewers"`
+	// CustomEvents declares the payload schema of each custom event type
+	// external systems may append, keyed by type such as "ci.build".
+	CustomEvents map[string]domain.CustomEventSchema `json:"custom_events"`
+	// CIGate holds flows in phase E until CI passes on the task branch.
+	CIGate CIGateConfig `json:"ci_gate"`
+	// IssueSync mirrors flows into Jira or Linear issues.
+	IssueSync IssueSyncConfig `json:"issue_sync"`
+	// WorkspaceConflict decides what happens to a flow created while
+	// another running, blocked, or paused flow holds its workspace: "share"
+	// (default) lets them share it, "fail" rejects the new flow, and "queue"
+	// queues it until the workspace is free.
+	WorkspaceConflict string `json:"workspace_conflict"`
+}
+
+// adapterProblems checks that a provider's adapter, if set, is a built-in
+// one; prefix is the provider's config path.
+func adapterProblems(prefix string, p ProviderConfig) []string {
+	if p.Adapter == "" || mcp.ValidAdapter(p.Adapter) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s.adapter: unknown adapter %q (want one of %s)",
+		prefix, p.Adapter, strings.Join(mcp.AdapterNames(), ", "))}
+}
+
+// scenarioProblems checks a mock provider's scenario; prefix is the
+// provider's config path.
+func scenarioProblems(prefix string, p ProviderConfig) []string {
+	if p.Scenario == "" {
+		return nil
+	}
+	if p.Command != "" {
+		return []string{prefix + ": command and scenario are mutually exclusive"}
+	}
+	if _, err := os.Stat(p.Scenario); err != nil {
+		return []string{fmt.Sprintf("%s.scenario: %v", prefix, err)}
+	}
+	return nil
+}
+
+// secretProblems checks the secret references in a provider's env values;
+// prefix is the provider's config path.
+func (c *Config) secretProblems(prefix string, p ProviderConfig) []string {
+	var problems []string
+	for k, v := range p.Env {
+		if m := secretRef.FindStringSubmatch(v); m != nil {
+			problems = append(problems, c.backendProblems(prefix+".env."+k, m[1])...)
+		}
+	}
+	return problems
+}
+
+// backendProblems checks that the secret backend a reference at path names
+// is available.
+func (c *Config) backendProblems(path, backend string) []string {
+	switch {
+	case backend != "env" && backend != "keychain" && backend != "file":
+		return []string{fmt.Sprintf("%s: unknown secret backend %q (want env, keychain or file)", path, backend)}
+	case backend == "file" && c.Secrets.File == "":
+		return []string{fmt.Sprintf("%s: file secrets need secrets.file", path)}
+	}
+	return nil
+}
+
+// encryptionProblems checks that every encryption key is a secret
+// reference, so no key sits in the config file.
+func (c *Config) encryptionProblems() []string {
+	var problems []string
+	check := func(path, ref string) {
+		m := secretRef.FindStringSubmatch(ref)
+		if m == nil {
+			problems = append(problems, fmt.Sprintf("%s must be a secret reference such as ${file:payload-key}", path))
+			return
+		}
+		problems = append(problems, c.backendProblems(path, m[1])...)
+	}
+	if c.Encryption.Key != "" {
+		check("encryption.key", c.Encryption.Key)
+	}
+	for i, ref := range c.Encryption.PreviousKeys {
+		check(fmt.Sprintf("encryption.previous_keys[%d]", i), ref)
+	}
+	return problems
+}
+
+// notificationProblems checks the notification channels and the budget
+// alerts that use them.
+func (c *Config) notificationProblems() []string {
+	var problems []string
+	for name, ch := range c.Notifications {
+		prefix := "notifications." + name
+		switch ch.Type {
+		case "webhook":
+			if u, err := url.Parse(ch.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				problems = append(problems, fmt.Sprintf("%s.url: want an http or https URL, got %q", prefix, ch.URL))
+			}
+		case "log":
+		case "email":
+			problems = append(problems, c.emailProblems(prefix, ch)...)
+		default:
+			problems = append(problems, fmt.Sprintf("%s.type: must be webhook, log, or email, got %q", prefix, ch.Type))
+		}
+		for k, v := range ch.Headers {
+			if m := secretRef.FindStringSubmatch(v); m != nil {
+				problems = append(problems, c.backendProblems(prefix+".headers."+k, m[1])...)
+			}
+		}
+		for _, event := range ch.Events {
+			known := false
+			for _, e := range FlowEvents {
+				known = known || e == event
+			}
+			if !known {
+				problems = append(problems, fmt.Sprintf("%s.events: unknown event %q, want one of %s", prefix, event, strings.Join(FlowEvents, ", ")))
+			}
+		}
+	}
+	seen := make(map[string]bool, len(c.BudgetAlerts))
+	for i, a := range c.BudgetAlerts {
+		prefix := fmt.Sprintf("budget_alerts[%d]", i)
+		switch {
+		case a.Name == "":
+			problems = append(problems, prefix+".name is required")
+		case seen[a.Name]:
+			problems = append(problems, fmt.Sprintf("%s.name: duplicate alert %q", prefix, a.Name))
+		}
+		seen[a.Name] = true
+		if a.Ratio <= 0 {
+			problems = append(problems, prefix+".ratio must be positive")
+		}
+		if len(a.Channels) == 0 {
+			problems = append(problems, prefix+".channels: at least one channel is required")
+		}
+		for _, ch := range a.Channels {
+			if _, ok := c.Notifications[ch]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.channels: unknown channel %q", prefix, ch))
+			}
+		}
+	}
+	return problems
+}
+
+// emailProblems checks an email notification channel.
+func (c *Config) emailProblems(prefix string, ch ChannelConfig) []string {
+	var problems []string
+	if ch.Host == "" {
+		problems = append(problems, prefix+".host is required")
+	}
+	if ch.Port < 1 || ch.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("%s.port: must be between 1 and 65535, got %d", prefix, ch.Port))
+	}
+	if _, err := mail.ParseAddress(ch.From); err != nil {
+		problems = append(problems, fmt.Sprintf("%s.from: %v", prefix, err))
+	}
+	if len(ch.To) == 0 {
+		problems = append(problems, prefix+".to: at least one recipient is required")
+	}
+	for i, to := range ch.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.to[%d]: %v", prefix, i, err))
+		}
+	}
+	if ch.Password != "" && ch.Username == "" {
+		problems = append(problems, prefix+".username is required with a password")
+	}
+	if m := secretRef.FindStringSubmatch(ch.Password); m != nil {
+		problems = append(problems, c.backendProblems(prefix+".password", m[1])...)
+	}
+	for event, t := range ch.Templates {
+		if _, err := notify.ParseTemplate(t.Subject, t.Body); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.templates.%s.%v", prefix, event, err))
+		}
+	}
+	if ch.BatchWindowSec < 0 {
+		problems = append(problems, prefix+".batch_window_sec must not be negative")
+	}
+	return problems
+}
+
+// hookProblems checks the hooks.
+func (c *Config) hookProblems() []string {
+	var problems []string
+	seen := make(map[string]bool, len(c.Hooks))
+	for i, h := range c.Hooks {
+		prefix := fmt.Sprintf("hooks[%d]", i)
+		switch {
+		case h.Name == "":
+			problems = append(problems, prefix+".name is required")
+		case seen[h.Name]:
+			problems = append(problems, fmt.Sprintf("%s.name: duplicate hook %q", prefix, h.Name))
+		}
+		seen[h.Name] = true
+		switch h.Type {
+		case "webhook":
+			if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				problems = append(problems, fmt.Sprintf("%s.url: want an http or https URL, got %q", prefix, h.URL))
+			}
+		case "exec":
+			if h.Command == "" {
+				problems = append(problems, prefix+".command is required for exec hooks")
+			}
+		case "plugin":
+			if h.Path == "" || h.Symbol == "" {
+				problems = append(problems, prefix+": path and symbol are required for plugin hooks")
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("%s.type: must be webhook, exec, or plugin, got %q", prefix, h.Type))
+		}
+		for k, v := range h.Headers {
+			if m := secretRef.FindStringSubmatch(v); m != nil {
+				problems = append(problems, c.backendProblems(prefix+".headers."+k, m[1])...)
+			}
+		}
+		for _, p := range h.Points {
+			if !hookPoints[p] {
+				problems = append(problems, fmt.Sprintf("%s.points: unknown point %q", prefix, p))
+			}
+		}
+		for _, p := range h.Phases {
+			if !validPhases[p] {
+				problems = append(problems, fmt.Sprintf("%s.phases: unknown phase %q", prefix, p))
+			}
+		}
+		if h.TimeoutSec < 0 {
+			problems = append(problems, prefix+".timeout_sec must not be negative")
+		}
+	}
+	return problems
+}
+
+// issueSyncProblems checks the issue tracker settings.
+func (c *Config) issueSyncProblems() []string {
+	is := c.IssueSync
+	var problems []string
+	switch is.Provider {
+	case "":
+		return nil
+	case "jira":
+		if u, err := url.Parse(is.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("issue_sync.url: want the Jira site's http or https URL, got %q", is.URL))
+		}
+	case "linear":
+		if is.DoneState == "" {
+			problems = append(problems, "issue_sync.done_state is required for linear: the ID of the state that closes an issue")
+		}
+	default:
+		return []string{fmt.Sprintf("issue_sync.provider: must be jira or linear, got %q", is.Provider)}
+	}
+	if is.Project == "" {
+		problems = append(problems, "issue_sync.project is required")
+	}
+	for ns := range is.NamespaceProjects {
+		if !domain.ValidNamespace(ns) {
+			problems = append(problems, fmt.Sprintf("issue_sync.namespace_projects: invalid namespace %q", ns))
+		}
+	}
+	if is.IntervalSec < 0 || is.RetryMaxSec < 0 || is.MaxAttempts < 0 {
+		problems = append(problems, "issue_sync: interval_sec, retry_max_sec, and max_attempts must not be negative")
+	}
+	return problems
+}
+
+// customEventProblems checks the custom event schemas.
+func (c *Config) customEventProblems() []string {
+	var problems []string
+	for t, schema := range c.CustomEvents {
+		prefix := "custom_events." + t
+		if !domain.EventType(t).Custom() {
+			problems = append(problems, fmt.Sprintf("custom_events: %q is not a namespaced type such as \"ci.build\"", t))
+		}
+		if schema.Version < 0 {
+			problems = append(problems, prefix+".version must be positive")
+		}
+		for field, typ := range schema.Fields {
+			if !domain.CustomEventFieldTypes[typ] {
+				problems = append(problems, fmt.Sprintf("%s.fields.%s: unknown type %q", prefix, field, typ))
+			}
+		}
+		for _, field := range schema.Required {
+			if _, ok := schema.Fields[field]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.required: %q is not a declared field", prefix, field))
+			}
+		}
+	}
+	return problems
+}
+
+// Load reads a JSON, YAML, or TOML config file, applies defaults, and
+// validates.
 func Load(path string) (*Config, error) {
+	return LoadWithOverrides(path, nil)
+}
+
+// LoadWithOverrides reads a config file, replaces the fields named in
+// overrides (see Override), applies defaults, and validates. Every problem
+// found is reported in one ErrConfigInvalid.
+func LoadWithOverrides(path string, overrides map[string]string) (*Config, error) {
+	cfg, problems, err := load(path, overrides)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 {
+		return nil, invalid(problems)
+	}
+	return cfg, nil
+}
+
+// Check loads path like LoadWithOverrides but returns the problems found
+// instead of failing on them. The error is set only when the file cannot be
+// read or parsed at all.
+func Check(path string, overrides map[string]string) ([]string, error) {
+	_, problems, err := load(path, overrides)
+	return problems, err
+}
+
+func load(path string, overrides map[string]string) (*Config, []string, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("read config file: %w", err)
+		return nil, nil, fmt.Errorf("read config file: %w", err)
+	}
+	doc, err := decode(path, data)
+	if err != nil {
+		return nil, nil, err
 	}
+	problems := checkSchema(doc)
 
+	// Mistyped values were reported above; decode the rest so the semantic
+	// checks still run.
+	normalized, _ := json.Marshal(doc)
 	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parse config JSON: %w", err)
-	}
+	_ = json.Unmarshal(normalized, &cfg)
 
+	problems = append(problems, cfg.override(overrides)...)
 	cfg.applyDefaults()
+	problems = append(problems, cfg.problems()...)
+	return &cfg, problems, nil
+}
 
-	if err := cfg.validate(); err != nil {
-		return nil, err
+func invalid(problems []string) error {
+	return &domain.EngineError{
+		Code:    domain.ErrConfigInvalid.Code,
+		Message: fmt.Sprintf("%s: %v", domain.ErrConfigInvalid.Message, problems),
 	}
+}
 
-	return &cfg, nil
+// memoryDBPath is the db_path of an in-memory database, as store.MemoryPath.
+const memoryDBPath = ":memory:"
+
+// InMemory reports whether the database is kept in memory rather than on
+// disk, as for tests and demos.
+func (c *Config) InMemory() bool {
+	return c.DBPath == memoryDBPath
 }
 
 func (c *Config) applyDefaults() {
+	if c.BudgetWarnRatio == 0 {
+		c.BudgetWarnRatio = 0.8
+	}
+	if c.BudgetHaltRatio == 0 {
+		c.BudgetHaltRatio = 1.0
+	}
+	if c.GuardCacheTTLMS == 0 {
+		c.GuardCacheTTLMS = 1000
+	}
+	if c.TaskCacheSize == 0 {
+		c.TaskCacheSize = 1024
+	}
+	if c.WatchIntervalSec == 0 {
+		c.WatchIntervalSec = 5
+	}
+	if c.LeaderLeaseSec == 0 {
+		c.LeaderLeaseSec = 15
+	}
+	if c.ProviderChecks.SmokeArgs == nil {
+		c.ProviderChecks.SmokeArgs = []string{"--version"}
+	}
+	if c.ProviderChecks.TimeoutSec == 0 {
+		c.ProviderChecks.TimeoutSec = 10
+	}
+	if c.ShutdownGraceSec == 0 {
+		c.ShutdownGraceSec = 10
+	}
+	if c.MinFreeDiskMB == 0 {
+		c.MinFreeDiskMB = 100
+	}
 	if c.CheckIntervalSec == 0 {
 		c.CheckIntervalSec = 10
 	}
@@ -60,6 +1067,9 @@ func (c *Config) applyDefaults() {
 	if c.ListenAddr == "" {
 		c.ListenAddr = ":9800"
 	}
+	if c.ListenPortTries == 0 {
+		c.ListenPortTries = 10
+	}
 	if c.MaxRounds == 0 {
 		c.MaxRounds = 3
 	}
@@ -69,9 +1079,201 @@ func (c *Config) applyDefaults() {
 	if c.HeartbeatMaxAge == 0 {
 		c.HeartbeatMaxAge = 30
 	}
+	if c.AdvanceRetryAttempts == 0 {
+		c.AdvanceRetryAttempts = 3
+	}
+	if c.AdvanceRetryBackoffMS == 0 {
+		c.AdvanceRetryBackoffMS = 25
+	}
+	if c.CostBatchSize == 0 {
+		c.CostBatchSize = 50
+	}
+	if c.CostFlushIntervalMS == 0 {
+		c.CostFlushIntervalMS = 500
+	}
+	if c.ReadPoolSize == 0 {
+		c.ReadPoolSize = 4
+	}
+	// An in-memory database has no directory to keep archives and backups
+	// next to; they stay off unless configured.
+	onDisk := c.DBPath != "" && !c.InMemory()
+	if c.Retention.ArchiveDir == "" && onDisk {
+		c.Retention.ArchiveDir = filepath.Join(filepath.Dir(c.DBPath), "archive")
+	}
+	if c.Backup.Dir == "" && onDisk {
+		c.Backup.Dir = filepath.Join(filepath.Dir(c.DBPath), "backups")
+	}
+	if c.DigestFormat == "" {
+		c.DigestFormat = "json"
+	}
+	if c.ArtifactDir == "" && onDisk {
+		c.ArtifactDir = filepath.Join(filepath.Dir(c.DBPath), "artifacts")
+	}
+	if c.ArtifactDir == "" && c.InMemory() {
+		c.ArtifactDir = filepath.Join(os.TempDir(), "threebody-artifacts")
+	}
+	if c.Backup.Keep == 0 {
+		c.Backup.Keep = 7
+	}
+	if c.HTTP.ReadTimeoutSec == 0 {
+		c.HTTP.ReadTimeoutSec = 60
+	}
+	if c.HTTP.IdleTimeoutSec == 0 {
+		c.HTTP.IdleTimeoutSec = 120
+	}
+	if c.HTTP.RequestTimeoutSec == 0 {
+		c.HTTP.RequestTimeoutSec = 30
+	}
+	if c.HTTP.MaxBodyBytes == 0 {
+		c.HTTP.MaxBodyBytes = 32 << 20
+	}
+	if c.Git.BranchPrefix == "" {
+		c.Git.BranchPrefix = "threebody/"
+	}
+	if c.Git.AuthorName == "" {
+		c.Git.AuthorName = "Three-Body Engine"
+	}
+	if c.Git.AuthorEmail == "" {
+		c.Git.AuthorEmail = "threebody@localhost"
+	}
+	if c.PullRequests.Provider != "" {
+		if c.PullRequests.TokenEnv == "" {
+			c.PullRequests.TokenEnv = strings.ToUpper(c.PullRequests.Provider) + "_TOKEN"
+		}
+		if c.PullRequests.Remote == "" {
+			c.PullRequests.Remote = "origin"
+		}
+		if c.PullRequests.BaseBranch == "" {
+			c.PullRequests.BaseBranch = "main"
+		}
+	}
+	if c.Workspaces.OnComplete == "" {
+		c.Workspaces.OnComplete = "keep"
+	}
+	if c.WorkspaceConflict == "" {
+		c.WorkspaceConflict = "share"
+	}
+	if c.Conflicts.Strategy == "" {
+		c.Conflicts.Strategy = "fail"
+	}
+	if c.Secrets.KeyEnv == "" {
+		c.Secrets.KeyEnv = "THREEBODY_SECRETS_KEY"
+	}
+	if c.Secrets.KeychainService == "" {
+		c.Secrets.KeychainService = "threebody"
+	}
+	if c.Exec.TimeoutSec == 0 {
+		c.Exec.TimeoutSec = 300
+	}
+	if c.Exec.MaxOutputBytes == 0 {
+		c.Exec.MaxOutputBytes = 1 << 20
+	}
+	if c.TestGate.Command != "" {
+		if c.TestGate.TimeoutSec == 0 {
+			c.TestGate.TimeoutSec = 600
+		}
+		if c.TestGate.CoveragePattern == "" {
+			c.TestGate.CoveragePattern = `coverage: ([0-9.]+)%`
+		}
+	}
+	if c.CIGate.Provider == "github" {
+		if c.CIGate.TokenEnv == "" {
+			c.CIGate.TokenEnv = "GITHUB_TOKEN"
+		}
+		if c.CIGate.PollIntervalSec == 0 {
+			c.CIGate.PollIntervalSec = 60
+		}
+	}
+	switch c.IssueSync.Provider {
+	case "jira":
+		if c.IssueSync.TokenEnv == "" {
+			c.IssueSync.TokenEnv = "JIRA_API_TOKEN"
+		}
+		if c.IssueSync.IssueType == "" {
+			c.IssueSync.IssueType = "Task"
+		}
+		if c.IssueSync.DoneState == "" {
+			c.IssueSync.DoneState = "Done"
+		}
+	case "linear":
+		if c.IssueSync.TokenEnv == "" {
+			c.IssueSync.TokenEnv = "LINEAR_API_KEY"
+		}
+	}
+	if c.IssueSync.Provider != "" {
+		if c.IssueSync.IntervalSec == 0 {
+			c.IssueSync.IntervalSec = 10
+		}
+		if c.IssueSync.RetryMaxSec == 0 {
+			c.IssueSync.RetryMaxSec = 3600
+		}
+	}
+	for t, schema := range c.CustomEvents {
+		if schema.Version == 0 {
+			schema.Version = 1
+			c.CustomEvents[t] = schema
+		}
+	}
+	if c.Workspaces.ArchiveDir == "" && c.Workspaces.Root != "" {
+		c.Workspaces.ArchiveDir = filepath.Join(c.Workspaces.Root, "archive")
+	}
+	for phase, workers := range c.Phases {
+		for i := range workers {
+			if workers[i].Count == 0 {
+				workers[i].Count = 1
+			}
+			if workers[i].SoftTimeoutSec == 0 {
+				workers[i].SoftTimeoutSec = 300
+			}
+			if workers[i].HardTimeoutSec == 0 {
+				workers[i].HardTimeoutSec = 600
+			}
+		}
+		c.Phases[phase] = workers
+	}
+	for role, r := range c.Reviewers {
+		if r.SoftTimeoutSec == 0 {
+			r.SoftTimeoutSec = 300
+		}
+		if r.HardTimeoutSec == 0 {
+			r.HardTimeoutSec = 600
+		}
+		c.Reviewers[role] = r
+	}
+	for name, ch := range c.Notifications {
+		if ch.Type != "email" {
+			continue
+		}
+		if ch.Port == 0 {
+			ch.Port = 587
+		}
+		if ch.BatchWindowSec == 0 {
+			ch.BatchWindowSec = 300
+		}
+		c.Notifications[name] = ch
+	}
+}
+
+// retainedTables are the table keys accepted in retention.tables.
+var retainedTables = map[string]bool{
+	"workflow_events": true,
+	"cost_deltas":     true,
+	"audit_records":   true,
+	"gate_decisions":  true,
+}
+
+// validPhases are the phase keys accepted in the phases map.
+var validPhases = map[string]bool{
+	string(domain.PhaseA): true,
+	string(domain.PhaseB): true,
+	string(domain.PhaseC): true,
+	string(domain.PhaseD): true,
+	string(domain.PhaseE): true,
+	string(domain.PhaseF): true,
+	string(domain.PhaseG): true,
 }
 
-func (c *Config) validate() error {
+func (c *Config) problems() []string {
 	var problems []string
 
 	if c.DBPath == "" {
@@ -86,12 +1288,357 @@ func (c *Config) validate() error {
 	if len(c.Providers) == 0 {
 		problems = append(problems, "at least one provider is required")
 	}
-
-	if len(problems) > 0 {
-		return &domain.EngineError{
-			Code:    domain.ErrConfigInvalid.Code,
-			Message: fmt.Sprintf("%s: %v", domain.ErrConfigInvalid.Message, problems),
+	for name, p := range c.Providers {
+		problems = append(problems, adapterProblems("providers."+name, p)...)
+		problems = append(problems, scenarioProblems("providers."+name, p)...)
+		problems = append(problems, c.secretProblems("providers."+name, p)...)
+	}
+	for ns, n := range c.Namespaces {
+		if !domain.ValidNamespace(ns) {
+			problems = append(problems, fmt.Sprintf("namespaces: %q is not a valid namespace name", ns))
+		}
+		if n.BudgetUSD < 0 {
+			problems = append(problems, fmt.Sprintf("namespaces.%s.budget_usd must not be negative", ns))
+		}
+		for name, p := range n.Providers {
+			if _, ok := c.Providers[name]; !ok {
+				problems = append(problems, fmt.Sprintf("namespaces.%s.providers: unknown provider %q", ns, name))
+			}
+			if p.Command == "" && p.Scenario == "" {
+				problems = append(problems, fmt.Sprintf("namespaces.%s.providers.%s.command is required", ns, name))
+			}
+			problems = append(problems, adapterProblems("namespaces."+ns+".providers."+name, p)...)
+			problems = append(problems, scenarioProblems("namespaces."+ns+".providers."+name, p)...)
+			problems = append(problems, c.secretProblems("namespaces."+ns+".providers."+name, p)...)
 		}
 	}
-	return nil
+	if c.BudgetWarnRatio < 0 || c.BudgetWarnRatio > c.BudgetHaltRatio {
+		problems = append(problems, "budget_warn_ratio must be between 0 and budget_halt_ratio")
+	}
+	if c.MaxReplacements < 0 {
+		problems = append(problems, "max_replacements must not be negative")
+	}
+	if c.DigestFormat != "json" && c.DigestFormat != "markdown" {
+		problems = append(problems, fmt.Sprintf("digest_format %q must be json or markdown", c.DigestFormat))
+	}
+	if c.ProgressMaxAge < 0 {
+		problems = append(problems, "progress_max_age must not be negative")
+	}
+	if c.MaxConcurrentFlows < 0 {
+		problems = append(problems, "max_concurrent_flows must not be negative")
+	}
+	if c.LeaderLeaseSec < 3 {
+		problems = append(problems, "leader_lease_sec must be at least 3")
+	}
+	if c.ProviderChecks.TimeoutSec < 0 {
+		problems = append(problems, "provider_checks.timeout_sec must not be negative")
+	}
+	if c.ListenPortTries < 0 {
+		problems = append(problems, "listen_port_tries must not be negative")
+	}
+	if c.ShutdownGraceSec < 0 {
+		problems = append(problems, "shutdown_grace_sec must not be negative")
+	}
+	if c.MinFreeDiskMB < 0 {
+		problems = append(problems, "min_free_disk_mb must not be negative")
+	}
+	if c.AdvanceRetryAttempts < 0 || c.AdvanceRetryBackoffMS < 0 {
+		problems = append(problems, "advance_retry_attempts and advance_retry_backoff_ms must not be negative")
+	}
+	if c.CostBatchSize < 0 || c.CostFlushIntervalMS < 0 {
+		problems = append(problems, "cost_batch_size and cost_flush_interval_ms must not be negative")
+	}
+	if c.ReadPoolSize < 0 {
+		problems = append(problems, "read_pool_size must not be negative")
+	}
+	if c.InMemory() && c.MultiInstance {
+		problems = append(problems, "multi_instance requires an on-disk db_path")
+	}
+	if c.InMemory() && c.Backup.IntervalSec > 0 && c.Backup.Dir == "" {
+		problems = append(problems, "backup.dir is required to back up an in-memory database")
+	}
+	if c.WorkerPoolSize < 0 {
+		problems = append(problems, "worker_pool_size must not be negative")
+	}
+	if c.ReservedPrioritySlots < 0 || c.WorkerPoolSize > 0 && c.ReservedPrioritySlots >= c.WorkerPoolSize {
+		problems = append(problems, "reserved_priority_slots must be between 0 and worker_pool_size-1")
+	}
+	for role, limit := range c.WorkerRoleLimits {
+		if limit < 0 {
+			problems = append(problems, fmt.Sprintf("worker_role_limits.%s must not be negative", role))
+		}
+	}
+	if c.RateLimitBurst < 0 {
+		problems = append(problems, "rate_limit_burst must not be negative")
+	}
+	if bh := c.GuardRules.BusinessHours; bh != nil {
+		if _, _, _, _, err := bh.Window(); err != nil {
+			problems = append(problems, fmt.Sprintf("guard_rules.business_hours.%v", err))
+		}
+	}
+	checkRules := func(key string, rules []string) {
+		for _, name := range rules {
+			if !builtinGuardRules[name] && !(name == "business_hours" && c.GuardRules.BusinessHours != nil) {
+				problems = append(problems, fmt.Sprintf("%s: unknown rule %q", key, name))
+			}
+		}
+	}
+	checkRules("guard_rules.default", c.GuardRules.Default)
+	for pattern, rules := range c.GuardRules.Tasks {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("guard_rules.tasks: malformed pattern %q", pattern))
+		}
+		checkRules("guard_rules.tasks."+pattern, rules)
+	}
+	for op, l := range c.RateLimits.Operations {
+		if !rateLimitOperations[op] {
+			problems = append(problems, fmt.Sprintf("rate_limits.operations: unknown operation %q (want session, file, or exec)", op))
+		}
+		if l.PerMinute <= 0 || l.Burst < 0 {
+			problems = append(problems, fmt.Sprintf("rate_limits.operations.%s: per_minute must be positive and burst not negative", op))
+		}
+	}
+	for name, l := range c.RateLimits.Providers {
+		if _, ok := c.Providers[name]; !ok {
+			problems = append(problems, fmt.Sprintf("rate_limits.providers: unknown provider %q", name))
+		}
+		if l.PerMinute <= 0 || l.Burst < 0 {
+			problems = append(problems, fmt.Sprintf("rate_limits.providers.%s: per_minute must be positive and burst not negative", name))
+		}
+	}
+	for i, step := range c.RateLimits.Adaptive.Steps {
+		if step.BudgetRatio <= 0 {
+			problems = append(problems, fmt.Sprintf("rate_limits.adaptive.steps[%d].budget_ratio must be positive", i))
+		}
+		if step.RateFactor <= 0 || step.RateFactor > 1 {
+			problems = append(problems, fmt.Sprintf("rate_limits.adaptive.steps[%d].rate_factor must be above 0 and at most 1", i))
+		}
+	}
+	if c.Retention.IntervalSec < 0 {
+		problems = append(problems, "retention.interval_sec must not be negative")
+	}
+	if c.Backup.IntervalSec < 0 || c.Backup.Keep < 0 {
+		problems = append(problems, "backup.interval_sec and backup.keep must not be negative")
+	}
+	if c.HTTP.ReadTimeoutSec < 0 || c.HTTP.IdleTimeoutSec < 0 || c.HTTP.RequestTimeoutSec < 0 || c.HTTP.MaxBodyBytes < 0 {
+		problems = append(problems, "http: timeouts and max_body_bytes must not be negative")
+	}
+	for route, sec := range c.HTTP.RouteTimeoutsSec {
+		if sec < 0 {
+			problems = append(problems, fmt.Sprintf("http.route_timeouts_sec.%s must not be negative", route))
+		}
+	}
+	if c.IntentQueue.TimeoutSec < 0 {
+		problems = append(problems, "intent_queue.timeout_sec must not be negative")
+	}
+	if !conflictStrategies[c.Conflicts.Strategy] {
+		problems = append(problems, fmt.Sprintf("conflicts.strategy: must be fail, phase-priority, first-acquired, or escalate, got %q", c.Conflicts.Strategy))
+	}
+	for pattern, s := range c.Conflicts.Tasks {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("conflicts.tasks: malformed pattern %q", pattern))
+		}
+		if !conflictStrategies[s] {
+			problems = append(problems, fmt.Sprintf("conflicts.tasks.%s: unknown strategy %q", pattern, s))
+		}
+	}
+	globalDenied := append(append([]string(nil), domain.DefaultDeniedPatterns...), c.Policy.DeniedPatterns...)
+	problems = append(problems, policyProblems("policy", c.Policy.DeniedPatterns, c.Policy.AllowedPaths, globalDenied)...)
+	for pattern, t := range c.Policy.Tasks {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("policy.tasks: malformed pattern %q", pattern))
+		}
+		prefix := "policy.tasks." + pattern
+		problems = append(problems, policyProblems(prefix, t.DeniedPatterns, t.AllowedPaths,
+			append(append([]string(nil), globalDenied...), t.DeniedPatterns...))...)
+		if len(t.AllowedPaths) == 0 {
+			for _, p := range domain.PolicyConflicts(t.DeniedPatterns, c.Policy.AllowedPaths) {
+				problems = append(problems, prefix+": "+p)
+			}
+		}
+	}
+	switch c.Workspaces.OnComplete {
+	case "keep", "delete", "archive":
+	default:
+		problems = append(problems, fmt.Sprintf("workspaces.on_complete: must be keep, delete, or archive, got %q", c.Workspaces.OnComplete))
+	}
+	switch c.WorkspaceConflict {
+	case "share", "fail", "queue":
+	default:
+		problems = append(problems, fmt.Sprintf("workspace_conflict: must be share, fail, or queue, got %q", c.WorkspaceConflict))
+	}
+	switch c.PullRequests.Provider {
+	case "":
+	case "github", "gitlab":
+		if !c.Git.Enabled {
+			problems = append(problems, "pull_requests requires git.enabled")
+		}
+		if c.PullRequests.Repo == "" {
+			problems = append(problems, "pull_requests.repo is required")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("pull_requests.provider: must be github or gitlab, got %q", c.PullRequests.Provider))
+	}
+	switch c.CIGate.Provider {
+	case "":
+	case "github":
+		if c.CIGate.Repo == "" {
+			problems = append(problems, "ci_gate.repo is required for the github provider")
+		}
+		if c.CIGate.PollIntervalSec < 0 {
+			problems = append(problems, "ci_gate.poll_interval_sec must not be negative")
+		}
+	case "webhook":
+		if c.CIGate.WebhookSecret == "" {
+			problems = append(problems, "ci_gate.webhook_secret is required for the webhook provider")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("ci_gate.provider: must be github or webhook, got %q", c.CIGate.Provider))
+	}
+	if m := secretRef.FindStringSubmatch(c.CIGate.WebhookSecret); m != nil {
+		problems = append(problems, c.backendProblems("ci_gate.webhook_secret", m[1])...)
+	}
+	problems = append(problems, c.issueSyncProblems()...)
+	for table, policy := range c.Retention.Tables {
+		if !retainedTables[table] {
+			problems = append(problems, fmt.Sprintf("retention.tables: unsupported table %q", table))
+		}
+		if policy.MaxAgeDays < 0 || policy.MaxRowsPerTask < 0 {
+			problems = append(problems, fmt.Sprintf("retention.tables.%s: limits must not be negative", table))
+		}
+	}
+	for _, r := range []struct {
+		name string
+		rate float64
+	}{
+		{"kill_session_rate", c.Chaos.KillSessionRate},
+		{"malformed_event_rate", c.Chaos.MalformedEventRate},
+		{"drop_heartbeat_rate", c.Chaos.DropHeartbeatRate},
+		{"write_delay_rate", c.Chaos.WriteDelayRate},
+	} {
+		if r.rate < 0 || r.rate > 1 {
+			problems = append(problems, fmt.Sprintf("chaos.%s must be between 0 and 1", r.name))
+		}
+	}
+	problems = append(problems, c.encryptionProblems()...)
+	problems = append(problems, c.notificationProblems()...)
+	problems = append(problems, c.hookProblems()...)
+	problems = append(problems, c.customEventProblems()...)
+	if c.Chaos.WriteDelayMS < 0 {
+		problems = append(problems, "chaos.write_delay_ms must not be negative")
+	}
+	if c.CodingStandards != "" {
+		if _, err := os.Stat(c.CodingStandards); err != nil {
+			problems = append(problems, fmt.Sprintf("coding_standards: %v", err))
+		}
+	}
+	for _, p := range c.Consensus.Problems() {
+		problems = append(problems, "consensus."+p)
+	}
+	for phase, policy := range c.VerdictPolicies {
+		if !validPhases[phase] {
+			problems = append(problems, fmt.Sprintf("verdict_policies: unknown phase %q", phase))
+		}
+		if policy.MinReviewers < 0 {
+			problems = append(problems, fmt.Sprintf("verdict_policies.%s.min_reviewers must not be negative", phase))
+		}
+	}
+	if c.Exec.TimeoutSec < 0 || c.Exec.MaxOutputBytes < 0 {
+		problems = append(problems, "exec: timeout_sec and max_output_bytes must not be negative")
+	}
+	for _, cmd := range c.Exec.AllowedCommands {
+		if cmd == "" || strings.ContainsAny(cmd, `/\`) {
+			problems = append(problems, fmt.Sprintf("exec.allowed_commands: %q must be a bare program name", cmd))
+		}
+	}
+	for name, pattern := range c.Redaction.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			problems = append(problems, fmt.Sprintf("redaction.patterns.%s: %v", name, err))
+		}
+	}
+	for phase, sg := range c.ScriptGates {
+		prefix := "script_gates." + phase
+		if !validPhases[phase] || phase == string(domain.PhaseG) {
+			problems = append(problems, fmt.Sprintf("script_gates: unknown phase %q", phase))
+		}
+		if (sg.Expr == "") == (sg.File == "") {
+			problems = append(problems, prefix+": exactly one of expr and file is required")
+			continue
+		}
+		if sg.Events < 0 {
+			problems = append(problems, prefix+".events must not be negative")
+		}
+		src, err := sg.Source()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s.file: %v", prefix, err))
+			continue
+		}
+		if _, err := expr.Compile(src); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", prefix, err))
+		}
+	}
+	if c.TestGate.TimeoutSec < 0 {
+		problems = append(problems, "test_gate.timeout_sec must not be negative")
+	}
+	if c.TestGate.MinCoverage < 0 || c.TestGate.MinCoverage > 100 {
+		problems = append(problems, "test_gate.min_coverage must be between 0 and 100")
+	}
+	if c.TestGate.CoveragePattern != "" {
+		if re, err := regexp.Compile(c.TestGate.CoveragePattern); err != nil {
+			problems = append(problems, fmt.Sprintf("test_gate.coverage_pattern: %v", err))
+		} else if re.NumSubexp() < 1 {
+			problems = append(problems, "test_gate.coverage_pattern needs a group capturing the percentage")
+		}
+	}
+	for role, r := range c.Reviewers {
+		if !reviewerRoles[role] {
+			problems = append(problems, fmt.Sprintf("reviewers: unknown role %q (want primary, secondary or lead)", role))
+		}
+		if _, ok := c.Providers[r.Provider]; !ok {
+			problems = append(problems, fmt.Sprintf("reviewers.%s: unknown provider %q", role, r.Provider))
+		}
+		if r.HardTimeoutSec < r.SoftTimeoutSec {
+			problems = append(problems, fmt.Sprintf("reviewers.%s: hard_timeout_sec must be >= soft_timeout_sec", role))
+		}
+	}
+	for phase, text := range c.ObjectiveTemplates {
+		if !validPhases[phase] {
+			problems = append(problems, fmt.Sprintf("objective_templates: unknown phase %q", phase))
+		} else if _, err := template.New(phase).Parse(text); err != nil {
+			problems = append(problems, fmt.Sprintf("objective_templates.%s: %v", phase, err))
+		}
+	}
+	for phase, sec := range c.PhaseSLASec {
+		if !validPhases[phase] || phase == string(domain.PhaseG) {
+			problems = append(problems, fmt.Sprintf("phase_sla_sec: %q is not a phase a flow can leave", phase))
+		} else if sec <= 0 {
+			problems = append(problems, fmt.Sprintf("phase_sla_sec.%s must be positive", phase))
+		}
+	}
+	for _, phase := range c.Approvals.Phases {
+		if !validPhases[phase] || phase == string(domain.PhaseG) {
+			problems = append(problems, fmt.Sprintf("approvals.phases: %q is not a phase a flow can leave", phase))
+		}
+	}
+	for phase, workers := range c.Phases {
+		if !validPhases[phase] {
+			problems = append(problems, fmt.Sprintf("phases: unknown phase %q", phase))
+		}
+		for i, w := range workers {
+			if w.Role == "" {
+				problems = append(problems, fmt.Sprintf("phases.%s[%d]: role is required", phase, i))
+			}
+			if _, ok := c.Providers[w.Provider]; !ok {
+				problems = append(problems, fmt.Sprintf("phases.%s[%d]: unknown provider %q", phase, i, w.Provider))
+			}
+			if w.Count < 0 {
+				problems = append(problems, fmt.Sprintf("phases.%s[%d]: count must not be negative", phase, i))
+			}
+			if w.HardTimeoutSec < w.SoftTimeoutSec {
+				problems = append(problems, fmt.Sprintf("phases.%s[%d]: hard_timeout_sec must be >= soft_timeout_sec", phase, i))
+			}
+		}
+	}
+
+	return problems
 }
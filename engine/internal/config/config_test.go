@@ -3,7 +3,10 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/anthropics/three-body-engine/internal/domain"
 )
@@ -181,4 +184,1145 @@ func TestLoad_DefaultsApplied(t *testing.T) {
 	if cfg.RateLimitPerMinute != 60 {
 		t.Errorf("RateLimitPerMinute = %d, want 60", cfg.RateLimitPerMinute)
 	}
+	if cfg.ReadPoolSize != 4 {
+		t.Errorf("ReadPoolSize = %d, want 4", cfg.ReadPoolSize)
+	}
+	if cfg.GuardCacheTTLMS != 1000 {
+		t.Errorf("GuardCacheTTLMS = %d, want 1000", cfg.GuardCacheTTLMS)
+	}
+	if cfg.TaskCacheSize != 1024 {
+		t.Errorf("TaskCacheSize = %d, want 1024", cfg.TaskCacheSize)
+	}
+	if cfg.ListenPortTries != 10 {
+		t.Errorf("ListenPortTries = %d, want 10", cfg.ListenPortTries)
+	}
+	if !reflect.DeepEqual(cfg.ProviderChecks.SmokeArgs, []string{"--version"}) || cfg.ProviderChecks.TimeoutSec != 10 {
+		t.Errorf("ProviderChecks = %+v, want --version with a 10s timeout", cfg.ProviderChecks)
+	}
+	if cfg.ShutdownGraceSec != 10 {
+		t.Errorf("ShutdownGraceSec = %d, want 10", cfg.ShutdownGraceSec)
+	}
+	if cfg.WorkspaceConflict != "share" {
+		t.Errorf("WorkspaceConflict = %q, want share", cfg.WorkspaceConflict)
+	}
+}
+
+func TestLoad_WorkspaceConflict(t *testing.T) {
+	dir := t.TempDir()
+	base := strings.TrimSuffix(strings.TrimSpace(validJSON()), "}")
+	cfg, err := Load(writeConfig(t, dir, base+`, "workspace_conflict": "queue"}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.WorkspaceConflict != "queue" {
+		t.Errorf("WorkspaceConflict = %q, want queue", cfg.WorkspaceConflict)
+	}
+
+	_, err = Load(writeConfig(t, dir, base+`, "workspace_conflict": "wait"}`))
+	if err == nil || !strings.Contains(err.Error(), `workspace_conflict: must be share, fail, or queue, got "wait"`) {
+		t.Errorf("Load = %v, want a workspace_conflict error", err)
+	}
+}
+
+func TestLoad_PhaseWorkersDefaults(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"phases": {"C": [{"role": "lead", "provider": "claude"}]}
+	}`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	w := cfg.Phases["C"][0]
+	if w.Count != 1 || w.SoftTimeoutSec != 300 || w.HardTimeoutSec != 600 {
+		t.Errorf("phase worker defaults = %+v", w)
+	}
+}
+
+func TestLoad_PhaseWorkersInvalid(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"phases": {"Z": [{"role": "", "provider": "gemini"}]}
+	}`)
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected error for invalid phases, got nil")
+	}
+	msg := err.Error()
+	for _, want := range []string{`unknown phase "Z"`, "role is required", `unknown provider "gemini"`} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q missing %q", msg, want)
+		}
+	}
+}
+
+func TestLoad_NegativeMaxConcurrentFlows(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"max_concurrent_flows": -1
+	}`)
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "max_concurrent_flows") {
+		t.Fatalf("expected max_concurrent_flows error, got %v", err)
+	}
+}
+
+func TestLoad_ReservedSlotsExceedPool(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"worker_pool_size": 2,
+		"reserved_priority_slots": 2
+	}`)
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "reserved_priority_slots") {
+		t.Fatalf("expected reserved_priority_slots error, got %v", err)
+	}
+}
+
+func TestLoad_RetentionUnknownTable(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"retention": {"tables": {"tasks": {"max_age_days": 30}}}
+	}`)
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), `unsupported table "tasks"`) {
+		t.Fatalf("expected unsupported table error, got %v", err)
+	}
+}
+
+func TestLoad_MaintenanceDirDefaults(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, validJSON())
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if want := filepath.Join("/tmp", "archive"); cfg.Retention.ArchiveDir != want {
+		t.Errorf("ArchiveDir = %q, want %q", cfg.Retention.ArchiveDir, want)
+	}
+	if want := filepath.Join("/tmp", "backups"); cfg.Backup.Dir != want {
+		t.Errorf("Backup.Dir = %q, want %q", cfg.Backup.Dir, want)
+	}
+	if want := filepath.Join("/tmp", "artifacts"); cfg.ArtifactDir != want {
+		t.Errorf("ArtifactDir = %q, want %q", cfg.ArtifactDir, want)
+	}
+	if cfg.Backup.Keep != 7 {
+		t.Errorf("Backup.Keep = %d, want 7", cfg.Backup.Keep)
+	}
+}
+
+func TestLoad_InMemory(t *testing.T) {
+	dir := t.TempDir()
+	mem := strings.Replace(validJSON(), `"/tmp/test.db"`, `":memory:"`, 1)
+	cfg, err := Load(writeConfig(t, dir, mem))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.InMemory() {
+		t.Error("InMemory = false, want true")
+	}
+	if cfg.Retention.ArchiveDir != "" || cfg.Backup.Dir != "" {
+		t.Errorf("archive dir %q, backup dir %q: want none for an in-memory database", cfg.Retention.ArchiveDir, cfg.Backup.Dir)
+	}
+	if want := filepath.Join(os.TempDir(), "threebody-artifacts"); cfg.ArtifactDir != want {
+		t.Errorf("ArtifactDir = %q, want %q", cfg.ArtifactDir, want)
+	}
+
+	for _, extra := range []string{`"multi_instance": true`, `"backup": {"interval_sec": 60}`} {
+		path := writeConfig(t, dir, strings.Replace(mem, "{", "{"+extra+",", 1))
+		if _, err := Load(path); err == nil {
+			t.Errorf("Load with %s succeeded, want an error", extra)
+		}
+	}
+}
+
+func TestLoad_MockProvider(t *testing.T) {
+	dir := t.TempDir()
+	scenario := filepath.Join(dir, "scenario.json")
+	if err := os.WriteFile(scenario, []byte(`{"steps": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"mock": {"scenario": "`+scenario+`"}},
+		"namespaces": {"team-a": {"providers": {"mock": {"scenario": "`+scenario+`"}}}}
+	}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Providers["mock"].Scenario != scenario {
+		t.Errorf("Scenario = %q, want %q", cfg.Providers["mock"].Scenario, scenario)
+	}
+
+	path = writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {
+			"both": {"command": "claude", "scenario": "`+scenario+`"},
+			"missing": {"scenario": "/nonexistent/scenario.json"},
+			"typo": {"command": "claude", "adapter": "claud"}
+		}
+	}`)
+	_, err = Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"providers.both: command and scenario are mutually exclusive", "providers.missing.scenario", `providers.typo.adapter: unknown adapter "claud"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
+
+func TestLoad_Chaos(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, validJSON())
+	cfg, err := LoadWithOverrides(path, map[string]string{
+		"chaos.enabled":           "true",
+		"chaos.kill_session_rate": "0.1",
+		"chaos.write_delay_ms":    "50",
+	})
+	if err != nil {
+		t.Fatalf("LoadWithOverrides: %v", err)
+	}
+	if !cfg.Chaos.Enabled || cfg.Chaos.KillSessionRate != 0.1 || cfg.Chaos.WriteDelayMS != 50 {
+		t.Errorf("Chaos = %+v", cfg.Chaos)
+	}
+
+	_, err = LoadWithOverrides(path, map[string]string{
+		"chaos.drop_heartbeat_rate": "1.5",
+		"chaos.write_delay_ms":      "-1",
+	})
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"chaos.drop_heartbeat_rate must be between 0 and 1", "chaos.write_delay_ms"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
+
+func TestLoad_ConflictStrategies(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"conflicts": {
+			"strategy": "first-acquired",
+			"tasks": {"release-*": "escalate", "release-1.*": "phase-priority", "hotfix": "fail"}
+		}
+	}`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for taskID, want := range map[string]string{
+		"feature-x":   "first-acquired",
+		"hotfix":      "fail",
+		"release-2.0": "escalate",
+		"release-1.4": "phase-priority",
+	} {
+		if got := cfg.Conflicts.StrategyFor(taskID); got != want {
+			t.Errorf("StrategyFor(%q) = %q, want %q", taskID, got, want)
+		}
+	}
+}
+
+func TestLoad_InvalidDigestFormat(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"digest_format": "yaml"
+	}`)
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), `digest_format "yaml"`) {
+		t.Fatalf("expected digest_format error, got %v", err)
+	}
+}
+
+func TestLoad_InvalidObjectiveTemplates(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"objective_templates": {"Q": "x", "C": "{{.Role"},
+		"coding_standards": "/nonexistent/STANDARDS.md"
+	}`)
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{`unknown phase "Q"`, "objective_templates.C", "coding_standards"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
+
+func TestLoad_UnknownConflictStrategy(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"conflicts": {"tasks": {"t1": "coin-flip"}}
+	}`)
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), `unknown strategy "coin-flip"`) {
+		t.Fatalf("expected unknown strategy error, got %v", err)
+	}
+}
+
+func TestLoad_InvalidConsensus(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"consensus": {"weights": {"primary": 0.5, "lead": -0.1}, "pass_threshold": 3.0, "conditional_threshold": 3.5}
+	}`)
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"consensus.weights.lead must not be negative", "consensus.weights must sum to 1", "consensus.conditional_threshold must not exceed"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
+
+func TestLoad_InvalidVerdictPolicies(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"verdict_policies": {"F": {"lead_veto": true, "min_reviewers": -1}, "X": {}}
+	}`)
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"verdict_policies.F.min_reviewers", `verdict_policies: unknown phase "X"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
+
+func TestLoad_Reviewers(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}, "codex": {"command": "codex"}},
+		"reviewers": {"primary": {"provider": "claude"}, "lead": {"provider": "codex", "hard_timeout_sec": 900}}
+	}`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Reviewers["primary"]; got.Provider != "claude" || got.SoftTimeoutSec != 300 || got.HardTimeoutSec != 600 {
+		t.Errorf("primary = %+v, want claude with default timeouts", got)
+	}
+	if got := cfg.Reviewers["lead"]; got.HardTimeoutSec != 900 {
+		t.Errorf("lead hard timeout = %d, want 900", got.HardTimeoutSec)
+	}
+}
+
+func TestLoad_InvalidReviewers(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"reviewers": {"primary": {"provider": "gemini"}, "tester": {"provider": "claude"}}
+	}`)
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{`reviewers.primary: unknown provider "gemini"`, `reviewers: unknown role "tester"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
+
+func TestLoad_TestGate(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"test_gate": {"command": "go", "args": ["test", "-cover", "./..."], "min_coverage": 70}
+	}`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.TestGate.TimeoutSec != 600 || cfg.TestGate.CoveragePattern == "" {
+		t.Errorf("TestGate = %+v, want default timeout and coverage pattern", cfg.TestGate)
+	}
+
+	path = writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"test_gate": {"command": "make", "min_coverage": 120, "coverage_pattern": "total: [0-9]+%"}
+	}`)
+	_, err = Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"test_gate.min_coverage must be between 0 and 100", "test_gate.coverage_pattern needs a group"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
+
+func TestLoad_Exec(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"exec": {"allowed_commands": ["go", "make"]}
+	}`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Exec.AllowedCommands) != 2 || cfg.Exec.TimeoutSec != 300 || cfg.Exec.MaxOutputBytes != 1<<20 {
+		t.Errorf("Exec = %+v, want two commands and default limits", cfg.Exec)
+	}
+
+	path = writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"exec": {"allowed_commands": ["/bin/sh"], "timeout_sec": -1}
+	}`)
+	_, err = Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{`"/bin/sh" must be a bare program name`, "exec: timeout_sec"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_RateLimits(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"rate_limit_burst": 10,
+		"rate_limits": {
+			"operations": {"file": {"per_minute": 600, "burst": 50}},
+			"providers": {"claude": {"per_minute": 20}},
+			"adaptive": {"enabled": true}
+		}
+	}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.RateLimitBurst != 10 || cfg.RateLimits.Operations["file"].Burst != 50 || cfg.RateLimits.Providers["claude"].PerMinute != 20 {
+		t.Errorf("rate limits = %d %+v", cfg.RateLimitBurst, cfg.RateLimits)
+	}
+	if steps := cfg.RateLimits.Adaptive.StepsFor(cfg.BudgetWarnRatio); len(steps) != 1 || steps[0] != (AdaptiveRateStep{BudgetRatio: 0.8, RateFactor: 0.5}) {
+		t.Errorf("default adaptive steps = %+v, want half rate past 0.8", steps)
+	}
+
+	path = writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"rate_limit_burst": -1,
+		"rate_limits": {
+			"operations": {"deploy": {"per_minute": 1}, "exec": {"per_minute": 0}},
+			"providers": {"gemini": {"per_minute": 5}},
+			"adaptive": {"enabled": true, "steps": [{"budget_ratio": 0.5, "rate_factor": 0.5}, {"budget_ratio": 0, "rate_factor": 0}]}
+		}
+	}`)
+	_, err = Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		"rate_limit_burst must not be negative",
+		`unknown operation "deploy"`,
+		"rate_limits.operations.exec: per_minute must be positive",
+		`rate_limits.providers: unknown provider "gemini"`,
+		"rate_limits.adaptive.steps[1].budget_ratio must be positive",
+		"rate_limits.adaptive.steps[1].rate_factor must be above 0 and at most 1",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_GuardRules(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"guard_rules": {
+			"tasks": {"spike-*": ["permission", "rate"], "prod-*": ["business_hours", "budget", "permission", "rate", "rounds"]},
+			"business_hours": {"start": "09:00", "end": "18:00", "days": ["mon", "tue", "wed", "thu", "fri"]}
+		}
+	}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.GuardRules.For("spike-1"); !reflect.DeepEqual(got, []string{"permission", "rate"}) {
+		t.Errorf("For(spike-1) = %v", got)
+	}
+	if got := cfg.GuardRules.For("feature"); got != nil {
+		t.Errorf("For(feature) = %v, want nil for every rule", got)
+	}
+	start, end, days, _, err := cfg.GuardRules.BusinessHours.Window()
+	if err != nil || start != 9*time.Hour || end != 18*time.Hour || len(days) != 5 {
+		t.Errorf("Window = %v %v %v %v", start, end, days, err)
+	}
+
+	path = writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"guard_rules": {"default": ["budget", "business_hours", "typo"]}
+	}`)
+	_, err = Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{`guard_rules.default: unknown rule "business_hours"`, `unknown rule "typo"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_Policy(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"policy": {
+			"denied_patterns": ["secrets/"],
+			"allowed_paths": ["src/", "docs/"],
+			"tasks": {"infra-*": {"denied_patterns": ["*.tfstate"], "allowed_commands": ["read"]}, "docs": {"allowed_paths": ["docs/"]}}
+		}
+	}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	p := cfg.Policy.For("infra-1")
+	if !reflect.DeepEqual(p.DeniedPatterns, []string{"secrets/", "*.tfstate"}) ||
+		!reflect.DeepEqual(p.AllowedPaths, []string{"src/", "docs/"}) ||
+		!reflect.DeepEqual(p.AllowedCommands, []string{"read"}) {
+		t.Errorf("For(infra-1) = %+v", p)
+	}
+	if p := cfg.Policy.For("docs"); !reflect.DeepEqual(p.AllowedPaths, []string{"docs/"}) || len(p.AllowedCommands) != 0 {
+		t.Errorf("For(docs) = %+v", p)
+	}
+
+	path = writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"policy": {
+			"denied_patterns": ["secrets/"],
+			"allowed_paths": ["src/", ".git/hooks/"],
+			"tasks": {"t1": {"allowed_paths": ["secrets/prod/"]}, "t2": {"denied_patterns": ["src/"]}}
+		}
+	}`)
+	_, err = Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		`policy: allowed path ".git/hooks/" is denied by ".git/*"`,
+		`policy.tasks.t1: allowed path "secrets/prod/" is denied by "secrets/"`,
+		`policy.tasks.t2: allowed path "src/" is denied by "src/"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_Redaction(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"redaction": {"patterns": {"ticket": "TICKET-[0-9]+"}, "keywords": ["pin"]}
+	}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Redaction.Patterns["ticket"] == "" || len(cfg.Redaction.Keywords) != 1 {
+		t.Errorf("Redaction = %+v", cfg.Redaction)
+	}
+
+	path = writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"redaction": {"patterns": {"broken": "("}}
+	}`)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "redaction.patterns.broken") {
+		t.Errorf("err = %v, want invalid pattern error", err)
+	}
+}
+
+func TestLoad_SecretRefs(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude", "env": {"ANTHROPIC_API_KEY": "${keychain:anthropic}"}}}
+	}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Secrets.KeyEnv != "THREEBODY_SECRETS_KEY" || cfg.Secrets.KeychainService != "threebody" {
+		t.Errorf("Secrets = %+v, want defaults", cfg.Secrets)
+	}
+
+	path = writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude", "env": {"A": "${vault:x}", "B": "${file:anthropic}"}}}
+	}`)
+	_, err = Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{`unknown secret backend "vault"`, "file secrets need secrets.file"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_Encryption(t *testing.T) {
+	dir := t.TempDir()
+	base := strings.TrimSuffix(strings.TrimSpace(validJSON()), "}")
+	cfg, err := Load(writeConfig(t, dir, base+`,
+		"encryption": {"key": "${keychain:payload-key}", "previous_keys": ["${env:OLD_PAYLOAD_KEY}"]}
+	}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.Encryption.Enabled() || cfg.Encryption.Key != "${keychain:payload-key}" {
+		t.Errorf("Encryption = %+v", cfg.Encryption)
+	}
+
+	_, err = Load(writeConfig(t, dir, base+`,
+		"encryption": {"key": "c2VjcmV0", "previous_keys": ["${file:old}"]}
+	}`))
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"encryption.key must be a secret reference", "encryption.previous_keys[0]: file secrets need secrets.file"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_BudgetAlerts(t *testing.T) {
+	dir := t.TempDir()
+	base := strings.TrimSuffix(strings.TrimSpace(validJSON()), "}")
+	cfg, err := Load(writeConfig(t, dir, base+`,
+		"notifications": {
+			"ops": {"type": "webhook", "url": "https://hooks.example.com/t", "headers": {"Authorization": "${env:HOOK_TOKEN}"}},
+			"log": {"type": "log"}
+		},
+		"budget_alerts": [
+			{"name": "half", "ratio": 0.5, "channels": ["log"]},
+			{"name": "nearly", "ratio": 0.9, "channels": ["ops", "log"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.BudgetAlerts) != 2 || cfg.BudgetAlerts[1].Channels[0] != "ops" || cfg.Notifications["ops"].Type != "webhook" {
+		t.Errorf("BudgetAlerts = %+v, Notifications = %+v", cfg.BudgetAlerts, cfg.Notifications)
+	}
+
+	_, err = Load(writeConfig(t, dir, base+`,
+		"notifications": {
+			"ops": {"type": "webhook", "url": "hooks.example.com"},
+			"pager": {"type": "sms"}
+		},
+		"budget_alerts": [
+			{"name": "half", "ratio": 0.5, "channels": ["ops"]},
+			{"name": "half", "ratio": 0, "channels": ["slack"]}
+		]
+	}`))
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		"notifications.ops.url: want an http or https URL",
+		"notifications.pager.type: must be webhook, log, or email",
+		`budget_alerts[1].name: duplicate alert "half"`,
+		"budget_alerts[1].ratio must be positive",
+		`budget_alerts[1].channels: unknown channel "slack"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_EmailChannel(t *testing.T) {
+	dir := t.TempDir()
+	base := strings.TrimSuffix(strings.TrimSpace(validJSON()), "}")
+	cfg, err := Load(writeConfig(t, dir, base+`,
+		"notifications": {
+			"mail": {
+				"type": "email", "host": "smtp.acme.com", "username": "bot", "password": "${env:SMTP_PASSWORD}",
+				"from": "threebody@acme.com", "to": ["ops@acme.com"],
+				"templates": {"flow_blocked": {"subject": "{{.TaskID}} blocked", "body": "{{.Text}}"}},
+				"batch": ["budget_alert"], "events": ["flow_blocked", "flow_completed"]
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if ch := cfg.Notifications["mail"]; ch.Port != 587 || ch.BatchWindowSec != 300 || ch.Templates["flow_blocked"].Subject != "{{.TaskID}} blocked" {
+		t.Errorf("mail channel = %+v", ch)
+	}
+
+	_, err = Load(writeConfig(t, dir, base+`,
+		"notifications": {
+			"mail": {
+				"type": "email", "port": 70000, "password": "pw", "from": "nobody", "to": ["ops@acme.com", "bad"],
+				"templates": {"flow_failed": {"subject": "{{.TaskID", "body": ""}},
+				"batch_window_sec": -1, "events": ["flow_started"]
+			}
+		}
+	}`))
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		"notifications.mail.host is required",
+		"notifications.mail.port: must be between 1 and 65535",
+		"notifications.mail.from: ",
+		"notifications.mail.to[1]: ",
+		"notifications.mail.username is required with a password",
+		"notifications.mail.templates.flow_failed.subject: ",
+		"notifications.mail.batch_window_sec must not be negative",
+		`notifications.mail.events: unknown event "flow_started"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_Hooks(t *testing.T) {
+	dir := t.TempDir()
+	base := strings.TrimSuffix(strings.TrimSpace(validJSON()), "}")
+	cfg, err := Load(writeConfig(t, dir, base+`,
+		"hooks": [
+			{"name": "change-ticket", "type": "webhook", "url": "https://policy.example.com/hook", "headers": {"Authorization": "${env:POLICY_TOKEN}"}, "points": ["phase_exit"], "phases": ["E"]},
+			{"name": "coverage", "type": "exec", "command": "./ci/coverage.sh", "points": ["phase_exit"], "phases": ["E"], "timeout_sec": 60},
+			{"name": "audit", "type": "plugin", "path": "/opt/hooks/audit.so", "symbol": "Hook", "fail_open": true}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Hooks) != 3 || cfg.Hooks[1].TimeoutSec != 60 || !cfg.Hooks[2].FailOpen || cfg.Hooks[0].Phases[0] != "E" {
+		t.Errorf("Hooks = %+v", cfg.Hooks)
+	}
+
+	_, err = Load(writeConfig(t, dir, base+`,
+		"hooks": [
+			{"name": "a", "type": "webhook", "url": "policy.example.com"},
+			{"name": "a", "type": "exec", "points": ["phase_start"], "phases": ["H"], "timeout_sec": -1},
+			{"name": "b", "type": "plugin", "path": "/opt/hooks/b.so"},
+			{"type": "lambda"}
+		]
+	}`))
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		"hooks[0].url: want an http or https URL",
+		`hooks[1].name: duplicate hook "a"`,
+		"hooks[1].command is required",
+		`hooks[1].points: unknown point "phase_start"`,
+		`hooks[1].phases: unknown phase "H"`,
+		"hooks[1].timeout_sec must not be negative",
+		"hooks[2]: path and symbol are required",
+		"hooks[3].name is required",
+		"hooks[3].type: must be webhook, exec, or plugin",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_ScriptGates(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "exit-e.expr")
+	os.WriteFile(script, []byte("cost.ratio < 0.9 &&\n  state.round < 3"), 0o644)
+	base := strings.TrimSuffix(strings.TrimSpace(validJSON()), "}")
+	cfg, err := Load(writeConfig(t, dir, base+`,
+		"script_gates": {
+			"C": {"expr": "any(events, .eventType == \"decision_recorded\")", "message": "record a design decision first"},
+			"E": {"file": "`+script+`", "events": 200}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if src, err := cfg.ScriptGates["E"].Source(); err != nil || !strings.Contains(src, "state.round < 3") || cfg.ScriptGates["E"].Events != 200 {
+		t.Errorf("ScriptGates[E] = %+v, source %q, %v", cfg.ScriptGates["E"], src, err)
+	}
+
+	_, err = Load(writeConfig(t, dir, base+`,
+		"script_gates": {
+			"A": {"expr": "state.round >"},
+			"B": {},
+			"D": {"file": "`+filepath.Join(dir, "missing.expr")+`"},
+			"G": {"expr": "true", "events": -1}
+		}
+	}`))
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		"script_gates.A: unexpected token",
+		"script_gates.B: exactly one of expr and file is required",
+		"script_gates.D.file: open",
+		`script_gates: unknown phase "G"`,
+		"script_gates.G.events must not be negative",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_CustomEvents(t *testing.T) {
+	dir := t.TempDir()
+	base := strings.TrimSuffix(strings.TrimSpace(validJSON()), "}")
+	cfg, err := Load(writeConfig(t, dir, base+`,
+		"custom_events": {
+			"ci.build": {"fields": {"status": "string", "url": "string"}, "required": ["status"]},
+			"jira.issue_moved": {"version": 3, "fields": {"key": "string", "to": "string"}}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.CustomEvents["ci.build"].Version != 1 || cfg.CustomEvents["jira.issue_moved"].Version != 3 {
+		t.Errorf("CustomEvents = %+v", cfg.CustomEvents)
+	}
+
+	_, err = Load(writeConfig(t, dir, base+`,
+		"custom_events": {
+			"build": {},
+			"ci.build": {"version": -1, "fields": {"status": "text"}, "required": ["url"]}
+		}
+	}`))
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		`custom_events: "build" is not a namespaced type`,
+		"custom_events.ci.build.version must be positive",
+		`custom_events.ci.build.fields.status: unknown type "text"`,
+		`custom_events.ci.build.required: "url" is not a declared field`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_CIGate(t *testing.T) {
+	dir := t.TempDir()
+	base := strings.TrimSuffix(strings.TrimSpace(validJSON()), "}")
+	cfg, err := Load(writeConfig(t, dir, base+`,
+		"ci_gate": {"provider": "github", "repo": "acme/app", "required_checks": ["build", "lint"]}
+	}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.CIGate.TokenEnv != "GITHUB_TOKEN" || cfg.CIGate.PollIntervalSec != 60 || len(cfg.CIGate.RequiredChecks) != 2 {
+		t.Errorf("CIGate = %+v", cfg.CIGate)
+	}
+
+	for body, want := range map[string]string{
+		`{"provider": "github", "poll_interval_sec": -1}`:          "ci_gate.repo is required",
+		`{"provider": "webhook"}`:                                  "ci_gate.webhook_secret is required",
+		`{"provider": "webhook", "webhook_secret": "${vault:ci}"}`: `ci_gate.webhook_secret: unknown secret backend "vault"`,
+		`{"provider": "jenkins"}`:                                  `ci_gate.provider: must be github or webhook, got "jenkins"`,
+	} {
+		_, err := Load(writeConfig(t, dir, base+`, "ci_gate": `+body+`}`))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ci_gate %s: error = %v, want %q", body, err, want)
+		}
+	}
+}
+
+func TestLoad_IssueSync(t *testing.T) {
+	dir := t.TempDir()
+	base := strings.TrimSuffix(strings.TrimSpace(validJSON()), "}")
+	cfg, err := Load(writeConfig(t, dir, base+`,
+		"issue_sync": {"provider": "jira", "url": "https://acme.atlassian.net", "email": "bot@acme.com", "project": "ENG", "namespace_projects": {"payments": "PAY"}}
+	}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	is := cfg.IssueSync
+	if is.TokenEnv != "JIRA_API_TOKEN" || is.IssueType != "Task" || is.DoneState != "Done" || is.IntervalSec != 10 || is.RetryMaxSec != 3600 || is.NamespaceProjects["payments"] != "PAY" {
+		t.Errorf("IssueSync = %+v", is)
+	}
+
+	for body, want := range map[string]string{
+		`{"provider": "jira", "project": "ENG"}`:     `issue_sync.url: want the Jira site's http or https URL, got ""`,
+		`{"provider": "linear"}`:                     "issue_sync.done_state is required for linear",
+		`{"provider": "linear", "done_state": "s1"}`: "issue_sync.project is required",
+		`{"provider": "linear", "done_state": "s1", "project": "t", "namespace_projects": {"Bad NS": "x"}}`: `issue_sync.namespace_projects: invalid namespace "Bad NS"`,
+		`{"provider": "linear", "done_state": "s1", "project": "t", "max_attempts": -1}`:                    "max_attempts must not be negative",
+		`{"provider": "github"}`: `issue_sync.provider: must be jira or linear, got "github"`,
+	} {
+		_, err := Load(writeConfig(t, dir, base+`, "issue_sync": `+body+`}`))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("issue_sync %s: error = %v, want %q", body, err, want)
+		}
+	}
+}
+
+func TestLoad_Approvals(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"approvals": {"phases": ["F"]}
+	}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.Approvals.Phases, []string{"F"}) {
+		t.Errorf("Approvals.Phases = %v", cfg.Approvals.Phases)
+	}
+
+	path = writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"approvals": {"phases": ["G", "Z"]}
+	}`)
+	_, err = Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{`approvals.phases: "G"`, `approvals.phases: "Z"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_Namespaces(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"namespaces": {"team-a": {"budget_usd": 100, "providers": {"claude": {"command": "claude", "env": {"ANTHROPIC_API_KEY": "${env:TEAM_A_KEY}"}}}}}
+	}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	ns := cfg.Namespaces["team-a"]
+	if ns.BudgetUSD != 100 || ns.Providers["claude"].Env["ANTHROPIC_API_KEY"] != "${env:TEAM_A_KEY}" {
+		t.Errorf("Namespaces = %+v", cfg.Namespaces)
+	}
+
+	path = writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"namespaces": {
+			"Team A": {},
+			"team-b": {"budget_usd": -1, "providers": {"codex": {"command": "codex"}, "claude": {}}}
+		}
+	}`)
+	_, err = Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		`namespaces: "Team A" is not a valid namespace name`,
+		"namespaces.team-b.budget_usd must not be negative",
+		`namespaces.team-b.providers: unknown provider "codex"`,
+		"namespaces.team-b.providers.claude.command is required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoad_PhaseSLA(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"phase_sla_sec": {"B": 3600, "G": 60, "X": 60, "C": 0}
+	}`)
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		`phase_sla_sec: "G" is not a phase a flow can leave`,
+		`phase_sla_sec: "X" is not a phase a flow can leave`,
+		"phase_sla_sec.C must be positive",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "phase_sla_sec.B") {
+		t.Errorf("error %q rejects a valid SLA", err)
+	}
+}
+
+func TestLoad_HTTP(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"http": {"request_timeout_sec": 10, "route_timeouts_sec": {"GET /api/v1/flow/{taskID}/report": 120}}
+	}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.HTTP.RequestTimeoutSec != 10 || cfg.HTTP.ReadTimeoutSec != 60 || cfg.HTTP.IdleTimeoutSec != 120 || cfg.HTTP.MaxBodyBytes != 32<<20 {
+		t.Errorf("HTTP = %+v, want a 10s request timeout and default limits", cfg.HTTP)
+	}
+
+	path = writeConfig(t, dir, `{
+		"db_path": "/tmp/test.db",
+		"workspace": "/tmp/ws",
+		"budget_cap_usd": 5.0,
+		"providers": {"claude": {"command": "claude"}},
+		"http": {"max_body_bytes": -1, "route_timeouts_sec": {"GET /api/v1/health": -5}}
+	}`)
+	_, err = Load(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"http: timeouts and max_body_bytes", "http.route_timeouts_sec.GET /api/v1/health"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
 }
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Aliases maps short override names to the config keys they set. An alias is
+// applied before its full key, so the full key wins when both are given.
+var Aliases = map[string]string{
+	"max_workers": "max_concurrent_workers",
+	"budget_cap":  "budget_cap_usd",
+}
+
+// Keys returns the dotted JSON keys of every scalar config field. These are the
+// fields that can be overridden from the environment or the command line.
+func Keys() []string {
+	var keys []string
+	walkScalars(reflect.ValueOf(&Config{}).Elem(), "", func(key string, _ reflect.Value) {
+		keys = append(keys, key)
+	})
+	return keys
+}
+
+// EnvName returns the environment variable that overrides key, for example
+// TB_DB_PATH for db_path and TB_BACKUP_KEEP for backup.keep.
+func EnvName(key string) string {
+	return "TB_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// FlagName returns the command-line flag that overrides key, for example
+// db-path for db_path and backup-keep for backup.keep.
+func FlagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// EnvOverrides collects the overrides set in environ, a list of KEY=value
+// pairs as returned by os.Environ.
+func EnvOverrides(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+
+	overrides := make(map[string]string)
+	for alias, key := range Aliases {
+		if v, ok := env[EnvName(alias)]; ok {
+			overrides[key] = v
+		}
+	}
+	for _, key := range Keys() {
+		if v, ok := env[EnvName(key)]; ok {
+			overrides[key] = v
+		}
+	}
+	return overrides
+}
+
+// Override sets the fields named by the keys of overrides, parsing each value
+// for the field's type. Keys may be full config keys or aliases. All problems
+// are reported together.
+func (c *Config) Override(overrides map[string]string) error {
+	if problems := c.override(overrides); len(problems) > 0 {
+		return invalid(problems)
+	}
+	return nil
+}
+
+func (c *Config) override(overrides map[string]string) []string {
+	fields := make(map[string]reflect.Value)
+	walkScalars(reflect.ValueOf(c).Elem(), "", func(key string, v reflect.Value) {
+		fields[key] = v
+	})
+
+	// Aliases sort first so a full key given alongside its alias wins.
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		_, ai := Aliases[names[i]]
+		_, aj := Aliases[names[j]]
+		if ai != aj {
+			return ai
+		}
+		return names[i] < names[j]
+	})
+
+	var problems []string
+	for _, name := range names {
+		key := name
+		if full, ok := Aliases[name]; ok {
+			key = full
+		}
+		f, ok := fields[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown config key", name))
+			continue
+		}
+		if err := setScalar(f, overrides[name]); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	return problems
+}
+
+// walkScalars calls fn for every string, int, float, and bool field of v,
+// descending into nested structs. Maps and slices are skipped.
+func walkScalars(v reflect.Value, prefix string, fn func(key string, field reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := prefix + strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Struct:
+			walkScalars(f, key+".", fn)
+		case reflect.String, reflect.Int, reflect.Float64, reflect.Bool:
+			fn(key, f)
+		}
+	}
+}
+
+func setScalar(f reflect.Value, raw string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		f.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		f.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		f.SetBool(b)
+	}
+	return nil
+}
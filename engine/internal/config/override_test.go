@@ -0,0 +1,73 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvOverrides(t *testing.T) {
+	got := EnvOverrides([]string{
+		"TB_DB_PATH=/data/tb.db",
+		"TB_MAX_WORKERS=9",
+		"TB_BACKUP_KEEP=2",
+		"TB_CONFIG=/etc/tb.json",
+		"HOME=/root",
+	})
+	want := map[string]string{
+		"db_path":                "/data/tb.db",
+		"max_concurrent_workers": "9",
+		"backup.keep":            "2",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("EnvOverrides = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestLoadWithOverrides(t *testing.T) {
+	path := writeConfig(t, t.TempDir(), validJSON())
+
+	cfg, err := LoadWithOverrides(path, map[string]string{
+		"listen_addr":            ":9999",
+		"budget_cap":             "1",
+		"budget_cap_usd":         "25.5",
+		"preempt_flows":          "true",
+		"retention.interval_sec": "60",
+	})
+	if err != nil {
+		t.Fatalf("LoadWithOverrides: %v", err)
+	}
+	if cfg.ListenAddr != ":9999" {
+		t.Errorf("ListenAddr = %q, want :9999", cfg.ListenAddr)
+	}
+	if cfg.BudgetCapUSD != 25.5 {
+		t.Errorf("BudgetCapUSD = %v, want the full key to beat its alias", cfg.BudgetCapUSD)
+	}
+	if !cfg.PreemptFlows || cfg.Retention.IntervalSec != 60 {
+		t.Errorf("PreemptFlows = %v, Retention.IntervalSec = %d", cfg.PreemptFlows, cfg.Retention.IntervalSec)
+	}
+	if cfg.DBPath != "/tmp/test.db" {
+		t.Errorf("DBPath = %q, want the file value", cfg.DBPath)
+	}
+}
+
+func TestLoadWithOverrides_ReportsAllProblems(t *testing.T) {
+	path := writeConfig(t, t.TempDir(), validJSON())
+
+	_, err := LoadWithOverrides(path, map[string]string{
+		"max_rounds":  "many",
+		"no_such_key": "1",
+	})
+	if err == nil {
+		t.Fatal("expected error for bad overrides")
+	}
+	for _, want := range []string{"max_rounds: invalid integer", "no_such_key: unknown config key"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// decode parses a config file into a generic JSON document. The format is
+// chosen by extension: .yaml/.yml and .toml are converted to their JSON
+// equivalent, anything else is parsed as JSON.
+func decode(path string, data []byte) (map[string]interface{}, error) {
+	var doc interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse config YAML: %w", err)
+		}
+	case ".toml":
+		var m map[string]interface{}
+		if _, err := toml.Decode(string(data), &m); err != nil {
+			return nil, fmt.Errorf("parse config TOML: %w", err)
+		}
+		doc = m
+	default:
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse config JSON: %w", err)
+		}
+		data = nil
+	}
+
+	// Round-trip YAML and TOML through JSON so all formats share one set of
+	// value types and the json struct tags.
+	if data != nil {
+		normalized, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("convert config to JSON: %w", err)
+		}
+		doc = nil
+		if err := json.Unmarshal(normalized, &doc); err != nil {
+			return nil, fmt.Errorf("convert config to JSON: %w", err)
+		}
+	}
+
+	if doc == nil {
+		return map[string]interface{}{}, nil
+	}
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("parse config: top level must be an object")
+	}
+	return m, nil
+}
+
+// checkSchema compares a decoded document against the Config struct and
+// returns every unknown key and mistyped value, sorted by key path.
+func checkSchema(doc map[string]interface{}) []string {
+	var problems []string
+	checkValue(reflect.TypeOf(Config{}), doc, "", &problems)
+	return problems
+}
+
+func checkValue(t reflect.Type, v interface{}, path string, problems *[]string) {
+	if v == nil {
+		return
+	}
+	fail := func(want string) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, want, jsonType(v)))
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			fail("object")
+			return
+		}
+		fields := make(map[string]reflect.Type, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			fields[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = t.Field(i).Type
+		}
+		for _, key := range sortedKeys(m) {
+			ft, ok := fields[key]
+			if !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: unknown key", join(path, key)))
+				continue
+			}
+			checkValue(ft, m[key], join(path, key), problems)
+		}
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			fail("object")
+			return
+		}
+		for _, key := range sortedKeys(m) {
+			checkValue(t.Elem(), m[key], join(path, key), problems)
+		}
+	case reflect.Slice:
+		items, ok := v.([]interface{})
+		if !ok {
+			fail("array")
+			return
+		}
+		for i, item := range items {
+			checkValue(t.Elem(), item, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			fail("string")
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			fail("boolean")
+		}
+	case reflect.Int:
+		if n, ok := v.(float64); !ok || n != math.Trunc(n) {
+			fail("integer")
+		}
+	case reflect.Float64:
+		if _, ok := v.(float64); !ok {
+			fail("number")
+		}
+	}
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
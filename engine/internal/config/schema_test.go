@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeNamed(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return p
+}
+
+func TestLoad_YAML(t *testing.T) {
+	path := writeNamed(t, "config.yaml", `
+db_path: /tmp/test.db
+workspace: /tmp/workspace
+budget_cap_usd: 10
+max_rounds: 4
+providers:
+  claude:
+    command: claude
+    args: ["--print"]
+phases:
+  B:
+    - role: explorer
+      provider: claude
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.BudgetCapUSD != 10 || cfg.MaxRounds != 4 {
+		t.Errorf("BudgetCapUSD = %v, MaxRounds = %d", cfg.BudgetCapUSD, cfg.MaxRounds)
+	}
+	if got := cfg.Phases["B"]; len(got) != 1 || got[0].Count != 1 {
+		t.Errorf("Phases[B] = %+v, want one defaulted group", got)
+	}
+}
+
+func TestLoad_TOML(t *testing.T) {
+	path := writeNamed(t, "config.toml", `
+db_path = "/tmp/test.db"
+workspace = "/tmp/workspace"
+budget_cap_usd = 10.0
+
+[providers.claude]
+command = "claude"
+args = ["--print"]
+
+[[phases.B]]
+role = "explorer"
+provider = "claude"
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Providers["claude"].Command != "claude" {
+		t.Errorf("Providers = %+v", cfg.Providers)
+	}
+	if len(cfg.Phases["B"]) != 1 {
+		t.Errorf("Phases[B] = %+v, want one group", cfg.Phases["B"])
+	}
+}
+
+func TestCheck_ReportsAllProblems(t *testing.T) {
+	path := writeNamed(t, "config.yaml", `
+db_path: /tmp/test.db
+budget_cap_usd: "ten"
+max_rounds: 2.5
+listen_adr: ":1"
+providers:
+  claude:
+    command: claude
+    args: "--print"
+`)
+	problems, err := Check(path, nil)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	want := []string{
+		"budget_cap_usd: expected number, got string",
+		"listen_adr: unknown key",
+		"max_rounds: expected integer, got number",
+		"providers.claude.args: expected array, got string",
+		"workspace is required",
+	}
+	all := strings.Join(problems, "\n")
+	for _, w := range want {
+		if !strings.Contains(all, w) {
+			t.Errorf("problems missing %q:\n%s", w, all)
+		}
+	}
+}
+
+func TestLoad_MalformedYAML(t *testing.T) {
+	path := writeNamed(t, "config.yml", "db_path: [unclosed\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "parse config YAML") {
+		t.Fatalf("err = %v, want YAML parse error", err)
+	}
+}
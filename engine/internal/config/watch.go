@@ -0,0 +1,134 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Reloadable lists the top-level keys that may change while the engine runs.
+// Changes to any other key are reported but only take effect after a restart.
+var Reloadable = map[string]bool{
+	"providers":               true,
+	"rate_limit_per_minute":   true,
+	"rate_limit_burst":        true,
+	"rate_limits":             true,
+	"max_rounds":              true,
+	"budget_warn_ratio":       true,
+	"budget_halt_ratio":       true,
+	"budget_alerts":           true,
+	"max_concurrent_workers":  true,
+	"worker_pool_size":        true,
+	"reserved_priority_slots": true,
+	"worker_role_limits":      true,
+	"phases":                  true,
+}
+
+// Changed returns the JSON keys of top-level fields that differ between two
+// configs, in sorted order.
+func Changed(prev, next *Config) []string {
+	pv := reflect.ValueOf(prev).Elem()
+	nv := reflect.ValueOf(next).Elem()
+	t := pv.Type()
+
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		if reflect.DeepEqual(pv.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+		key := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Watcher reloads the config file when it changes on disk or the process
+// receives SIGHUP. A file that fails to load or validate is reported through
+// OnError and leaves the running configuration untouched.
+type Watcher struct {
+	Path     string
+	Interval time.Duration
+	OnReload func(*Config)
+	OnError  func(error)
+	// Overrides are re-applied on every reload so environment and flag
+	// settings keep precedence over the file.
+	Overrides map[string]string
+
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher creates a Watcher polling path every interval.
+func NewWatcher(path string, interval time.Duration, onReload func(*Config)) *Watcher {
+	w := &Watcher{Path: path, Interval: interval, OnReload: onReload}
+	w.changed()
+	return w
+}
+
+// Start watches until ctx is cancelled.
+func (w *Watcher) Start(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if w.Interval > 0 {
+		ticker := time.NewTicker(w.Interval)
+		tick = ticker.C
+		go func() {
+			<-ctx.Done()
+			ticker.Stop()
+		}()
+	}
+
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				w.changed()
+				w.Reload()
+			case <-tick:
+				if w.changed() {
+					w.Reload()
+				}
+			}
+		}
+	}()
+}
+
+// Reload loads and validates the file, then hands it to OnReload.
+func (w *Watcher) Reload() error {
+	cfg, err := LoadWithOverrides(w.Path, w.Overrides)
+	if err != nil {
+		if w.OnError != nil {
+			w.OnError(err)
+		}
+		return err
+	}
+	if w.OnReload != nil {
+		w.OnReload(cfg)
+	}
+	return nil
+}
+
+// changed records the file's current modification time and size and reports
+// whether either differs from the last observation.
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.Path)
+	if err != nil {
+		return false
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return false
+	}
+	w.modTime, w.size = info.ModTime(), info.Size()
+	return true
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChanged(t *testing.T) {
+	dir := t.TempDir()
+	prev, err := Load(writeConfig(t, dir, validJSON()))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	next := *prev
+	next.RateLimitPerMinute = 5
+	next.ListenAddr = ":1"
+
+	got := Changed(prev, &next)
+	if len(got) != 2 || got[0] != "listen_addr" || got[1] != "rate_limit_per_minute" {
+		t.Fatalf("Changed = %v, want [listen_addr rate_limit_per_minute]", got)
+	}
+	if !Reloadable["rate_limit_per_minute"] || Reloadable["listen_addr"] {
+		t.Error("rate_limit_per_minute should reload and listen_addr should not")
+	}
+}
+
+func TestWatcher_ReloadsOnFileChange(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, validJSON())
+
+	reloaded := make(chan *Config, 1)
+	w := NewWatcher(path, 10*time.Millisecond, func(c *Config) { reloaded <- c })
+	failed := make(chan error, 1)
+	w.OnError = func(err error) { failed <- err }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w.Start(ctx)
+
+	updated := strings.Replace(validJSON(), `"budget_cap_usd": 10.0,`, `"budget_cap_usd": 10.0, "rate_limit_per_minute": 7,`, 1)
+	writeLater(t, path, updated)
+
+	select {
+	case c := <-reloaded:
+		if c.RateLimitPerMinute != 7 {
+			t.Errorf("RateLimitPerMinute = %d, want 7", c.RateLimitPerMinute)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not reload the changed file")
+	}
+
+	writeLater(t, path, `{"db_path": ""}`)
+	select {
+	case err := <-failed:
+		if !strings.Contains(err.Error(), "db_path is required") {
+			t.Errorf("error = %v, want validation failure", err)
+		}
+	case <-reloaded:
+		t.Fatal("invalid config was applied")
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not report the invalid file")
+	}
+}
+
+// writeLater atomically replaces path with a modification time distinct from
+// the last write, so coarse filesystem timestamps still register a change.
+func writeLater(t *testing.T, path, content string) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(tmp, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+}
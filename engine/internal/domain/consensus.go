@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+)
+
+// Problems lists what is wrong with s: weights must not be negative and must
+// sum to 1 (within 0.01), and set thresholds must lie within the 1-5 score
+// range with conditional_threshold no higher than pass_threshold.
+func (s ConsensusSettings) Problems() []string {
+	var problems []string
+	if len(s.Weights) > 0 {
+		var sum float64
+		for role, w := range s.Weights {
+			if w < 0 {
+				problems = append(problems, fmt.Sprintf("weights.%s must not be negative", role))
+			}
+			sum += w
+		}
+		if math.Abs(sum-1) > 0.01 {
+			problems = append(problems, fmt.Sprintf("weights must sum to 1, got %.2f", sum))
+		}
+	}
+	if s.PassThreshold != 0 && (s.PassThreshold < 1 || s.PassThreshold > 5) {
+		problems = append(problems, "pass_threshold must be between 1 and 5")
+	}
+	if s.ConditionalThreshold != 0 && (s.ConditionalThreshold < 1 || s.ConditionalThreshold > 5) {
+		problems = append(problems, "conditional_threshold must be between 1 and 5")
+	}
+	if s.PassThreshold != 0 && s.ConditionalThreshold > s.PassThreshold {
+		problems = append(problems, "conditional_threshold must not exceed pass_threshold")
+	}
+	return problems
+}
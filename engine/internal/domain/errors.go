@@ -27,16 +27,27 @@ func WrapEngineError(code int, msg string, cause error) *EngineError {
 // ---- Engine / FSM / Gate errors (-32010 to -32039) ----
 
 var (
-	ErrInvalidTransition = &EngineError{Code: -32010, Message: "invalid phase transition"}
-	ErrPhaseGateFailed   = &EngineError{Code: -32011, Message: "phase gate evaluation failed"}
-	ErrFlowNotFound      = &EngineError{Code: -32012, Message: "workflow not found"}
-	ErrFlowAlreadyDone   = &EngineError{Code: -32013, Message: "workflow already completed"}
-	ErrFlowBlocked       = &EngineError{Code: -32014, Message: "workflow is blocked"}
-	ErrOptimisticLock    = &EngineError{Code: -32015, Message: "optimistic lock conflict: state was modified concurrently"}
-	ErrInvalidPhase      = &EngineError{Code: -32016, Message: "invalid phase value"}
-	ErrGateNotRegistered = &EngineError{Code: -32017, Message: "no gate registered for phase"}
-	ErrFSMNotStarted     = &EngineError{Code: -32018, Message: "workflow has not been started"}
-	ErrDuplicateTask     = &EngineError{Code: -32019, Message: "task already exists"}
+	ErrInvalidTransition    = &EngineError{Code: -32010, Message: "invalid phase transition"}
+	ErrPhaseGateFailed      = &EngineError{Code: -32011, Message: "phase gate evaluation failed"}
+	ErrFlowNotFound         = &EngineError{Code: -32012, Message: "workflow not found"}
+	ErrFlowAlreadyDone      = &EngineError{Code: -32013, Message: "workflow already completed"}
+	ErrFlowBlocked          = &EngineError{Code: -32014, Message: "workflow is blocked"}
+	ErrOptimisticLock       = &EngineError{Code: -32015, Message: "optimistic lock conflict: state was modified concurrently"}
+	ErrInvalidPhase         = &EngineError{Code: -32016, Message: "invalid phase value"}
+	ErrGateNotRegistered    = &EngineError{Code: -32017, Message: "no gate registered for phase"}
+	ErrFSMNotStarted        = &EngineError{Code: -32018, Message: "workflow has not been started"}
+	ErrDuplicateTask        = &EngineError{Code: -32019, Message: "task already exists"}
+	ErrInvalidChildFlow     = &EngineError{Code: -32020, Message: "invalid child flow"}
+	ErrTransitionSuperseded = &EngineError{Code: -32021, Message: "transition superseded by a concurrent change"}
+	ErrInvalidBudget        = &EngineError{Code: -32022, Message: "invalid budget cap"}
+	ErrApprovalInvalid      = &EngineError{Code: -32023, Message: "invalid approval"}
+	ErrCloneInvalid         = &EngineError{Code: -32024, Message: "invalid flow clone"}
+	ErrFailureInvalid       = &EngineError{Code: -32025, Message: "invalid failure reason"}
+	ErrPostMortemNotFound   = &EngineError{Code: -32026, Message: "flow has no post-mortem"}
+	ErrEventInvalid         = &EngineError{Code: -32027, Message: "invalid workflow event"}
+	ErrFlowActive           = &EngineError{Code: -32028, Message: "workflow is still active"}
+	ErrWorkspaceInUse       = &EngineError{Code: -32029, Message: "workspace is held by another flow"}
+	ErrFlowNotRunning       = &EngineError{Code: -32030, Message: "workflow is not running"}
 )
 
 // ---- Worker / Supervisor / Intent errors (-32040 to -32069) ----
@@ -53,6 +64,17 @@ var (
 	ErrIntentHashMismatch  = &EngineError{Code: -32048, Message: "intent pre-hash does not match current file"}
 	ErrCompactionInvalid   = &EngineError{Code: -32049, Message: "compaction slots validation failed"}
 	ErrWorkerAlreadyDone   = &EngineError{Code: -32050, Message: "worker is already in terminal state"}
+	ErrIntentNotActive     = &EngineError{Code: -32051, Message: "intent is not active"}
+	ErrIntentQueued        = &EngineError{Code: -32052, Message: "intent queued behind an active intent"}
+	ErrConflictEscalated   = &EngineError{Code: -32053, Message: "conflict escalated for a human decision"}
+	ErrDecisionNotFound    = &EngineError{Code: -32054, Message: "decision not found"}
+	ErrDecisionResolved    = &EngineError{Code: -32055, Message: "decision already resolved"}
+	ErrDecisionInvalid     = &EngineError{Code: -32056, Message: "invalid decision choice"}
+	ErrCrossTaskConflict   = &EngineError{Code: -32057, Message: "file locked by another task in the same workspace"}
+	ErrArtifactNotFound    = &EngineError{Code: -32058, Message: "artifact not found"}
+	ErrRiskNotFound        = &EngineError{Code: -32059, Message: "risk not found"}
+	ErrConstraintNotFound  = &EngineError{Code: -32060, Message: "constraint not found"}
+	ErrFileNotFound        = &EngineError{Code: -32061, Message: "file not found"}
 )
 
 // ---- MCP / Bridge errors (-32070 to -32099) ----
@@ -64,6 +86,10 @@ var (
 	ErrBridgeNotReady      = &EngineError{Code: -32073, Message: "bridge is not ready"}
 	ErrSessionNotFound     = &EngineError{Code: -32074, Message: "code agent session not found"}
 	ErrProviderUnavailable = &EngineError{Code: -32075, Message: "code agent provider unavailable"}
+	ErrDeadLetterNotFound  = &EngineError{Code: -32076, Message: "dead letter event not found"}
+	ErrDeadLetterInvalid   = &EngineError{Code: -32077, Message: "dead letter event still cannot be parsed"}
+	ErrProviderInvalid     = &EngineError{Code: -32078, Message: "invalid provider registration"}
+	ErrProviderNotFound    = &EngineError{Code: -32079, Message: "provider not registered at runtime"}
 )
 
 // ---- Guard / Permission errors (-32100 to -32129) ----
@@ -82,6 +108,8 @@ var (
 var (
 	ErrScoreCardInvalid = &EngineError{Code: -32160, Message: "score card validation failed"}
 	ErrConsensusNoCards = &EngineError{Code: -32161, Message: "consensus requires at least one score card"}
+	ErrIssueNotFound    = &EngineError{Code: -32162, Message: "review issue not found"}
+	ErrIssueTransition  = &EngineError{Code: -32163, Message: "invalid review issue status change"}
 )
 
 // ---- Store / Recovery / Config errors (-32130 to -32159) ----
@@ -95,4 +123,7 @@ var (
 	ErrRecoveryFailed  = &EngineError{Code: -32135, Message: "recovery from snapshot failed"}
 	ErrConfigInvalid   = &EngineError{Code: -32136, Message: "invalid configuration"}
 	ErrDuplicateEvent  = &EngineError{Code: -32137, Message: "duplicate event sequence number"}
+	ErrBundleInvalid   = &EngineError{Code: -32138, Message: "invalid task bundle"}
+	ErrGitDisabled     = &EngineError{Code: -32139, Message: "git integration is not enabled"}
+	ErrSealKeyMissing  = &EngineError{Code: -32140, Message: "payload is encrypted with a key that is not configured"}
 )
@@ -0,0 +1,327 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EventType names the kind of a WorkflowEvent.
+type EventType string
+
+const (
+	EventFlowStarted        EventType = "flow_started"
+	EventFlowQueued         EventType = "flow_queued"
+	EventFlowPreempted      EventType = "flow_preempted"
+	EventFlowResumed        EventType = "flow_resumed"
+	EventFlowBlocked        EventType = "flow_blocked"
+	EventFlowUnblocked      EventType = "flow_unblocked"
+	EventFlowFailed         EventType = "flow_failed"
+	EventFlowCloned         EventType = "flow_cloned"
+	EventCloneCreated       EventType = "clone_created"
+	EventChildSpawned       EventType = "child_spawned"
+	EventBudgetChanged      EventType = "budget_changed"
+	EventPhaseTransition    EventType = "phase_transition"
+	EventPhaseSLAExceeded   EventType = "phase_sla_exceeded"
+	EventAutoAdvanced       EventType = "auto_advanced"
+	EventIntentGranted      EventType = "intent_granted"
+	EventIntentExpired      EventType = "intent_expired"
+	EventIntentQueueTimeout EventType = "intent_queue_timeout"
+	EventPullRequestOpened  EventType = "pull_request_opened"
+	EventReportGenerated    EventType = "report_generated"
+	EventEngineShutdown     EventType = "engine_shutdown"
+)
+
+// ExperimentalEventPrefix marks an event type as experimental. Experimental
+// events may be appended without a registered schema; their payload need
+// only be JSON, and consumers should not rely on its shape.
+const ExperimentalEventPrefix = "x_"
+
+// Experimental reports whether t is an experimental event type.
+func (t EventType) Experimental() bool {
+	return strings.HasPrefix(string(t), ExperimentalEventPrefix) && len(t) > len(ExperimentalEventPrefix)
+}
+
+// customEventPattern matches custom event types: a namespace and a name
+// joined by a dot, such as "ci.build".
+var customEventPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}\.[a-z][a-z0-9_.-]{0,126}$`)
+
+// Custom reports whether t is a custom event type, reported by an external
+// system rather than the engine.
+func (t EventType) Custom() bool {
+	return customEventPattern.MatchString(string(t))
+}
+
+// CustomEventFieldTypes are the JSON types a custom event field may have.
+var CustomEventFieldTypes = map[string]bool{
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"boolean": true,
+	"object":  true,
+	"array":   true,
+}
+
+// CustomEventSchema describes the payload of one custom event type: a JSON
+// object whose Fields have the given JSON types, with Required among them.
+// Fields not declared are rejected; a declared field may be null.
+type CustomEventSchema struct {
+	Version  int               `json:"version"`
+	Fields   map[string]string `json:"fields"`
+	Required []string          `json:"required"`
+}
+
+// CustomEventSchemas lists the custom event types that may be appended,
+// set from the configuration at startup.
+var CustomEventSchemas = map[EventType]CustomEventSchema{}
+
+// validate checks payloadJSON against s.
+func (s CustomEventSchema) validate(t EventType, payloadJSON string) error {
+	dec := json.NewDecoder(strings.NewReader(payloadJSON))
+	dec.UseNumber()
+	var payload map[string]interface{}
+	if err := dec.Decode(&payload); err != nil || payload == nil {
+		return NewEngineError(ErrEventInvalid.Code, fmt.Sprintf("%s payload must be a JSON object", t))
+	}
+	if dec.More() {
+		return NewEngineError(ErrEventInvalid.Code, fmt.Sprintf("%s payload has trailing data", t))
+	}
+	for _, name := range s.Required {
+		if _, ok := payload[name]; !ok {
+			return NewEngineError(ErrEventInvalid.Code, fmt.Sprintf("%s payload: %s is required", t, name))
+		}
+	}
+	names := make([]string, 0, len(payload))
+	for name := range payload {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		want, ok := s.Fields[name]
+		if !ok {
+			return NewEngineError(ErrEventInvalid.Code, fmt.Sprintf("%s payload: unknown field %q", t, name))
+		}
+		if v := payload[name]; v != nil && !hasJSONType(v, want) {
+			return NewEngineError(ErrEventInvalid.Code, fmt.Sprintf("%s payload: %s must be of type %s", t, name, want))
+		}
+	}
+	return nil
+}
+
+// hasJSONType reports whether a value decoded with UseNumber has the JSON
+// type typ.
+func hasJSONType(v interface{}, typ string) bool {
+	switch v := v.(type) {
+	case string:
+		return typ == "string"
+	case json.Number:
+		if typ == "integer" {
+			_, err := v.Int64()
+			return err == nil
+		}
+		return typ == "number"
+	case bool:
+		return typ == "boolean"
+	case map[string]interface{}:
+		return typ == "object"
+	case []interface{}:
+		return typ == "array"
+	}
+	return false
+}
+
+// EventSchema describes the payload of one event type. Version is recorded
+// with every event of the type; when a payload changes incompatibly the
+// version is bumped, so consumers can tell old events from new ones.
+type EventSchema struct {
+	Version int
+	// New returns a pointer to an empty payload.
+	New func() interface{}
+}
+
+// EventSchemas lists the payload schema of every event type that is not
+// experimental.
+var EventSchemas = map[EventType]EventSchema{
+	EventFlowStarted:        {1, func() interface{} { return &FlowStartedPayload{} }},
+	EventFlowQueued:         {1, func() interface{} { return &FlowStartedPayload{} }},
+	EventFlowPreempted:      {1, func() interface{} { return &FlowPreemptedPayload{} }},
+	EventFlowResumed:        {1, func() interface{} { return &struct{}{} }},
+	EventFlowBlocked:        {1, func() interface{} { return &FlowBlockedPayload{} }},
+	EventFlowUnblocked:      {1, func() interface{} { return &FlowUnblockedPayload{} }},
+	EventFlowFailed:         {1, func() interface{} { return &PostMortem{} }},
+	EventFlowCloned:         {1, func() interface{} { return &FlowClonedPayload{} }},
+	EventCloneCreated:       {1, func() interface{} { return &CloneCreatedPayload{} }},
+	EventChildSpawned:       {1, func() interface{} { return &ChildSpawnedPayload{} }},
+	EventBudgetChanged:      {1, func() interface{} { return &BudgetChangedPayload{} }},
+	EventPhaseTransition:    {1, func() interface{} { return &PhaseTransitionPayload{} }},
+	EventPhaseSLAExceeded:   {1, func() interface{} { return &PhaseSLAExceededPayload{} }},
+	EventAutoAdvanced:       {1, func() interface{} { return &AutoAdvancedPayload{} }},
+	EventIntentGranted:      {1, func() interface{} { return &IntentEventPayload{} }},
+	EventIntentExpired:      {1, func() interface{} { return &IntentEventPayload{} }},
+	EventIntentQueueTimeout: {1, func() interface{} { return &IntentEventPayload{} }},
+	EventPullRequestOpened:  {1, func() interface{} { return &PullRequestOpenedPayload{} }},
+	EventReportGenerated:    {1, func() interface{} { return &ReportGeneratedPayload{} }},
+	EventEngineShutdown:     {1, func() interface{} { return &EngineShutdownPayload{} }},
+}
+
+// ValidateEvent checks payloadJSON against the schema of t and returns the
+// schema version to record with the event: 0 for experimental types. The
+// payload must be a JSON object with no fields the schema does not define.
+// Unknown, non-experimental types are rejected.
+func ValidateEvent(t EventType, payloadJSON string) (int, error) {
+	if t.Experimental() {
+		if !json.Valid([]byte(payloadJSON)) {
+			return 0, NewEngineError(ErrEventInvalid.Code,
+				fmt.Sprintf("%s payload is not JSON", t))
+		}
+		return 0, nil
+	}
+	if t.Custom() {
+		schema, ok := CustomEventSchemas[t]
+		if !ok {
+			return 0, NewEngineError(ErrEventInvalid.Code,
+				fmt.Sprintf("custom event type %q is not configured", t))
+		}
+		if err := schema.validate(t, payloadJSON); err != nil {
+			return 0, err
+		}
+		return schema.Version, nil
+	}
+	schema, ok := EventSchemas[t]
+	if !ok {
+		return 0, NewEngineError(ErrEventInvalid.Code,
+			fmt.Sprintf("unknown event type %q; experimental types start with %q", t, ExperimentalEventPrefix))
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(payloadJSON)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(schema.New()); err != nil {
+		return 0, WrapEngineError(ErrEventInvalid.Code, fmt.Sprintf("%s payload", t), err)
+	}
+	if dec.More() {
+		return 0, NewEngineError(ErrEventInvalid.Code,
+			fmt.Sprintf("%s payload has trailing data", t))
+	}
+	return schema.Version, nil
+}
+
+// FlowStartedPayload is the payload of flow_started and flow_queued.
+type FlowStartedPayload struct {
+	// Parent is set when the flow was spawned as a child flow.
+	Parent string `json:"parent,omitempty"`
+	// ShareWorkspace is set when the flow may start in a workspace another
+	// flow holds.
+	ShareWorkspace bool `json:"shareWorkspace,omitempty"`
+	// WorkspaceHeldBy is the flow holding the workspace when this one was
+	// queued to wait for it.
+	WorkspaceHeldBy string `json:"workspaceHeldBy,omitempty"`
+}
+
+// FlowPreemptedPayload is the payload of flow_preempted.
+type FlowPreemptedPayload struct {
+	PreemptedBy string `json:"preemptedBy"`
+}
+
+// FlowBlockedPayload is the payload of flow_blocked.
+type FlowBlockedPayload struct {
+	Reason string `json:"reason"`
+	// Cause is "gate" when a phase gate blocked the flow.
+	Cause string `json:"cause,omitempty"`
+}
+
+// FlowUnblockedPayload is the payload of flow_unblocked.
+type FlowUnblockedPayload struct {
+	Actor string `json:"actor"`
+}
+
+// FlowClonedPayload is the payload of flow_cloned, the first event of a
+// cloned flow.
+type FlowClonedPayload struct {
+	Source    string `json:"source"`
+	Phase     Phase  `json:"phase"`
+	Snapshot  int64  `json:"snapshot,omitempty"`
+	Artifacts int    `json:"artifacts"`
+}
+
+// CloneCreatedPayload is the payload of clone_created, recorded on the
+// source of a clone.
+type CloneCreatedPayload struct {
+	Clone string `json:"clone"`
+	Phase Phase  `json:"phase"`
+}
+
+// ChildSpawnedPayload is the payload of child_spawned, recorded on the
+// parent flow.
+type ChildSpawnedPayload struct {
+	Child          string  `json:"child"`
+	BudgetFraction float64 `json:"budgetFraction"`
+	BudgetCapUSD   float64 `json:"budgetCapUsd"`
+}
+
+// BudgetChangedPayload is the payload of budget_changed.
+type BudgetChangedPayload struct {
+	From float64 `json:"from"`
+	To   float64 `json:"to"`
+}
+
+// PhaseTransitionPayload is the payload of phase_transition. The restore
+// and invalidation fields are set on rollbacks only.
+type PhaseTransitionPayload struct {
+	From               Phase  `json:"from"`
+	To                 Phase  `json:"to"`
+	Action             string `json:"action"`
+	Actor              string `json:"actor"`
+	RestoredSnapshot   int64  `json:"restored_snapshot,omitempty"`
+	InvalidatedIntents int64  `json:"invalidated_intents,omitempty"`
+	InvalidatedReviews int64  `json:"invalidated_reviews,omitempty"`
+}
+
+// PhaseSLAExceededPayload is the payload of phase_sla_exceeded.
+type PhaseSLAExceededPayload struct {
+	Phase      Phase `json:"phase"`
+	EnteredAt  int64 `json:"entered_at"`
+	ElapsedSec int64 `json:"elapsed_sec"`
+	SLASec     int64 `json:"sla_sec"`
+	OverrunSec int64 `json:"overrun_sec"`
+}
+
+// AutoAdvancedPayload is the payload of auto_advanced.
+type AutoAdvancedPayload struct {
+	From     Phase        `json:"from"`
+	To       Phase        `json:"to"`
+	Gate     string       `json:"gate"`
+	Decision GateDecision `json:"decision"`
+}
+
+// IntentEventPayload is the payload of intent_granted, intent_expired, and
+// intent_queue_timeout.
+type IntentEventPayload struct {
+	Intent string `json:"intent"`
+	Worker string `json:"worker"`
+	File   string `json:"file"`
+}
+
+// PullRequestOpenedPayload is the payload of pull_request_opened.
+type PullRequestOpenedPayload struct {
+	URL    string `json:"url"`
+	Branch string `json:"branch"`
+}
+
+// ReportGeneratedPayload is the payload of report_generated.
+type ReportGeneratedPayload struct {
+	Artifact string `json:"artifact"`
+	Version  int    `json:"version"`
+}
+
+// EngineShutdownPayload is the payload of engine_shutdown, recorded on each
+// flow whose sessions an engine stopped as it shut down.
+type EngineShutdownPayload struct {
+	// Instance names the engine that shut down.
+	Instance string `json:"instance"`
+	// Status is the flow's status when the engine shut down; a running
+	// flow is paused by the event.
+	Status   FlowStatus `json:"status"`
+	Sessions int        `json:"sessions"`
+}
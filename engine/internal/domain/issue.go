@@ -0,0 +1,26 @@
+package domain
+
+// issueTransitions lists the statuses a review issue may move to from each
+// status. Closed issues can only be reopened.
+var issueTransitions = map[string][]string{
+	"open":         {"acknowledged", "fixed", "wont_fix"},
+	"acknowledged": {"open", "fixed", "wont_fix"},
+	"fixed":        {"open"},
+	"wont_fix":     {"open"},
+}
+
+// ValidIssueTransition reports whether a review issue may move from one
+// status to another.
+func ValidIssueTransition(from, to string) bool {
+	for _, s := range issueTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Closed reports whether the issue no longer needs work.
+func (i ReviewIssue) Closed() bool {
+	return i.Status == "fixed" || i.Status == "wont_fix"
+}
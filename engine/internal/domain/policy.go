@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultDeniedPatterns are file patterns denied to every worker. Policies
+// may add to them but never remove them.
+var DefaultDeniedPatterns = []string{".env", "*.key", ".git/*"}
+
+// CapabilityPolicy is the effective permission policy of a task: the paths
+// and commands its workers' capability sheets allow by default, and the
+// patterns denied whatever a sheet allows.
+type CapabilityPolicy struct {
+	TaskID          string   `json:"taskId,omitempty"`
+	DeniedPatterns  []string `json:"deniedPatterns"`
+	AllowedPaths    []string `json:"allowedPaths"`
+	AllowedCommands []string `json:"allowedCommands"`
+}
+
+// PolicyConflicts lists the allowed paths that lie wholly inside a denied
+// pattern, which would read as allowing what is always denied.
+func PolicyConflicts(denied, allowed []string) []string {
+	var problems []string
+	for _, a := range allowed {
+		for _, d := range denied {
+			if deniesPath(d, a) {
+				problems = append(problems, fmt.Sprintf("allowed path %q is denied by %q", a, d))
+			}
+		}
+	}
+	return problems
+}
+
+// deniesPath reports whether every file under allowed matches denied: the
+// allowed path is the denied file or directory or inside it, or the denied
+// glob matches the allowed path or all of its children.
+func deniesPath(denied, allowed string) bool {
+	a := strings.TrimPrefix(path.Clean(strings.TrimSuffix(allowed, "/")), "./")
+	d := strings.TrimPrefix(path.Clean(strings.TrimSuffix(denied, "/")), "./")
+	if a == "." || a == "" {
+		return false
+	}
+	if a == d || strings.HasPrefix(a, d+"/") {
+		return true
+	}
+	if ok, _ := path.Match(d, a); ok {
+		return true
+	}
+	ok, _ := path.Match(d, a+"/x")
+	return ok && strings.HasSuffix(d, "/*")
+}
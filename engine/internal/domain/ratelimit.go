@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// RateBucket is the persisted state of a token bucket: the tokens left
+// when it was last updated.
+type RateBucket struct {
+	Key         string  `json:"key"`
+	Tokens      float64 `json:"tokens"`
+	UpdatedAtMS int64   `json:"updatedAtMs"`
+}
+
+// RateLimitError reports a request refused by a rate limit and when a token
+// will next be available. It unwraps to ErrRateLimitExceeded.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v: retry after %v", ErrRateLimitExceeded, e.RetryAfter)
+}
+
+// Unwrap returns ErrRateLimitExceeded.
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimitExceeded
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds, at least one,
+// as sent in a Retry-After header.
+func (e *RateLimitError) RetryAfterSeconds() int {
+	secs := int((e.RetryAfter + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
@@ -1,6 +1,8 @@
 // Package domain defines the core types for the Three-Body Engine workflow.
 package domain
 
+import "regexp"
+
 // Phase represents workflow phases A through G.
 type Phase string
 
@@ -18,8 +20,10 @@ const (
 type FlowStatus string
 
 const (
+	StatusQueued  FlowStatus = "queued"
 	StatusRunning FlowStatus = "running"
 	StatusBlocked FlowStatus = "blocked"
+	StatusPaused  FlowStatus = "paused"
 	StatusFailed  FlowStatus = "failed"
 	StatusDone    FlowStatus = "completed"
 )
@@ -31,10 +35,58 @@ type FlowState struct {
 	Status        FlowStatus `json:"status"`
 	StateVersion  int64      `json:"stateVersion"`
 	Round         int        `json:"round"`
-	BudgetUsedUSD float64   `json:"budgetUsedUsd"`
-	BudgetCapUSD  float64   `json:"budgetCapUsd"`
+	BudgetUsedUSD float64    `json:"budgetUsedUsd"`
+	BudgetCapUSD  float64    `json:"budgetCapUsd"`
 	LastEventSeq  int64      `json:"lastEventSeq"`
 	UpdatedAtUnix int64      `json:"updatedAtUnix"`
+	AutoAdvance   bool       `json:"autoAdvance"`
+	ParentTaskID  string     `json:"parentTaskId,omitempty"`
+	StartAt       int64      `json:"startAt,omitempty"`
+	Priority      int        `json:"priority"`
+	Workspace     string     `json:"workspace,omitempty"`
+	// Title, Description, and AcceptanceCriteria describe the task to the
+	// workers; all are optional.
+	Title              string `json:"title,omitempty"`
+	Description        string `json:"description,omitempty"`
+	AcceptanceCriteria string `json:"acceptanceCriteria,omitempty"`
+	// Consensus overrides the configured consensus settings for this flow.
+	Consensus *ConsensusSettings `json:"consensus,omitempty"`
+	// Namespace isolates the flow with the other flows of one team.
+	Namespace string `json:"namespace"`
+}
+
+// DefaultNamespace holds flows created without a namespace.
+const DefaultNamespace = "default"
+
+// namespacePattern matches valid namespace names.
+var namespacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ValidNamespace reports whether name is a valid namespace: 1 to 63
+// lowercase letters, digits, '-' or '_', starting with a letter or digit.
+func ValidNamespace(name string) bool {
+	return namespacePattern.MatchString(name)
+}
+
+// NamespaceSummary describes the flows of one namespace. AllocatedUSD is the
+// budget its flows hold: the cap of each unfinished top-level flow and the
+// spend of each completed one. BudgetUSD is the configured limit on it, zero
+// when unlimited.
+type NamespaceSummary struct {
+	Namespace    string  `json:"namespace"`
+	Flows        int     `json:"flows"`
+	Active       int     `json:"active"`
+	AllocatedUSD float64 `json:"allocatedUsd"`
+	UsedUSD      float64 `json:"usedUsd"`
+	BudgetUSD    float64 `json:"budgetUsd"`
+}
+
+// ConsensusSettings weight reviewers by role and set the weighted scores at
+// or above which the consensus verdict is pass or conditional_pass. Zero
+// fields fall back to the configured or built-in defaults.
+type ConsensusSettings struct {
+	Weights              map[string]float64 `json:"weights,omitempty"`
+	PassThreshold        float64            `json:"pass_threshold,omitempty"`
+	ConditionalThreshold float64            `json:"conditional_threshold,omitempty"`
 }
 
 // TransitionTrigger initiates a phase transition.
@@ -42,15 +94,172 @@ type TransitionTrigger struct {
 	Action  string `json:"action"`
 	Actor   string `json:"actor"`
 	Payload []byte `json:"payload,omitempty"`
+	// RollbackTo selects the target of a "rollback" action. It must name a
+	// phase earlier than the current one; empty keeps the default D -> C.
+	RollbackTo Phase `json:"rollback_to,omitempty"`
 }
 
 // GateDecision is the result of evaluating phase exit conditions.
 type GateDecision struct {
-	Allow      bool
-	Blockers   []string
-	Retryable  bool
-	NextPhase  Phase
-	RequireOps []string
+	Allow      bool     `json:"allow"`
+	Blockers   []string `json:"blockers"`
+	Retryable  bool     `json:"retryable"`
+	NextPhase  Phase    `json:"nextPhase"`
+	RequireOps []string `json:"requireOps"`
+	// ReviewDiffs compares each reviewer's score cards in the flow's
+	// current round with their previous round, set by review gates.
+	ReviewDiffs []ScoreCardDiff `json:"reviewDiffs,omitempty"`
+}
+
+// GateRecord is one recorded gate evaluation: the decision the phase gate
+// returned and the trigger that asked for it.
+type GateRecord struct {
+	ID        int64    `json:"id"`
+	TaskID    string   `json:"taskId"`
+	Phase     Phase    `json:"phase"`
+	Gate      string   `json:"gate"`
+	Allow     bool     `json:"allow"`
+	Blockers  []string `json:"blockers"`
+	Retryable bool     `json:"retryable"`
+	Action    string   `json:"action"`
+	Actor     string   `json:"actor"`
+	CreatedAt int64    `json:"createdAt"`
+}
+
+// EngineStats are fleet-wide counters for dashboards. FlowsByPhase counts
+// unfinished flows only. PhaseDurationsSec averages the time flows spent in
+// each phase they have left. GateBlockRate and WorkerTimeoutRate are
+// fractions of all gate evaluations and workers; AdvanceP95MS covers the
+// Advance calls since the engine started. FailuresByCause counts failed
+// flows by the cause they failed with.
+type EngineStats struct {
+	FlowsByStatus     map[FlowStatus]int   `json:"flowsByStatus"`
+	FlowsByPhase      map[Phase]int        `json:"flowsByPhase"`
+	SpendTodayUSD     float64              `json:"spendTodayUsd"`
+	PhaseDurationsSec map[Phase]float64    `json:"phaseDurationsSec"`
+	GateBlockRate     float64              `json:"gateBlockRate"`
+	WorkerTimeoutRate float64              `json:"workerTimeoutRate"`
+	AdvanceP95MS      float64              `json:"advanceP95Ms"`
+	FailuresByCause   map[FailureCause]int `json:"failuresByCause"`
+	ComputedAt        int64                `json:"computedAt"`
+}
+
+// FailureCause classifies why a flow failed.
+type FailureCause string
+
+const (
+	FailureBudget   FailureCause = "budget"
+	FailureTimeout  FailureCause = "timeout"
+	FailureGate     FailureCause = "gate"
+	FailureManual   FailureCause = "manual"
+	FailureProvider FailureCause = "provider_error"
+)
+
+// ValidFailureCauses lists every cause a flow may fail with.
+var ValidFailureCauses = map[FailureCause]bool{
+	FailureBudget:   true,
+	FailureTimeout:  true,
+	FailureGate:     true,
+	FailureManual:   true,
+	FailureProvider: true,
+}
+
+// PostMortem is recorded when a flow fails: why, where, by whom, and the
+// flow's last events before it failed, oldest first. Status is the status
+// the flow failed from.
+type PostMortem struct {
+	TaskID        string          `json:"taskId"`
+	Phase         Phase           `json:"phase"`
+	Round         int             `json:"round"`
+	Status        FlowStatus      `json:"status"`
+	Cause         FailureCause    `json:"cause"`
+	Detail        string          `json:"detail,omitempty"`
+	Actor         string          `json:"actor,omitempty"`
+	BudgetUsedUSD float64         `json:"budgetUsedUsd"`
+	FailedAt      int64           `json:"failedAt"`
+	Events        []WorkflowEvent `json:"events"`
+}
+
+// PhaseDuration is one stay of a flow in a phase. ExitedAt is zero while
+// the flow is still there; DurationSec then runs to now. SLASec is the
+// phase's SLA when the flow entered it, zero for none, and SLAWarnedAt is
+// when the stay was flagged for exceeding it.
+type PhaseDuration struct {
+	ID          int64  `json:"id"`
+	TaskID      string `json:"taskId"`
+	Phase       Phase  `json:"phase"`
+	EnteredAt   int64  `json:"enteredAt"`
+	ExitedAt    int64  `json:"exitedAt"`
+	DurationSec int64  `json:"durationSec"`
+	SLASec      int64  `json:"slaSec"`
+	SLAWarnedAt int64  `json:"slaWarnedAt"`
+}
+
+// PhaseDurationStats summarize every flow's stays in one phase. The
+// average and maximum cover finished stays only; Breaches counts stays,
+// finished or not, that ran past their SLA. SLASec is the phase's current
+// SLA, zero for none.
+type PhaseDurationStats struct {
+	Phase    Phase   `json:"phase"`
+	Entries  int     `json:"entries"`
+	Finished int     `json:"finished"`
+	Open     int     `json:"open"`
+	AvgSec   float64 `json:"avgSec"`
+	MaxSec   int64   `json:"maxSec"`
+	Breaches int     `json:"breaches"`
+	SLASec   int64   `json:"slaSec"`
+}
+
+// Approval decisions.
+const (
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
+// Approval is a human's decision on letting a flow leave Phase in Round.
+// A rejection's Comment says what must change.
+type Approval struct {
+	ID        int64  `json:"id"`
+	TaskID    string `json:"taskId"`
+	Phase     Phase  `json:"phase"`
+	Round     int    `json:"round"`
+	Actor     string `json:"actor"`
+	Decision  string `json:"decision"`
+	Comment   string `json:"comment,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// Lease is a named lock held by one engine instance until ExpiresAt (unix
+// seconds) unless the holder renews it.
+type Lease struct {
+	Name       string `json:"name"`
+	Holder     string `json:"holder"`
+	AcquiredAt int64  `json:"acquiredAt"`
+	ExpiresAt  int64  `json:"expiresAt"`
+}
+
+// IdempotentResponse is the stored response to a request sent with an
+// idempotency key, replayed when the request is retried.
+type IdempotentResponse struct {
+	Key       string `json:"key"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Status    int    `json:"status"`
+	Body      string `json:"body"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// AdvancePreview reports what advancing a flow would do, without doing it.
+// Allow is true when the gate allows the flow out of From and the trigger
+// names a legal transition to To; Error explains a trigger or flow state
+// that would be refused before the gate.
+type AdvancePreview struct {
+	TaskID   string       `json:"taskId"`
+	From     Phase        `json:"from"`
+	To       Phase        `json:"to,omitempty"`
+	Allow    bool         `json:"allow"`
+	Decision GateDecision `json:"decision"`
+	Error    string       `json:"error,omitempty"`
 }
 
 // WorkerState represents the lifecycle state of a worker.
@@ -74,95 +283,208 @@ type WorkerSpec struct {
 	DigestPath     string
 	SoftTimeoutSec int
 	HardTimeoutSec int
+	// Priority is copied from the owning flow; higher values may use the
+	// worker pool's reserved slots.
+	Priority int
+	// LineageID and Generation are set when respawning a replaced worker.
+	LineageID  string
+	Generation int
+	// ArtifactPhase limits the digest's artifacts to those submitted in
+	// one phase; empty means every artifact.
+	ArtifactPhase Phase
+	// Capabilities, when set, restricts what the worker's session may do.
+	Capabilities *CapabilitySheet
 }
 
 // Intent represents a planned file operation by a worker.
 type Intent struct {
-	IntentID    string
-	TaskID      string
-	WorkerID    string
-	TargetFile  string
-	Operation   string
-	Status      string
-	PreHash     string
-	PostHash    string
-	PayloadHash string
-	LeaseUntil  int64
+	IntentID    string `json:"intentId"`
+	TaskID      string `json:"taskId"`
+	WorkerID    string `json:"workerId"`
+	TargetFile  string `json:"targetFile"`
+	Operation   string `json:"operation"`
+	Status      string `json:"status"`
+	PreHash     string `json:"preHash"`
+	PostHash    string `json:"postHash"`
+	PayloadHash string `json:"payloadHash"`
+	LeaseUntil  int64  `json:"leaseUntil"`
+	CreatedAt   int64  `json:"createdAt"`
+	// LeaseSec is the lease a queued intent receives when it is granted.
+	LeaseSec int64 `json:"leaseSec,omitempty"`
+	// QueuedAt orders the waiters on a file, in unix nanoseconds. It is zero
+	// for intents that never waited.
+	QueuedAt int64 `json:"queuedAt,omitempty"`
+	// QueueUntil is when a queued intent stops waiting; zero waits forever.
+	QueueUntil int64 `json:"queueUntil,omitempty"`
 }
 
-// ArtifactRef points to a versioned artifact in the task directory.
+// ArtifactRef points to a versioned artifact in the task directory. Each
+// submission for the same task and path gets the next Version.
 type ArtifactRef struct {
-	ID      string
-	Type    string
-	Path    string
-	Version int
-	Hash    string
+	ID        string `json:"id"`
+	TaskID    string `json:"taskId,omitempty"`
+	WorkerID  string `json:"workerId,omitempty"`
+	Phase     Phase  `json:"phase,omitempty"`
+	Type      string `json:"type"`
+	Path      string `json:"path"`
+	Version   int    `json:"version"`
+	Hash      string `json:"hash"`
+	CreatedAt int64  `json:"createdAt,omitempty"`
 }
 
 // Deadline defines soft and hard time limits.
 type Deadline struct {
-	Soft string
-	Hard string
+	Soft string `json:"soft"`
+	Hard string `json:"hard"`
 }
 
 // ContextDigest is the lightweight index sent to workers.
 type ContextDigest struct {
-	TaskID          string
-	PhaseID         string
-	Objective       string
-	Constraints     []string
-	FileOwnership   []string
-	Deadline        Deadline
-	ArtifactRefs    []ArtifactRef
-	CodingStandards string
+	TaskID          string        `json:"taskId"`
+	PhaseID         string        `json:"phaseId"`
+	Role            string        `json:"role,omitempty"`
+	Objective       string        `json:"objective"`
+	Constraints     []string      `json:"constraints"`
+	FileOwnership   []string      `json:"fileOwnership"`
+	Deadline        Deadline      `json:"deadline"`
+	ArtifactRefs    []ArtifactRef `json:"artifactRefs"`
+	CodingStandards string        `json:"codingStandards,omitempty"`
+	// Round is the flow's review round; in later rounds PriorIssues lists
+	// the issues reviewers raised in the round before.
+	Round       int      `json:"round"`
+	PriorIssues []string `json:"priorIssues,omitempty"`
+	// Compacted is the context compacted when the phase was entered, if any.
+	Compacted *CompactionSlots `json:"compacted,omitempty"`
+	// Capabilities is the worker's capability sheet, if it has one.
+	Capabilities *CapabilitySheet `json:"capabilities,omitempty"`
 }
 
 // CompactionSlots are the 9 semantic slots that must survive compaction.
 type CompactionSlots struct {
-	TaskSpec           string
-	AcceptanceCriteria string
-	CurrentPhase       string
-	OpenRisks          []string
-	ActiveConstraints  []string
-	FileOwnership      []string
-	ArtifactRefs       []ArtifactRef
-	PendingIntents     []string
-	NextPhaseReqs      []string
+	TaskSpec           string        `json:"taskSpec"`
+	AcceptanceCriteria string        `json:"acceptanceCriteria"`
+	CurrentPhase       string        `json:"currentPhase"`
+	OpenRisks          []string      `json:"openRisks"`
+	ActiveConstraints  []string      `json:"activeConstraints"`
+	FileOwnership      []string      `json:"fileOwnership"`
+	ArtifactRefs       []ArtifactRef `json:"artifactRefs"`
+	PendingIntents     []string      `json:"pendingIntents"`
+	NextPhaseReqs      []string      `json:"nextPhaseReqs"`
 }
 
 // WorkflowEvent represents an event in the workflow event log.
 type WorkflowEvent struct {
-	ID          int64  `json:"id"`
-	TaskID      string `json:"taskId"`
-	SeqNo       int64  `json:"seqNo"`
-	Phase       Phase  `json:"phase"`
-	EventType   string `json:"eventType"`
-	PayloadJSON string `json:"payloadJson"`
-	CreatedAt   int64  `json:"createdAt"`
+	ID          int64     `json:"id"`
+	TaskID      string    `json:"taskId"`
+	SeqNo       int64     `json:"seqNo"`
+	Phase       Phase     `json:"phase"`
+	EventType   EventType `json:"eventType"`
+	PayloadJSON string    `json:"payloadJson"`
+	// SchemaVersion is the version of EventType's payload schema the
+	// event was recorded with; 0 for experimental event types.
+	SchemaVersion int   `json:"schemaVersion"`
+	CreatedAt     int64 `json:"createdAt"`
 }
 
 // PhaseSnapshot captures the state at a phase boundary.
 type PhaseSnapshot struct {
-	ID           int64
-	TaskID       string
-	Phase        Phase
-	Round        int
-	SnapshotJSON string
-	Checksum     string
-	CreatedAt    int64
+	ID           int64  `json:"id"`
+	TaskID       string `json:"taskId"`
+	Phase        Phase  `json:"phase"`
+	Round        int    `json:"round"`
+	SnapshotJSON string `json:"snapshotJson"`
+	Checksum     string `json:"checksum"`
+	CreatedAt    int64  `json:"createdAt"`
+	// Kind is empty for the snapshot taken at each transition and
+	// SnapshotCompaction for compacted context.
+	Kind string `json:"kind,omitempty"`
 }
 
+// SnapshotCompaction is the Kind of snapshots holding CompactionSlots.
+const SnapshotCompaction = "compaction"
+
 // AuditRecord logs security and compliance events.
 type AuditRecord struct {
-	ID           string
-	TaskID       string
-	Category     string
-	Actor        string
-	Action       string
-	RequestJSON  string
-	DecisionJSON string
-	Severity     string
-	CreatedAt    int64
+	ID           string `json:"id"`
+	TaskID       string `json:"taskId"`
+	Category     string `json:"category"`
+	Actor        string `json:"actor"`
+	Action       string `json:"action"`
+	RequestJSON  string `json:"requestJson"`
+	DecisionJSON string `json:"decisionJson"`
+	Severity     string `json:"severity"`
+	CreatedAt    int64  `json:"createdAt"`
+	// Seq, PrevHash, and Hash place the record in its task's audit chain.
+	// Records from before chaining have Seq 0.
+	Seq      int64  `json:"seq"`
+	PrevHash string `json:"prevHash"`
+	Hash     string `json:"hash"`
+}
+
+// AuditChainReport is the result of verifying a task's audit chain.
+// PrunedThrough is the last record retention removed, and HeadSeq and
+// HeadHash identify the newest record; keeping the head elsewhere lets a
+// later check notice records deleted from the end.
+type AuditChainReport struct {
+	TaskID        string              `json:"taskId"`
+	Valid         bool                `json:"valid"`
+	Records       int                 `json:"records"`
+	Unchained     int                 `json:"unchained"`
+	PrunedThrough int64               `json:"prunedThrough"`
+	HeadSeq       int64               `json:"headSeq"`
+	HeadHash      string              `json:"headHash"`
+	Problems      []AuditChainProblem `json:"problems,omitempty"`
+}
+
+// AuditChainProblem is a break in an audit chain, found at the record with
+// the given position.
+type AuditChainProblem struct {
+	Seq      int64  `json:"seq"`
+	RecordID string `json:"recordId"`
+	Problem  string `json:"problem"`
+}
+
+// Decision is a question raised for a human, such as which of two
+// conflicting intents to keep. Status is "pending" until resolved.
+type Decision struct {
+	DecisionID  string `json:"decisionId"`
+	TaskID      string `json:"taskId"`
+	Kind        string `json:"kind"`
+	SubjectJSON string `json:"subjectJson"`
+	Status      string `json:"status"`
+	Choice      string `json:"choice,omitempty"`
+	Actor       string `json:"actor,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+	CreatedAt   int64  `json:"createdAt"`
+	ResolvedAt  int64  `json:"resolvedAt,omitempty"`
+}
+
+// Constraint is a rule every worker on a task must respect, such as "do not
+// change the public API". Status is "active" until resolved; active
+// constraints are listed in context digests in every phase.
+type Constraint struct {
+	ConstraintID string `json:"constraintId"`
+	TaskID       string `json:"taskId"`
+	Text         string `json:"text"`
+	Status       string `json:"status"`
+	CreatedAt    int64  `json:"createdAt"`
+	ResolvedAt   int64  `json:"resolvedAt,omitempty"`
+}
+
+// Risk is a known hazard of a task, rated P0 (blocking) to P2. Status is
+// "open" until resolved; open risks carry across phases and open P0 risks
+// block the review gates.
+type Risk struct {
+	RiskID     string `json:"riskId"`
+	TaskID     string `json:"taskId"`
+	Phase      Phase  `json:"phase"`
+	Severity   string `json:"severity"`
+	Text       string `json:"text"`
+	Status     string `json:"status"`
+	Actor      string `json:"actor,omitempty"`
+	CreatedAt  int64  `json:"createdAt"`
+	ResolvedBy string `json:"resolvedBy,omitempty"`
+	ResolvedAt int64  `json:"resolvedAt,omitempty"`
 }
 
 // Scores holds the 5-dimension review scores (1-5 each).
@@ -193,14 +515,94 @@ type ScoreCard struct {
 	Alternatives []string `json:"alternatives"`
 	Verdict      string   `json:"verdict"`
 	CreatedAt    int64    `json:"createdAt"`
+	// Round is the flow round the card was submitted in; InvalidatedAt is
+	// set once a rollback or rework has discarded it.
+	Round         int   `json:"round"`
+	InvalidatedAt int64 `json:"invalidatedAt,omitempty"`
+}
+
+// ReviewIssue is an issue raised in a score card, tracked as a record of its
+// own until it is closed. Status is open, acknowledged, fixed, or wont_fix;
+// FixIntentID and FixCommit link the change that addressed it.
+type ReviewIssue struct {
+	IssueID  string `json:"issueId"`
+	TaskID   string `json:"taskId"`
+	ReviewID string `json:"reviewId"`
+	Reviewer string `json:"reviewer"`
+	Round    int    `json:"round"`
+	Issue
+	Status      string `json:"status"`
+	FixIntentID string `json:"fixIntentId,omitempty"`
+	FixCommit   string `json:"fixCommit,omitempty"`
+	Note        string `json:"note,omitempty"`
+	UpdatedBy   string `json:"updatedBy,omitempty"`
+	CreatedAt   int64  `json:"createdAt"`
+	UpdatedAt   int64  `json:"updatedAt,omitempty"`
+}
+
+// TestReport is the outcome of one run of the project's test command.
+// Coverage is nil when the output reported none.
+type TestReport struct {
+	Command    string   `json:"command"`
+	ExitCode   int      `json:"exitCode"`
+	Passed     int      `json:"passed"`
+	Failed     int      `json:"failed"`
+	Coverage   *float64 `json:"coverage,omitempty"`
+	DurationMS int64    `json:"durationMs"`
+	TimedOut   bool     `json:"timedOut,omitempty"`
+	Output     string   `json:"output"`
+	CreatedAt  int64    `json:"createdAt"`
 }
 
-// ConsensusResult is the aggregated review decision.
+// ScoreCardDiff compares one reviewer's score cards from two rounds.
+// ScoreDeltas holds the later scores minus the earlier ones; issues are
+// matched by location and description.
+type ScoreCardDiff struct {
+	Reviewer    string  `json:"reviewer"`
+	FromRound   int     `json:"fromRound"`
+	ToRound     int     `json:"toRound"`
+	FromVerdict string  `json:"fromVerdict"`
+	ToVerdict   string  `json:"toVerdict"`
+	ScoreDeltas Scores  `json:"scoreDeltas"`
+	Resolved    []Issue `json:"resolved"`
+	New         []Issue `json:"new"`
+	Persisting  []Issue `json:"persisting"`
+}
+
+// ReviewRound is one review cycle of a flow. Round 0 starts with the flow;
+// every rollback or rework ends the current round with the trigger action as
+// its outcome and starts the next one in the phase the flow returns to.
+type ReviewRound struct {
+	TaskID    string `json:"taskId"`
+	Round     int    `json:"round"`
+	Phase     Phase  `json:"phase"`
+	StartedAt int64  `json:"startedAt"`
+	EndedAt   int64  `json:"endedAt,omitempty"`
+	Outcome   string `json:"outcome,omitempty"`
+}
+
+// VerdictPolicy adds hard rules on top of the weighted consensus of a phase.
+// MinReviewers requires score cards from that many distinct reviewers
+// (verdict "insufficient_reviews"); AnyFailForcesFail fails the consensus on
+// any fail card and LeadVeto on a fail card from the lead; P0ForcesRework
+// returns "rework" when any card raises a P0 issue.
+type VerdictPolicy struct {
+	AnyFailForcesFail bool `json:"any_fail_forces_fail,omitempty"`
+	LeadVeto          bool `json:"lead_veto,omitempty"`
+	MinReviewers      int  `json:"min_reviewers,omitempty"`
+	P0ForcesRework    bool `json:"p0_forces_rework,omitempty"`
+}
+
+// ConsensusResult is the aggregated review decision and the settings and
+// verdict policy it was reached with.
 type ConsensusResult struct {
-	WeightedScore float64
-	Blocking      bool
-	BlockReasons  []string
-	FinalVerdict  string
+	WeightedScore float64           `json:"weightedScore"`
+	Blocking      bool              `json:"blocking"`
+	BlockReasons  []string          `json:"blockReasons"`
+	FinalVerdict  string            `json:"finalVerdict"`
+	Settings      ConsensusSettings `json:"settings"`
+	Phase         Phase             `json:"phase,omitempty"`
+	Policy        VerdictPolicy     `json:"policy"`
 }
 
 // Provider identifies a code agent provider.
@@ -210,16 +612,49 @@ const (
 	ProviderClaude Provider = "claude"
 	ProviderCodex  Provider = "codex"
 	ProviderGemini Provider = "gemini"
+	// ProviderMock names the adapter of scenario-driven mock providers,
+	// whose output is already canonical.
+	ProviderMock Provider = "mock"
 )
 
+// ValidProviderName reports whether name can name a provider registered at
+// runtime; the rules are those of ValidNamespace.
+func ValidProviderName(name string) bool {
+	return namespacePattern.MatchString(name)
+}
+
+// ProviderRegistration is a provider registered through the API rather than
+// the config file. It takes precedence over a configured provider of the
+// same name.
+type ProviderRegistration struct {
+	Name      Provider          `json:"name"`
+	Command   string            `json:"command"`
+	Args      []string          `json:"args"`
+	Env       map[string]string `json:"env"`
+	Adapter   string            `json:"adapter,omitempty"`
+	CreatedAt int64             `json:"createdAt"`
+	UpdatedAt int64             `json:"updatedAt"`
+}
+
 // SessionConfig configures a code agent session.
 type SessionConfig struct {
 	TaskID      string
 	Role        string
+	Provider    Provider
 	Workspace   string
 	Env         map[string]string
 	TimeoutSec  int
 	ContextFile string
+	// Capabilities, when set, is the worker's capability sheet.
+	Capabilities *CapabilitySheet
+	// Namespace selects the namespace's provider overrides, if any.
+	Namespace string
+	// WorkerID is the worker the session runs for; its cost is charged to it.
+	WorkerID string
+	// Phase and BudgetRemainingUSD describe the flow when the session
+	// starts, for env template variables.
+	Phase              Phase
+	BudgetRemainingUSD float64
 }
 
 // NormalizedEvent is a provider-agnostic event from a code agent session.
@@ -230,6 +665,34 @@ type NormalizedEvent struct {
 	Payload   []byte   `json:"payload"`
 }
 
+// SessionEvent is a persisted NormalizedEvent in a session's transcript.
+type SessionEvent struct {
+	ID          int64    `json:"id"`
+	SessionID   string   `json:"sessionId"`
+	TaskID      string   `json:"taskId"`
+	SeqNo       int64    `json:"seqNo"`
+	EventType   string   `json:"eventType"`
+	Provider    Provider `json:"provider"`
+	PayloadJSON string   `json:"payloadJson"`
+	CreatedAt   int64    `json:"createdAt"`
+}
+
+// DeadLetterEvent is a line of session output its provider's adapter could
+// not parse, kept so it can be inspected and requeued once the adapter is
+// fixed. RequeuedAt is zero until the line is parsed successfully.
+type DeadLetterEvent struct {
+	ID         int64    `json:"id"`
+	SessionID  string   `json:"sessionId"`
+	TaskID     string   `json:"taskId"`
+	WorkerID   string   `json:"workerId,omitempty"`
+	Provider   Provider `json:"provider"`
+	Adapter    Provider `json:"adapter"`
+	Raw        string   `json:"raw"`
+	Error      string   `json:"error"`
+	CreatedAt  int64    `json:"createdAt"`
+	RequeuedAt int64    `json:"requeuedAt"`
+}
+
 // CostDelta records a cost increment.
 type CostDelta struct {
 	InputTokens  int64    `json:"inputTokens"`
@@ -237,7 +700,113 @@ type CostDelta struct {
 	AmountUSD    float64  `json:"amountUsd"`
 	Provider     Provider `json:"provider"`
 	Phase        Phase    `json:"phase"`
-	CreatedAt    int64    `json:"createdAt"`
+	WorkerID     string   `json:"workerId,omitempty"`
+	// Key, when set, identifies the provider's cost report; a delta with a
+	// key already recorded for the task is not counted again.
+	Key       string `json:"key,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// BudgetAlert records a named budget alert that fired for a task: its
+// spend crossed Ratio of the cap in force at the time.
+type BudgetAlert struct {
+	TaskID  string  `json:"taskId"`
+	Name    string  `json:"name"`
+	CapUSD  float64 `json:"capUsd"`
+	Ratio   float64 `json:"ratio"`
+	UsedUSD float64 `json:"usedUsd"`
+	FiredAt int64   `json:"firedAt"`
+}
+
+// CI check statuses.
+const (
+	CISuccess = "success"
+	CIFailure = "failure"
+	CIPending = "pending"
+)
+
+// CICheck is the latest result of one CI check on a task's branch, polled
+// from the CI provider or reported through the CI webhook.
+type CICheck struct {
+	TaskID    string `json:"taskId"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	URL       string `json:"url,omitempty"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// Issue sync operation kinds.
+const (
+	IssueOpCreate  = "create"
+	IssueOpComment = "comment"
+	IssueOpClose   = "close"
+)
+
+// IssueLink ties a task to the issue tracking it in Jira or Linear.
+type IssueLink struct {
+	TaskID   string `json:"taskId"`
+	Provider string `json:"provider"`
+	// Key is the issue's human-readable key, such as ENG-42; ID is the
+	// tracker's internal ID where it differs.
+	Key       string `json:"key"`
+	ID        string `json:"id,omitempty"`
+	URL       string `json:"url,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// IssueSyncOp is a change to a task's tracking issue waiting to be sent.
+// Failed operations are retried at NextAttemptAt, in order per task.
+type IssueSyncOp struct {
+	ID            int64  `json:"id"`
+	TaskID        string `json:"taskId"`
+	Kind          string `json:"kind"`
+	Body          string `json:"body,omitempty"`
+	Attempts      int    `json:"attempts"`
+	NextAttemptAt int64  `json:"nextAttemptAt"`
+	LastError     string `json:"lastError,omitempty"`
+	CreatedAt     int64  `json:"createdAt"`
+}
+
+// CostGroup is the spend of the cost deltas sharing one grouping key.
+type CostGroup struct {
+	Key          string  `json:"key"`
+	Deltas       int     `json:"deltas"`
+	InputTokens  int64   `json:"inputTokens"`
+	OutputTokens int64   `json:"outputTokens"`
+	AmountUSD    float64 `json:"amountUsd"`
+}
+
+// CostBreakdown is spend grouped by GroupBy, largest group first.
+type CostBreakdown struct {
+	GroupBy  string      `json:"groupBy"`
+	TotalUSD float64     `json:"totalUsd"`
+	Groups   []CostGroup `json:"groups"`
+}
+
+// TaskBundle is a portable copy of everything recorded for one task, used to
+// move a task between engine instances.
+type TaskBundle struct {
+	FormatVersion int             `json:"formatVersion"`
+	ExportedAt    int64           `json:"exportedAt"`
+	Task          FlowState       `json:"task"`
+	Events        []WorkflowEvent `json:"events"`
+	Snapshots     []PhaseSnapshot `json:"snapshots"`
+	Intents       []Intent        `json:"intents"`
+	Workers       []*WorkerRef    `json:"workers"`
+	ScoreCards    []ScoreCard     `json:"scoreCards"`
+	CostDeltas    []CostDelta     `json:"costDeltas"`
+	Audit         []AuditRecord   `json:"audit"`
+
+	SessionEvents []SessionEvent    `json:"sessionEvents,omitempty"`
+	DeadLetters   []DeadLetterEvent `json:"deadLetters,omitempty"`
+	Artifacts     []ArtifactRef     `json:"artifacts,omitempty"`
+	Decisions     []Decision        `json:"decisions,omitempty"`
+	Constraints   []Constraint      `json:"constraints,omitempty"`
+	Risks         []Risk            `json:"risks,omitempty"`
+	ReviewRounds  []ReviewRound     `json:"reviewRounds,omitempty"`
+	ReviewIssues  []ReviewIssue     `json:"reviewIssues,omitempty"`
+	GateDecisions []GateRecord      `json:"gateDecisions,omitempty"`
+	Approvals     []Approval        `json:"approvals,omitempty"`
 }
 
 // WorkerRef tracks an active worker instance.
@@ -252,15 +821,31 @@ type WorkerRef struct {
 	HardTimeoutSec int         `json:"hardTimeoutSec"`
 	LastHeartbeat  int64       `json:"lastHeartbeat"`
 	CreatedAtUnix  int64       `json:"createdAtUnix"`
+	// LineageID is the ID of the original worker a replacement descends
+	// from; empty for an original. Generation counts replacements.
+	LineageID  string         `json:"lineageId,omitempty"`
+	Generation int            `json:"generation"`
+	Progress   WorkerProgress `json:"progress"`
+	// Capabilities is the sheet the worker was spawned with, if any.
+	Capabilities *CapabilitySheet `json:"capabilities,omitempty"`
+}
+
+// WorkerProgress is the latest progress a worker reported. UpdatedAt is zero
+// until the first report.
+type WorkerProgress struct {
+	Percent     int    `json:"percent"`
+	CurrentFile string `json:"currentFile,omitempty"`
+	Note        string `json:"note,omitempty"`
+	UpdatedAt   int64  `json:"updatedAt"`
 }
 
 // CapabilitySheet defines allowed operations for a task.
 type CapabilitySheet struct {
-	TaskID          string
-	AllowedPaths    []string
-	AllowedCommands []string
-	DeniedPatterns  []string
-	CreatedAtUnix   int64
+	TaskID          string   `json:"taskId"`
+	AllowedPaths    []string `json:"allowedPaths"`
+	AllowedCommands []string `json:"allowedCommands"`
+	DeniedPatterns  []string `json:"deniedPatterns"`
+	CreatedAtUnix   int64    `json:"createdAt"`
 }
 
 // CostAction is the decision from the cost governor.
@@ -0,0 +1,87 @@
+// Package eventbus provides an in-process publish/subscribe channel for state
+// change signals, letting background loops react to writes made elsewhere
+// without polling the database.
+package eventbus
+
+import "sync"
+
+// Topic names the kind of state change a Signal announces.
+type Topic string
+
+const (
+	TopicReviewSubmitted Topic = "review_submitted"
+	TopicIntentDone      Topic = "intent_done"
+	TopicIntentExpired   Topic = "intent_expired"
+	TopicIntentGranted   Topic = "intent_granted"
+	TopicChildDone       Topic = "child_done"
+	TopicFlowBlocked     Topic = "flow_blocked"
+	TopicFlowUnblocked   Topic = "flow_unblocked"
+	// TopicApprovalRecorded announces a human approval of a flow's phase.
+	TopicApprovalRecorded Topic = "approval_recorded"
+	// TopicCustomEvent announces a custom event reported for a flow by an
+	// external system.
+	TopicCustomEvent Topic = "custom_event"
+	// TopicCIReported announces a change in the CI checks of a flow's
+	// branch.
+	TopicCIReported Topic = "ci_reported"
+	// TopicFlowCreated announces a new flow, running or queued.
+	TopicFlowCreated Topic = "flow_created"
+	// TopicFlowUpdated announces a committed change to a flow's state row:
+	// its phase, status, round, or budget.
+	TopicFlowUpdated Topic = "flow_updated"
+	// TopicBudgetChanged announces a new budget cap for a flow.
+	TopicBudgetChanged Topic = "budget_changed"
+)
+
+// Signal announces that something changed for a task.
+type Signal struct {
+	Topic  Topic
+	TaskID string
+}
+
+// Bus fans out published signals to every subscriber. A nil *Bus is valid
+// and discards all signals, so components can publish unconditionally.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[chan Signal]struct{}
+}
+
+// New creates an empty Bus.
+func New() *Bus {
+	return &Bus{subs: make(map[chan Signal]struct{})}
+}
+
+// Subscribe returns a channel receiving every subsequently published signal
+// and a function that unsubscribes and closes it. Signals are dropped for a
+// subscriber whose buffer is full, so consumers must tolerate missed signals.
+func (b *Bus) Subscribe(buffer int) (<-chan Signal, func()) {
+	ch := make(chan Signal, buffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish delivers sig to all subscribers without blocking.
+func (b *Bus) Publish(sig Signal) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs {
+		select {
+		case ch <- sig:
+		default:
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package eventbus
+
+import "testing"
+
+func TestBus_PublishFanOut(t *testing.T) {
+	b := New()
+	a, unsubA := b.Subscribe(1)
+	defer unsubA()
+	c, unsubC := b.Subscribe(1)
+	defer unsubC()
+
+	b.Publish(Signal{Topic: TopicIntentDone, TaskID: "t1"})
+
+	for i, ch := range []<-chan Signal{a, c} {
+		select {
+		case sig := <-ch:
+			if sig.TaskID != "t1" || sig.Topic != TopicIntentDone {
+				t.Errorf("subscriber %d got %+v", i, sig)
+			}
+		default:
+			t.Errorf("subscriber %d received nothing", i)
+		}
+	}
+}
+
+func TestBus_DropsWhenFull(t *testing.T) {
+	b := New()
+	ch, unsub := b.Subscribe(1)
+	defer unsub()
+
+	b.Publish(Signal{TaskID: "first"})
+	b.Publish(Signal{TaskID: "second"})
+
+	if sig := <-ch; sig.TaskID != "first" {
+		t.Errorf("got %q, want first", sig.TaskID)
+	}
+	select {
+	case sig := <-ch:
+		t.Errorf("expected dropped signal, got %+v", sig)
+	default:
+	}
+}
+
+func TestBus_UnsubscribeClosesChannel(t *testing.T) {
+	b := New()
+	ch, unsub := b.Subscribe(1)
+	unsub()
+	unsub()
+
+	if _, ok := <-ch; ok {
+		t.Error("expected closed channel")
+	}
+	b.Publish(Signal{TaskID: "t1"})
+}
+
+func TestBus_NilIsNoop(t *testing.T) {
+	var b *Bus
+	b.Publish(Signal{TaskID: "t1"})
+}
@@ -0,0 +1,297 @@
+// Package git records each flow's file changes on a dedicated branch of the
+// project repository and produces the diffs reviewers inspect in phase F.
+//
+// Commits are built with plumbing commands against a temporary index, so the
+// branch advances without being checked out and several flows can share one
+// working tree.
+package git
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// FileChange summarises one file in a Diff.
+type FileChange struct {
+	Path      string `json:"path"`
+	Status    string `json:"status"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+}
+
+// Diff is the set of changes on a flow's branch since it was created.
+type Diff struct {
+	TaskID string       `json:"taskId"`
+	Branch string       `json:"branch"`
+	Base   string       `json:"base,omitempty"`
+	Head   string       `json:"head,omitempty"`
+	Files  []FileChange `json:"files"`
+	Patch  string       `json:"patch"`
+}
+
+// Manager creates flow branches and commits executed intents to them.
+type Manager struct {
+	DB        *sql.DB
+	TaskRepo  *store.TaskRepo
+	AuditRepo *store.AuditRepo
+	// Repo is the working tree used by flows without their own workspace.
+	Repo         string
+	BranchPrefix string
+	AuthorName   string
+	AuthorEmail  string
+
+	// mu serialises commits so concurrent intents of one flow do not race
+	// on the branch ref.
+	mu sync.Mutex
+}
+
+// New creates a Manager for the repository at repo.
+func New(db *sql.DB, repo string) *Manager {
+	return &Manager{
+		DB:           db,
+		TaskRepo:     &store.TaskRepo{},
+		AuditRepo:    &store.AuditRepo{},
+		Repo:         repo,
+		BranchPrefix: "threebody/",
+		AuthorName:   "Three-Body Engine",
+		AuthorEmail:  "threebody@localhost",
+	}
+}
+
+// Branch returns the name of the branch that holds taskID's changes.
+func (m *Manager) Branch(taskID string) string {
+	return m.BranchPrefix + taskID
+}
+
+// baseRef marks the commit a flow's branch was created from.
+func baseRef(taskID string) string {
+	return "refs/threebody/base/" + taskID
+}
+
+// Start creates each flow's branch on its first transition. Branches are also
+// created on demand by CommitIntent.
+func (m *Manager) Start(engine *workflow.Engine) {
+	engine.AddListener(func(ctx context.Context, state domain.FlowState, _ domain.Phase) {
+		if err := m.EnsureBranch(ctx, state); err != nil {
+			m.audit(state.TaskID, "branch_failed", "warning", map[string]string{"error": err.Error()})
+		}
+	})
+}
+
+// EnsureBranch creates the flow's branch and base ref at the HEAD of its
+// working tree unless they already exist.
+func (m *Manager) EnsureBranch(ctx context.Context, state domain.FlowState) error {
+	dir := m.dir(state)
+	branch := "refs/heads/" + m.Branch(state.TaskID)
+	if _, err := m.git(ctx, dir, nil, "rev-parse", "--verify", "--quiet", branch); err == nil {
+		return nil
+	}
+	head, err := m.git(ctx, dir, nil, "rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return fmt.Errorf("resolve HEAD: %w", err)
+	}
+	if _, err := m.git(ctx, dir, nil, "update-ref", baseRef(state.TaskID), head); err != nil {
+		return fmt.Errorf("record base: %w", err)
+	}
+	if _, err := m.git(ctx, dir, nil, "update-ref", branch, head, ""); err != nil {
+		return fmt.Errorf("create branch: %w", err)
+	}
+	m.audit(state.TaskID, "branch_created", "info", map[string]string{"branch": m.Branch(state.TaskID), "base": head})
+	return nil
+}
+
+// CommitIntent commits the current content of an executed intent's target
+// file to the flow's branch. Nothing is committed if the file is unchanged.
+func (m *Manager) CommitIntent(ctx context.Context, intent domain.Intent) error {
+	state, err := m.TaskRepo.GetByID(ctx, m.DB, intent.TaskID)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.EnsureBranch(ctx, *state); err != nil {
+		return err
+	}
+	dir := m.dir(*state)
+	branch := "refs/heads/" + m.Branch(intent.TaskID)
+
+	index, err := os.CreateTemp("", "threebody-index-*")
+	if err != nil {
+		return fmt.Errorf("create index: %w", err)
+	}
+	index.Close()
+	os.Remove(index.Name())
+	defer os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	parent, err := m.git(ctx, dir, nil, "rev-parse", "--verify", branch)
+	if err != nil {
+		return fmt.Errorf("resolve branch: %w", err)
+	}
+	if _, err := m.git(ctx, dir, env, "read-tree", parent); err != nil {
+		return fmt.Errorf("read tree: %w", err)
+	}
+	if _, err := m.git(ctx, dir, env, "add", "--all", "--", intent.TargetFile); err != nil {
+		return fmt.Errorf("stage %s: %w", intent.TargetFile, err)
+	}
+	tree, err := m.git(ctx, dir, env, "write-tree")
+	if err != nil {
+		return fmt.Errorf("write tree: %w", err)
+	}
+	parentTree, err := m.git(ctx, dir, nil, "rev-parse", parent+"^{tree}")
+	if err != nil {
+		return fmt.Errorf("resolve parent tree: %w", err)
+	}
+	if tree == parentTree {
+		return nil
+	}
+
+	msg := fmt.Sprintf("threebody: %s %s\n\nTask: %s\nIntent: %s\nWorker: %s\n",
+		intent.Operation, intent.TargetFile, intent.TaskID, intent.IntentID, intent.WorkerID)
+	author := []string{
+		"GIT_AUTHOR_NAME=" + m.AuthorName, "GIT_AUTHOR_EMAIL=" + m.AuthorEmail,
+		"GIT_COMMITTER_NAME=" + m.AuthorName, "GIT_COMMITTER_EMAIL=" + m.AuthorEmail,
+	}
+	commit, err := m.git(ctx, dir, author, "commit-tree", tree, "-p", parent, "-m", msg)
+	if err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	if _, err := m.git(ctx, dir, nil, "update-ref", branch, commit, parent); err != nil {
+		return fmt.Errorf("advance branch: %w", err)
+	}
+
+	m.audit(intent.TaskID, "intent_committed", "info", map[string]string{
+		"intent": intent.IntentID,
+		"worker": intent.WorkerID,
+		"commit": commit,
+	})
+	return nil
+}
+
+// Push pushes taskID's branch to remote under the same name, creating the
+// branch first if the flow has none yet.
+func (m *Manager) Push(ctx context.Context, taskID, remote string) error {
+	state, err := m.TaskRepo.GetByID(ctx, m.DB, taskID)
+	if err != nil {
+		return err
+	}
+	if err := m.EnsureBranch(ctx, *state); err != nil {
+		return err
+	}
+	ref := "refs/heads/" + m.Branch(taskID)
+	if _, err := m.git(ctx, m.dir(*state), nil, "push", remote, ref+":"+ref); err != nil {
+		return fmt.Errorf("push: %w", err)
+	}
+	return nil
+}
+
+// Diff returns the changes on taskID's branch since it was created, limited to
+// paths when any are given. A flow without a branch yet has an empty diff.
+func (m *Manager) Diff(ctx context.Context, taskID string, paths ...string) (*Diff, error) {
+	state, err := m.TaskRepo.GetByID(ctx, m.DB, taskID)
+	if err != nil {
+		return nil, err
+	}
+	dir := m.dir(*state)
+	d := &Diff{TaskID: taskID, Branch: m.Branch(taskID), Files: []FileChange{}}
+
+	base, err := m.git(ctx, dir, nil, "rev-parse", "--verify", "--quiet", baseRef(taskID))
+	if err != nil {
+		return d, nil
+	}
+	head, err := m.git(ctx, dir, nil, "rev-parse", "--verify", "refs/heads/"+d.Branch)
+	if err != nil {
+		return nil, fmt.Errorf("resolve branch: %w", err)
+	}
+	d.Base, d.Head = base, head
+
+	args := append([]string{base, head, "--"}, paths...)
+	status, err := m.git(ctx, dir, nil, append([]string{"diff", "--name-status", "--no-renames"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	numstat, err := m.git(ctx, dir, nil, append([]string{"diff", "--numstat", "--no-renames"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := m.git(ctx, dir, nil, append([]string{"diff", "--no-renames"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string][2]int)
+	for _, line := range lines(numstat) {
+		f := strings.SplitN(line, "\t", 3)
+		if len(f) == 3 {
+			add, _ := strconv.Atoi(f[0]) // "-" for binary files
+			del, _ := strconv.Atoi(f[1])
+			counts[f[2]] = [2]int{add, del}
+		}
+	}
+	for _, line := range lines(status) {
+		f := strings.SplitN(line, "\t", 2)
+		if len(f) == 2 {
+			c := counts[f[1]]
+			d.Files = append(d.Files, FileChange{Path: f[1], Status: f[0], Additions: c[0], Deletions: c[1]})
+		}
+	}
+	d.Patch = patch
+	return d, nil
+}
+
+// dir returns the working tree a flow's changes live in.
+func (m *Manager) dir(state domain.FlowState) string {
+	if state.Workspace != "" {
+		return state.Workspace
+	}
+	return m.Repo
+}
+
+// git runs a git command in dir and returns its trimmed stdout.
+func (m *Manager) git(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func (m *Manager) audit(taskID, action, severity string, detail map[string]string) {
+	data, _ := json.Marshal(detail)
+	now := time.Now()
+	_ = m.AuditRepo.Record(context.Background(), m.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-git-%d", now.UnixNano()),
+		TaskID:       taskID,
+		Category:     "git",
+		Actor:        "system",
+		Action:       action,
+		DecisionJSON: string(data),
+		Severity:     severity,
+		CreatedAt:    now.Unix(),
+	})
+}
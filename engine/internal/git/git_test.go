@@ -0,0 +1,109 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// setup creates a repository with one commit and a flow "t1" working in it.
+func setup(t *testing.T) (*Manager, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "main.go"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	opts := workflow.FlowOptions{Workspace: repo}
+	if err := workflow.NewEngine(db).StartFlowWithOptions(context.Background(), "t1", 10, opts); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	return New(db, repo), repo
+}
+
+func TestCommitIntent_AndDiff(t *testing.T) {
+	m, repo := setup(t)
+	ctx := context.Background()
+
+	d, err := m.Diff(ctx, "t1")
+	if err != nil {
+		t.Fatalf("Diff before branch: %v", err)
+	}
+	if len(d.Files) != 0 || d.Head != "" {
+		t.Fatalf("diff before any commit = %+v, want empty", d)
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	intent := domain.Intent{IntentID: "int-1", TaskID: "t1", WorkerID: "w1", TargetFile: "main.go", Operation: "write"}
+	if err := m.CommitIntent(ctx, intent); err != nil {
+		t.Fatalf("CommitIntent: %v", err)
+	}
+
+	d, err = m.Diff(ctx, "t1")
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if d.Branch != "threebody/t1" || d.Base == d.Head {
+		t.Fatalf("diff = %+v, want a commit on threebody/t1", d)
+	}
+	if len(d.Files) != 1 || d.Files[0] != (FileChange{Path: "main.go", Status: "M", Additions: 2}) {
+		t.Errorf("Files = %+v", d.Files)
+	}
+	if !strings.Contains(d.Patch, "+func main() {}") {
+		t.Errorf("Patch missing change:\n%s", d.Patch)
+	}
+
+	msg, err := m.git(ctx, repo, nil, "log", "-1", "--format=%B", "threebody/t1")
+	if err != nil {
+		t.Fatalf("git log: %v", err)
+	}
+	if !strings.Contains(msg, "Intent: int-1") || !strings.Contains(msg, "Worker: w1") {
+		t.Errorf("commit message = %q, want intent and worker ids", msg)
+	}
+
+	// The checked-out branch is untouched.
+	if head, _ := m.git(ctx, repo, nil, "rev-parse", "HEAD"); head != d.Base {
+		t.Errorf("HEAD moved to %s, want %s", head, d.Base)
+	}
+
+	// Re-committing an unchanged file adds nothing.
+	if err := m.CommitIntent(ctx, intent); err != nil {
+		t.Fatalf("second CommitIntent: %v", err)
+	}
+	if again, _ := m.Diff(ctx, "t1"); again.Head != d.Head {
+		t.Errorf("unchanged commit moved branch from %s to %s", d.Head, again.Head)
+	}
+}
+
+func TestDiff_FlowNotFound(t *testing.T) {
+	m, _ := setup(t)
+	if _, err := m.Diff(context.Background(), "missing"); err != domain.ErrFlowNotFound {
+		t.Fatalf("Diff error = %v, want ErrFlowNotFound", err)
+	}
+}
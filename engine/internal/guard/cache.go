@@ -0,0 +1,98 @@
+package guard
+
+import (
+	"context"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+)
+
+// flowSnapshot is the state the budget and round checks read for a task,
+// cached for up to CacheTTL.
+type flowSnapshot struct {
+	state    domain.FlowState
+	reviewed int
+	loaded   time.Time
+}
+
+// CacheStats counts snapshot lookups served from the cache and from the
+// database.
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// CacheStats returns the snapshot cache counters.
+func (g *Guard) CacheStats() CacheStats {
+	return CacheStats{Hits: g.hits.Load(), Misses: g.misses.Load()}
+}
+
+// Watch invalidates cached snapshots when bus announces a change to their
+// task, until ctx is done. Signals dropped by a full subscriber are covered
+// by CacheTTL.
+func (g *Guard) Watch(ctx context.Context, bus *eventbus.Bus) {
+	ch, unsubscribe := bus.Subscribe(64)
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-ch:
+				g.Invalidate(sig.TaskID)
+			}
+		}
+	}()
+}
+
+// Invalidate drops the cached snapshot of taskID.
+func (g *Guard) Invalidate(taskID string) {
+	g.cacheMu.Lock()
+	delete(g.snapshots, taskID)
+	g.cacheGen++
+	g.cacheMu.Unlock()
+}
+
+// snapshot returns the task's state and reviewed round count, from the
+// cache when CacheTTL is set and the entry is younger than it.
+func (g *Guard) snapshot(ctx context.Context, taskID string) (*flowSnapshot, error) {
+	now := g.Clock.Now()
+	var gen uint64
+	if g.CacheTTL > 0 {
+		g.cacheMu.Lock()
+		snap, ok := g.snapshots[taskID]
+		gen = g.cacheGen
+		g.cacheMu.Unlock()
+		if ok && now.Sub(snap.loaded) < g.CacheTTL {
+			g.hits.Add(1)
+			return snap, nil
+		}
+	}
+	g.misses.Add(1)
+
+	state, err := g.TaskRepo.GetByID(ctx, g.DB, taskID)
+	if err != nil {
+		return nil, err
+	}
+	rounds, err := g.RoundRepo.ListByTask(ctx, g.DB, taskID)
+	if err != nil {
+		return nil, err
+	}
+	snap := &flowSnapshot{state: *state, reviewed: state.Round, loaded: now}
+	if len(rounds) > 0 {
+		if snap.reviewed, err = g.RoundRepo.CountReviewed(ctx, g.DB, taskID); err != nil {
+			return nil, err
+		}
+	}
+	if g.CacheTTL > 0 {
+		// An invalidation during the load may have announced a write the
+		// load missed, so the result is only cached if none arrived.
+		g.cacheMu.Lock()
+		if g.cacheGen == gen {
+			g.snapshots[taskID] = snap
+		}
+		g.cacheMu.Unlock()
+	}
+	return snap, nil
+}
@@ -4,126 +4,182 @@ import (
 	"context"
 	"database/sql"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
 	"github.com/anthropics/three-body-engine/internal/store"
 	"github.com/anthropics/three-body-engine/internal/team"
 	"github.com/anthropics/three-body-engine/internal/workflow"
 )
 
-// GuardConfig holds rate and round limits.
+// GuardConfig holds rate and round limits. Each task's rate limit is a
+// token bucket refilled at RateLimitPerMinute that holds at most RateBurst
+// tokens (default RateLimitPerMinute); a non-positive rate disables it.
+// Operations adds a limit per task for an operation class, and Providers a
+// limit per provider shared by all tasks; both are taken by CheckRate.
+// Adaptive, if set, slows a task's own limits, the task and operation
+// limits, as its budget runs down (see RateStep).
+// Rules, if set, returns the names of the rules CheckAll runs for a task, in
+// order; nil from it, or a nil Rules, runs every registered rule.
 type GuardConfig struct {
 	MaxRounds          int
 	RateLimitPerMinute int
+	RateBurst          int
+	Operations         map[string]RateLimit
+	Providers          map[string]RateLimit
+	Adaptive           []RateStep
+	Rules              func(taskID string) []string
 }
 
-// Guard coordinates budget, permission, rate, and round checks.
+// Guard runs each guarded action through a chain of rules: by default the
+// budget, permission, rate, and round checks, then any registered with
+// Register.
 type Guard struct {
-	Governor *workflow.BudgetGovernor
-	Broker   *team.PermissionBroker
-	Config   GuardConfig
-	TaskRepo *store.TaskRepo
-	DB       *sql.DB
-
-	mu         sync.Mutex
-	rateCounts map[string]*rateBucket
-}
-
-type rateBucket struct {
-	count       int
-	windowStart int64
+	Governor  *workflow.BudgetGovernor
+	Broker    *team.PermissionBroker
+	Config    GuardConfig
+	TaskRepo  *store.TaskRepo
+	RoundRepo *store.ReviewRoundRepo
+	DB        *sql.DB
+	// RateRepo, if set, persists rate buckets so limits survive a restart.
+	RateRepo *store.RateBucketRepo
+	// CacheTTL, if positive, caches each task's state and reviewed rounds
+	// for the budget and round checks this long. Watch drops entries early
+	// when the task changes.
+	CacheTTL time.Duration
+	// Clock times rate windows and cache entries.
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+
+	cacheMu      sync.Mutex
+	snapshots    map[string]*flowSnapshot
+	cacheGen     uint64
+	hits, misses atomic.Int64
+
+	rulesMu sync.RWMutex
+	rules   []Rule
 }
 
 // NewGuard creates a Guard with the given dependencies.
 func NewGuard(db *sql.DB, gov *workflow.BudgetGovernor, broker *team.PermissionBroker, cfg GuardConfig) *Guard {
-	return &Guard{
-		Governor:   gov,
-		Broker:     broker,
-		Config:     cfg,
-		TaskRepo:   &store.TaskRepo{},
-		DB:         db,
-		rateCounts: make(map[string]*rateBucket),
+	g := &Guard{
+		Governor:  gov,
+		Broker:    broker,
+		Config:    cfg,
+		TaskRepo:  &store.TaskRepo{},
+		RoundRepo: &store.ReviewRoundRepo{},
+		DB:        db,
+		buckets:   make(map[string]*tokenBucket),
+		Clock:     clock.System,
+		snapshots: make(map[string]*flowSnapshot),
 	}
+	g.rules = g.builtinRules()
+	return g
+}
+
+// SetConfig replaces the rate and round limits at runtime.
+func (g *Guard) SetConfig(cfg GuardConfig) {
+	g.mu.Lock()
+	g.Config = cfg
+	g.mu.Unlock()
 }
 
-// CheckAll runs all checks in order: budget, permission, rate limit, rounds.
-// It short-circuits on the first error.
+// CheckAll runs the task's rule chain, by default budget, permission, rate
+// limit, rounds. It short-circuits on the first error. Every rule sees one
+// snapshot of the task.
 func (g *Guard) CheckAll(ctx context.Context, taskID, path, command string, sheet *domain.CapabilitySheet) error {
-	action, err := g.CheckBudget(ctx, taskID)
+	chain, err := g.chain(taskID)
 	if err != nil {
 		return err
 	}
-	if action == domain.CostHalt {
-		return domain.ErrBudgetExceeded
-	}
-
-	allowed, err := g.Broker.CheckPermission(ctx, sheet, path, command)
+	snap, err := g.snapshot(ctx, taskID)
 	if err != nil {
 		return err
 	}
-	if !allowed {
-		return domain.ErrPermissionDenied
+	req := &Request{
+		TaskID:         taskID,
+		Path:           path,
+		Command:        command,
+		Sheet:          sheet,
+		State:          snap.state,
+		ReviewedRounds: snap.reviewed,
 	}
-
-	if err := g.CheckRateLimit(taskID); err != nil {
-		return err
+	for _, rule := range chain {
+		if err := rule.Check(ctx, req); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	if err := g.CheckRounds(ctx, taskID); err != nil {
-		return err
+// Evaluate runs the rule chain CheckAll would on the request as a dry run
+// and returns every rule's verdict, rather than stopping at the first
+// refusal. Nothing is consumed: the rate rule only looks at the bucket.
+func (g *Guard) Evaluate(ctx context.Context, taskID, path, command string, sheet *domain.CapabilitySheet) ([]RuleResult, error) {
+	chain, err := g.chain(taskID)
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	snap, err := g.snapshot(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	req := &Request{
+		TaskID:         taskID,
+		Path:           path,
+		Command:        command,
+		Sheet:          sheet,
+		State:          snap.state,
+		ReviewedRounds: snap.reviewed,
+		DryRun:         true,
+	}
+	results := make([]RuleResult, 0, len(chain))
+	for _, rule := range chain {
+		res := RuleResult{Rule: rule.Name(), Allowed: true}
+		if err := rule.Check(ctx, req); err != nil {
+			res.Allowed = false
+			res.Error = err.Error()
+		}
+		results = append(results, res)
+	}
+	return results, nil
 }
 
 // CheckBudget fetches the task state and delegates to the BudgetGovernor.
 // Returns ErrBudgetExceeded if the action is CostHalt.
 func (g *Guard) CheckBudget(ctx context.Context, taskID string) (domain.CostAction, error) {
-	state, err := g.TaskRepo.GetByID(ctx, g.DB, taskID)
+	snap, err := g.snapshot(ctx, taskID)
 	if err != nil {
 		return domain.CostContinue, err
 	}
-	return g.Governor.CheckBudget(ctx, *state)
+	return g.Governor.CheckBudget(ctx, snap.state)
 }
 
-// CheckRateLimit enforces a per-task sliding window rate limit.
-// The window is 60 seconds. If the count exceeds the configured limit,
-// ErrRateLimitExceeded is returned.
+// CheckRateLimit takes a token from the task's bucket. If it is empty, a
+// *domain.RateLimitError wrapping ErrRateLimitExceeded reports when the
+// next token is due.
 func (g *Guard) CheckRateLimit(taskID string) error {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	now := time.Now().Unix()
-	bucket, ok := g.rateCounts[taskID]
-	if !ok {
-		g.rateCounts[taskID] = &rateBucket{count: 1, windowStart: now}
-		return nil
-	}
-
-	if now-bucket.windowStart > 60 {
-		bucket.count = 1
-		bucket.windowStart = now
-		return nil
-	}
-
-	if bucket.count >= g.Config.RateLimitPerMinute {
-		return domain.ErrRateLimitExceeded
+	ctx := context.Background()
+	factor, err := g.rateFactor(ctx, taskID)
+	if err != nil {
+		return err
 	}
-
-	bucket.count++
-	return nil
+	return g.checkRate(ctx, taskID, factor)
 }
 
-// CheckRounds reads the task's FlowState and compares the current round
-// against the configured maximum. Returns ErrMaxRoundsExceeded if exceeded.
+// CheckRounds compares the review cycles a task has been sent back from
+// against the configured maximum: rounds ended by a rollback or rework count
+// only if reviewers submitted score cards in them. Flows without recorded
+// rounds fall back to the FlowState round counter. Returns
+// ErrMaxRoundsExceeded if exceeded.
 func (g *Guard) CheckRounds(ctx context.Context, taskID string) error {
-	state, err := g.TaskRepo.GetByID(ctx, g.DB, taskID)
+	snap, err := g.snapshot(ctx, taskID)
 	if err != nil {
 		return err
 	}
-	if state.Round >= g.Config.MaxRounds {
-		return domain.ErrMaxRoundsExceeded
-	}
-	return nil
+	return g.roundsRule(ctx, &Request{TaskID: taskID, State: snap.state, ReviewedRounds: snap.reviewed})
 }
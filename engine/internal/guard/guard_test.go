@@ -2,20 +2,22 @@ package guard
 
 import (
 	"context"
-	"path/filepath"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
 	"github.com/anthropics/three-body-engine/internal/store"
 	"github.com/anthropics/three-body-engine/internal/team"
 	"github.com/anthropics/three-body-engine/internal/workflow"
 )
 
 // setupGuard creates a DB, task, and Guard for testing.
-func setupGuard(t *testing.T, round int, budgetUsed, budgetCap float64) *Guard {
+func setupGuard(t testing.TB, round int, budgetUsed, budgetCap float64) *Guard {
 	t.Helper()
-	dir := t.TempDir()
-	db, err := store.NewDB(filepath.Join(dir, "test.db"))
+	db, err := store.NewDB(store.MemoryPath)
 	if err != nil {
 		t.Fatalf("NewDB: %v", err)
 	}
@@ -100,7 +102,7 @@ func TestCheckAll_RateLimitExceeded(t *testing.T) {
 
 	// Next call should hit rate limit.
 	err := g.CheckAll(ctx, "task-1", "/workspace/main.go", "read", sheet)
-	if err != domain.ErrRateLimitExceeded {
+	if !errors.Is(err, domain.ErrRateLimitExceeded) {
 		t.Fatalf("expected ErrRateLimitExceeded, got %v", err)
 	}
 }
@@ -157,28 +159,290 @@ func TestCheckRateLimit_WithinLimit(t *testing.T) {
 	}
 }
 
-func TestCheckRateLimit_WindowResets(t *testing.T) {
+func TestCheckRateLimit_Refills(t *testing.T) {
 	g := setupGuard(t, 0, 1.0, 10.0)
+	clk := clock.NewFake(time.Unix(1000, 0))
+	g.Clock = clk
 
-	// Fill the bucket up to the limit.
+	// Drain the bucket, which starts full at the limit.
 	for i := 0; i < 5; i++ {
 		if err := g.CheckRateLimit("task-1"); err != nil {
 			t.Fatalf("CheckRateLimit iteration %d: %v", i, err)
 		}
 	}
 
-	// Should be rate limited now.
-	if err := g.CheckRateLimit("task-1"); err != domain.ErrRateLimitExceeded {
-		t.Fatalf("expected ErrRateLimitExceeded, got %v", err)
+	// Should be rate limited now, with a token due in 12s (5 per minute).
+	err := g.CheckRateLimit("task-1")
+	var rateErr *domain.RateLimitError
+	if !errors.As(err, &rateErr) || !errors.Is(err, domain.ErrRateLimitExceeded) {
+		t.Fatalf("expected RateLimitError, got %v", err)
+	}
+	if rateErr.RetryAfter != 12*time.Second || rateErr.RetryAfterSeconds() != 12 {
+		t.Errorf("RetryAfter = %v, want 12s", rateErr.RetryAfter)
+	}
+
+	// One token accrues after 12s, not a whole window.
+	clk.Advance(12 * time.Second)
+	if err := g.CheckRateLimit("task-1"); err != nil {
+		t.Fatalf("CheckRateLimit after refill: %v", err)
+	}
+	if err := g.CheckRateLimit("task-1"); err == nil {
+		t.Fatal("expected the refilled token to be used up")
+	}
+}
+
+func TestCheckRateLimit_Burst(t *testing.T) {
+	g := setupGuard(t, 0, 1.0, 10.0)
+	g.Config = GuardConfig{RateLimitPerMinute: 60, RateBurst: 2}
+	clk := clock.NewFake(time.Unix(1000, 0))
+	g.Clock = clk
+
+	for i := 0; i < 2; i++ {
+		if err := g.CheckRateLimit("task-1"); err != nil {
+			t.Fatalf("CheckRateLimit iteration %d: %v", i, err)
+		}
+	}
+	if err := g.CheckRateLimit("task-1"); err == nil {
+		t.Fatal("expected burst of 2 to be exhausted")
+	}
+
+	// An idle hour refills only up to the burst.
+	clk.Advance(time.Hour)
+	for i := 0; i < 2; i++ {
+		if err := g.CheckRateLimit("task-1"); err != nil {
+			t.Fatalf("CheckRateLimit after idle %d: %v", i, err)
+		}
+	}
+	if err := g.CheckRateLimit("task-1"); err == nil {
+		t.Fatal("expected bucket capped at burst")
+	}
+}
+
+func TestCheckRateLimit_Persisted(t *testing.T) {
+	g := setupGuard(t, 0, 1.0, 10.0)
+	g.RateRepo = &store.RateBucketRepo{}
+	clk := clock.NewFake(time.Unix(1000, 0))
+	g.Clock = clk
+	for i := 0; i < 5; i++ {
+		if err := g.CheckRateLimit("task-1"); err != nil {
+			t.Fatalf("CheckRateLimit iteration %d: %v", i, err)
+		}
+	}
+
+	// A new guard over the same database, as after a restart, sees the
+	// drained bucket.
+	restarted := NewGuard(g.DB, g.Governor, g.Broker, g.Config)
+	restarted.RateRepo = &store.RateBucketRepo{}
+	restarted.Clock = g.Clock
+	if err := restarted.CheckRateLimit("task-1"); !errors.Is(err, domain.ErrRateLimitExceeded) {
+		t.Fatalf("expected ErrRateLimitExceeded after restart, got %v", err)
+	}
+}
+
+func TestCheckRateLimit_Disabled(t *testing.T) {
+	g := setupGuard(t, 0, 1.0, 10.0)
+	g.Config.RateLimitPerMinute = 0
+	for i := 0; i < 100; i++ {
+		if err := g.CheckRateLimit("task-1"); err != nil {
+			t.Fatalf("CheckRateLimit iteration %d: %v", i, err)
+		}
 	}
+}
 
-	// Simulate window reset by moving windowStart back.
-	g.mu.Lock()
-	g.rateCounts["task-1"].windowStart -= 61
-	g.mu.Unlock()
+func TestCheckRounds_CountsReviewedRounds(t *testing.T) {
+	g := setupGuard(t, 3, 1.0, 10.0)
+	ctx := context.Background()
 
-	// After window reset, should succeed again.
+	// Three rounds were sent back, but only round 1 had been reviewed.
+	tx, err := g.DB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	for i := 0; i <= 3; i++ {
+		if err := g.RoundRepo.StartTx(ctx, tx, domain.ReviewRound{TaskID: "task-1", Round: i, StartedAt: int64(i)}); err != nil {
+			t.Fatalf("StartTx: %v", err)
+		}
+		if i < 3 {
+			if err := g.RoundRepo.EndTx(ctx, tx, "task-1", i, int64(i), "rollback"); err != nil {
+				t.Fatalf("EndTx: %v", err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	cards := &store.ScoreCardRepo{}
+	if err := cards.Create(ctx, g.DB, domain.ScoreCard{ReviewID: "rev-1", TaskID: "task-1", Reviewer: "a", Round: 1}); err != nil {
+		t.Fatalf("Create card: %v", err)
+	}
+	if err := g.CheckRounds(ctx, "task-1"); err != nil {
+		t.Fatalf("CheckRounds with 1 reviewed round: %v", err)
+	}
+
+	g.SetConfig(GuardConfig{MaxRounds: 1, RateLimitPerMinute: 5})
+	if err := g.CheckRounds(ctx, "task-1"); err != domain.ErrMaxRoundsExceeded {
+		t.Errorf("CheckRounds = %v, want ErrMaxRoundsExceeded", err)
+	}
+}
+
+func TestCheckRate_OperationsAndProviders(t *testing.T) {
+	g := setupGuard(t, 0, 1.0, 10.0)
+	g.Config.Operations = map[string]RateLimit{OpFile: {PerMinute: 60, Burst: 1}, OpSession: {PerMinute: 60, Burst: 5}}
+	g.Config.Providers = map[string]RateLimit{"gemini": {PerMinute: 60, Burst: 1}}
+	clk := clock.NewFake(time.Unix(1000, 0))
+	g.Clock = clk
+	ctx := context.Background()
+
+	if err := g.CheckRate(ctx, "task-1", "", OpFile); err != nil {
+		t.Fatalf("first file op: %v", err)
+	}
+	if err := g.CheckRate(ctx, "task-1", "", OpFile); !errors.Is(err, domain.ErrRateLimitExceeded) {
+		t.Fatalf("second file op = %v, want ErrRateLimitExceeded", err)
+	}
+	// Operation buckets are per task; exec has no limit.
+	if err := g.CheckRate(ctx, "task-2", "", OpFile); err != nil {
+		t.Fatalf("other task's file op: %v", err)
+	}
+	if err := g.CheckRate(ctx, "task-1", "", OpExec); err != nil {
+		t.Fatalf("unlimited exec: %v", err)
+	}
+
+	// The provider bucket is shared across tasks. A refused start takes no
+	// token from the session bucket either.
+	if err := g.CheckRate(ctx, "task-1", "gemini", OpSession); err != nil {
+		t.Fatalf("first gemini start: %v", err)
+	}
+	if err := g.CheckRate(ctx, "task-2", "gemini", OpSession); !errors.Is(err, domain.ErrRateLimitExceeded) {
+		t.Fatalf("second gemini start = %v, want ErrRateLimitExceeded", err)
+	}
+	if err := g.CheckRate(ctx, "task-2", "claude", OpSession); err != nil {
+		t.Fatalf("claude start: %v", err)
+	}
+
+	limits, err := g.Limits(ctx, "task-2")
+	if err != nil {
+		t.Fatalf("Limits: %v", err)
+	}
+	want := []LimitState{
+		{Scope: "task", PerMinute: 5, Burst: 5, Tokens: 5},
+		{Scope: "operation", Name: OpFile, PerMinute: 60, Burst: 1, Tokens: 0, RetryAfterMS: 1000},
+		{Scope: "operation", Name: OpSession, PerMinute: 60, Burst: 5, Tokens: 4},
+		{Scope: "provider", Name: "gemini", PerMinute: 60, Burst: 1, Tokens: 0, RetryAfterMS: 1000},
+	}
+	if len(limits) != len(want) {
+		t.Fatalf("Limits = %+v", limits)
+	}
+	for i := range want {
+		if limits[i] != want[i] {
+			t.Errorf("Limits[%d] = %+v, want %+v", i, limits[i], want[i])
+		}
+	}
+}
+
+func TestCheckRate_AdaptsToBudget(t *testing.T) {
+	g := setupGuard(t, 0, 8.5, 10.0)
+	g.Config = GuardConfig{
+		RateLimitPerMinute: 60,
+		RateBurst:          1,
+		Operations:         map[string]RateLimit{OpExec: {PerMinute: 30, Burst: 1}},
+		Providers:          map[string]RateLimit{"gemini": {PerMinute: 60, Burst: 1}},
+		Adaptive:           []RateStep{{BudgetRatio: 0.9, Factor: 0.25}, {BudgetRatio: 0.8, Factor: 0.5}},
+	}
+	clk := clock.NewFake(time.Unix(1000, 0))
+	g.Clock = clk
+	ctx := context.Background()
+
+	// Past 80% of the budget the task's own limits refill at half rate.
 	if err := g.CheckRateLimit("task-1"); err != nil {
-		t.Fatalf("CheckRateLimit after window reset: %v", err)
+		t.Fatalf("first check: %v", err)
+	}
+	var rateErr *domain.RateLimitError
+	if err := g.CheckRateLimit("task-1"); !errors.As(err, &rateErr) || rateErr.RetryAfter != 2*time.Second {
+		t.Fatalf("second check = %v, want a 2s wait", err)
+	}
+	limits, err := g.Limits(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("Limits: %v", err)
+	}
+	if limits[0].PerMinute != 30 || limits[0].BasePerMinute != 60 ||
+		limits[1].PerMinute != 15 || limits[1].BasePerMinute != 30 ||
+		limits[2].PerMinute != 60 || limits[2].BasePerMinute != 0 {
+		t.Errorf("Limits = %+v, want the task and exec limits halved", limits)
 	}
+
+	// The highest step reached applies; unknown tasks are not slowed.
+	g.Config.Adaptive = []RateStep{{BudgetRatio: 0.8, Factor: 0.5}, {BudgetRatio: 0.85, Factor: 0.1}}
+	if limits, _ := g.Limits(ctx, "task-1"); limits[0].PerMinute != 6 {
+		t.Errorf("task rate past 85%% = %d, want 6", limits[0].PerMinute)
+	}
+	if limits, _ := g.Limits(ctx, "task-2"); limits[0].PerMinute != 60 || limits[0].BasePerMinute != 0 {
+		t.Errorf("unknown task's rate = %+v, want 60", limits[0])
+	}
+}
+
+func TestSnapshotCache(t *testing.T) {
+	g := setupGuard(t, 0, 1.0, 10.0)
+	g.CacheTTL = time.Minute
+	clk := clock.NewFake(time.Unix(1000, 0))
+	g.Clock = clk
+	ctx := context.Background()
+
+	// CheckAll reads the task once for both the budget and round checks,
+	// and later checks are served from the cache.
+	for i := 0; i < 3; i++ {
+		if err := g.CheckAll(ctx, "task-1", "/workspace/main.go", "read", defaultSheet()); err != nil {
+			t.Fatalf("CheckAll: %v", err)
+		}
+	}
+	if stats := g.CacheStats(); stats.Misses != 1 || stats.Hits != 2 {
+		t.Errorf("CacheStats = %+v, want 1 miss and 2 hits", stats)
+	}
+
+	// Recording usage announces the write, and the watcher drops the
+	// stale snapshot.
+	bus := eventbus.New()
+	g.Governor.Bus = bus
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g.Watch(watchCtx, bus)
+	if _, err := g.Governor.RecordUsage(ctx, "task-1", domain.CostDelta{AmountUSD: 9.5}); err != nil {
+		t.Fatalf("RecordUsage: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		action, err := g.CheckBudget(ctx, "task-1")
+		if err != nil {
+			t.Fatalf("CheckBudget: %v", err)
+		}
+		if action == domain.CostHalt {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("action = %q after recorded usage, want halt", action)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Entries also expire after CacheTTL without a signal.
+	misses := g.CacheStats().Misses
+	clk.Advance(time.Minute)
+	if _, err := g.CheckBudget(ctx, "task-1"); err != nil {
+		t.Fatalf("CheckBudget: %v", err)
+	}
+	if g.CacheStats().Misses != misses+1 {
+		t.Errorf("expired entry served from cache: %+v", g.CacheStats())
+	}
+}
+
+func BenchmarkGuard_CheckBudget(b *testing.B) {
+	g := setupGuard(b, 0, 1.0, 10.0)
+	ctx := context.Background()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := g.CheckBudget(ctx, "task-1"); err != nil {
+				b.Errorf("CheckBudget: %v", err)
+			}
+		}
+	})
 }
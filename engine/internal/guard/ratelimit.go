@@ -0,0 +1,322 @@
+package guard
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// Operation classes that may carry their own rate limit.
+const (
+	OpSession = "session"
+	OpFile    = "file"
+	OpExec    = "exec"
+)
+
+// Operations lists the operation classes, for validation.
+var Operations = []string{OpSession, OpFile, OpExec}
+
+// RateLimit is a token bucket refilled at PerMinute that holds at most
+// Burst tokens (default PerMinute).
+type RateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+// burst returns the bucket size, defaulting to PerMinute.
+func (l RateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.PerMinute
+}
+
+// scaled returns l refilling at factor of its rate, rounded up so a slowed
+// limit never stops, with its bucket size unchanged.
+func (l RateLimit) scaled(factor float64) RateLimit {
+	if factor >= 1 || l.PerMinute <= 0 {
+		return l
+	}
+	return RateLimit{PerMinute: int(math.Ceil(float64(l.PerMinute) * factor)), Burst: l.burst()}
+}
+
+// RateStep slows a task's limits once its spend reaches BudgetRatio of its
+// cap: they refill at Factor of their configured rate. Of the steps a task
+// has reached, the one with the highest BudgetRatio applies.
+type RateStep struct {
+	BudgetRatio float64
+	Factor      float64
+}
+
+// LimitState reports one rate limit and its bucket. Scope is "task",
+// "operation" (Name is the class, counted per task), or "provider" (Name is
+// the provider, counted across all tasks).
+type LimitState struct {
+	Scope string `json:"scope"`
+	Name  string `json:"name,omitempty"`
+	// PerMinute is the rate in effect. When the task's budget has slowed
+	// the limit, BasePerMinute is the configured rate.
+	PerMinute     int     `json:"perMinute"`
+	BasePerMinute int     `json:"basePerMinute,omitempty"`
+	Burst         int     `json:"burst"`
+	Tokens        float64 `json:"tokens"`
+	// RetryAfterMS is how long until a token is available; zero if one is.
+	RetryAfterMS int64 `json:"retryAfterMs"`
+}
+
+// tokenBucket holds the tokens left at updated. Tokens accrue continuously,
+// so a full bucket allows a burst and an empty one paces requests evenly
+// instead of resetting at a window edge.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens accrued since updated under limit, capped at its
+// burst, and returns how long until a whole token is available.
+func (b *tokenBucket) refill(now time.Time, limit RateLimit) time.Duration {
+	rate := float64(limit.PerMinute) / 60
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * rate
+	}
+	if b.tokens > float64(limit.burst()) {
+		b.tokens = float64(limit.burst())
+	}
+	b.updated = now
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// limitedBucket pairs a bucket key with the limit that applies to it.
+type limitedBucket struct {
+	key   string
+	limit RateLimit
+}
+
+// taskKey, operationKey, and providerKey name the buckets of each scope.
+// Task buckets keep the bare task ID.
+func taskKey(taskID string) string          { return taskID }
+func operationKey(taskID, op string) string { return taskID + "/op:" + op }
+func providerKey(provider string) string    { return "provider:" + provider }
+
+// rateFactor returns the factor taskID's budget sets on its limits, loading
+// the task only when adaptive steps are configured. Unknown tasks are not
+// slowed.
+func (g *Guard) rateFactor(ctx context.Context, taskID string) (float64, error) {
+	g.mu.Lock()
+	adaptive := len(g.Config.Adaptive) > 0
+	g.mu.Unlock()
+	if !adaptive {
+		return 1, nil
+	}
+	snap, err := g.snapshot(ctx, taskID)
+	if err == domain.ErrFlowNotFound {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return g.stateFactor(snap.state), nil
+}
+
+// stateFactor returns the factor of the highest adaptive step state's spend
+// has reached, or 1.
+func (g *Guard) stateFactor(state domain.FlowState) float64 {
+	if state.BudgetCapUSD <= 0 {
+		return 1
+	}
+	g.mu.Lock()
+	steps := g.Config.Adaptive
+	g.mu.Unlock()
+
+	ratio := state.BudgetUsedUSD / state.BudgetCapUSD
+	factor, reached := 1.0, -1.0
+	for _, s := range steps {
+		if ratio >= s.BudgetRatio && s.BudgetRatio > reached {
+			factor, reached = s.Factor, s.BudgetRatio
+		}
+	}
+	return factor
+}
+
+// checkRate takes a token from taskID's bucket, its limit slowed by factor.
+func (g *Guard) checkRate(ctx context.Context, taskID string, factor float64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	limit := RateLimit{PerMinute: g.Config.RateLimitPerMinute, Burst: g.Config.RateBurst}.scaled(factor)
+	return g.take(ctx, []limitedBucket{{taskKey(taskID), limit}})
+}
+
+// peekRate reports whether taskID's bucket holds a token, without taking
+// it or saving the bucket.
+func (g *Guard) peekRate(ctx context.Context, taskID string, factor float64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	limit := RateLimit{PerMinute: g.Config.RateLimitPerMinute, Burst: g.Config.RateBurst}.scaled(factor)
+	if limit.PerMinute <= 0 {
+		return nil
+	}
+	now := g.Clock.Now()
+	b, err := g.bucket(ctx, limitedBucket{taskKey(taskID), limit}, now)
+	if err != nil {
+		return err
+	}
+	if wait := b.refill(now, limit); wait > 0 {
+		return &domain.RateLimitError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// CheckRate takes a token from the buckets of op for taskID and of
+// provider, where those have limits configured. A token is taken from every
+// bucket or none: if any is empty, a *domain.RateLimitError reports the
+// longest wait. An empty op or provider is not limited. The op limit is
+// slowed by the task's budget; the provider limit, shared by all tasks, is
+// not.
+func (g *Guard) CheckRate(ctx context.Context, taskID string, provider domain.Provider, op string) error {
+	factor, err := g.rateFactor(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var buckets []limitedBucket
+	if l, ok := g.Config.Operations[op]; ok && op != "" {
+		buckets = append(buckets, limitedBucket{operationKey(taskID, op), l.scaled(factor)})
+	}
+	if l, ok := g.Config.Providers[string(provider)]; ok && provider != "" {
+		buckets = append(buckets, limitedBucket{providerKey(string(provider)), l})
+	}
+	return g.take(ctx, buckets)
+}
+
+// take refills buckets and, if each holds a token, takes one from each.
+// Buckets are loaded from RateRepo on first use and saved after every take
+// when it is set. The caller holds mu.
+func (g *Guard) take(ctx context.Context, buckets []limitedBucket) error {
+	now := g.Clock.Now()
+	var wait time.Duration
+	var active []limitedBucket
+	for _, lb := range buckets {
+		if lb.limit.PerMinute <= 0 {
+			continue
+		}
+		b, err := g.bucket(ctx, lb, now)
+		if err != nil {
+			return err
+		}
+		if w := b.refill(now, lb.limit); w > wait {
+			wait = w
+		}
+		active = append(active, lb)
+	}
+	if wait == 0 {
+		for _, lb := range active {
+			g.buckets[lb.key].tokens--
+		}
+	}
+	if g.RateRepo != nil {
+		for _, lb := range active {
+			b := g.buckets[lb.key]
+			if err := g.RateRepo.Put(ctx, g.DB, domain.RateBucket{
+				Key:         lb.key,
+				Tokens:      b.tokens,
+				UpdatedAtMS: b.updated.UnixMilli(),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	if wait > 0 {
+		return &domain.RateLimitError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// bucket returns the in-memory bucket for lb, loading it from RateRepo or
+// starting it full. The caller holds mu.
+func (g *Guard) bucket(ctx context.Context, lb limitedBucket, now time.Time) (*tokenBucket, error) {
+	if b, ok := g.buckets[lb.key]; ok {
+		return b, nil
+	}
+	b := &tokenBucket{tokens: float64(lb.limit.burst()), updated: now}
+	if g.RateRepo != nil {
+		stored, err := g.RateRepo.Get(ctx, g.DB, lb.key)
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			b = &tokenBucket{tokens: stored.Tokens, updated: time.UnixMilli(stored.UpdatedAtMS)}
+		}
+	}
+	g.buckets[lb.key] = b
+	return b, nil
+}
+
+// Limits reports the rate limits that apply to taskID, with the rate in
+// effect and the tokens in each bucket now: the task limit, then operation
+// and provider limits by name.
+func (g *Guard) Limits(ctx context.Context, taskID string) ([]LimitState, error) {
+	factor, err := g.rateFactor(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	type scoped struct {
+		scope, name string
+		base        int
+		limitedBucket
+	}
+	task := RateLimit{PerMinute: g.Config.RateLimitPerMinute, Burst: g.Config.RateBurst}
+	all := []scoped{{"task", "", task.PerMinute, limitedBucket{taskKey(taskID), task.scaled(factor)}}}
+	for _, op := range sortedKeys(g.Config.Operations) {
+		l := g.Config.Operations[op]
+		all = append(all, scoped{"operation", op, l.PerMinute, limitedBucket{operationKey(taskID, op), l.scaled(factor)}})
+	}
+	for _, p := range sortedKeys(g.Config.Providers) {
+		l := g.Config.Providers[p]
+		all = append(all, scoped{"provider", p, l.PerMinute, limitedBucket{providerKey(p), l}})
+	}
+
+	now := g.Clock.Now()
+	states := make([]LimitState, 0, len(all))
+	for _, s := range all {
+		if s.limit.PerMinute <= 0 {
+			continue
+		}
+		b, err := g.bucket(ctx, s.limitedBucket, now)
+		if err != nil {
+			return nil, err
+		}
+		wait := b.refill(now, s.limit)
+		state := LimitState{
+			Scope:        s.scope,
+			Name:         s.name,
+			PerMinute:    s.limit.PerMinute,
+			Burst:        s.limit.burst(),
+			Tokens:       b.tokens,
+			RetryAfterMS: wait.Milliseconds(),
+		}
+		if s.base != s.limit.PerMinute {
+			state.BasePerMinute = s.base
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func sortedKeys(m map[string]RateLimit) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,233 @@
+package guard
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// Names of the built-in rules, in their default order.
+const (
+	RuleBudget     = "budget"
+	RulePermission = "permission"
+	RuleRate       = "rate"
+	RuleRounds     = "rounds"
+)
+
+// Request is one action CheckAll evaluates. State and ReviewedRounds are
+// the task's snapshot, loaded once for every rule. DryRun is set by
+// Evaluate: a rule must then report its verdict without consuming or
+// recording anything. A dry run without Path and Command checks only the
+// rules that do not concern the action itself.
+type Request struct {
+	TaskID         string
+	Path           string
+	Command        string
+	Sheet          *domain.CapabilitySheet
+	State          domain.FlowState
+	ReviewedRounds int
+	DryRun         bool
+}
+
+// RuleResult is one rule's verdict on a request evaluated by Evaluate.
+type RuleResult struct {
+	Rule    string `json:"rule"`
+	Allowed bool   `json:"allowed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Rule is one check in the guard's chain. Check returns nil to let the
+// request through to the next rule, or the error that refuses it.
+type Rule interface {
+	Name() string
+	Check(ctx context.Context, req *Request) error
+}
+
+// RuleFunc adapts a function to Rule.
+func RuleFunc(name string, check func(ctx context.Context, req *Request) error) Rule {
+	return funcRule{name: name, check: check}
+}
+
+type funcRule struct {
+	name  string
+	check func(ctx context.Context, req *Request) error
+}
+
+func (r funcRule) Name() string                                  { return r.name }
+func (r funcRule) Check(ctx context.Context, req *Request) error { return r.check(ctx, req) }
+
+// builtinRules returns the built-in rules bound to g, in default order.
+func (g *Guard) builtinRules() []Rule {
+	return []Rule{
+		RuleFunc(RuleBudget, g.budgetRule),
+		RuleFunc(RulePermission, g.permissionRule),
+		RuleFunc(RuleRate, g.rateRule),
+		RuleFunc(RuleRounds, g.roundsRule),
+	}
+}
+
+// Register appends rule to the chain, after the built-in rules and those
+// registered before it. A rule with the name of a registered one replaces
+// it in place.
+func (g *Guard) Register(rule Rule) {
+	g.rulesMu.Lock()
+	defer g.rulesMu.Unlock()
+	for i, r := range g.rules {
+		if r.Name() == rule.Name() {
+			g.rules[i] = rule
+			return
+		}
+	}
+	g.rules = append(g.rules, rule)
+}
+
+// RuleNames returns the names of the registered rules in chain order.
+func (g *Guard) RuleNames() []string {
+	g.rulesMu.RLock()
+	defer g.rulesMu.RUnlock()
+	names := make([]string, len(g.rules))
+	for i, r := range g.rules {
+		names[i] = r.Name()
+	}
+	return names
+}
+
+// chain returns the rules that apply to taskID: those named by
+// Config.Rules in that order, or every registered rule.
+func (g *Guard) chain(taskID string) ([]Rule, error) {
+	g.mu.Lock()
+	ruleSet := g.Config.Rules
+	g.mu.Unlock()
+
+	g.rulesMu.RLock()
+	defer g.rulesMu.RUnlock()
+	if ruleSet == nil {
+		return append([]Rule(nil), g.rules...), nil
+	}
+	names := ruleSet(taskID)
+	if names == nil {
+		return append([]Rule(nil), g.rules...), nil
+	}
+	chain := make([]Rule, 0, len(names))
+	for _, name := range names {
+		rule := g.lookup(name)
+		if rule == nil {
+			return nil, fmt.Errorf("guard rule %q is not registered", name)
+		}
+		chain = append(chain, rule)
+	}
+	return chain, nil
+}
+
+// lookup returns the registered rule named name. The caller holds rulesMu.
+func (g *Guard) lookup(name string) Rule {
+	for _, r := range g.rules {
+		if r.Name() == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func (g *Guard) budgetRule(ctx context.Context, req *Request) error {
+	action, err := g.Governor.CheckBudget(ctx, req.State)
+	if err != nil {
+		return err
+	}
+	if action == domain.CostHalt {
+		return domain.ErrBudgetExceeded
+	}
+	return nil
+}
+
+func (g *Guard) permissionRule(ctx context.Context, req *Request) error {
+	if req.DryRun {
+		if req.Path == "" && req.Command == "" {
+			return nil
+		}
+		reason, err := g.Broker.DenialReason(req.Sheet, req.Path, req.Command)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			return domain.NewEngineError(domain.ErrPermissionDenied.Code, "permission denied: "+reason)
+		}
+		return nil
+	}
+	allowed, err := g.Broker.CheckPermission(ctx, req.Sheet, req.Path, req.Command)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return domain.ErrPermissionDenied
+	}
+	return nil
+}
+
+func (g *Guard) rateRule(ctx context.Context, req *Request) error {
+	factor := g.stateFactor(req.State)
+	if req.DryRun {
+		return g.peekRate(ctx, req.TaskID, factor)
+	}
+	return g.checkRate(ctx, req.TaskID, factor)
+}
+
+func (g *Guard) roundsRule(_ context.Context, req *Request) error {
+	g.mu.Lock()
+	maxRounds := g.Config.MaxRounds
+	g.mu.Unlock()
+	if req.ReviewedRounds >= maxRounds {
+		return domain.ErrMaxRoundsExceeded
+	}
+	return nil
+}
+
+// BusinessHours is a rule refusing actions outside a daily window, for
+// example to keep agents from running unattended overnight. Start and End
+// are offsets from midnight in Location (default UTC); a window with End
+// before Start spans midnight. Days, if set, lists the weekdays allowed.
+type BusinessHours struct {
+	Start, End time.Duration
+	Days       []time.Weekday
+	Location   *time.Location
+	// Now returns the current time; nil means time.Now.
+	Now func() time.Time
+}
+
+// Name implements Rule.
+func (b *BusinessHours) Name() string { return "business_hours" }
+
+// Check implements Rule.
+func (b *BusinessHours) Check(_ context.Context, _ *Request) error {
+	now := time.Now
+	if b.Now != nil {
+		now = b.Now
+	}
+	loc := b.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t := now().In(loc)
+	if len(b.Days) > 0 {
+		ok := false
+		for _, d := range b.Days {
+			ok = ok || d == t.Weekday()
+		}
+		if !ok {
+			return domain.NewEngineError(domain.ErrForbiddenOperation.Code,
+				fmt.Sprintf("outside business hours: %s is not a working day", t.Weekday()))
+		}
+	}
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	inside := offset >= b.Start && offset < b.End
+	if b.End < b.Start {
+		inside = offset >= b.Start || offset < b.End
+	}
+	if !inside {
+		return domain.NewEngineError(domain.ErrForbiddenOperation.Code,
+			fmt.Sprintf("outside business hours: %s", t.Format("15:04")))
+	}
+	return nil
+}
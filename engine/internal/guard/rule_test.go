@@ -0,0 +1,137 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestRegister_CustomRule(t *testing.T) {
+	g := setupGuard(t, 0, 1.0, 10.0)
+	errTooLarge := errors.New("file too large")
+	var seen *Request
+	g.Register(RuleFunc("file_size", func(_ context.Context, req *Request) error {
+		seen = req
+		if strings.HasSuffix(req.Path, ".iso") {
+			return errTooLarge
+		}
+		return nil
+	}))
+
+	want := []string{RuleBudget, RulePermission, RuleRate, RuleRounds, "file_size"}
+	if got := g.RuleNames(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("RuleNames = %v, want %v", got, want)
+	}
+
+	ctx := context.Background()
+	if err := g.CheckAll(ctx, "task-1", "/workspace/main.go", "read", defaultSheet()); err != nil {
+		t.Fatalf("CheckAll: %v", err)
+	}
+	if seen == nil || seen.State.TaskID != "task-1" || seen.State.BudgetCapUSD != 10.0 {
+		t.Errorf("rule saw %+v, want the task snapshot", seen)
+	}
+	if err := g.CheckAll(ctx, "task-1", "/workspace/disk.iso", "read", defaultSheet()); err != errTooLarge {
+		t.Errorf("CheckAll = %v, want the custom rule's error", err)
+	}
+}
+
+func TestCheckAll_PerTaskRules(t *testing.T) {
+	g := setupGuard(t, 0, 10.0, 10.0)
+	g.Config.Rules = func(taskID string) []string {
+		if taskID == "task-1" {
+			return []string{RulePermission, RuleRate}
+		}
+		return nil
+	}
+
+	// The budget rule is left out for task-1, so its exhausted budget does
+	// not refuse the action.
+	ctx := context.Background()
+	if err := g.CheckAll(ctx, "task-1", "/workspace/main.go", "read", defaultSheet()); err != nil {
+		t.Fatalf("CheckAll without budget rule: %v", err)
+	}
+	if err := g.CheckAll(ctx, "task-1", "/workspace/.env", "read", defaultSheet()); err != domain.ErrPermissionDenied {
+		t.Errorf("CheckAll = %v, want ErrPermissionDenied", err)
+	}
+
+	g.Config.Rules = func(string) []string { return []string{"missing"} }
+	if err := g.CheckAll(ctx, "task-1", "/workspace/main.go", "read", defaultSheet()); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("CheckAll = %v, want unregistered rule error", err)
+	}
+}
+
+func TestBusinessHours(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC) // Monday
+	rule := &BusinessHours{
+		Start: 9 * time.Hour,
+		End:   18 * time.Hour,
+		Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Now:   func() time.Time { return now },
+	}
+	ctx := context.Background()
+	for _, tc := range []struct {
+		at   time.Time
+		want bool
+	}{
+		{time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC), false},
+		{time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), true},
+		{time.Date(2026, 3, 2, 17, 59, 0, 0, time.UTC), true},
+		{time.Date(2026, 3, 2, 18, 0, 0, 0, time.UTC), false},
+		{time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC), false}, // Saturday
+	} {
+		now = tc.at
+		err := rule.Check(ctx, &Request{})
+		if (err == nil) != tc.want {
+			t.Errorf("Check at %v = %v, want allowed=%v", tc.at, err, tc.want)
+		}
+	}
+
+	// A window ending before it starts spans midnight.
+	night := &BusinessHours{Start: 22 * time.Hour, End: 6 * time.Hour, Now: func() time.Time { return now }}
+	now = time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC)
+	if err := night.Check(ctx, &Request{}); err != nil {
+		t.Errorf("overnight Check at 23:00 = %v", err)
+	}
+	now = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	if err := night.Check(ctx, &Request{}); err == nil {
+		t.Error("overnight Check at 12:00 should refuse")
+	}
+}
+
+func TestEvaluate_ReportsEveryRuleWithoutConsuming(t *testing.T) {
+	g := setupGuard(t, 5, 10.0, 10.0)
+	g.Config.RateLimitPerMinute = 1
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		results, err := g.Evaluate(ctx, "task-1", "/workspace/.env", "read", defaultSheet())
+		if err != nil {
+			t.Fatalf("Evaluate: %v", err)
+		}
+		allowed := map[string]bool{}
+		for _, r := range results {
+			allowed[r.Rule] = r.Allowed
+			if !r.Allowed && r.Error == "" {
+				t.Errorf("rule %s refused without an error", r.Rule)
+			}
+		}
+		want := map[string]bool{RuleBudget: false, RulePermission: false, RuleRate: true, RuleRounds: false}
+		if len(results) != len(want) {
+			t.Fatalf("results = %+v, want one per rule", results)
+		}
+		for name, ok := range want {
+			if allowed[name] != ok {
+				t.Errorf("evaluation %d: %s allowed = %v, want %v", i, name, allowed[name], ok)
+			}
+		}
+	}
+
+	// The single token is still there for a real check.
+	if err := g.CheckRateLimit("task-1"); err != nil {
+		t.Errorf("CheckRateLimit after dry runs: %v", err)
+	}
+}
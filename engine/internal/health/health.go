@@ -0,0 +1,103 @@
+// Package health reports whether the engine is ready to serve: its database
+// answers and is migrated, providers are configured, and the workspace disk
+// has room.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// Status values of a Report.
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Check is the outcome of one readiness check.
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Report is the outcome of every readiness check. Status is ok only if all
+// checks passed.
+type Report struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks"`
+}
+
+// Checker runs the readiness checks.
+type Checker struct {
+	DB *sql.DB
+	// Providers lists the registered providers.
+	Providers func() []domain.Provider
+	// Workspace is the directory whose disk must have MinFreeBytes free.
+	Workspace    string
+	MinFreeBytes uint64
+	// Timeout bounds the database checks (default 2s).
+	Timeout time.Duration
+}
+
+// NewChecker creates a Checker with the default timeout.
+func NewChecker(db *sql.DB, providers func() []domain.Provider, workspace string, minFreeBytes uint64) *Checker {
+	return &Checker{DB: db, Providers: providers, Workspace: workspace, MinFreeBytes: minFreeBytes, Timeout: 2 * time.Second}
+}
+
+// Ready runs every check and reports the results in a fixed order.
+func (c *Checker) Ready(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	checks := []Check{c.database(ctx), c.migrations(ctx), c.providers(), c.disk()}
+	report := Report{Status: StatusOK, Checks: checks}
+	for _, check := range checks {
+		if !check.OK {
+			report.Status = StatusUnavailable
+		}
+	}
+	return report
+}
+
+func (c *Checker) database(ctx context.Context) Check {
+	if err := c.DB.PingContext(ctx); err != nil {
+		return Check{Name: "database", Detail: err.Error()}
+	}
+	return Check{Name: "database", OK: true}
+}
+
+func (c *Checker) migrations(ctx context.Context) Check {
+	current, latest, err := store.SchemaVersion(ctx, c.DB)
+	switch {
+	case err != nil:
+		return Check{Name: "migrations", Detail: err.Error()}
+	case current != latest:
+		return Check{Name: "migrations", Detail: fmt.Sprintf("schema version %d, want %d", current, latest)}
+	}
+	return Check{Name: "migrations", OK: true, Detail: fmt.Sprintf("schema version %d", current)}
+}
+
+func (c *Checker) providers() Check {
+	if c.Providers == nil || len(c.Providers()) == 0 {
+		return Check{Name: "providers", Detail: "no providers registered"}
+	}
+	return Check{Name: "providers", OK: true, Detail: fmt.Sprintf("%d registered", len(c.Providers()))}
+}
+
+func (c *Checker) disk() Check {
+	free, err := freeBytes(c.Workspace)
+	if err != nil {
+		return Check{Name: "disk", Detail: err.Error()}
+	}
+	detail := fmt.Sprintf("%d MiB free in %s", free>>20, c.Workspace)
+	if free < c.MinFreeBytes {
+		return Check{Name: "disk", Detail: fmt.Sprintf("%s, want at least %d MiB", detail, c.MinFreeBytes>>20)}
+	}
+	return Check{Name: "disk", OK: true, Detail: detail}
+}
@@ -0,0 +1,50 @@
+package health
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+func TestChecker_Ready(t *testing.T) {
+	dir := t.TempDir()
+	db, err := store.NewDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	providers := []domain.Provider{"claude"}
+	c := NewChecker(db, func() []domain.Provider { return providers }, dir, 1)
+
+	report := c.Ready(context.Background())
+	if report.Status != StatusOK || len(report.Checks) != 4 {
+		t.Fatalf("report = %+v, want ok with 4 checks", report)
+	}
+	for _, check := range report.Checks {
+		if !check.OK {
+			t.Errorf("check %s failed: %s", check.Name, check.Detail)
+		}
+	}
+
+	providers = nil
+	c.MinFreeBytes = 1 << 62
+	failed := map[string]bool{}
+	report = c.Ready(context.Background())
+	for _, check := range report.Checks {
+		if !check.OK {
+			failed[check.Name] = true
+		}
+	}
+	if report.Status != StatusUnavailable || !failed["providers"] || !failed["disk"] || failed["database"] {
+		t.Errorf("report = %+v, want providers and disk failing", report)
+	}
+
+	db.Close()
+	report = c.Ready(context.Background())
+	if report.Status != StatusUnavailable || report.Checks[0].OK {
+		t.Errorf("after close = %+v, want database failing", report)
+	}
+}
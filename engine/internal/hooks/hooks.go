@@ -0,0 +1,306 @@
+// Package hooks runs operator-supplied hooks — webhooks, external commands,
+// or Go plugins — at points in a flow's life: phase entry and exit, a gate
+// blocking the flow, and flow completion. Hooks receive the flow's state and
+// may return blockers, so organisations can add policy without forking the
+// engine.
+package hooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// Points at which hooks run.
+const (
+	// PhaseEnter runs after a flow enters a phase. Blockers block the flow
+	// until a human unblocks it.
+	PhaseEnter = "phase_enter"
+	// PhaseExit runs when a flow's phase gate would let it advance or roll
+	// back. Blockers hold the flow in the phase.
+	PhaseExit = "phase_exit"
+	// GateBlocked runs after a flow is blocked because its gate refused to
+	// let it advance. Blockers are ignored.
+	GateBlocked = "gate_blocked"
+	// FlowCompleted runs after a flow reaches phase G. Blockers are ignored.
+	FlowCompleted = "flow_completed"
+)
+
+// Points lists the hook points, for validation.
+var Points = []string{PhaseEnter, PhaseExit, GateBlocked, FlowCompleted}
+
+// Call is what a hook receives.
+type Call struct {
+	Point string           `json:"point"`
+	State domain.FlowState `json:"state"`
+	// Phase is the phase entered, exited, or blocked in.
+	Phase domain.Phase `json:"phase"`
+	// From is the phase the flow left, for phase_enter and flow_completed.
+	From domain.Phase `json:"from,omitempty"`
+	// Blockers are the gate's, for gate_blocked.
+	Blockers []string `json:"blockers,omitempty"`
+	// DryRun marks a phase_exit call made to preview a transition.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// Result is a hook's answer.
+type Result struct {
+	Blockers []string `json:"blockers,omitempty"`
+}
+
+// Hook is one hook implementation.
+type Hook interface {
+	Run(ctx context.Context, call Call) (Result, error)
+}
+
+// Func adapts a function to a Hook.
+type Func func(ctx context.Context, call Call) (Result, error)
+
+// Run implements Hook.
+func (f Func) Run(ctx context.Context, call Call) (Result, error) {
+	return f(ctx, call)
+}
+
+// Registration places a hook at points in the flow's life.
+type Registration struct {
+	Name string
+	Hook Hook
+	// Points are the points the hook runs at; empty means all.
+	Points []string
+	// Phases limits the hook to calls about these phases; empty means all.
+	Phases []domain.Phase
+	// Timeout bounds each run (default 10s).
+	Timeout time.Duration
+	// FailOpen ignores a hook that fails. Otherwise a failing phase_enter
+	// or phase_exit hook blocks the flow as if it had returned a blocker.
+	FailOpen bool
+}
+
+// matches reports whether r runs for call.
+func (r Registration) matches(call Call) bool {
+	return matchesPoint(r.Points, call.Point) && matchesPhase(r.Phases, call.Phase)
+}
+
+func matchesPoint(points []string, point string) bool {
+	for _, p := range points {
+		if p == point {
+			return true
+		}
+	}
+	return len(points) == 0
+}
+
+func matchesPhase(phases []domain.Phase, phase domain.Phase) bool {
+	for _, p := range phases {
+		if p == phase {
+			return true
+		}
+	}
+	return len(phases) == 0
+}
+
+// exitPhases are the phases a flow can leave.
+var exitPhases = []domain.Phase{
+	domain.PhaseA, domain.PhaseB, domain.PhaseC, domain.PhaseD, domain.PhaseE, domain.PhaseF,
+}
+
+// Registry holds the hooks and runs them at their points.
+type Registry struct {
+	Engine    *workflow.Engine
+	DB        *sql.DB
+	AuditRepo *store.AuditRepo
+	GateRepo  *store.GateDecisionRepo
+
+	mu    sync.RWMutex
+	hooks []Registration
+}
+
+// NewRegistry creates an empty Registry for engine.
+func NewRegistry(engine *workflow.Engine) *Registry {
+	return &Registry{
+		Engine:    engine,
+		DB:        engine.DB,
+		AuditRepo: &store.AuditRepo{},
+		GateRepo:  &store.GateDecisionRepo{},
+	}
+}
+
+// Register adds a hook. Hooks run in registration order. phase_exit hooks
+// must be registered before Start.
+func (r *Registry) Register(reg Registration) {
+	r.mu.Lock()
+	r.hooks = append(r.hooks, reg)
+	r.mu.Unlock()
+}
+
+// Run runs every hook registered for call, in order, and returns their
+// blockers, each prefixed with the hook's name. A failing hook is audited
+// and, unless it fails open, reported as a blocker.
+func (r *Registry) Run(ctx context.Context, call Call) []string {
+	r.mu.RLock()
+	hooks := append([]Registration(nil), r.hooks...)
+	r.mu.RUnlock()
+
+	var blockers []string
+	for _, reg := range hooks {
+		if !reg.matches(call) {
+			continue
+		}
+		timeout := reg.Timeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		runCtx, cancel := context.WithTimeout(ctx, timeout)
+		res, err := reg.Hook.Run(runCtx, call)
+		cancel()
+		if err != nil {
+			r.audit(call.State.TaskID, "hook_failed", "warning", map[string]string{
+				"hook":  reg.Name,
+				"point": call.Point,
+				"phase": string(call.Phase),
+				"error": err.Error(),
+			})
+			if !reg.FailOpen {
+				blockers = append(blockers, fmt.Sprintf("hook %s failed: %v", reg.Name, err))
+			}
+			continue
+		}
+		for _, b := range res.Blockers {
+			blockers = append(blockers, fmt.Sprintf("hook %s: %s", reg.Name, b))
+		}
+	}
+	return blockers
+}
+
+// Start wraps the gate of every phase that has phase_exit hooks, and runs
+// the other points' hooks as flows move, until ctx is cancelled.
+func (r *Registry) Start(ctx context.Context, bus *eventbus.Bus) {
+	for _, phase := range exitPhases {
+		if !r.has(PhaseExit, phase) {
+			continue
+		}
+		inner, err := r.Engine.GateRegistry.Get(phase)
+		if err != nil {
+			continue
+		}
+		r.Engine.GateRegistry.Register(phase, &Gate{Inner: inner, Registry: r})
+	}
+
+	r.Engine.AddListener(func(_ context.Context, state domain.FlowState, from domain.Phase) {
+		if state.CurrentPhase == from {
+			return // a status change, not a transition
+		}
+		go r.entered(ctx, state, from)
+	})
+
+	signals, unsubscribe := bus.Subscribe(64)
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				if sig.Topic == eventbus.TopicFlowBlocked {
+					r.blocked(ctx, sig.TaskID)
+				}
+			}
+		}
+	}()
+}
+
+// has reports whether any hook runs at point for phase.
+func (r *Registry) has(point string, phase domain.Phase) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, reg := range r.hooks {
+		if reg.matches(Call{Point: point, Phase: phase}) {
+			return true
+		}
+	}
+	return false
+}
+
+// entered runs the phase_enter hooks of a transition, blocking the flow if
+// they return blockers, and the flow_completed hooks once it is done.
+func (r *Registry) entered(ctx context.Context, state domain.FlowState, from domain.Phase) {
+	blockers := r.Run(ctx, Call{Point: PhaseEnter, State: state, Phase: state.CurrentPhase, From: from})
+	if state.Status == domain.StatusDone {
+		r.Run(ctx, Call{Point: FlowCompleted, State: state, Phase: state.CurrentPhase, From: from})
+		return
+	}
+	if len(blockers) > 0 {
+		reason := "blocked on entering " + string(state.CurrentPhase) + ": " + strings.Join(blockers, "; ")
+		if err := r.Engine.Block(ctx, state.TaskID, reason); err != nil {
+			r.audit(state.TaskID, "hook_block_failed", "warning", map[string]string{"error": err.Error()})
+		}
+	}
+}
+
+// blocked runs the gate_blocked hooks of a flow its gate just blocked.
+func (r *Registry) blocked(ctx context.Context, taskID string) {
+	state, err := r.Engine.GetState(ctx, taskID)
+	if err != nil {
+		return
+	}
+	call := Call{Point: GateBlocked, State: *state, Phase: state.CurrentPhase}
+	if records, err := r.GateRepo.ListByTask(ctx, r.DB, taskID, state.CurrentPhase, 1); err == nil && len(records) > 0 {
+		call.Blockers = records[0].Blockers
+	}
+	r.Run(ctx, call)
+}
+
+func (r *Registry) audit(taskID, action, severity string, detail map[string]string) {
+	data, _ := json.Marshal(detail)
+	now := time.Now()
+	_ = r.AuditRepo.Record(context.Background(), r.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-hook-%d", now.UnixNano()),
+		TaskID:       taskID,
+		Category:     "hook",
+		Actor:        "system",
+		Action:       action,
+		DecisionJSON: string(data),
+		Severity:     severity,
+		CreatedAt:    now.Unix(),
+	})
+}
+
+// Gate wraps a phase's gate: once Inner allows, the phase_exit hooks run
+// and any blockers they return hold the flow in its phase.
+type Gate struct {
+	Inner    workflow.Gate
+	Registry *Registry
+}
+
+// Name returns the gate name.
+func (g *Gate) Name() string {
+	return "hooks"
+}
+
+// Evaluate checks the inner gate first, then runs the phase_exit hooks.
+func (g *Gate) Evaluate(ctx context.Context, state domain.FlowState) (domain.GateDecision, error) {
+	decision, err := g.Inner.Evaluate(ctx, state)
+	if err != nil || !decision.Allow {
+		return decision, err
+	}
+	blockers := g.Registry.Run(ctx, Call{
+		Point:  PhaseExit,
+		State:  state,
+		Phase:  state.CurrentPhase,
+		DryRun: workflow.IsDryRun(ctx),
+	})
+	if len(blockers) > 0 {
+		decision.Allow = false
+		decision.Blockers = append(decision.Blockers, blockers...)
+	}
+	return decision, nil
+}
@@ -0,0 +1,244 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+func newTestEngine(t *testing.T) *workflow.Engine {
+	t.Helper()
+	db, err := store.NewDB(store.MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	eng := workflow.NewEngine(db)
+	eng.Bus = eventbus.New()
+	return eng
+}
+
+// recorder is a hook that records its calls and returns blockers.
+type recorder struct {
+	mu       sync.Mutex
+	calls    []Call
+	blockers []string
+	err      error
+}
+
+func (r *recorder) Run(ctx context.Context, call Call) (Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+	return Result{Blockers: r.blockers}, r.err
+}
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func advance(eng *workflow.Engine, taskID string) error {
+	return eng.Advance(context.Background(), taskID, domain.TransitionTrigger{Action: "advance", Actor: "test"})
+}
+
+func TestGate_PhaseExitHooksHoldTheFlow(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eng.StartFlow(ctx, "t1", 10)
+
+	policy := &recorder{blockers: []string{"change ticket missing"}}
+	other := &recorder{}
+	r := NewRegistry(eng)
+	r.Register(Registration{Name: "policy", Hook: policy, Points: []string{PhaseExit}, Phases: []domain.Phase{domain.PhaseA}})
+	r.Register(Registration{Name: "other", Hook: other, Points: []string{PhaseExit}, Phases: []domain.Phase{domain.PhaseB}})
+	r.Start(ctx, eng.Bus)
+
+	preview, err := eng.Preview(ctx, "t1", domain.TransitionTrigger{Action: "advance"})
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if preview.Decision.Allow || len(policy.calls) != 1 || !policy.calls[0].DryRun {
+		t.Errorf("preview = %+v after calls %+v", preview.Decision, policy.calls)
+	}
+
+	err = advance(eng, "t1")
+	if err == nil || !strings.Contains(err.Error(), "hook policy: change ticket missing") {
+		t.Fatalf("Advance = %v, want the hook's blocker", err)
+	}
+	if gate, _ := eng.GateRegistry.Get(domain.PhaseA); gate.Name() != "hooks" {
+		t.Errorf("phase A gate = %s, want hooks", gate.Name())
+	}
+	if gate, _ := eng.GateRegistry.Get(domain.PhaseC); gate.Name() == "hooks" {
+		t.Error("phase C, which has no exit hooks, was wrapped")
+	}
+
+	policy.mu.Lock()
+	policy.blockers = nil
+	policy.mu.Unlock()
+	if err := advance(eng, "t1"); err != nil {
+		t.Fatalf("Advance once the hook allows: %v", err)
+	}
+	if len(other.calls) != 0 {
+		t.Errorf("phase B hook ran in phase A: %+v", other.calls)
+	}
+}
+
+func TestRegistry_FailingHooks(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "t1", 10)
+	state, _ := eng.GetState(ctx, "t1")
+
+	r := NewRegistry(eng)
+	r.Register(Registration{Name: "closed", Hook: &recorder{err: errors.New("unreachable")}})
+	r.Register(Registration{Name: "open", Hook: &recorder{err: errors.New("unreachable")}, FailOpen: true})
+	blockers := r.Run(ctx, Call{Point: PhaseExit, State: *state, Phase: domain.PhaseA})
+	if len(blockers) != 1 || blockers[0] != "hook closed failed: unreachable" {
+		t.Errorf("blockers = %v, want only the closed hook's failure", blockers)
+	}
+	audits, _ := r.AuditRepo.ListByTask(ctx, eng.DB, "t1")
+	if len(audits) != 2 || audits[0].Action != "hook_failed" {
+		t.Errorf("audits = %+v, want both failures recorded", audits)
+	}
+}
+
+func TestRegistry_PhaseEnterAndCompletion(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eng.StartFlow(ctx, "t1", 10)
+	eng.StartFlow(ctx, "t2", 10)
+
+	gatekeeper := &recorder{blockers: []string{"security review pending"}}
+	completed := &recorder{}
+	r := NewRegistry(eng)
+	r.Register(Registration{Name: "security", Hook: gatekeeper, Points: []string{PhaseEnter}, Phases: []domain.Phase{domain.PhaseC}})
+	r.Register(Registration{Name: "done", Hook: completed, Points: []string{FlowCompleted}})
+	r.Start(ctx, eng.Bus)
+
+	advance(eng, "t1")
+	advance(eng, "t1")
+	waitFor(t, "the phase_enter hook to block t1", func() bool {
+		state, _ := eng.GetState(ctx, "t1")
+		return state.Status == domain.StatusBlocked
+	})
+	events, _ := eng.EventRepo.ListByTask(ctx, eng.DB, "t1", 0)
+	var payload domain.FlowBlockedPayload
+	json.Unmarshal([]byte(events[len(events)-1].PayloadJSON), &payload)
+	if !strings.Contains(payload.Reason, "hook security: security review pending") {
+		t.Errorf("block reason = %q", payload.Reason)
+	}
+
+	gatekeeper.mu.Lock()
+	gatekeeper.blockers = nil
+	gatekeeper.mu.Unlock()
+	for i := 0; i < 6; i++ {
+		if err := advance(eng, "t2"); err != nil {
+			t.Fatalf("advance t2 %d: %v", i, err)
+		}
+	}
+	waitFor(t, "the flow_completed hook", func() bool {
+		completed.mu.Lock()
+		defer completed.mu.Unlock()
+		return len(completed.calls) == 1
+	})
+	if c := completed.calls[0]; c.State.TaskID != "t2" || c.Phase != domain.PhaseG || c.From != domain.PhaseF {
+		t.Errorf("completion call = %+v", c)
+	}
+}
+
+func TestRegistry_GateBlocked(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eng.StartFlow(ctx, "t1", 10)
+
+	notified := &recorder{blockers: []string{"ignored"}}
+	r := NewRegistry(eng)
+	r.Register(Registration{Name: "pager", Hook: notified, Points: []string{GateBlocked}})
+	r.Start(ctx, eng.Bus)
+	eng.GateRepo.Create(ctx, eng.DB, domain.GateRecord{TaskID: "t1", Phase: domain.PhaseA, Gate: "default", Blockers: []string{"tests failing"}})
+
+	if err := eng.BlockOnGate(ctx, "t1", "gate blocked transition"); err != nil {
+		t.Fatalf("BlockOnGate: %v", err)
+	}
+	waitFor(t, "the gate_blocked hook", func() bool {
+		notified.mu.Lock()
+		defer notified.mu.Unlock()
+		return len(notified.calls) == 1
+	})
+	if c := notified.calls[0]; c.Phase != domain.PhaseA || len(c.Blockers) != 1 || c.Blockers[0] != "tests failing" {
+		t.Errorf("gate_blocked call = %+v", c)
+	}
+}
+
+func TestWebhook_Run(t *testing.T) {
+	var got Call
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "s3cret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		if got.Point == PhaseExit {
+			w.Write([]byte(`{"blockers": ["no"]}`))
+		}
+	}))
+	defer srv.Close()
+
+	w := &Webhook{URL: srv.URL, Headers: map[string]string{"X-Token": "s3cret"}}
+	call := Call{Point: PhaseExit, State: domain.FlowState{TaskID: "t1"}, Phase: domain.PhaseA}
+	if res, err := w.Run(context.Background(), call); err != nil || len(res.Blockers) != 1 || got.State.TaskID != "t1" {
+		t.Errorf("Run = %+v, %v; server saw %+v", res, err, got)
+	}
+	call.Point = FlowCompleted
+	if res, err := w.Run(context.Background(), call); err != nil || len(res.Blockers) != 0 {
+		t.Errorf("Run with an empty answer = %+v, %v", res, err)
+	}
+	if _, err := (&Webhook{URL: srv.URL}).Run(context.Background(), call); err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("Run refused = %v", err)
+	}
+}
+
+func TestExec_Run(t *testing.T) {
+	dir := t.TempDir()
+	call := Call{Point: PhaseExit, State: domain.FlowState{TaskID: "t1", Workspace: dir}, Phase: domain.PhaseE}
+
+	script := `grep -q '"phase":"E"' && [ "$THREEBODY_TASK_ID" = t1 ] && [ "$(pwd)" = "` + dir + `" ] && echo '{"blockers":["coverage below 80%"]}'`
+	res, err := (&Exec{Command: "sh", Args: []string{"-c", script}}).Run(context.Background(), call)
+	if err != nil || len(res.Blockers) != 1 || res.Blockers[0] != "coverage below 80%" {
+		t.Errorf("Run = %+v, %v", res, err)
+	}
+	if res, err := (&Exec{Command: "true"}).Run(context.Background(), call); err != nil || len(res.Blockers) != 0 {
+		t.Errorf("Run with no output = %+v, %v", res, err)
+	}
+	if _, err := (&Exec{Command: "sh", Args: []string{"-c", "echo broken >&2; exit 3"}}).Run(context.Background(), call); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("failing command = %v, want its stderr", err)
+	}
+}
+
+func TestOpenPlugin_Missing(t *testing.T) {
+	if _, err := OpenPlugin("/nonexistent/hook.so", "Hook"); err == nil {
+		t.Error("OpenPlugin of a missing file succeeded")
+	}
+}
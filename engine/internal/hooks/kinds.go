@@ -0,0 +1,137 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"plugin"
+)
+
+// Webhook posts each call as JSON to URL and reads a Result from a 2xx
+// response. An empty response body means no blockers.
+type Webhook struct {
+	URL     string
+	Headers map[string]string
+	HTTP    *http.Client
+}
+
+// Run implements Hook.
+func (w *Webhook) Run(ctx context.Context, call Call) (Result, error) {
+	client := w.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	data, err := json.Marshal(call)
+	if err != nil {
+		return Result{}, fmt.Errorf("marshal call: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(data))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Result{}, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return Result{}, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(limit(body)))
+	}
+	return decodeResult(body)
+}
+
+// Exec runs Command with the call as JSON on stdin, in Dir (default the
+// flow's workspace), and reads a Result from stdout. Empty output means no
+// blockers; a non-zero exit is a failure. THREEBODY_HOOK_POINT and
+// THREEBODY_TASK_ID are added to the environment.
+type Exec struct {
+	Command string
+	Args    []string
+	Dir     string
+}
+
+// Run implements Hook.
+func (e *Exec) Run(ctx context.Context, call Call) (Result, error) {
+	data, err := json.Marshal(call)
+	if err != nil {
+		return Result{}, fmt.Errorf("marshal call: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, e.Command, e.Args...)
+	cmd.Dir = e.Dir
+	if cmd.Dir == "" {
+		cmd.Dir = call.State.Workspace
+	}
+	cmd.Env = append(os.Environ(), "THREEBODY_HOOK_POINT="+call.Point, "THREEBODY_TASK_ID="+call.State.TaskID)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := bytes.TrimSpace(limit(stderr.Bytes())); len(msg) > 0 {
+			return Result{}, fmt.Errorf("%w: %s", err, msg)
+		}
+		return Result{}, err
+	}
+	return decodeResult(stdout.Bytes())
+}
+
+// decodeResult parses a hook's JSON answer.
+func decodeResult(data []byte) (Result, error) {
+	var res Result
+	if len(bytes.TrimSpace(data)) == 0 {
+		return res, nil
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return Result{}, fmt.Errorf("decode result: %w", err)
+	}
+	return res, nil
+}
+
+// limit keeps the last 4 KiB of output for error messages.
+func limit(out []byte) []byte {
+	if len(out) > 4096 {
+		return out[len(out)-4096:]
+	}
+	return out
+}
+
+// OpenPlugin loads a hook from the Go plugin at path. symbol must name a
+// variable holding a Hook, or a function with Func's signature. The plugin
+// must be built with the same Go version and module versions as the
+// engine.
+func OpenPlugin(path, symbol string) (Hook, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup(symbol)
+	if err != nil {
+		return nil, err
+	}
+	switch h := sym.(type) {
+	case *Hook:
+		if *h == nil {
+			return nil, fmt.Errorf("%s: %s is nil", path, symbol)
+		}
+		return *h, nil
+	case Hook:
+		return h, nil
+	case func(context.Context, Call) (Result, error):
+		return Func(h), nil
+	}
+	return nil, fmt.Errorf("%s: %s is a %T, not a hooks.Hook", path, symbol, sym)
+}
@@ -0,0 +1,144 @@
+// Package instance keeps a second engine from running against the same
+// database on one machine, and finds a free port when the configured one
+// is taken.
+package instance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+)
+
+// Holder describes the engine holding a lock.
+type Holder struct {
+	PID int `json:"pid"`
+	// URL is where the engine serves its API and UI; empty until it listens.
+	URL       string `json:"url"`
+	StartedAt int64  `json:"startedAt"`
+}
+
+// Lock is a lock file held by this process.
+type Lock struct {
+	path   string
+	holder Holder
+}
+
+// Acquire creates the lock file at path for h. When a live process already
+// holds it, no lock is taken and that process's Holder is returned instead.
+// A lock file left by a process that is gone is replaced.
+func Acquire(path string, h Holder) (*Lock, *Holder, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			err = json.NewEncoder(f).Encode(h)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, nil, fmt.Errorf("write lock file: %w", err)
+			}
+			return &Lock{path: path, holder: h}, nil, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, nil, fmt.Errorf("create lock file: %w", err)
+		}
+
+		existing, err := read(path)
+		if err == nil && existing.PID != os.Getpid() && processAlive(existing.PID) {
+			return nil, existing, nil
+		}
+		// Unreadable, or left by a process that is gone: take it over.
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("remove stale lock file: %w", err)
+		}
+	}
+	return nil, nil, fmt.Errorf("lock file %s keeps reappearing", path)
+}
+
+// read returns the Holder recorded in the lock file at path.
+func read(path string) (*Holder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var h Holder
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// SetURL records the URL the holder serves on, once it is listening.
+func (l *Lock) SetURL(url string) error {
+	l.holder.URL = url
+	data, err := json.Marshal(l.holder)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.path, append(data, '\n'), 0o644)
+}
+
+// Release removes the lock file.
+func (l *Lock) Release() error {
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Listen listens on addr or, while its port is in use, on each of the next
+// tries-1 ports, and returns the listener with the address it took. Ports
+// other than in-use ones fail at once.
+func Listen(addr string, tries int) (net.Listener, string, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, "", err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, "", fmt.Errorf("listen address %s: bad port", addr)
+	}
+	if tries < 1 || port == 0 {
+		tries = 1
+	}
+
+	var firstErr error
+	for i := 0; i < tries && port+i <= 65535; i++ {
+		candidate := net.JoinHostPort(host, strconv.Itoa(port+i))
+		ln, err := net.Listen("tcp", candidate)
+		if err == nil {
+			return ln, candidate, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if !inUse(err) {
+			break
+		}
+	}
+	return nil, "", firstErr
+}
+
+// OpenBrowser opens url in the user's default browser.
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,74 @@
+package instance
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "engine.db.lock")
+
+	lock, running, err := Acquire(path, Holder{PID: os.Getpid()})
+	if err != nil || running != nil || lock == nil {
+		t.Fatalf("Acquire = %v, %+v, %v, want the lock", lock, running, err)
+	}
+	if err := lock.SetURL("http://localhost:9800"); err != nil {
+		t.Fatalf("SetURL: %v", err)
+	}
+	if h, err := read(path); err != nil || h.URL != "http://localhost:9800" || h.PID != os.Getpid() {
+		t.Errorf("lock file = %+v, %v", h, err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("lock file still present after Release: %v", err)
+	}
+
+	// Held by a live process: its holder is returned.
+	live := Holder{PID: os.Getppid(), URL: "http://localhost:9801"}
+	data, _ := json.Marshal(live)
+	os.WriteFile(path, data, 0o644)
+	lock, running, err = Acquire(path, Holder{PID: os.Getpid()})
+	if err != nil || lock != nil || running == nil || *running != live {
+		t.Errorf("Acquire over a live holder = %v, %+v, %v, want %+v", lock, running, err, live)
+	}
+
+	// Left by a process that is gone, or unreadable: taken over.
+	for _, stale := range []string{`{"pid": -1}`, `not json`} {
+		os.WriteFile(path, []byte(stale), 0o644)
+		lock, running, err = Acquire(path, Holder{PID: os.Getpid()})
+		if err != nil || running != nil || lock == nil {
+			t.Errorf("Acquire over %s = %v, %+v, %v, want the lock", stale, lock, running, err)
+		}
+	}
+}
+
+func TestListen_FallsBackToNextPort(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer taken.Close()
+	port := taken.Addr().(*net.TCPAddr).Port
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+
+	if _, _, err := Listen(addr, 1); err == nil {
+		t.Error("Listen on a taken port without fallback succeeded")
+	}
+	ln, got, err := Listen(addr, 5)
+	if err != nil {
+		t.Skipf("no free port after %d: %v", port, err)
+	}
+	defer ln.Close()
+	if got == addr {
+		t.Errorf("Listen took the taken address %s", got)
+	}
+	if ln.Addr().String() != got {
+		t.Errorf("listener on %s, reported %s", ln.Addr(), got)
+	}
+}
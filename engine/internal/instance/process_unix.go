@@ -0,0 +1,22 @@
+//go:build !windows
+
+package instance
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive reports whether a process with pid exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// inUse reports whether a listen error means the port is taken.
+func inUse(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE)
+}
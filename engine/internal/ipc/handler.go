@@ -2,43 +2,240 @@
 package ipc
 
 import (
+	"bufio"
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/anthropics/three-body-engine/internal/artifact"
 	"github.com/anthropics/three-body-engine/internal/bridge"
+	"github.com/anthropics/three-body-engine/internal/bundle"
+	"github.com/anthropics/three-body-engine/internal/chaos"
+	"github.com/anthropics/three-body-engine/internal/ci"
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+	"github.com/anthropics/three-body-engine/internal/git"
 	"github.com/anthropics/three-body-engine/internal/guard"
+	"github.com/anthropics/three-body-engine/internal/health"
+	"github.com/anthropics/three-body-engine/internal/leader"
+	"github.com/anthropics/three-body-engine/internal/mcp"
+	"github.com/anthropics/three-body-engine/internal/purge"
+	"github.com/anthropics/three-body-engine/internal/redact"
+	"github.com/anthropics/three-body-engine/internal/report"
+	"github.com/anthropics/three-body-engine/internal/review"
+	"github.com/anthropics/three-body-engine/internal/sandbox"
+	"github.com/anthropics/three-body-engine/internal/secrets"
 	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/team"
 	"github.com/anthropics/three-body-engine/internal/workflow"
 )
 
 // Handler holds all dependencies for the HTTP handlers.
 type Handler struct {
-	Engine        *workflow.Engine
-	Bridge        *bridge.Bridge
-	Guard         *guard.Guard
-	DB            *sql.DB
-	EventRepo     *store.EventRepo
-	WorkerRepo    *store.WorkerRepo
-	ScoreCardRepo *store.ScoreCardRepo
-	CostDeltaRepo *store.CostDeltaRepo
-	TaskRepo      *store.TaskRepo
+	Engine           *workflow.Engine
+	Scheduler        *workflow.Scheduler
+	Bridge           *bridge.Bridge
+	Guard            *guard.Guard
+	DB               *sql.DB
+	ReadDB           *sql.DB
+	EventRepo        *store.EventRepo
+	WorkerRepo       *store.WorkerRepo
+	IntentRepo       *store.IntentRepo
+	DecisionRepo     *store.DecisionRepo
+	ArtifactRepo     *store.ArtifactRepo
+	ConstraintRepo   *store.ConstraintRepo
+	RiskRepo         *store.RiskRepo
+	ScoreCardRepo    *store.ScoreCardRepo
+	IssueRepo        *store.IssueRepo
+	RoundRepo        *store.ReviewRoundRepo
+	Consensus        *review.ConsensusEngine
+	CostDeltaRepo    *store.CostDeltaRepo
+	BudgetAlertRepo  *store.BudgetAlertRepo
+	TaskRepo         *store.TaskRepo
+	SessionEventRepo *store.SessionEventRepo
+	Bus              *eventbus.Bus
+	Bundler          *bundle.Bundler
+	// Git, when set, serves the diff of each flow's branch.
+	Git *git.Manager
+	// Conflicts settles escalated intent conflicts.
+	Conflicts *team.ConflictDetector
+	// Workers, when set, reports the worker spawn queue.
+	Workers *team.WorkerManager
+	// Blobs, when set, stores the content submitted with artifacts.
+	Blobs *artifact.Blobs
+	// Digests builds context digests for prospective workers.
+	Digests *team.DigestBuilder
+	// Exec runs allowlisted commands for workers.
+	Exec *sandbox.Executor
+	// Files proxies worker file access to flow workspaces.
+	Files *sandbox.Files
+	// Redactor masks secrets in stored payloads; its counts are reported
+	// by Metrics.
+	Redactor *redact.Redactor
+	// TaskCache, when set, caches flow state reads; its counts are
+	// reported by Metrics.
+	TaskCache *store.TaskCache
+	// Approvals records human approvals of the phases that need one.
+	Approvals *workflow.Approvals
+	// Leader, when set, is this instance's campaign for the engine lease.
+	Leader *leader.Elector
+	// Providers, when set, reports and runs provider validation.
+	Providers *mcp.ProviderRegistry
+	// ProviderCheck configures provider validation requested over the API.
+	ProviderCheck mcp.ProviderCheck
+	// Readiness, when set, runs the checks behind GET /readyz.
+	Readiness *health.Checker
+	// Reports renders flow run reports.
+	Reports *report.Generator
+	// StatsTTL is how long GET /api/v1/stats serves the same counters
+	// before computing them again (default 10s).
+	StatsTTL time.Duration
+	// HTTP sets the server's timeouts and body size limit.
+	HTTP HTTPConfig
+	// SSEKeepAlive is how often an idle event stream sends a comment so
+	// proxies keep it open (default 15s).
+	SSEKeepAlive time.Duration
+	// Chaos, if set, is the fault injector whose counts GET
+	// /api/v1/metrics reports.
+	Chaos *chaos.Monkey
+	// Purger erases flows on request; a default one, which leaves files
+	// alone, is used when it is unset.
+	Purger *purge.Purger
+	// CIChecks stores the CI checks reported for each flow.
+	CIChecks *store.CICheckRepo
+	// CISecret signs CI webhook reports; the webhook is off while it is
+	// empty. CIBranchPrefix maps a reported branch to its task.
+	CISecret       []byte
+	CIBranchPrefix string
+	// Clock dates the records the API writes and the stats it serves; nil
+	// means the wall clock. Record IDs stay on the wall clock so they
+	// remain unique.
+	Clock clock.Clock
+
+	statsMu sync.Mutex
+	stats   *domain.EngineStats
+	statsAt time.Time
+}
+
+// now returns the time on h's clock.
+func (h *Handler) now() time.Time {
+	return clock.Or(h.Clock).Now()
 }
 
 // CreateFlowRequest is the body for POST /api/v1/flow.
 type CreateFlowRequest struct {
 	TaskID       string  `json:"task_id"`
 	BudgetCapUSD float64 `json:"budget_cap_usd"`
+	AutoAdvance  bool    `json:"auto_advance"`
+	Queued       bool    `json:"queued"`
+	StartAt      int64   `json:"start_at"`
+	Priority     int     `json:"priority"`
+
+	Title              string `json:"title,omitempty"`
+	Description        string `json:"description,omitempty"`
+	AcceptanceCriteria string `json:"acceptance_criteria,omitempty"`
+	// Consensus overrides the configured consensus weights and thresholds.
+	Consensus *domain.ConsensusSettings `json:"consensus,omitempty"`
+	// Namespace places the flow in a team's namespace; the namespaced route
+	// sets it from the path.
+	Namespace string `json:"namespace,omitempty"`
+	// ShareWorkspace starts the flow even if another flow holds its
+	// workspace.
+	ShareWorkspace bool `json:"share_workspace,omitempty"`
+}
+
+// CreateChildRequest is the body for POST /api/v1/flow/{taskID}/children.
+type CreateChildRequest struct {
+	TaskID         string  `json:"task_id"`
+	BudgetFraction float64 `json:"budget_fraction"`
+	AutoAdvance    bool    `json:"auto_advance"`
+	Priority       int     `json:"priority"`
+}
+
+// CloneFlowRequest is the body for POST /api/v1/flow/{taskID}/clone.
+type CloneFlowRequest struct {
+	TaskID       string  `json:"task_id"`
+	BudgetCapUSD float64 `json:"budget_cap_usd"`
+}
+
+// FailFlowRequest is the body for POST /api/v1/flow/{taskID}/fail.
+type FailFlowRequest struct {
+	Cause  domain.FailureCause `json:"cause"`
+	Detail string              `json:"detail"`
+	Actor  string              `json:"actor"`
 }
 
 // AdvanceRequest is the body for POST /api/v1/flow/{taskID}/advance.
 type AdvanceRequest struct {
-	Action string `json:"action"`
-	Actor  string `json:"actor"`
+	Action     string `json:"action"`
+	Actor      string `json:"actor"`
+	RollbackTo string `json:"rollback_to,omitempty"`
+}
+
+// BudgetRequest is the body for PUT /api/v1/flow/{taskID}/budget.
+type BudgetRequest struct {
+	BudgetCapUSD float64 `json:"budget_cap_usd"`
+}
+
+// RegisterProviderRequest is the body for POST /api/v1/providers. Env
+// values are stored in the database, so credentials should be secret
+// references such as "${keychain:anthropic}" rather than plain values.
+type RegisterProviderRequest struct {
+	Name    domain.Provider   `json:"name"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+	Adapter string            `json:"adapter,omitempty"`
+	Actor   string            `json:"actor"`
+	// SkipCheck registers the provider without resolving its command and
+	// running its smoke test first.
+	SkipCheck bool `json:"skip_check"`
+}
+
+// PurgeRequest is the body for POST /api/v1/flow/{taskID}/purge.
+type PurgeRequest struct {
+	Actor string `json:"actor"`
+}
+
+// ApprovalRequest is the body for POST /api/v1/flow/{taskID}/approvals.
+// Decision is "approved" or "rejected"; a rejection's comment says what
+// must change.
+type ApprovalRequest struct {
+	Actor    string `json:"actor"`
+	Decision string `json:"decision"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// PrecheckRequest is the body for POST /api/v1/flow/{taskID}/precheck.
+// Action defaults to "advance". Path and Command are checked against the
+// capability sheet of WorkerID or, without one, the task's default sheet.
+type PrecheckRequest struct {
+	Action     string `json:"action,omitempty"`
+	RollbackTo string `json:"rollback_to,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Command    string `json:"command,omitempty"`
+	WorkerID   string `json:"worker_id,omitempty"`
+}
+
+// PrecheckResponse is the response for POST /api/v1/flow/{taskID}/precheck.
+// Allow is true when the flow may advance and every guard rule passes.
+type PrecheckResponse struct {
+	Allow   bool                   `json:"allow"`
+	Advance *domain.AdvancePreview `json:"advance"`
+	Rules   []guard.RuleResult     `json:"rules"`
 }
 
 // CostSummary is the response for GET /api/v1/flow/{taskID}/cost.
@@ -47,6 +244,8 @@ type CostSummary struct {
 	BudgetCapUSD  float64            `json:"budgetCapUsd"`
 	CostAction    domain.CostAction  `json:"costAction"`
 	Deltas        []domain.CostDelta `json:"deltas"`
+	// Alerts are the budget alerts fired for the flow.
+	Alerts []domain.BudgetAlert `json:"alerts,omitempty"`
 }
 
 // APIError is a structured error response.
@@ -55,135 +254,2047 @@ type APIError struct {
 	Message string `json:"message"`
 }
 
-// Health handles GET /api/v1/health.
-func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+// reader returns the pool for read-only queries, falling back to the writer
+// connection when no read pool is configured.
+func (h *Handler) reader() *sql.DB {
+	if h.ReadDB != nil {
+		return h.ReadDB
+	}
+	return h.DB
+}
+
+// Health handles GET /api/v1/health and GET /healthz. It answers as long
+// as the process serves HTTP, for liveness probes.
+func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz handles GET /readyz: 200 with every check's outcome when the
+// engine can serve, 503 when any check fails. Without a checker the engine
+// is always ready.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if h.Readiness == nil {
+		writeJSON(w, http.StatusOK, health.Report{Status: health.StatusOK, Checks: []health.Check{}})
+		return
+	}
+	report := h.Readiness.Ready(r.Context())
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// LeaderResponse is the body of GET /api/v1/leader. Instance is this
+// instance's holder ID; Lease is the engine lease as stored, nil when no
+// instance holds it.
+type LeaderResponse struct {
+	Instance string        `json:"instance"`
+	Leading  bool          `json:"leading"`
+	Lease    *domain.Lease `json:"lease"`
+}
+
+// GetLeader handles GET /api/v1/leader. Without an elector the instance
+// runs alone and always leads.
+func (h *Handler) GetLeader(w http.ResponseWriter, r *http.Request) {
+	if h.Leader == nil {
+		writeJSON(w, http.StatusOK, LeaderResponse{Leading: true})
+		return
+	}
+	lease, err := h.Leader.Lease(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, LeaderResponse{Instance: h.Leader.Holder, Leading: h.Leader.IsLeader(), Lease: lease})
+}
+
+// MetricsResponse is the body of GET /api/v1/metrics.
+type MetricsResponse struct {
+	Redactions  redact.Stats         `json:"redactions"`
+	GuardCache  guard.CacheStats     `json:"guardCache"`
+	TaskCache   store.TaskCacheStats `json:"taskCache"`
+	DeadLetters DeadLetterMetrics    `json:"deadLetters"`
+	// Chaos counts the faults injected, when chaos testing is enabled.
+	Chaos *chaos.Stats `json:"chaos,omitempty"`
+}
+
+// DeadLetterMetrics counts session output lines no adapter could parse:
+// those received since the engine started, and those stored and not yet
+// requeued.
+type DeadLetterMetrics struct {
+	Received int64 `json:"received"`
+	Pending  int   `json:"pending"`
+}
+
+// Metrics handles GET /api/v1/metrics.
+func (h *Handler) Metrics(w http.ResponseWriter, r *http.Request) {
+	resp := MetricsResponse{Redactions: h.Redactor.Stats(), GuardCache: h.Guard.CacheStats(), TaskCache: h.TaskCache.Stats()}
+	if h.Bridge != nil && h.Bridge.Sessions != nil {
+		resp.DeadLetters.Received = h.Bridge.Sessions.DeadLetters()
+	}
+	pending, err := h.deadLetterRepo().CountPending(r.Context(), h.reader())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp.DeadLetters.Pending = pending
+	if h.Chaos != nil {
+		stats := h.Chaos.Stats()
+		resp.Chaos = &stats
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// ListDeadLetters handles GET /api/v1/deadletters, returning stored session
+// output lines that could not be parsed, newest first. ?session_id= and
+// ?provider= filter them, ?pending=true keeps those not yet requeued, and
+// ?limit= caps the count (default 100).
+func (h *Handler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := store.DeadLetterFilter{
+		SessionID: q.Get("session_id"),
+		Provider:  domain.Provider(q.Get("provider")),
+		Pending:   q.Get("pending") == "true",
+		Limit:     100,
+	}
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 0 {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "limit must be a non-negative number"})
+			return
+		}
+		filter.Limit = limit
+	}
+	letters, err := h.deadLetterRepo().List(r.Context(), h.reader(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if letters == nil {
+		letters = []domain.DeadLetterEvent{}
+	}
+	writeJSON(w, http.StatusOK, letters)
+}
+
+// GetDeadLetter handles GET /api/v1/deadletters/{id}.
+func (h *Handler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, ok := deadLetterID(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.deadLetterRepo().GetByID(r.Context(), h.reader(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// RequeueDeadLetterResponse is the body returned by
+// POST /api/v1/deadletters/{id}/requeue.
+type RequeueDeadLetterResponse struct {
+	ID     int64                    `json:"id"`
+	Events []domain.NormalizedEvent `json:"events"`
+}
+
+// RequeueDeadLetter handles POST /api/v1/deadletters/{id}/requeue, parsing
+// the line again and processing the events it now yields.
+func (h *Handler) RequeueDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, ok := deadLetterID(w, r)
+	if !ok {
+		return
+	}
+	if h.Bridge == nil {
+		writeError(w, domain.ErrBridgeNotReady)
+		return
+	}
+	events, err := h.Bridge.RequeueDeadLetter(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, RequeueDeadLetterResponse{ID: id, Events: events})
+}
+
+// deadLetterID parses the {id} path value, writing a 400 if it is not a
+// number.
+func deadLetterID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "id must be a number"})
+		return 0, false
+	}
+	return id, true
+}
+
+// deadLetterRepo returns the bridge's dead letter repository.
+func (h *Handler) deadLetterRepo() *store.DeadLetterRepo {
+	if h.Bridge != nil && h.Bridge.DeadLetterRepo != nil {
+		return h.Bridge.DeadLetterRepo
+	}
+	return &store.DeadLetterRepo{}
+}
+
+// ListProviders handles GET /api/v1/providers, returning the latest
+// validation of every provider and namespace override; checkedAt is 0 for
+// those not yet validated.
+func (h *Handler) ListProviders(w http.ResponseWriter, r *http.Request) {
+	if h.Providers == nil {
+		writeError(w, domain.ErrBridgeNotReady)
+		return
+	}
+	statuses := h.Providers.Statuses()
+	if statuses == nil {
+		statuses = []mcp.ProviderStatus{}
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// ValidateProviders handles POST /api/v1/providers/validate: it resolves
+// every provider's command and runs its smoke test, then returns the
+// results as ListProviders does.
+func (h *Handler) ValidateProviders(w http.ResponseWriter, r *http.Request) {
+	if h.Providers == nil {
+		writeError(w, domain.ErrBridgeNotReady)
+		return
+	}
+	statuses := h.Providers.Validate(r.Context(), h.ProviderCheck)
+	if statuses == nil {
+		statuses = []mcp.ProviderStatus{}
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// RegisterProvider handles POST /api/v1/providers: it registers a provider
+// or rotates the command, args, and env of one registered earlier, and
+// answers 201 or 200 with its status. Unless SkipCheck is set, a spec that
+// fails validation is rejected with 422 and its status. Sessions already
+// running keep the spec they were started with; new sessions, including
+// those of active flows, use the new one.
+func (h *Handler) RegisterProvider(w http.ResponseWriter, r *http.Request) {
+	if h.Providers == nil {
+		writeError(w, domain.ErrBridgeNotReady)
+		return
+	}
+	var req RegisterProviderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if err := validateProviderRequest(req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Actor == "" {
+		req.Actor = "api"
+	}
+
+	spec := mcp.ProviderSpec{Name: req.Name, Command: req.Command, Args: req.Args, Env: req.Env, Adapter: req.Adapter}
+	var status mcp.ProviderStatus
+	if !req.SkipCheck {
+		status = mcp.CheckSpec(r.Context(), spec, h.ProviderCheck)
+		if !status.OK {
+			writeJSON(w, http.StatusUnprocessableEntity, status)
+			return
+		}
+	}
+
+	now := h.now().Unix()
+	created, err := (&store.ProviderRepo{}).Upsert(r.Context(), h.DB, domain.ProviderRegistration{
+		Name:      req.Name,
+		Command:   req.Command,
+		Args:      req.Args,
+		Env:       req.Env,
+		Adapter:   req.Adapter,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.Providers.SetRuntime(spec, status)
+
+	action, code := "provider_updated", http.StatusOK
+	if created {
+		action, code = "provider_registered", http.StatusCreated
+	}
+	envKeys := make([]string, 0, len(req.Env))
+	for k := range req.Env {
+		envKeys = append(envKeys, k)
+	}
+	sort.Strings(envKeys)
+	h.auditProvider(r.Context(), req.Actor, action, map[string]interface{}{
+		"name":     req.Name,
+		"command":  req.Command,
+		"args":     req.Args,
+		"env_keys": envKeys,
+		"adapter":  req.Adapter,
+	})
+
+	status.Name, status.Command, status.Runtime = req.Name, req.Command, true
+	writeJSON(w, code, status)
+}
+
+// DeleteProvider handles DELETE /api/v1/providers/{name}?actor=. Only
+// providers registered at runtime can be removed; a configured provider of
+// the same name applies again afterwards.
+func (h *Handler) DeleteProvider(w http.ResponseWriter, r *http.Request) {
+	if h.Providers == nil {
+		writeError(w, domain.ErrBridgeNotReady)
+		return
+	}
+	name := domain.Provider(r.PathValue("name"))
+	deleted, err := (&store.ProviderRepo{}).Delete(r.Context(), h.DB, name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	removed := h.Providers.RemoveRuntime(name)
+	if !deleted && !removed {
+		writeError(w, domain.ErrProviderNotFound)
+		return
+	}
+	actor := r.URL.Query().Get("actor")
+	if actor == "" {
+		actor = "api"
+	}
+	h.auditProvider(r.Context(), actor, "provider_removed", map[string]interface{}{"name": name})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateProviderRequest checks a registration before its command is run.
+func validateProviderRequest(req RegisterProviderRequest) error {
+	if !domain.ValidProviderName(string(req.Name)) {
+		return domain.NewEngineError(domain.ErrProviderInvalid.Code,
+			fmt.Sprintf("invalid provider name %q", req.Name))
+	}
+	if strings.TrimSpace(req.Command) == "" {
+		return domain.NewEngineError(domain.ErrProviderInvalid.Code, "command is required")
+	}
+	if req.Adapter != "" && !mcp.ValidAdapter(req.Adapter) {
+		return domain.NewEngineError(domain.ErrProviderInvalid.Code,
+			fmt.Sprintf("unknown adapter %q (want one of %s)", req.Adapter, strings.Join(mcp.AdapterNames(), ", ")))
+	}
+	for k, v := range req.Env {
+		if k == "" || strings.ContainsAny(k, "=\x00") {
+			return domain.NewEngineError(domain.ErrProviderInvalid.Code,
+				fmt.Sprintf("invalid env variable name %q", k))
+		}
+		if _, _, ok := secrets.ParseRef(v); !ok && strings.HasPrefix(v, "${") {
+			return domain.NewEngineError(domain.ErrProviderInvalid.Code,
+				fmt.Sprintf("env %s: malformed secret reference %q", k, v))
+		}
+	}
+	return nil
+}
+
+// auditProvider records a change to the runtime providers.
+func (h *Handler) auditProvider(ctx context.Context, actor, action string, request interface{}) {
+	now := h.now()
+	req, _ := json.Marshal(request)
+	_ = (&store.AuditRepo{}).Record(ctx, h.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-%d", time.Now().UnixNano()),
+		Category:     "provider",
+		Actor:        actor,
+		Action:       action,
+		RequestJSON:  string(req),
+		DecisionJSON: "{}",
+		Severity:     "info",
+		CreatedAt:    now.Unix(),
+	})
+}
+
+// Stats handles GET /api/v1/stats. Counters are computed from the database
+// at most once per StatsTTL and served from memory in between.
+func (h *Handler) Stats(w http.ResponseWriter, r *http.Request) {
+	ttl := h.StatsTTL
+	if ttl == 0 {
+		ttl = 10 * time.Second
+	}
+	h.statsMu.Lock()
+	defer h.statsMu.Unlock()
+	now := h.now()
+	if h.stats == nil || now.Sub(h.statsAt) >= ttl {
+		year, month, day := now.UTC().Date()
+		midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		stats, err := (&store.StatsRepo{}).Compute(r.Context(), h.reader(), midnight.Unix())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		stats.AdvanceP95MS = float64(h.Engine.AdvanceLatency(0.95)) / float64(time.Millisecond)
+		stats.ComputedAt = now.Unix()
+		h.stats, h.statsAt = stats, now
+	}
+	writeJSON(w, http.StatusOK, h.stats)
+}
+
+// GetFlow handles GET /api/v1/flow/{taskID}.
+func (h *Handler) GetFlow(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("taskID")
+	state, err := h.Engine.GetState(r.Context(), taskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// GetPolicy handles GET /api/v1/flow/{taskID}/policy, returning the
+// capability policy in effect for the flow's workers.
+func (h *Handler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	state, err := h.Engine.GetState(r.Context(), r.PathValue("taskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.Guard.Broker.EffectivePolicy(state.TaskID))
+}
+
+// GetLimits handles GET /api/v1/flow/{taskID}/limits, reporting the rate
+// limits that apply to the flow and the tokens left in each.
+func (h *Handler) GetLimits(w http.ResponseWriter, r *http.Request) {
+	state, err := h.Engine.GetState(r.Context(), r.PathValue("taskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	limits, err := h.Guard.Limits(r.Context(), state.TaskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, limits)
+}
+
+// VerifyAudit handles GET /api/v1/flow/{taskID}/audit/verify, checking
+// the flow's audit chain for deleted or altered records.
+func (h *Handler) VerifyAudit(w http.ResponseWriter, r *http.Request) {
+	state, err := h.Engine.GetState(r.Context(), r.PathValue("taskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	report, err := (&store.AuditRepo{}).Verify(r.Context(), h.reader(), state.TaskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// PurgeFlow handles POST /api/v1/flow/{taskID}/purge, erasing a finished
+// flow's rows and files for a data deletion request. With ?dry_run=true it
+// erases nothing and reports what it would.
+func (h *Handler) PurgeFlow(w http.ResponseWriter, r *http.Request) {
+	var req PurgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Actor == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "actor is required"})
+		return
+	}
+	dry, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	purger := h.Purger
+	if purger == nil {
+		purger = purge.NewPurger(h.DB)
+	}
+	report, err := purger.Purge(r.Context(), r.PathValue("taskID"), req.Actor, dry)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// RecordApproval handles POST /api/v1/flow/{taskID}/approvals, recording a
+// human decision on the flow's current phase. A rejection sends the flow
+// back for rework; the response says where to, or why it could not.
+func (h *Handler) RecordApproval(w http.ResponseWriter, r *http.Request) {
+	var req ApprovalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	out, err := h.Approvals.Record(r.Context(), r.PathValue("taskID"), req.Actor, req.Decision, req.Comment)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, out)
+}
+
+// ListApprovals handles GET /api/v1/flow/{taskID}/approvals.
+func (h *Handler) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	state, err := h.Engine.GetState(r.Context(), r.PathValue("taskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	approvals, err := h.Approvals.Repo.ListByTask(r.Context(), h.reader(), state.TaskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if approvals == nil {
+		approvals = []domain.Approval{}
+	}
+	writeJSON(w, http.StatusOK, approvals)
+}
+
+// ListPhaseDurations handles GET /api/v1/flow/{taskID}/phases, returning
+// the flow's stays in each phase in order of entry.
+func (h *Handler) ListPhaseDurations(w http.ResponseWriter, r *http.Request) {
+	stays, err := h.Engine.PhaseDurations(r.Context(), r.PathValue("taskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if stays == nil {
+		stays = []domain.PhaseDuration{}
+	}
+	writeJSON(w, http.StatusOK, stays)
+}
+
+// GetReport handles GET /api/v1/flow/{taskID}/report?format=F, rendering
+// the flow's run report as Markdown (the default) or, with format=html, as
+// an HTML page.
+func (h *Handler) GetReport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "md"
+	}
+	if format != "md" && format != "html" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "format must be md or html"})
+		return
+	}
+	gen := h.Reports
+	if gen == nil {
+		gen = report.New(h.DB, h.Engine, h.Blobs)
+	}
+	rep, err := gen.Build(r.Context(), r.PathValue("taskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if format == "md" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(rep.Markdown()))
+		return
+	}
+	page, err := rep.HTML()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(page))
+}
+
+// PhaseStats handles GET /api/v1/phases/stats, returning fleet-wide
+// duration statistics and SLA breaches per phase.
+func (h *Handler) PhaseStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.Engine.PhaseDurationStats(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if stats == nil {
+		stats = []domain.PhaseDurationStats{}
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// ListGates handles GET /api/v1/flow/{taskID}/gates?phase=P&limit=N,
+// returning the flow's recorded gate decisions, newest first.
+func (h *Handler) ListGates(w http.ResponseWriter, r *http.Request) {
+	state, err := h.Engine.GetState(r.Context(), r.PathValue("taskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	q := r.URL.Query()
+	limit := 0
+	if s := q.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 0 {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "limit must be a non-negative number"})
+			return
+		}
+	}
+	records, err := h.Engine.GateRepo.ListByTask(r.Context(), h.reader(), state.TaskID, domain.Phase(q.Get("phase")), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if records == nil {
+		records = []domain.GateRecord{}
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+// CreateFlow handles POST /api/v1/flow.
+func (h *Handler) CreateFlow(w http.ResponseWriter, r *http.Request) {
+	var req CreateFlowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.TaskID == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "task_id is required"})
+		return
+	}
+	if req.BudgetCapUSD <= 0 {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "budget_cap_usd must be positive"})
+		return
+	}
+	if ns := r.PathValue("namespace"); ns != "" {
+		if req.Namespace != "" && req.Namespace != ns {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "namespace does not match the path"})
+			return
+		}
+		req.Namespace = ns
+	}
+	if req.Namespace != "" && !domain.ValidNamespace(req.Namespace) {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid namespace"})
+		return
+	}
+	if req.Consensus != nil {
+		if problems := h.Consensus.For(req.Consensus).Settings().Problems(); len(problems) > 0 {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "consensus: " + strings.Join(problems, "; ")})
+			return
+		}
+	}
+
+	opts := workflow.FlowOptions{
+		AutoAdvance: req.AutoAdvance,
+		Queued:      req.Queued,
+		StartAt:     req.StartAt,
+		Priority:    req.Priority,
+
+		Title:              req.Title,
+		Description:        req.Description,
+		AcceptanceCriteria: req.AcceptanceCriteria,
+		Consensus:          req.Consensus,
+		Namespace:          req.Namespace,
+		ShareWorkspace:     req.ShareWorkspace,
+	}
+	if err := h.Engine.StartFlowWithOptions(r.Context(), req.TaskID, req.BudgetCapUSD, opts); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	state, err := h.Engine.GetState(r.Context(), req.TaskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, state)
+}
+
+// ListFlows handles GET /api/v1/flow?namespace= and
+// GET /api/v1/ns/{namespace}/flow, listing the flows of a namespace.
+func (h *Handler) ListFlows(w http.ResponseWriter, r *http.Request) {
+	ns := r.PathValue("namespace")
+	if ns == "" {
+		ns = r.URL.Query().Get("namespace")
+	}
+	if ns == "" {
+		ns = domain.DefaultNamespace
+	}
+	flows, err := h.Engine.ListFlows(r.Context(), ns)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if flows == nil {
+		flows = []*domain.FlowState{}
+	}
+	writeJSON(w, http.StatusOK, flows)
+}
+
+// ListNamespaces handles GET /api/v1/ns.
+func (h *Handler) ListNamespaces(w http.ResponseWriter, r *http.Request) {
+	summaries, err := h.Engine.Namespaces(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if summaries == nil {
+		summaries = []domain.NamespaceSummary{}
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// inNamespace serves a flow route under /api/v1/ns/{namespace}. The
+// namespace must be valid, and a flow named in the path must belong to it;
+// a flow of another namespace is reported as not found.
+func (h *Handler) inNamespace(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ns := r.PathValue("namespace")
+		if !domain.ValidNamespace(ns) {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid namespace"})
+			return
+		}
+		if taskID := r.PathValue("taskID"); taskID != "" {
+			state, err := h.Engine.GetState(r.Context(), taskID)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			if state.Namespace != ns {
+				writeError(w, domain.ErrFlowNotFound)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// AdvanceFlow handles POST /api/v1/flow/{taskID}/advance. With
+// ?dry_run=true it changes nothing and responds with the AdvancePreview.
+func (h *Handler) AdvanceFlow(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("taskID")
+	var req AdvanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Action == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "action is required"})
+		return
+	}
+
+	trigger := domain.TransitionTrigger{
+		Action:     req.Action,
+		Actor:      req.Actor,
+		RollbackTo: domain.Phase(req.RollbackTo),
+	}
+	if dry, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dry {
+		preview, err := h.Engine.Preview(r.Context(), taskID, trigger)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, preview)
+		return
+	}
+	if err := h.Engine.Advance(r.Context(), taskID, trigger); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetBudget handles PUT /api/v1/flow/{taskID}/budget, changing the flow's
+// budget cap. Raising it lets a flow blocked on its budget be unblocked.
+func (h *Handler) SetBudget(w http.ResponseWriter, r *http.Request) {
+	var req BudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	taskID := r.PathValue("taskID")
+	if err := h.Engine.SetBudgetCap(r.Context(), taskID, req.BudgetCapUSD); err != nil {
+		writeError(w, err)
+		return
+	}
+	state, err := h.Engine.GetState(r.Context(), taskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// Precheck handles POST /api/v1/flow/{taskID}/precheck, explaining what
+// would stop the flow: its gate decision and the verdict of every guard
+// rule on the given action. Nothing is evaluated for real.
+func (h *Handler) Precheck(w http.ResponseWriter, r *http.Request) {
+	var req PrecheckRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+			return
+		}
+	}
+	if req.Action == "" {
+		req.Action = "advance"
+	}
+	taskID := r.PathValue("taskID")
+	preview, err := h.Engine.Preview(r.Context(), taskID, domain.TransitionTrigger{
+		Action:     req.Action,
+		RollbackTo: domain.Phase(req.RollbackTo),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var sheet *domain.CapabilitySheet
+	if req.WorkerID != "" {
+		worker, err := h.WorkerRepo.GetByID(r.Context(), h.DB, req.WorkerID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if worker.TaskID != taskID {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "worker belongs to another flow"})
+			return
+		}
+		sheet = worker.Capabilities
+	}
+	if sheet == nil {
+		var commands []string
+		if h.Exec != nil {
+			commands = h.Exec.AllowedCommands
+		}
+		sheet = h.Guard.Broker.BuildCapabilitySheet(taskID, []string{"./"}, commands)
+	}
+	rules, err := h.Guard.Evaluate(r.Context(), taskID, req.Path, req.Command, sheet)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := PrecheckResponse{Allow: preview.Allow, Advance: preview, Rules: rules}
+	for _, rule := range rules {
+		resp.Allow = resp.Allow && rule.Allowed
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// CreateChild handles POST /api/v1/flow/{taskID}/children.
+func (h *Handler) CreateChild(w http.ResponseWriter, r *http.Request) {
+	parentID := r.PathValue("taskID")
+	var req CreateChildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.TaskID == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "task_id is required"})
+		return
+	}
+
+	opts := workflow.FlowOptions{AutoAdvance: req.AutoAdvance, Priority: req.Priority}
+	if err := h.Engine.SpawnChild(r.Context(), parentID, req.TaskID, req.BudgetFraction, opts); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	state, err := h.Engine.GetState(r.Context(), req.TaskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, state)
+}
+
+// CloneFlow handles POST /api/v1/flow/{taskID}/clone?from_phase=P. It
+// creates the flow named in the body from the source's snapshot of phase P,
+// carrying its spec and artifacts, with a fresh budget.
+func (h *Handler) CloneFlow(w http.ResponseWriter, r *http.Request) {
+	fromPhase := domain.Phase(r.URL.Query().Get("from_phase"))
+	if fromPhase == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "from_phase is required"})
+		return
+	}
+	var req CloneFlowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.TaskID == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "task_id is required"})
+		return
+	}
+
+	if err := h.Engine.CloneFlow(r.Context(), r.PathValue("taskID"), req.TaskID, fromPhase, req.BudgetCapUSD); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	state, err := h.Engine.GetState(r.Context(), req.TaskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, state)
+}
+
+// FailFlow handles POST /api/v1/flow/{taskID}/fail, marking the flow failed
+// with the cause given and returning its post-mortem.
+func (h *Handler) FailFlow(w http.ResponseWriter, r *http.Request) {
+	var req FailFlowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Cause == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "cause is required"})
+		return
+	}
+	pm, err := h.Engine.Fail(r.Context(), r.PathValue("taskID"), req.Cause, req.Detail, req.Actor)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pm)
+}
+
+// GetPostMortem handles GET /api/v1/flow/{taskID}/postmortem.
+func (h *Handler) GetPostMortem(w http.ResponseWriter, r *http.Request) {
+	pm, err := h.Engine.PostMortem(r.Context(), r.PathValue("taskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pm)
+}
+
+// ListChildren handles GET /api/v1/flow/{taskID}/children.
+func (h *Handler) ListChildren(w http.ResponseWriter, r *http.Request) {
+	children, err := h.Engine.ListChildren(r.Context(), r.PathValue("taskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if children == nil {
+		children = []*domain.FlowState{}
+	}
+	writeJSON(w, http.StatusOK, children)
+}
+
+// GetQueue handles GET /api/v1/queue.
+func (h *Handler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	status, err := h.Scheduler.Status(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// GetWorkerQueue handles GET /api/v1/workers/queue.
+func (h *Handler) GetWorkerQueue(w http.ResponseWriter, r *http.Request) {
+	stats := team.WorkerQueueStats{ByRole: map[string]int{}}
+	if h.Workers != nil {
+		stats = h.Workers.QueueStats()
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// WorkerProgressRequest is the body for POST /api/v1/workers/{workerID}/progress.
+type WorkerProgressRequest struct {
+	Percent     int    `json:"percent"`
+	CurrentFile string `json:"current_file"`
+	Note        string `json:"note"`
+}
+
+// ReportProgress handles POST /api/v1/workers/{workerID}/progress.
+func (h *Handler) ReportProgress(w http.ResponseWriter, r *http.Request) {
+	var req WorkerProgressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Percent < 0 || req.Percent > 100 {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "percent must be between 0 and 100"})
+		return
+	}
+	progress := domain.WorkerProgress{
+		Percent:     req.Percent,
+		CurrentFile: req.CurrentFile,
+		Note:        req.Note,
+		UpdatedAt:   h.now().Unix(),
+	}
+	if err := h.WorkerRepo.UpdateProgress(r.Context(), h.DB, r.PathValue("workerID"), progress); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, progress)
+}
+
+// SubmitArtifactRequest is the body for POST /api/v1/workers/{workerID}/artifacts.
+// Either Hash or Content is required; Content, when given, is stored in the
+// blob store and its SHA-256 becomes the hash.
+type SubmitArtifactRequest struct {
+	Type    string `json:"type"`
+	Path    string `json:"path"`
+	Hash    string `json:"hash,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// SubmitArtifact handles POST /api/v1/workers/{workerID}/artifacts. The
+// artifact is recorded against the worker's task and phase as the next
+// version of its path.
+func (h *Handler) SubmitArtifact(w http.ResponseWriter, r *http.Request) {
+	var req SubmitArtifactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Type == "" || req.Path == "" || (req.Hash == "" && req.Content == "") {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "type, path, and hash or content are required"})
+		return
+	}
+	if req.Content != "" {
+		hash := artifact.Hash([]byte(req.Content))
+		if req.Hash != "" && req.Hash != hash {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "hash does not match content"})
+			return
+		}
+		req.Hash = hash
+	}
+	worker, err := h.WorkerRepo.GetByID(r.Context(), h.DB, r.PathValue("workerID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Content != "" && h.Blobs != nil {
+		if _, err := h.Blobs.Put([]byte(req.Content)); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	now := h.now()
+	artifact, err := h.ArtifactRepo.Create(r.Context(), h.DB, domain.ArtifactRef{
+		ID:        fmt.Sprintf("art-%d", time.Now().UnixNano()),
+		TaskID:    worker.TaskID,
+		WorkerID:  worker.WorkerID,
+		Phase:     worker.Phase,
+		Type:      req.Type,
+		Path:      req.Path,
+		Hash:      req.Hash,
+		CreatedAt: now.Unix(),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, artifact)
+}
+
+// ExecCommand handles POST /api/v1/workers/{workerID}/exec. The command's
+// exit code is part of the result; errors mean it was refused.
+func (h *Handler) ExecCommand(w http.ResponseWriter, r *http.Request) {
+	var req sandbox.ExecRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Command == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "command is required"})
+		return
+	}
+	result, err := h.Exec.Exec(r.Context(), r.PathValue("workerID"), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// ListFiles handles GET /api/v1/workers/{workerID}/files?path=P, listing a
+// directory of the worker's workspace (the root by default).
+func (h *Handler) ListFiles(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.Files.List(r.Context(), r.PathValue("workerID"), r.URL.Query().Get("path"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// ReadFile handles GET /api/v1/workers/{workerID}/files/content?path=P.
+func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "path is required"})
+		return
+	}
+	content, err := h.Files.Read(r.Context(), r.PathValue("workerID"), path)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, content)
+}
+
+// WriteFile handles PUT /api/v1/workers/{workerID}/files/content. A write
+// queued behind another intent on the file returns 202 without writing.
+func (h *Handler) WriteFile(w http.ResponseWriter, r *http.Request) {
+	var req sandbox.WriteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Path == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "path is required"})
+		return
+	}
+	result, err := h.Files.Write(r.Context(), r.PathValue("workerID"), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if result.Queued {
+		status = http.StatusAccepted
+	}
+	writeJSON(w, status, result)
+}
+
+// GetArtifact handles GET /api/v1/artifacts/{artifactID}.
+func (h *Handler) GetArtifact(w http.ResponseWriter, r *http.Request) {
+	ref, err := h.ArtifactRepo.GetByID(r.Context(), h.reader(), r.PathValue("artifactID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ref)
+}
+
+// GetArtifactContent handles GET /api/v1/artifacts/{artifactID}/content. It
+// serves the stored blob with the artifact's hash as ETag; artifacts
+// submitted by hash alone have no content and return 404.
+func (h *Handler) GetArtifactContent(w http.ResponseWriter, r *http.Request) {
+	ref, err := h.ArtifactRepo.GetByID(r.Context(), h.reader(), r.PathValue("artifactID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if h.Blobs == nil {
+		writeError(w, domain.ErrArtifactNotFound)
+		return
+	}
+	f, err := h.Blobs.Open(ref.Hash)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("ETag", strconv.Quote(ref.Hash))
+	http.ServeContent(w, r, path.Base(ref.Path), time.Unix(ref.CreatedAt, 0), f)
+}
+
+// GetDigest handles GET /api/v1/flow/{taskID}/digest?role=R&phase=P&format=F.
+// It builds the ContextDigest a worker with role R would be given in phase P,
+// the flow's current phase by default, as JSON or, with format=markdown, as
+// a Markdown brief.
+func (h *Handler) GetDigest(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	role := q.Get("role")
+	if role == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "role is required"})
+		return
+	}
+	format := q.Get("format")
+	if format == "" {
+		format = team.DigestJSON
+	}
+	if format != team.DigestJSON && format != team.DigestMarkdown {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "format must be json or markdown"})
+		return
+	}
+	state, err := h.Engine.GetState(r.Context(), r.PathValue("taskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	phase := state.CurrentPhase
+	if p := q.Get("phase"); p != "" {
+		phase = domain.Phase(p)
+		if len(p) != 1 || phase < domain.PhaseA || phase > domain.PhaseG {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "phase must be one of A-G"})
+			return
+		}
+	}
+	digest, err := h.Digests.Build(r.Context(), state.TaskID, phase, domain.WorkerSpec{
+		TaskID: state.TaskID,
+		Phase:  phase,
+		Role:   role,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if format == team.DigestJSON {
+		writeJSON(w, http.StatusOK, digest)
+		return
+	}
+	data, err := team.RenderDigest(digest, format)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// AddConstraintRequest is the body for POST /api/v1/flow/{taskID}/constraints.
+type AddConstraintRequest struct {
+	Text string `json:"text"`
+}
+
+// ListConstraints handles GET /api/v1/flow/{taskID}/constraints?status=S.
+func (h *Handler) ListConstraints(w http.ResponseWriter, r *http.Request) {
+	constraints, err := h.ConstraintRepo.ListByTask(r.Context(), h.reader(), r.PathValue("taskID"), r.URL.Query().Get("status"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if constraints == nil {
+		constraints = []domain.Constraint{}
+	}
+	writeJSON(w, http.StatusOK, constraints)
+}
+
+// AddConstraint handles POST /api/v1/flow/{taskID}/constraints.
+func (h *Handler) AddConstraint(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("taskID")
+	var req AddConstraintRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Text == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "text is required"})
+		return
+	}
+	if _, err := h.Engine.GetState(r.Context(), taskID); err != nil {
+		writeError(w, err)
+		return
+	}
+	now := h.now()
+	c := domain.Constraint{
+		ConstraintID: fmt.Sprintf("con-%d", time.Now().UnixNano()),
+		TaskID:       taskID,
+		Text:         req.Text,
+		Status:       "active",
+		CreatedAt:    now.Unix(),
+	}
+	if err := h.ConstraintRepo.Create(r.Context(), h.DB, c); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// ResolveConstraint handles POST /api/v1/flow/{taskID}/constraints/{constraintID}/resolve.
+func (h *Handler) ResolveConstraint(w http.ResponseWriter, r *http.Request) {
+	c, err := h.ConstraintRepo.GetByID(r.Context(), h.DB, r.PathValue("constraintID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if c.TaskID != r.PathValue("taskID") {
+		writeError(w, domain.ErrConstraintNotFound)
+		return
+	}
+	if err := h.ConstraintRepo.Resolve(r.Context(), h.DB, c.ConstraintID, h.now().Unix()); err != nil {
+		writeError(w, err)
+		return
+	}
+	c, err = h.ConstraintRepo.GetByID(r.Context(), h.DB, c.ConstraintID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// AddRiskRequest is the body for POST /api/v1/flow/{taskID}/risks. Severity
+// is P0, P1, or P2; open P0 risks block the review gates.
+type AddRiskRequest struct {
+	Severity string `json:"severity"`
+	Text     string `json:"text"`
+	Actor    string `json:"actor,omitempty"`
+}
+
+// ResolveRiskRequest is the body for POST /api/v1/flow/{taskID}/risks/{riskID}/resolve.
+type ResolveRiskRequest struct {
+	Actor string `json:"actor"`
+}
+
+// ListRisks handles GET /api/v1/flow/{taskID}/risks?status=S.
+func (h *Handler) ListRisks(w http.ResponseWriter, r *http.Request) {
+	risks, err := h.RiskRepo.ListByTask(r.Context(), h.reader(), r.PathValue("taskID"), r.URL.Query().Get("status"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if risks == nil {
+		risks = []domain.Risk{}
+	}
+	writeJSON(w, http.StatusOK, risks)
+}
+
+// AddRisk handles POST /api/v1/flow/{taskID}/risks. The risk is stamped with
+// the flow's current phase.
+func (h *Handler) AddRisk(w http.ResponseWriter, r *http.Request) {
+	var req AddRiskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Text == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "text is required"})
+		return
+	}
+	switch req.Severity {
+	case "P0", "P1", "P2":
+	default:
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "severity must be one of P0, P1, P2"})
+		return
+	}
+	state, err := h.Engine.GetState(r.Context(), r.PathValue("taskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	now := h.now()
+	k := domain.Risk{
+		RiskID:    fmt.Sprintf("risk-%d", time.Now().UnixNano()),
+		TaskID:    state.TaskID,
+		Phase:     state.CurrentPhase,
+		Severity:  req.Severity,
+		Text:      req.Text,
+		Status:    "open",
+		Actor:     req.Actor,
+		CreatedAt: now.Unix(),
+	}
+	if err := h.RiskRepo.Create(r.Context(), h.DB, k); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, k)
+}
+
+// ResolveRisk handles POST /api/v1/flow/{taskID}/risks/{riskID}/resolve.
+func (h *Handler) ResolveRisk(w http.ResponseWriter, r *http.Request) {
+	var req ResolveRiskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Actor == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "actor is required"})
+		return
+	}
+	k, err := h.RiskRepo.GetByID(r.Context(), h.DB, r.PathValue("riskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if k.TaskID != r.PathValue("taskID") {
+		writeError(w, domain.ErrRiskNotFound)
+		return
+	}
+	if err := h.RiskRepo.Resolve(r.Context(), h.DB, k.RiskID, req.Actor, h.now().Unix()); err != nil {
+		writeError(w, err)
+		return
+	}
+	// Resolving a risk may unblock a review gate.
+	h.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicReviewSubmitted, TaskID: k.TaskID})
+	k, err = h.RiskRepo.GetByID(r.Context(), h.DB, k.RiskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, k)
+}
+
+// ListWorkers handles GET /api/v1/flow/{taskID}/workers.
+func (h *Handler) ListWorkers(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("taskID")
+	workers, err := h.WorkerRepo.ListByTask(r.Context(), h.reader(), taskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if workers == nil {
+		workers = []*domain.WorkerRef{}
+	}
+	writeJSON(w, http.StatusOK, workers)
+}
+
+// IntentWaiter is a queued intent and its place in its file's queue.
+type IntentWaiter struct {
+	IntentID   string `json:"intentId"`
+	WorkerID   string `json:"workerId"`
+	TargetFile string `json:"targetFile"`
+	Position   int    `json:"position"`
+	QueuedAt   int64  `json:"queuedAt"`
+	QueueUntil int64  `json:"queueUntil,omitempty"`
+}
+
+// IntentsResponse is the body of GET /api/v1/flow/{taskID}/intents.
+type IntentsResponse struct {
+	Intents []domain.Intent `json:"intents"`
+	Waiters []IntentWaiter  `json:"waiters"`
+}
+
+// ListIntents handles GET /api/v1/flow/{taskID}/intents?status=S. Waiters
+// lists the queued intents of every file in the order they will be granted.
+func (h *Handler) ListIntents(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("taskID")
+	var intents []domain.Intent
+	var err error
+	if status := r.URL.Query().Get("status"); status != "" {
+		intents, err = h.IntentRepo.ListByTaskStatus(r.Context(), h.reader(), taskID, status)
+	} else {
+		intents, err = h.IntentRepo.ListByTask(r.Context(), h.reader(), taskID)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	queued, err := h.IntentRepo.ListWaiters(r.Context(), h.reader(), taskID, "")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := IntentsResponse{Intents: intents, Waiters: []IntentWaiter{}}
+	if resp.Intents == nil {
+		resp.Intents = []domain.Intent{}
+	}
+	position := make(map[string]int)
+	for _, q := range queued {
+		position[q.TargetFile]++
+		resp.Waiters = append(resp.Waiters, IntentWaiter{
+			IntentID:   q.IntentID,
+			WorkerID:   q.WorkerID,
+			TargetFile: q.TargetFile,
+			Position:   position[q.TargetFile],
+			QueuedAt:   q.QueuedAt,
+			QueueUntil: q.QueueUntil,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DecideRequest is the body for POST /api/v1/flow/{taskID}/decisions/{decisionID}.
+// For an intent_conflict decision Choice is the ID of the intent to keep.
+type DecideRequest struct {
+	Actor   string `json:"actor"`
+	Choice  string `json:"choice"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// ListDecisions handles GET /api/v1/flow/{taskID}/decisions?status=S.
+func (h *Handler) ListDecisions(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("taskID")
+	decisions, err := h.DecisionRepo.ListByTask(r.Context(), h.reader(), taskID, r.URL.Query().Get("status"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if decisions == nil {
+		decisions = []domain.Decision{}
+	}
+	writeJSON(w, http.StatusOK, decisions)
+}
+
+// Decide handles POST /api/v1/flow/{taskID}/decisions/{decisionID}.
+func (h *Handler) Decide(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("taskID")
+	var req DecideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Actor == "" || req.Choice == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "actor and choice are required"})
+		return
+	}
+	decision, err := h.DecisionRepo.GetByID(r.Context(), h.DB, r.PathValue("decisionID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if decision.TaskID != taskID {
+		writeError(w, domain.ErrDecisionNotFound)
+		return
+	}
+	if decision.Kind != "intent_conflict" || h.Conflicts == nil {
+		writeError(w, domain.ErrDecisionInvalid)
+		return
+	}
+	if err := h.Conflicts.Decide(r.Context(), decision.DecisionID, req.Choice, req.Actor, req.Comment); err != nil {
+		writeError(w, err)
+		return
+	}
+	decision, err = h.DecisionRepo.GetByID(r.Context(), h.DB, decision.DecisionID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, decision)
+}
+
+// AppendEventRequest is the body for POST /api/v1/flow/{taskID}/events.
+// Type is a configured custom event type such as "ci.build"; Payload must
+// match its schema and defaults to an empty object.
+type AppendEventRequest struct {
+	Type    domain.EventType `json:"type"`
+	Payload json.RawMessage  `json:"payload,omitempty"`
+}
+
+// AppendEvent handles POST /api/v1/flow/{taskID}/events. It records a
+// custom event from an external system at the flow's next sequence number
+// and returns it.
+func (h *Handler) AppendEvent(w http.ResponseWriter, r *http.Request) {
+	var req AppendEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Type == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "type is required"})
+		return
+	}
+	payload := string(req.Payload)
+	if payload == "" {
+		payload = "{}"
+	}
+	event, err := h.Engine.IngestEvent(r.Context(), r.PathValue("taskID"), req.Type, payload)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, event)
+}
+
+// ListCIChecks handles GET /api/v1/flow/{taskID}/ci.
+func (h *Handler) ListCIChecks(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("taskID")
+	if _, err := h.Engine.GetState(r.Context(), taskID); err != nil {
+		writeError(w, err)
+		return
+	}
+	checks, err := h.CIChecks.ListByTask(r.Context(), h.reader(), taskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if checks == nil {
+		checks = []domain.CICheck{}
+	}
+	writeJSON(w, http.StatusOK, checks)
+}
+
+// CIWebhook handles POST /api/v1/ci/webhook: a CI system reporting check
+// results for a flow's branch as a ci.Report, signed in the
+// X-Threebody-Signature (or X-Hub-Signature-256) header. Flows whose checks
+// changed have their gates evaluated again.
+func (h *Handler) CIWebhook(w http.ResponseWriter, r *http.Request) {
+	if len(h.CISecret) == 0 {
+		writeJSON(w, http.StatusNotFound, APIError{Code: 404, Message: "CI webhook is not configured"})
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	sig := r.Header.Get(ci.SignatureHeader)
+	if sig == "" {
+		sig = r.Header.Get("X-Hub-Signature-256")
+	}
+	if !ci.Verify(h.CISecret, body, sig) {
+		writeJSON(w, http.StatusUnauthorized, APIError{Code: 401, Message: "invalid signature"})
+		return
+	}
+	var report ci.Report
+	if err := json.Unmarshal(body, &report); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if err := report.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: err.Error()})
+		return
+	}
+	taskID := report.TaskID
+	if taskID == "" {
+		if !strings.HasPrefix(report.Branch, h.CIBranchPrefix) || report.Branch == h.CIBranchPrefix {
+			writeJSON(w, http.StatusUnprocessableEntity, APIError{Code: 422, Message: fmt.Sprintf("branch %q is not a flow branch", report.Branch)})
+			return
+		}
+		taskID = strings.TrimPrefix(report.Branch, h.CIBranchPrefix)
+	}
+	if _, err := h.Engine.GetState(r.Context(), taskID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	changed := false
+	now := h.now().Unix()
+	for _, c := range report.Checks {
+		ok, err := h.CIChecks.Upsert(r.Context(), h.DB, domain.CICheck{TaskID: taskID, Name: c.Name, Status: c.Status, URL: c.URL, UpdatedAt: now})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		changed = changed || ok
+	}
+	if changed {
+		h.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicCIReported, TaskID: taskID})
+	}
+	checks, err := h.CIChecks.ListByTask(r.Context(), h.DB, taskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, checks)
+}
+
+// maxEventWait caps the ?wait= of ListEvents.
+const maxEventWait = 60 * time.Second
+
+// eventPollInterval is how often a waiting ListEvents checks for new events.
+const eventPollInterval = 250 * time.Millisecond
+
+// ListEvents handles GET /api/v1/flow/{taskID}/events?since_seq=N. The
+// events can be narrowed with ?event_type= (comma-separated), ?phase=, and
+// ?payload.<field>=<value>, where field may be a dotted path.
+//
+// With ?wait=30s, a request that finds no events holds until one matches or
+// the wait (at most maxEventWait) ends. Every response carries a
+// Resume-Token header naming the flow, the last event returned, and the
+// filter; passing it back as ?resume= continues the listing after that
+// event with the same filter, in place of since_seq and the filter
+// parameters.
+func (h *Handler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("taskID")
+	q := r.URL.Query()
+	filter, err := eventFilter(q)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: err.Error()})
+		return
+	}
+	if s := q.Get("resume"); s != "" {
+		token, err := decodeResumeToken(s)
+		if err != nil || token.TaskID != taskID {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid resume token"})
+			return
+		}
+		filter = token.Filter
+		filter.SinceSeq = token.Seq
+	}
+	var wait time.Duration
+	if s := q.Get("wait"); s != "" {
+		wait, err = time.ParseDuration(s)
+		if err != nil || wait < 0 {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "wait must be a duration such as 30s"})
+			return
+		}
+		if wait > maxEventWait {
+			wait = maxEventWait
+		}
+	}
+
+	events, err := h.waitForEvents(r.Context(), taskID, filter, wait)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if events == nil {
+		events = []domain.WorkflowEvent{}
+	}
+	next := resumeToken{TaskID: taskID, Seq: filter.SinceSeq, Filter: filter}
+	if len(events) > 0 {
+		next.Seq = events[len(events)-1].SeqNo
+	}
+	next.Filter.SinceSeq = 0
+	w.Header().Set("Resume-Token", next.encode())
+	writeJSON(w, http.StatusOK, events)
+}
+
+// waitForEvents lists the events matching filter, polling for up to wait
+// while there are none. It returns no events, and no error, if the wait
+// ends or ctx is cancelled first.
+func (h *Handler) waitForEvents(ctx context.Context, taskID string, filter store.EventFilter, wait time.Duration) ([]domain.WorkflowEvent, error) {
+	events, err := h.EventRepo.List(ctx, h.reader(), taskID, filter)
+	if err != nil || len(events) > 0 || wait == 0 {
+		return events, err
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-deadline.C:
+			return nil, nil
+		case <-ticker.C:
+			events, err := h.EventRepo.List(ctx, h.reader(), taskID, filter)
+			if err != nil || len(events) > 0 {
+				return events, err
+			}
+		}
+	}
+}
+
+// resumeToken is where an event listing left off. It is handed to clients
+// as opaque base64 JSON.
+type resumeToken struct {
+	TaskID string            `json:"t"`
+	Seq    int64             `json:"s"`
+	Filter store.EventFilter `json:"f"`
+}
+
+func (t resumeToken) encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeResumeToken(s string) (resumeToken, error) {
+	var t resumeToken
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, err
+	}
+	return t, t.Filter.Validate()
+}
+
+// eventFilter reads the ListEvents query parameters.
+func eventFilter(q url.Values) (store.EventFilter, error) {
+	var f store.EventFilter
+	if s := q.Get("since_seq"); s != "" {
+		if parsed, err := strconv.ParseInt(s, 10, 64); err == nil {
+			f.SinceSeq = parsed
+		}
+	}
+	for _, list := range q["event_type"] {
+		for _, t := range strings.Split(list, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				f.Types = append(f.Types, domain.EventType(t))
+			}
+		}
+	}
+	f.Phase = domain.Phase(q.Get("phase"))
+	for key, values := range q {
+		field, ok := strings.CutPrefix(key, "payload.")
+		if !ok {
+			continue
+		}
+		if f.Payload == nil {
+			f.Payload = make(map[string]string)
+		}
+		f.Payload[field] = values[0]
+	}
+	return f, f.Validate()
 }
 
-// GetFlow handles GET /api/v1/flow/{taskID}.
-func (h *Handler) GetFlow(w http.ResponseWriter, r *http.Request) {
+// ListReviews handles GET /api/v1/flow/{taskID}/reviews.
+func (h *Handler) ListReviews(w http.ResponseWriter, r *http.Request) {
 	taskID := r.PathValue("taskID")
-	state, err := h.Engine.GetState(r.Context(), taskID)
+	cards, err := h.ScoreCardRepo.ListByTask(r.Context(), h.reader(), taskID)
 	if err != nil {
 		writeError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, state)
+	if cards == nil {
+		cards = []domain.ScoreCard{}
+	}
+	writeJSON(w, http.StatusOK, cards)
 }
 
-// CreateFlow handles POST /api/v1/flow.
-func (h *Handler) CreateFlow(w http.ResponseWriter, r *http.Request) {
-	var req CreateFlowRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+// GetConsensus handles GET /api/v1/flow/{taskID}/consensus?phase=P. It
+// evaluates the flow's valid score cards with the flow's consensus settings
+// and the verdict policy of phase, by default the flow's current phase.
+func (h *Handler) GetConsensus(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("taskID")
+	state, err := h.TaskRepo.GetByID(r.Context(), h.reader(), taskID)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	if req.TaskID == "" {
-		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "task_id is required"})
+	phase := state.CurrentPhase
+	if p := r.URL.Query().Get("phase"); p != "" {
+		phase = domain.Phase(p)
+		if len(p) != 1 || phase < domain.PhaseA || phase > domain.PhaseG {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "phase must be one of A-G"})
+			return
+		}
+	}
+	cards, err := h.ScoreCardRepo.ListByTask(r.Context(), h.reader(), taskID)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	if req.BudgetCapUSD <= 0 {
-		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "budget_cap_usd must be positive"})
+	result, err := h.Consensus.For(state.Consensus).EvaluatePhase(cards, phase)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// ReviewRoundResponse is one review round with the score cards submitted in
+// it, including cards a later rollback invalidated.
+type ReviewRoundResponse struct {
+	domain.ReviewRound
+	ScoreCards []domain.ScoreCard `json:"scoreCards"`
+}
 
-	if err := h.Engine.StartFlow(r.Context(), req.TaskID, req.BudgetCapUSD); err != nil {
+// ListRounds handles GET /api/v1/flow/{taskID}/rounds. Rounds that predate
+// round tracking appear only if they have score cards.
+func (h *Handler) ListRounds(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("taskID")
+	db := h.reader()
+	if _, err := h.TaskRepo.GetByID(r.Context(), db, taskID); err != nil {
 		writeError(w, err)
 		return
 	}
-
-	state, err := h.Engine.GetState(r.Context(), req.TaskID)
+	rounds, err := h.RoundRepo.ListByTask(r.Context(), db, taskID)
 	if err != nil {
 		writeError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusCreated, state)
+	cards, err := h.ScoreCardRepo.ListHistory(r.Context(), db, taskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	byRound := make(map[int]*ReviewRoundResponse)
+	for _, rr := range rounds {
+		byRound[rr.Round] = &ReviewRoundResponse{ReviewRound: rr, ScoreCards: []domain.ScoreCard{}}
+	}
+	for _, c := range cards {
+		resp, ok := byRound[c.Round]
+		if !ok {
+			resp = &ReviewRoundResponse{
+				ReviewRound: domain.ReviewRound{TaskID: taskID, Round: c.Round},
+				ScoreCards:  []domain.ScoreCard{},
+			}
+			byRound[c.Round] = resp
+		}
+		resp.ScoreCards = append(resp.ScoreCards, c)
+	}
+	resp := make([]ReviewRoundResponse, 0, len(byRound))
+	for _, rr := range byRound {
+		resp = append(resp, *rr)
+	}
+	sort.Slice(resp, func(i, j int) bool { return resp[i].Round < resp[j].Round })
+	writeJSON(w, http.StatusOK, resp)
 }
 
-// AdvanceFlow handles POST /api/v1/flow/{taskID}/advance.
-func (h *Handler) AdvanceFlow(w http.ResponseWriter, r *http.Request) {
+// DiffReviews handles GET /api/v1/flow/{taskID}/reviews/diff. It compares
+// each reviewer's score card in round to (default: the flow's current round)
+// with round from (default: the reviewer's previous round); reviewer limits
+// the result to one reviewer.
+func (h *Handler) DiffReviews(w http.ResponseWriter, r *http.Request) {
 	taskID := r.PathValue("taskID")
-	var req AdvanceRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+	db := h.reader()
+	state, err := h.TaskRepo.GetByID(r.Context(), db, taskID)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	if req.Action == "" {
-		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "action is required"})
+
+	q := r.URL.Query()
+	from, to := -1, state.Round
+	for name, dst := range map[string]*int{"from": &from, "to": &to} {
+		if s := q.Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: name + " must be a non-negative round"})
+				return
+			}
+			*dst = n
+		}
+	}
+	if from >= 0 && from >= to {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "from must be before to"})
 		return
 	}
 
-	trigger := domain.TransitionTrigger{
-		Action: req.Action,
-		Actor:  req.Actor,
-	}
-	if err := h.Engine.Advance(r.Context(), taskID, trigger); err != nil {
+	cards, err := h.ScoreCardRepo.ListHistory(r.Context(), db, taskID)
+	if err != nil {
 		writeError(w, err)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	diffs := review.DiffRounds(cards, from, to)
+	if reviewer := q.Get("reviewer"); reviewer != "" {
+		filtered := []domain.ScoreCardDiff{}
+		for _, d := range diffs {
+			if d.Reviewer == reviewer {
+				filtered = append(filtered, d)
+			}
+		}
+		diffs = filtered
+	}
+	writeJSON(w, http.StatusOK, diffs)
 }
 
-// ListWorkers handles GET /api/v1/flow/{taskID}/workers.
-func (h *Handler) ListWorkers(w http.ResponseWriter, r *http.Request) {
+// SubmitReview handles POST /api/v1/flow/{taskID}/reviews.
+func (h *Handler) SubmitReview(w http.ResponseWriter, r *http.Request) {
 	taskID := r.PathValue("taskID")
-	workers, err := h.WorkerRepo.ListByTask(r.Context(), h.DB, taskID)
+	var card domain.ScoreCard
+	if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	state, err := h.TaskRepo.GetByID(r.Context(), h.reader(), taskID)
 	if err != nil {
 		writeError(w, err)
 		return
 	}
-	if workers == nil {
-		workers = []*domain.WorkerRef{}
+
+	validator := &review.SchemaValidator{}
+	if err := validator.Validate(card); err != nil {
+		writeError(w, err)
+		return
 	}
-	writeJSON(w, http.StatusOK, workers)
-}
 
-// ListEvents handles GET /api/v1/flow/{taskID}/events?since_seq=N.
-func (h *Handler) ListEvents(w http.ResponseWriter, r *http.Request) {
-	taskID := r.PathValue("taskID")
-	sinceSeq := int64(0)
-	if s := r.URL.Query().Get("since_seq"); s != "" {
-		parsed, err := strconv.ParseInt(s, 10, 64)
-		if err == nil {
-			sinceSeq = parsed
+	card.TaskID = taskID
+	card.Round = state.Round
+	card.CreatedAt = h.now().Unix()
+
+	// Each issue on the card becomes a tracked review issue.
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer tx.Rollback()
+	if err := h.ScoreCardRepo.CreateTx(r.Context(), tx, card); err != nil {
+		writeError(w, err)
+		return
+	}
+	for i, issue := range card.Issues {
+		err := h.IssueRepo.CreateTx(r.Context(), tx, domain.ReviewIssue{
+			IssueID:   fmt.Sprintf("iss-%s-%d", card.ReviewID, i+1),
+			TaskID:    taskID,
+			ReviewID:  card.ReviewID,
+			Reviewer:  card.Reviewer,
+			Round:     card.Round,
+			Issue:     issue,
+			CreatedAt: card.CreatedAt,
+		})
+		if err != nil {
+			writeError(w, err)
+			return
 		}
 	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicReviewSubmitted, TaskID: taskID})
+	writeJSON(w, http.StatusCreated, card)
+}
 
-	events, err := h.EventRepo.ListByTask(r.Context(), h.DB, taskID, sinceSeq)
+// UpdateIssueRequest is the body for POST
+// /api/v1/flow/{taskID}/issues/{issueID}/status. FixIntentID and FixCommit
+// link the change that fixed the issue; wont_fix needs a Note.
+type UpdateIssueRequest struct {
+	Status      string `json:"status"`
+	Actor       string `json:"actor"`
+	Note        string `json:"note,omitempty"`
+	FixIntentID string `json:"fixIntentId,omitempty"`
+	FixCommit   string `json:"fixCommit,omitempty"`
+}
+
+// ListIssues handles GET /api/v1/flow/{taskID}/issues?status=S&severity=P.
+func (h *Handler) ListIssues(w http.ResponseWriter, r *http.Request) {
+	issues, err := h.IssueRepo.ListByTask(r.Context(), h.reader(), r.PathValue("taskID"), r.URL.Query().Get("status"))
 	if err != nil {
 		writeError(w, err)
 		return
 	}
-	if events == nil {
-		events = []domain.WorkflowEvent{}
+	severity := r.URL.Query().Get("severity")
+	filtered := []domain.ReviewIssue{}
+	for _, i := range issues {
+		if severity == "" || i.Severity == severity {
+			filtered = append(filtered, i)
+		}
 	}
-	writeJSON(w, http.StatusOK, events)
+	writeJSON(w, http.StatusOK, filtered)
 }
 
-// ListReviews handles GET /api/v1/flow/{taskID}/reviews.
-func (h *Handler) ListReviews(w http.ResponseWriter, r *http.Request) {
+// UpdateIssue handles POST /api/v1/flow/{taskID}/issues/{issueID}/status.
+func (h *Handler) UpdateIssue(w http.ResponseWriter, r *http.Request) {
 	taskID := r.PathValue("taskID")
-	cards, err := h.ScoreCardRepo.ListByTask(r.Context(), h.DB, taskID)
+	var req UpdateIssueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if req.Actor == "" || req.Status == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "actor and status are required"})
+		return
+	}
+	if req.Status == "wont_fix" && req.Note == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "wont_fix requires a note"})
+		return
+	}
+	issue, err := h.IssueRepo.GetByID(r.Context(), h.DB, r.PathValue("issueID"))
 	if err != nil {
 		writeError(w, err)
 		return
 	}
-	if cards == nil {
-		cards = []domain.ScoreCard{}
+	if issue.TaskID != taskID {
+		writeError(w, domain.ErrIssueNotFound)
+		return
 	}
-	writeJSON(w, http.StatusOK, cards)
+	if !domain.ValidIssueTransition(issue.Status, req.Status) {
+		writeError(w, domain.NewEngineError(domain.ErrIssueTransition.Code,
+			fmt.Sprintf("cannot move issue from %s to %s", issue.Status, req.Status)))
+		return
+	}
+	if req.FixIntentID != "" {
+		intent, err := h.IntentRepo.GetByID(r.Context(), h.DB, req.FixIntentID)
+		if err != nil || intent.TaskID != taskID {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "unknown intent " + req.FixIntentID})
+			return
+		}
+		issue.FixIntentID = req.FixIntentID
+	}
+	if req.FixCommit != "" {
+		issue.FixCommit = req.FixCommit
+	}
+	if req.Note != "" {
+		issue.Note = req.Note
+	}
+	issue.Status = req.Status
+	issue.UpdatedBy = req.Actor
+	issue.UpdatedAt = h.now().Unix()
+	if err := h.IssueRepo.Update(r.Context(), h.DB, *issue); err != nil {
+		writeError(w, err)
+		return
+	}
+	// Closing an issue may unblock the F gate of an auto-advance flow.
+	h.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicReviewSubmitted, TaskID: taskID})
+	writeJSON(w, http.StatusOK, issue)
 }
 
 // GetCost handles GET /api/v1/flow/{taskID}/cost.
 func (h *Handler) GetCost(w http.ResponseWriter, r *http.Request) {
 	taskID := r.PathValue("taskID")
-	state, err := h.TaskRepo.GetByID(r.Context(), h.DB, taskID)
+	state, err := h.TaskRepo.GetByID(r.Context(), h.reader(), taskID)
 	if err != nil {
 		writeError(w, err)
 		return
 	}
 
-	deltas, err := h.CostDeltaRepo.ListByTask(r.Context(), h.DB, taskID)
+	deltas, err := h.CostDeltaRepo.ListByTask(r.Context(), h.reader(), taskID)
 	if err != nil {
 		writeError(w, err)
 		return
@@ -192,6 +2303,12 @@ func (h *Handler) GetCost(w http.ResponseWriter, r *http.Request) {
 		deltas = []domain.CostDelta{}
 	}
 
+	alerts, err := h.BudgetAlertRepo.ListByTask(r.Context(), h.reader(), taskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
 	action, _ := h.Guard.CheckBudget(r.Context(), taskID)
 
 	summary := CostSummary{
@@ -199,11 +2316,254 @@ func (h *Handler) GetCost(w http.ResponseWriter, r *http.Request) {
 		BudgetCapUSD:  state.BudgetCapUSD,
 		CostAction:    action,
 		Deltas:        deltas,
+		Alerts:        alerts,
 	}
 	writeJSON(w, http.StatusOK, summary)
 }
 
-// StreamEvents handles GET /api/v1/flow/{taskID}/events/stream (SSE).
+// taskCostGroupings are the groupings of a single flow's cost breakdown.
+var taskCostGroupings = map[string]bool{"phase": true, "provider": true, "worker": true, "day": true}
+
+// GetCostBreakdown handles GET /api/v1/flow/{taskID}/cost/breakdown,
+// summing the flow's spend by ?group_by=phase|provider|worker|day (default
+// phase) between ?since= and ?until=.
+func (h *Handler) GetCostBreakdown(w http.ResponseWriter, r *http.Request) {
+	state, err := h.TaskRepo.GetByID(r.Context(), h.reader(), r.PathValue("taskID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = "phase"
+	}
+	if !taskCostGroupings[groupBy] {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "group_by must be phase, provider, worker, or day"})
+		return
+	}
+	h.writeCostBreakdown(w, r, groupBy, store.CostFilter{TaskID: state.TaskID})
+}
+
+// GetFleetCost handles GET /api/v1/cost, summing the spend of every flow,
+// or of ?namespace='s, by ?group_by=phase|provider|worker|day|task|namespace
+// (default task) between ?since= and ?until=.
+func (h *Handler) GetFleetCost(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	groupBy := q.Get("group_by")
+	if groupBy == "" {
+		groupBy = "task"
+	}
+	if _, ok := store.CostGroupings[groupBy]; !ok {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "group_by must be phase, provider, worker, day, task, or namespace"})
+		return
+	}
+	h.writeCostBreakdown(w, r, groupBy, store.CostFilter{Namespace: q.Get("namespace")})
+}
+
+// writeCostBreakdown applies the request's time range to filter and writes
+// the breakdown.
+func (h *Handler) writeCostBreakdown(w http.ResponseWriter, r *http.Request, groupBy string, filter store.CostFilter) {
+	var err error
+	if filter.Since, filter.Until, err = timeRange(r); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: err.Error()})
+		return
+	}
+	breakdown, err := h.CostDeltaRepo.Breakdown(r.Context(), h.reader(), groupBy, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, breakdown)
+}
+
+// timeRange parses the request's ?since= and ?until= parameters, each unix
+// seconds, an RFC 3339 time, or a YYYY-MM-DD date (midnight UTC). Missing
+// parameters are zero.
+func timeRange(r *http.Request) (since, until int64, err error) {
+	q := r.URL.Query()
+	if since, err = parseTimeParam("since", q.Get("since")); err != nil {
+		return 0, 0, err
+	}
+	if until, err = parseTimeParam("until", q.Get("until")); err != nil {
+		return 0, 0, err
+	}
+	return since, until, nil
+}
+
+func parseTimeParam(name, value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("%s must be unix seconds, an RFC 3339 time, or a YYYY-MM-DD date", name)
+}
+
+// ExportRecords handles GET /api/v1/export/{kind}, streaming every event,
+// cost delta, audit record, or score card (kind events, costs, audits, or
+// scorecards) oldest first, as ?format=json (default, an array of objects)
+// or csv with a header row. ?task_id=, ?namespace=, ?since=, and ?until=
+// filter the rows.
+func (h *Handler) ExportRecords(w http.ResponseWriter, r *http.Request) {
+	kind := r.PathValue("kind")
+	repo := &store.ExportRepo{}
+	columns, err := repo.Columns(kind)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, APIError{Code: 404, Message: "export kind must be events, costs, audits, or scorecards"})
+		return
+	}
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "format must be json or csv"})
+		return
+	}
+	filter := store.ExportFilter{TaskID: q.Get("task_id"), Namespace: q.Get("namespace")}
+	if filter.Since, filter.Until, err = timeRange(r); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", kind+"."+format))
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		cw := csv.NewWriter(w)
+		cw.Write(columns)
+		record := make([]string, len(columns))
+		_ = repo.Each(r.Context(), h.reader(), kind, filter, func(row []interface{}) error {
+			for i, v := range row {
+				record[i] = csvValue(v)
+			}
+			return cw.Write(record)
+		})
+		cw.Flush()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[")
+	sep := ""
+	_ = repo.Each(r.Context(), h.reader(), kind, filter, func(row []interface{}) error {
+		bw.WriteString(sep + "{")
+		sep = ",\n"
+		for i, v := range row {
+			key, _ := json.Marshal(columns[i])
+			value, _ := json.Marshal(v)
+			if i > 0 {
+				bw.WriteString(",")
+			}
+			bw.Write(key)
+			bw.WriteString(":")
+			bw.Write(value)
+		}
+		_, err := bw.WriteString("}")
+		return err
+	})
+	bw.WriteString("]\n")
+	bw.Flush()
+}
+
+// csvValue formats an exported column value for CSV.
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// ExportFlow handles GET /api/v1/flow/{taskID}/export.
+func (h *Handler) ExportFlow(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("taskID")
+	b, err := h.Bundler.Export(r.Context(), taskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", taskID+".bundle.json"))
+	writeJSON(w, http.StatusOK, b)
+}
+
+// GetDiff handles GET /api/v1/flow/{taskID}/diff. Repeated path query
+// parameters limit the diff to those files.
+func (h *Handler) GetDiff(w http.ResponseWriter, r *http.Request) {
+	if h.Git == nil {
+		writeError(w, domain.ErrGitDisabled)
+		return
+	}
+	d, err := h.Git.Diff(r.Context(), r.PathValue("taskID"), r.URL.Query()["path"]...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// ImportFlow handles POST /api/v1/flow/import. Under /api/v1/ns/{namespace}
+// the flow is imported into that namespace.
+func (h *Handler) ImportFlow(w http.ResponseWriter, r *http.Request) {
+	var b domain.TaskBundle
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "invalid request body"})
+		return
+	}
+	if ns := r.PathValue("namespace"); ns != "" {
+		b.Task.Namespace = ns
+	}
+	if err := h.Bundler.Import(r.Context(), &b); err != nil {
+		writeError(w, err)
+		return
+	}
+	state, err := h.Engine.GetState(r.Context(), b.Task.TaskID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, state)
+}
+
+// GetTranscript handles GET /api/v1/sessions/{sessionID}/transcript?since_seq=N.
+func (h *Handler) GetTranscript(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("sessionID")
+	sinceSeq := int64(0)
+	if s := r.URL.Query().Get("since_seq"); s != "" {
+		parsed, err := strconv.ParseInt(s, 10, 64)
+		if err == nil {
+			sinceSeq = parsed
+		}
+	}
+
+	events, err := h.SessionEventRepo.ListBySession(r.Context(), h.reader(), sessionID, sinceSeq)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if events == nil {
+		events = []domain.SessionEvent{}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// StreamEvents handles GET /api/v1/flow/{taskID}/events/stream (SSE). Each
+// event's SSE id is its SeqNo, so a reconnecting client's Last-Event-ID
+// header resumes the stream after the last event it saw. An idle stream
+// sends a ": keepalive" comment every SSEKeepAlive.
 func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
 	taskID := r.PathValue("taskID")
 	flusher, ok := w.(http.Flusher)
@@ -211,13 +2571,26 @@ func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusInternalServerError, APIError{Code: 500, Message: "streaming not supported"})
 		return
 	}
+	lastSeq := int64(0)
+	if s := r.Header.Get("Last-Event-ID"); s != "" {
+		seq, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || seq < 0 {
+			writeJSON(w, http.StatusBadRequest, APIError{Code: 400, Message: "Last-Event-ID must be an event sequence number"})
+			return
+		}
+		lastSeq = seq
+	}
+	keepAlive := h.SSEKeepAlive
+	if keepAlive == 0 {
+		keepAlive = 15 * time.Second
+	}
 
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
 	// Send initial batch of events.
-	events, err := h.EventRepo.ListByTask(r.Context(), h.DB, taskID, 0)
+	events, err := h.EventRepo.ListByTask(r.Context(), h.reader(), taskID, lastSeq)
 	if err != nil {
 		writeSSEError(w, flusher, err)
 		return
@@ -225,9 +2598,12 @@ func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
 	for _, ev := range events {
 		writeSSEEvent(w, flusher, ev)
 	}
+	if len(events) == 0 {
+		// Send the headers now, so the client knows the stream is open.
+		flusher.Flush()
+	}
 
 	// Poll for new events.
-	lastSeq := int64(0)
 	if len(events) > 0 {
 		lastSeq = events[len(events)-1].SeqNo
 	}
@@ -235,13 +2611,18 @@ func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
+	idle := time.NewTicker(keepAlive)
+	defer idle.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-idle.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
 		case <-ticker.C:
-			newEvents, err := h.EventRepo.ListByTask(ctx, h.DB, taskID, lastSeq)
+			newEvents, err := h.EventRepo.ListByTask(ctx, h.reader(), taskID, lastSeq)
 			if err != nil {
 				return
 			}
@@ -249,6 +2630,9 @@ func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
 				writeSSEEvent(w, flusher, ev)
 				lastSeq = ev.SeqNo
 			}
+			if len(newEvents) > 0 {
+				idle.Reset(keepAlive)
+			}
 		}
 	}
 }
@@ -260,22 +2644,41 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 }
 
 func writeError(w http.ResponseWriter, err error) {
+	if rateErr, ok := err.(*domain.RateLimitError); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(rateErr.RetryAfterSeconds()))
+		err = domain.ErrRateLimitExceeded
+	}
 	if engErr, ok := err.(*domain.EngineError); ok {
 		status := http.StatusInternalServerError
 		switch engErr.Code {
-		case domain.ErrFlowNotFound.Code, domain.ErrWorkerNotFound.Code, domain.ErrSessionNotFound.Code:
+		case domain.ErrFlowNotFound.Code, domain.ErrWorkerNotFound.Code, domain.ErrSessionNotFound.Code,
+			domain.ErrDecisionNotFound.Code, domain.ErrArtifactNotFound.Code, domain.ErrRiskNotFound.Code,
+			domain.ErrConstraintNotFound.Code, domain.ErrIssueNotFound.Code, domain.ErrIntentNotFound.Code,
+			domain.ErrFileNotFound.Code, domain.ErrPostMortemNotFound.Code, domain.ErrDeadLetterNotFound.Code,
+			domain.ErrProviderNotFound.Code:
 			status = http.StatusNotFound
-		case domain.ErrDuplicateTask.Code, domain.ErrOptimisticLock.Code:
+		case domain.ErrDuplicateTask.Code, domain.ErrOptimisticLock.Code, domain.ErrTransitionSuperseded.Code,
+			domain.ErrDecisionResolved.Code, domain.ErrConflictEscalated.Code, domain.ErrCrossTaskConflict.Code,
+			domain.ErrIntentConflict.Code, domain.ErrIntentNotActive.Code, domain.ErrIntentHashMismatch.Code,
+			domain.ErrLeaseExpired.Code, domain.ErrFlowActive.Code, domain.ErrWorkspaceInUse.Code,
+			domain.ErrFlowAlreadyDone.Code, domain.ErrFlowBlocked.Code, domain.ErrFlowNotRunning.Code:
 			status = http.StatusConflict
-		case domain.ErrBudgetExceeded.Code, domain.ErrPermissionDenied.Code, domain.ErrForbiddenOperation.Code:
+		case domain.ErrBudgetExceeded.Code, domain.ErrPermissionDenied.Code, domain.ErrForbiddenOperation.Code,
+			domain.ErrFileOwnership.Code:
 			status = http.StatusForbidden
 		case domain.ErrRateLimitExceeded.Code:
 			status = http.StatusTooManyRequests
-		case domain.ErrInvalidTransition.Code, domain.ErrPhaseGateFailed.Code,
-			domain.ErrMaxRoundsExceeded.Code, domain.ErrScoreCardInvalid.Code:
+		case domain.ErrInvalidTransition.Code, domain.ErrPhaseGateFailed.Code, domain.ErrInvalidChildFlow.Code,
+			domain.ErrInvalidBudget.Code, domain.ErrApprovalInvalid.Code, domain.ErrCloneInvalid.Code, domain.ErrFailureInvalid.Code, domain.ErrEventInvalid.Code,
+			domain.ErrDeadLetterInvalid.Code, domain.ErrMaxRoundsExceeded.Code, domain.ErrScoreCardInvalid.Code, domain.ErrBundleInvalid.Code,
+			domain.ErrDecisionInvalid.Code, domain.ErrConsensusNoCards.Code, domain.ErrIssueTransition.Code:
 			status = http.StatusUnprocessableEntity
-		case domain.ErrConfigInvalid.Code:
+		case domain.ErrConfigInvalid.Code, domain.ErrProviderInvalid.Code:
 			status = http.StatusBadRequest
+		case domain.ErrGitDisabled.Code:
+			status = http.StatusNotImplemented
+		case domain.ErrBridgeNotReady.Code:
+			status = http.StatusServiceUnavailable
 		}
 		writeJSON(w, status, APIError{Code: engErr.Code, Message: engErr.Message})
 		return
@@ -285,7 +2688,7 @@ func writeError(w http.ResponseWriter, err error) {
 
 func writeSSEEvent(w http.ResponseWriter, f http.Flusher, ev domain.WorkflowEvent) {
 	data, _ := json.Marshal(ev)
-	fmt.Fprintf(w, "data: %s\n\n", data)
+	fmt.Fprintf(w, "id: %d\ndata: %s\n\n", ev.SeqNo, data)
 	f.Flush()
 }
 
@@ -4,14 +4,31 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/anthropics/three-body-engine/internal/artifact"
+	"github.com/anthropics/three-body-engine/internal/bridge"
+	"github.com/anthropics/three-body-engine/internal/bundle"
+	"github.com/anthropics/three-body-engine/internal/chaos"
+	"github.com/anthropics/three-body-engine/internal/ci"
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
 	"github.com/anthropics/three-body-engine/internal/guard"
+	"github.com/anthropics/three-body-engine/internal/health"
+	"github.com/anthropics/three-body-engine/internal/leader"
+	"github.com/anthropics/three-body-engine/internal/mcp"
+	"github.com/anthropics/three-body-engine/internal/purge"
+	"github.com/anthropics/three-body-engine/internal/redact"
+	"github.com/anthropics/three-body-engine/internal/review"
+	"github.com/anthropics/three-body-engine/internal/sandbox"
 	"github.com/anthropics/three-body-engine/internal/store"
 	"github.com/anthropics/three-body-engine/internal/team"
 	"github.com/anthropics/three-body-engine/internal/workflow"
@@ -25,6 +42,11 @@ func newTestHandler(t *testing.T) *Handler {
 		t.Fatalf("create db: %v", err)
 	}
 	t.Cleanup(func() { db.Close() })
+	rdb, err := store.NewReadDB(dbPath, 4)
+	if err != nil {
+		t.Fatalf("create read db: %v", err)
+	}
+	t.Cleanup(func() { rdb.Close() })
 
 	gov := workflow.NewBudgetGovernor(db)
 	broker := team.NewPermissionBroker(db)
@@ -34,16 +56,34 @@ func newTestHandler(t *testing.T) *Handler {
 	})
 
 	engine := workflow.NewEngine(db)
+	engine.ReadDB = rdb
 
 	return &Handler{
-		Engine:        engine,
-		Guard:         g,
-		DB:            db,
-		EventRepo:     &store.EventRepo{},
-		WorkerRepo:    &store.WorkerRepo{},
-		ScoreCardRepo: &store.ScoreCardRepo{},
-		CostDeltaRepo: &store.CostDeltaRepo{},
-		TaskRepo:      &store.TaskRepo{},
+		Engine:           engine,
+		Scheduler:        workflow.NewScheduler(engine, 1),
+		Guard:            g,
+		DB:               db,
+		ReadDB:           rdb,
+		EventRepo:        &store.EventRepo{},
+		WorkerRepo:       &store.WorkerRepo{},
+		IntentRepo:       &store.IntentRepo{},
+		DecisionRepo:     &store.DecisionRepo{},
+		ArtifactRepo:     &store.ArtifactRepo{},
+		ConstraintRepo:   &store.ConstraintRepo{},
+		RiskRepo:         &store.RiskRepo{},
+		IssueRepo:        &store.IssueRepo{},
+		ScoreCardRepo:    &store.ScoreCardRepo{},
+		RoundRepo:        &store.ReviewRoundRepo{},
+		Consensus:        review.NewConsensusEngine(review.DefaultWeights()),
+		CostDeltaRepo:    &store.CostDeltaRepo{},
+		BudgetAlertRepo:  &store.BudgetAlertRepo{},
+		TaskRepo:         &store.TaskRepo{},
+		SessionEventRepo: &store.SessionEventRepo{},
+		Bundler:          bundle.New(db),
+		Conflicts:        team.NewConflictDetector(db),
+		Digests:          team.NewDigestBuilder(db),
+		Approvals:        workflow.NewApprovals(engine, []domain.Phase{domain.PhaseF}),
+		CIChecks:         &store.CICheckRepo{},
 	}
 }
 
@@ -149,6 +189,66 @@ func TestAdvanceFlow_InvalidAction(t *testing.T) {
 	}
 }
 
+func TestAdvanceFlow_DryRun(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+
+	body := `{"action":"advance","actor":"test"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/advance?dry_run=true", bytes.NewBufferString(body))
+	req.SetPathValue("taskID", "t1")
+	w := httptest.NewRecorder()
+
+	h.AdvanceFlow(w, req)
+
+	var preview domain.AdvancePreview
+	json.NewDecoder(w.Body).Decode(&preview)
+	if w.Code != http.StatusOK || !preview.Allow || preview.To != domain.PhaseB {
+		t.Fatalf("dry run = %d %+v, want 200 allowing A -> B", w.Code, preview)
+	}
+	state, _ := h.Engine.GetState(ctx, "t1")
+	if state.CurrentPhase != domain.PhaseA {
+		t.Errorf("dry run moved the flow to %s", state.CurrentPhase)
+	}
+}
+
+func TestPrecheck(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+
+	post := func(taskID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/"+taskID+"/precheck", bytes.NewBufferString(body))
+		req.SetPathValue("taskID", taskID)
+		w := httptest.NewRecorder()
+		h.Precheck(w, req)
+		return w
+	}
+
+	if w := post("missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown flow = %d, want 404", w.Code)
+	}
+
+	w := post("t1", "")
+	var resp PrecheckResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || !resp.Allow || resp.Advance == nil || resp.Advance.To != domain.PhaseB || len(resp.Rules) != 4 {
+		t.Fatalf("precheck = %d %+v", w.Code, resp)
+	}
+
+	w = post("t1", `{"action":"rework","path":".env"}`)
+	resp = PrecheckResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || resp.Allow || resp.Advance.Error == "" {
+		t.Fatalf("refused precheck = %d %+v", w.Code, resp)
+	}
+	for _, rule := range resp.Rules {
+		if rule.Allowed != (rule.Rule != guard.RulePermission) {
+			t.Errorf("rule %+v, want only the permission rule to refuse", rule)
+		}
+	}
+}
+
 func TestListWorkers_Empty(t *testing.T) {
 	h := newTestHandler(t)
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/workers", nil)
@@ -168,6 +268,101 @@ func TestListWorkers_Empty(t *testing.T) {
 	}
 }
 
+func TestListIntents_ReportsWaiters(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	for _, intent := range []domain.Intent{
+		{IntentID: "i1", TaskID: "t1", TargetFile: "a.go", Operation: "write", Status: "pending"},
+		{IntentID: "i2", TaskID: "t1", TargetFile: "a.go", Operation: "write", Status: "queued", QueuedAt: 2},
+		{IntentID: "i3", TaskID: "t1", TargetFile: "a.go", Operation: "write", Status: "queued", QueuedAt: 1},
+	} {
+		if err := h.IntentRepo.UpsertTx(ctx, tx, intent); err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/intents", nil)
+	req.SetPathValue("taskID", "t1")
+	w := httptest.NewRecorder()
+	h.ListIntents(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp IntentsResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Intents) != 3 {
+		t.Errorf("intents = %d, want 3", len(resp.Intents))
+	}
+	if len(resp.Waiters) != 2 || resp.Waiters[0].IntentID != "i3" || resp.Waiters[0].Position != 1 || resp.Waiters[1].Position != 2 {
+		t.Errorf("waiters = %+v, want i3 then i2", resp.Waiters)
+	}
+}
+
+func TestDecide_ResolvesEscalatedConflict(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	for _, intent := range []domain.Intent{
+		{IntentID: "i1", TaskID: "t1", TargetFile: "a.go", Operation: "write", Status: "pending"},
+		{IntentID: "i2", TaskID: "t1", TargetFile: "a.go", Operation: "write", Status: "pending"},
+	} {
+		if err := h.IntentRepo.UpsertTx(ctx, tx, intent); err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	h.Conflicts.Strategy = func(string) string { return team.StrategyEscalate }
+	conflicts, _ := h.Conflicts.Detect(ctx, "t1")
+	if len(conflicts) != 1 || h.Conflicts.Resolve(ctx, conflicts[0]) != domain.ErrConflictEscalated {
+		t.Fatalf("expected one escalated conflict, got %d", len(conflicts))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/decisions?status=pending", nil)
+	req.SetPathValue("taskID", "t1")
+	w := httptest.NewRecorder()
+	h.ListDecisions(w, req)
+	var decisions []domain.Decision
+	json.NewDecoder(w.Body).Decode(&decisions)
+	if w.Code != http.StatusOK || len(decisions) != 1 {
+		t.Fatalf("list: status %d, decisions %+v", w.Code, decisions)
+	}
+
+	decide := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/decisions/"+decisions[0].DecisionID, strings.NewReader(body))
+		req.SetPathValue("taskID", "t1")
+		req.SetPathValue("decisionID", decisions[0].DecisionID)
+		w := httptest.NewRecorder()
+		h.Decide(w, req)
+		return w
+	}
+	if w := decide(`{"actor":"alice","choice":"i3"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unknown choice: expected 422, got %d", w.Code)
+	}
+	if w := decide(`{"actor":"alice","choice":"i1"}`); w.Code != http.StatusOK {
+		t.Fatalf("decide: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if w := decide(`{"actor":"bob","choice":"i2"}`); w.Code != http.StatusConflict {
+		t.Errorf("second decide: expected 409, got %d", w.Code)
+	}
+	loser, _ := h.IntentRepo.GetByID(ctx, h.DB, "i2")
+	if loser.Status != "cancelled" {
+		t.Errorf("i2 status = %q, want cancelled", loser.Status)
+	}
+}
+
 func TestListEvents_ReturnsEvents(t *testing.T) {
 	h := newTestHandler(t)
 	ctx := context.Background()
@@ -190,10 +385,214 @@ func TestListEvents_ReturnsEvents(t *testing.T) {
 	}
 }
 
+func TestListEvents_Filters(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+	h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "alice"})
+	if err := h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "rollback", Actor: "bob", RollbackTo: domain.PhaseA}); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	list := func(query string) (int, []domain.WorkflowEvent) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/events?"+query, nil)
+		req.SetPathValue("taskID", "t1")
+		w := httptest.NewRecorder()
+		h.ListEvents(w, req)
+		var events []domain.WorkflowEvent
+		json.NewDecoder(w.Body).Decode(&events)
+		return w.Code, events
+	}
+
+	if code, events := list("event_type=phase_transition&payload.action=rollback"); code != http.StatusOK || len(events) != 1 || events[0].SeqNo != 3 {
+		t.Errorf("rollbacks = %d %+v, want the one at seq 3", code, events)
+	}
+	if _, events := list("event_type=flow_started,phase_transition&phase=B"); len(events) != 1 || events[0].EventType != domain.EventPhaseTransition {
+		t.Errorf("phase B events = %+v, want the advance", events)
+	}
+	if _, events := list("payload.actor=carol"); len(events) != 0 {
+		t.Errorf("carol's events = %+v, want none", events)
+	}
+	if code, _ := list("payload.x%27%29=1"); code != http.StatusBadRequest {
+		t.Errorf("bad payload key = %d, want 400", code)
+	}
+}
+
+func TestAppendEvent_Custom(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+	domain.CustomEventSchemas = map[domain.EventType]domain.CustomEventSchema{
+		"ci.build": {Version: 2, Fields: map[string]string{"status": "string", "run": "integer"}, Required: []string{"status"}},
+	}
+	t.Cleanup(func() { domain.CustomEventSchemas = map[domain.EventType]domain.CustomEventSchema{} })
+
+	appendEvent := func(body string) (int, domain.WorkflowEvent, string) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/events", strings.NewReader(body))
+		req.SetPathValue("taskID", "t1")
+		w := httptest.NewRecorder()
+		h.AppendEvent(w, req)
+		var event domain.WorkflowEvent
+		json.Unmarshal(w.Body.Bytes(), &event)
+		return w.Code, event, w.Body.String()
+	}
+
+	code, event, body := appendEvent(`{"type": "ci.build", "payload": {"status": "success", "run": 42}}`)
+	if code != http.StatusCreated || event.SeqNo != 2 || event.Phase != domain.PhaseA || event.SchemaVersion != 2 {
+		t.Fatalf("append = %d %s", code, body)
+	}
+	events, _ := h.EventRepo.ListByTask(ctx, h.DB, "t1", 1)
+	if len(events) != 1 || events[0].EventType != "ci.build" || events[0].SchemaVersion != 2 {
+		t.Errorf("stored events = %+v", events)
+	}
+
+	for _, tt := range []struct {
+		body string
+		code int
+		want string
+	}{
+		{`{"type": "ci.build", "payload": {"run": 1}}`, http.StatusUnprocessableEntity, "status is required"},
+		{`{"type": "ci.build", "payload": {"status": "ok", "run": 1.5}}`, http.StatusUnprocessableEntity, "run must be of type integer"},
+		{`{"type": "ci.build", "payload": {"status": "ok", "url": "x"}}`, http.StatusUnprocessableEntity, `unknown field \"url\"`},
+		{`{"type": "ci.build", "payload": ["ok"]}`, http.StatusUnprocessableEntity, "must be a JSON object"},
+		{`{"type": "ci.deploy"}`, http.StatusUnprocessableEntity, "is not configured"},
+		{`{"type": "phase_transition", "payload": {}}`, http.StatusUnprocessableEntity, "is not a custom type"},
+		{`{"payload": {}}`, http.StatusBadRequest, "type is required"},
+	} {
+		if code, _, body := appendEvent(tt.body); code != tt.code || !strings.Contains(body, tt.want) {
+			t.Errorf("%s = %d %s, want %d mentioning %q", tt.body, code, body, tt.code, tt.want)
+		}
+	}
+	if state, _ := h.Engine.GetState(ctx, "t1"); state.LastEventSeq != 2 {
+		t.Errorf("LastEventSeq = %d after rejected events, want 2", state.LastEventSeq)
+	}
+}
+
+func TestCIWebhook(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+	h.Bus = eventbus.New()
+	signals, unsubscribe := h.Bus.Subscribe(4)
+	defer unsubscribe()
+
+	post := func(body, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/ci/webhook", strings.NewReader(body))
+		if signature != "" {
+			req.Header.Set(ci.SignatureHeader, signature)
+		}
+		w := httptest.NewRecorder()
+		h.CIWebhook(w, req)
+		return w
+	}
+	report := `{"branch": "threebody/t1", "checks": [{"name": "unit", "status": "failure", "url": "https://ci/7"}]}`
+	if w := post(report, "sha256=00"); w.Code != http.StatusNotFound {
+		t.Errorf("webhook without a secret = %d, want 404", w.Code)
+	}
+
+	h.CISecret = []byte("s3cret")
+	h.CIBranchPrefix = "threebody/"
+	if w := post(report, ci.Sign([]byte("wrong"), []byte(report))); w.Code != http.StatusUnauthorized {
+		t.Errorf("badly signed webhook = %d, want 401", w.Code)
+	}
+	w := post(report, ci.Sign(h.CISecret, []byte(report)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("webhook = %d %s", w.Code, w.Body)
+	}
+	select {
+	case sig := <-signals:
+		if sig.Topic != eventbus.TopicCIReported || sig.TaskID != "t1" {
+			t.Errorf("signal = %+v", sig)
+		}
+	default:
+		t.Error("no signal for the reported checks")
+	}
+
+	for body, code := range map[string]int{
+		`{"branch": "feature/x", "checks": [{"name": "unit", "status": "success"}]}`: http.StatusUnprocessableEntity,
+		`{"taskId": "missing", "checks": [{"name": "unit", "status": "success"}]}`:   http.StatusNotFound,
+		`{"taskId": "t1", "checks": [{"name": "unit", "status": "green"}]}`:          http.StatusBadRequest,
+	} {
+		if w := post(body, ci.Sign(h.CISecret, []byte(body))); w.Code != code {
+			t.Errorf("%s = %d, want %d", body, w.Code, code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/ci", nil)
+	req.SetPathValue("taskID", "t1")
+	w = httptest.NewRecorder()
+	h.ListCIChecks(w, req)
+	var checks []domain.CICheck
+	json.NewDecoder(w.Body).Decode(&checks)
+	if len(checks) != 1 || checks[0].Name != "unit" || checks[0].Status != domain.CIFailure || checks[0].URL != "https://ci/7" {
+		t.Errorf("checks = %+v", checks)
+	}
+}
+
+func TestListEvents_LongPollAndResume(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+	h.Engine.StartFlow(ctx, "t2", 10.0)
+	list := func(taskID, query string) (*httptest.ResponseRecorder, []domain.WorkflowEvent) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/"+taskID+"/events?"+query, nil)
+		req.SetPathValue("taskID", taskID)
+		w := httptest.NewRecorder()
+		h.ListEvents(w, req)
+		var events []domain.WorkflowEvent
+		json.NewDecoder(w.Body).Decode(&events)
+		return w, events
+	}
+
+	w, events := list("t1", "event_type=phase_transition")
+	token := w.Header().Get("Resume-Token")
+	if len(events) != 0 || token == "" {
+		t.Fatalf("first listing = %+v, token %q", events, token)
+	}
+
+	// Nothing new: the wait runs out and the token is unchanged.
+	start := time.Now()
+	w, events = list("t1", "resume="+token+"&wait=300ms")
+	if len(events) != 0 || time.Since(start) < 300*time.Millisecond || w.Header().Get("Resume-Token") != token {
+		t.Errorf("idle long poll = %+v after %s", events, time.Since(start))
+	}
+
+	// An event that arrives during the wait ends it; the token's filter
+	// still skips the events that do not match.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		h.Engine.AppendEvent(ctx, "t1", "x_note", map[string]string{})
+		h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "alice"})
+	}()
+	w, events = list("t1", "resume="+token+"&wait=10s")
+	if len(events) != 1 || events[0].EventType != domain.EventPhaseTransition {
+		t.Fatalf("long poll = %+v, want the transition", events)
+	}
+	token = w.Header().Get("Resume-Token")
+	if _, events = list("t1", "resume="+token); len(events) != 0 {
+		t.Errorf("resumed listing = %+v, want nothing after the transition", events)
+	}
+
+	for name, query := range map[string]string{
+		"garbage token": "resume=not-a-token",
+		"other flow":    "resume=" + token,
+		"bad wait":      "wait=soon",
+		"negative wait": "wait=-1s",
+	} {
+		taskID := "t1"
+		if name == "other flow" {
+			taskID = "t2"
+		}
+		if w, _ := list(taskID, query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", name, w.Code)
+		}
+	}
+}
+
 func TestGetCost_ReturnsSummary(t *testing.T) {
 	h := newTestHandler(t)
 	ctx := context.Background()
 	h.Engine.StartFlow(ctx, "t1", 10.0)
+	h.BudgetAlertRepo.Fire(ctx, h.DB, domain.BudgetAlert{TaskID: "t1", Name: "half", CapUSD: 10, Ratio: 0.5, UsedUSD: 5})
 
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/cost", nil)
 	req.SetPathValue("taskID", "t1")
@@ -210,6 +609,9 @@ func TestGetCost_ReturnsSummary(t *testing.T) {
 	if summary.BudgetCapUSD != 10.0 {
 		t.Errorf("expected budget_cap=10.0, got %f", summary.BudgetCapUSD)
 	}
+	if len(summary.Alerts) != 1 || summary.Alerts[0].Name != "half" {
+		t.Errorf("alerts = %+v, want the fired half alert", summary.Alerts)
+	}
 }
 
 func TestStreamEvents_SSE_FirstBatch(t *testing.T) {
@@ -235,6 +637,39 @@ func TestStreamEvents_SSE_FirstBatch(t *testing.T) {
 	}
 }
 
+func TestStreamEvents_ResumesAndKeepsAlive(t *testing.T) {
+	h := newTestHandler(t)
+	h.SSEKeepAlive = 20 * time.Millisecond
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+	h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "test"})
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/events/stream", nil).WithContext(ctx)
+	req.SetPathValue("taskID", "t1")
+	req.Header.Set("Last-Event-ID", "1")
+	w := httptest.NewRecorder()
+	h.StreamEvents(w, req)
+
+	body := w.Body.String()
+	if strings.Contains(body, "id: 1\n") || !strings.Contains(body, "id: 2\ndata: ") {
+		t.Errorf("stream after event 1 = %q, want only event 2", body)
+	}
+	if !strings.Contains(body, ": keepalive\n\n") {
+		t.Errorf("idle stream = %q, want a keepalive comment", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/events/stream", nil)
+	req.SetPathValue("taskID", "t1")
+	req.Header.Set("Last-Event-ID", "latest")
+	w = httptest.NewRecorder()
+	h.StreamEvents(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad Last-Event-ID = %d, want 400", w.Code)
+	}
+}
+
 func TestCORSHeaders(t *testing.T) {
 	h := newTestHandler(t)
 	srv := NewServer(h, ":0")
@@ -271,3 +706,1728 @@ func TestListReviews_Empty(t *testing.T) {
 	}
 }
 
+func TestGetTranscript_SinceSeq(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := h.SessionEventRepo.Append(ctx, h.DB, domain.SessionEvent{
+			SessionID: "ses-1", TaskID: "t1", EventType: "message", PayloadJSON: "{}", CreatedAt: time.Now().Unix(),
+		}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/ses-1/transcript?since_seq=1", nil)
+	req.SetPathValue("sessionID", "ses-1")
+	w := httptest.NewRecorder()
+
+	h.GetTranscript(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var events []domain.SessionEvent
+	json.NewDecoder(w.Body).Decode(&events)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[0].SeqNo != 2 {
+		t.Errorf("first SeqNo = %d, want 2", events[0].SeqNo)
+	}
+}
+
+func TestSubmitReview_Created(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+
+	body := `{"reviewId":"r1","reviewer":"claude","verdict":"pass","scores":{"correctness":4,"security":4,"maintainability":4,"cost":4,"deliveryRisk":4}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/reviews", bytes.NewBufferString(body))
+	req.SetPathValue("taskID", "t1")
+	w := httptest.NewRecorder()
+
+	h.SubmitReview(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	cards, _ := h.ScoreCardRepo.ListByTask(ctx, h.DB, "t1")
+	if len(cards) != 1 {
+		t.Fatalf("expected 1 card, got %d", len(cards))
+	}
+}
+
+func TestListRounds(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+	for i := 0; i < 3; i++ {
+		h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "test"})
+	}
+
+	submit := func(id string) {
+		body := `{"reviewId":"` + id + `","reviewer":"claude","verdict":"pass","scores":{"correctness":4,"security":4,"maintainability":4,"cost":4,"deliveryRisk":4}}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/reviews", bytes.NewBufferString(body))
+		req.SetPathValue("taskID", "t1")
+		w := httptest.NewRecorder()
+		h.SubmitReview(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("SubmitReview: %d %s", w.Code, w.Body.String())
+		}
+	}
+	submit("r1")
+	if err := h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "rollback", Actor: "test"}); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	submit("r2")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/rounds", nil)
+	req.SetPathValue("taskID", "t1")
+	w := httptest.NewRecorder()
+	h.ListRounds(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var rounds []ReviewRoundResponse
+	json.NewDecoder(w.Body).Decode(&rounds)
+	if len(rounds) != 2 {
+		t.Fatalf("rounds = %+v, want 2", rounds)
+	}
+	if rounds[0].Outcome != "rollback" || len(rounds[0].ScoreCards) != 1 || rounds[0].ScoreCards[0].ReviewID != "r1" {
+		t.Errorf("round 0 = %+v", rounds[0])
+	}
+	if rounds[1].Round != 1 || len(rounds[1].ScoreCards) != 1 || rounds[1].ScoreCards[0].ReviewID != "r2" {
+		t.Errorf("round 1 = %+v", rounds[1])
+	}
+}
+
+func TestDiffReviews(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+	for i := 0; i < 3; i++ {
+		h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "test"})
+	}
+
+	submit := func(id, reviewer, verdict string, correctness int, issues string) {
+		body := fmt.Sprintf(`{"reviewId":%q,"reviewer":%q,"verdict":%q,"scores":{"correctness":%d,"security":4,"maintainability":4,"cost":4,"deliveryRisk":4},"issues":[%s]}`,
+			id, reviewer, verdict, correctness, issues)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/reviews", bytes.NewBufferString(body))
+		req.SetPathValue("taskID", "t1")
+		w := httptest.NewRecorder()
+		h.SubmitReview(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("SubmitReview: %d %s", w.Code, w.Body.String())
+		}
+	}
+	submit("r1", "lead", "fail", 2, `{"severity":"P1","location":"a.go","description":"missing check"}`)
+	submit("r2", "primary", "pass", 4, "")
+	if err := h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "rollback", Actor: "test"}); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	submit("r3", "lead", "pass", 4, `{"severity":"P2","location":"b.go","description":"typo"}`)
+
+	diff := func(query string) (int, []domain.ScoreCardDiff) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/reviews/diff"+query, nil)
+		req.SetPathValue("taskID", "t1")
+		w := httptest.NewRecorder()
+		h.DiffReviews(w, req)
+		var diffs []domain.ScoreCardDiff
+		json.NewDecoder(w.Body).Decode(&diffs)
+		return w.Code, diffs
+	}
+
+	code, diffs := diff("?reviewer=lead")
+	if code != http.StatusOK || len(diffs) != 1 {
+		t.Fatalf("diff = %d %+v, want one lead diff", code, diffs)
+	}
+	d := diffs[0]
+	if d.FromRound != 0 || d.ToRound != 1 || d.ScoreDeltas.Correctness != 2 || d.ToVerdict != "pass" {
+		t.Errorf("lead diff = %+v", d)
+	}
+	if len(d.Resolved) != 1 || d.Resolved[0].Location != "a.go" || len(d.New) != 1 || d.New[0].Location != "b.go" {
+		t.Errorf("lead issues resolved=%+v new=%+v", d.Resolved, d.New)
+	}
+
+	if code, diffs := diff("?reviewer=primary"); code != http.StatusOK || len(diffs) != 0 {
+		t.Errorf("primary diff = %d %+v, want none", code, diffs)
+	}
+	if code, _ := diff("?from=1&to=1"); code != http.StatusBadRequest {
+		t.Errorf("from == to: expected 400, got %d", code)
+	}
+}
+
+func TestReviewIssues(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+
+	body := `{"reviewId":"r1","reviewer":"lead","verdict":"conditional_pass","scores":{"correctness":3,"security":4,"maintainability":4,"cost":4,"deliveryRisk":4},` +
+		`"issues":[{"severity":"P2","location":"b.go","description":"typo"},{"severity":"P0","location":"a.go","description":"crash"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/reviews", bytes.NewBufferString(body))
+	req.SetPathValue("taskID", "t1")
+	w := httptest.NewRecorder()
+	h.SubmitReview(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("SubmitReview: %d %s", w.Code, w.Body.String())
+	}
+
+	list := func(query string) []domain.ReviewIssue {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/issues"+query, nil)
+		req.SetPathValue("taskID", "t1")
+		w := httptest.NewRecorder()
+		h.ListIssues(w, req)
+		var issues []domain.ReviewIssue
+		json.NewDecoder(w.Body).Decode(&issues)
+		return issues
+	}
+	issues := list("")
+	if len(issues) != 2 || issues[0].IssueID != "iss-r1-2" || issues[0].Status != "open" || issues[0].Reviewer != "lead" {
+		t.Fatalf("issues = %+v, want the P0 first and open", issues)
+	}
+	if got := list("?severity=P2"); len(got) != 1 || got[0].Description != "typo" {
+		t.Errorf("P2 issues = %+v", got)
+	}
+
+	update := func(issueID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/issues/"+issueID+"/status", bytes.NewBufferString(body))
+		req.SetPathValue("taskID", "t1")
+		req.SetPathValue("issueID", issueID)
+		w := httptest.NewRecorder()
+		h.UpdateIssue(w, req)
+		return w
+	}
+	if w := update("iss-r1-1", `{"status":"wont_fix","actor":"alice"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("wont_fix without note: expected 400, got %d", w.Code)
+	}
+	if w := update("iss-r1-2", `{"status":"fixed","actor":"alice","fixIntentId":"missing"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown intent: expected 400, got %d", w.Code)
+	}
+	w = update("iss-r1-2", `{"status":"fixed","actor":"alice","fixCommit":"abc123"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("fix: %d %s", w.Code, w.Body.String())
+	}
+	var fixed domain.ReviewIssue
+	json.NewDecoder(w.Body).Decode(&fixed)
+	if fixed.Status != "fixed" || fixed.FixCommit != "abc123" || fixed.UpdatedBy != "alice" {
+		t.Errorf("fixed = %+v", fixed)
+	}
+	if w := update("iss-r1-2", `{"status":"acknowledged","actor":"alice"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("fixed -> acknowledged: expected 422, got %d", w.Code)
+	}
+	if w := update("iss-r1-9", `{"status":"fixed","actor":"alice"}`); w.Code != http.StatusNotFound {
+		t.Errorf("missing issue: expected 404, got %d", w.Code)
+	}
+	if got := list("?status=open"); len(got) != 1 || got[0].IssueID != "iss-r1-1" {
+		t.Errorf("open issues = %+v", got)
+	}
+}
+
+func TestGetConsensus_TaskOverride(t *testing.T) {
+	h := newTestHandler(t)
+	create := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.CreateFlow(w, httptest.NewRequest(http.MethodPost, "/api/v1/flow", bytes.NewBufferString(body)))
+		return w
+	}
+	if w := create(`{"task_id":"bad","budget_cap_usd":10.0,"consensus":{"weights":{"primary":0.3}}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("weights summing to 0.3: status %d, want 400", w.Code)
+	}
+	if w := create(`{"task_id":"t1","budget_cap_usd":10.0,"consensus":{"pass_threshold":4.5}}`); w.Code != http.StatusCreated {
+		t.Fatalf("CreateFlow: %d %s", w.Code, w.Body.String())
+	}
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/consensus", nil)
+		req.SetPathValue("taskID", "t1")
+		w := httptest.NewRecorder()
+		h.GetConsensus(w, req)
+		return w
+	}
+	if w := get(); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("no cards: status %d, want 422", w.Code)
+	}
+
+	body := `{"reviewId":"r1","reviewer":"primary","verdict":"pass","scores":{"correctness":5,"security":5,"maintainability":4,"cost":4,"deliveryRisk":4}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/reviews", bytes.NewBufferString(body))
+	req.SetPathValue("taskID", "t1")
+	h.SubmitReview(httptest.NewRecorder(), req)
+
+	w := get()
+	var result domain.ConsensusResult
+	json.NewDecoder(w.Body).Decode(&result)
+	if w.Code != http.StatusOK || result.FinalVerdict != "conditional_pass" {
+		t.Errorf("consensus: status %d, result %+v", w.Code, result)
+	}
+	if result.Settings.PassThreshold != 4.5 || result.Settings.Weights["primary"] != 0.45 {
+		t.Errorf("settings = %+v", result.Settings)
+	}
+
+	h.Consensus.Policies = map[domain.Phase]domain.VerdictPolicy{domain.PhaseF: {MinReviewers: 2}}
+	for query, want := range map[string]int{"?phase=F": http.StatusOK, "?phase=Z": http.StatusBadRequest} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/consensus"+query, nil)
+		req.SetPathValue("taskID", "t1")
+		w := httptest.NewRecorder()
+		h.GetConsensus(w, req)
+		if w.Code != want {
+			t.Errorf("%s: status %d, want %d", query, w.Code, want)
+		}
+		if want == http.StatusOK {
+			json.NewDecoder(w.Body).Decode(&result)
+			if result.FinalVerdict != "insufficient_reviews" || result.Policy.MinReviewers != 2 {
+				t.Errorf("%s: result %+v", query, result)
+			}
+		}
+	}
+}
+
+func TestSubmitReview_InvalidCard(t *testing.T) {
+	h := newTestHandler(t)
+	h.Engine.StartFlow(context.Background(), "t1", 10.0)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/reviews", bytes.NewBufferString(`{"reviewId":"r1"}`))
+	req.SetPathValue("taskID", "t1")
+	w := httptest.NewRecorder()
+
+	h.SubmitReview(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", w.Code)
+	}
+}
+
+func TestCreateChild_Created(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+
+	body := `{"task_id":"t1-a","budget_fraction":0.5}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/children", bytes.NewBufferString(body))
+	req.SetPathValue("taskID", "t1")
+	w := httptest.NewRecorder()
+
+	h.CreateChild(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var state domain.FlowState
+	json.NewDecoder(w.Body).Decode(&state)
+	if state.ParentTaskID != "t1" || state.BudgetCapUSD != 5.0 {
+		t.Errorf("child = %+v, want parent t1 with cap 5", state)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/children", nil)
+	req.SetPathValue("taskID", "t1")
+	w = httptest.NewRecorder()
+	h.ListChildren(w, req)
+
+	var children []domain.FlowState
+	json.NewDecoder(w.Body).Decode(&children)
+	if len(children) != 1 {
+		t.Errorf("expected 1 child, got %d", len(children))
+	}
+}
+
+func TestCreateChild_InvalidFraction(t *testing.T) {
+	h := newTestHandler(t)
+	h.Engine.StartFlow(context.Background(), "t1", 10.0)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/children", bytes.NewBufferString(`{"task_id":"c","budget_fraction":2}`))
+	req.SetPathValue("taskID", "t1")
+	w := httptest.NewRecorder()
+
+	h.CreateChild(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", w.Code)
+	}
+}
+
+func TestGetWorkerQueue_ReportsDepth(t *testing.T) {
+	h := newTestHandler(t)
+	h.Workers = team.NewWorkerManager(h.DB, 10)
+	h.Workers.SetLimits(10, 1, 0)
+	h.Workers.Queue = true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	spec := domain.WorkerSpec{TaskID: "t1", Phase: domain.PhaseC, Role: "reviewer"}
+	if _, err := h.Workers.Spawn(ctx, spec); err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	go h.Workers.SpawnWait(ctx, spec)
+	for i := 0; i < 200 && h.Workers.QueueStats().Waiting == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/workers/queue", nil)
+	w := httptest.NewRecorder()
+	h.GetWorkerQueue(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var stats team.WorkerQueueStats
+	json.NewDecoder(w.Body).Decode(&stats)
+	if stats.Waiting != 1 || stats.ByRole["reviewer"] != 1 {
+		t.Errorf("stats = %+v, want one waiting reviewer", stats)
+	}
+}
+
+func TestGetQueue_ListsQueuedFlows(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+
+	body := `{"task_id":"t2","budget_cap_usd":10.0,"queued":true}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/flow", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.CreateFlow(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/queue", nil)
+	w = httptest.NewRecorder()
+	h.GetQueue(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var status workflow.QueueStatus
+	json.NewDecoder(w.Body).Decode(&status)
+	if status.Active != 1 || status.MaxConcurrent != 1 {
+		t.Errorf("status = %+v, want active 1 of 1", status)
+	}
+	if len(status.Queued) != 1 || status.Queued[0].TaskID != "t2" {
+		t.Errorf("queued = %+v, want [t2]", status.Queued)
+	}
+}
+
+func TestListEvents_NotBlockedByOpenWrite(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+
+	// Occupy the single writer connection.
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback()
+
+	done := make(chan int, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/events", nil)
+		req.SetPathValue("taskID", "t1")
+		w := httptest.NewRecorder()
+		h.ListEvents(w, req)
+		done <- w.Code
+	}()
+
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ListEvents waited on the writer connection")
+	}
+}
+
+func TestExportImportFlow(t *testing.T) {
+	src := newTestHandler(t)
+	ctx := context.Background()
+	src.Engine.StartFlow(ctx, "t1", 10.0)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/export", nil)
+	req.SetPathValue("taskID", "t1")
+	w := httptest.NewRecorder()
+	src.ExportFlow(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	exported := w.Body.Bytes()
+
+	dst := newTestHandler(t)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/flow/import", bytes.NewReader(exported))
+	w = httptest.NewRecorder()
+	dst.ImportFlow(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("import: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var state domain.FlowState
+	json.NewDecoder(w.Body).Decode(&state)
+	if state.TaskID != "t1" || state.CurrentPhase != domain.PhaseA {
+		t.Errorf("imported state = %+v", state)
+	}
+
+	// Importing the same bundle again conflicts.
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/flow/import", bytes.NewReader(exported))
+	w = httptest.NewRecorder()
+	dst.ImportFlow(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("re-import: expected 409, got %d", w.Code)
+	}
+}
+
+func TestGetDiff_GitDisabled(t *testing.T) {
+	h := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/diff", nil)
+	req.SetPathValue("taskID", "t1")
+	w := httptest.NewRecorder()
+	h.GetDiff(w, req)
+	if w.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReportProgress(t *testing.T) {
+	h := newTestHandler(t)
+	h.Clock = clock.NewFake(time.Unix(1_700_000_000, 0))
+	ctx := context.Background()
+	if err := h.WorkerRepo.Create(ctx, h.DB, domain.WorkerRef{
+		WorkerID: "w1", TaskID: "t1", Phase: domain.PhaseC, Role: "coder", State: domain.WorkerRunning,
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	report := func(workerID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/workers/"+workerID+"/progress", strings.NewReader(body))
+		req.SetPathValue("workerID", workerID)
+		w := httptest.NewRecorder()
+		h.ReportProgress(w, req)
+		return w
+	}
+	if w := report("w1", `{"percent":150}`); w.Code != http.StatusBadRequest {
+		t.Errorf("out of range percent: status %d, want 400", w.Code)
+	}
+	if w := report("missing", `{"percent":10}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown worker: status %d, want 404", w.Code)
+	}
+	if w := report("w1", `{"percent":30,"current_file":"main.go","note":"refactoring"}`); w.Code != http.StatusOK {
+		t.Fatalf("report: status %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/workers", nil)
+	req.SetPathValue("taskID", "t1")
+	w := httptest.NewRecorder()
+	h.ListWorkers(w, req)
+	var workers []domain.WorkerRef
+	json.NewDecoder(w.Body).Decode(&workers)
+	if len(workers) != 1 {
+		t.Fatalf("workers = %+v, want 1", workers)
+	}
+	p := workers[0].Progress
+	if p.Percent != 30 || p.CurrentFile != "main.go" || p.Note != "refactoring" || p.UpdatedAt != 1_700_000_000 {
+		t.Errorf("progress = %+v", p)
+	}
+}
+
+func TestSubmitArtifact(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	if err := h.WorkerRepo.Create(ctx, h.DB, domain.WorkerRef{
+		WorkerID: "w1", TaskID: "t1", Phase: domain.PhaseD, Role: "coder", State: domain.WorkerRunning,
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	submit := func(workerID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/workers/"+workerID+"/artifacts", strings.NewReader(body))
+		req.SetPathValue("workerID", workerID)
+		w := httptest.NewRecorder()
+		h.SubmitArtifact(w, req)
+		return w
+	}
+	if w := submit("w1", `{"type":"diff","path":"main.go"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing hash: status %d, want 400", w.Code)
+	}
+	if w := submit("missing", `{"type":"diff","path":"main.go","hash":"h1"}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown worker: status %d, want 404", w.Code)
+	}
+	submit("w1", `{"type":"diff","path":"main.go","hash":"h1"}`)
+	w := submit("w1", `{"type":"diff","path":"main.go","hash":"h2"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("submit: status %d: %s", w.Code, w.Body.String())
+	}
+	var artifact domain.ArtifactRef
+	json.NewDecoder(w.Body).Decode(&artifact)
+	if artifact.TaskID != "t1" || artifact.Phase != domain.PhaseD || artifact.Version != 2 || artifact.Hash != "h2" {
+		t.Errorf("artifact = %+v", artifact)
+	}
+}
+
+func TestGetArtifactContent(t *testing.T) {
+	h := newTestHandler(t)
+	h.Blobs = artifact.NewBlobs(filepath.Join(t.TempDir(), "artifacts"))
+	ctx := context.Background()
+	if err := h.WorkerRepo.Create(ctx, h.DB, domain.WorkerRef{
+		WorkerID: "w1", TaskID: "t1", Phase: domain.PhaseB, Role: "architect", State: domain.WorkerRunning,
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/workers/w1/artifacts",
+		strings.NewReader(`{"type":"design","path":"docs/design.md","content":"# Design\n","hash":"bogus"}`))
+	req.SetPathValue("workerID", "w1")
+	w := httptest.NewRecorder()
+	h.SubmitArtifact(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("mismatched hash: status %d, want 400", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/workers/w1/artifacts",
+		strings.NewReader(`{"type":"design","path":"docs/design.md","content":"# Design\n"}`))
+	req.SetPathValue("workerID", "w1")
+	w = httptest.NewRecorder()
+	h.SubmitArtifact(w, req)
+	var ref domain.ArtifactRef
+	json.NewDecoder(w.Body).Decode(&ref)
+	if w.Code != http.StatusCreated || ref.Hash != artifact.Hash([]byte("# Design\n")) {
+		t.Fatalf("submit: status %d, artifact %+v", w.Code, ref)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/artifacts/"+ref.ID+"/content", nil)
+	req.SetPathValue("artifactID", ref.ID)
+	w = httptest.NewRecorder()
+	h.GetArtifactContent(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "# Design\n" {
+		t.Errorf("content: status %d, body %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("ETag"); got != `"`+ref.Hash+`"` {
+		t.Errorf("ETag = %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/artifacts/missing", nil)
+	req.SetPathValue("artifactID", "missing")
+	w = httptest.NewRecorder()
+	h.GetArtifact(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing artifact: status %d, want 404", w.Code)
+	}
+}
+
+func TestGetDigest(t *testing.T) {
+	h := newTestHandler(t)
+	create := httptest.NewRequest(http.MethodPost, "/api/v1/flow", bytes.NewBufferString(`{"task_id":"t1","budget_cap_usd":10.0}`))
+	h.CreateFlow(httptest.NewRecorder(), create)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/digest?"+query, nil)
+		req.SetPathValue("taskID", "t1")
+		w := httptest.NewRecorder()
+		h.GetDigest(w, req)
+		return w
+	}
+	if w := get(""); w.Code != http.StatusBadRequest {
+		t.Errorf("missing role: status %d, want 400", w.Code)
+	}
+	if w := get("role=coder&phase=Z"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad phase: status %d, want 400", w.Code)
+	}
+
+	w := get("role=coder&phase=C")
+	var digest domain.ContextDigest
+	json.NewDecoder(w.Body).Decode(&digest)
+	if w.Code != http.StatusOK || digest.TaskID != "t1" || digest.PhaseID != "C" || digest.Role != "coder" {
+		t.Fatalf("json digest: status %d, %+v", w.Code, digest)
+	}
+
+	w = get("role=coder&format=markdown")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "# Task t1, phase A") {
+		t.Errorf("markdown digest: status %d, body %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/markdown") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestAddConstraint(t *testing.T) {
+	h := newTestHandler(t)
+	create := httptest.NewRequest(http.MethodPost, "/api/v1/flow",
+		bytes.NewBufferString(`{"task_id":"t1","budget_cap_usd":10.0,"title":"rate limiter"}`))
+	w := httptest.NewRecorder()
+	h.CreateFlow(w, create)
+	var state domain.FlowState
+	json.NewDecoder(w.Body).Decode(&state)
+	if state.Title != "rate limiter" {
+		t.Errorf("Title = %q", state.Title)
+	}
+
+	add := func(taskID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/"+taskID+"/constraints", strings.NewReader(body))
+		req.SetPathValue("taskID", taskID)
+		w := httptest.NewRecorder()
+		h.AddConstraint(w, req)
+		return w
+	}
+	if w := add("t1", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("empty text: status %d, want 400", w.Code)
+	}
+	if w := add("missing", `{"text":"x"}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown flow: status %d, want 404", w.Code)
+	}
+	if w := add("t1", `{"text":"no new dependencies"}`); w.Code != http.StatusCreated {
+		t.Fatalf("add: status %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/digest?role=coder", nil)
+	req.SetPathValue("taskID", "t1")
+	w = httptest.NewRecorder()
+	h.GetDigest(w, req)
+	var digest domain.ContextDigest
+	json.NewDecoder(w.Body).Decode(&digest)
+	if len(digest.Constraints) == 0 || digest.Constraints[0] != "no new dependencies" {
+		t.Errorf("digest constraints = %v", digest.Constraints)
+	}
+	if !strings.Contains(digest.Objective, "rate limiter") {
+		t.Errorf("digest objective = %q", digest.Objective)
+	}
+
+	var c domain.Constraint
+	json.NewDecoder(add("t1", `{"text":"keep go 1.22"}`).Body).Decode(&c)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/constraints/"+c.ConstraintID+"/resolve", nil)
+	req.SetPathValue("taskID", "t1")
+	req.SetPathValue("constraintID", c.ConstraintID)
+	w = httptest.NewRecorder()
+	h.ResolveConstraint(w, req)
+	json.NewDecoder(w.Body).Decode(&c)
+	if w.Code != http.StatusOK || c.Status != "resolved" {
+		t.Errorf("resolve: status %d, constraint %+v", w.Code, c)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/constraints?status=active", nil)
+	req.SetPathValue("taskID", "t1")
+	w = httptest.NewRecorder()
+	h.ListConstraints(w, req)
+	var active []domain.Constraint
+	json.NewDecoder(w.Body).Decode(&active)
+	if len(active) != 1 || active[0].Text != "no new dependencies" {
+		t.Errorf("active constraints = %+v", active)
+	}
+}
+
+func TestRisks(t *testing.T) {
+	h := newTestHandler(t)
+	create := httptest.NewRequest(http.MethodPost, "/api/v1/flow",
+		bytes.NewBufferString(`{"task_id":"t1","budget_cap_usd":10.0}`))
+	h.CreateFlow(httptest.NewRecorder(), create)
+
+	add := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/risks", strings.NewReader(body))
+		req.SetPathValue("taskID", "t1")
+		w := httptest.NewRecorder()
+		h.AddRisk(w, req)
+		return w
+	}
+	if w := add(`{"severity":"P3","text":"x"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("bad severity: status %d, want 400", w.Code)
+	}
+	w := add(`{"severity":"P0","text":"data loss","actor":"alice"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("add: status %d: %s", w.Code, w.Body.String())
+	}
+	var risk domain.Risk
+	json.NewDecoder(w.Body).Decode(&risk)
+	if risk.Phase != domain.PhaseA || risk.Status != "open" {
+		t.Errorf("risk = %+v, want phase A status open", risk)
+	}
+
+	resolve := func(taskID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/"+taskID+"/risks/"+risk.RiskID+"/resolve", strings.NewReader(body))
+		req.SetPathValue("taskID", taskID)
+		req.SetPathValue("riskID", risk.RiskID)
+		w := httptest.NewRecorder()
+		h.ResolveRisk(w, req)
+		return w
+	}
+	if w := resolve("t1", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("no actor: status %d, want 400", w.Code)
+	}
+	if w := resolve("other", `{"actor":"bob"}`); w.Code != http.StatusNotFound {
+		t.Errorf("wrong flow: status %d, want 404", w.Code)
+	}
+	w = resolve("t1", `{"actor":"bob"}`)
+	json.NewDecoder(w.Body).Decode(&risk)
+	if w.Code != http.StatusOK || risk.Status != "resolved" || risk.ResolvedBy != "bob" {
+		t.Errorf("resolve: status %d, risk %+v", w.Code, risk)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/risks?status=open", nil)
+	req.SetPathValue("taskID", "t1")
+	w = httptest.NewRecorder()
+	h.ListRisks(w, req)
+	var open []domain.Risk
+	json.NewDecoder(w.Body).Decode(&open)
+	if w.Code != http.StatusOK || len(open) != 0 {
+		t.Errorf("open risks: status %d, %+v", w.Code, open)
+	}
+}
+
+func TestExecCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+	h.WorkerRepo.Create(ctx, h.DB, domain.WorkerRef{WorkerID: "w-1", TaskID: "t1", Phase: domain.PhaseA, Role: "builder", State: domain.WorkerRunning})
+	h.Exec = sandbox.NewExecutor(h.DB, h.Guard, nil, []string{"sh"}, t.TempDir())
+
+	exec := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/workers/w-1/exec", bytes.NewBufferString(body))
+		req.SetPathValue("workerID", "w-1")
+		w := httptest.NewRecorder()
+		h.ExecCommand(w, req)
+		return w
+	}
+
+	if w := exec(`{"args":["-c","true"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing command = %d, want 400", w.Code)
+	}
+	if w := exec(`{"command":"curl","args":["example.com"]}`); w.Code != http.StatusForbidden {
+		t.Errorf("curl = %d, want 403", w.Code)
+	}
+	w := exec(`{"command":"sh","args":["-c","echo hi; exit 2"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("sh = %d %s, want 200", w.Code, w.Body.String())
+	}
+	var result sandbox.ExecResult
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.ExitCode != 2 || result.Output != "hi\n" || result.Artifact == nil {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestFileProxy(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+	h.WorkerRepo.Create(ctx, h.DB, domain.WorkerRef{WorkerID: "w-1", TaskID: "t1", Phase: domain.PhaseC, Role: "builder", State: domain.WorkerRunning, FileOwnership: []string{"src/"}})
+	resolver := &team.IntentResolver{DB: h.DB, IntentRepo: h.IntentRepo, WorkerRepo: h.WorkerRepo, AuditRepo: &store.AuditRepo{}}
+	h.Files = sandbox.NewFiles(h.DB, h.Guard.Broker, resolver, t.TempDir())
+
+	do := func(method, target, body string, fn http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.SetPathValue("workerID", "w-1")
+		w := httptest.NewRecorder()
+		fn(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPut, "/api/v1/workers/w-1/files/content", `{"content":"x"}`, h.WriteFile); w.Code != http.StatusBadRequest {
+		t.Errorf("write without path = %d, want 400", w.Code)
+	}
+	if w := do(http.MethodPut, "/api/v1/workers/w-1/files/content", `{"path":"README.md","content":"x"}`, h.WriteFile); w.Code != http.StatusForbidden {
+		t.Errorf("write outside ownership = %d %s, want 403", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/api/v1/workers/w-1/files/content", `{"path":"src/main.go","content":"package main\n"}`, h.WriteFile); w.Code != http.StatusOK {
+		t.Fatalf("write = %d %s, want 200", w.Code, w.Body.String())
+	}
+
+	w := do(http.MethodGet, "/api/v1/workers/w-1/files/content?path=src/main.go", "", h.ReadFile)
+	var content sandbox.FileContent
+	json.NewDecoder(w.Body).Decode(&content)
+	if w.Code != http.StatusOK || content.Content != "package main\n" {
+		t.Errorf("read = %d %+v", w.Code, content)
+	}
+	if w := do(http.MethodGet, "/api/v1/workers/w-1/files/content?path=src/none.go", "", h.ReadFile); w.Code != http.StatusNotFound {
+		t.Errorf("read missing = %d, want 404", w.Code)
+	}
+
+	w = do(http.MethodGet, "/api/v1/workers/w-1/files?path=src", "", h.ListFiles)
+	var entries []sandbox.FileEntry
+	json.NewDecoder(w.Body).Decode(&entries)
+	if w.Code != http.StatusOK || len(entries) != 1 || entries[0].Path != "src/main.go" {
+		t.Errorf("list = %d %+v", w.Code, entries)
+	}
+}
+
+func TestMetrics_Redactions(t *testing.T) {
+	h := newTestHandler(t)
+	h.Redactor = redact.Default()
+	h.Redactor.String("password=hunter22")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/metrics", nil)
+	w := httptest.NewRecorder()
+	h.Metrics(w, req)
+
+	var resp MetricsResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || resp.Redactions.Total != 1 || resp.Redactions.ByRule["keyword:password"] != 1 {
+		t.Errorf("metrics = %d %+v", w.Code, resp)
+	}
+}
+
+func TestMetrics_Chaos(t *testing.T) {
+	h := newTestHandler(t)
+	metrics := func() MetricsResponse {
+		w := httptest.NewRecorder()
+		h.Metrics(w, httptest.NewRequest(http.MethodGet, "/api/v1/metrics", nil))
+		var resp MetricsResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+	if resp := metrics(); resp.Chaos != nil {
+		t.Errorf("chaos = %+v without a fault injector", resp.Chaos)
+	}
+
+	h.Chaos = chaos.New(chaos.Config{DropHeartbeatRate: 1})
+	h.Chaos.DropHeartbeat()
+	if resp := metrics(); resp.Chaos == nil || resp.Chaos.HeartbeatsDropped != 1 {
+		t.Errorf("chaos = %+v, want one heartbeat dropped", resp.Chaos)
+	}
+}
+
+func TestGetPolicy(t *testing.T) {
+	h := newTestHandler(t)
+	h.Engine.StartFlow(context.Background(), "t1", 10.0)
+	h.Guard.Broker.Policy = func(taskID string) domain.CapabilityPolicy {
+		return domain.CapabilityPolicy{DeniedPatterns: []string{"secrets/"}, AllowedCommands: []string{"read"}}
+	}
+
+	get := func(taskID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/"+taskID+"/policy", nil)
+		req.SetPathValue("taskID", taskID)
+		w := httptest.NewRecorder()
+		h.GetPolicy(w, req)
+		return w
+	}
+
+	if w := get("missing"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown flow = %d, want 404", w.Code)
+	}
+	w := get("t1")
+	var policy domain.CapabilityPolicy
+	json.NewDecoder(w.Body).Decode(&policy)
+	if w.Code != http.StatusOK || policy.TaskID != "t1" || len(policy.DeniedPatterns) != len(domain.DefaultDeniedPatterns)+1 ||
+		len(policy.AllowedPaths) != 0 || policy.AllowedCommands[0] != "read" {
+		t.Errorf("policy = %d %+v", w.Code, policy)
+	}
+}
+
+func TestWriteError_RetryAfter(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeError(w, &domain.RateLimitError{RetryAfter: 1500 * time.Millisecond})
+
+	var apiErr APIError
+	json.NewDecoder(w.Body).Decode(&apiErr)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" || apiErr.Code != domain.ErrRateLimitExceeded.Code {
+		t.Errorf("response = %d Retry-After=%q %+v", w.Code, w.Header().Get("Retry-After"), apiErr)
+	}
+}
+
+func TestGetLimits(t *testing.T) {
+	h := newTestHandler(t)
+	h.Engine.StartFlow(context.Background(), "t1", 10.0)
+	h.Guard.Config.Operations = map[string]guard.RateLimit{guard.OpExec: {PerMinute: 10}}
+
+	get := func(taskID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/"+taskID+"/limits", nil)
+		req.SetPathValue("taskID", taskID)
+		w := httptest.NewRecorder()
+		h.GetLimits(w, req)
+		return w
+	}
+
+	if w := get("missing"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown flow = %d, want 404", w.Code)
+	}
+	w := get("t1")
+	var limits []guard.LimitState
+	json.NewDecoder(w.Body).Decode(&limits)
+	if w.Code != http.StatusOK || len(limits) != 2 || limits[0].Scope != "task" ||
+		limits[1].Name != guard.OpExec || limits[1].Tokens != 10 {
+		t.Errorf("limits = %d %+v", w.Code, limits)
+	}
+}
+
+func TestVerifyAudit(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+	repo := &store.AuditRepo{}
+	for _, id := range []string{"a1", "a2"} {
+		repo.Record(ctx, h.DB, domain.AuditRecord{ID: id, TaskID: "t1", Category: "c", Action: "a"})
+	}
+
+	get := func(taskID string) (*httptest.ResponseRecorder, domain.AuditChainReport) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/"+taskID+"/audit/verify", nil)
+		req.SetPathValue("taskID", taskID)
+		w := httptest.NewRecorder()
+		h.VerifyAudit(w, req)
+		var report domain.AuditChainReport
+		json.NewDecoder(w.Body).Decode(&report)
+		return w, report
+	}
+
+	if w, _ := get("missing"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown flow = %d, want 404", w.Code)
+	}
+	if w, report := get("t1"); w.Code != http.StatusOK || !report.Valid || report.Records < 2 {
+		t.Errorf("intact chain = %d %+v", w.Code, report)
+	}
+	h.DB.Exec(`UPDATE audit_records SET actor = 'mallory' WHERE id = 'a1'`)
+	if w, report := get("t1"); w.Code != http.StatusOK || report.Valid || report.Problems[0].RecordID != "a1" {
+		t.Errorf("altered chain = %d %+v", w.Code, report)
+	}
+}
+
+func TestPurgeFlow(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+
+	post := func(taskID, query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/"+taskID+"/purge"+query, strings.NewReader(body))
+		req.SetPathValue("taskID", taskID)
+		w := httptest.NewRecorder()
+		h.PurgeFlow(w, req)
+		return w
+	}
+
+	if w := post("t1", "", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing actor = %d, want 400", w.Code)
+	}
+	if w := post("missing", "", `{"actor":"dpo"}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown flow = %d, want 404", w.Code)
+	}
+	if w := post("t1", "", `{"actor":"dpo"}`); w.Code != http.StatusConflict {
+		t.Errorf("running flow = %d, want 409", w.Code)
+	}
+	w := post("t1", "?dry_run=true", `{"actor":"dpo"}`)
+	var report purge.Report
+	json.NewDecoder(w.Body).Decode(&report)
+	if w.Code != http.StatusOK || !report.DryRun || report.Rows["tasks"] != 1 {
+		t.Errorf("dry run = %d %+v", w.Code, report)
+	}
+
+	h.DB.Exec(`UPDATE tasks SET status = 'failed' WHERE task_id = 't1'`)
+	w = post("t1", "", `{"actor":"dpo"}`)
+	json.NewDecoder(w.Body).Decode(&report)
+	if w.Code != http.StatusOK || report.DryRun || report.AuditID == "" {
+		t.Errorf("purge = %d %+v", w.Code, report)
+	}
+	if _, err := h.Engine.GetState(ctx, "t1"); err != domain.ErrFlowNotFound {
+		t.Errorf("GetState after purge = %v, want ErrFlowNotFound", err)
+	}
+}
+
+func TestListGates(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+	h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "alice"})
+	h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "bob"})
+
+	get := func(taskID, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/"+taskID+"/gates"+query, nil)
+		req.SetPathValue("taskID", taskID)
+		w := httptest.NewRecorder()
+		h.ListGates(w, req)
+		return w
+	}
+
+	if w := get("missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown flow = %d, want 404", w.Code)
+	}
+	if w := get("t1", "?limit=x"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad limit = %d, want 400", w.Code)
+	}
+	w := get("t1", "")
+	var records []domain.GateRecord
+	json.NewDecoder(w.Body).Decode(&records)
+	if w.Code != http.StatusOK || len(records) != 2 || records[0].Phase != domain.PhaseB || records[0].Actor != "bob" {
+		t.Errorf("gates = %d %+v", w.Code, records)
+	}
+	w = get("t1", "?phase=A&limit=5")
+	records = nil
+	json.NewDecoder(w.Body).Decode(&records)
+	if len(records) != 1 || records[0].Actor != "alice" {
+		t.Errorf("phase A gates = %+v", records)
+	}
+}
+
+func TestSetBudget(t *testing.T) {
+	h := newTestHandler(t)
+	h.Engine.StartFlow(context.Background(), "t1", 10.0)
+
+	put := func(taskID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/flow/"+taskID+"/budget", bytes.NewBufferString(body))
+		req.SetPathValue("taskID", taskID)
+		w := httptest.NewRecorder()
+		h.SetBudget(w, req)
+		return w
+	}
+
+	if w := put("missing", `{"budget_cap_usd":5}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown flow = %d, want 404", w.Code)
+	}
+	if w := put("t1", `{"budget_cap_usd":0}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("zero cap = %d, want 422", w.Code)
+	}
+	w := put("t1", `{"budget_cap_usd":25}`)
+	var state domain.FlowState
+	json.NewDecoder(w.Body).Decode(&state)
+	if w.Code != http.StatusOK || state.BudgetCapUSD != 25 {
+		t.Errorf("set budget = %d %+v", w.Code, state)
+	}
+}
+
+func TestApprovals(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10.0)
+	for i := 0; i < 5; i++ {
+		h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "test"})
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/flow/t1/approvals", bytes.NewBufferString(body))
+		req.SetPathValue("taskID", "t1")
+		w := httptest.NewRecorder()
+		h.RecordApproval(w, req)
+		return w
+	}
+
+	if w := post(`{"actor":"","decision":"approved"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("missing actor = %d, want 422", w.Code)
+	}
+	w := post(`{"actor":"alice","decision":"rejected","comment":"needs tests"}`)
+	var out workflow.ApprovalOutcome
+	json.NewDecoder(w.Body).Decode(&out)
+	if w.Code != http.StatusCreated || out.ReworkTo != domain.PhaseE || out.Approval.Comment != "needs tests" {
+		t.Fatalf("reject = %d %+v", w.Code, out)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/approvals", nil)
+	req.SetPathValue("taskID", "t1")
+	w = httptest.NewRecorder()
+	h.ListApprovals(w, req)
+	var approvals []domain.Approval
+	json.NewDecoder(w.Body).Decode(&approvals)
+	if w.Code != http.StatusOK || len(approvals) != 1 || approvals[0].Decision != domain.ApprovalRejected {
+		t.Errorf("approvals = %d %+v", w.Code, approvals)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	h := newTestHandler(t)
+	srv := NewServer(h, ":0")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/api/v1/ns/team-a/flow", `{"task_id":"a1","budget_cap_usd":5}`); w.Code != http.StatusCreated {
+		t.Fatalf("create in team-a = %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPost, "/api/v1/flow", `{"task_id":"d1","budget_cap_usd":5}`); w.Code != http.StatusCreated {
+		t.Fatalf("create in default = %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPost, "/api/v1/ns/team-a/flow", `{"task_id":"a2","budget_cap_usd":5,"namespace":"team-b"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("mismatched namespace = %d, want 400", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/v1/ns/Team%20A/flow", `{"task_id":"a2","budget_cap_usd":5}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid namespace = %d, want 400", w.Code)
+	}
+
+	w := do(http.MethodGet, "/api/v1/ns/team-a/flow/a1", "")
+	var state domain.FlowState
+	json.NewDecoder(w.Body).Decode(&state)
+	if w.Code != http.StatusOK || state.Namespace != "team-a" {
+		t.Errorf("get a1 in team-a = %d %+v", w.Code, state)
+	}
+	if w := do(http.MethodGet, "/api/v1/ns/team-a/flow/d1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("get d1 in team-a = %d, want 404", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/v1/ns/team-b/flow/a1/events", ""); w.Code != http.StatusNotFound {
+		t.Errorf("events of a1 in team-b = %d, want 404", w.Code)
+	}
+
+	w = do(http.MethodGet, "/api/v1/ns/team-a/flow", "")
+	var flows []domain.FlowState
+	json.NewDecoder(w.Body).Decode(&flows)
+	if len(flows) != 1 || flows[0].TaskID != "a1" {
+		t.Errorf("team-a flows = %+v, want a1", flows)
+	}
+
+	w = do(http.MethodGet, "/api/v1/ns", "")
+	var summaries []domain.NamespaceSummary
+	json.NewDecoder(w.Body).Decode(&summaries)
+	if len(summaries) != 2 || summaries[0].Namespace != "default" || summaries[1].Namespace != "team-a" {
+		t.Errorf("namespaces = %+v", summaries)
+	}
+}
+
+func TestGetLeader(t *testing.T) {
+	h := newTestHandler(t)
+	get := func() LeaderResponse {
+		w := httptest.NewRecorder()
+		h.GetLeader(w, httptest.NewRequest(http.MethodGet, "/api/v1/leader", nil))
+		var resp LeaderResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+	if resp := get(); !resp.Leading {
+		t.Errorf("without an elector = %+v, want leading", resp)
+	}
+
+	h.Leader = leader.New(h.DB, "inst-1", 15*time.Second)
+	if resp := get(); resp.Leading || resp.Lease != nil {
+		t.Errorf("before campaigning = %+v, want not leading and no lease", resp)
+	}
+	h.Leader.Campaign(context.Background(), time.Now(), nil)
+	if resp := get(); !resp.Leading || resp.Instance != "inst-1" || resp.Lease == nil || resp.Lease.Holder != "inst-1" {
+		t.Errorf("after campaigning = %+v", resp)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	h := newTestHandler(t)
+	srv := NewServer(h, ":0")
+	post := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set(IdempotencyHeader, key)
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post("/api/v1/flow", "key-1", `{"task_id":"t1","budget_cap_usd":5}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first create = %d: %s", first.Code, first.Body)
+	}
+	retry := post("/api/v1/flow", "key-1", `{"task_id":"t1","budget_cap_usd":5}`)
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "true" || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %q, want the first response replayed", retry.Code, retry.Body)
+	}
+	if w := post("/api/v1/flow", "key-2", `{"task_id":"t2","budget_cap_usd":5}`); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("new key = %d %v, want a fresh create", w.Code, w.Header())
+	}
+	if w := post("/api/v1/flow/t1/advance", "key-1", `{"action":"advance"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused on another path = %d, want 422", w.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	h := newTestHandler(t)
+	srv := NewServer(h, ":0")
+	get := func(path string) (int, health.Report) {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var report health.Report
+		json.NewDecoder(w.Body).Decode(&report)
+		return w.Code, report
+	}
+
+	if code, report := get("/healthz"); code != http.StatusOK || report.Status != health.StatusOK {
+		t.Errorf("healthz = %d %+v", code, report)
+	}
+	if code, _ := get("/readyz"); code != http.StatusOK {
+		t.Errorf("readyz without checker = %d, want 200", code)
+	}
+
+	var providers []domain.Provider
+	h.Readiness = health.NewChecker(h.DB, func() []domain.Provider { return providers }, t.TempDir(), 0)
+	if code, report := get("/readyz"); code != http.StatusServiceUnavailable || report.Status != health.StatusUnavailable {
+		t.Errorf("readyz without providers = %d %+v, want 503", code, report)
+	}
+	providers = []domain.Provider{"claude"}
+	if code, report := get("/readyz"); code != http.StatusOK || len(report.Checks) != 4 {
+		t.Errorf("readyz = %d %+v, want 200 with 4 checks", code, report)
+	}
+}
+
+func TestStats(t *testing.T) {
+	h := newTestHandler(t)
+	get := func() domain.EngineStats {
+		w := httptest.NewRecorder()
+		h.Stats(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("stats = %d: %s", w.Code, w.Body)
+		}
+		var stats domain.EngineStats
+		json.NewDecoder(w.Body).Decode(&stats)
+		return stats
+	}
+
+	if err := h.Engine.StartFlow(context.Background(), "t1", 10); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	stats := get()
+	if stats.FlowsByStatus[domain.StatusRunning] != 1 || stats.FlowsByPhase[domain.PhaseA] != 1 {
+		t.Errorf("stats = %+v, want one running flow in A", stats)
+	}
+
+	// Cached counters do not see the second flow until the TTL passes.
+	h.Engine.StartFlow(context.Background(), "t2", 10)
+	if stats := get(); stats.FlowsByStatus[domain.StatusRunning] != 1 {
+		t.Errorf("cached stats = %+v, want the first result", stats)
+	}
+	h.StatsTTL = time.Nanosecond
+	if stats := get(); stats.FlowsByStatus[domain.StatusRunning] != 2 {
+		t.Errorf("refreshed stats = %+v, want two running flows", stats)
+	}
+}
+
+func TestPhaseDurations(t *testing.T) {
+	h := newTestHandler(t)
+	srv := NewServer(h, ":0")
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10)
+	h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance"})
+
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/flow/t1/phases", nil))
+	var stays []domain.PhaseDuration
+	json.NewDecoder(w.Body).Decode(&stays)
+	if w.Code != http.StatusOK || len(stays) != 2 || stays[1].Phase != domain.PhaseB {
+		t.Errorf("phases = %d %+v, want A and B", w.Code, stays)
+	}
+
+	w = httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/phases/stats", nil))
+	var stats []domain.PhaseDurationStats
+	json.NewDecoder(w.Body).Decode(&stats)
+	if w.Code != http.StatusOK || len(stats) != 2 || stats[0].Finished != 1 || stats[1].Open != 1 {
+		t.Errorf("phase stats = %d %+v", w.Code, stats)
+	}
+
+	w = httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/flow/missing/phases", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing flow = %d, want 404", w.Code)
+	}
+}
+
+func TestCostBreakdown(t *testing.T) {
+	h := newTestHandler(t)
+	srv := NewServer(h, ":0")
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10)
+	h.CostDeltaRepo.Create(ctx, h.DB, "t1", domain.CostDelta{AmountUSD: 1, Provider: "claude", CreatedAt: 1700000000})
+	h.CostDeltaRepo.Create(ctx, h.DB, "t1", domain.CostDelta{AmountUSD: 2, Provider: "codex", CreatedAt: 1700086400})
+	get := func(path string) (int, domain.CostBreakdown) {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var b domain.CostBreakdown
+		json.NewDecoder(w.Body).Decode(&b)
+		return w.Code, b
+	}
+
+	code, b := get("/api/v1/flow/t1/cost/breakdown?group_by=provider")
+	if code != http.StatusOK || b.TotalUSD != 3 || len(b.Groups) != 2 || b.Groups[0].Key != "codex" {
+		t.Errorf("by provider = %d %+v", code, b)
+	}
+	code, b = get("/api/v1/cost?group_by=day&since=2023-11-15")
+	if code != http.StatusOK || len(b.Groups) != 1 || b.Groups[0].AmountUSD != 2 {
+		t.Errorf("fleet by day since 2023-11-15 = %d %+v", code, b)
+	}
+	if code, b = get("/api/v1/cost"); code != http.StatusOK || b.GroupBy != "task" || b.Groups[0].Key != "t1" {
+		t.Errorf("fleet default = %d %+v", code, b)
+	}
+
+	for _, path := range []string{
+		"/api/v1/flow/t1/cost/breakdown?group_by=task",
+		"/api/v1/cost?group_by=color",
+		"/api/v1/cost?since=yesterday",
+	} {
+		if code, _ := get(path); code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", path, code)
+		}
+	}
+	if code, _ := get("/api/v1/flow/missing/cost/breakdown"); code != http.StatusNotFound {
+		t.Errorf("missing flow = %d, want 404", code)
+	}
+}
+
+func TestExportRecords(t *testing.T) {
+	h := newTestHandler(t)
+	srv := NewServer(h, ":0")
+	ctx := context.Background()
+	h.CostDeltaRepo.Create(ctx, h.DB, "t1", domain.CostDelta{AmountUSD: 1.5, Provider: "claude", CreatedAt: 1700000000})
+	h.CostDeltaRepo.Create(ctx, h.DB, "t1", domain.CostDelta{AmountUSD: 2, Provider: "codex", CreatedAt: 1700086400})
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/v1/export/costs?format=csv")
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" || len(lines) != 3 ||
+		lines[0] != "task_id,phase,provider,worker_id,input_tokens,output_tokens,amount_usd,created_at" ||
+		lines[1] != "t1,,claude,,0,0,1.5,1700000000" {
+		t.Errorf("csv = %d %q", w.Code, w.Body)
+	}
+
+	w = get("/api/v1/export/costs?since=2023-11-15")
+	var rows []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&rows); err != nil || len(rows) != 1 || rows[0]["provider"] != "codex" {
+		t.Errorf("json = %v %v, want the codex delta", rows, err)
+	}
+	w = get("/api/v1/export/events")
+	if err := json.NewDecoder(w.Body).Decode(&rows); err != nil || len(rows) != 0 {
+		t.Errorf("empty json = %q, %v", w.Body, err)
+	}
+
+	if w := get("/api/v1/export/tasks"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown kind = %d, want 404", w.Code)
+	}
+	if w := get("/api/v1/export/costs?format=xml"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown format = %d, want 400", w.Code)
+	}
+}
+
+func TestGetReport(t *testing.T) {
+	h := newTestHandler(t)
+	srv := NewServer(h, ":0")
+	h.Engine.StartFlow(context.Background(), "t1", 10)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/v1/flow/t1/report")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/markdown") || !strings.Contains(w.Body.String(), "# Run report: t1") {
+		t.Errorf("markdown = %d %q %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	w = get("/api/v1/flow/t1/report?format=html")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), "<h1>Run report: t1</h1>") {
+		t.Errorf("html = %d %q %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	if w = get("/api/v1/flow/t1/report?format=pdf"); w.Code != http.StatusBadRequest {
+		t.Errorf("format=pdf = %d, want 400", w.Code)
+	}
+	if w = get("/api/v1/flow/missing/report"); w.Code != http.StatusNotFound {
+		t.Errorf("missing flow = %d, want 404", w.Code)
+	}
+}
+
+func TestCloneFlow(t *testing.T) {
+	h := newTestHandler(t)
+	srv := NewServer(h, ":0")
+	ctx := context.Background()
+	h.Engine.StartFlow(ctx, "t1", 10)
+	h.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "test"})
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body)))
+		return w
+	}
+
+	w := post("/api/v1/flow/t1/clone?from_phase=B", `{"task_id":"t2","budget_cap_usd":4}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("clone = %d: %s", w.Code, w.Body)
+	}
+	var state domain.FlowState
+	json.NewDecoder(w.Body).Decode(&state)
+	if state.TaskID != "t2" || state.CurrentPhase != domain.PhaseB || state.BudgetCapUSD != 4 {
+		t.Errorf("clone = %+v, want t2 in B with a $4 cap", state)
+	}
+
+	for _, tc := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/v1/flow/t1/clone", `{"task_id":"t3"}`, http.StatusBadRequest},
+		{"/api/v1/flow/t1/clone?from_phase=B", `{}`, http.StatusBadRequest},
+		{"/api/v1/flow/t1/clone?from_phase=E", `{"task_id":"t3"}`, http.StatusUnprocessableEntity},
+		{"/api/v1/flow/t1/clone?from_phase=B", `{"task_id":"t2"}`, http.StatusConflict},
+		{"/api/v1/flow/missing/clone?from_phase=A", `{"task_id":"t3"}`, http.StatusNotFound},
+	} {
+		if w := post(tc.path, tc.body); w.Code != tc.want {
+			t.Errorf("%s %s = %d, want %d", tc.path, tc.body, w.Code, tc.want)
+		}
+	}
+}
+
+func TestFailFlow(t *testing.T) {
+	h := newTestHandler(t)
+	srv := NewServer(h, ":0")
+	h.Engine.StartFlow(context.Background(), "t1", 10)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+		return w
+	}
+
+	if w := do(http.MethodGet, "/api/v1/flow/t1/postmortem", ""); w.Code != http.StatusNotFound {
+		t.Errorf("post-mortem before failing = %d, want 404", w.Code)
+	}
+	for body, want := range map[string]int{
+		`{}`:                  http.StatusBadRequest,
+		`{"cause":"weather"}`: http.StatusUnprocessableEntity,
+	} {
+		if w := do(http.MethodPost, "/api/v1/flow/t1/fail", body); w.Code != want {
+			t.Errorf("fail %s = %d, want %d", body, w.Code, want)
+		}
+	}
+
+	w := do(http.MethodPost, "/api/v1/flow/t1/fail", `{"cause":"timeout","detail":"phase A stalled","actor":"alice"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("fail = %d: %s", w.Code, w.Body)
+	}
+	w = do(http.MethodGet, "/api/v1/flow/t1/postmortem", "")
+	var pm domain.PostMortem
+	json.NewDecoder(w.Body).Decode(&pm)
+	if w.Code != http.StatusOK || pm.Cause != domain.FailureTimeout || pm.Actor != "alice" || len(pm.Events) == 0 {
+		t.Errorf("post-mortem = %d %+v", w.Code, pm)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	h := newTestHandler(t)
+	srv := NewServer(h, ":0")
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	if w := do(http.MethodPost, "/api/v1/deadletters/1/requeue"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("requeue without a bridge = %d, want 503", w.Code)
+	}
+	gov := workflow.NewBudgetGovernor(h.DB)
+	h.Bridge = bridge.NewBridge(mcp.NewSessionManager(mcp.NewProviderRegistry()), h.Guard, gov, &store.CostDeltaRepo{}, &store.AuditRepo{}, h.DB)
+	h.Bridge.RecordDeadLetter(domain.DeadLetterEvent{
+		SessionID: "ses-1", TaskID: "t1", Provider: domain.ProviderClaude, Adapter: domain.ProviderClaude,
+		Raw: `{"type":"result","data":"ok"}`, Error: "truncated", CreatedAt: 1,
+	})
+	h.Bridge.RecordDeadLetter(domain.DeadLetterEvent{
+		SessionID: "ses-2", TaskID: "t1", Provider: domain.ProviderCodex, Adapter: domain.ProviderCodex,
+		Raw: "garbage", Error: "bad", CreatedAt: 2,
+	})
+
+	w := do(http.MethodGet, "/api/v1/deadletters?provider=claude")
+	var letters []domain.DeadLetterEvent
+	json.NewDecoder(w.Body).Decode(&letters)
+	if w.Code != http.StatusOK || len(letters) != 1 || letters[0].SessionID != "ses-1" {
+		t.Fatalf("list = %d %+v", w.Code, letters)
+	}
+	id := letters[0].ID
+	if w := do(http.MethodGet, fmt.Sprintf("/api/v1/deadletters/%d", id)); w.Code != http.StatusOK {
+		t.Errorf("get = %d", w.Code)
+	}
+	for path, want := range map[string]int{
+		"/api/v1/deadletters/x":       http.StatusBadRequest,
+		"/api/v1/deadletters/999":     http.StatusNotFound,
+		"/api/v1/deadletters?limit=x": http.StatusBadRequest,
+	} {
+		if w := do(http.MethodGet, path); w.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, want)
+		}
+	}
+
+	w = do(http.MethodPost, fmt.Sprintf("/api/v1/deadletters/%d/requeue", id))
+	var resp RequeueDeadLetterResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || len(resp.Events) != 1 {
+		t.Errorf("requeue = %d %+v", w.Code, resp)
+	}
+	if w := do(http.MethodPost, fmt.Sprintf("/api/v1/deadletters/%d/requeue", letters[0].ID+1)); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("requeue unparseable = %d, want 422", w.Code)
+	}
+
+	w = do(http.MethodGet, "/api/v1/metrics")
+	var metrics MetricsResponse
+	json.NewDecoder(w.Body).Decode(&metrics)
+	if metrics.DeadLetters.Pending != 1 {
+		t.Errorf("metrics dead letters = %+v, want 1 pending", metrics.DeadLetters)
+	}
+}
+
+func TestMiddleware_RecoversPanics(t *testing.T) {
+	h := newTestHandler(t)
+	handler := recoverMiddleware(h.DB, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/broken", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	records, err := (&store.AuditRepo{}).ListByTask(context.Background(), h.DB, "")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(records) != 1 || records[0].Action != "panic" || !strings.Contains(records[0].DecisionJSON, "boom") || !strings.Contains(records[0].RequestJSON, "/api/v1/broken") {
+		t.Errorf("audit = %+v, want one panic record", records)
+	}
+}
+
+func TestMiddleware_LimitsBodies(t *testing.T) {
+	h := newTestHandler(t)
+	h.HTTP.MaxBodyBytes = 64
+	srv := NewServer(h, ":0")
+
+	body := `{"taskId":"` + strings.Repeat("x", 100) + `","budgetCapUsd":5}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/flow", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("declared oversize body = %d, want 413", w.Code)
+	}
+
+	// A body without a declared length is cut off at the limit.
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/flow", strings.NewReader(body))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("undeclared oversize body = %d, want 400", w.Code)
+	}
+}
+
+func TestMiddleware_RouteTimeouts(t *testing.T) {
+	h := &Handler{HTTP: HTTPConfig{
+		RequestTimeout: 20 * time.Millisecond,
+		RouteTimeouts:  map[string]time.Duration{"GET /slow": 0},
+	}}
+	wait := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			w.WriteHeader(http.StatusGatewayTimeout)
+		case <-time.After(200 * time.Millisecond):
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.withTimeout("GET /fast", wait)(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("timed route = %d, want its context cancelled", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.withTimeout("GET /slow", wait)(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("untimed route = %d, want 200", w.Code)
+	}
+	if d := h.HTTP.routeTimeout("GET /api/v1/flow/{taskID}/events/stream"); d != 0 {
+		t.Errorf("stream timeout = %v, want none", d)
+	}
+}
+
+func TestProviders(t *testing.T) {
+	h := newTestHandler(t)
+	srv := NewServer(h, ":0")
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	if w := do(http.MethodGet, "/api/v1/providers"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a registry = %d, want 503", w.Code)
+	}
+
+	present := "sh"
+	if runtime.GOOS == "windows" {
+		present = "cmd"
+	}
+	h.Providers = mcp.NewProviderRegistry()
+	h.Providers.Replace([]mcp.ProviderSpec{
+		{Name: domain.ProviderClaude, Command: present},
+		{Name: domain.ProviderCodex, Command: "codexx-not-installed"},
+	})
+
+	var statuses []mcp.ProviderStatus
+	w := do(http.MethodGet, "/api/v1/providers")
+	json.NewDecoder(w.Body).Decode(&statuses)
+	if w.Code != http.StatusOK || len(statuses) != 2 || statuses[0].CheckedAt != 0 {
+		t.Fatalf("before validation = %d %+v, want two unchecked providers", w.Code, statuses)
+	}
+
+	w = do(http.MethodPost, "/api/v1/providers/validate")
+	json.NewDecoder(w.Body).Decode(&statuses)
+	if w.Code != http.StatusOK || len(statuses) != 2 || !statuses[0].OK || statuses[1].OK || statuses[1].Error == "" {
+		t.Errorf("validate = %d %+v, want claude ok and codex broken", w.Code, statuses)
+	}
+	w = do(http.MethodGet, "/api/v1/providers")
+	json.NewDecoder(w.Body).Decode(&statuses)
+	if len(statuses) != 2 || statuses[1].CheckedAt == 0 || statuses[1].OK {
+		t.Errorf("after validation = %+v, want the recorded results", statuses)
+	}
+}
+
+func TestProviderRegistration(t *testing.T) {
+	h := newTestHandler(t)
+	h.Providers = mcp.NewProviderRegistry()
+	srv := NewServer(h, ":0")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	present := "sh"
+	if runtime.GOOS == "windows" {
+		present = "cmd"
+	}
+
+	if w := do(http.MethodPost, "/api/v1/providers", `{"name":"Bad Name","command":"sh"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid name = %d, want 400", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/v1/providers", `{"name":"aider","command":"sh","env":{"KEY":"${keychain"}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("malformed secret reference = %d, want 400", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/v1/providers", `{"name":"aider","command":"sh","adapter":"claud"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown adapter = %d, want 400", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/v1/providers", `{"name":"aider","command":"aider-not-installed"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("missing command = %d, want 422", w.Code)
+	}
+
+	var status mcp.ProviderStatus
+	w := do(http.MethodPost, "/api/v1/providers", `{"name":"aider","command":"`+present+`","env":{"KEY":"${keychain:aider}"},"actor":"ops"}`)
+	json.NewDecoder(w.Body).Decode(&status)
+	if w.Code != http.StatusCreated || !status.OK || !status.Runtime {
+		t.Fatalf("register = %d %+v, want 201 and ok", w.Code, status)
+	}
+	if spec, err := h.Providers.Get("aider"); err != nil || spec.Env["KEY"] != "${keychain:aider}" {
+		t.Errorf("registered spec = %+v, %v", spec, err)
+	}
+
+	w = do(http.MethodPost, "/api/v1/providers", `{"name":"aider","command":"`+present+`","args":["--yes"],"actor":"ops"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("rotate = %d, want 200", w.Code)
+	}
+	if spec, _ := h.Providers.Get("aider"); len(spec.Args) != 1 || spec.Env != nil {
+		t.Errorf("rotated spec = %+v, want the new args and env", spec)
+	}
+	stored, err := (&store.ProviderRepo{}).List(context.Background(), h.DB)
+	if err != nil || len(stored) != 1 || stored[0].Args[0] != "--yes" {
+		t.Errorf("stored providers = %+v, %v", stored, err)
+	}
+
+	if w := do(http.MethodDelete, "/api/v1/providers/aider?actor=ops", ""); w.Code != http.StatusNoContent {
+		t.Errorf("delete = %d, want 204", w.Code)
+	}
+	if _, err := h.Providers.Get("aider"); err == nil {
+		t.Error("aider is still registered after delete")
+	}
+	if w := do(http.MethodDelete, "/api/v1/providers/aider", ""); w.Code != http.StatusNotFound {
+		t.Errorf("delete again = %d, want 404", w.Code)
+	}
+
+	records, err := (&store.AuditRepo{}).ListByTask(context.Background(), h.DB, "")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	var actions []string
+	for _, rec := range records {
+		if rec.Category == "provider" && rec.Actor == "ops" {
+			actions = append(actions, rec.Action)
+		}
+		if strings.Contains(rec.RequestJSON, "keychain") {
+			t.Errorf("audit record %s stores env values: %s", rec.Action, rec.RequestJSON)
+		}
+	}
+	if strings.Join(actions, ",") != "provider_registered,provider_updated,provider_removed" {
+		t.Errorf("audited actions = %v", actions)
+	}
+}
@@ -1,13 +1,23 @@
 package ipc
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
 )
 
 // Server wraps an HTTP server with engine-specific routing.
@@ -20,27 +30,121 @@ type Server struct {
 // it serves the frontend UI at "/" and auto-opens the browser.
 func NewServer(h *Handler, listenAddr string) *Server {
 	mux := http.NewServeMux()
+	// handle registers a route with its request timeout.
+	handle := func(pattern string, hf http.HandlerFunc) {
+		mux.Handle(pattern, h.withTimeout(pattern, hf))
+	}
+
+	// Health endpoints: /healthz for liveness, /readyz for readiness.
+	handle("GET /api/v1/health", h.Health)
+	handle("GET /healthz", h.Health)
+	handle("GET /readyz", h.Readyz)
+	handle("GET /api/v1/metrics", h.Metrics)
+	handle("GET /api/v1/deadletters", h.ListDeadLetters)
+	handle("GET /api/v1/deadletters/{id}", h.GetDeadLetter)
+	handle("POST /api/v1/deadletters/{id}/requeue", h.RequeueDeadLetter)
+	handle("GET /api/v1/providers", h.ListProviders)
+	handle("POST /api/v1/providers", h.RegisterProvider)
+	handle("DELETE /api/v1/providers/{name}", h.DeleteProvider)
+	handle("POST /api/v1/providers/validate", h.ValidateProviders)
+	handle("GET /api/v1/stats", h.Stats)
+	handle("GET /api/v1/phases/stats", h.PhaseStats)
+	handle("GET /api/v1/leader", h.GetLeader)
 
-	// Health endpoint.
-	mux.HandleFunc("GET /api/v1/health", h.Health)
+	// Flow endpoints are served both globally and under /api/v1/ns/{namespace},
+	// where only the namespace's flows are found.
+	// Both take the timeout of the global route.
+	flow := func(method, path string, hf http.HandlerFunc) {
+		pattern := method + " /api/v1/flow" + path
+		handle(pattern, hf)
+		mux.Handle(method+" /api/v1/ns/{namespace}/flow"+path, h.withTimeout(pattern, h.inNamespace(hf)))
+	}
+
+	// Namespace endpoint.
+	handle("GET /api/v1/ns", h.ListNamespaces)
 
 	// Flow endpoints.
-	mux.HandleFunc("POST /api/v1/flow", h.CreateFlow)
-	mux.HandleFunc("GET /api/v1/flow/{taskID}", h.GetFlow)
-	mux.HandleFunc("POST /api/v1/flow/{taskID}/advance", h.AdvanceFlow)
+	flow("POST", "", h.CreateFlow)
+	flow("GET", "", h.ListFlows)
+	flow("GET", "/{taskID}", h.GetFlow)
+	flow("POST", "/{taskID}/advance", h.AdvanceFlow)
+	flow("POST", "/{taskID}/precheck", h.Precheck)
+	flow("PUT", "/{taskID}/budget", h.SetBudget)
+	flow("POST", "/{taskID}/children", h.CreateChild)
+	flow("GET", "/{taskID}/children", h.ListChildren)
+	flow("POST", "/{taskID}/clone", h.CloneFlow)
+	flow("POST", "/{taskID}/fail", h.FailFlow)
+	flow("POST", "/{taskID}/purge", h.PurgeFlow)
+	flow("GET", "/{taskID}/postmortem", h.GetPostMortem)
+	flow("GET", "/{taskID}/export", h.ExportFlow)
+	flow("GET", "/{taskID}/policy", h.GetPolicy)
+	flow("GET", "/{taskID}/limits", h.GetLimits)
+	flow("GET", "/{taskID}/audit/verify", h.VerifyAudit)
+	flow("GET", "/{taskID}/gates", h.ListGates)
+	flow("GET", "/{taskID}/phases", h.ListPhaseDurations)
+	flow("GET", "/{taskID}/report", h.GetReport)
+	flow("GET", "/{taskID}/approvals", h.ListApprovals)
+	flow("POST", "/{taskID}/approvals", h.RecordApproval)
+	flow("POST", "/import", h.ImportFlow)
+
+	// Queue endpoint.
+	handle("GET /api/v1/queue", h.GetQueue)
 
-	// Worker endpoint.
-	mux.HandleFunc("GET /api/v1/flow/{taskID}/workers", h.ListWorkers)
+	// Worker endpoints.
+	handle("GET /api/v1/workers/queue", h.GetWorkerQueue)
+	handle("POST /api/v1/workers/{workerID}/progress", h.ReportProgress)
+	handle("POST /api/v1/workers/{workerID}/artifacts", h.SubmitArtifact)
+	handle("POST /api/v1/workers/{workerID}/exec", h.ExecCommand)
+	handle("GET /api/v1/workers/{workerID}/files", h.ListFiles)
+	handle("GET /api/v1/workers/{workerID}/files/content", h.ReadFile)
+	handle("PUT /api/v1/workers/{workerID}/files/content", h.WriteFile)
+	handle("GET /api/v1/artifacts/{artifactID}", h.GetArtifact)
+	flow("GET", "/{taskID}/digest", h.GetDigest)
+	flow("GET", "/{taskID}/constraints", h.ListConstraints)
+	flow("POST", "/{taskID}/constraints", h.AddConstraint)
+	flow("POST", "/{taskID}/constraints/{constraintID}/resolve", h.ResolveConstraint)
+	flow("GET", "/{taskID}/risks", h.ListRisks)
+	flow("POST", "/{taskID}/risks", h.AddRisk)
+	flow("POST", "/{taskID}/risks/{riskID}/resolve", h.ResolveRisk)
+	handle("GET /api/v1/artifacts/{artifactID}/content", h.GetArtifactContent)
+	flow("GET", "/{taskID}/workers", h.ListWorkers)
+
+	// Intent endpoint.
+	flow("GET", "/{taskID}/intents", h.ListIntents)
+
+	// Decision endpoints.
+	flow("GET", "/{taskID}/decisions", h.ListDecisions)
+	flow("POST", "/{taskID}/decisions/{decisionID}", h.Decide)
 
 	// Event endpoints.
-	mux.HandleFunc("GET /api/v1/flow/{taskID}/events", h.ListEvents)
-	mux.HandleFunc("GET /api/v1/flow/{taskID}/events/stream", h.StreamEvents)
+	flow("GET", "/{taskID}/events", h.ListEvents)
+	flow("POST", "/{taskID}/events", h.AppendEvent)
+	flow("GET", "/{taskID}/events/stream", h.StreamEvents)
+
+	// Review endpoints.
+	flow("GET", "/{taskID}/reviews", h.ListReviews)
+	flow("POST", "/{taskID}/reviews", h.SubmitReview)
+	flow("GET", "/{taskID}/reviews/diff", h.DiffReviews)
+	flow("GET", "/{taskID}/issues", h.ListIssues)
+	flow("POST", "/{taskID}/issues/{issueID}/status", h.UpdateIssue)
+	flow("GET", "/{taskID}/rounds", h.ListRounds)
+	flow("GET", "/{taskID}/consensus", h.GetConsensus)
 
-	// Review endpoint.
-	mux.HandleFunc("GET /api/v1/flow/{taskID}/reviews", h.ListReviews)
+	// CI endpoints.
+	flow("GET", "/{taskID}/ci", h.ListCIChecks)
+	handle("POST /api/v1/ci/webhook", h.CIWebhook)
+
+	// Diff endpoint.
+	flow("GET", "/{taskID}/diff", h.GetDiff)
 
 	// Cost endpoint.
-	mux.HandleFunc("GET /api/v1/flow/{taskID}/cost", h.GetCost)
+	flow("GET", "/{taskID}/cost", h.GetCost)
+	flow("GET", "/{taskID}/cost/breakdown", h.GetCostBreakdown)
+	handle("GET /api/v1/cost", h.GetFleetCost)
+	handle("GET /api/v1/export/{kind}", h.ExportRecords)
+
+	// Session transcript endpoint.
+	handle("GET /api/v1/sessions/{sessionID}/transcript", h.GetTranscript)
 
 	// Serve frontend static files if dist/ directory exists.
 	if distDir := findDistDir(); distDir != "" {
@@ -49,9 +153,13 @@ func NewServer(h *Handler, listenAddr string) *Server {
 		mux.Handle("/", fs)
 	}
 
+	cfg := h.HTTP.withDefaults()
 	srv := &http.Server{
-		Addr:    listenAddr,
-		Handler: corsMiddleware(mux),
+		Addr:              listenAddr,
+		Handler:           logMiddleware(cfg.LogRequests, recoverMiddleware(h.DB, limitBodyMiddleware(cfg.MaxBodyBytes, corsMiddleware(idempotencyMiddleware(h.DB, mux))))),
+		ReadHeaderTimeout: cfg.ReadTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
 	}
 
 	return &Server{
@@ -64,17 +172,203 @@ func (s *Server) Start() error {
 	return s.httpServer.ListenAndServe()
 }
 
+// Serve accepts HTTP connections on ln. Blocks until the server stops.
+func (s *Server) Serve(ln net.Listener) error {
+	return s.httpServer.Serve(ln)
+}
+
 // Shutdown gracefully stops the server.
 func (s *Server) Shutdown(ctx context.Context) error {
 	return s.httpServer.Shutdown(ctx)
 }
 
+// HTTPConfig sets the server's limits. Zero fields take the defaults in
+// withDefaults.
+type HTTPConfig struct {
+	// ReadTimeout bounds reading a request, headers and body.
+	ReadTimeout time.Duration
+	// IdleTimeout closes keep-alive connections idle this long.
+	IdleTimeout time.Duration
+	// RequestTimeout bounds each request's handling and the writing of its
+	// response, unless RouteTimeouts sets another for its route.
+	RequestTimeout time.Duration
+	// RouteTimeouts overrides RequestTimeout per route pattern, such as
+	// "GET /api/v1/flow/{taskID}/report"; 0 means no timeout. Namespaced
+	// flow routes use the timeout of their global pattern. Entries are
+	// merged over defaultRouteTimeouts.
+	RouteTimeouts map[string]time.Duration
+	// MaxBodyBytes caps request bodies. Larger ones are refused with 413.
+	MaxBodyBytes int64
+	// LogRequests logs the method, path, status, and duration of every
+	// request.
+	LogRequests bool
+}
+
+// defaultRouteTimeouts are the routes that need longer than RequestTimeout:
+// the event stream runs until the client leaves, a long-polled event
+// listing holds for up to maxEventWait, and exec enforces its own timeout.
+var defaultRouteTimeouts = map[string]time.Duration{
+	"GET /api/v1/flow/{taskID}/events/stream": 0,
+	"GET /api/v1/flow/{taskID}/events":        maxEventWait + 30*time.Second,
+	"POST /api/v1/workers/{workerID}/exec":    0,
+}
+
+// withDefaults fills in the zero fields of c.
+func (c HTTPConfig) withDefaults() HTTPConfig {
+	if c.ReadTimeout == 0 {
+		c.ReadTimeout = time.Minute
+	}
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = 2 * time.Minute
+	}
+	if c.RequestTimeout == 0 {
+		c.RequestTimeout = 30 * time.Second
+	}
+	if c.MaxBodyBytes == 0 {
+		c.MaxBodyBytes = 32 << 20
+	}
+	return c
+}
+
+// routeTimeout returns the request timeout of pattern, 0 for none.
+func (c HTTPConfig) routeTimeout(pattern string) time.Duration {
+	if d, ok := c.RouteTimeouts[pattern]; ok {
+		return d
+	}
+	if d, ok := defaultRouteTimeouts[pattern]; ok {
+		return d
+	}
+	return c.withDefaults().RequestTimeout
+}
+
+// writeGrace is how long past its timeout a request may still write its
+// response, so a handler that gives up can report why.
+const writeGrace = 5 * time.Second
+
+// withTimeout bounds hf by the timeout of pattern: its request context is
+// cancelled when the timeout passes, and writes fail writeGrace later.
+func (h *Handler) withTimeout(pattern string, hf http.HandlerFunc) http.HandlerFunc {
+	d := h.HTTP.routeTimeout(pattern)
+	if d <= 0 {
+		return hf
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d + writeGrace))
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		hf(w, r.WithContext(ctx))
+	}
+}
+
+// limitBodyMiddleware refuses requests declaring a body over max bytes with
+// 413 and stops reading any body at max bytes.
+func limitBodyMiddleware(max int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > max {
+			writeJSON(w, http.StatusRequestEntityTooLarge, APIError{Code: 413, Message: fmt.Sprintf("request body exceeds %d bytes", max)})
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recoverMiddleware turns a handler panic into a 500, logging it with its
+// stack and recording an error audit entry, instead of dropping the
+// connection.
+func recoverMiddleware(db *sql.DB, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			log.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, p, debug.Stack())
+			if db != nil {
+				now := time.Now()
+				req, _ := json.Marshal(map[string]string{"method": r.Method, "path": r.URL.Path})
+				decision, _ := json.Marshal(map[string]string{"panic": fmt.Sprint(p)})
+				_ = (&store.AuditRepo{}).Record(context.Background(), db, domain.AuditRecord{
+					ID:           fmt.Sprintf("aud-%d", now.UnixNano()),
+					Category:     "http",
+					Actor:        "system",
+					Action:       "panic",
+					RequestJSON:  string(req),
+					DecisionJSON: string(decision),
+					Severity:     "error",
+					CreatedAt:    now.Unix(),
+				})
+			}
+			if sw.status == 0 {
+				writeJSON(sw, http.StatusInternalServerError, APIError{Code: 500, Message: "internal server error"})
+			}
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// logMiddleware logs each request's method, path, status, and duration
+// when enabled.
+func logMiddleware(enabled bool, next http.Handler) http.Handler {
+	if !enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		log.Printf("%s %s %d %s", r.Method, r.URL.Path, sw.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// statusWriter passes a response through while keeping its status, 0
+// until the header is written. It keeps streaming working by passing
+// Flush on and unwrapping for http.ResponseController.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // corsMiddleware adds CORS headers for local desktop app access.
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, Last-Event-ID")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -85,6 +379,88 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// IdempotencyHeader carries a client-chosen key that makes a POST, PUT, or
+// DELETE safe to retry.
+const IdempotencyHeader = "Idempotency-Key"
+
+// idempotencyTTL is how long a response stays replayable under its key.
+const idempotencyTTL = 24 * time.Hour
+
+// idempotencyMiddleware stores the response to a write request sent with an
+// Idempotency-Key and replays it, marked Idempotent-Replayed, when a request
+// with the same key arrives within idempotencyTTL, without running the
+// handler again. A key reused for another method or path is refused. 5xx
+// responses are not stored, so the request can be retried.
+func idempotencyMiddleware(db *sql.DB, next http.Handler) http.Handler {
+	repo := &store.IdempotencyRepo{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyHeader)
+		if key == "" || db == nil || (r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		since := now.Add(-idempotencyTTL).Unix()
+		stored, err := repo.Get(r.Context(), db, key, since)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if stored != nil {
+			if stored.Method != r.Method || stored.Path != r.URL.Path {
+				writeJSON(w, http.StatusUnprocessableEntity, APIError{Code: 422, Message: "idempotency key was used for a different request"})
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			io.WriteString(w, stored.Body)
+			return
+		}
+
+		rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status >= 500 {
+			return
+		}
+		_ = repo.Save(context.Background(), db, domain.IdempotentResponse{
+			Key:       key,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Status:    rec.status,
+			Body:      rec.body.String(),
+			CreatedAt: now.Unix(),
+		}, since)
+	})
+}
+
+// recordingWriter passes a response through while keeping its status and
+// body.
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // findDistDir looks for a dist/ directory next to the executable, then in cwd.
 func findDistDir() string {
 	if exe, err := os.Executable(); err == nil {
@@ -0,0 +1,133 @@
+// Package leader elects one engine instance, among those sharing a database,
+// to run the background loops. The leader holds a lease row that it renews;
+// when it stops renewing, another instance takes over once the lease expires.
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// LeaseName is the lease the engine's background loops run under.
+const LeaseName = "engine"
+
+// Elector campaigns for a lease on behalf of one instance.
+type Elector struct {
+	DB     *sql.DB
+	Repo   *store.LeaseRepo
+	Name   string
+	Holder string
+	// TTL is how long a lease lasts without renewal. The elector renews it
+	// every TTL/3.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	leading bool
+	expires time.Time
+	cancel  context.CancelFunc
+}
+
+// New creates an Elector for the engine lease with a TTL of ttl, holding it
+// as holder.
+func New(db *sql.DB, holder string, ttl time.Duration) *Elector {
+	return &Elector{DB: db, Repo: &store.LeaseRepo{}, Name: LeaseName, Holder: holder, TTL: ttl}
+}
+
+// DefaultHolder identifies this process by host name and process ID.
+func DefaultHolder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// IsLeader reports whether this instance currently holds the lease.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leading
+}
+
+// Lease returns the lease as stored, or nil if nobody holds it.
+func (e *Elector) Lease(ctx context.Context) (*domain.Lease, error) {
+	return e.Repo.Get(ctx, e.DB, e.Name)
+}
+
+// Run campaigns for the lease until ctx is cancelled, then releases it.
+// Each time this instance becomes leader, lead is called with a context
+// that is cancelled when leadership is lost, so the loops it starts stop.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	go func() {
+		ticker := time.NewTicker(e.TTL / 3)
+		defer ticker.Stop()
+		for {
+			_ = e.Campaign(ctx, time.Now(), lead)
+			select {
+			case <-ctx.Done():
+				_ = e.Resign(context.Background())
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Campaign makes one attempt at taking or renewing the lease at now and
+// starts or stops leading accordingly. A failed write keeps a leader leading
+// until its last lease runs out.
+func (e *Elector) Campaign(ctx context.Context, now time.Time, lead func(ctx context.Context)) error {
+	expires := now.Add(e.TTL)
+	held, err := e.Repo.Acquire(ctx, e.DB, e.Name, e.Holder, now.Unix(), expires.Unix())
+	if err != nil {
+		e.mu.Lock()
+		lapsed := e.leading && !now.Before(e.expires)
+		e.mu.Unlock()
+		if lapsed {
+			e.stepDown()
+		}
+		return err
+	}
+	if !held {
+		e.stepDown()
+		return nil
+	}
+
+	e.mu.Lock()
+	e.expires = expires
+	if e.leading {
+		e.mu.Unlock()
+		return nil
+	}
+	leadCtx, cancel := context.WithCancel(ctx)
+	e.leading, e.cancel = true, cancel
+	e.mu.Unlock()
+	if lead != nil {
+		lead(leadCtx)
+	}
+	return nil
+}
+
+// Resign stops leading and releases the lease, letting another instance
+// take over without waiting for it to expire.
+func (e *Elector) Resign(ctx context.Context) error {
+	e.stepDown()
+	return e.Repo.Release(ctx, e.DB, e.Name, e.Holder)
+}
+
+// stepDown stops leading, cancelling the loops started by lead.
+func (e *Elector) stepDown() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.leading, e.cancel = false, nil
+}
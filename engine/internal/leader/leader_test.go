@@ -0,0 +1,60 @@
+package leader
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+func TestElector_OneLeaderAtATime(t *testing.T) {
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	a := New(db, "a", 15*time.Second)
+	b := New(db, "b", 15*time.Second)
+
+	var aCtx context.Context
+	leads := map[string]int{}
+	lead := func(name string) func(context.Context) {
+		return func(c context.Context) {
+			leads[name]++
+			if name == "a" {
+				aCtx = c
+			}
+		}
+	}
+
+	now := time.Unix(1000, 0)
+	a.Campaign(ctx, now, lead("a"))
+	b.Campaign(ctx, now, lead("b"))
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("leaders: a=%v b=%v, want only a", a.IsLeader(), b.IsLeader())
+	}
+
+	// Renewing keeps a leading without starting its loops again.
+	a.Campaign(ctx, now.Add(5*time.Second), lead("a"))
+	b.Campaign(ctx, now.Add(16*time.Second), lead("b"))
+	if leads["a"] != 1 || b.IsLeader() {
+		t.Fatalf("after renewal: leads=%v b=%v", leads, b.IsLeader())
+	}
+
+	// a stops renewing; b takes over once the lease expires, and a steps
+	// down on its next attempt.
+	b.Campaign(ctx, now.Add(21*time.Second), lead("b"))
+	if !b.IsLeader() {
+		t.Fatal("b did not take the expired lease")
+	}
+	a.Campaign(ctx, now.Add(22*time.Second), lead("a"))
+	if a.IsLeader() || aCtx.Err() == nil {
+		t.Error("a kept leading after losing the lease")
+	}
+	if l, _ := a.Lease(ctx); l == nil || l.Holder != "b" {
+		t.Errorf("lease = %+v, want held by b", l)
+	}
+}
@@ -0,0 +1,350 @@
+// Package loadtest simulates concurrent flows against an in-process engine
+// backed by a scratch database: each flow's session reports a stream of
+// cost events through the bridge, checks its budget with the guard, and
+// churns intent locks. It backs cmd/loadgen and the benchmarks, so
+// regressions in the store, guard, and bridge show up before a release.
+package loadtest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/bridge"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/guard"
+	"github.com/anthropics/three-body-engine/internal/mcp"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/team"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// Operations timed by Run.
+const (
+	OpCost   = "cost"   // a cost event handled by the bridge
+	OpGuard  = "guard"  // a budget check by the guard
+	OpIntent = "intent" // an intent lock acquired and released
+	OpState  = "state"  // a flow state read
+)
+
+// costPerEvent is the spend of each simulated cost event.
+const costPerEvent = 0.001
+
+// Config sizes a run. Zero fields take the defaults noted.
+type Config struct {
+	// Flows is the number of flows run concurrently (default 10).
+	Flows int
+	// CostEvents is the number of cost events each flow's session reports
+	// (default 200).
+	CostEvents int
+	// Intents is the number of intent locks each flow's worker acquires and
+	// releases (default 50).
+	Intents int
+	// Batch charges cost events through a CostBatcher, as a positive
+	// cost_batch_size does.
+	Batch bool
+	// TaskCache caches up to this many flow states in the store; 0 disables
+	// the cache.
+	TaskCache int
+	// DBPath is the scratch database. Empty means a temporary one, removed
+	// when the run ends.
+	DBPath string
+}
+
+func (c Config) withDefaults() Config {
+	if c.Flows == 0 {
+		c.Flows = 10
+	}
+	if c.CostEvents == 0 {
+		c.CostEvents = 200
+	}
+	if c.Intents == 0 {
+		c.Intents = 50
+	}
+	return c
+}
+
+// OpStats summarises the latencies of one operation.
+type OpStats struct {
+	Op     string        `json:"op"`
+	Count  int           `json:"count"`
+	Errors int           `json:"errors"`
+	PerSec float64       `json:"perSec"`
+	P50    time.Duration `json:"p50"`
+	P99    time.Duration `json:"p99"`
+	Max    time.Duration `json:"max"`
+}
+
+// Report is the result of a run.
+type Report struct {
+	Flows   int           `json:"flows"`
+	Elapsed time.Duration `json:"elapsed"`
+	Ops     []OpStats     `json:"ops"`
+}
+
+// Op returns the stats of the named operation.
+func (r *Report) Op(name string) OpStats {
+	for _, s := range r.Ops {
+		if s.Op == name {
+			return s
+		}
+	}
+	return OpStats{Op: name}
+}
+
+// harness is the engine a run drives.
+type harness struct {
+	db       *sql.DB
+	cache    *store.TaskCache
+	engine   *workflow.Engine
+	guard    *guard.Guard
+	bridge   *bridge.Bridge
+	intents  *team.IntentResolver
+	batcher  *workflow.CostBatcher
+	workers  *store.WorkerRepo
+	cfg      Config
+	recorder *recorder
+}
+
+// Run starts cfg.Flows flows and drives them concurrently until each has
+// reported its cost events and churned its intents. It fails if any flow's
+// recorded spend differs from what its session reported, since a lost
+// update under load is a correctness bug, not just a slow path.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	cfg = cfg.withDefaults()
+	path := cfg.DBPath
+	if path == "" {
+		dir, err := os.MkdirTemp("", "threebody-loadtest-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		path = filepath.Join(dir, "load.db")
+	}
+	h, err := newHarness(path, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer h.close()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	errs := make(chan error, cfg.Flows)
+	for i := 0; i < cfg.Flows; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := h.runFlow(ctx, fmt.Sprintf("load-%d", i)); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	if h.batcher != nil {
+		if err := h.batcher.Flush(ctx); err != nil {
+			return nil, err
+		}
+	}
+	elapsed := time.Since(start)
+	if err := h.verify(ctx); err != nil {
+		return nil, err
+	}
+	return &Report{Flows: cfg.Flows, Elapsed: elapsed, Ops: h.recorder.stats(elapsed)}, nil
+}
+
+func newHarness(path string, cfg Config) (*harness, error) {
+	db, err := store.NewDB(path)
+	if err != nil {
+		return nil, err
+	}
+	var cache *store.TaskCache
+	if cfg.TaskCache > 0 {
+		cache = store.CacheTasks(db, cfg.TaskCache)
+	}
+	engine := workflow.NewEngine(db)
+	gov := workflow.NewBudgetGovernor(db)
+	g := guard.NewGuard(db, gov, team.NewPermissionBroker(db), guard.GuardConfig{})
+	b := bridge.NewBridge(mcp.NewSessionManager(mcp.NewProviderRegistry()), g, gov,
+		&store.CostDeltaRepo{}, &store.AuditRepo{}, db)
+	h := &harness{
+		db:     db,
+		cache:  cache,
+		engine: engine,
+		guard:  g,
+		bridge: b,
+		intents: &team.IntentResolver{
+			DB:         db,
+			IntentRepo: &store.IntentRepo{},
+			WorkerRepo: &store.WorkerRepo{},
+			AuditRepo:  &store.AuditRepo{},
+		},
+		workers:  &store.WorkerRepo{},
+		cfg:      cfg,
+		recorder: newRecorder(),
+	}
+	if cfg.Batch {
+		h.batcher = workflow.NewCostBatcher(gov, &store.CostDeltaRepo{})
+		b.CostBatcher = h.batcher
+	}
+	return h, nil
+}
+
+func (h *harness) close() {
+	if h.cache != nil {
+		h.cache.Close()
+	}
+	h.db.Close()
+}
+
+// runFlow starts one flow with a worker owning src/ and interleaves its
+// cost events, budget checks, state reads, and intent churn.
+func (h *harness) runFlow(ctx context.Context, taskID string) error {
+	if err := h.engine.StartFlow(ctx, taskID, 1e6); err != nil {
+		return fmt.Errorf("start %s: %w", taskID, err)
+	}
+	workerID := taskID + "-w"
+	if err := h.workers.Create(ctx, h.db, domain.WorkerRef{
+		WorkerID:      workerID,
+		TaskID:        taskID,
+		Phase:         domain.PhaseA,
+		Role:          "load",
+		State:         domain.WorkerRunning,
+		FileOwnership: []string{"src/"},
+		CreatedAtUnix: time.Now().Unix(),
+	}); err != nil {
+		return fmt.Errorf("create worker for %s: %w", taskID, err)
+	}
+
+	sessionID := "ses-" + taskID
+	cfg := domain.SessionConfig{TaskID: taskID, WorkerID: workerID}
+	n := h.cfg.CostEvents
+	if h.cfg.Intents > n {
+		n = h.cfg.Intents
+	}
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if i < h.cfg.CostEvents {
+			payload, _ := json.Marshal(mcp.CostPayload{InputTokens: 100, OutputTokens: 20, AmountUSD: costPerEvent, Key: fmt.Sprint(i)})
+			ev := domain.NormalizedEvent{Type: mcp.EventCost, Provider: domain.ProviderClaude, SessionID: sessionID, Payload: payload}
+			h.time(OpCost, func() error {
+				h.bridge.ProcessEvent(ctx, cfg, ev)
+				return nil
+			})
+			h.time(OpGuard, func() error {
+				_, err := h.guard.CheckBudget(ctx, taskID)
+				return err
+			})
+		}
+		if i < h.cfg.Intents {
+			intent := domain.Intent{
+				IntentID:   fmt.Sprintf("%s-i%d", taskID, i),
+				TaskID:     taskID,
+				WorkerID:   workerID,
+				TargetFile: fmt.Sprintf("src/file%d.go", i%4),
+				Operation:  "write",
+			}
+			h.time(OpIntent, func() error {
+				if err := h.intents.AcquireLock(ctx, intent, 60); err != nil {
+					return err
+				}
+				return h.intents.ReleaseLock(ctx, intent.IntentID)
+			})
+		}
+		if i%10 == 0 {
+			h.time(OpState, func() error {
+				_, err := h.engine.GetState(ctx, taskID)
+				return err
+			})
+		}
+	}
+	return nil
+}
+
+// verify checks every flow was charged exactly what its session reported.
+func (h *harness) verify(ctx context.Context) error {
+	want := float64(h.cfg.CostEvents) * costPerEvent
+	for i := 0; i < h.cfg.Flows; i++ {
+		taskID := fmt.Sprintf("load-%d", i)
+		state, err := h.engine.GetState(ctx, taskID)
+		if err != nil {
+			return err
+		}
+		if math.Abs(state.BudgetUsedUSD-want) > 1e-9 {
+			return fmt.Errorf("flow %s recorded $%.6f, want $%.6f", taskID, state.BudgetUsedUSD, want)
+		}
+	}
+	return nil
+}
+
+// time runs op and records its latency under name.
+func (h *harness) time(name string, op func() error) {
+	start := time.Now()
+	err := op()
+	h.recorder.add(name, time.Since(start), err)
+}
+
+// recorder collects latencies from concurrent flows.
+type recorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{latencies: make(map[string][]time.Duration), errors: make(map[string]int)}
+}
+
+func (r *recorder) add(op string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies[op] = append(r.latencies[op], d)
+	if err != nil {
+		r.errors[op]++
+	}
+}
+
+// stats summarises each operation, in name order.
+func (r *recorder) stats(elapsed time.Duration) []OpStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]OpStats, 0, len(r.latencies))
+	for op, ds := range r.latencies {
+		sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+		out = append(out, OpStats{
+			Op:     op,
+			Count:  len(ds),
+			Errors: r.errors[op],
+			PerSec: float64(len(ds)) / elapsed.Seconds(),
+			P50:    percentile(ds, 0.50),
+			P99:    percentile(ds, 0.99),
+			Max:    ds[len(ds)-1],
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Op < out[j].Op })
+	return out
+}
+
+// percentile returns the q quantile of sorted durations.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
@@ -0,0 +1,67 @@
+package loadtest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	for _, batch := range []bool{false, true} {
+		rep, err := Run(context.Background(), Config{Flows: 4, CostEvents: 20, Intents: 8, Batch: batch, TaskCache: 64})
+		if err != nil {
+			t.Fatalf("Run(batch=%v): %v", batch, err)
+		}
+		if rep.Flows != 4 || rep.Elapsed <= 0 {
+			t.Errorf("report = %+v", rep)
+		}
+		for op, want := range map[string]int{OpCost: 80, OpGuard: 80, OpIntent: 32, OpState: 8} {
+			st := rep.Op(op)
+			if st.Count != want || st.Errors != 0 || st.P99 < st.P50 || st.Max < st.P99 {
+				t.Errorf("batch=%v %s = %+v, want %d clean ops", batch, op, st, want)
+			}
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i + 1)
+	}
+	if got := percentile(sorted, 0.99); got != 99 {
+		t.Errorf("p99 = %d, want 99", got)
+	}
+	if got := percentile(sorted, 0.50); got != 50 {
+		t.Errorf("p50 = %d, want 50", got)
+	}
+	if got := percentile(nil, 0.99); got != 0 {
+		t.Errorf("p99 of nothing = %d, want 0", got)
+	}
+}
+
+func benchmarkRun(b *testing.B, cfg Config) {
+	for i := 0; i < b.N; i++ {
+		rep, err := Run(context.Background(), cfg)
+		if err != nil {
+			b.Fatalf("Run: %v", err)
+		}
+		for _, op := range []string{OpCost, OpIntent} {
+			st := rep.Op(op)
+			b.ReportMetric(float64(st.P99.Microseconds()), op+"-p99-µs")
+			b.ReportMetric(st.PerSec, op+"/s")
+		}
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	benchmarkRun(b, Config{Flows: 8, CostEvents: 50, Intents: 10})
+}
+
+func BenchmarkRun_Batched(b *testing.B) {
+	benchmarkRun(b, Config{Flows: 8, CostEvents: 50, Intents: 10, Batch: true})
+}
+
+func BenchmarkRun_TaskCache(b *testing.B) {
+	benchmarkRun(b, Config{Flows: 8, CostEvents: 50, Intents: 10, TaskCache: 1024})
+}
@@ -0,0 +1,360 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// Canonical event types emitted by every ProviderAdapter. Native event types
+// that have no canonical equivalent are passed through unchanged.
+const (
+	EventMessage  = "message"
+	EventToolCall = "tool_call"
+	EventCost     = "cost"
+	EventResult   = "result"
+	EventError    = "error"
+)
+
+// MessagePayload is the canonical payload of a "message" event.
+type MessagePayload struct {
+	Role string `json:"role"`
+	Text string `json:"text"`
+}
+
+// ToolCallPayload is the canonical payload of a "tool_call" event.
+type ToolCallPayload struct {
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// CostPayload is the canonical payload of a "cost" event. Its JSON shape
+// matches domain.CostDelta so consumers can decode it directly.
+type CostPayload struct {
+	InputTokens  int64   `json:"inputTokens"`
+	OutputTokens int64   `json:"outputTokens"`
+	AmountUSD    float64 `json:"amountUsd"`
+	// Key, when set, identifies the report within its session, such as a
+	// provider's message ID; a report with a key seen before is dropped.
+	Key string `json:"key,omitempty"`
+	// Cumulative marks amounts that are the session's totals so far rather
+	// than the spend since the previous report. Sessions turn them into
+	// deltas before they reach consumers.
+	Cumulative bool `json:"cumulative,omitempty"`
+}
+
+// ResultPayload is the canonical payload of a "result" event.
+type ResultPayload struct {
+	Text string `json:"text"`
+}
+
+// ErrorPayload is the canonical payload of an "error" event.
+type ErrorPayload struct {
+	Message string `json:"message"`
+}
+
+// ProviderAdapter translates one line of a provider's native JSON output into
+// zero or more canonical events. Provider and SessionID are filled in by the
+// session, so adapters only set Type and Payload.
+type ProviderAdapter interface {
+	Translate(line []byte) ([]domain.NormalizedEvent, error)
+}
+
+// PassthroughAdapter names the adapter that forwards lines as-is, using
+// their "type" field.
+const PassthroughAdapter = "passthrough"
+
+// adapters maps built-in providers to their translators.
+var adapters = map[domain.Provider]ProviderAdapter{
+	domain.ProviderClaude:               claudeAdapter{},
+	domain.ProviderCodex:                codexAdapter{},
+	domain.ProviderGemini:               geminiAdapter{},
+	domain.ProviderMock:                 passthroughAdapter{},
+	domain.Provider(PassthroughAdapter): passthroughAdapter{},
+}
+
+// ValidAdapter reports whether name selects a built-in adapter. Configured
+// and registered adapter names are checked with it, since AdapterFor would
+// translate an unknown one as passthrough.
+func ValidAdapter(name string) bool {
+	_, ok := adapters[domain.Provider(name)]
+	return ok
+}
+
+// AdapterNames returns the names ValidAdapter accepts, sorted.
+func AdapterNames() []string {
+	names := make([]string, 0, len(adapters))
+	for name := range adapters {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AdapterFor returns the adapter for the given name, falling back to a
+// passthrough adapter that only requires a "type" field. Only a provider
+// that names no adapter should reach the fallback.
+func AdapterFor(name domain.Provider) ProviderAdapter {
+	if a, ok := adapters[name]; ok {
+		return a
+	}
+	return passthroughAdapter{}
+}
+
+// passthroughAdapter forwards lines as-is, using their "type" field.
+type passthroughAdapter struct{}
+
+func (passthroughAdapter) Translate(line []byte) ([]domain.NormalizedEvent, error) {
+	typ, err := lineType(line)
+	if err != nil {
+		return nil, err
+	}
+	return []domain.NormalizedEvent{passthrough(typ, line)}, nil
+}
+
+// claudeAdapter translates Claude Code stream-json output.
+type claudeAdapter struct{}
+
+func (claudeAdapter) Translate(line []byte) ([]domain.NormalizedEvent, error) {
+	typ, err := lineType(line)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case "assistant":
+		var raw struct {
+			Message struct {
+				Content []struct {
+					Type  string          `json:"type"`
+					Text  string          `json:"text"`
+					ID    string          `json:"id"`
+					Name  string          `json:"name"`
+					Input json.RawMessage `json:"input"`
+				} `json:"content"`
+			} `json:"message"`
+		}
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, err
+		}
+		var events []domain.NormalizedEvent
+		for _, c := range raw.Message.Content {
+			switch c.Type {
+			case "text":
+				events = append(events, canonical(EventMessage, MessagePayload{Role: "assistant", Text: c.Text}))
+			case "tool_use":
+				events = append(events, canonical(EventToolCall, ToolCallPayload{ID: c.ID, Name: c.Name, Input: c.Input}))
+			}
+		}
+		return events, nil
+
+	case "result":
+		var raw struct {
+			IsError      bool     `json:"is_error"`
+			Result       string   `json:"result"`
+			TotalCostUSD *float64 `json:"total_cost_usd"`
+			Usage        *struct {
+				InputTokens  int64 `json:"input_tokens"`
+				OutputTokens int64 `json:"output_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, err
+		}
+		var events []domain.NormalizedEvent
+		if raw.TotalCostUSD != nil || raw.Usage != nil {
+			// Claude reports the session's totals with each result.
+			cost := CostPayload{Cumulative: true}
+			if raw.TotalCostUSD != nil {
+				cost.AmountUSD = *raw.TotalCostUSD
+			}
+			if raw.Usage != nil {
+				cost.InputTokens = raw.Usage.InputTokens
+				cost.OutputTokens = raw.Usage.OutputTokens
+			}
+			events = append(events, canonical(EventCost, cost))
+		}
+		if raw.IsError {
+			events = append(events, canonical(EventError, ErrorPayload{Message: raw.Result}))
+		} else {
+			events = append(events, canonical(EventResult, ResultPayload{Text: raw.Result}))
+		}
+		return events, nil
+	}
+
+	return []domain.NormalizedEvent{passthrough(typ, line)}, nil
+}
+
+// codexAdapter translates Codex CLI `exec --json` output.
+type codexAdapter struct{}
+
+func (codexAdapter) Translate(line []byte) ([]domain.NormalizedEvent, error) {
+	typ, err := lineType(line)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case "item.completed":
+		var raw struct {
+			Item struct {
+				ID      string          `json:"id"`
+				Type    string          `json:"type"`
+				Text    string          `json:"text"`
+				Command string          `json:"command"`
+				Tool    string          `json:"tool"`
+				Args    json.RawMessage `json:"arguments"`
+			} `json:"item"`
+		}
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, err
+		}
+		switch raw.Item.Type {
+		case "agent_message":
+			return []domain.NormalizedEvent{canonical(EventMessage, MessagePayload{Role: "assistant", Text: raw.Item.Text})}, nil
+		case "command_execution":
+			input, _ := json.Marshal(map[string]string{"command": raw.Item.Command})
+			return []domain.NormalizedEvent{canonical(EventToolCall, ToolCallPayload{ID: raw.Item.ID, Name: "shell", Input: input})}, nil
+		case "mcp_tool_call":
+			return []domain.NormalizedEvent{canonical(EventToolCall, ToolCallPayload{ID: raw.Item.ID, Name: raw.Item.Tool, Input: raw.Item.Args})}, nil
+		}
+
+	case "turn.completed":
+		var raw struct {
+			Usage struct {
+				InputTokens  int64 `json:"input_tokens"`
+				OutputTokens int64 `json:"output_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, err
+		}
+		return []domain.NormalizedEvent{canonical(EventCost, CostPayload{
+			InputTokens:  raw.Usage.InputTokens,
+			OutputTokens: raw.Usage.OutputTokens,
+		})}, nil
+
+	case "turn.failed", "error":
+		var raw struct {
+			Message string `json:"message"`
+			Error   struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, err
+		}
+		msg := raw.Message
+		if msg == "" {
+			msg = raw.Error.Message
+		}
+		return []domain.NormalizedEvent{canonical(EventError, ErrorPayload{Message: msg})}, nil
+	}
+
+	return []domain.NormalizedEvent{passthrough(typ, line)}, nil
+}
+
+// geminiAdapter translates Gemini CLI stream-json output.
+type geminiAdapter struct{}
+
+func (geminiAdapter) Translate(line []byte) ([]domain.NormalizedEvent, error) {
+	typ, err := lineType(line)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case "message":
+		var raw struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		}
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, err
+		}
+		return []domain.NormalizedEvent{canonical(EventMessage, MessagePayload{Role: raw.Role, Text: raw.Content})}, nil
+
+	case "tool_use":
+		var raw struct {
+			ToolID     string          `json:"tool_id"`
+			ToolName   string          `json:"tool_name"`
+			Parameters json.RawMessage `json:"parameters"`
+		}
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, err
+		}
+		return []domain.NormalizedEvent{canonical(EventToolCall, ToolCallPayload{ID: raw.ToolID, Name: raw.ToolName, Input: raw.Parameters})}, nil
+
+	case "result":
+		var raw struct {
+			Status string `json:"status"`
+			Error  struct {
+				Message string `json:"message"`
+			} `json:"error"`
+			Stats *struct {
+				InputTokens  int64 `json:"input_tokens"`
+				OutputTokens int64 `json:"output_tokens"`
+			} `json:"stats"`
+		}
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, err
+		}
+		var events []domain.NormalizedEvent
+		if raw.Stats != nil {
+			events = append(events, canonical(EventCost, CostPayload{
+				InputTokens:  raw.Stats.InputTokens,
+				OutputTokens: raw.Stats.OutputTokens,
+				Cumulative:   true,
+			}))
+		}
+		if raw.Status == "error" {
+			events = append(events, canonical(EventError, ErrorPayload{Message: raw.Error.Message}))
+		} else {
+			events = append(events, canonical(EventResult, ResultPayload{Text: raw.Status}))
+		}
+		return events, nil
+
+	case "error":
+		var raw struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, err
+		}
+		return []domain.NormalizedEvent{canonical(EventError, ErrorPayload{Message: raw.Message})}, nil
+	}
+
+	return []domain.NormalizedEvent{passthrough(typ, line)}, nil
+}
+
+// lineType extracts the required "type" field from a JSON line.
+func lineType(line []byte) (string, error) {
+	var raw struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return "", err
+	}
+	if raw.Type == "" {
+		return "", fmt.Errorf("event has no type field")
+	}
+	return raw.Type, nil
+}
+
+// passthrough wraps a native line without translation. The payload is copied
+// so callers may reuse the scanner buffer.
+func passthrough(typ string, line []byte) domain.NormalizedEvent {
+	return domain.NormalizedEvent{Type: typ, Payload: append([]byte(nil), line...)}
+}
+
+// canonical builds an event with a marshalled canonical payload.
+func canonical(typ string, payload interface{}) domain.NormalizedEvent {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		data = []byte("{}")
+	}
+	return domain.NormalizedEvent{Type: typ, Payload: data}
+}
@@ -0,0 +1,160 @@
+package mcp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func eventTypes(events []domain.NormalizedEvent) []string {
+	types := make([]string, len(events))
+	for i, ev := range events {
+		types[i] = ev.Type
+	}
+	return types
+}
+
+func TestAdapterFor_UnknownFallsBackToPassthrough(t *testing.T) {
+	a := AdapterFor(domain.Provider("custom"))
+	events, err := a.Translate([]byte(`{"type":"anything","x":1}`))
+	if err != nil {
+		t.Fatalf("Translate: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "anything" {
+		t.Fatalf("events = %v, want single passthrough", eventTypes(events))
+	}
+}
+
+func TestValidAdapter(t *testing.T) {
+	for _, name := range []string{"claude", "codex", "gemini", "mock", PassthroughAdapter} {
+		if !ValidAdapter(name) {
+			t.Errorf("ValidAdapter(%q) = false", name)
+		}
+	}
+	if ValidAdapter("claud") {
+		t.Error("ValidAdapter accepted an unknown name")
+	}
+}
+
+func TestProviderSpec_AdapterName(t *testing.T) {
+	spec := ProviderSpec{Name: "my-claude", Adapter: "claude"}
+	if got := spec.AdapterName(); got != domain.ProviderClaude {
+		t.Errorf("AdapterName = %q, want claude", got)
+	}
+	spec = ProviderSpec{Name: domain.ProviderGemini}
+	if got := spec.AdapterName(); got != domain.ProviderGemini {
+		t.Errorf("AdapterName = %q, want gemini", got)
+	}
+}
+
+func TestClaudeAdapter_AssistantMessage(t *testing.T) {
+	line := `{"type":"assistant","message":{"content":[{"type":"text","text":"hi"},{"type":"tool_use","id":"tu1","name":"Edit","input":{"file":"a.go"}}]}}`
+	events, err := AdapterFor(domain.ProviderClaude).Translate([]byte(line))
+	if err != nil {
+		t.Fatalf("Translate: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != EventMessage || events[1].Type != EventToolCall {
+		t.Fatalf("types = %v, want [message tool_call]", eventTypes(events))
+	}
+
+	var tc ToolCallPayload
+	if err := json.Unmarshal(events[1].Payload, &tc); err != nil {
+		t.Fatalf("unmarshal tool call: %v", err)
+	}
+	if tc.Name != "Edit" || tc.ID != "tu1" {
+		t.Errorf("tool call = %+v, want Edit/tu1", tc)
+	}
+}
+
+func TestClaudeAdapter_ResultWithCost(t *testing.T) {
+	line := `{"type":"result","result":"done","total_cost_usd":0.25,"usage":{"input_tokens":100,"output_tokens":50}}`
+	events, err := AdapterFor(domain.ProviderClaude).Translate([]byte(line))
+	if err != nil {
+		t.Fatalf("Translate: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != EventCost || events[1].Type != EventResult {
+		t.Fatalf("types = %v, want [cost result]", eventTypes(events))
+	}
+
+	var delta domain.CostDelta
+	if err := json.Unmarshal(events[0].Payload, &delta); err != nil {
+		t.Fatalf("unmarshal cost: %v", err)
+	}
+	if delta.AmountUSD != 0.25 || delta.InputTokens != 100 || delta.OutputTokens != 50 {
+		t.Errorf("delta = %+v", delta)
+	}
+}
+
+func TestClaudeAdapter_ResultError(t *testing.T) {
+	events, err := AdapterFor(domain.ProviderClaude).Translate([]byte(`{"type":"result","is_error":true,"result":"boom"}`))
+	if err != nil {
+		t.Fatalf("Translate: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != EventError {
+		t.Fatalf("types = %v, want [error]", eventTypes(events))
+	}
+}
+
+func TestCodexAdapter(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"agent_message", `{"type":"item.completed","item":{"type":"agent_message","text":"ok"}}`, EventMessage},
+		{"command", `{"type":"item.completed","item":{"id":"i1","type":"command_execution","command":"go test"}}`, EventToolCall},
+		{"usage", `{"type":"turn.completed","usage":{"input_tokens":10,"output_tokens":5}}`, EventCost},
+		{"failed", `{"type":"turn.failed","error":{"message":"x"}}`, EventError},
+		{"unknown", `{"type":"thread.started"}`, "thread.started"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := AdapterFor(domain.ProviderCodex).Translate([]byte(tt.line))
+			if err != nil {
+				t.Fatalf("Translate: %v", err)
+			}
+			if len(events) != 1 || events[0].Type != tt.want {
+				t.Fatalf("types = %v, want [%s]", eventTypes(events), tt.want)
+			}
+		})
+	}
+}
+
+func TestGeminiAdapter(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"message", `{"type":"message","role":"assistant","content":"hi"}`, []string{EventMessage}},
+		{"tool_use", `{"type":"tool_use","tool_name":"read_file","tool_id":"t1","parameters":{}}`, []string{EventToolCall}},
+		{"result", `{"type":"result","status":"success","stats":{"input_tokens":3,"output_tokens":4}}`, []string{EventCost, EventResult}},
+		{"error", `{"type":"error","message":"quota"}`, []string{EventError}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := AdapterFor(domain.ProviderGemini).Translate([]byte(tt.line))
+			if err != nil {
+				t.Fatalf("Translate: %v", err)
+			}
+			got := eventTypes(events)
+			if len(got) != len(tt.want) {
+				t.Fatalf("types = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("types[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAdapters_RejectMissingType(t *testing.T) {
+	for _, p := range []domain.Provider{domain.ProviderClaude, domain.ProviderCodex, domain.ProviderGemini, "other"} {
+		if _, err := AdapterFor(p).Translate([]byte(`{"data":1}`)); err == nil {
+			t.Errorf("%s: expected error for missing type", p)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// costTracker turns one session's cost events into deltas. Providers that
+// report cumulative totals get the difference from their previous report,
+// and reports repeating a key or a total are dropped, so a provider that
+// re-emits a cost line does not have its spend counted twice.
+type costTracker struct {
+	total CostPayload
+	seen  map[string]bool
+}
+
+// track returns ev as a delta, or false when it repeats an earlier report.
+// Events other than costs pass through unchanged.
+func (t *costTracker) track(ev domain.NormalizedEvent) (domain.NormalizedEvent, bool) {
+	if ev.Type != EventCost {
+		return ev, true
+	}
+	var cost CostPayload
+	if err := json.Unmarshal(ev.Payload, &cost); err != nil {
+		return ev, true
+	}
+	if cost.Key != "" {
+		if t.seen[cost.Key] {
+			return ev, false
+		}
+		if t.seen == nil {
+			t.seen = make(map[string]bool)
+		}
+		t.seen[cost.Key] = true
+	}
+	if !cost.Cumulative {
+		return ev, true
+	}
+
+	delta := CostPayload{
+		InputTokens:  nonNegative(cost.InputTokens - t.total.InputTokens),
+		OutputTokens: nonNegative(cost.OutputTokens - t.total.OutputTokens),
+		AmountUSD:    cost.AmountUSD - t.total.AmountUSD,
+		Key:          cost.Key,
+	}
+	if delta.AmountUSD < 0 {
+		delta.AmountUSD = 0
+	}
+	t.total = CostPayload{InputTokens: cost.InputTokens, OutputTokens: cost.OutputTokens, AmountUSD: cost.AmountUSD}
+	if delta.InputTokens == 0 && delta.OutputTokens == 0 && delta.AmountUSD == 0 {
+		return ev, false
+	}
+	if delta.Key == "" {
+		// The totals identify the report, so a replay of it is recognised
+		// downstream too.
+		delta.Key = fmt.Sprintf("total-%d-%d-%.6f", cost.InputTokens, cost.OutputTokens, cost.AmountUSD)
+	}
+	payload, err := json.Marshal(delta)
+	if err != nil {
+		return ev, true
+	}
+	ev.Payload = payload
+	return ev, true
+}
+
+func nonNegative(n int64) int64 {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
@@ -3,12 +3,20 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/anthropics/three-body-engine/internal/chaos"
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/secrets"
 )
 
 // ---------------------------------------------------------------------------
@@ -90,6 +98,50 @@ func TestProviderRegistry_List(t *testing.T) {
 	}
 }
 
+func TestProviderRegistry_Replace(t *testing.T) {
+	reg := NewProviderRegistry()
+	if err := reg.Register(ProviderSpec{Name: domain.ProviderClaude, Command: "echo"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	reg.Replace([]ProviderSpec{{Name: domain.ProviderCodex, Command: "codex"}})
+
+	if _, err := reg.Get(domain.ProviderClaude); err != domain.ErrProviderUnavailable {
+		t.Errorf("Get(claude) err = %v, want ErrProviderUnavailable", err)
+	}
+	spec, err := reg.Get(domain.ProviderCodex)
+	if err != nil {
+		t.Fatalf("Get(codex): %v", err)
+	}
+	if spec.Command != "codex" {
+		t.Errorf("Command = %q, want codex", spec.Command)
+	}
+}
+
+func TestProviderRegistry_GetFor(t *testing.T) {
+	reg := NewProviderRegistry()
+	reg.Replace([]ProviderSpec{
+		{Name: domain.ProviderClaude, Command: "claude"},
+		{Name: domain.ProviderCodex, Command: "codex"},
+	})
+	reg.SetNamespace("team-a", []ProviderSpec{{Name: domain.ProviderClaude, Command: "claude-team-a"}})
+
+	if spec, _ := reg.GetFor("team-a", domain.ProviderClaude); spec.Command != "claude-team-a" {
+		t.Errorf("team-a claude = %q, want the override", spec.Command)
+	}
+	if spec, _ := reg.GetFor("team-a", domain.ProviderCodex); spec.Command != "codex" {
+		t.Errorf("team-a codex = %q, want the global spec", spec.Command)
+	}
+	if spec, _ := reg.GetFor("team-b", domain.ProviderClaude); spec.Command != "claude" {
+		t.Errorf("team-b claude = %q, want the global spec", spec.Command)
+	}
+
+	reg.Replace([]ProviderSpec{{Name: domain.ProviderCodex, Command: "codex"}})
+	if spec, _ := reg.GetFor("team-a", domain.ProviderClaude); spec.Command != "claude-team-a" {
+		t.Errorf("override lost on Replace: %q", spec.Command)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // SessionManager tests
 // ---------------------------------------------------------------------------
@@ -205,6 +257,195 @@ func TestSessionManager_StopAll(t *testing.T) {
 	}
 }
 
+func TestSessionManager_Shutdown(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh and signals")
+	}
+	reg := NewProviderRegistry()
+	// One provider exits on interrupt with a last result, one ignores it.
+	for name, script := range map[domain.Provider]string{
+		domain.ProviderClaude: `trap 'echo "{\"type\":\"result\",\"data\":\"bye\"}"; exit 0' INT; while :; do sleep 0.05; done`,
+		domain.ProviderCodex:  `trap '' INT; while :; do sleep 0.05; done`,
+	} {
+		if err := reg.Register(ProviderSpec{Name: name, Command: "sh", Args: []string{"-c", script}}); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	mgr := NewSessionManager(reg)
+	ctx := context.Background()
+	polite, err := mgr.Create(ctx, domain.ProviderClaude, domain.SessionConfig{Workspace: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	stubborn, err := mgr.Create(ctx, domain.ProviderCodex, domain.SessionConfig{Workspace: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	politeSess, _ := mgr.Get(polite)
+	stubbornSess, _ := mgr.Get(stubborn)
+	if got := len(mgr.List()); got != 2 {
+		t.Fatalf("List = %d sessions, want 2", got)
+	}
+	time.Sleep(100 * time.Millisecond) // let the traps install
+
+	var results []domain.NormalizedEvent
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for ev := range politeSess.Events() {
+			results = append(results, ev)
+		}
+	}()
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	mgr.Shutdown(shutdownCtx)
+	<-collected
+
+	if len(results) != 1 || results[0].Type != "result" {
+		t.Errorf("polite session events = %+v, want its last result", results)
+	}
+	select {
+	case <-stubbornSess.Done():
+	case <-time.After(2 * time.Second):
+		t.Error("stubborn session still running after Shutdown")
+	}
+	if got := len(mgr.List()); got != 0 {
+		t.Errorf("List after Shutdown = %d sessions, want 0", got)
+	}
+}
+
+func TestProviderRegistry_Validate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh scripts")
+	}
+	dir := t.TempDir()
+	script := func(name, body string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+			t.Fatalf("write script: %v", err)
+		}
+		return p
+	}
+	good := script("agent", `echo "agent 1.2.3"; echo built today`)
+	broken := script("broken", `echo "cannot load config" >&2; exit 3`)
+
+	reg := NewProviderRegistry()
+	reg.Replace([]ProviderSpec{
+		{Name: domain.ProviderClaude, Command: good},
+		{Name: domain.ProviderCodex, Command: "claud-typo-not-installed"},
+	})
+	reg.SetNamespace("team-a", []ProviderSpec{{Name: domain.ProviderClaude, Command: broken}})
+
+	if st := reg.Statuses(); len(st) != 3 || st[0].CheckedAt != 0 {
+		t.Fatalf("Statuses before Validate = %+v, want three unchecked", st)
+	}
+	results := reg.Validate(context.Background(), ProviderCheck{SmokeArgs: []string{"--version"}, Timeout: 5 * time.Second})
+	if len(results) != 3 {
+		t.Fatalf("Validate = %+v, want three results", results)
+	}
+	claude, codex, teamA := results[0], results[1], results[2]
+	if !claude.OK || claude.Version != "agent 1.2.3" || claude.Path != good {
+		t.Errorf("claude = %+v, want ok with its version", claude)
+	}
+	if codex.OK || !strings.Contains(codex.Error, "not found") {
+		t.Errorf("codex = %+v, want command not found", codex)
+	}
+	if teamA.OK || teamA.Namespace != "team-a" || !strings.Contains(teamA.Error, "cannot load config") {
+		t.Errorf("team-a claude = %+v, want the smoke test's failure", teamA)
+	}
+	if st := reg.Statuses(); !reflect.DeepEqual(st, results) {
+		t.Errorf("Statuses = %+v, want the validation results", st)
+	}
+
+	// Reloading keeps the results of unchanged specs only.
+	reg.Replace([]ProviderSpec{
+		{Name: domain.ProviderClaude, Command: good},
+		{Name: domain.ProviderCodex, Command: good},
+	})
+	st := reg.Statuses()
+	if !st[0].OK || st[1].CheckedAt != 0 || st[1].Command != good {
+		t.Errorf("Statuses after reload = %+v", st)
+	}
+
+	// Resolving alone does not run the command.
+	if st := CheckSpec(context.Background(), ProviderSpec{Name: domain.ProviderClaude, Command: broken}, ProviderCheck{}); !st.OK || st.Version != "" {
+		t.Errorf("CheckSpec without smoke args = %+v, want ok", st)
+	}
+}
+
+func TestProviderRegistry_Runtime(t *testing.T) {
+	reg := NewProviderRegistry()
+	reg.Replace([]ProviderSpec{{Name: domain.ProviderClaude, Command: "claude"}})
+	reg.SetNamespace("team-a", []ProviderSpec{{Name: domain.ProviderClaude, Command: "claude-team"}})
+
+	reg.SetRuntime(ProviderSpec{Name: domain.ProviderClaude, Command: "claude-next"}, ProviderStatus{OK: true, CheckedAt: 1})
+	reg.SetRuntime(ProviderSpec{Name: "aider", Command: "aider"}, ProviderStatus{})
+	if spec, _ := reg.Get(domain.ProviderClaude); spec.Command != "claude-next" {
+		t.Errorf("Get claude = %+v, want the runtime spec", spec)
+	}
+	if spec, _ := reg.GetFor("team-a", domain.ProviderClaude); spec.Command != "claude-team" {
+		t.Errorf("GetFor team-a = %+v, want the namespace override", spec)
+	}
+	if names := reg.List(); len(names) != 2 {
+		t.Errorf("List = %v, want claude and aider", names)
+	}
+	st := reg.Statuses()
+	if len(st) != 3 || st[0].Name != "aider" || !st[0].Runtime || !st[1].Runtime || !st[1].OK || st[2].Runtime {
+		t.Errorf("Statuses = %+v, want runtime aider and claude, then team-a's override", st)
+	}
+
+	// Reloading the config keeps runtime specs.
+	reg.Replace([]ProviderSpec{{Name: domain.ProviderClaude, Command: "claude"}})
+	if spec, err := reg.Get("aider"); err != nil || spec.Command != "aider" {
+		t.Errorf("Get aider after reload = %+v, %v", spec, err)
+	}
+
+	if !reg.RemoveRuntime(domain.ProviderClaude) || reg.RemoveRuntime(domain.ProviderClaude) {
+		t.Error("RemoveRuntime should report only the first removal")
+	}
+	if spec, _ := reg.Get(domain.ProviderClaude); spec.Command != "claude" {
+		t.Errorf("Get claude after removal = %+v, want the configured spec", spec)
+	}
+}
+
+func TestCostTracker(t *testing.T) {
+	var tr costTracker
+	cost := func(p CostPayload) domain.NormalizedEvent {
+		data, _ := json.Marshal(p)
+		return domain.NormalizedEvent{Type: EventCost, Payload: data}
+	}
+	track := func(p CostPayload) (CostPayload, bool) {
+		ev, ok := tr.track(cost(p))
+		var out CostPayload
+		json.Unmarshal(ev.Payload, &out)
+		return out, ok
+	}
+
+	if got, ok := track(CostPayload{InputTokens: 100, AmountUSD: 0.5, Cumulative: true}); !ok || got.AmountUSD != 0.5 || got.Cumulative || got.Key == "" {
+		t.Errorf("first total = %+v, %v, want it as a keyed delta", got, ok)
+	}
+	if got, ok := track(CostPayload{InputTokens: 100, AmountUSD: 0.5, Cumulative: true}); ok {
+		t.Errorf("repeated total = %+v, want it dropped", got)
+	}
+	if got, ok := track(CostPayload{InputTokens: 250, OutputTokens: 10, AmountUSD: 1.25, Cumulative: true}); !ok || got.InputTokens != 150 || got.OutputTokens != 10 || got.AmountUSD != 0.75 {
+		t.Errorf("next total = %+v, %v, want the difference", got, ok)
+	}
+
+	if got, ok := track(CostPayload{AmountUSD: 0.1, Key: "msg-1"}); !ok || got.AmountUSD != 0.1 {
+		t.Errorf("keyed delta = %+v, %v", got, ok)
+	}
+	if _, ok := track(CostPayload{AmountUSD: 0.1, Key: "msg-1"}); ok {
+		t.Error("repeated key was not dropped")
+	}
+	if _, ok := track(CostPayload{AmountUSD: 0.1}); !ok {
+		t.Error("unkeyed delta was dropped")
+	}
+	if _, ok := tr.track(domain.NormalizedEvent{Type: EventMessage}); !ok {
+		t.Error("message was dropped")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Session unit tests
 // ---------------------------------------------------------------------------
@@ -225,7 +466,7 @@ func TestParseEvent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ev, err := parseEvent([]byte(tt.input), domain.ProviderClaude, "ses-test")
+			events, err := parseEvents([]byte(tt.input), AdapterFor(domain.ProviderClaude), domain.ProviderClaude, "ses-test")
 			if tt.wantErr {
 				if err == nil {
 					t.Error("expected error, got nil")
@@ -235,6 +476,10 @@ func TestParseEvent(t *testing.T) {
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
+			if len(events) != 1 {
+				t.Fatalf("len(events) = %d, want 1", len(events))
+			}
+			ev := events[0]
 			if ev.Type != tt.wantTyp {
 				t.Errorf("Type = %q, want %q", ev.Type, tt.wantTyp)
 			}
@@ -304,10 +549,11 @@ func TestSession_StopTerminatesProcess(t *testing.T) {
 func TestParseEvent_PayloadCopy(t *testing.T) {
 	// Verify that the returned Payload is an independent copy.
 	raw := []byte(`{"type":"test"}`)
-	ev, err := parseEvent(raw, domain.ProviderClaude, "ses-1")
+	events, err := parseEvents(raw, AdapterFor(domain.ProviderClaude), domain.ProviderClaude, "ses-1")
 	if err != nil {
-		t.Fatalf("parseEvent: %v", err)
+		t.Fatalf("parseEvents: %v", err)
 	}
+	ev := events[0]
 
 	// Mutate original.
 	raw[0] = 'X'
@@ -319,3 +565,219 @@ func TestParseEvent_PayloadCopy(t *testing.T) {
 		t.Error("payload was not an independent copy")
 	}
 }
+
+func TestSessionManager_ResolvesSecrets(t *testing.T) {
+	reg := NewProviderRegistry()
+	cmd, args := echoCommand()
+	if err := reg.Register(ProviderSpec{
+		Name:    domain.ProviderClaude,
+		Command: cmd,
+		Args:    args,
+		Env:     map[string]string{"API_KEY": "${test:claude}", "PLAIN": "value"},
+	}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	mgr := NewSessionManager(reg)
+	defer mgr.StopAll()
+	mgr.Secrets = secrets.NewResolver()
+	mgr.Secrets.Register("test", secrets.BackendFunc(func(_ context.Context, key string) (string, error) {
+		if key != "claude" {
+			return "", fmt.Errorf("no secret %s", key)
+		}
+		return "sk-resolved", nil
+	}))
+
+	ctx := context.Background()
+	id, err := mgr.Create(ctx, domain.ProviderClaude, domain.SessionConfig{Workspace: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	sess, _ := mgr.Get(id)
+	env := strings.Join(sess.cmd.Env, "\n")
+	if !strings.Contains(env, "API_KEY=sk-resolved") || !strings.Contains(env, "PLAIN=value") {
+		t.Errorf("env = %q, want the resolved secret and plain value", env)
+	}
+
+	_, err = mgr.Create(ctx, domain.ProviderClaude, domain.SessionConfig{Env: map[string]string{"OTHER": "${test:missing}"}})
+	if err == nil || !strings.Contains(err.Error(), "${test:missing}") {
+		t.Errorf("err = %v, want unresolved reference error", err)
+	}
+}
+
+func TestSessionManager_ExpandsEnvTemplates(t *testing.T) {
+	reg := NewProviderRegistry()
+	cmd, args := echoCommand()
+	if err := reg.Register(ProviderSpec{
+		Name:    domain.ProviderClaude,
+		Command: cmd,
+		Args:    args,
+		Env:     map[string]string{"TASK": "{{task_id}}@{{ phase }}", "KEY": "${test:key}"},
+	}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	mgr := NewSessionManager(reg)
+	defer mgr.StopAll()
+	mgr.Secrets = secrets.NewResolver()
+	mgr.Secrets.Register("test", secrets.BackendFunc(func(_ context.Context, key string) (string, error) {
+		return "{{task_id}}", nil
+	}))
+
+	ctx := context.Background()
+	ws := t.TempDir()
+	id, err := mgr.Create(ctx, domain.ProviderClaude, domain.SessionConfig{
+		TaskID:             "t1",
+		Phase:              domain.PhaseC,
+		Workspace:          ws,
+		ContextFile:        "/tmp/digest.json",
+		BudgetRemainingUSD: 7.5,
+		Env:                map[string]string{"CTX": "{{workspace}} {{digest_path}} {{budget_remaining}}"},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	sess, _ := mgr.Get(id)
+	env := strings.Join(sess.cmd.Env, "\n")
+	for _, want := range []string{"TASK=t1@C", "KEY={{task_id}}", "CTX=" + ws + " /tmp/digest.json 7.50"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("env = %q, want %s", env, want)
+		}
+	}
+
+	_, err = mgr.Create(ctx, domain.ProviderClaude, domain.SessionConfig{Env: map[string]string{"X": "{{task}}"}})
+	if err == nil || !strings.Contains(err.Error(), `unknown template variable "task"`) {
+		t.Errorf("err = %v, want unknown variable error", err)
+	}
+}
+
+func TestSessionManager_ArgTemplatesAndDir(t *testing.T) {
+	reg := NewProviderRegistry()
+	cmd, args := echoCommand()
+	if err := reg.Register(ProviderSpec{
+		Name:    domain.ProviderClaude,
+		Command: cmd,
+		Args:    append(args, "--context", "{{context_file}}", "--task={{task_id}}", "{{role}}", "{{api_url}}"),
+	}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	mgr := NewSessionManager(reg)
+	defer mgr.StopAll()
+	mgr.SetAPIURL("http://localhost:9800")
+
+	ctx := context.Background()
+	ws := t.TempDir()
+	id, err := mgr.Create(ctx, domain.ProviderClaude, domain.SessionConfig{TaskID: "t1", Role: "coder", Workspace: ws, ContextFile: "digest.json"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	sess, _ := mgr.Get(id)
+	got := sess.cmd.Args[len(sess.cmd.Args)-5:]
+	if !reflect.DeepEqual(got, []string{"--context", "digest.json", "--task=t1", "coder", "http://localhost:9800"}) {
+		t.Errorf("args = %q, want the expanded templates", got)
+	}
+	if sess.cmd.Dir != ws {
+		t.Errorf("dir = %q, want the workspace %q", sess.cmd.Dir, ws)
+	}
+
+	reg.Replace([]ProviderSpec{{Name: domain.ProviderClaude, Command: cmd, Args: []string{"{{nope}}"}}})
+	if _, err := mgr.Create(ctx, domain.ProviderClaude, domain.SessionConfig{}); err == nil || !strings.Contains(err.Error(), "args") {
+		t.Errorf("err = %v, want an args template error", err)
+	}
+}
+
+func TestSessionManager_DeadLetters(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	reg := NewProviderRegistry()
+	if err := reg.Register(ProviderSpec{
+		Name:    domain.ProviderClaude,
+		Command: "sh",
+		Args:    []string{"-c", `echo 'not json'; echo '{"type":"result","data":"ok"}'`},
+	}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	mgr := NewSessionManager(reg)
+	defer mgr.StopAll()
+	var mu sync.Mutex
+	var letters []domain.DeadLetterEvent
+	mgr.OnDeadLetter = func(d domain.DeadLetterEvent) {
+		mu.Lock()
+		letters = append(letters, d)
+		mu.Unlock()
+	}
+
+	id, err := mgr.Create(context.Background(), domain.ProviderClaude, domain.SessionConfig{TaskID: "t1", WorkerID: "w1"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	sess, _ := mgr.Get(id)
+	var parsed int
+	for range sess.Events() {
+		parsed++
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if parsed != 1 || len(letters) != 1 || mgr.DeadLetters() != 1 {
+		t.Fatalf("parsed %d, dead letters %+v (count %d), want one of each", parsed, letters, mgr.DeadLetters())
+	}
+	d := letters[0]
+	if d.Raw != "not json" || d.SessionID != id || d.TaskID != "t1" || d.WorkerID != "w1" || d.Adapter != domain.ProviderClaude || d.Error == "" {
+		t.Errorf("dead letter = %+v", d)
+	}
+	if _, err := ParseLine([]byte(d.Raw), d.Adapter, d.Provider, d.SessionID); err == nil {
+		t.Error("ParseLine accepted the dead letter")
+	}
+}
+
+func TestSessionManager_Chaos(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	reg := NewProviderRegistry()
+	if err := reg.Register(ProviderSpec{
+		Name:    domain.ProviderClaude,
+		Command: "sh",
+		Args:    []string{"-c", `echo '{"type":"result","data":"ok"}'; sleep 30`},
+	}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	run := func(cfg chaos.Config) (events int, mgr *SessionManager) {
+		mgr = NewSessionManager(reg)
+		mgr.OnDeadLetter = func(domain.DeadLetterEvent) {}
+		mgr.Chaos = chaos.New(cfg)
+		t.Cleanup(mgr.StopAll)
+		id, err := mgr.Create(context.Background(), domain.ProviderClaude, domain.SessionConfig{TaskID: "t1"})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		sess, _ := mgr.Get(id)
+		for {
+			select {
+			case _, ok := <-sess.Events():
+				if !ok {
+					return events, mgr
+				}
+				events++
+			case <-time.After(time.Second):
+				return events, mgr
+			}
+		}
+	}
+
+	// A killed session ends at its first line, long before its process would.
+	start := time.Now()
+	events, mgr := run(chaos.Config{KillSessionRate: 1})
+	if events != 0 || time.Since(start) > 10*time.Second {
+		t.Errorf("killed session emitted %d events after %s", events, time.Since(start))
+	}
+	if mgr.Chaos.Stats().SessionsKilled != 1 {
+		t.Errorf("Stats = %+v, want one session killed", mgr.Chaos.Stats())
+	}
+
+	// A malformed line becomes a dead letter instead of an event.
+	events, mgr = run(chaos.Config{MalformedEventRate: 1})
+	if events != 0 || mgr.DeadLetters() != 1 {
+		t.Errorf("malformed output: %d events, %d dead letters, want 0 and 1", events, mgr.DeadLetters())
+	}
+}
@@ -2,6 +2,7 @@
 package mcp
 
 import (
+	"reflect"
 	"sort"
 	"sync"
 
@@ -14,18 +15,39 @@ type ProviderSpec struct {
 	Command string
 	Args    []string
 	Env     map[string]string
+	// Adapter selects the output translator by provider name. Empty means
+	// the adapter matching Name is used.
+	Adapter string
+}
+
+// AdapterName returns the provider name whose adapter translates this spec's output.
+func (s ProviderSpec) AdapterName() domain.Provider {
+	if s.Adapter != "" {
+		return domain.Provider(s.Adapter)
+	}
+	return s.Name
 }
 
 // ProviderRegistry is a thread-safe registry of provider specifications.
+// A namespace may override the specs of some providers for its sessions.
+// Specs registered at runtime take precedence over configured ones of the
+// same name and outlive Replace.
 type ProviderRegistry struct {
-	mu        sync.RWMutex
-	providers map[domain.Provider]ProviderSpec
+	mu         sync.RWMutex
+	providers  map[domain.Provider]ProviderSpec
+	runtime    map[domain.Provider]ProviderSpec
+	namespaces map[string]map[domain.Provider]ProviderSpec
+	// checks holds the latest validation of each spec, by checkKey.
+	checks map[string]ProviderStatus
 }
 
 // NewProviderRegistry creates an empty registry.
 func NewProviderRegistry() *ProviderRegistry {
 	return &ProviderRegistry{
-		providers: make(map[domain.Provider]ProviderSpec),
+		providers:  make(map[domain.Provider]ProviderSpec),
+		runtime:    make(map[domain.Provider]ProviderSpec),
+		namespaces: make(map[string]map[domain.Provider]ProviderSpec),
+		checks:     make(map[string]ProviderStatus),
 	}
 }
 
@@ -43,14 +65,32 @@ func (r *ProviderRegistry) Register(spec ProviderSpec) error {
 		)
 	}
 	r.providers[spec.Name] = spec
+	delete(r.checks, checkKey("", spec.Name))
 	return nil
 }
 
+// Replace swaps the registered providers for specs in one step. Sessions that
+// are already running keep the spec they were started with. Validation
+// results are kept for the specs that did not change.
+func (r *ProviderRegistry) Replace(specs []ProviderSpec) {
+	providers := make(map[domain.Provider]ProviderSpec, len(specs))
+	for _, spec := range specs {
+		providers[spec.Name] = spec
+	}
+	r.mu.Lock()
+	r.forgetChanged("", r.global(), providers)
+	r.providers = providers
+	r.mu.Unlock()
+}
+
 // Get returns the spec for the named provider, or ErrProviderUnavailable if not found.
 func (r *ProviderRegistry) Get(name domain.Provider) (ProviderSpec, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	if spec, ok := r.runtime[name]; ok {
+		return spec, nil
+	}
 	spec, ok := r.providers[name]
 	if !ok {
 		return ProviderSpec{}, domain.ErrProviderUnavailable
@@ -58,13 +98,91 @@ func (r *ProviderRegistry) Get(name domain.Provider) (ProviderSpec, error) {
 	return spec, nil
 }
 
+// SetRuntime registers spec at runtime, replacing an earlier runtime spec
+// of the same name, and records check as its latest validation unless
+// check.CheckedAt is 0. Sessions that are already running keep the spec
+// they were started with.
+func (r *ProviderRegistry) SetRuntime(spec ProviderSpec, check ProviderStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runtime[spec.Name] = spec
+	if check.CheckedAt == 0 {
+		delete(r.checks, checkKey("", spec.Name))
+		return
+	}
+	check.Name, check.Namespace = spec.Name, ""
+	r.checks[checkKey("", spec.Name)] = check
+}
+
+// RemoveRuntime removes the runtime spec of name, so a configured spec of
+// the same name applies again. It reports whether there was one.
+func (r *ProviderRegistry) RemoveRuntime(name domain.Provider) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.runtime[name]; !ok {
+		return false
+	}
+	delete(r.runtime, name)
+	delete(r.checks, checkKey("", name))
+	return true
+}
+
+// global returns the specs that apply outside namespaces. The caller holds
+// r.mu.
+func (r *ProviderRegistry) global() map[domain.Provider]ProviderSpec {
+	specs := make(map[domain.Provider]ProviderSpec, len(r.providers)+len(r.runtime))
+	for name, spec := range r.providers {
+		specs[name] = spec
+	}
+	for name, spec := range r.runtime {
+		specs[name] = spec
+	}
+	return specs
+}
+
+// SetNamespace replaces the provider overrides of a namespace. Providers
+// without an override keep resolving to the global spec.
+func (r *ProviderRegistry) SetNamespace(namespace string, specs []ProviderSpec) {
+	overrides := make(map[domain.Provider]ProviderSpec, len(specs))
+	for _, spec := range specs {
+		overrides[spec.Name] = spec
+	}
+	r.mu.Lock()
+	r.forgetChanged(namespace, r.namespaces[namespace], overrides)
+	r.namespaces[namespace] = overrides
+	r.mu.Unlock()
+}
+
+// forgetChanged drops the validation results of the specs of namespace
+// that differ between old and updated. The caller holds r.mu.
+func (r *ProviderRegistry) forgetChanged(namespace string, old, updated map[domain.Provider]ProviderSpec) {
+	for name, spec := range updated {
+		if !reflect.DeepEqual(old[name], spec) {
+			delete(r.checks, checkKey(namespace, name))
+		}
+	}
+}
+
+// GetFor returns namespace's override of the named provider, or the global
+// spec as Get does when it has none.
+func (r *ProviderRegistry) GetFor(namespace string, name domain.Provider) (ProviderSpec, error) {
+	r.mu.RLock()
+	spec, ok := r.namespaces[namespace][name]
+	r.mu.RUnlock()
+	if ok {
+		return spec, nil
+	}
+	return r.Get(name)
+}
+
 // List returns all registered provider names in sorted order.
 func (r *ProviderRegistry) List() []domain.Provider {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	names := make([]domain.Provider, 0, len(r.providers))
-	for name := range r.providers {
+	global := r.global()
+	names := make([]domain.Provider, 0, len(global))
+	for name := range global {
 		names = append(names, name)
 	}
 	sort.Slice(names, func(i, j int) bool {
@@ -3,15 +3,18 @@ package mcp
 import (
 	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/anthropics/three-body-engine/internal/chaos"
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/secrets"
 )
 
 const eventChannelBuffer = 64
@@ -21,12 +24,22 @@ type Session struct {
 	ID        string
 	Provider  domain.Provider
 	Config    domain.SessionConfig
+	adapter   ProviderAdapter
 	cmd       *exec.Cmd
 	stdout    io.ReadCloser
 	events    chan domain.NormalizedEvent
 	done      chan struct{}
 	doneOnce  sync.Once
 	startedAt int64
+
+	// adapterName names adapter, so dead letters can be parsed again.
+	adapterName domain.Provider
+	// deadLetter, if set, receives each line adapter could not parse.
+	deadLetter func(line []byte, err error)
+	// costs turns the session's cost reports into deltas.
+	costs costTracker
+	// chaos, if set, may kill the session or malform its output.
+	chaos *chaos.Monkey
 }
 
 // Start launches the provider process and begins reading events from stdout.
@@ -54,6 +67,30 @@ func (s *Session) Stop() error {
 	return err
 }
 
+// Shutdown asks the provider process to exit with an interrupt and waits
+// for its output to end, killing it when ctx ends first or the platform
+// cannot deliver interrupts.
+func (s *Session) Shutdown(ctx context.Context) error {
+	if s.cmd.Process == nil {
+		return nil
+	}
+	if err := s.cmd.Process.Signal(os.Interrupt); err != nil {
+		if errors.Is(err, os.ErrProcessDone) {
+			_ = s.cmd.Wait()
+			s.markDone()
+			return nil
+		}
+		return s.Stop()
+	}
+	select {
+	case <-s.done:
+		_ = s.cmd.Wait()
+		return nil
+	case <-ctx.Done():
+		return s.Stop()
+	}
+}
+
 // Events returns a receive-only channel of normalized events from the provider.
 func (s *Session) Events() <-chan domain.NormalizedEvent {
 	return s.events
@@ -75,42 +112,71 @@ func (s *Session) readStdout() {
 	defer s.markDone()
 	defer close(s.events)
 
+	adapter := s.adapter
+	if adapter == nil {
+		adapter = AdapterFor(s.Provider)
+	}
+
 	scanner := bufio.NewScanner(s.stdout)
 	for scanner.Scan() {
-		line := scanner.Bytes()
-		ev, err := parseEvent(line, s.Provider, s.ID)
+		if s.chaos.KillSession() {
+			_ = s.Stop()
+			return
+		}
+		line := s.chaos.Malform(scanner.Bytes())
+		events, err := parseEvents(line, adapter, s.Provider, s.ID)
 		if err != nil {
+			if s.deadLetter != nil {
+				s.deadLetter(line, err)
+			}
 			continue
 		}
-		s.events <- ev
+		for _, ev := range events {
+			if ev, ok := s.costs.track(ev); ok {
+				s.events <- ev
+			}
+		}
 	}
 }
 
-// parseEvent converts a JSON line into a NormalizedEvent.
-func parseEvent(line []byte, provider domain.Provider, sessionID string) (domain.NormalizedEvent, error) {
-	var raw struct {
-		Type string `json:"type"`
-	}
-	if err := json.Unmarshal(line, &raw); err != nil {
-		return domain.NormalizedEvent{}, err
+// parseEvents converts a JSON line into canonical NormalizedEvents using the
+// provider's adapter, stamping each event with the provider and session ID.
+func parseEvents(line []byte, adapter ProviderAdapter, provider domain.Provider, sessionID string) ([]domain.NormalizedEvent, error) {
+	events, err := adapter.Translate(line)
+	if err != nil {
+		return nil, err
 	}
-	if raw.Type == "" {
-		return domain.NormalizedEvent{}, fmt.Errorf("event has no type field")
+	for i := range events {
+		events[i].Provider = provider
+		events[i].SessionID = sessionID
 	}
-	return domain.NormalizedEvent{
-		Type:      raw.Type,
-		Provider:  provider,
-		SessionID: sessionID,
-		Payload:   append([]byte(nil), line...),
-	}, nil
+	return events, nil
+}
+
+// ParseLine converts a line of session output into NormalizedEvents with
+// the named adapter, as the session's reader would have.
+func ParseLine(line []byte, adapter, provider domain.Provider, sessionID string) ([]domain.NormalizedEvent, error) {
+	return parseEvents(line, AdapterFor(adapter), provider, sessionID)
 }
 
 // SessionManager creates, tracks, and stops code agent sessions.
 type SessionManager struct {
+	// Secrets, if set, resolves secret references in provider and session
+	// env values at launch.
+	Secrets *secrets.Resolver
+	// OnDeadLetter, if set, receives every line of session output the
+	// provider's adapter could not parse, instead of the line being dropped.
+	// It is called from the session's reader.
+	OnDeadLetter func(domain.DeadLetterEvent)
+	// Chaos, if set, kills sessions and malforms their output at its rates.
+	Chaos *chaos.Monkey
+
 	registry *ProviderRegistry
 	mu       sync.RWMutex
 	sessions map[string]*Session
+	apiURL   string
 	seq      atomic.Int64
+	dead     atomic.Int64
 }
 
 // NewSessionManager creates a manager backed by the given provider registry.
@@ -121,21 +187,42 @@ func NewSessionManager(registry *ProviderRegistry) *SessionManager {
 	}
 }
 
-// Create starts a new code agent session for the given provider and config.
+// Create starts a new code agent session for the given provider and config,
+// using the provider spec of cfg's namespace.
 func (m *SessionManager) Create(ctx context.Context, provider domain.Provider, cfg domain.SessionConfig) (string, error) {
-	spec, err := m.registry.Get(provider)
+	spec, err := m.registry.GetFor(cfg.Namespace, provider)
 	if err != nil {
 		return "", err
 	}
 
 	id := fmt.Sprintf("ses-%s-%d-%d", provider, time.Now().UnixNano(), m.seq.Add(1))
-	cmd := exec.CommandContext(ctx, spec.Command, spec.Args...)
+	m.mu.RLock()
+	vars := templateVars(cfg, m.apiURL)
+	m.mu.RUnlock()
+	args := make([]string, len(spec.Args))
+	for i, arg := range spec.Args {
+		if args[i], err = expandTemplate(arg, vars); err != nil {
+			return "", fmt.Errorf("provider %s args: %w", provider, err)
+		}
+	}
+	cmd := exec.CommandContext(ctx, spec.Command, args...)
+	// The provider runs in the task's workspace.
+	cmd.Dir = cfg.Workspace
 
-	// Merge provider env with session-specific env.
-	for k, v := range spec.Env {
+	// Merge provider env with session-specific env, resolving secrets only
+	// into the child's environment and expanding template variables.
+	providerEnv, err := m.sessionEnv(ctx, spec.Env, vars)
+	if err != nil {
+		return "", fmt.Errorf("provider %s env: %w", provider, err)
+	}
+	sessionEnv, err := m.sessionEnv(ctx, cfg.Env, vars)
+	if err != nil {
+		return "", fmt.Errorf("session env: %w", err)
+	}
+	for k, v := range providerEnv {
 		cmd.Env = append(cmd.Env, k+"="+v)
 	}
-	for k, v := range cfg.Env {
+	for k, v := range sessionEnv {
 		cmd.Env = append(cmd.Env, k+"="+v)
 	}
 
@@ -145,14 +232,18 @@ func (m *SessionManager) Create(ctx context.Context, provider domain.Provider, c
 	}
 
 	sess := &Session{
-		ID:       id,
-		Provider: provider,
-		Config:   cfg,
-		cmd:      cmd,
-		stdout:   stdout,
-		events:   make(chan domain.NormalizedEvent, eventChannelBuffer),
-		done:     make(chan struct{}),
+		ID:          id,
+		Provider:    provider,
+		Config:      cfg,
+		adapter:     AdapterFor(spec.AdapterName()),
+		adapterName: spec.AdapterName(),
+		cmd:         cmd,
+		stdout:      stdout,
+		events:      make(chan domain.NormalizedEvent, eventChannelBuffer),
+		done:        make(chan struct{}),
+		chaos:       m.Chaos,
 	}
+	sess.deadLetter = func(line []byte, err error) { m.deadLetter(sess, line, err) }
 
 	if err := sess.Start(ctx); err != nil {
 		return "", err
@@ -165,6 +256,39 @@ func (m *SessionManager) Create(ctx context.Context, provider domain.Provider, c
 	return id, nil
 }
 
+// SetAPIURL sets the engine's API base URL offered to sessions started from
+// now on as {{api_url}}, once the engine knows where it listens.
+func (m *SessionManager) SetAPIURL(url string) {
+	m.mu.Lock()
+	m.apiURL = url
+	m.mu.Unlock()
+}
+
+// deadLetter counts a line sess could not parse and hands it to
+// OnDeadLetter.
+func (m *SessionManager) deadLetter(sess *Session, line []byte, err error) {
+	m.dead.Add(1)
+	if m.OnDeadLetter == nil {
+		return
+	}
+	m.OnDeadLetter(domain.DeadLetterEvent{
+		SessionID: sess.ID,
+		TaskID:    sess.Config.TaskID,
+		WorkerID:  sess.Config.WorkerID,
+		Provider:  sess.Provider,
+		Adapter:   sess.adapterName,
+		Raw:       string(line),
+		Error:     err.Error(),
+		CreatedAt: time.Now().Unix(),
+	})
+}
+
+// DeadLetters returns how many lines of session output could not be parsed
+// since the manager was created.
+func (m *SessionManager) DeadLetters() int64 {
+	return m.dead.Load()
+}
+
 // Get returns a session by ID, or ErrSessionNotFound.
 func (m *SessionManager) Get(sessionID string) (*Session, error) {
 	m.mu.RLock()
@@ -191,6 +315,37 @@ func (m *SessionManager) Stop(sessionID string) error {
 	return sess.Stop()
 }
 
+// List returns the tracked sessions.
+func (m *SessionManager) List() []*Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sessions := make([]*Session, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		sessions = append(sessions, sess)
+	}
+	return sessions
+}
+
+// Shutdown shuts every tracked session down concurrently, as
+// Session.Shutdown does, and returns once all have ended.
+func (m *SessionManager) Shutdown(ctx context.Context) {
+	m.mu.Lock()
+	sessions := m.sessions
+	m.sessions = make(map[string]*Session)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, sess := range sessions {
+		wg.Add(1)
+		go func(sess *Session) {
+			defer wg.Done()
+			_ = sess.Shutdown(ctx)
+		}(sess)
+	}
+	wg.Wait()
+}
+
 // StopAll terminates every tracked session.
 func (m *SessionManager) StopAll() {
 	m.mu.Lock()
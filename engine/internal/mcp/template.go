@@ -0,0 +1,77 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/secrets"
+)
+
+// templatePattern matches a template variable such as "{{task_id}}".
+var templatePattern = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// templateVars returns the variables a session's args and env values may
+// use: task_id, phase, role, workspace, context_file (also digest_path),
+// budget_remaining (in USD), and api_url, the engine's API base URL.
+func templateVars(cfg domain.SessionConfig, apiURL string) map[string]string {
+	return map[string]string{
+		"task_id":          cfg.TaskID,
+		"phase":            string(cfg.Phase),
+		"role":             cfg.Role,
+		"api_url":          apiURL,
+		"workspace":        cfg.Workspace,
+		"context_file":     cfg.ContextFile,
+		"digest_path":      cfg.ContextFile,
+		"budget_remaining": strconv.FormatFloat(cfg.BudgetRemainingUSD, 'f', 2, 64),
+	}
+}
+
+// expandTemplate replaces the template variables in s with their values.
+// Unknown variables are an error, so a typo does not reach the provider.
+func expandTemplate(s string, vars map[string]string) (string, error) {
+	var unknown string
+	out := templatePattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := templatePattern.FindStringSubmatch(m)[1]
+		v, ok := vars[name]
+		if !ok && unknown == "" {
+			unknown = name
+		}
+		return v
+	})
+	if unknown != "" {
+		return "", fmt.Errorf("unknown template variable %q", unknown)
+	}
+	return out, nil
+}
+
+// sessionEnv resolves the secret references and expands the template
+// variables in env into a new map. Secret values are never expanded, and
+// expanded values are never resolved, so task data cannot name a secret.
+func (m *SessionManager) sessionEnv(ctx context.Context, env, vars map[string]string) (map[string]string, error) {
+	if len(env) == 0 {
+		return env, nil
+	}
+	out := make(map[string]string, len(env))
+	for k, v := range env {
+		if _, _, ok := secrets.ParseRef(v); ok {
+			if m.Secrets != nil {
+				rv, err := m.Secrets.Resolve(ctx, v)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", k, err)
+				}
+				v = rv
+			}
+			out[k] = v
+			continue
+		}
+		ev, err := expandTemplate(v, vars)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		out[k] = ev
+	}
+	return out, nil
+}
@@ -0,0 +1,181 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// ProviderCheck configures how providers are validated.
+type ProviderCheck struct {
+	// SmokeArgs are run with each provider's command, such as
+	// ["--version"]. Empty only resolves the executable.
+	SmokeArgs []string
+	// Timeout bounds each smoke test (default 10s).
+	Timeout time.Duration
+}
+
+// ProviderStatus is the outcome of validating one provider spec. CheckedAt
+// is 0 until the spec has been validated.
+type ProviderStatus struct {
+	Name domain.Provider `json:"name"`
+	// Namespace is set for a namespace's override of the provider.
+	Namespace string `json:"namespace,omitempty"`
+	// Runtime is set for a provider registered through the API.
+	Runtime bool   `json:"runtime,omitempty"`
+	Command string `json:"command"`
+	// Path is the executable Command resolved to.
+	Path string `json:"path,omitempty"`
+	// Version is the first line the smoke test printed.
+	Version   string `json:"version,omitempty"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+	CheckedAt int64  `json:"checkedAt"`
+}
+
+// CheckSpec resolves spec's command on PATH and, when c has SmokeArgs, runs
+// it with them, expecting a zero exit status within c.Timeout.
+func CheckSpec(ctx context.Context, spec ProviderSpec, c ProviderCheck) ProviderStatus {
+	st := ProviderStatus{Name: spec.Name, Command: spec.Command, CheckedAt: time.Now().Unix()}
+	path, err := exec.LookPath(spec.Command)
+	if err != nil {
+		st.Error = fmt.Sprintf("command %q not found: %v", spec.Command, err)
+		return st
+	}
+	st.Path = path
+	if len(c.SmokeArgs) == 0 {
+		st.OK = true
+		return st
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, c.SmokeArgs...).CombinedOutput()
+	line := firstLine(out)
+	smoke := strings.Join(append([]string{spec.Command}, c.SmokeArgs...), " ")
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		st.Error = fmt.Sprintf("%s did not finish within %s", smoke, timeout)
+	case err != nil && line != "":
+		st.Error = fmt.Sprintf("%s: %v: %s", smoke, err, line)
+	case err != nil:
+		st.Error = fmt.Sprintf("%s: %v", smoke, err)
+	default:
+		st.Version, st.OK = line, true
+	}
+	return st
+}
+
+// firstLine returns the first non-blank line of out.
+func firstLine(out []byte) string {
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		if s := strings.TrimSpace(string(line)); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// checkKey identifies the spec a status belongs to.
+func checkKey(namespace string, name domain.Provider) string {
+	return namespace + "/" + string(name)
+}
+
+// Validate checks every registered provider and namespace override
+// concurrently with CheckSpec, keeps the results for Statuses, and returns
+// them as Statuses orders them.
+func (r *ProviderRegistry) Validate(ctx context.Context, c ProviderCheck) []ProviderStatus {
+	type target struct {
+		namespace string
+		spec      ProviderSpec
+	}
+	var targets []target
+	r.mu.RLock()
+	for _, spec := range r.global() {
+		targets = append(targets, target{spec: spec})
+	}
+	for ns, overrides := range r.namespaces {
+		for _, spec := range overrides {
+			targets = append(targets, target{namespace: ns, spec: spec})
+		}
+	}
+	r.mu.RUnlock()
+
+	results := make([]ProviderStatus, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t target) {
+			defer wg.Done()
+			results[i] = CheckSpec(ctx, t.spec, c)
+			results[i].Namespace = t.namespace
+		}(i, t)
+	}
+	wg.Wait()
+	r.mu.RLock()
+	for i, t := range targets {
+		_, runtime := r.runtime[t.spec.Name]
+		results[i].Runtime = runtime && t.namespace == ""
+	}
+	r.mu.RUnlock()
+
+	r.mu.Lock()
+	for _, st := range results {
+		r.checks[checkKey(st.Namespace, st.Name)] = st
+	}
+	r.mu.Unlock()
+	sortStatuses(results)
+	return results
+}
+
+// Statuses returns the latest validation of every registered provider and
+// namespace override, global providers first, each group by name. Specs
+// replaced since they were validated are reported unchecked.
+func (r *ProviderRegistry) Statuses() []ProviderStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var statuses []ProviderStatus
+	add := func(namespace string, spec ProviderSpec) {
+		st, ok := r.checks[checkKey(namespace, spec.Name)]
+		if !ok {
+			st = ProviderStatus{Name: spec.Name, Namespace: namespace, Command: spec.Command}
+		}
+		_, runtime := r.runtime[spec.Name]
+		st.Runtime = runtime && namespace == ""
+		statuses = append(statuses, st)
+	}
+	for _, spec := range r.global() {
+		add("", spec)
+	}
+	for ns, overrides := range r.namespaces {
+		for _, spec := range overrides {
+			add(ns, spec)
+		}
+	}
+	sortStatuses(statuses)
+	return statuses
+}
+
+// sortStatuses orders global providers first, then by namespace and name.
+func sortStatuses(statuses []ProviderStatus) {
+	sort.Slice(statuses, func(i, j int) bool {
+		a, b := statuses[i], statuses[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+}
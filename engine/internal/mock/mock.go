@@ -0,0 +1,213 @@
+// Package mock replays scripted agent sessions from a scenario file, so a
+// flow can be rehearsed from phase A to G, with its orchestration, budget,
+// and review logic, without running or paying for real agents.
+//
+// A scenario lists the steps a session plays. Sessions take the steps of
+// their worker's role when the scenario has them, else those of their
+// phase, else the default steps:
+//
+//	{
+//	  "steps":  [{"type": "cost", "amount_usd": 0.02}, {"type": "result", "text": "done"}],
+//	  "phases": {"C": [{"type": "message", "text": "coding"}, {"type": "result", "text": "done"}]},
+//	  "roles":  {"security": [{"type": "review", "review": {...}}, {"type": "result", "text": "ok"}]}
+//	}
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/mcp"
+	"github.com/anthropics/three-body-engine/pkg/client"
+)
+
+// StepReview is a step that submits a score card to the engine; every other
+// step type is one of the canonical session event types.
+const StepReview = "review"
+
+// Step is one scripted action of a session.
+type Step struct {
+	// Type is message, tool_call, cost, result, error, or review.
+	Type string `json:"type"`
+	// DelayMS is how long to wait before the step.
+	DelayMS int `json:"delay_ms,omitempty"`
+
+	// Text is a message's or result's text; Role a message's role
+	// (default "assistant").
+	Text string `json:"text,omitempty"`
+	Role string `json:"role,omitempty"`
+	// Name and Input describe a tool call.
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// InputTokens, OutputTokens, AmountUSD, and Key make up a cost report.
+	InputTokens  int64   `json:"input_tokens,omitempty"`
+	OutputTokens int64   `json:"output_tokens,omitempty"`
+	AmountUSD    float64 `json:"amount_usd,omitempty"`
+	Key          string  `json:"key,omitempty"`
+	// Message is an error's message.
+	Message string `json:"message,omitempty"`
+	// Review is the score card a review step submits. Its reviewer defaults
+	// to the session's role.
+	Review *client.ScoreCard `json:"review,omitempty"`
+}
+
+// Scenario is the script of every mock session.
+type Scenario struct {
+	Steps  []Step            `json:"steps"`
+	Phases map[string][]Step `json:"phases,omitempty"`
+	Roles  map[string][]Step `json:"roles,omitempty"`
+}
+
+// Load reads and checks the scenario at path.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario: %w", err)
+	}
+	var sc Scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("parse scenario %s: %w", path, err)
+	}
+	if err := sc.validate(); err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+func (sc *Scenario) validate() error {
+	check := func(where string, steps []Step) error {
+		for i, st := range steps {
+			switch st.Type {
+			case mcp.EventMessage, mcp.EventToolCall, mcp.EventCost, mcp.EventResult, mcp.EventError:
+			case StepReview:
+				if st.Review == nil {
+					return fmt.Errorf("%s[%d]: review step has no review", where, i)
+				}
+			default:
+				return fmt.Errorf("%s[%d]: unknown step type %q", where, i, st.Type)
+			}
+			if st.DelayMS < 0 {
+				return fmt.Errorf("%s[%d]: delay_ms must not be negative", where, i)
+			}
+		}
+		return nil
+	}
+	if err := check("steps", sc.Steps); err != nil {
+		return err
+	}
+	for phase, steps := range sc.Phases {
+		if err := check("phases."+phase, steps); err != nil {
+			return err
+		}
+	}
+	for role, steps := range sc.Roles {
+		if err := check("roles."+role, steps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StepsFor returns the steps a session of role in phase plays.
+func (sc *Scenario) StepsFor(phase, role string) []Step {
+	if steps, ok := sc.Roles[role]; ok {
+		return steps
+	}
+	if steps, ok := sc.Phases[phase]; ok {
+		return steps
+	}
+	return sc.Steps
+}
+
+// Session identifies the session Play plays for.
+type Session struct {
+	TaskID string
+	Phase  string
+	Role   string
+	// Engine submits review steps' score cards; nil fails them.
+	Engine *client.Client
+}
+
+// Play writes steps to w as JSON lines in the engine's canonical event
+// shapes, which the passthrough adapter reads back unchanged. A review step
+// that cannot be submitted is reported as an error event and ends the play.
+func Play(ctx context.Context, w io.Writer, sess Session, steps []Step) error {
+	enc := json.NewEncoder(w)
+	for i, st := range steps {
+		if st.DelayMS > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(st.DelayMS) * time.Millisecond):
+			}
+		}
+		if st.Type == StepReview {
+			if err := submit(ctx, sess, i, st.Review); err != nil {
+				enc.Encode(event(Step{Type: mcp.EventError, Message: err.Error()}))
+				return err
+			}
+			continue
+		}
+		if err := enc.Encode(event(st)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// event returns the output line of st: its type and canonical payload.
+func event(st Step) interface{} {
+	switch st.Type {
+	case mcp.EventMessage:
+		role := st.Role
+		if role == "" {
+			role = "assistant"
+		}
+		return struct {
+			Type string `json:"type"`
+			mcp.MessagePayload
+		}{st.Type, mcp.MessagePayload{Role: role, Text: st.Text}}
+	case mcp.EventToolCall:
+		return struct {
+			Type string `json:"type"`
+			mcp.ToolCallPayload
+		}{st.Type, mcp.ToolCallPayload{Name: st.Name, Input: st.Input}}
+	case mcp.EventCost:
+		return struct {
+			Type string `json:"type"`
+			mcp.CostPayload
+		}{st.Type, mcp.CostPayload{InputTokens: st.InputTokens, OutputTokens: st.OutputTokens, AmountUSD: st.AmountUSD, Key: st.Key}}
+	case mcp.EventError:
+		return struct {
+			Type string `json:"type"`
+			mcp.ErrorPayload
+		}{st.Type, mcp.ErrorPayload{Message: st.Message}}
+	default:
+		return struct {
+			Type string `json:"type"`
+			mcp.ResultPayload
+		}{st.Type, mcp.ResultPayload{Text: st.Text}}
+	}
+}
+
+// submit posts the score card of the i-th step for sess.
+func submit(ctx context.Context, sess Session, i int, card *client.ScoreCard) error {
+	if sess.Engine == nil {
+		return fmt.Errorf("review step %d: no engine URL to submit to", i)
+	}
+	c := *card
+	if c.Reviewer == "" {
+		c.Reviewer = sess.Role
+	}
+	if c.ReviewID == "" {
+		c.ReviewID = fmt.Sprintf("mock-%s-%s-%s-%d", sess.TaskID, sess.Phase, c.Reviewer, time.Now().UnixNano())
+	}
+	if _, err := sess.Engine.SubmitScoreCard(ctx, sess.TaskID, c); err != nil {
+		return fmt.Errorf("review step %d: %w", i, err)
+	}
+	return nil
+}
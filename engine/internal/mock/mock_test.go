@@ -0,0 +1,144 @@
+package mock
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/mcp"
+	"github.com/anthropics/three-body-engine/pkg/client"
+)
+
+func writeScenario(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_StepsFor(t *testing.T) {
+	sc, err := Load(writeScenario(t, `{
+		"steps":  [{"type": "result", "text": "default"}],
+		"phases": {"C": [{"type": "result", "text": "phase"}]},
+		"roles":  {"security": [{"type": "result", "text": "role"}]}
+	}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, tc := range []struct{ phase, role, want string }{
+		{"A", "", "default"},
+		{"C", "", "phase"},
+		{"C", "security", "role"},
+		{"E", "security", "role"},
+	} {
+		if got := sc.StepsFor(tc.phase, tc.role)[0].Text; got != tc.want {
+			t.Errorf("StepsFor(%q, %q) = %q, want %q", tc.phase, tc.role, got, tc.want)
+		}
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"unknown type":  `{"steps": [{"type": "dance"}]}`,
+		"empty review":  `{"roles": {"r": [{"type": "review"}]}}`,
+		"negative wait": `{"phases": {"B": [{"type": "result", "delay_ms": -1}]}}`,
+		"bad json":      `{"steps": [`,
+	} {
+		if _, err := Load(writeScenario(t, body)); err == nil {
+			t.Errorf("%s: Load succeeded", name)
+		}
+	}
+}
+
+func TestPlay_CanonicalEvents(t *testing.T) {
+	steps := []Step{
+		{Type: mcp.EventMessage, Text: "hi"},
+		{Type: mcp.EventToolCall, Name: "edit", Input: json.RawMessage(`{"path":"a.go"}`)},
+		{Type: mcp.EventCost, InputTokens: 10, OutputTokens: 5, AmountUSD: 0.25},
+		{Type: mcp.EventResult, Text: "done"},
+	}
+	var buf bytes.Buffer
+	if err := Play(context.Background(), &buf, Session{TaskID: "t1"}, steps); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+
+	// Every line must read back unchanged through the passthrough adapter.
+	adapter := mcp.AdapterFor("")
+	var events []domain.NormalizedEvent
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		evs, err := adapter.Translate(sc.Bytes())
+		if err != nil {
+			t.Fatalf("Translate %s: %v", sc.Text(), err)
+		}
+		events = append(events, evs...)
+	}
+	if len(events) != len(steps) {
+		t.Fatalf("got %d events, want %d", len(events), len(steps))
+	}
+	for i, ev := range events {
+		if ev.Type != steps[i].Type {
+			t.Errorf("event %d type = %q, want %q", i, ev.Type, steps[i].Type)
+		}
+	}
+	var msg mcp.MessagePayload
+	json.Unmarshal(events[0].Payload, &msg)
+	if msg.Role != "assistant" || msg.Text != "hi" {
+		t.Errorf("message = %+v", msg)
+	}
+	var cost mcp.CostPayload
+	json.Unmarshal(events[2].Payload, &cost)
+	if cost.InputTokens != 10 || cost.OutputTokens != 5 || cost.AmountUSD != 0.25 {
+		t.Errorf("cost = %+v", cost)
+	}
+}
+
+func TestPlay_Review(t *testing.T) {
+	var got client.ScoreCard
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(got)
+	}))
+	defer srv.Close()
+
+	steps := []Step{
+		{Type: StepReview, Review: &client.ScoreCard{Verdict: "pass"}},
+		{Type: mcp.EventResult, Text: "reviewed"},
+	}
+	var buf bytes.Buffer
+	sess := Session{TaskID: "t1", Phase: "E", Role: "security", Engine: client.New(srv.URL)}
+	if err := Play(context.Background(), &buf, sess, steps); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	if path != "/api/v1/flow/t1/reviews" {
+		t.Errorf("path = %q", path)
+	}
+	if got.Reviewer != "security" || got.ReviewID == "" || got.Verdict != "pass" {
+		t.Errorf("card = %+v", got)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 1 {
+		t.Errorf("got %d lines, want only the result:\n%s", lines, buf.String())
+	}
+
+	// Without an engine the review fails the play with an error event.
+	buf.Reset()
+	if err := Play(context.Background(), &buf, Session{TaskID: "t1"}, steps); err == nil {
+		t.Fatal("Play without an engine succeeded")
+	}
+	if !strings.Contains(buf.String(), `"type":"error"`) {
+		t.Errorf("output = %s, want an error event", buf.String())
+	}
+}
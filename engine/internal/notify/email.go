@@ -0,0 +1,246 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/clock"
+)
+
+// Template renders the subject and body of an email. Single notifications
+// are rendered with the Notification as data; digests with a Digest.
+type Template struct {
+	Subject *template.Template
+	Body    *template.Template
+}
+
+// ParseTemplate parses subject and body as text/template templates.
+func ParseTemplate(subject, body string) (*Template, error) {
+	s, err := template.New("subject").Option("missingkey=zero").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("subject: %w", err)
+	}
+	b, err := template.New("body").Option("missingkey=zero").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	return &Template{Subject: s, Body: b}, nil
+}
+
+// Digest is the data of a digest email: the notifications of one event
+// collected over a batch window, oldest first.
+type Digest struct {
+	Event         string
+	Notifications []Notification
+}
+
+// DigestTemplate is the key of the template rendering digests.
+const DigestTemplate = "digest"
+
+var (
+	defaultTemplate = mustParse(
+		`[threebody] {{.Subject}}`,
+		"{{.Subject}}\n{{with .Text}}\n{{.}}\n{{end}}{{with .TaskID}}\nTask: {{.}}{{end}}\nEvent: {{.Event}}\n")
+	defaultDigestTemplate = mustParse(
+		`[threebody] {{len .Notifications}} {{.Event}} notifications`,
+		"{{range .Notifications}}- {{.Subject}}\n{{end}}")
+)
+
+func mustParse(subject, body string) *Template {
+	t, err := ParseTemplate(subject, body)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Email sends notifications through an SMTP server. Templates, keyed by
+// event, render each email, falling back to a plain summary. Notifications
+// of the events in Batch are collected for BatchWindow after the first one
+// and sent as a single digest, rendered with the "digest" template.
+type Email struct {
+	// Addr is the SMTP server as host:port.
+	Addr string
+	// Username and Password, when set, authenticate with PLAIN auth.
+	Username string
+	Password string
+	// ImplicitTLS connects over TLS (port 465); otherwise the connection
+	// is upgraded with STARTTLS when the server offers it.
+	ImplicitTLS bool
+	From        string
+	To          []string
+	Templates   map[string]*Template
+	Batch       map[string]bool
+	BatchWindow time.Duration
+	Clock       clock.Clock
+	// Timeout bounds the delivery of a digest (default 30s).
+	Timeout time.Duration
+	// OnError receives failed digest deliveries; they are logged otherwise.
+	OnError func(err error)
+
+	mu      sync.Mutex
+	pending map[string][]Notification
+}
+
+// Send implements Channel. A batched notification is queued and reported
+// as sent; its digest is delivered when the window closes.
+func (e *Email) Send(ctx context.Context, n Notification) error {
+	if e.Batch[n.Event] && e.BatchWindow > 0 {
+		e.mu.Lock()
+		if e.pending == nil {
+			e.pending = make(map[string][]Notification)
+		}
+		first := len(e.pending[n.Event]) == 0
+		e.pending[n.Event] = append(e.pending[n.Event], n)
+		e.mu.Unlock()
+		if first {
+			go func() {
+				<-clock.Or(e.Clock).After(e.BatchWindow)
+				e.flush(n.Event)
+			}()
+		}
+		return nil
+	}
+
+	t := e.Templates[n.Event]
+	if t == nil {
+		t = defaultTemplate
+	}
+	return e.deliver(ctx, t, n)
+}
+
+// Flush sends the digests still collecting.
+func (e *Email) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	pending := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	var errs []string
+	for event, ns := range pending {
+		if err := e.sendDigest(ctx, event, ns); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// flush sends the digest of event once its window has closed.
+func (e *Email) flush(event string) {
+	e.mu.Lock()
+	ns := e.pending[event]
+	delete(e.pending, event)
+	e.mu.Unlock()
+	if len(ns) == 0 {
+		return // already sent by Flush
+	}
+
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := e.sendDigest(ctx, event, ns); err != nil {
+		if e.OnError != nil {
+			e.OnError(err)
+			return
+		}
+		log.Printf("notify %s digest via email: %v", event, err)
+	}
+}
+
+func (e *Email) sendDigest(ctx context.Context, event string, ns []Notification) error {
+	t := e.Templates[DigestTemplate]
+	if t == nil {
+		t = defaultDigestTemplate
+	}
+	return e.deliver(ctx, t, Digest{Event: event, Notifications: ns})
+}
+
+// deliver renders t with data and sends the message.
+func (e *Email) deliver(ctx context.Context, t *Template, data interface{}) error {
+	var subject, body bytes.Buffer
+	if err := t.Subject.Execute(&subject, data); err != nil {
+		return fmt.Errorf("render subject: %w", err)
+	}
+	if err := t.Body.Execute(&body, data); err != nil {
+		return fmt.Errorf("render body: %w", err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	// Header values must not carry line breaks.
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.Join(strings.Fields(subject.String()), " "))
+	fmt.Fprintf(&msg, "Date: %s\r\n", clock.Or(e.Clock).Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body.String(), "\r\n", "\n"), "\n", "\r\n"))
+	return e.send(ctx, msg.Bytes())
+}
+
+// send delivers msg to every recipient in one SMTP session.
+func (e *Email) send(ctx context.Context, msg []byte) error {
+	host, _, err := net.SplitHostPort(e.Addr)
+	if err != nil {
+		return err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if e.ImplicitTLS {
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && !e.ImplicitTLS {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if e.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.Username, e.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.From); err != nil {
+		return err
+	}
+	for _, to := range e.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
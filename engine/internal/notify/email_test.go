@@ -0,0 +1,192 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/clock"
+)
+
+// smtpServer is a minimal SMTP server recording the messages it accepts.
+type smtpServer struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	auth []string
+	rcpt []string
+	msgs []string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpServer{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		switch verb := strings.ToUpper(strings.Fields(cmd + " x")[0]); verb {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			creds, _ := base64.StdEncoding.DecodeString(strings.Fields(cmd)[2])
+			s.mu.Lock()
+			s.auth = append(s.auth, string(creds))
+			s.mu.Unlock()
+			reply("235 accepted")
+		case "RCPT":
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, cmd)
+			s.mu.Unlock()
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var msg strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				msg.WriteString(l)
+			}
+			s.mu.Lock()
+			s.msgs = append(s.msgs, msg.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *smtpServer) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.msgs...)
+}
+
+func TestEmail_Send(t *testing.T) {
+	srv := newSMTPServer(t)
+	blocked, err := ParseTemplate(`{{.TaskID}} blocked`, "Reason: {{.Data.reason}}\n")
+	if err != nil {
+		t.Fatalf("ParseTemplate: %v", err)
+	}
+	e := &Email{
+		Addr:      srv.ln.Addr().String(),
+		Username:  "bot",
+		Password:  "s3cret",
+		From:      "threebody@acme.com",
+		To:        []string{"ops@acme.com", "lead@acme.com"},
+		Templates: map[string]*Template{"flow_blocked": blocked},
+	}
+	ctx := context.Background()
+	if err := e.Send(ctx, Notification{Event: "flow_blocked", TaskID: "t1", Data: map[string]interface{}{"reason": "tests failing"}}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := e.Send(ctx, Notification{Event: "flow_completed", TaskID: "t2", Subject: "t2 completed"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	msgs := srv.messages()
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want 2", len(msgs))
+	}
+	for _, want := range []string{"From: threebody@acme.com\r\n", "To: ops@acme.com, lead@acme.com\r\n", "Subject: t1 blocked\r\n", "\r\n\r\nReason: tests failing\r\n"} {
+		if !strings.Contains(msgs[0], want) {
+			t.Errorf("templated message lacks %q:\n%s", want, msgs[0])
+		}
+	}
+	if !strings.Contains(msgs[1], "Subject: [threebody] t2 completed\r\n") || !strings.Contains(msgs[1], "Task: t2") {
+		t.Errorf("default message:\n%s", msgs[1])
+	}
+	if len(srv.rcpt) != 4 || srv.auth[0] != "\x00bot\x00s3cret" {
+		t.Errorf("rcpt = %q, auth = %q", srv.rcpt, srv.auth)
+	}
+
+	e.Addr = "127.0.0.1:1"
+	if err := e.Send(ctx, Notification{Event: "flow_completed"}); err == nil {
+		t.Error("Send to a closed port succeeded")
+	}
+}
+
+func TestEmail_Batch(t *testing.T) {
+	srv := newSMTPServer(t)
+	fake := clock.NewFake(time.Unix(1000, 0))
+	e := &Email{
+		Addr:        srv.ln.Addr().String(),
+		From:        "threebody@acme.com",
+		To:          []string{"ops@acme.com"},
+		Batch:       map[string]bool{"budget_alert": true},
+		BatchWindow: 5 * time.Minute,
+		Clock:       fake,
+	}
+	ctx := context.Background()
+	e.Send(ctx, Notification{Event: "budget_alert", Subject: "t1 spent half"})
+	e.Send(ctx, Notification{Event: "budget_alert", Subject: "t2 spent half"})
+	if msgs := srv.messages(); len(msgs) != 0 {
+		t.Fatalf("batched notifications were sent at once: %q", msgs)
+	}
+
+	for fake.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	fake.Advance(5 * time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for len(srv.messages()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	msgs := srv.messages()
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "Subject: [threebody] 2 budget_alert notifications") ||
+		!strings.Contains(msgs[0], "- t1 spent half\r\n- t2 spent half\r\n") {
+		t.Fatalf("digest = %q", msgs)
+	}
+
+	e.Send(ctx, Notification{Event: "budget_alert", Subject: "t3 spent half"})
+	if err := e.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if msgs := srv.messages(); len(msgs) != 2 || !strings.Contains(msgs[1], "- t3 spent half") {
+		t.Errorf("messages after Flush = %q", msgs)
+	}
+}
+
+func TestParseTemplate_Invalid(t *testing.T) {
+	if _, err := ParseTemplate("{{.Subject", "body"); err == nil || !strings.HasPrefix(err.Error(), "subject: ") {
+		t.Errorf("ParseTemplate = %v, want a subject error", err)
+	}
+}
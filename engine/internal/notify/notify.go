@@ -0,0 +1,205 @@
+// Package notify delivers engine notifications, such as budget alerts, to
+// named channels configured by the operator.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Notification is one message for operators.
+type Notification struct {
+	// Event names what happened, such as budget_alert.
+	Event  string `json:"event"`
+	TaskID string `json:"taskId,omitempty"`
+	// Subject is a one-line summary; Text may add detail.
+	Subject string                 `json:"subject"`
+	Text    string                 `json:"text,omitempty"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+	Time    int64                  `json:"time"`
+}
+
+// Channel delivers notifications to one destination.
+type Channel interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// Webhook posts each notification as JSON to URL.
+type Webhook struct {
+	URL     string
+	Headers map[string]string
+	HTTP    *http.Client
+}
+
+// Send implements Channel.
+func (w *Webhook) Send(ctx context.Context, n Notification) error {
+	client := w.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("marshal notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Log writes each notification to the process log.
+type Log struct{}
+
+// Send implements Channel.
+func (Log) Send(ctx context.Context, n Notification) error {
+	if n.TaskID != "" {
+		log.Printf("notify %s [%s]: %s", n.Event, n.TaskID, n.Subject)
+	} else {
+		log.Printf("notify %s: %s", n.Event, n.Subject)
+	}
+	return nil
+}
+
+// Dispatcher sends notifications to channels by name. Delivery runs in the
+// background so a slow channel never holds up the caller. A nil Dispatcher
+// drops every notification.
+type Dispatcher struct {
+	// Timeout bounds each delivery (default 10s).
+	Timeout time.Duration
+	// OnError, when set, receives failed deliveries; they are logged
+	// otherwise.
+	OnError func(channel string, n Notification, err error)
+
+	mu       sync.RWMutex
+	channels map[string]Channel
+	routes   map[string][]string
+	wg       sync.WaitGroup
+}
+
+// Flusher is a Channel that holds notifications back, such as for a
+// digest, and can send them early.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// NewDispatcher creates a Dispatcher over channels.
+func NewDispatcher(channels map[string]Channel) *Dispatcher {
+	d := &Dispatcher{Timeout: 10 * time.Second}
+	d.SetChannels(channels)
+	return d
+}
+
+// SetChannels replaces the channels at runtime.
+func (d *Dispatcher) SetChannels(channels map[string]Channel) {
+	copied := make(map[string]Channel, len(channels))
+	for name, ch := range channels {
+		copied[name] = ch
+	}
+	d.mu.Lock()
+	d.channels = copied
+	d.mu.Unlock()
+}
+
+// SetRoutes replaces the channels each event is announced on.
+func (d *Dispatcher) SetRoutes(routes map[string][]string) {
+	copied := make(map[string][]string, len(routes))
+	for event, names := range routes {
+		copied[event] = append([]string(nil), names...)
+	}
+	d.mu.Lock()
+	d.routes = copied
+	d.mu.Unlock()
+}
+
+// Announce sends n to the channels routed for its event, if any.
+func (d *Dispatcher) Announce(n Notification) {
+	if d == nil {
+		return
+	}
+	d.mu.RLock()
+	names := d.routes[n.Event]
+	d.mu.RUnlock()
+	d.Notify(names, n)
+}
+
+// Notify sends n to each named channel. Names with no channel are reported
+// as delivery errors.
+func (d *Dispatcher) Notify(names []string, n Notification) {
+	if d == nil {
+		return
+	}
+	d.mu.RLock()
+	channels := d.channels
+	d.mu.RUnlock()
+
+	for _, name := range names {
+		ch, ok := channels[name]
+		if !ok {
+			d.fail(name, n, fmt.Errorf("unknown channel"))
+			continue
+		}
+		d.wg.Add(1)
+		go func(name string, ch Channel) {
+			defer d.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
+			defer cancel()
+			if err := ch.Send(ctx, n); err != nil {
+				d.fail(name, n, err)
+			}
+		}(name, ch)
+	}
+}
+
+// Wait blocks until every delivery in flight has finished, then sends
+// what Flusher channels hold back.
+func (d *Dispatcher) Wait() {
+	if d == nil {
+		return
+	}
+	d.wg.Wait()
+
+	d.mu.RLock()
+	channels := d.channels
+	d.mu.RUnlock()
+	for name, ch := range channels {
+		f, ok := ch.(Flusher)
+		if !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
+		if err := f.Flush(ctx); err != nil {
+			d.fail(name, Notification{Event: "flush"}, err)
+		}
+		cancel()
+	}
+}
+
+func (d *Dispatcher) fail(channel string, n Notification, err error) {
+	if d.OnError != nil {
+		d.OnError(channel, n, err)
+		return
+	}
+	log.Printf("notify %s via %s: %v", n.Event, channel, err)
+}
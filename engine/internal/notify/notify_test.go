@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type recorder struct {
+	mu   sync.Mutex
+	sent []Notification
+	err  error
+}
+
+func (r *recorder) Send(ctx context.Context, n Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, n)
+	return r.err
+}
+
+func TestWebhook_Send(t *testing.T) {
+	var got Notification
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	w := &Webhook{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer t"}}
+	n := Notification{Event: "budget_alert", TaskID: "t1", Subject: "half spent", Time: 1}
+	if err := w.Send(context.Background(), n); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got.Event != n.Event || got.TaskID != "t1" || got.Subject != n.Subject || auth != "Bearer t" {
+		t.Errorf("received %+v with auth %q", got, auth)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if err := (&Webhook{URL: failing.URL}).Send(context.Background(), n); err == nil {
+		t.Error("Send to a failing endpoint succeeded")
+	}
+}
+
+func TestDispatcher_Notify(t *testing.T) {
+	ok, broken := &recorder{}, &recorder{err: errors.New("down")}
+	d := NewDispatcher(map[string]Channel{"ok": ok, "broken": broken})
+	var mu sync.Mutex
+	failed := map[string]bool{}
+	d.OnError = func(channel string, n Notification, err error) {
+		mu.Lock()
+		failed[channel] = true
+		mu.Unlock()
+	}
+
+	d.Notify([]string{"ok", "broken", "missing"}, Notification{Event: "e"})
+	d.Wait()
+	if len(ok.sent) != 1 || len(broken.sent) != 1 {
+		t.Errorf("sent ok=%d broken=%d, want 1 each", len(ok.sent), len(broken.sent))
+	}
+	if !failed["broken"] || !failed["missing"] || failed["ok"] {
+		t.Errorf("failed = %v, want broken and missing", failed)
+	}
+
+	d.SetChannels(nil)
+	d.Notify([]string{"ok"}, Notification{Event: "e"})
+	d.Wait()
+	if len(ok.sent) != 1 {
+		t.Errorf("removed channel still received %d", len(ok.sent))
+	}
+
+	var nilDispatcher *Dispatcher
+	nilDispatcher.Notify([]string{"ok"}, Notification{})
+	nilDispatcher.Wait()
+}
+
+// holder is a Flusher channel that keeps notifications until flushed.
+type holder struct {
+	recorder
+	held []Notification
+}
+
+func (h *holder) Send(ctx context.Context, n Notification) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.held = append(h.held, n)
+	return nil
+}
+
+func (h *holder) Flush(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sent = append(h.sent, h.held...)
+	h.held = nil
+	return nil
+}
+
+func TestDispatcher_Announce(t *testing.T) {
+	ops, digest := &recorder{}, &holder{}
+	d := NewDispatcher(map[string]Channel{"ops": ops, "digest": digest})
+	d.SetRoutes(map[string][]string{"flow_blocked": {"ops", "digest"}, "flow_completed": {"digest"}})
+
+	d.Announce(Notification{Event: "flow_blocked", TaskID: "t1"})
+	d.Announce(Notification{Event: "flow_completed", TaskID: "t1"})
+	d.Announce(Notification{Event: "flow_failed", TaskID: "t1"})
+	d.Wait()
+	if len(ops.sent) != 1 || ops.sent[0].Event != "flow_blocked" {
+		t.Errorf("ops received %+v, want only flow_blocked", ops.sent)
+	}
+	if len(digest.sent) != 2 || len(digest.held) != 0 {
+		t.Errorf("digest sent %+v and held %+v; Wait should flush it", digest.sent, digest.held)
+	}
+}
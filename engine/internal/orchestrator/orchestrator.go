@@ -0,0 +1,479 @@
+// Package orchestrator drives code agent sessions for each workflow phase,
+// spawning the configured workers on phase entry and advancing the flow once
+// every worker has delivered a result.
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/bridge"
+	"github.com/anthropics/three-body-engine/internal/chaos"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/mcp"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/team"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// WorkerPlan describes a group of identical workers to run in a phase.
+type WorkerPlan struct {
+	Role           string
+	Provider       domain.Provider
+	Count          int
+	SoftTimeoutSec int
+	HardTimeoutSec int
+	// Partition splits the workspace's files into Count non-overlapping
+	// FileOwnership sets, one per worker, instead of leaving ownership to be
+	// assigned by hand.
+	Partition bool
+	// Reviewer marks a phase F reviewer: it owns no files, runs with a
+	// read-only capability sheet, sees only the phase E artifacts, and
+	// succeeds only once it has submitted a score card under its role.
+	Reviewer bool
+}
+
+// Orchestrator connects phase transitions to worker and session creation.
+type Orchestrator struct {
+	Engine    *workflow.Engine
+	Workers   *team.WorkerManager
+	Bridge    *bridge.Bridge
+	Digests   *team.DigestBuilder
+	Plans     map[domain.Phase][]WorkerPlan
+	Workspace string
+	// Permissions builds reviewers' capability sheets and ScoreCards
+	// looks up the score cards they submit.
+	Permissions *team.PermissionBroker
+	ScoreCards  *store.ScoreCardRepo
+	// DigestFormat is team.DigestJSON or team.DigestMarkdown, the format of
+	// digests written for workers without a DigestPath. Empty means JSON.
+	DigestFormat string
+	// Chaos, if set, drops the heartbeats of session events at its rate.
+	Chaos *chaos.Monkey
+
+	mu     sync.Mutex
+	base   context.Context
+	cancel context.CancelFunc
+	runs   map[string]*phaseRun
+}
+
+// phaseRun tracks the workers started for one task in one phase.
+type phaseRun struct {
+	phase  domain.Phase
+	cancel context.CancelFunc
+}
+
+// workerOutcome is reported by each worker's event loop when its session ends.
+type workerOutcome struct {
+	WorkerID string
+	OK       bool
+	Reason   string
+	// Reviewer is the role of a reviewer worker, whose score card is
+	// checked before the outcome counts as OK.
+	Reviewer string
+}
+
+// New creates an Orchestrator. Start must be called before transitions are handled.
+func New(engine *workflow.Engine, workers *team.WorkerManager, b *bridge.Bridge, digests *team.DigestBuilder, plans map[domain.Phase][]WorkerPlan, workspace string) *Orchestrator {
+	return &Orchestrator{
+		Engine:      engine,
+		Workers:     workers,
+		Bridge:      b,
+		Digests:     digests,
+		Plans:       plans,
+		Workspace:   workspace,
+		Permissions: team.NewPermissionBroker(workers.DB),
+		ScoreCards:  &store.ScoreCardRepo{},
+		runs:        make(map[string]*phaseRun),
+	}
+}
+
+// Start registers the orchestrator as a transition listener. Sessions are
+// bound to ctx rather than to the request that triggered the transition.
+func (o *Orchestrator) Start(ctx context.Context) {
+	o.mu.Lock()
+	o.base, o.cancel = context.WithCancel(ctx)
+	o.mu.Unlock()
+
+	o.Engine.AddListener(func(_ context.Context, state domain.FlowState, _ domain.Phase) {
+		if err := o.EnterPhase(state); err != nil {
+			o.audit(state.TaskID, "phase_start_failed", "warning", map[string]string{
+				"phase": string(state.CurrentPhase),
+				"error": err.Error(),
+			})
+		}
+	})
+}
+
+// Recover enters the current phase of every running flow, restarting the
+// work of flows an engine left running when it stopped without suspending
+// them. It returns how many flows were entered.
+func (o *Orchestrator) Recover(ctx context.Context) (int, error) {
+	running, err := o.Engine.TaskRepo.ListByStatus(ctx, o.Engine.DB, domain.StatusRunning)
+	if err != nil {
+		return 0, err
+	}
+	for _, state := range running {
+		if err := o.EnterPhase(*state); err != nil {
+			o.audit(state.TaskID, "phase_start_failed", "warning", map[string]string{
+				"phase": string(state.CurrentPhase),
+				"error": err.Error(),
+			})
+		}
+	}
+	return len(running), nil
+}
+
+// SetPlans replaces the per-phase worker plans. Runs already in progress keep
+// their workers; the new plans apply from the next phase entry.
+func (o *Orchestrator) SetPlans(plans map[domain.Phase][]WorkerPlan) {
+	o.mu.Lock()
+	o.Plans = plans
+	o.mu.Unlock()
+}
+
+// Stop cancels every in-flight phase run. Safe to call multiple times.
+func (o *Orchestrator) Stop() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.cancel != nil {
+		o.cancel()
+	}
+	o.runs = make(map[string]*phaseRun)
+}
+
+// EnterPhase spawns the planned workers for the flow's current phase and
+// starts their sessions. Any run still active for the task is cancelled.
+// Phases without a plan (or terminal flows) are left for manual control.
+func (o *Orchestrator) EnterPhase(state domain.FlowState) error {
+	o.mu.Lock()
+	if o.base == nil {
+		o.mu.Unlock()
+		return fmt.Errorf("orchestrator not started")
+	}
+	if prev, ok := o.runs[state.TaskID]; ok {
+		prev.cancel()
+		delete(o.runs, state.TaskID)
+	}
+	plans := o.Plans[state.CurrentPhase]
+	if state.Status != domain.StatusRunning || len(plans) == 0 {
+		o.mu.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(o.base)
+	run := &phaseRun{phase: state.CurrentPhase, cancel: cancel}
+	o.runs[state.TaskID] = run
+	o.mu.Unlock()
+
+	outcomes := make(chan workerOutcome)
+	started := 0
+	var spawned []string
+	abort := func() {
+		cancel()
+		o.finish(state.TaskID, run)
+		for _, id := range spawned {
+			_ = o.Workers.Shutdown(context.Background(), id)
+		}
+	}
+	for _, plan := range plans {
+		ownership := make([][]string, plan.Count)
+		if plan.Partition && !plan.Reviewer {
+			var err error
+			if ownership, err = o.partition(state, plan); err != nil {
+				abort()
+				return err
+			}
+		}
+		for i := 0; i < plan.Count; i++ {
+			workerID, err := o.startWorker(ctx, state, plan, ownership[i], outcomes)
+			if workerID != "" {
+				spawned = append(spawned, workerID)
+			}
+			if err != nil {
+				abort()
+				return fmt.Errorf("start %s worker: %w", plan.Role, err)
+			}
+			started++
+		}
+	}
+
+	go o.await(ctx, state, run, started, outcomes)
+	return nil
+}
+
+// partition computes one FileOwnership set per worker of plan from the
+// flow's workspace and records the assignment in the audit log.
+func (o *Orchestrator) partition(state domain.FlowState, plan WorkerPlan) ([][]string, error) {
+	files, err := team.ListFiles(o.workspace(state))
+	if err != nil {
+		return nil, fmt.Errorf("list workspace files: %w", err)
+	}
+	sets := team.PartitionOwnership(files, plan.Count)
+	detail := make(map[string]string, len(sets))
+	for i, set := range sets {
+		detail[fmt.Sprintf("%s-%d", plan.Role, i)] = strings.Join(set, " ")
+	}
+	o.audit(state.TaskID, "ownership_partitioned", "info", detail)
+	return sets, nil
+}
+
+// workspace returns the directory a flow's workers run in.
+func (o *Orchestrator) workspace(state domain.FlowState) string {
+	if state.Workspace != "" {
+		return state.Workspace
+	}
+	return o.Workspace
+}
+
+// startWorker spawns one worker, writes its digest, and launches its session.
+// When the worker limits are reached and the WorkerManager queues spawns, the
+// worker is started in the background once a slot frees up. It returns the
+// ID of the worker it spawned, if any, even when the launch failed.
+func (o *Orchestrator) startWorker(ctx context.Context, state domain.FlowState, plan WorkerPlan, ownership []string, outcomes chan<- workerOutcome) (string, error) {
+	spec := domain.WorkerSpec{
+		TaskID:         state.TaskID,
+		Phase:          state.CurrentPhase,
+		Role:           plan.Role,
+		FileOwnership:  ownership,
+		SoftTimeoutSec: plan.SoftTimeoutSec,
+		HardTimeoutSec: plan.HardTimeoutSec,
+		Priority:       state.Priority,
+	}
+	if plan.Reviewer {
+		spec.FileOwnership = nil
+		spec.ArtifactPhase = domain.PhaseE
+		spec.Capabilities = o.Permissions.BuildCapabilitySheet(state.TaskID, []string{"./"}, []string{"read"})
+	}
+
+	worker, err := o.Workers.Spawn(ctx, spec)
+	if err == domain.ErrWorkerLimitReached && o.Workers.Queue {
+		// Wait for a slot without holding up the transition; a worker
+		// that never starts counts as a failed outcome.
+		o.audit(state.TaskID, "worker_queued", "info", map[string]string{"role": plan.Role})
+		go func() {
+			worker, err := o.Workers.SpawnWait(ctx, spec)
+			if err == nil {
+				if err = o.launch(ctx, state, plan, spec, worker, outcomes); err != nil {
+					_ = o.Workers.Shutdown(context.Background(), worker.WorkerID)
+				}
+			}
+			if err != nil {
+				select {
+				case outcomes <- workerOutcome{WorkerID: "queued-" + plan.Role, Reason: "start worker: " + err.Error()}:
+				case <-ctx.Done():
+				}
+			}
+		}()
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return worker.WorkerID, o.launch(ctx, state, plan, spec, worker, outcomes)
+}
+
+// launch writes a spawned worker's digest and starts its session.
+func (o *Orchestrator) launch(ctx context.Context, state domain.FlowState, plan WorkerPlan, spec domain.WorkerSpec, worker *domain.WorkerRef, outcomes chan<- workerOutcome) error {
+	digest, err := o.Digests.Build(ctx, state.TaskID, state.CurrentPhase, spec)
+	if err != nil {
+		return fmt.Errorf("build digest: %w", err)
+	}
+	if plan.Reviewer {
+		digest.Objective += fmt.Sprintf("\n\nDo not modify any file. Submit your score card with reviewer %q to POST /api/v1/flow/%s/reviews.", plan.Role, state.TaskID)
+	}
+	digestPath, err := o.writeDigest(worker.WorkerID, spec.DigestPath, digest)
+	if err != nil {
+		return err
+	}
+
+	workspace := o.workspace(state)
+	sessionID, err := o.Bridge.StartSession(ctx, *worker, domain.SessionConfig{
+		TaskID:       state.TaskID,
+		Role:         plan.Role,
+		Provider:     plan.Provider,
+		Workspace:    workspace,
+		TimeoutSec:   plan.HardTimeoutSec,
+		ContextFile:  digestPath,
+		Capabilities: spec.Capabilities,
+	})
+	if err != nil {
+		return err
+	}
+	_ = o.Workers.UpdateState(ctx, worker.WorkerID, domain.WorkerRunning)
+
+	events, err := o.Bridge.StreamEvents(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	reviewer := ""
+	if plan.Reviewer {
+		reviewer = plan.Role
+	}
+	go o.watch(ctx, worker.WorkerID, reviewer, events, outcomes)
+	return nil
+}
+
+// watch consumes a worker's session events, refreshing its heartbeat, and
+// reports whether the session ended with a result and no error.
+func (o *Orchestrator) watch(ctx context.Context, workerID, reviewer string, events <-chan domain.NormalizedEvent, outcomes chan<- workerOutcome) {
+	var sawResult bool
+	var errMsg string
+	for ev := range events {
+		if !o.Chaos.DropHeartbeat() {
+			_ = o.Workers.WorkerRepo.UpdateHeartbeat(ctx, o.Workers.DB, workerID, time.Now().Unix())
+		}
+		switch ev.Type {
+		case mcp.EventResult:
+			sawResult = true
+		case mcp.EventError:
+			errMsg = string(ev.Payload)
+		}
+	}
+
+	// The run was cancelled (phase change, pause, or shutdown) and the
+	// session killed with it; release the worker's slot.
+	if ctx.Err() != nil {
+		_ = o.Workers.Shutdown(context.Background(), workerID)
+		return
+	}
+
+	outcome := workerOutcome{WorkerID: workerID, OK: sawResult && errMsg == "", Reviewer: reviewer}
+	switch {
+	case errMsg != "":
+		outcome.Reason = "session reported an error: " + errMsg
+	case !sawResult:
+		outcome.Reason = "session ended without a result"
+	}
+
+	if outcome.OK {
+		_ = o.Workers.Shutdown(ctx, workerID)
+	}
+
+	select {
+	case outcomes <- outcome:
+	case <-ctx.Done():
+	}
+}
+
+// await collects worker outcomes and advances the flow when all succeed.
+func (o *Orchestrator) await(ctx context.Context, state domain.FlowState, run *phaseRun, expected int, outcomes <-chan workerOutcome) {
+	defer o.finish(state.TaskID, run)
+
+	var failures []workerOutcome
+	for i := 0; i < expected; i++ {
+		select {
+		case out := <-outcomes:
+			if out.OK && out.Reviewer != "" {
+				out = o.checkScoreCard(ctx, state, out)
+			}
+			if !out.OK {
+				failures = append(failures, out)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	if len(failures) > 0 {
+		reasons := make(map[string]string, len(failures))
+		for _, f := range failures {
+			reasons[f.WorkerID] = f.Reason
+		}
+		o.audit(state.TaskID, "phase_incomplete", "warning", reasons)
+		return
+	}
+
+	// Advance under the orchestrator's context rather than the run's: the
+	// transition enters the next phase, which cancels this run before the
+	// remaining listeners are notified.
+	o.mu.Lock()
+	base := o.base
+	o.mu.Unlock()
+	trigger := domain.TransitionTrigger{Action: "advance", Actor: "orchestrator"}
+	if err := o.Engine.Advance(base, state.TaskID, trigger); err != nil {
+		o.audit(state.TaskID, "advance_failed", "warning", map[string]string{
+			"phase": string(state.CurrentPhase),
+			"error": err.Error(),
+		})
+		// The phase's work is done, so a gate refusal leaves the flow
+		// waiting on its blockers; block it until they clear.
+		if engErr, ok := err.(*domain.EngineError); ok && engErr.Code == domain.ErrPhaseGateFailed.Code {
+			_ = o.Engine.BlockOnGate(base, state.TaskID, engErr.Message)
+		}
+	}
+}
+
+// checkScoreCard fails a reviewer's outcome unless a score card from its
+// role was submitted in the flow's current round.
+func (o *Orchestrator) checkScoreCard(ctx context.Context, state domain.FlowState, out workerOutcome) workerOutcome {
+	cards, err := o.ScoreCards.ListByTask(ctx, o.Workers.DB, state.TaskID)
+	if err != nil {
+		out.OK, out.Reason = false, "list score cards: "+err.Error()
+		return out
+	}
+	for _, c := range cards {
+		if c.Reviewer == out.Reviewer && c.Round == state.Round {
+			return out
+		}
+	}
+	out.OK, out.Reason = false, fmt.Sprintf("reviewer %s submitted no score card", out.Reviewer)
+	return out
+}
+
+// finish forgets a run if it is still the task's current run.
+func (o *Orchestrator) finish(taskID string, run *phaseRun) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.runs[taskID] == run {
+		delete(o.runs, taskID)
+	}
+}
+
+// ActivePhase returns the phase currently being driven for a task, if any.
+func (o *Orchestrator) ActivePhase(taskID string) (domain.Phase, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	run, ok := o.runs[taskID]
+	if !ok {
+		return "", false
+	}
+	return run.phase, true
+}
+
+// writeDigest stores a worker's ContextDigest at path or, when path is
+// empty, under the shared workspace outside any per-task workspace the agent
+// edits, in DigestFormat.
+func (o *Orchestrator) writeDigest(workerID, path string, digest *domain.ContextDigest) (string, error) {
+	if path == "" {
+		name := "digest-" + workerID + ".json"
+		if o.DigestFormat == team.DigestMarkdown {
+			name = "digest-" + workerID + ".md"
+		}
+		path = filepath.Join(o.Workspace, ".threebody", digest.TaskID, name)
+	}
+	if err := team.WriteDigest(path, digest); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (o *Orchestrator) audit(taskID, action, severity string, detail map[string]string) {
+	data, _ := json.Marshal(detail)
+	now := time.Now()
+	_ = o.Workers.AuditRepo.Record(context.Background(), o.Workers.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-orch-%d", now.UnixNano()),
+		TaskID:       taskID,
+		Category:     "orchestrator",
+		Actor:        "orchestrator",
+		Action:       action,
+		DecisionJSON: string(data),
+		Severity:     severity,
+		CreatedAt:    now.Unix(),
+	})
+}
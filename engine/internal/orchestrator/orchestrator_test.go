@@ -0,0 +1,411 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/bridge"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/guard"
+	"github.com/anthropics/three-body-engine/internal/mcp"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/team"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+func lineCommand(line string) (string, []string) {
+	if runtime.GOOS == "windows" {
+		return "cmd", []string{"/C", "echo " + line}
+	}
+	return "sh", []string{"-c", "echo '" + line + "'"}
+}
+
+func newTestOrchestrator(t *testing.T, line string, plans map[domain.Phase][]WorkerPlan) *Orchestrator {
+	t.Helper()
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	reg := mcp.NewProviderRegistry()
+	cmd, args := lineCommand(line)
+	if err := reg.Register(mcp.ProviderSpec{Name: domain.ProviderClaude, Command: cmd, Args: args}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	sessions := mcp.NewSessionManager(reg)
+	t.Cleanup(sessions.StopAll)
+
+	engine := workflow.NewEngine(db)
+	gov := workflow.NewBudgetGovernor(db)
+	g := guard.NewGuard(db, gov, team.NewPermissionBroker(db), guard.GuardConfig{MaxRounds: 10, RateLimitPerMinute: 100})
+	b := bridge.NewBridge(sessions, g, gov, &store.CostDeltaRepo{}, &store.AuditRepo{}, db)
+
+	o := New(engine, team.NewWorkerManager(db, 5), b, team.NewDigestBuilder(db), plans, t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	o.Start(ctx)
+	t.Cleanup(o.Stop)
+	return o
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
+
+func TestOrchestrator_AdvancesWhenAllWorkersSucceed(t *testing.T) {
+	plans := map[domain.Phase][]WorkerPlan{
+		domain.PhaseB: {{Role: "explorer", Provider: domain.ProviderClaude, Count: 2, SoftTimeoutSec: 60, HardTimeoutSec: 120}},
+	}
+	o := newTestOrchestrator(t, `{"type":"result","result":"done"}`, plans)
+	ctx := context.Background()
+
+	if err := o.Engine.StartFlow(ctx, "task-1", 100.0); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	if err := o.Engine.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "human"}); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+
+	waitFor(t, func() bool {
+		state, err := o.Engine.GetState(ctx, "task-1")
+		return err == nil && state.CurrentPhase == domain.PhaseC
+	})
+
+	workers, err := o.Workers.WorkerRepo.ListByTask(ctx, o.Workers.DB, "task-1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(workers) != 2 {
+		t.Fatalf("expected 2 workers, got %d", len(workers))
+	}
+	waitFor(t, func() bool {
+		count, _ := o.Workers.WorkerRepo.CountActive(ctx, o.Workers.DB, "task-1")
+		return count == 0
+	})
+}
+
+func TestOrchestrator_AdvanceContextOutlivesRun(t *testing.T) {
+	plans := map[domain.Phase][]WorkerPlan{
+		domain.PhaseB: {{Role: "explorer", Provider: domain.ProviderClaude, Count: 1, SoftTimeoutSec: 60, HardTimeoutSec: 120}},
+	}
+	o := newTestOrchestrator(t, `{"type":"result","result":"done"}`, plans)
+	ctx := context.Background()
+
+	errs := make(chan error, 1)
+	o.Engine.AddListener(func(ctx context.Context, state domain.FlowState, from domain.Phase) {
+		if from == domain.PhaseB && state.CurrentPhase == domain.PhaseC {
+			errs <- ctx.Err()
+		}
+	})
+	o.Engine.StartFlow(ctx, "task-1", 100.0)
+	if err := o.Engine.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "human"}); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("listener ctx.Err() = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("flow did not advance to C")
+	}
+}
+
+func TestOrchestrator_EntersFirstPhaseOfStartedFlows(t *testing.T) {
+	plans := map[domain.Phase][]WorkerPlan{
+		domain.PhaseA: {{Role: "analyst", Provider: domain.ProviderClaude, Count: 1, SoftTimeoutSec: 60, HardTimeoutSec: 120}},
+	}
+	o := newTestOrchestrator(t, `{"type":"result","result":"done"}`, plans)
+	ctx := context.Background()
+
+	if err := o.Engine.StartFlow(ctx, "task-1", 100.0); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	if err := o.Engine.StartFlowWithOptions(ctx, "task-2", 100.0, workflow.FlowOptions{Queued: true}); err != nil {
+		t.Fatalf("StartFlow queued: %v", err)
+	}
+	if _, ok := o.ActivePhase("task-2"); ok {
+		t.Error("queued flow entered its phase")
+	}
+	if err := o.Engine.Activate(ctx, "task-2"); err != nil {
+		t.Fatalf("Activate: %v", err)
+	}
+
+	for _, id := range []string{"task-1", "task-2"} {
+		waitFor(t, func() bool {
+			state, err := o.Engine.GetState(ctx, id)
+			return err == nil && state.CurrentPhase == domain.PhaseB
+		})
+	}
+}
+
+func TestOrchestrator_RecoverEntersRunningFlows(t *testing.T) {
+	o := newTestOrchestrator(t, `{"type":"result","result":"done"}`, nil)
+	ctx := context.Background()
+
+	o.Engine.StartFlow(ctx, "task-1", 100.0)
+	o.Engine.StartFlowWithOptions(ctx, "task-2", 100.0, workflow.FlowOptions{Queued: true})
+	o.SetPlans(map[domain.Phase][]WorkerPlan{
+		domain.PhaseA: {{Role: "analyst", Provider: domain.ProviderClaude, Count: 1, SoftTimeoutSec: 60, HardTimeoutSec: 120}},
+	})
+
+	if n, err := o.Recover(ctx); err != nil || n != 1 {
+		t.Fatalf("Recover = %d, %v; want 1", n, err)
+	}
+	waitFor(t, func() bool {
+		state, err := o.Engine.GetState(ctx, "task-1")
+		return err == nil && state.CurrentPhase == domain.PhaseB
+	})
+	if state, _ := o.Engine.GetState(ctx, "task-2"); state.CurrentPhase != domain.PhaseA {
+		t.Errorf("queued flow phase = %q, want A", state.CurrentPhase)
+	}
+}
+
+func TestOrchestrator_PartitionsOwnership(t *testing.T) {
+	plans := map[domain.Phase][]WorkerPlan{
+		domain.PhaseB: {{Role: "coder", Provider: domain.ProviderClaude, Count: 2, SoftTimeoutSec: 60, HardTimeoutSec: 120, Partition: true}},
+	}
+	o := newTestOrchestrator(t, `{"type":"result","result":"done"}`, plans)
+	ctx := context.Background()
+	for _, f := range []string{"api/handler.go", "store/db.go"} {
+		path := filepath.Join(o.Workspace, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	o.Engine.StartFlow(ctx, "task-1", 100.0)
+	if err := o.Engine.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "human"}); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+
+	workers, err := o.Workers.WorkerRepo.ListByTask(ctx, o.Workers.DB, "task-1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	var got []string
+	for _, w := range workers {
+		got = append(got, strings.Join(w.FileOwnership, ","))
+	}
+	sort.Strings(got)
+	if want := []string{"api/", "store/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ownership = %v, want %v", got, want)
+	}
+}
+
+func TestOrchestrator_StaysInPhaseOnError(t *testing.T) {
+	plans := map[domain.Phase][]WorkerPlan{
+		domain.PhaseB: {{Role: "explorer", Provider: domain.ProviderClaude, Count: 1, SoftTimeoutSec: 60, HardTimeoutSec: 120}},
+	}
+	o := newTestOrchestrator(t, `{"type":"result","is_error":true,"result":"boom"}`, plans)
+	ctx := context.Background()
+
+	o.Engine.StartFlow(ctx, "task-1", 100.0)
+	o.Engine.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "human"})
+
+	audits := &store.AuditRepo{}
+	waitFor(t, func() bool {
+		records, _ := audits.ListByTask(ctx, o.Workers.DB, "task-1")
+		for _, r := range records {
+			if r.Action == "phase_incomplete" {
+				return true
+			}
+		}
+		return false
+	})
+
+	state, _ := o.Engine.GetState(ctx, "task-1")
+	if state.CurrentPhase != domain.PhaseB {
+		t.Errorf("Phase = %q, want B", state.CurrentPhase)
+	}
+}
+
+func TestOrchestrator_ShutsDownSpawnedWorkersWhenStartFails(t *testing.T) {
+	plans := map[domain.Phase][]WorkerPlan{
+		domain.PhaseB: {
+			{Role: "explorer", Provider: domain.ProviderClaude, Count: 1, SoftTimeoutSec: 60, HardTimeoutSec: 120},
+			{Role: "coder", Provider: domain.ProviderCodex, Count: 1, SoftTimeoutSec: 60, HardTimeoutSec: 120},
+		},
+	}
+	o := newTestOrchestrator(t, `{"type":"result","result":"done"}`, plans)
+	ctx := context.Background()
+
+	o.Engine.StartFlow(ctx, "task-1", 100.0)
+	state := domain.FlowState{TaskID: "task-1", CurrentPhase: domain.PhaseB, Status: domain.StatusRunning}
+	if err := o.EnterPhase(state); err == nil || !strings.Contains(err.Error(), "start coder worker") {
+		t.Fatalf("EnterPhase = %v, want a coder start error", err)
+	}
+
+	workers, err := o.Workers.WorkerRepo.ListByTask(ctx, o.Workers.DB, "task-1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(workers) != 2 {
+		t.Fatalf("expected 2 spawned workers, got %d", len(workers))
+	}
+	if count, _ := o.Workers.WorkerRepo.CountActive(ctx, o.Workers.DB, "task-1"); count != 0 {
+		t.Errorf("active workers = %d, want 0", count)
+	}
+	if _, ok := o.ActivePhase("task-1"); ok {
+		t.Error("failed run is still active")
+	}
+}
+
+// refusingGate blocks every flow.
+type refusingGate struct{}
+
+func (refusingGate) Name() string { return "refusing" }
+func (refusingGate) Evaluate(_ context.Context, _ domain.FlowState) (domain.GateDecision, error) {
+	return domain.GateDecision{Blockers: []string{"waiting on sign-off"}}, nil
+}
+
+func TestOrchestrator_BlocksFlowWhenGateRefuses(t *testing.T) {
+	plans := map[domain.Phase][]WorkerPlan{
+		domain.PhaseB: {{Role: "explorer", Provider: domain.ProviderClaude, Count: 1, SoftTimeoutSec: 60, HardTimeoutSec: 120}},
+	}
+	o := newTestOrchestrator(t, `{"type":"result","result":"done"}`, plans)
+	o.Engine.GateRegistry.Register(domain.PhaseB, refusingGate{})
+	ctx := context.Background()
+
+	o.Engine.StartFlow(ctx, "task-1", 100.0)
+	if err := o.Engine.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "human"}); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+
+	waitFor(t, func() bool {
+		state, err := o.Engine.GetState(ctx, "task-1")
+		return err == nil && state.Status == domain.StatusBlocked
+	})
+	event, err := o.Engine.EventRepo.LatestByType(ctx, o.Engine.DB, "task-1", "flow_blocked")
+	if err != nil || event == nil || !strings.Contains(event.PayloadJSON, workflow.BlockCauseGate) {
+		t.Errorf("flow_blocked event = %+v, %v; want a gate cause", event, err)
+	}
+}
+
+func TestOrchestrator_PhaseWithoutPlanIsIgnored(t *testing.T) {
+	o := newTestOrchestrator(t, `{"type":"result"}`, nil)
+	ctx := context.Background()
+
+	o.Engine.StartFlow(ctx, "task-1", 100.0)
+	if err := o.Engine.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "human"}); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if _, ok := o.ActivePhase("task-1"); ok {
+		t.Error("expected no active run for a phase without a plan")
+	}
+}
+
+// enterReview gives the started task-1 phase D and E artifacts and drives
+// the phase F plans directly.
+func enterReview(t *testing.T, o *Orchestrator) {
+	t.Helper()
+	ctx := context.Background()
+	artifacts := &store.ArtifactRepo{}
+	for _, a := range []domain.ArtifactRef{
+		{ID: "art-design", TaskID: "task-1", Phase: domain.PhaseD, Type: "design", Path: "design.md", Hash: "h1"},
+		{ID: "art-impl", TaskID: "task-1", Phase: domain.PhaseE, Type: "patch", Path: "impl.diff", Hash: "h2"},
+	} {
+		if _, err := artifacts.Create(ctx, o.Workers.DB, a); err != nil {
+			t.Fatalf("Create artifact: %v", err)
+		}
+	}
+	state := domain.FlowState{TaskID: "task-1", CurrentPhase: domain.PhaseF, Status: domain.StatusRunning}
+	if err := o.EnterPhase(state); err != nil {
+		t.Fatalf("EnterPhase: %v", err)
+	}
+}
+
+func reviewerPlans() map[domain.Phase][]WorkerPlan {
+	return map[domain.Phase][]WorkerPlan{
+		domain.PhaseF: {{Role: "primary", Provider: domain.ProviderClaude, Count: 1, SoftTimeoutSec: 60, HardTimeoutSec: 120, Reviewer: true}},
+	}
+}
+
+func TestOrchestrator_ReviewerIsReadOnly(t *testing.T) {
+	o := newTestOrchestrator(t, `{"type":"result","result":"done"}`, reviewerPlans())
+	ctx := context.Background()
+	o.Engine.StartFlow(ctx, "task-1", 100.0)
+	enterReview(t, o)
+
+	workers, err := o.Workers.WorkerRepo.ListByTask(ctx, o.Workers.DB, "task-1")
+	if err != nil || len(workers) != 1 {
+		t.Fatalf("ListByTask = %v, %v; want 1 worker", workers, err)
+	}
+	if len(workers[0].FileOwnership) != 0 {
+		t.Errorf("reviewer ownership = %v, want none", workers[0].FileOwnership)
+	}
+
+	data, err := os.ReadFile(filepath.Join(o.Workspace, ".threebody", "task-1", "digest-"+workers[0].WorkerID+".json"))
+	if err != nil {
+		t.Fatalf("read digest: %v", err)
+	}
+	digest := string(data)
+	for _, want := range []string{`"allowedCommands": [`, `"read"`, "impl.diff", `reviewer \"primary\"`, "/api/v1/flow/task-1/reviews"} {
+		if !strings.Contains(digest, want) {
+			t.Errorf("digest missing %q:\n%s", want, digest)
+		}
+	}
+	if strings.Contains(digest, "design.md") {
+		t.Errorf("digest lists a phase D artifact:\n%s", digest)
+	}
+}
+
+func TestOrchestrator_ReviewerWithoutScoreCardFails(t *testing.T) {
+	o := newTestOrchestrator(t, `{"type":"result","result":"done"}`, reviewerPlans())
+	ctx := context.Background()
+	o.Engine.StartFlow(ctx, "task-1", 100.0)
+	enterReview(t, o)
+
+	audits := &store.AuditRepo{}
+	waitFor(t, func() bool {
+		records, _ := audits.ListByTask(ctx, o.Workers.DB, "task-1")
+		for _, r := range records {
+			if r.Action == "phase_incomplete" && strings.Contains(r.DecisionJSON, "reviewer primary submitted no score card") {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func TestOrchestrator_ReviewerScoreCardCollected(t *testing.T) {
+	o := newTestOrchestrator(t, `{"type":"result","result":"done"}`, reviewerPlans())
+	ctx := context.Background()
+	card := domain.ScoreCard{ReviewID: "rev-1", TaskID: "task-1", Reviewer: "primary", Verdict: "pass", CreatedAt: time.Now().Unix()}
+	o.Engine.StartFlow(ctx, "task-1", 100.0)
+	if err := o.ScoreCards.Create(ctx, o.Workers.DB, card); err != nil {
+		t.Fatalf("Create score card: %v", err)
+	}
+	enterReview(t, o)
+
+	waitFor(t, func() bool {
+		_, active := o.ActivePhase("task-1")
+		return !active
+	})
+	records, _ := (&store.AuditRepo{}).ListByTask(ctx, o.Workers.DB, "task-1")
+	for _, r := range records {
+		if r.Action == "phase_incomplete" {
+			t.Errorf("phase incomplete: %s", r.DecisionJSON)
+		}
+	}
+}
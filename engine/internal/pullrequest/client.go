@@ -0,0 +1,119 @@
+package pullrequest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Request describes a pull request to open.
+type Request struct {
+	Head  string
+	Base  string
+	Title string
+	Body  string
+}
+
+// Client opens pull requests on a code host and returns their web URL.
+type Client interface {
+	Open(ctx context.Context, req Request) (string, error)
+}
+
+// GitHub opens pull requests through the GitHub REST API.
+type GitHub struct {
+	// APIURL defaults to https://api.github.com.
+	APIURL string
+	// Repo is the target repository as owner/name.
+	Repo  string
+	Token string
+	HTTP  *http.Client
+}
+
+// Open implements Client.
+func (g *GitHub) Open(ctx context.Context, req Request) (string, error) {
+	api := g.APIURL
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	body := map[string]string{"title": req.Title, "head": req.Head, "base": req.Base, "body": req.Body}
+	var resp struct {
+		HTMLURL string `json:"html_url"`
+	}
+	headers := map[string]string{
+		"Authorization": "Bearer " + g.Token,
+		"Accept":        "application/vnd.github+json",
+	}
+	if err := post(ctx, g.HTTP, api+"/repos/"+g.Repo+"/pulls", headers, body, &resp); err != nil {
+		return "", fmt.Errorf("github: %w", err)
+	}
+	return resp.HTMLURL, nil
+}
+
+// GitLab opens merge requests through the GitLab REST API.
+type GitLab struct {
+	// APIURL defaults to https://gitlab.com/api/v4.
+	APIURL string
+	// Project is the numeric ID or namespace/name path of the project.
+	Project string
+	Token   string
+	HTTP    *http.Client
+}
+
+// Open implements Client.
+func (g *GitLab) Open(ctx context.Context, req Request) (string, error) {
+	api := g.APIURL
+	if api == "" {
+		api = "https://gitlab.com/api/v4"
+	}
+	body := map[string]string{
+		"source_branch": req.Head,
+		"target_branch": req.Base,
+		"title":         req.Title,
+		"description":   req.Body,
+	}
+	var resp struct {
+		WebURL string `json:"web_url"`
+	}
+	endpoint := api + "/projects/" + url.PathEscape(g.Project) + "/merge_requests"
+	if err := post(ctx, g.HTTP, endpoint, map[string]string{"PRIVATE-TOKEN": g.Token}, body, &resp); err != nil {
+		return "", fmt.Errorf("gitlab: %w", err)
+	}
+	return resp.WebURL, nil
+}
+
+// post sends body as JSON and decodes a 2xx response into out.
+func post(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, body, out interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Package pullrequest pushes a flow's branch and opens a pull request on
+// GitHub or GitLab once the flow reaches phase G with a passing review
+// consensus.
+package pullrequest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/git"
+	"github.com/anthropics/three-body-engine/internal/review"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// Opener opens a pull request for each flow that enters phase G.
+type Opener struct {
+	DB            *sql.DB
+	Engine        *workflow.Engine
+	Git           *git.Manager
+	Client        Client
+	Consensus     *review.ConsensusEngine
+	TaskRepo      *store.TaskRepo
+	ScoreCardRepo *store.ScoreCardRepo
+	CostDeltaRepo *store.CostDeltaRepo
+	AuditRepo     *store.AuditRepo
+	// Remote is the git remote the branch is pushed to.
+	Remote string
+	// BaseBranch is the branch the pull request targets.
+	BaseBranch string
+}
+
+// New creates an Opener with default repositories and consensus weights.
+func New(db *sql.DB, engine *workflow.Engine, g *git.Manager, client Client) *Opener {
+	return &Opener{
+		DB:            db,
+		Engine:        engine,
+		Git:           g,
+		Client:        client,
+		Consensus:     review.NewConsensusEngine(review.DefaultWeights()),
+		TaskRepo:      &store.TaskRepo{},
+		ScoreCardRepo: &store.ScoreCardRepo{},
+		CostDeltaRepo: &store.CostDeltaRepo{},
+		AuditRepo:     &store.AuditRepo{},
+		Remote:        "origin",
+		BaseBranch:    "main",
+	}
+}
+
+// Start opens a pull request in the background whenever a flow moves from
+// phase F to phase G.
+func (o *Opener) Start(ctx context.Context) {
+	o.Engine.AddListener(func(_ context.Context, state domain.FlowState, from domain.Phase) {
+		if state.CurrentPhase != domain.PhaseG || from != domain.PhaseF {
+			return
+		}
+		go func() {
+			if _, err := o.Open(ctx, state.TaskID); err != nil {
+				o.audit(state.TaskID, "pr_failed", "warning", map[string]string{"error": err.Error()})
+			}
+		}()
+	})
+}
+
+// Open pushes the task's branch and opens a pull request if the review
+// consensus passes under the phase F verdict policy. It returns the pull request URL, or "" when the consensus
+// did not pass.
+func (o *Opener) Open(ctx context.Context, taskID string) (string, error) {
+	state, err := o.TaskRepo.GetByID(ctx, o.DB, taskID)
+	if err != nil {
+		return "", err
+	}
+	cards, err := o.ScoreCardRepo.ListByTask(ctx, o.DB, taskID)
+	if err != nil {
+		return "", err
+	}
+	consensus, err := o.Consensus.For(state.Consensus).EvaluatePhase(cards, domain.PhaseF)
+	if err != nil {
+		return "", fmt.Errorf("evaluate consensus: %w", err)
+	}
+	if consensus.FinalVerdict != "pass" {
+		o.audit(taskID, "pr_skipped", "info", map[string]string{"verdict": consensus.FinalVerdict})
+		return "", nil
+	}
+	deltas, err := o.CostDeltaRepo.ListByTask(ctx, o.DB, taskID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := o.Git.Push(ctx, taskID, o.Remote); err != nil {
+		return "", err
+	}
+	url, err := o.Client.Open(ctx, Request{
+		Head:  o.Git.Branch(taskID),
+		Base:  o.BaseBranch,
+		Title: "threebody: " + taskID,
+		Body:  Describe(*state, cards, consensus, deltas),
+	})
+	if err != nil {
+		return "", fmt.Errorf("open pull request: %w", err)
+	}
+
+	o.audit(taskID, "pr_opened", "info", map[string]string{"url": url})
+	if err := o.Engine.AppendEvent(ctx, taskID, domain.EventPullRequestOpened, domain.PullRequestOpenedPayload{
+		URL:    url,
+		Branch: o.Git.Branch(taskID),
+	}); err != nil {
+		return url, fmt.Errorf("record pull request: %w", err)
+	}
+	return url, nil
+}
+
+// Describe renders the pull request description: the consensus result, each
+// score card, and the cost summary.
+func Describe(state domain.FlowState, cards []domain.ScoreCard, consensus *domain.ConsensusResult, deltas []domain.CostDelta) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Opened by the Three-Body Engine for task `%s`.\n\n", state.TaskID)
+
+	fmt.Fprintf(&b, "## Consensus\n\n**%s** (weighted score %.2f)\n", consensus.FinalVerdict, consensus.WeightedScore)
+	for _, reason := range consensus.BlockReasons {
+		fmt.Fprintf(&b, "- %s\n", reason)
+	}
+
+	b.WriteString("\n## Score cards\n\n")
+	b.WriteString("| Reviewer | Verdict | Correctness | Security | Maintainability | Cost | Delivery risk | Issues |\n")
+	b.WriteString("|---|---|---|---|---|---|---|---|\n")
+	for _, c := range cards {
+		fmt.Fprintf(&b, "| %s | %s | %d | %d | %d | %d | %d | %d |\n", c.Reviewer, c.Verdict,
+			c.Scores.Correctness, c.Scores.Security, c.Scores.Maintainability, c.Scores.Cost, c.Scores.DeliveryRisk, len(c.Issues))
+	}
+	for _, c := range cards {
+		for _, issue := range c.Issues {
+			fmt.Fprintf(&b, "- **%s** (%s, %s): %s\n", issue.Severity, c.Reviewer, issue.Location, issue.Description)
+		}
+	}
+
+	byProvider := make(map[domain.Provider]float64)
+	var providers []domain.Provider
+	var inTokens, outTokens int64
+	for _, d := range deltas {
+		if _, ok := byProvider[d.Provider]; !ok {
+			providers = append(providers, d.Provider)
+		}
+		byProvider[d.Provider] += d.AmountUSD
+		inTokens += d.InputTokens
+		outTokens += d.OutputTokens
+	}
+	fmt.Fprintf(&b, "\n## Cost\n\n$%.2f of $%.2f budget, %d input / %d output tokens\n",
+		state.BudgetUsedUSD, state.BudgetCapUSD, inTokens, outTokens)
+	for _, p := range providers {
+		fmt.Fprintf(&b, "- %s: $%.2f\n", p, byProvider[p])
+	}
+	return b.String()
+}
+
+func (o *Opener) audit(taskID, action, severity string, detail map[string]string) {
+	data, _ := json.Marshal(detail)
+	now := time.Now()
+	_ = o.AuditRepo.Record(context.Background(), o.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-pr-%d", now.UnixNano()),
+		TaskID:       taskID,
+		Category:     "pull_request",
+		Actor:        "system",
+		Action:       action,
+		DecisionJSON: string(data),
+		Severity:     severity,
+		CreatedAt:    now.Unix(),
+	})
+}
@@ -0,0 +1,153 @@
+package pullrequest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/git"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+func run(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
+
+// setup creates a repository with a bare "origin" remote, a flow "t1" working
+// in it, and an Opener talking to a fake GitHub API that records requests.
+func setup(t *testing.T) (*Opener, *[]map[string]string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	remote := t.TempDir()
+	run(t, remote, "init", "-q", "--bare")
+	repo := t.TempDir()
+	run(t, repo, "init", "-q")
+	run(t, repo, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init")
+	run(t, repo, "remote", "add", "origin", remote)
+
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	engine := workflow.NewEngine(db)
+	if err := engine.StartFlowWithOptions(context.Background(), "t1", 10, workflow.FlowOptions{Workspace: repo}); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+
+	var requests []map[string]string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/app/pulls" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"html_url": "https://github.com/acme/app/pull/1"})
+	}))
+	t.Cleanup(api.Close)
+
+	client := &GitHub{APIURL: api.URL, Repo: "acme/app", Token: "secret"}
+	return New(db, engine, git.New(db, repo), client), &requests, remote
+}
+
+func addCard(t *testing.T, o *Opener, id string, score int, verdict string) {
+	t.Helper()
+	card := domain.ScoreCard{
+		ReviewID: id, TaskID: "t1", Reviewer: "primary", Verdict: verdict, CreatedAt: 1,
+		Scores: domain.Scores{Correctness: score, Security: score, Maintainability: score, Cost: score, DeliveryRisk: score},
+		Issues: []domain.Issue{}, Alternatives: []string{},
+	}
+	if err := o.ScoreCardRepo.Create(context.Background(), o.DB, card); err != nil {
+		t.Fatalf("create card: %v", err)
+	}
+}
+
+func TestOpen_PassingConsensus(t *testing.T) {
+	o, requests, remote := setup(t)
+	ctx := context.Background()
+	addCard(t, o, "r1", 5, "pass")
+	if err := o.CostDeltaRepo.Create(ctx, o.DB, "t1", domain.CostDelta{AmountUSD: 1.25, Provider: domain.ProviderClaude, InputTokens: 100, CreatedAt: 1}); err != nil {
+		t.Fatalf("create cost delta: %v", err)
+	}
+
+	url, err := o.Open(ctx, "t1")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if url != "https://github.com/acme/app/pull/1" {
+		t.Errorf("url = %q", url)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("API requests = %d, want 1", len(*requests))
+	}
+	req := (*requests)[0]
+	if req["head"] != "threebody/t1" || req["base"] != "main" {
+		t.Errorf("head/base = %q/%q", req["head"], req["base"])
+	}
+	for _, want := range []string{"**pass**", "| primary | pass | 5 |", "claude: $1.25"} {
+		if !strings.Contains(req["body"], want) {
+			t.Errorf("body missing %q:\n%s", want, req["body"])
+		}
+	}
+
+	// The branch reached the remote.
+	if out, err := exec.Command("git", "-C", remote, "rev-parse", "--verify", "refs/heads/threebody/t1").CombinedOutput(); err != nil {
+		t.Errorf("branch not pushed: %v: %s", err, out)
+	}
+
+	events, err := (&store.EventRepo{}).ListByTask(ctx, o.DB, "t1", 0)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if last := events[len(events)-1]; last.EventType != "pull_request_opened" {
+		t.Errorf("last event = %q, want pull_request_opened", last.EventType)
+	}
+}
+
+func TestOpen_FailingConsensusSkips(t *testing.T) {
+	o, requests, _ := setup(t)
+	addCard(t, o, "r1", 2, "fail")
+
+	url, err := o.Open(context.Background(), "t1")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if url != "" || len(*requests) != 0 {
+		t.Errorf("url = %q, requests = %d; want no pull request", url, len(*requests))
+	}
+}
+
+func TestGitLab_Open(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/projects/acme%2Fapp/merge_requests" || r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			http.Error(w, "unexpected request "+r.URL.EscapedPath(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"web_url": "https://gitlab.com/acme/app/-/merge_requests/1"})
+	}))
+	defer api.Close()
+
+	c := &GitLab{APIURL: api.URL, Project: "acme/app", Token: "secret"}
+	url, err := c.Open(context.Background(), Request{Head: "threebody/t1", Base: "main", Title: "t"})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if url != "https://gitlab.com/acme/app/-/merge_requests/1" {
+		t.Errorf("url = %q", url)
+	}
+}
@@ -0,0 +1,171 @@
+// Package purge erases everything the engine holds about a finished task —
+// its rows in every table, artifact content no other task shares, its
+// workspace and workspace archives, its context digests, and its rows in
+// retention archives — for data deletion requests. The purge itself is
+// recorded as the first entry of a fresh audit chain for the task.
+package purge
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/anthropics/three-body-engine/internal/artifact"
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/retention"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workspace"
+)
+
+// Report lists what a purge removed or, for a dry run, would remove.
+type Report struct {
+	TaskID string `json:"taskId"`
+	DryRun bool   `json:"dryRun"`
+	// Rows counts the task's rows per table.
+	Rows map[string]int64 `json:"rows"`
+	// Blobs are the hashes of artifact content only this task referenced.
+	Blobs []string `json:"blobs,omitempty"`
+	// Files are workspaces, workspace archives, and digest directories.
+	Files []string `json:"files,omitempty"`
+	// Archives counts the task's rows in each retention archive.
+	Archives map[string]int `json:"archives,omitempty"`
+	// AuditID is the audit record of the purge; empty for a dry run.
+	AuditID string `json:"auditId,omitempty"`
+}
+
+// Purger erases tasks.
+type Purger struct {
+	DB        *sql.DB
+	TaskRepo  *store.TaskRepo
+	PurgeRepo *store.PurgeRepo
+	AuditRepo *store.AuditRepo
+	// Blobs, when set, is where artifact content is stored.
+	Blobs *artifact.Blobs
+	// Workspaces, when set, manages per-task workspaces and their archives.
+	Workspaces *workspace.Manager
+	// Workspace is the shared workspace, holding digests under
+	// .threebody/<task>.
+	Workspace string
+	// ArchiveDir holds retention archives.
+	ArchiveDir string
+	Clock      clock.Clock
+}
+
+// NewPurger creates a Purger with default repositories.
+func NewPurger(db *sql.DB) *Purger {
+	return &Purger{
+		DB:        db,
+		TaskRepo:  &store.TaskRepo{},
+		PurgeRepo: &store.PurgeRepo{},
+		AuditRepo: &store.AuditRepo{},
+	}
+}
+
+// Purge erases taskID on behalf of actor, or with dryRun set reports what
+// it would erase. Only completed and failed flows can be purged. Files go
+// first, so a purge that fails part way can be run again.
+func (p *Purger) Purge(ctx context.Context, taskID, actor string, dryRun bool) (*Report, error) {
+	state, err := p.TaskRepo.GetByID(ctx, p.DB, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun && state.Status != domain.StatusDone && state.Status != domain.StatusFailed {
+		return nil, domain.ErrFlowActive
+	}
+
+	report := &Report{TaskID: taskID, DryRun: dryRun}
+	if report.Rows, err = p.PurgeRepo.Count(ctx, p.DB, taskID); err != nil {
+		return nil, err
+	}
+	if p.Blobs != nil {
+		if report.Blobs, err = p.PurgeRepo.ExclusiveBlobs(ctx, p.DB, taskID); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.purgeFiles(ctx, state, report); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin purge: %w", err)
+	}
+	defer tx.Rollback()
+	if report.Rows, err = p.PurgeRepo.DeleteTx(ctx, tx, taskID); err != nil {
+		return nil, err
+	}
+	now := clock.Or(p.Clock).Now()
+	report.AuditID = fmt.Sprintf("aud-purge-%d", now.UnixNano())
+	request, _ := json.Marshal(map[string]string{"task_id": taskID})
+	decision, _ := json.Marshal(report)
+	if err := p.AuditRepo.RecordTx(ctx, tx, domain.AuditRecord{
+		ID:           report.AuditID,
+		TaskID:       taskID,
+		Category:     "purge",
+		Actor:        actor,
+		Action:       "task_purged",
+		RequestJSON:  string(request),
+		DecisionJSON: string(decision),
+		Severity:     "warning",
+		CreatedAt:    now.Unix(),
+	}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit purge: %w", err)
+	}
+	return report, nil
+}
+
+// purgeFiles removes, or for a dry run lists, the task's files outside the
+// database.
+func (p *Purger) purgeFiles(ctx context.Context, state *domain.FlowState, report *Report) error {
+	for _, hash := range report.Blobs {
+		if report.DryRun {
+			continue
+		}
+		if err := p.Blobs.Remove(hash); err != nil {
+			return err
+		}
+	}
+
+	if p.Workspaces != nil {
+		files, err := p.Workspaces.Purge(ctx, state.TaskID, state.Workspace, report.DryRun)
+		report.Files = append(report.Files, files...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.Workspace != "" && state.TaskID == filepath.Base(state.TaskID) {
+		digests := filepath.Join(p.Workspace, ".threebody", state.TaskID)
+		if _, err := os.Stat(digests); err == nil {
+			report.Files = append(report.Files, digests)
+			if !report.DryRun {
+				if err := os.RemoveAll(digests); err != nil {
+					return fmt.Errorf("remove digests: %w", err)
+				}
+			}
+		}
+	}
+	sort.Strings(report.Files)
+
+	if p.ArchiveDir != "" {
+		archives, err := retention.PurgeArchives(p.ArchiveDir, state.TaskID, report.DryRun)
+		if len(archives) > 0 {
+			report.Archives = archives
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package purge
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/artifact"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// setup creates a completed flow t1 and a running flow t2 with rows and
+// files of each kind a purge removes.
+func setup(t *testing.T) (*Purger, *sql.DB) {
+	t.Helper()
+	db, err := store.NewDB(store.MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+
+	p := NewPurger(db)
+	p.Blobs = artifact.NewBlobs(filepath.Join(t.TempDir(), "blobs"))
+	p.Workspace = t.TempDir()
+	p.ArchiveDir = t.TempDir()
+
+	shared, _ := p.Blobs.Put([]byte("shared"))
+	for id, status := range map[string]domain.FlowStatus{"t1": domain.StatusDone, "t2": domain.StatusRunning} {
+		tx, _ := db.Begin()
+		if err := (&store.TaskRepo{}).CreateTx(ctx, tx, domain.FlowState{TaskID: id, CurrentPhase: domain.PhaseA, Status: status}); err != nil {
+			t.Fatalf("CreateTx: %v", err)
+		}
+		tx.Commit()
+		(&store.AuditRepo{}).Record(ctx, db, domain.AuditRecord{ID: "aud-" + id, TaskID: id, Category: "c", Action: "a", RequestJSON: `{"name":"Ada"}`})
+		(&store.ArtifactRepo{}).Create(ctx, db, domain.ArtifactRef{ID: "shared-" + id, TaskID: id, Type: "doc", Path: "a.md", Hash: shared})
+	}
+	own, _ := p.Blobs.Put([]byte("t1 only"))
+	(&store.ArtifactRepo{}).Create(ctx, db, domain.ArtifactRef{ID: "own", TaskID: "t1", Type: "doc", Path: "b.md", Hash: own})
+
+	os.MkdirAll(filepath.Join(p.Workspace, ".threebody", "t1"), 0o755)
+	os.WriteFile(filepath.Join(p.Workspace, ".threebody", "t1", "digest-w1.json"), []byte("{}"), 0o644)
+
+	f, _ := os.Create(filepath.Join(p.ArchiveDir, "audit_records-1.jsonl.gz"))
+	gz := gzip.NewWriter(f)
+	fmt.Fprintln(gz, `{"id":"old","task_id":"t1"}`)
+	fmt.Fprintln(gz, `{"id":"old","task_id":"t2"}`)
+	gz.Close()
+	f.Close()
+	return p, db
+}
+
+func TestPurge_DryRun(t *testing.T) {
+	p, db := setup(t)
+	ctx := context.Background()
+
+	report, err := p.Purge(ctx, "t1", "dpo", true)
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if !report.DryRun || report.Rows["tasks"] != 1 || report.Rows["artifacts"] != 2 ||
+		len(report.Blobs) != 1 || len(report.Files) != 1 || len(report.Archives) != 1 || report.AuditID != "" {
+		t.Errorf("report = %+v", report)
+	}
+	if _, err := (&store.TaskRepo{}).GetByID(ctx, db, "t1"); err != nil {
+		t.Errorf("dry run removed the flow: %v", err)
+	}
+	if f, err := p.Blobs.Open(report.Blobs[0]); err != nil {
+		t.Errorf("dry run removed a blob: %v", err)
+	} else {
+		f.Close()
+	}
+
+	// A dry run is allowed for an active flow; a purge is not.
+	if _, err := p.Purge(ctx, "t2", "dpo", true); err != nil {
+		t.Errorf("dry run of an active flow = %v", err)
+	}
+	if _, err := p.Purge(ctx, "t2", "dpo", false); err != domain.ErrFlowActive {
+		t.Errorf("purge of an active flow = %v, want ErrFlowActive", err)
+	}
+	if _, err := p.Purge(ctx, "missing", "dpo", true); err != domain.ErrFlowNotFound {
+		t.Errorf("purge of an unknown flow = %v, want ErrFlowNotFound", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	p, db := setup(t)
+	ctx := context.Background()
+
+	report, err := p.Purge(ctx, "t1", "dpo", false)
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if report.Rows["tasks"] != 1 || report.AuditID == "" {
+		t.Errorf("report = %+v", report)
+	}
+	if _, err := (&store.TaskRepo{}).GetByID(ctx, db, "t1"); err != domain.ErrFlowNotFound {
+		t.Errorf("GetByID after purge = %v, want ErrFlowNotFound", err)
+	}
+	if _, err := p.Blobs.Open(report.Blobs[0]); err != domain.ErrArtifactNotFound {
+		t.Errorf("exclusive blob after purge = %v, want ErrArtifactNotFound", err)
+	}
+	shared, _ := (&store.ArtifactRepo{}).Latest(ctx, db, "t2", "a.md")
+	if f, err := p.Blobs.Open(shared.Hash); err != nil {
+		t.Errorf("shared blob was removed: %v", err)
+	} else {
+		f.Close()
+	}
+	if _, err := os.Stat(report.Files[0]); !os.IsNotExist(err) {
+		t.Errorf("digests still exist: %v", err)
+	}
+	if t2, _ := p.Purge(ctx, "t2", "dpo", true); len(t2.Archives) != 1 {
+		t.Errorf("t2's archived rows = %v, want the one row kept", t2.Archives)
+	}
+	if t1, _ := p.Purge(ctx, "t1", "dpo", true); t1 != nil {
+		t.Errorf("second purge found %+v", t1)
+	}
+
+	// Only the purge's own audit record remains, on a fresh chain.
+	audits, _ := (&store.AuditRepo{}).ListByTask(ctx, db, "t1")
+	if len(audits) != 1 || audits[0].ID != report.AuditID || audits[0].Actor != "dpo" || audits[0].Seq != 1 {
+		t.Fatalf("audits = %+v", audits)
+	}
+	if v, err := (&store.AuditRepo{}).Verify(ctx, db, "t1"); err != nil || !v.Valid {
+		t.Errorf("Verify = %+v, %v", v, err)
+	}
+}
@@ -0,0 +1,192 @@
+// Package redact masks secrets in payloads before they are persisted or
+// streamed. A Redactor applies regex rules that match a whole secret, such as
+// a provider API key, and keyword rules that mask the value assigned to a
+// key whose name contains the keyword, such as PASSWORD=... in a .env file or
+// "api_key": "..." in JSON.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces every redacted secret.
+const Mask = "[REDACTED]"
+
+// DefaultPatterns match well-known credential formats.
+var DefaultPatterns = map[string]string{
+	"anthropic_key": `sk-ant-[A-Za-z0-9_-]{20,}`,
+	"openai_key":    `sk-(?:proj-)?[A-Za-z0-9]{20,}`,
+	"aws_key_id":    `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	"github_token":  `\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	"slack_token":   `\bxox[abposr]-[A-Za-z0-9-]{10,}`,
+	"bearer":        `(?i)\bbearer\s+[A-Za-z0-9._~+/-]{16,}=*`,
+	"private_key":   `(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`,
+}
+
+// DefaultKeywords name keys whose values are secrets.
+var DefaultKeywords = []string{"password", "passwd", "secret", "api_key", "apikey", "access_key", "private_key", "token"}
+
+// rule is one compiled pattern. Keyword rules keep the key and separator,
+// their first submatch, and mask only the value.
+type rule struct {
+	name    string
+	re      *regexp.Regexp
+	keyword bool
+}
+
+// Stats counts redactions since start.
+type Stats struct {
+	Total  int64            `json:"total"`
+	ByRule map[string]int64 `json:"byRule"`
+}
+
+// Redactor masks secrets matched by its rules. A nil Redactor redacts
+// nothing. It is safe for concurrent use.
+type Redactor struct {
+	rules []rule
+
+	mu       sync.Mutex
+	counts   map[string]int64
+	literals []string
+}
+
+// New compiles a Redactor from named regex patterns and keywords. Rules are
+// applied in name order, patterns before keywords.
+func New(patterns map[string]string, keywords []string) (*Redactor, error) {
+	r := &Redactor{counts: make(map[string]int64)}
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		re, err := regexp.Compile(patterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %s: %w", name, err)
+		}
+		r.rules = append(r.rules, rule{name: name, re: re})
+	}
+	for _, kw := range keywords {
+		if kw == "" {
+			continue
+		}
+		// The key may carry a prefix or suffix ("DB_PASSWORD", "secret_key")
+		// and be quoted; the value runs to whitespace, a quote, or a delimiter.
+		re, err := regexp.Compile(`(?i)(\b[A-Za-z0-9_.-]*` + regexp.QuoteMeta(kw) + `[A-Za-z0-9_.-]*\\?"?\s*[:=]\s*\\?"?)([^\s"'\\,;}&]{4,})`)
+		if err != nil {
+			return nil, fmt.Errorf("redaction keyword %s: %w", kw, err)
+		}
+		r.rules = append(r.rules, rule{name: "keyword:" + strings.ToLower(kw), re: re, keyword: true})
+	}
+	return r, nil
+}
+
+// Default returns a Redactor with the default patterns and keywords.
+func Default() *Redactor {
+	r, err := New(DefaultPatterns, DefaultKeywords)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// String returns s with every secret replaced by Mask.
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	var hits map[string]int64
+	r.mu.Lock()
+	literals := r.literals
+	r.mu.Unlock()
+	for _, lit := range literals {
+		if n := strings.Count(s, lit); n > 0 {
+			s = strings.ReplaceAll(s, lit, Mask)
+			if hits == nil {
+				hits = make(map[string]int64)
+			}
+			hits["secret"] += int64(n)
+		}
+	}
+	for _, ru := range r.rules {
+		n := 0
+		s = ru.re.ReplaceAllStringFunc(s, func(m string) string {
+			if !ru.keyword {
+				n++
+				return Mask
+			}
+			sub := ru.re.FindStringSubmatch(m)
+			if sub[2] == Mask || literal(sub[2]) {
+				return m
+			}
+			n++
+			return sub[1] + Mask
+		})
+		if n > 0 {
+			if hits == nil {
+				hits = make(map[string]int64)
+			}
+			hits[ru.name] += int64(n)
+		}
+	}
+	if hits != nil {
+		r.mu.Lock()
+		for name, n := range hits {
+			r.counts[name] += n
+		}
+		r.mu.Unlock()
+	}
+	return s
+}
+
+// minSecretLen is the shortest value AddSecret masks; shorter values would
+// mangle ordinary text.
+const minSecretLen = 8
+
+// AddSecret masks every later occurrence of value under the rule "secret",
+// for example a credential resolved from the secrets store.
+func (r *Redactor) AddSecret(value string) {
+	if r == nil || len(value) < minSecretLen {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, lit := range r.literals {
+		if lit == value {
+			return
+		}
+	}
+	// Copy on write: String reads the slice without holding mu.
+	literals := make([]string, len(r.literals), len(r.literals)+1)
+	copy(literals, r.literals)
+	r.literals = append(literals, value)
+}
+
+// literal reports whether a keyword value is a number, boolean, or null,
+// such as "max_tokens": 4096, which is never a secret.
+func literal(v string) bool {
+	switch strings.ToLower(v) {
+	case "true", "false", "null":
+		return true
+	}
+	return strings.Trim(v, "0123456789.-") == ""
+}
+
+// Stats returns a snapshot of the redaction counts.
+func (r *Redactor) Stats() Stats {
+	stats := Stats{ByRule: map[string]int64{}}
+	if r == nil {
+		return stats
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, n := range r.counts {
+		stats.ByRule[name] = n
+		stats.Total += n
+	}
+	return stats
+}
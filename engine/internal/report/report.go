@@ -0,0 +1,374 @@
+// Package report summarizes a flow's run — phases, rounds, blockers,
+// budget, reviews, issues, risks, and artifacts — as Markdown or HTML, and
+// stores the summary of every finished flow as an artifact.
+package report
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/artifact"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// ArtifactPath is the path a flow's report is stored under.
+const ArtifactPath = "report.md"
+
+// Report is everything recorded for one flow that its summary shows.
+type Report struct {
+	Flow       domain.FlowState
+	Phases     []domain.PhaseDuration
+	Rounds     []domain.ReviewRound
+	Blockers   []domain.GateRecord
+	ScoreCards []domain.ScoreCard
+	Issues     []domain.ReviewIssue
+	Risks      []domain.Risk
+	Costs      *domain.CostBreakdown
+	Artifacts  []domain.ArtifactRef
+}
+
+// Generator builds reports and stores the report of every flow that
+// finishes.
+type Generator struct {
+	DB     *sql.DB
+	Engine *workflow.Engine
+	// Blobs, when set, stores the content of generated reports.
+	Blobs         *artifact.Blobs
+	ArtifactRepo  *store.ArtifactRepo
+	ScoreCardRepo *store.ScoreCardRepo
+	IssueRepo     *store.IssueRepo
+	RiskRepo      *store.RiskRepo
+	CostDeltaRepo *store.CostDeltaRepo
+}
+
+// New creates a Generator with default repositories.
+func New(db *sql.DB, engine *workflow.Engine, blobs *artifact.Blobs) *Generator {
+	return &Generator{
+		DB:            db,
+		Engine:        engine,
+		Blobs:         blobs,
+		ArtifactRepo:  &store.ArtifactRepo{},
+		ScoreCardRepo: &store.ScoreCardRepo{},
+		IssueRepo:     &store.IssueRepo{},
+		RiskRepo:      &store.RiskRepo{},
+		CostDeltaRepo: &store.CostDeltaRepo{},
+	}
+}
+
+// Build gathers a flow's report from the database.
+func (g *Generator) Build(ctx context.Context, taskID string) (*Report, error) {
+	e := g.Engine
+	db := e.Reader()
+	state, err := e.GetState(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{Flow: *state}
+	if r.Phases, err = e.DurationRepo.ListByTask(ctx, db, taskID, time.Now().Unix()); err != nil {
+		return nil, err
+	}
+	if r.Rounds, err = e.RoundRepo.ListByTask(ctx, db, taskID); err != nil {
+		return nil, err
+	}
+	gates, err := e.GateRepo.ListByTask(ctx, db, taskID, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	// Gate decisions are listed newest first; the report reads oldest first.
+	for i := len(gates) - 1; i >= 0; i-- {
+		if !gates[i].Allow {
+			r.Blockers = append(r.Blockers, gates[i])
+		}
+	}
+	if r.ScoreCards, err = g.ScoreCardRepo.ListByTask(ctx, db, taskID); err != nil {
+		return nil, err
+	}
+	if r.Issues, err = g.IssueRepo.ListByTask(ctx, db, taskID, ""); err != nil {
+		return nil, err
+	}
+	if r.Risks, err = g.RiskRepo.ListByTask(ctx, db, taskID, ""); err != nil {
+		return nil, err
+	}
+	if r.Costs, err = g.CostDeltaRepo.Breakdown(ctx, db, "provider", store.CostFilter{TaskID: taskID}); err != nil {
+		return nil, err
+	}
+	artifacts, err := g.ArtifactRepo.ListLatest(ctx, db, taskID)
+	if err != nil {
+		return nil, err
+	}
+	// The report does not list itself, so regenerating it is stable.
+	for _, a := range artifacts {
+		if a.Path != ArtifactPath {
+			r.Artifacts = append(r.Artifacts, a)
+		}
+	}
+	return r, nil
+}
+
+// Generate builds a flow's report and stores its Markdown as the flow's
+// report.md artifact, returning the stored artifact. Regenerating an
+// unchanged report returns the stored version.
+func (g *Generator) Generate(ctx context.Context, taskID string) (*domain.ArtifactRef, error) {
+	r, err := g.Build(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	content := []byte(r.Markdown())
+	if g.Blobs != nil {
+		if _, err := g.Blobs.Put(content); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	id := fmt.Sprintf("art-report-%d", now.UnixNano())
+	ref, err := g.ArtifactRepo.Create(ctx, g.DB, domain.ArtifactRef{
+		ID:        id,
+		TaskID:    taskID,
+		Phase:     r.Flow.CurrentPhase,
+		Type:      "report",
+		Path:      ArtifactPath,
+		Hash:      artifact.Hash(content),
+		CreatedAt: now.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ref.ID == id {
+		_ = g.Engine.AppendEvent(ctx, taskID, domain.EventReportGenerated, domain.ReportGeneratedPayload{
+			Artifact: ref.ID,
+			Version:  ref.Version,
+		})
+	}
+	return ref, nil
+}
+
+// Start generates the report of each flow that completes or fails, first
+// for finished flows that have none, then as flows finish, until ctx is
+// cancelled.
+func (g *Generator) Start(ctx context.Context, bus *eventbus.Bus) {
+	signals, unsubscribe := bus.Subscribe(64)
+	go func() {
+		defer unsubscribe()
+		for _, status := range []domain.FlowStatus{domain.StatusDone, domain.StatusFailed} {
+			finished, err := g.Engine.TaskRepo.ListByStatus(ctx, g.DB, status)
+			if err != nil {
+				continue
+			}
+			for _, state := range finished {
+				g.generateOnce(ctx, state.TaskID)
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				if sig.Topic != eventbus.TopicFlowUpdated {
+					continue
+				}
+				state, err := g.Engine.GetState(ctx, sig.TaskID)
+				if err == nil && (state.Status == domain.StatusDone || state.Status == domain.StatusFailed) {
+					g.generateOnce(ctx, sig.TaskID)
+				}
+			}
+		}
+	}()
+}
+
+// generateOnce generates a flow's report unless it already has one.
+func (g *Generator) generateOnce(ctx context.Context, taskID string) {
+	existing, err := g.ArtifactRepo.Latest(ctx, g.DB, taskID, ArtifactPath)
+	if err != nil || existing != nil {
+		return
+	}
+	_, _ = g.Generate(ctx, taskID)
+}
+
+// Markdown renders the report.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	f := r.Flow
+	fmt.Fprintf(&b, "# Run report: %s\n\n", f.TaskID)
+	fmt.Fprintf(&b, "- Status: %s in phase %s, round %d\n", f.Status, f.CurrentPhase, f.Round)
+	fmt.Fprintf(&b, "- Namespace: %s\n", f.Namespace)
+	fmt.Fprintf(&b, "- Budget: $%.2f of $%.2f\n", f.BudgetUsedUSD, f.BudgetCapUSD)
+
+	b.WriteString("\n## Phases\n\n")
+	if len(r.Phases) == 0 {
+		b.WriteString("No phase was entered.\n")
+	} else {
+		b.WriteString("| Phase | Entered | Duration | SLA |\n|---|---|---|---|\n")
+		for _, p := range r.Phases {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", p.Phase, formatTime(p.EnteredAt), stayDuration(p), slaCell(p))
+		}
+	}
+
+	b.WriteString("\n## Rounds\n\n")
+	for _, round := range r.Rounds {
+		fmt.Fprintf(&b, "- Round %d from phase %s, started %s", round.Round, round.Phase, formatTime(round.StartedAt))
+		if round.EndedAt > 0 {
+			fmt.Fprintf(&b, ", ended %s by %s", formatTime(round.EndedAt), round.Outcome)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n## Blockers\n\n")
+	if len(r.Blockers) == 0 {
+		b.WriteString("No gate refused to advance.\n")
+	}
+	for _, g := range r.Blockers {
+		fmt.Fprintf(&b, "- %s, phase %s, gate %s: %s\n", formatTime(g.CreatedAt), g.Phase, g.Gate, strings.Join(g.Blockers, "; "))
+	}
+
+	b.WriteString("\n## Reviews\n\n")
+	if len(r.ScoreCards) == 0 {
+		b.WriteString("No score cards.\n")
+	} else {
+		b.WriteString("| Reviewer | Round | Verdict | Correctness | Security | Maintainability | Cost | Delivery risk |\n")
+		b.WriteString("|---|---|---|---|---|---|---|---|\n")
+		for _, c := range r.ScoreCards {
+			s := c.Scores
+			fmt.Fprintf(&b, "| %s | %d | %s | %d | %d | %d | %d | %d |\n", c.Reviewer, c.Round, c.Verdict,
+				s.Correctness, s.Security, s.Maintainability, s.Cost, s.DeliveryRisk)
+		}
+	}
+
+	b.WriteString("\n## Issues\n\n")
+	if len(r.Issues) == 0 {
+		b.WriteString("No issues raised.\n")
+	}
+	for _, i := range r.Issues {
+		fmt.Fprintf(&b, "- **%s** %s (%s, %s): %s\n", i.Severity, i.Status, i.Reviewer, i.Location, i.Description)
+	}
+
+	b.WriteString("\n## Risks\n\n")
+	if len(r.Risks) == 0 {
+		b.WriteString("No risks recorded.\n")
+	}
+	for _, k := range r.Risks {
+		fmt.Fprintf(&b, "- **%s** %s: %s\n", k.Severity, k.Status, k.Text)
+	}
+
+	b.WriteString("\n## Cost\n\n")
+	if r.Costs == nil || len(r.Costs.Groups) == 0 {
+		b.WriteString("No cost recorded.\n")
+	} else {
+		for _, c := range r.Costs.Groups {
+			fmt.Fprintf(&b, "- %s: $%.2f, %d input / %d output tokens\n", costKey(c.Key), c.AmountUSD, c.InputTokens, c.OutputTokens)
+		}
+	}
+
+	b.WriteString("\n## Artifacts\n\n")
+	if len(r.Artifacts) == 0 {
+		b.WriteString("No artifacts.\n")
+	}
+	for _, a := range r.Artifacts {
+		fmt.Fprintf(&b, "- `%s` (%s, v%d, %s)\n", a.Path, a.Type, a.Version, shortHash(a.Hash))
+	}
+	return b.String()
+}
+
+// HTML renders the report as a standalone page.
+func (r *Report) HTML() (string, error) {
+	var b bytes.Buffer
+	if err := htmlTemplate.Execute(&b, r); err != nil {
+		return "", fmt.Errorf("render report: %w", err)
+	}
+	return b.String(), nil
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time":     formatTime,
+	"duration": stayDuration,
+	"sla":      slaCell,
+	"join":     strings.Join,
+	"costKey":  costKey,
+	"hash":     shortHash,
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Run report: {{.Flow.TaskID}}</title></head>
+<body>
+<h1>Run report: {{.Flow.TaskID}}</h1>
+<ul>
+<li>Status: {{.Flow.Status}} in phase {{.Flow.CurrentPhase}}, round {{.Flow.Round}}</li>
+<li>Namespace: {{.Flow.Namespace}}</li>
+<li>Budget: ${{printf "%.2f" .Flow.BudgetUsedUSD}} of ${{printf "%.2f" .Flow.BudgetCapUSD}}</li>
+</ul>
+<h2>Phases</h2>
+<table><tr><th>Phase</th><th>Entered</th><th>Duration</th><th>SLA</th></tr>
+{{range .Phases}}<tr><td>{{.Phase}}</td><td>{{time .EnteredAt}}</td><td>{{duration .}}</td><td>{{sla .}}</td></tr>
+{{end}}</table>
+<h2>Rounds</h2>
+<ul>{{range .Rounds}}<li>Round {{.Round}} from phase {{.Phase}}, started {{time .StartedAt}}{{if .EndedAt}}, ended {{time .EndedAt}} by {{.Outcome}}{{end}}</li>
+{{end}}</ul>
+<h2>Blockers</h2>
+<ul>{{range .Blockers}}<li>{{time .CreatedAt}}, phase {{.Phase}}, gate {{.Gate}}: {{join .Blockers "; "}}</li>
+{{else}}<li>No gate refused to advance.</li>{{end}}</ul>
+<h2>Reviews</h2>
+<table><tr><th>Reviewer</th><th>Round</th><th>Verdict</th><th>Correctness</th><th>Security</th><th>Maintainability</th><th>Cost</th><th>Delivery risk</th></tr>
+{{range .ScoreCards}}<tr><td>{{.Reviewer}}</td><td>{{.Round}}</td><td>{{.Verdict}}</td><td>{{.Scores.Correctness}}</td><td>{{.Scores.Security}}</td><td>{{.Scores.Maintainability}}</td><td>{{.Scores.Cost}}</td><td>{{.Scores.DeliveryRisk}}</td></tr>
+{{end}}</table>
+<h2>Issues</h2>
+<ul>{{range .Issues}}<li><b>{{.Severity}}</b> {{.Status}} ({{.Reviewer}}, {{.Location}}): {{.Description}}</li>
+{{else}}<li>No issues raised.</li>{{end}}</ul>
+<h2>Risks</h2>
+<ul>{{range .Risks}}<li><b>{{.Severity}}</b> {{.Status}}: {{.Text}}</li>
+{{else}}<li>No risks recorded.</li>{{end}}</ul>
+<h2>Cost</h2>
+<ul>{{if .Costs}}{{range .Costs.Groups}}<li>{{costKey .Key}}: ${{printf "%.2f" .AmountUSD}}, {{.InputTokens}} input / {{.OutputTokens}} output tokens</li>
+{{end}}{{end}}</ul>
+<h2>Artifacts</h2>
+<ul>{{range .Artifacts}}<li><code>{{.Path}}</code> ({{.Type}}, v{{.Version}}, {{hash .Hash}})</li>
+{{else}}<li>No artifacts.</li>{{end}}</ul>
+</body></html>
+`))
+
+// formatTime renders unix seconds as UTC.
+func formatTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format("2006-01-02 15:04:05Z")
+}
+
+// stayDuration renders how long a stay lasted, marking one still open.
+func stayDuration(p domain.PhaseDuration) string {
+	d := (time.Duration(p.DurationSec) * time.Second).String()
+	if p.ExitedAt == 0 {
+		return d + " (open)"
+	}
+	return d
+}
+
+// slaCell renders a stay's SLA and whether it was exceeded.
+func slaCell(p domain.PhaseDuration) string {
+	if p.SLASec == 0 {
+		return "-"
+	}
+	sla := (time.Duration(p.SLASec) * time.Second).String()
+	if p.DurationSec > p.SLASec {
+		return sla + " exceeded"
+	}
+	return sla
+}
+
+// costKey names the provider of a cost group; deltas without one are
+// reported as unknown.
+func costKey(key string) string {
+	if key == "" {
+		return "unknown"
+	}
+	return key
+}
+
+// shortHash abbreviates a content hash.
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,150 @@
+package report
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/artifact"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// setup creates a generator over a flow "t1" in phase A with a risk, a
+// score card, and a cost recorded.
+func setup(t *testing.T) *Generator {
+	t.Helper()
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+	engine := workflow.NewEngine(db)
+	if err := engine.StartFlow(ctx, "t1", 10); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	g := New(db, engine, artifact.NewBlobs(t.TempDir()))
+
+	risk := domain.Risk{RiskID: "r1", TaskID: "t1", Phase: domain.PhaseA, Severity: "high", Text: "schema drift", Status: "open", CreatedAt: 1}
+	if err := g.RiskRepo.Create(ctx, db, risk); err != nil {
+		t.Fatalf("create risk: %v", err)
+	}
+	card := domain.ScoreCard{
+		ReviewID: "rev1", TaskID: "t1", Reviewer: "primary", Verdict: "approve", CreatedAt: 1,
+		Scores: domain.Scores{Correctness: 4, Security: 5, Maintainability: 3, Cost: 4, DeliveryRisk: 2},
+		Issues: []domain.Issue{}, Alternatives: []string{},
+	}
+	if err := g.ScoreCardRepo.Create(ctx, db, card); err != nil {
+		t.Fatalf("create card: %v", err)
+	}
+	delta := domain.CostDelta{InputTokens: 100, OutputTokens: 50, AmountUSD: 1.25, Provider: domain.ProviderClaude, Phase: domain.PhaseA, CreatedAt: 1}
+	if err := g.CostDeltaRepo.Create(ctx, db, "t1", delta); err != nil {
+		t.Fatalf("create cost delta: %v", err)
+	}
+	return g
+}
+
+func TestBuild_Markdown(t *testing.T) {
+	g := setup(t)
+	r, err := g.Build(context.Background(), "t1")
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	md := r.Markdown()
+	for _, want := range []string{
+		"# Run report: t1",
+		"| A |",
+		"| primary | 0 | approve | 4 | 5 | 3 | 4 | 2 |",
+		"**high** open: schema drift",
+		"- claude: $1.25, 100 input / 50 output tokens",
+		"No gate refused to advance.",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+
+	page, err := r.HTML()
+	if err != nil {
+		t.Fatalf("HTML: %v", err)
+	}
+	if !strings.Contains(page, "<h1>Run report: t1</h1>") || !strings.Contains(page, "schema drift") {
+		t.Errorf("html = %s", page)
+	}
+}
+
+func TestBuild_UnknownFlow(t *testing.T) {
+	g := setup(t)
+	if _, err := g.Build(context.Background(), "missing"); err != domain.ErrFlowNotFound {
+		t.Errorf("err = %v, want ErrFlowNotFound", err)
+	}
+}
+
+func TestGenerate_StoresOnce(t *testing.T) {
+	g := setup(t)
+	ctx := context.Background()
+	first, err := g.Generate(ctx, "t1")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if first.Path != ArtifactPath || first.Type != "report" || first.Version != 1 {
+		t.Errorf("artifact = %+v", first)
+	}
+	f, err := g.Blobs.Open(first.Hash)
+	if err != nil {
+		t.Fatalf("open blob: %v", err)
+	}
+	content, _ := io.ReadAll(f)
+	f.Close()
+	if !strings.Contains(string(content), "# Run report: t1") {
+		t.Errorf("blob = %s", content)
+	}
+
+	again, err := g.Generate(ctx, "t1")
+	if err != nil {
+		t.Fatalf("Generate again: %v", err)
+	}
+	if again.ID != first.ID || again.Version != 1 {
+		t.Errorf("regenerated artifact = %+v, want the stored version", again)
+	}
+}
+
+func TestStart_ReportsFinishedFlows(t *testing.T) {
+	g := setup(t)
+	bus := eventbus.New()
+	g.Engine.Bus = bus
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g.Start(ctx, bus)
+
+	trigger := domain.TransitionTrigger{Action: "advance", Actor: "test"}
+	for i := 0; i < 6; i++ {
+		if err := g.Engine.Advance(ctx, "t1", trigger); err != nil {
+			t.Fatalf("Advance: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ref, err := g.ArtifactRepo.Latest(ctx, g.DB, "t1", ArtifactPath)
+		if err != nil {
+			t.Fatalf("Latest: %v", err)
+		}
+		if ref != nil {
+			if ref.Phase != domain.PhaseG {
+				t.Errorf("report phase = %s, want G", ref.Phase)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no report stored for the finished flow")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
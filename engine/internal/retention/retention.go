@@ -0,0 +1,311 @@
+// Package retention prunes append-only history tables according to per-table
+// policies, archiving each expired row to a gzip-compressed JSONL file before
+// it is deleted.
+package retention
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// TableReport summarises one policy's pass.
+type TableReport struct {
+	Table   string `json:"table"`
+	Deleted int64  `json:"deleted"`
+	Archive string `json:"archive,omitempty"`
+}
+
+// Manager applies retention policies to the database.
+type Manager struct {
+	DB            *sql.DB
+	RetentionRepo *store.RetentionRepo
+	Policies      []store.RetentionPolicy
+	// ArchiveDir receives one <table>-<unix>.jsonl.gz file per table per run.
+	// Empty deletes expired rows without archiving them.
+	ArchiveDir string
+	// BatchSize bounds how many rows are archived and deleted per transaction.
+	BatchSize int
+	// Interval between runs started by Start; zero disables the loop.
+	Interval time.Duration
+}
+
+// NewManager creates a Manager with default dependencies.
+func NewManager(db *sql.DB, policies []store.RetentionPolicy, archiveDir string) *Manager {
+	return &Manager{
+		DB:            db,
+		RetentionRepo: &store.RetentionRepo{},
+		Policies:      policies,
+		ArchiveDir:    archiveDir,
+		BatchSize:     500,
+	}
+}
+
+// Start runs the policies every Interval until ctx is cancelled. Failures are
+// returned by the next Run call only, so the loop keeps going.
+func (m *Manager) Start(ctx context.Context) {
+	if m.Interval <= 0 || len(m.Policies) == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(m.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_, _ = m.Run(ctx)
+			}
+		}
+	}()
+}
+
+// Run applies every policy once and reports what was removed.
+func (m *Manager) Run(ctx context.Context) ([]TableReport, error) {
+	now := time.Now().Unix()
+	reports := make([]TableReport, 0, len(m.Policies))
+	for _, policy := range m.Policies {
+		report, err := m.apply(ctx, policy, now)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// apply archives and deletes a policy's expired rows batch by batch. Each
+// batch reaches disk before the transaction that deletes it.
+func (m *Manager) apply(ctx context.Context, policy store.RetentionPolicy, now int64) (TableReport, error) {
+	report := TableReport{Table: policy.Table}
+	batch := m.BatchSize
+	if batch <= 0 {
+		batch = 500
+	}
+
+	var archive *archiveWriter
+	defer func() {
+		if archive != nil {
+			archive.Close()
+		}
+	}()
+
+	for {
+		rows, err := m.RetentionRepo.ListExpired(ctx, m.DB, policy, now, batch)
+		if err != nil {
+			return report, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		if m.ArchiveDir != "" {
+			if archive == nil {
+				path := filepath.Join(m.ArchiveDir, fmt.Sprintf("%s-%d.jsonl.gz", policy.Table, now))
+				if archive, err = openArchive(path); err != nil {
+					return report, err
+				}
+				report.Archive = path
+			}
+			if err := archive.Write(rows); err != nil {
+				return report, err
+			}
+		}
+
+		ids := make([]int64, len(rows))
+		for i, row := range rows {
+			ids[i] = row.RowID
+		}
+		tx, err := m.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return report, fmt.Errorf("begin retention tx: %w", err)
+		}
+		n, err := m.RetentionRepo.DeleteTx(ctx, tx, policy.Table, ids)
+		if err != nil {
+			tx.Rollback()
+			return report, err
+		}
+		if err := tx.Commit(); err != nil {
+			return report, fmt.Errorf("commit retention tx: %w", err)
+		}
+		report.Deleted += n
+
+		if len(rows) < batch {
+			break
+		}
+	}
+
+	if archive != nil {
+		err := archive.Close()
+		archive = nil
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// archiveWriter appends JSON lines to a gzip file.
+type archiveWriter struct {
+	f  *os.File
+	gz *gzip.Writer
+}
+
+func openArchive(path string) (*archiveWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create archive dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	return &archiveWriter{f: f, gz: gzip.NewWriter(f)}, nil
+}
+
+// Write appends rows and syncs them to disk.
+func (a *archiveWriter) Write(rows []store.ExpiredRow) error {
+	enc := json.NewEncoder(a.gz)
+	for _, row := range rows {
+		if err := enc.Encode(row.Columns); err != nil {
+			return fmt.Errorf("write archive: %w", err)
+		}
+	}
+	if err := a.gz.Flush(); err != nil {
+		return fmt.Errorf("flush archive: %w", err)
+	}
+	if err := a.f.Sync(); err != nil {
+		return fmt.Errorf("sync archive: %w", err)
+	}
+	return nil
+}
+
+// Close finishes the gzip stream and closes the file.
+func (a *archiveWriter) Close() error {
+	gzErr := a.gz.Close()
+	fErr := a.f.Close()
+	if gzErr != nil {
+		return fmt.Errorf("close archive: %w", gzErr)
+	}
+	if fErr != nil {
+		return fmt.Errorf("close archive: %w", fErr)
+	}
+	return nil
+}
+
+// PurgeArchives removes taskID's rows from every archive in dir and returns
+// the number removed from each archive that held any. An archive left empty
+// is deleted; the others are rewritten in place. With dryRun set the rows
+// are only counted.
+func PurgeArchives(dir, taskID string, dryRun bool) (map[string]int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl.gz"))
+	if err != nil {
+		return nil, fmt.Errorf("list archives: %w", err)
+	}
+	removed := make(map[string]int)
+	for _, path := range paths {
+		kept, n, err := filterArchive(path, taskID)
+		if err != nil {
+			return removed, err
+		}
+		if n == 0 {
+			continue
+		}
+		removed[path] = n
+		if dryRun {
+			continue
+		}
+		if len(kept) == 0 {
+			if err := os.Remove(path); err != nil {
+				return removed, fmt.Errorf("remove archive: %w", err)
+			}
+			continue
+		}
+		if err := rewriteArchive(path, kept); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// filterArchive reads the archive at path and returns the lines of rows
+// that do not belong to taskID, with the number of rows that do.
+func filterArchive(path, taskID string) ([][]byte, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("open archive: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read archive %s: %w", path, err)
+	}
+	defer gz.Close()
+
+	var kept [][]byte
+	removed := 0
+	r := bufio.NewReader(gz)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var row struct {
+				TaskID string `json:"task_id"`
+			}
+			if jsonErr := json.Unmarshal(line, &row); jsonErr != nil {
+				return nil, 0, fmt.Errorf("read archive %s: %w", path, jsonErr)
+			}
+			if row.TaskID == taskID {
+				removed++
+			} else {
+				kept = append(kept, line)
+			}
+		}
+		if err == io.EOF {
+			return kept, removed, nil
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("read archive %s: %w", path, err)
+		}
+	}
+}
+
+// rewriteArchive replaces the archive at path with one holding lines.
+func rewriteArchive(path string, lines [][]byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".archive-*")
+	if err != nil {
+		return fmt.Errorf("rewrite archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	gz := gzip.NewWriter(tmp)
+	for _, line := range lines {
+		if _, err := gz.Write(line); err != nil {
+			tmp.Close()
+			return fmt.Errorf("rewrite archive: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("rewrite archive: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("rewrite archive: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("rewrite archive: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rewrite archive: %w", err)
+	}
+	return nil
+}
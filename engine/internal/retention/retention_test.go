@@ -0,0 +1,154 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+func TestManager_ArchivesBeforeDeleting(t *testing.T) {
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	audits := &store.AuditRepo{}
+	old := time.Now().Add(-48 * time.Hour).Unix()
+	for i := 0; i < 5; i++ {
+		created := old
+		if i == 4 {
+			created = time.Now().Unix()
+		}
+		if err := audits.Record(ctx, db, domain.AuditRecord{
+			ID: fmt.Sprintf("aud-%d", i), TaskID: "t1", Category: "test", Action: "x", Severity: "info", CreatedAt: created,
+		}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	dir := t.TempDir()
+	m := NewManager(db, []store.RetentionPolicy{{Table: "audit_records", MaxAgeSec: 24 * 3600}}, dir)
+	m.BatchSize = 2
+
+	reports, err := m.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(reports) != 1 || reports[0].Deleted != 4 {
+		t.Fatalf("reports = %+v, want 4 audit rows deleted", reports)
+	}
+
+	left, err := audits.ListByTask(ctx, db, "t1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(left) != 1 || left[0].ID != "aud-4" {
+		t.Errorf("remaining = %+v, want only aud-4", left)
+	}
+
+	f, err := os.Open(reports[0].Archive)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	var ids []string
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var row map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("decode archived row: %v", err)
+		}
+		ids = append(ids, row["id"].(string))
+	}
+	if len(ids) != 4 || ids[0] != "aud-0" || ids[3] != "aud-3" {
+		t.Errorf("archived ids = %v, want aud-0..aud-3", ids)
+	}
+}
+
+func TestManager_NoArchiveDirOnlyDeletes(t *testing.T) {
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	eventRepo := &store.EventRepo{}
+	tx, _ := db.BeginTx(ctx, nil)
+	for i := int64(1); i <= 3; i++ {
+		if err := eventRepo.AppendTx(ctx, tx, domain.WorkflowEvent{TaskID: "t1", SeqNo: i, Phase: domain.PhaseA, EventType: "x_test", PayloadJSON: "{}", CreatedAt: time.Now().Unix()}); err != nil {
+			tx.Rollback()
+			t.Fatalf("AppendTx: %v", err)
+		}
+	}
+	tx.Commit()
+
+	m := NewManager(db, []store.RetentionPolicy{{Table: "workflow_events", MaxRowsPerTask: 1}}, "")
+	reports, err := m.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if reports[0].Deleted != 2 || reports[0].Archive != "" {
+		t.Errorf("report = %+v, want 2 deleted and no archive", reports[0])
+	}
+
+	events, _ := eventRepo.ListByTask(ctx, db, "t1", 0)
+	if len(events) != 1 || events[0].SeqNo != 3 {
+		t.Errorf("remaining events = %+v, want only seq 3", events)
+	}
+}
+
+func TestPurgeArchives(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, taskIDs ...string) string {
+		path := filepath.Join(dir, name)
+		f, _ := os.Create(path)
+		gz := gzip.NewWriter(f)
+		for i, id := range taskIDs {
+			fmt.Fprintf(gz, `{"id":"r%d","task_id":%q}`+"\n", i, id)
+		}
+		gz.Close()
+		f.Close()
+		return path
+	}
+	mixed := write("audit_records-1.jsonl.gz", "t1", "t2", "t1")
+	only := write("cost_deltas-1.jsonl.gz", "t1")
+	other := write("workflow_events-1.jsonl.gz", "t2")
+
+	dry, err := PurgeArchives(dir, "t1", true)
+	if err != nil || len(dry) != 2 || dry[mixed] != 2 || dry[only] != 1 {
+		t.Fatalf("dry run = %v, %v", dry, err)
+	}
+	if _, err := os.Stat(only); err != nil {
+		t.Errorf("dry run removed an archive: %v", err)
+	}
+
+	if _, err := PurgeArchives(dir, "t1", false); err != nil {
+		t.Fatalf("PurgeArchives: %v", err)
+	}
+	if _, err := os.Stat(only); !os.IsNotExist(err) {
+		t.Errorf("archive of only t1 rows still exists: %v", err)
+	}
+	kept, n, err := filterArchive(mixed, "t2")
+	if err != nil || n != 1 || len(kept) != 0 {
+		t.Errorf("rewritten archive holds %d t2 rows and %d others, %v; want only the t2 row", n, len(kept), err)
+	}
+	if _, n, _ := filterArchive(other, "t2"); n != 1 {
+		t.Errorf("archive without t1 rows changed")
+	}
+}
@@ -1,11 +1,28 @@
 package review
 
-import "github.com/anthropics/three-body-engine/internal/domain"
+import (
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// Default verdict thresholds on the 1-5 weighted score.
+const (
+	DefaultPassThreshold        = 4.0
+	DefaultConditionalThreshold = 3.0
+)
 
 // ConsensusEngine aggregates multiple ScoreCards into a single ConsensusResult
 // using weighted averaging.
 type ConsensusEngine struct {
-	Weights   map[string]float64
+	Weights map[string]float64
+	// PassThreshold and ConditionalThreshold are the weighted scores at or
+	// above which the verdict is pass or conditional_pass; zero uses the
+	// defaults.
+	PassThreshold        float64
+	ConditionalThreshold float64
+	// Policies holds the verdict policy applied by EvaluatePhase per phase.
+	Policies  map[domain.Phase]domain.VerdictPolicy
 	Validator *SchemaValidator
 }
 
@@ -26,6 +43,109 @@ func NewConsensusEngine(weights map[string]float64) *ConsensusEngine {
 	}
 }
 
+// NewConsensusEngineWith creates a ConsensusEngine from settings, using the
+// default weights when none are set.
+func NewConsensusEngineWith(s domain.ConsensusSettings) *ConsensusEngine {
+	return NewConsensusEngine(DefaultWeights()).For(&s)
+}
+
+// For returns a copy of e with the set fields of a flow's override applied.
+// A nil override returns e itself.
+func (e *ConsensusEngine) For(s *domain.ConsensusSettings) *ConsensusEngine {
+	if s == nil {
+		return e
+	}
+	c := *e
+	if len(s.Weights) > 0 {
+		c.Weights = s.Weights
+	}
+	if s.PassThreshold != 0 {
+		c.PassThreshold = s.PassThreshold
+	}
+	if s.ConditionalThreshold != 0 {
+		c.ConditionalThreshold = s.ConditionalThreshold
+	}
+	return &c
+}
+
+// Settings returns the weights and thresholds e evaluates with.
+func (e *ConsensusEngine) Settings() domain.ConsensusSettings {
+	s := domain.ConsensusSettings{
+		Weights:              e.Weights,
+		PassThreshold:        e.PassThreshold,
+		ConditionalThreshold: e.ConditionalThreshold,
+	}
+	if s.PassThreshold == 0 {
+		s.PassThreshold = DefaultPassThreshold
+	}
+	if s.ConditionalThreshold == 0 {
+		s.ConditionalThreshold = DefaultConditionalThreshold
+	}
+	return s
+}
+
+// EvaluatePhase computes the weighted consensus like Evaluate and then
+// applies the verdict policy of phase. Rules override the averaged verdict in
+// order of precedence: too few reviewers, a P0 issue, then a fail veto.
+func (e *ConsensusEngine) EvaluatePhase(cards []domain.ScoreCard, phase domain.Phase) (*domain.ConsensusResult, error) {
+	policy := e.Policies[phase]
+	reviewers := make(map[string]bool)
+	for _, card := range cards {
+		reviewers[card.Reviewer] = true
+	}
+	if len(cards) == 0 && policy.MinReviewers > 0 {
+		return &domain.ConsensusResult{
+			FinalVerdict: "insufficient_reviews",
+			Blocking:     true,
+			BlockReasons: []string{fmt.Sprintf("0 of %d required reviewers", policy.MinReviewers)},
+			Settings:     e.Settings(),
+			Phase:        phase,
+			Policy:       policy,
+		}, nil
+	}
+	result, err := e.Evaluate(cards)
+	if err != nil {
+		return nil, err
+	}
+	result.Phase = phase
+	result.Policy = policy
+
+	var short, rework, veto bool
+	var reasons []string
+	if policy.MinReviewers > 0 && len(reviewers) < policy.MinReviewers {
+		short = true
+		reasons = append(reasons, fmt.Sprintf("%d of %d required reviewers", len(reviewers), policy.MinReviewers))
+	}
+	for _, card := range cards {
+		if policy.P0ForcesRework {
+			for _, issue := range card.Issues {
+				if issue.Severity == "P0" {
+					rework = true
+					reasons = append(reasons, fmt.Sprintf("%s: P0 issue at %s: %s", card.Reviewer, issue.Location, issue.Description))
+				}
+			}
+		}
+		if card.Verdict == "fail" && (policy.AnyFailForcesFail || policy.LeadVeto && card.Reviewer == "lead") {
+			veto = true
+			reasons = append(reasons, fmt.Sprintf("%s: fail verdict", card.Reviewer))
+		}
+	}
+
+	switch {
+	case short:
+		result.FinalVerdict = "insufficient_reviews"
+	case rework:
+		result.FinalVerdict = "rework"
+	case veto:
+		result.FinalVerdict = "fail"
+	default:
+		return result, nil
+	}
+	result.Blocking = true
+	result.BlockReasons = reasons
+	return result, nil
+}
+
 // Evaluate computes a weighted consensus from the provided score cards.
 func (e *ConsensusEngine) Evaluate(cards []domain.ScoreCard) (*domain.ConsensusResult, error) {
 	if len(cards) == 0 {
@@ -38,6 +158,7 @@ func (e *ConsensusEngine) Evaluate(cards []domain.ScoreCard) (*domain.ConsensusR
 		}
 	}
 
+	settings := e.Settings()
 	var weightedSum, totalWeight float64
 	for _, card := range cards {
 		avg := float64(card.Scores.Correctness+card.Scores.Security+
@@ -45,7 +166,7 @@ func (e *ConsensusEngine) Evaluate(cards []domain.ScoreCard) (*domain.ConsensusR
 			card.Scores.DeliveryRisk) / 5.0
 
 		weight := 1.0
-		if w, ok := e.Weights[card.Reviewer]; ok {
+		if w, ok := settings.Weights[card.Reviewer]; ok {
 			weight = w
 		}
 		weightedSum += avg * weight
@@ -56,9 +177,9 @@ func (e *ConsensusEngine) Evaluate(cards []domain.ScoreCard) (*domain.ConsensusR
 
 	var verdict string
 	switch {
-	case finalScore >= 4.0:
+	case finalScore >= settings.PassThreshold:
 		verdict = "pass"
-	case finalScore >= 3.0:
+	case finalScore >= settings.ConditionalThreshold:
 		verdict = "conditional_pass"
 	default:
 		verdict = "fail"
@@ -69,5 +190,6 @@ func (e *ConsensusEngine) Evaluate(cards []domain.ScoreCard) (*domain.ConsensusR
 		FinalVerdict:  verdict,
 		Blocking:      false,
 		BlockReasons:  nil,
+		Settings:      settings,
 	}, nil
 }
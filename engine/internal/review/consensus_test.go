@@ -197,3 +197,79 @@ func TestEvaluate_DefaultWeights(t *testing.T) {
 		t.Errorf("expected %d weights, got %d", len(expected), len(w))
 	}
 }
+
+func TestEvaluate_ConfiguredThresholdsAndOverride(t *testing.T) {
+	eng := NewConsensusEngineWith(domain.ConsensusSettings{PassThreshold: 4.5})
+	card := makeCard("primary", 5, 5, 4, 4, 4, "pass")
+	// avg = 4.4: below the configured pass threshold.
+	res, err := eng.Evaluate([]domain.ScoreCard{card})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.FinalVerdict != "conditional_pass" {
+		t.Errorf("expected conditional_pass, got %s", res.FinalVerdict)
+	}
+	if res.Settings.PassThreshold != 4.5 || res.Settings.ConditionalThreshold != DefaultConditionalThreshold || res.Settings.Weights["primary"] != 0.45 {
+		t.Errorf("settings = %+v", res.Settings)
+	}
+
+	task := eng.For(&domain.ConsensusSettings{Weights: map[string]float64{"primary": 1}, PassThreshold: 4.0})
+	res, err = task.Evaluate([]domain.ScoreCard{card, makeCard("secondary", 1, 1, 1, 1, 1, "fail")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// secondary is unweighted in the override, so it counts with weight 1.
+	if !almostEqual(res.WeightedScore, 2.7, 0.01) || res.Settings.PassThreshold != 4.0 {
+		t.Errorf("override result = %+v", res)
+	}
+	if eng.PassThreshold != 4.5 {
+		t.Errorf("For modified the base engine: %+v", eng)
+	}
+}
+
+func TestEvaluatePhase_Policies(t *testing.T) {
+	eng := NewConsensusEngine(DefaultWeights())
+	eng.Policies = map[domain.Phase]domain.VerdictPolicy{
+		domain.PhaseD: {MinReviewers: 3},
+		domain.PhaseF: {LeadVeto: true, P0ForcesRework: true},
+	}
+	good := makeCard("primary", 5, 5, 5, 5, 5, "pass")
+	lead := makeCard("lead", 4, 4, 4, 4, 4, "fail")
+
+	// No policy for phase E: the averaged verdict stands.
+	res, err := eng.EvaluatePhase([]domain.ScoreCard{good, lead}, domain.PhaseE)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.FinalVerdict != "pass" || res.Blocking {
+		t.Errorf("phase E: %+v", res)
+	}
+
+	res, err = eng.EvaluatePhase([]domain.ScoreCard{good, lead}, domain.PhaseD)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.FinalVerdict != "insufficient_reviews" || !res.Blocking || res.Policy.MinReviewers != 3 || res.Phase != domain.PhaseD {
+		t.Errorf("phase D: %+v", res)
+	}
+	if res, err := eng.EvaluatePhase(nil, domain.PhaseD); err != nil || res.FinalVerdict != "insufficient_reviews" {
+		t.Errorf("phase D without cards: %+v, %v", res, err)
+	}
+
+	res, err = eng.EvaluatePhase([]domain.ScoreCard{good, lead}, domain.PhaseF)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.FinalVerdict != "fail" || len(res.BlockReasons) != 1 || res.BlockReasons[0] != "lead: fail verdict" {
+		t.Errorf("lead veto: %+v", res)
+	}
+
+	good.Issues = []domain.Issue{{Severity: "P0", Location: "a.go:1", Description: "data race"}}
+	res, err = eng.EvaluatePhase([]domain.ScoreCard{good, lead}, domain.PhaseF)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.FinalVerdict != "rework" || len(res.BlockReasons) != 2 {
+		t.Errorf("P0 rework: %+v", res)
+	}
+}
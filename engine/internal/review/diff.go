@@ -0,0 +1,99 @@
+package review
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// DiffScoreCards compares two cards of one reviewer. An issue of after is
+// persisting if before has an issue at the same location with the same
+// description, and new otherwise; issues of before with no match are
+// resolved.
+func DiffScoreCards(before, after domain.ScoreCard) domain.ScoreCardDiff {
+	diff := domain.ScoreCardDiff{
+		Reviewer:    after.Reviewer,
+		FromRound:   before.Round,
+		ToRound:     after.Round,
+		FromVerdict: before.Verdict,
+		ToVerdict:   after.Verdict,
+		ScoreDeltas: domain.Scores{
+			Correctness:     after.Scores.Correctness - before.Scores.Correctness,
+			Security:        after.Scores.Security - before.Scores.Security,
+			Maintainability: after.Scores.Maintainability - before.Scores.Maintainability,
+			Cost:            after.Scores.Cost - before.Scores.Cost,
+			DeliveryRisk:    after.Scores.DeliveryRisk - before.Scores.DeliveryRisk,
+		},
+		Resolved:   []domain.Issue{},
+		New:        []domain.Issue{},
+		Persisting: []domain.Issue{},
+	}
+
+	earlier := make(map[string]bool, len(before.Issues))
+	for _, issue := range before.Issues {
+		earlier[issueKey(issue)] = true
+	}
+	later := make(map[string]bool, len(after.Issues))
+	for _, issue := range after.Issues {
+		key := issueKey(issue)
+		later[key] = true
+		if earlier[key] {
+			diff.Persisting = append(diff.Persisting, issue)
+		} else {
+			diff.New = append(diff.New, issue)
+		}
+	}
+	for _, issue := range before.Issues {
+		if !later[issueKey(issue)] {
+			diff.Resolved = append(diff.Resolved, issue)
+		}
+	}
+	return diff
+}
+
+// DiffRounds diffs each reviewer's card in round to against their card in
+// round from, or, when from is negative, in the latest earlier round they
+// reviewed. A reviewer's latest card counts when a round has several.
+// Reviewers without a card in both rounds are left out; the result is
+// ordered by reviewer.
+func DiffRounds(cards []domain.ScoreCard, from, to int) []domain.ScoreCardDiff {
+	latest := make(map[string]map[int]domain.ScoreCard)
+	for _, c := range cards {
+		rounds, ok := latest[c.Reviewer]
+		if !ok {
+			rounds = make(map[int]domain.ScoreCard)
+			latest[c.Reviewer] = rounds
+		}
+		if prev, ok := rounds[c.Round]; !ok || c.CreatedAt >= prev.CreatedAt {
+			rounds[c.Round] = c
+		}
+	}
+
+	diffs := []domain.ScoreCardDiff{}
+	for _, rounds := range latest {
+		after, ok := rounds[to]
+		if !ok {
+			continue
+		}
+		prev := from
+		if prev < 0 {
+			for r := range rounds {
+				if r < to && r > prev {
+					prev = r
+				}
+			}
+		}
+		before, ok := rounds[prev]
+		if !ok || prev >= to {
+			continue
+		}
+		diffs = append(diffs, DiffScoreCards(before, after))
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Reviewer < diffs[j].Reviewer })
+	return diffs
+}
+
+func issueKey(issue domain.Issue) string {
+	return strings.ToLower(strings.TrimSpace(issue.Location)) + "\x00" + strings.ToLower(strings.TrimSpace(issue.Description))
+}
@@ -0,0 +1,73 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func roundCard(reviewer string, round int, correctness int, issues ...domain.Issue) domain.ScoreCard {
+	card := safeCard(reviewer)
+	card.Round = round
+	card.CreatedAt = int64(round)
+	card.Scores.Correctness = correctness
+	card.Issues = issues
+	return card
+}
+
+func TestDiffScoreCards(t *testing.T) {
+	nilCheck := domain.Issue{Severity: "P0", Location: "api.go:10", Description: "nil dereference"}
+	naming := domain.Issue{Severity: "P2", Location: "api.go:20", Description: "unclear name"}
+	leak := domain.Issue{Severity: "P1", Location: "db.go:5", Description: "rows not closed"}
+
+	before := roundCard("lead", 0, 2, nilCheck, naming)
+	before.Verdict = "fail"
+	after := roundCard("lead", 1, 4, domain.Issue{Severity: "P3", Location: "API.go:20 ", Description: "Unclear name"}, leak)
+
+	diff := DiffScoreCards(before, after)
+	if diff.FromRound != 0 || diff.ToRound != 1 || diff.FromVerdict != "fail" || diff.ToVerdict != "pass" {
+		t.Errorf("diff header = %+v", diff)
+	}
+	if diff.ScoreDeltas.Correctness != 2 || diff.ScoreDeltas.Security != 0 {
+		t.Errorf("ScoreDeltas = %+v, want correctness +2 only", diff.ScoreDeltas)
+	}
+	if len(diff.Resolved) != 1 || diff.Resolved[0] != nilCheck {
+		t.Errorf("Resolved = %+v, want the nil check", diff.Resolved)
+	}
+	if len(diff.New) != 1 || diff.New[0] != leak {
+		t.Errorf("New = %+v, want the leak", diff.New)
+	}
+	if len(diff.Persisting) != 1 || diff.Persisting[0].Severity != "P3" {
+		t.Errorf("Persisting = %+v, want the renamed issue at its new severity", diff.Persisting)
+	}
+}
+
+func TestDiffRounds(t *testing.T) {
+	cards := []domain.ScoreCard{
+		roundCard("primary", 0, 2),
+		roundCard("lead", 0, 3),
+		roundCard("primary", 1, 3),
+		roundCard("lead", 2, 5),
+		roundCard("primary", 2, 4),
+		roundCard("secondary", 2, 4),
+	}
+
+	diffs := DiffRounds(cards, -1, 2)
+	if len(diffs) != 2 {
+		t.Fatalf("diffs = %+v, want lead and primary", diffs)
+	}
+	if diffs[0].Reviewer != "lead" || diffs[0].FromRound != 0 || diffs[0].ScoreDeltas.Correctness != 2 {
+		t.Errorf("lead diff = %+v, want from round 0 with +2", diffs[0])
+	}
+	if diffs[1].Reviewer != "primary" || diffs[1].FromRound != 1 || diffs[1].ScoreDeltas.Correctness != 1 {
+		t.Errorf("primary diff = %+v, want from round 1 with +1", diffs[1])
+	}
+
+	diffs = DiffRounds(cards, 0, 2)
+	if len(diffs) != 2 || diffs[1].FromRound != 0 || diffs[1].ScoreDeltas.Correctness != 2 {
+		t.Errorf("explicit from = %+v, want primary from round 0", diffs)
+	}
+	if diffs := DiffRounds(cards, -1, 0); len(diffs) != 0 {
+		t.Errorf("first round diffs = %+v, want none", diffs)
+	}
+}
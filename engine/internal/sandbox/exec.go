@@ -0,0 +1,259 @@
+// Package sandbox runs commands and proxies file access on behalf of workers
+// inside their flow's workspace. Every command must be on the allowlist and
+// approved by the guard against the worker's capability sheet, and every
+// invocation, allowed or not, is audited; file access is described on Files.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/artifact"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/guard"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// Defaults for Executor limits.
+const (
+	DefaultTimeout   = 5 * time.Minute
+	DefaultMaxOutput = 1 << 20
+)
+
+// ExecRequest is a command a worker asks to run. Dir is relative to the
+// flow's workspace; TimeoutSec may only shorten the executor's timeout.
+type ExecRequest struct {
+	Command    string   `json:"command"`
+	Args       []string `json:"args,omitempty"`
+	Dir        string   `json:"dir,omitempty"`
+	TimeoutSec int      `json:"timeoutSec,omitempty"`
+}
+
+// ExecResult is the outcome of a command. Output holds the combined stdout
+// and stderr, keeping the tail when it exceeds the executor's limit; the
+// full kept output is also stored as the Artifact.
+type ExecResult struct {
+	ExitCode   int                 `json:"exitCode"`
+	TimedOut   bool                `json:"timedOut,omitempty"`
+	DurationMS int64               `json:"durationMs"`
+	Output     string              `json:"output"`
+	Truncated  bool                `json:"truncated,omitempty"`
+	Artifact   *domain.ArtifactRef `json:"artifact,omitempty"`
+}
+
+// Executor runs allowlisted commands for workers.
+type Executor struct {
+	DB           *sql.DB
+	Guard        *guard.Guard
+	WorkerRepo   *store.WorkerRepo
+	TaskRepo     *store.TaskRepo
+	ArtifactRepo *store.ArtifactRepo
+	AuditRepo    *store.AuditRepo
+	// Blobs, when set, stores command output.
+	Blobs *artifact.Blobs
+	// AllowedCommands are the program names workers may run; empty allows
+	// none.
+	AllowedCommands []string
+	// Workspace is the working directory of flows without their own.
+	Workspace string
+	Timeout   time.Duration
+	MaxOutput int
+}
+
+// NewExecutor creates an Executor with default repos and limits.
+func NewExecutor(db *sql.DB, g *guard.Guard, blobs *artifact.Blobs, allowed []string, workspace string) *Executor {
+	return &Executor{
+		DB:              db,
+		Guard:           g,
+		WorkerRepo:      &store.WorkerRepo{},
+		TaskRepo:        &store.TaskRepo{},
+		ArtifactRepo:    &store.ArtifactRepo{},
+		AuditRepo:       &store.AuditRepo{},
+		Blobs:           blobs,
+		AllowedCommands: allowed,
+		Workspace:       workspace,
+		Timeout:         DefaultTimeout,
+		MaxOutput:       DefaultMaxOutput,
+	}
+}
+
+// Exec runs req for a live worker. The command is checked against the
+// allowlist, then Guard.CheckAll approves it with the directory as path and
+// the program name as command under the worker's capability sheet (or, for
+// workers spawned without one, a sheet allowing the whole workspace and the
+// allowlist). A command that runs, whatever its exit code, returns a result.
+func (e *Executor) Exec(ctx context.Context, workerID string, req ExecRequest) (*ExecResult, error) {
+	worker, err := e.WorkerRepo.GetByID(ctx, e.DB, workerID)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := e.check(ctx, worker, req)
+	if err != nil {
+		e.audit(worker, req, "exec_denied", "warning", map[string]interface{}{"error": err.Error()})
+		return nil, err
+	}
+	root, err := workspaceRoot(ctx, e.DB, e.TaskRepo, worker.TaskID, e.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	full, err := resolve(root, dir)
+	if err != nil {
+		e.audit(worker, req, "exec_denied", "warning", map[string]interface{}{"error": err.Error()})
+		return nil, err
+	}
+
+	result := e.run(ctx, full, req)
+	if result.Artifact, err = e.record(ctx, worker, result.Output); err != nil {
+		return nil, err
+	}
+	severity := "info"
+	if result.ExitCode != 0 {
+		severity = "warning"
+	}
+	e.audit(worker, req, "exec", severity, map[string]interface{}{
+		"exit_code":   result.ExitCode,
+		"timed_out":   result.TimedOut,
+		"duration_ms": result.DurationMS,
+		"artifact":    result.Artifact.ID,
+	})
+	return result, nil
+}
+
+// check applies the allowlist, workspace confinement, and guard to req and
+// returns its cleaned, slash-separated directory.
+func (e *Executor) check(ctx context.Context, worker *domain.WorkerRef, req ExecRequest) (string, error) {
+	if err := checkLive(worker); err != nil {
+		return "", err
+	}
+	if !e.allowed(req.Command) {
+		return "", domain.NewEngineError(domain.ErrForbiddenOperation.Code,
+			fmt.Sprintf("command %q is not allowed", req.Command))
+	}
+	dir, err := cleanRel(req.Dir)
+	if err != nil {
+		return "", err
+	}
+
+	sheet := worker.Capabilities
+	if sheet == nil {
+		sheet = e.Guard.Broker.BuildCapabilitySheet(worker.TaskID, []string{"./"}, e.AllowedCommands)
+	}
+	if err := e.Guard.CheckAll(ctx, worker.TaskID, dir, req.Command, sheet); err != nil {
+		return "", err
+	}
+	return dir, e.Guard.CheckRate(ctx, worker.TaskID, "", guard.OpExec)
+}
+
+// allowed reports whether command is a bare program name on the allowlist.
+func (e *Executor) allowed(command string) bool {
+	if command == "" || strings.ContainsAny(command, `/\`) {
+		return false
+	}
+	for _, c := range e.AllowedCommands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+// run executes req in dir, bounded by the executor's timeout.
+func (e *Executor) run(ctx context.Context, dir string, req ExecRequest) *ExecResult {
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if t := time.Duration(req.TimeoutSec) * time.Second; t > 0 && t < timeout {
+		timeout = t
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, req.Command, req.Args...)
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	start := time.Now()
+	err := cmd.Run()
+
+	result := &ExecResult{
+		DurationMS: time.Since(start).Milliseconds(),
+		TimedOut:   ctx.Err() == context.DeadlineExceeded,
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		result.ExitCode = -1
+		fmt.Fprintf(&out, "\n%v\n", err)
+	}
+
+	limit := e.MaxOutput
+	if limit <= 0 {
+		limit = DefaultMaxOutput
+	}
+	output := out.Bytes()
+	if len(output) > limit {
+		output = output[len(output)-limit:]
+		result.Truncated = true
+	}
+	result.Output = string(output)
+	return result
+}
+
+// record stores output as the next version of the worker's exec log.
+func (e *Executor) record(ctx context.Context, worker *domain.WorkerRef, output string) (*domain.ArtifactRef, error) {
+	hash := artifact.Hash([]byte(output))
+	if e.Blobs != nil {
+		if _, err := e.Blobs.Put([]byte(output)); err != nil {
+			return nil, fmt.Errorf("store exec output: %w", err)
+		}
+	}
+	now := time.Now()
+	ref, err := e.ArtifactRepo.Create(ctx, e.DB, domain.ArtifactRef{
+		ID:        fmt.Sprintf("art-exec-%d", now.UnixNano()),
+		TaskID:    worker.TaskID,
+		WorkerID:  worker.WorkerID,
+		Phase:     worker.Phase,
+		Type:      "exec_output",
+		Path:      "exec/" + worker.WorkerID + ".log",
+		Hash:      hash,
+		CreatedAt: now.Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("record exec output: %w", err)
+	}
+	return ref, nil
+}
+
+func (e *Executor) audit(worker *domain.WorkerRef, req ExecRequest, action, severity string, decision map[string]interface{}) {
+	request, _ := json.Marshal(map[string]interface{}{
+		"worker":  worker.WorkerID,
+		"command": req.Command,
+		"args":    req.Args,
+		"dir":     req.Dir,
+	})
+	data, _ := json.Marshal(decision)
+	now := time.Now()
+	_ = e.AuditRepo.Record(context.Background(), e.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-exec-%d", now.UnixNano()),
+		TaskID:       worker.TaskID,
+		Category:     "exec",
+		Actor:        worker.WorkerID,
+		Action:       action,
+		RequestJSON:  string(request),
+		DecisionJSON: string(data),
+		Severity:     severity,
+		CreatedAt:    now.Unix(),
+	})
+}
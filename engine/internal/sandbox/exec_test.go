@@ -0,0 +1,182 @@
+package sandbox
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/artifact"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/guard"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/team"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// setupTask creates a DB with task-1 running in a temp workspace that holds
+// an empty sub directory. It returns the DB, the temp dir, and the workspace.
+func setupTask(t *testing.T) (*sql.DB, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := store.NewDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	workspace := filepath.Join(dir, "ws")
+	if err := os.MkdirAll(filepath.Join(workspace, "sub"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := (&store.TaskRepo{}).CreateTx(context.Background(), tx, domain.FlowState{
+		TaskID:       "task-1",
+		CurrentPhase: domain.PhaseE,
+		Status:       domain.StatusRunning,
+		StateVersion: 1,
+		BudgetCapUSD: 10,
+		Workspace:    workspace,
+	}); err != nil {
+		t.Fatalf("CreateTx: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	return db, dir, workspace
+}
+
+// addWorker creates a builder worker of task-1 in phase E.
+func addWorker(t *testing.T, db *sql.DB, workerID string, state domain.WorkerState, owns []string, sheet *domain.CapabilitySheet) {
+	t.Helper()
+	if err := (&store.WorkerRepo{}).Create(context.Background(), db, domain.WorkerRef{
+		WorkerID:      workerID,
+		TaskID:        "task-1",
+		Phase:         domain.PhaseE,
+		Role:          "builder",
+		State:         state,
+		FileOwnership: owns,
+		Capabilities:  sheet,
+		CreatedAtUnix: 1,
+	}); err != nil {
+		t.Fatalf("create worker: %v", err)
+	}
+}
+
+// setupExecutor creates task-1 with worker w-1 in state and an Executor
+// allowing sh.
+func setupExecutor(t *testing.T, state domain.WorkerState, sheet *domain.CapabilitySheet) *Executor {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	db, dir, _ := setupTask(t)
+	addWorker(t, db, "w-1", state, nil, sheet)
+
+	g := guard.NewGuard(db, workflow.NewBudgetGovernor(db), team.NewPermissionBroker(db), guard.GuardConfig{
+		MaxRounds:          3,
+		RateLimitPerMinute: 100,
+	})
+	return NewExecutor(db, g, artifact.NewBlobs(filepath.Join(dir, "blobs")), []string{"sh"}, "")
+}
+
+func auditActions(t *testing.T, db *sql.DB, category string) []string {
+	t.Helper()
+	recs, err := (&store.AuditRepo{}).ListByTask(context.Background(), db, "task-1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	var actions []string
+	for _, rec := range recs {
+		if rec.Category == category {
+			actions = append(actions, rec.Action)
+		}
+	}
+	return actions
+}
+
+func TestExec_RunsAndRecords(t *testing.T) {
+	e := setupExecutor(t, domain.WorkerRunning, nil)
+	result, err := e.Exec(context.Background(), "w-1", ExecRequest{
+		Command: "sh",
+		Args:    []string{"-c", "pwd; echo boom >&2; exit 3"},
+		Dir:     "sub",
+	})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, want 3", result.ExitCode)
+	}
+	if !strings.Contains(result.Output, "/ws/sub") || !strings.Contains(result.Output, "boom") {
+		t.Errorf("Output = %q, want the sub dir and stderr", result.Output)
+	}
+	if result.Artifact == nil || result.Artifact.Type != "exec_output" || result.Artifact.Path != "exec/w-1.log" {
+		t.Fatalf("Artifact = %+v", result.Artifact)
+	}
+	if result.Artifact.Hash != artifact.Hash([]byte(result.Output)) {
+		t.Errorf("artifact hash does not match output")
+	}
+	if got := auditActions(t, e.DB, "exec"); len(got) != 1 || got[0] != "exec" {
+		t.Errorf("audit actions = %v, want [exec]", got)
+	}
+}
+
+func TestExec_TruncatesOutput(t *testing.T) {
+	e := setupExecutor(t, domain.WorkerRunning, nil)
+	e.MaxOutput = 4
+	result, err := e.Exec(context.Background(), "w-1", ExecRequest{Command: "sh", Args: []string{"-c", "printf abcdefgh"}})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if result.Output != "efgh" || !result.Truncated {
+		t.Errorf("Output = %q truncated=%v, want tail efgh", result.Output, result.Truncated)
+	}
+}
+
+func TestExec_Refusals(t *testing.T) {
+	reviewer := &domain.CapabilitySheet{TaskID: "task-1", AllowedPaths: []string{"./"}, AllowedCommands: []string{"read"}}
+	cases := []struct {
+		name  string
+		state domain.WorkerState
+		sheet *domain.CapabilitySheet
+		req   ExecRequest
+		code  int
+	}{
+		{"not allowlisted", domain.WorkerRunning, nil, ExecRequest{Command: "rm", Args: []string{"-rf", "."}}, domain.ErrForbiddenOperation.Code},
+		{"path to program", domain.WorkerRunning, nil, ExecRequest{Command: "/bin/sh"}, domain.ErrForbiddenOperation.Code},
+		{"dir escape", domain.WorkerRunning, nil, ExecRequest{Command: "sh", Dir: "sub/../../"}, domain.ErrPermissionDenied.Code},
+		{"absolute dir", domain.WorkerRunning, nil, ExecRequest{Command: "sh", Dir: "/tmp"}, domain.ErrPermissionDenied.Code},
+		{"reviewer sheet", domain.WorkerRunning, reviewer, ExecRequest{Command: "sh", Args: []string{"-c", "true"}}, domain.ErrPermissionDenied.Code},
+		{"finished worker", domain.WorkerDone, nil, ExecRequest{Command: "sh", Args: []string{"-c", "true"}}, domain.ErrForbiddenOperation.Code},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := setupExecutor(t, tc.state, tc.sheet)
+			_, err := e.Exec(context.Background(), "w-1", tc.req)
+			if err == nil {
+				t.Fatal("expected refusal")
+			}
+			if engErr, ok := err.(*domain.EngineError); !ok || engErr.Code != tc.code {
+				t.Errorf("err = %v, want code %d", err, tc.code)
+			}
+			if got := auditActions(t, e.DB, "exec"); len(got) != 1 || got[0] != "exec_denied" {
+				t.Errorf("audit actions = %v, want [exec_denied]", got)
+			}
+		})
+	}
+}
+
+func TestExec_UnknownWorker(t *testing.T) {
+	e := setupExecutor(t, domain.WorkerRunning, nil)
+	if _, err := e.Exec(context.Background(), "w-missing", ExecRequest{Command: "sh"}); err != domain.ErrWorkerNotFound {
+		t.Fatalf("err = %v, want ErrWorkerNotFound", err)
+	}
+}
@@ -0,0 +1,314 @@
+package sandbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/artifact"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/guard"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/team"
+)
+
+// Defaults for Files limits.
+const (
+	DefaultLeaseSec = 60
+	DefaultMaxRead  = 1 << 20
+)
+
+// FileEntry is one entry of a directory listing. Path is relative to the
+// workspace.
+type FileEntry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Dir  bool   `json:"dir,omitempty"`
+	Size int64  `json:"size"`
+}
+
+// FileContent is a file read through the proxy. Content keeps the head of
+// files larger than the read limit; Hash and Size describe the whole file.
+type FileContent struct {
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	Hash      string `json:"hash"`
+	Size      int64  `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// WriteRequest replaces the content of a file. IntentID names a pending
+// intent the worker already holds on Path; without one the proxy acquires
+// an intent for the write itself.
+type WriteRequest struct {
+	Path     string `json:"path"`
+	Content  string `json:"content"`
+	IntentID string `json:"intentId,omitempty"`
+}
+
+// WriteResult is the outcome of a write. A Queued write did not happen: its
+// intent waits behind another on the file and can be retried with IntentID
+// once granted.
+type WriteResult struct {
+	Path     string `json:"path"`
+	IntentID string `json:"intentId"`
+	PreHash  string `json:"preHash"`
+	PostHash string `json:"postHash,omitempty"`
+	Queued   bool   `json:"queued,omitempty"`
+}
+
+// Files proxies worker file access to the flow's workspace. Every operation
+// is checked by the permission broker against the worker's capability sheet
+// ("read" for reads and listings, "write" for writes), and every write goes
+// through an intent lock, so ownership, conflicts, and pre-hashes apply.
+// Writes and refusals are audited.
+type Files struct {
+	DB         *sql.DB
+	Broker     *team.PermissionBroker
+	Intents    *team.IntentResolver
+	WorkerRepo *store.WorkerRepo
+	TaskRepo   *store.TaskRepo
+	IntentRepo *store.IntentRepo
+	AuditRepo  *store.AuditRepo
+	// Workspace is the root of flows without their own.
+	Workspace string
+	// LeaseSec is the lease of intents the proxy acquires for writes.
+	LeaseSec int
+	// MaxRead caps the content returned by Read.
+	MaxRead int64
+	// Guard, if set, applies its file operation rate limit.
+	Guard *guard.Guard
+}
+
+// NewFiles creates a Files proxy with default repos and limits.
+func NewFiles(db *sql.DB, broker *team.PermissionBroker, intents *team.IntentResolver, workspace string) *Files {
+	return &Files{
+		DB:         db,
+		Broker:     broker,
+		Intents:    intents,
+		WorkerRepo: &store.WorkerRepo{},
+		TaskRepo:   &store.TaskRepo{},
+		IntentRepo: &store.IntentRepo{},
+		AuditRepo:  &store.AuditRepo{},
+		Workspace:  workspace,
+		LeaseSec:   DefaultLeaseSec,
+		MaxRead:    DefaultMaxRead,
+	}
+}
+
+// List returns the entries of directory p, sorted by name.
+func (f *Files) List(ctx context.Context, workerID, p string) ([]FileEntry, error) {
+	_, rel, full, err := f.open(ctx, workerID, p, "read")
+	if err != nil {
+		return nil, err
+	}
+	dirEntries, err := os.ReadDir(full)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domain.NewEngineError(domain.ErrFileNotFound.Code, "no directory "+rel)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", rel, err)
+	}
+	entries := make([]FileEntry, 0, len(dirEntries))
+	for _, d := range dirEntries {
+		entry := FileEntry{Name: d.Name(), Path: path.Join(rel, d.Name()), Dir: d.IsDir()}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			entry.Size = info.Size()
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// Read returns the content of file p.
+func (f *Files) Read(ctx context.Context, workerID, p string) (*FileContent, error) {
+	_, rel, full, err := f.open(ctx, workerID, p, "read")
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(full)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domain.NewEngineError(domain.ErrFileNotFound.Code, "no file "+rel)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", rel, err)
+	}
+	content := &FileContent{Path: rel, Hash: artifact.Hash(data), Size: int64(len(data))}
+	limit := f.MaxRead
+	if limit <= 0 {
+		limit = DefaultMaxRead
+	}
+	if int64(len(data)) > limit {
+		data = data[:limit]
+		content.Truncated = true
+	}
+	content.Content = string(data)
+	return content, nil
+}
+
+// Write replaces the content of file p, creating it and its directories if
+// needed, under an intent lock that is executed with the file's old and new
+// hashes. If the intent cannot be executed the old content is restored.
+func (f *Files) Write(ctx context.Context, workerID string, req WriteRequest) (*WriteResult, error) {
+	worker, rel, full, err := f.open(ctx, workerID, req.Path, "write")
+	if err != nil {
+		return nil, err
+	}
+	old, err := os.ReadFile(full)
+	existed := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read %s: %w", rel, err)
+	}
+	result := &WriteResult{Path: rel, PreHash: fileHash(old, existed), IntentID: req.IntentID}
+
+	if req.IntentID == "" {
+		result.IntentID = fmt.Sprintf("int-fs-%d", time.Now().UnixNano())
+		err := f.Intents.AcquireLock(ctx, domain.Intent{
+			IntentID:    result.IntentID,
+			TaskID:      worker.TaskID,
+			WorkerID:    worker.WorkerID,
+			TargetFile:  rel,
+			Operation:   "write",
+			PreHash:     result.PreHash,
+			PayloadHash: artifact.Hash([]byte(req.Content)),
+		}, f.LeaseSec)
+		if err == domain.ErrIntentQueued {
+			result.Queued = true
+			return result, nil
+		}
+		if err != nil {
+			return nil, f.deny(worker, "write", rel, err)
+		}
+	} else if err := f.checkIntent(ctx, worker, rel, req.IntentID, result.PreHash); err != nil {
+		return nil, f.deny(worker, "write", rel, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return nil, fmt.Errorf("create dir for %s: %w", rel, err)
+	}
+	if err := os.WriteFile(full, []byte(req.Content), 0o644); err != nil {
+		return nil, fmt.Errorf("write %s: %w", rel, err)
+	}
+	result.PostHash = artifact.Hash([]byte(req.Content))
+	if err := f.Intents.Execute(ctx, result.IntentID, result.PreHash, result.PostHash); err != nil {
+		if existed {
+			_ = os.WriteFile(full, old, 0o644)
+		} else {
+			_ = os.Remove(full)
+		}
+		return nil, f.deny(worker, "write", rel, err)
+	}
+	f.audit(worker, "fs_write", "info", rel, map[string]interface{}{
+		"intent":    result.IntentID,
+		"pre_hash":  result.PreHash,
+		"post_hash": result.PostHash,
+	})
+	return result, nil
+}
+
+// open loads a live worker, confines p to its workspace, and checks command
+// against its capability sheet. It returns the worker and the relative and
+// absolute paths.
+func (f *Files) open(ctx context.Context, workerID, p, command string) (*domain.WorkerRef, string, string, error) {
+	worker, err := f.WorkerRepo.GetByID(ctx, f.DB, workerID)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if err := checkLive(worker); err != nil {
+		return nil, "", "", f.deny(worker, command, p, err)
+	}
+	rel, err := cleanRel(p)
+	if err != nil {
+		return nil, "", "", f.deny(worker, command, p, err)
+	}
+
+	sheet := worker.Capabilities
+	if sheet == nil {
+		sheet = f.Broker.BuildCapabilitySheet(worker.TaskID, []string{"./"}, []string{"read", "write"})
+	}
+	allowed, err := f.Broker.CheckPermission(ctx, sheet, rel, command)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if !allowed {
+		return nil, "", "", f.deny(worker, command, rel, domain.ErrPermissionDenied)
+	}
+	if f.Guard != nil {
+		if err := f.Guard.CheckRate(ctx, worker.TaskID, "", guard.OpFile); err != nil {
+			return nil, "", "", f.deny(worker, command, rel, err)
+		}
+	}
+
+	root, err := workspaceRoot(ctx, f.DB, f.TaskRepo, worker.TaskID, f.Workspace)
+	if err != nil {
+		return nil, "", "", err
+	}
+	full, err := resolve(root, rel)
+	if err != nil {
+		return nil, "", "", f.deny(worker, command, rel, err)
+	}
+	return worker, rel, full, nil
+}
+
+// checkIntent verifies that intentID is a live intent of worker on rel
+// whose pre-hash matches the file.
+func (f *Files) checkIntent(ctx context.Context, worker *domain.WorkerRef, rel, intentID, current string) error {
+	intent, err := f.IntentRepo.GetByID(ctx, f.DB, intentID)
+	if err != nil {
+		return err
+	}
+	if intent.WorkerID != worker.WorkerID || intent.TargetFile != rel {
+		return domain.NewEngineError(domain.ErrForbiddenOperation.Code,
+			fmt.Sprintf("intent %s does not cover %s for worker %s", intentID, rel, worker.WorkerID))
+	}
+	if intent.Status != "pending" && intent.Status != "running" {
+		return domain.ErrIntentNotActive
+	}
+	if intent.LeaseUntil < time.Now().Unix() {
+		return domain.ErrLeaseExpired
+	}
+	if intent.PreHash != current {
+		return domain.ErrIntentHashMismatch
+	}
+	return nil
+}
+
+// fileHash is the hash of a file's content, or "" for a missing file.
+func fileHash(content []byte, exists bool) string {
+	if !exists {
+		return ""
+	}
+	return artifact.Hash(content)
+}
+
+// deny audits a refused operation and returns err.
+func (f *Files) deny(worker *domain.WorkerRef, op, p string, err error) error {
+	f.audit(worker, "fs_denied", "warning", p, map[string]interface{}{"op": op, "error": err.Error()})
+	return err
+}
+
+func (f *Files) audit(worker *domain.WorkerRef, action, severity, p string, decision map[string]interface{}) {
+	request, _ := json.Marshal(map[string]string{"worker": worker.WorkerID, "path": p})
+	data, _ := json.Marshal(decision)
+	now := time.Now()
+	_ = f.AuditRepo.Record(context.Background(), f.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-fs-%d", now.UnixNano()),
+		TaskID:       worker.TaskID,
+		Category:     "fs",
+		Actor:        worker.WorkerID,
+		Action:       action,
+		RequestJSON:  string(request),
+		DecisionJSON: string(data),
+		Severity:     severity,
+		CreatedAt:    now.Unix(),
+	})
+}
@@ -0,0 +1,226 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/artifact"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/team"
+)
+
+// setupFiles creates task-1 with a workspace holding sub/a.txt, a builder
+// w-1 owning sub/, a builder w-2 owning other/, and a read-only reviewer r-1.
+func setupFiles(t *testing.T) (*Files, string) {
+	t.Helper()
+	db, _, workspace := setupTask(t)
+	if err := os.WriteFile(filepath.Join(workspace, "sub", "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, ".env"), []byte("TOKEN=x"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	addWorker(t, db, "w-1", domain.WorkerRunning, []string{"sub/"}, nil)
+	addWorker(t, db, "w-2", domain.WorkerRunning, []string{"other/"}, nil)
+	addWorker(t, db, "r-1", domain.WorkerRunning, nil, &domain.CapabilitySheet{
+		TaskID:          "task-1",
+		AllowedPaths:    []string{"./"},
+		AllowedCommands: []string{"read"},
+	})
+
+	broker := team.NewPermissionBroker(db)
+	resolver := &team.IntentResolver{
+		DB:         db,
+		IntentRepo: &store.IntentRepo{},
+		WorkerRepo: &store.WorkerRepo{},
+		AuditRepo:  &store.AuditRepo{},
+	}
+	return NewFiles(db, broker, resolver, ""), workspace
+}
+
+func engineCode(err error) int {
+	if engErr, ok := err.(*domain.EngineError); ok {
+		return engErr.Code
+	}
+	return 0
+}
+
+func TestFiles_ListAndRead(t *testing.T) {
+	f, _ := setupFiles(t)
+	ctx := context.Background()
+
+	entries, err := f.List(ctx, "r-1", "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != ".env" || entries[1].Path != "sub" || !entries[1].Dir {
+		t.Errorf("entries = %+v", entries)
+	}
+
+	content, err := f.Read(ctx, "r-1", "./sub/a.txt")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if content.Path != "sub/a.txt" || content.Content != "hello" || content.Hash != artifact.Hash([]byte("hello")) {
+		t.Errorf("content = %+v", content)
+	}
+
+	f.MaxRead = 2
+	content, err = f.Read(ctx, "r-1", "sub/a.txt")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if content.Content != "he" || !content.Truncated || content.Size != 5 {
+		t.Errorf("truncated content = %+v", content)
+	}
+
+	if _, err := f.Read(ctx, "r-1", "sub/missing.txt"); engineCode(err) != domain.ErrFileNotFound.Code {
+		t.Errorf("missing file err = %v, want ErrFileNotFound", err)
+	}
+}
+
+func TestFiles_Refusals(t *testing.T) {
+	f, workspace := setupFiles(t)
+	ctx := context.Background()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(workspace, "escape")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"denied pattern", func() error { _, err := f.Read(ctx, "w-1", ".env"); return err }(), domain.ErrPermissionDenied.Code},
+		{"parent dir", func() error { _, err := f.Read(ctx, "w-1", "../test.db"); return err }(), domain.ErrPermissionDenied.Code},
+		{"symlink escape", func() error { _, err := f.List(ctx, "w-1", "escape"); return err }(), domain.ErrPermissionDenied.Code},
+		{"reviewer write", func() error {
+			_, err := f.Write(ctx, "r-1", WriteRequest{Path: "sub/a.txt", Content: "x"})
+			return err
+		}(), domain.ErrPermissionDenied.Code},
+		{"not owner", func() error {
+			_, err := f.Write(ctx, "w-2", WriteRequest{Path: "sub/a.txt", Content: "x"})
+			return err
+		}(), domain.ErrFileOwnership.Code},
+		{"symlink write", func() error {
+			_, err := f.Write(ctx, "w-1", WriteRequest{Path: "escape/new.txt", Content: "x"})
+			return err
+		}(), domain.ErrPermissionDenied.Code},
+	}
+	for _, tc := range cases {
+		if engineCode(tc.err) != tc.code {
+			t.Errorf("%s: err = %v, want code %d", tc.name, tc.err, tc.code)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "sub", "a.txt")); string(data) != "hello" {
+		t.Errorf("sub/a.txt = %q, want it untouched", data)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); err == nil {
+		t.Error("write escaped the workspace")
+	}
+	if got := auditActions(t, f.DB, "fs"); len(got) != len(cases) {
+		t.Errorf("fs audit actions = %v, want %d denials", got, len(cases))
+	}
+}
+
+func TestFiles_WriteAcquiresIntent(t *testing.T) {
+	f, workspace := setupFiles(t)
+	ctx := context.Background()
+
+	result, err := f.Write(ctx, "w-1", WriteRequest{Path: "sub/new/b.txt", Content: "world"})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if result.PreHash != "" || result.PostHash != artifact.Hash([]byte("world")) || result.Queued {
+		t.Errorf("result = %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "sub", "new", "b.txt")); string(data) != "world" {
+		t.Errorf("sub/new/b.txt = %q", data)
+	}
+	intent, err := f.IntentRepo.GetByID(ctx, f.DB, result.IntentID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if intent.Status != "done" || intent.WorkerID != "w-1" || intent.TargetFile != "sub/new/b.txt" {
+		t.Errorf("intent = %+v", intent)
+	}
+	if got := auditActions(t, f.DB, "fs"); len(got) != 1 || got[0] != "fs_write" {
+		t.Errorf("fs audit actions = %v, want [fs_write]", got)
+	}
+}
+
+func TestFiles_WriteWithHeldIntent(t *testing.T) {
+	f, workspace := setupFiles(t)
+	ctx := context.Background()
+	hello := artifact.Hash([]byte("hello"))
+
+	hold := func(id, preHash string) {
+		t.Helper()
+		err := f.Intents.AcquireLock(ctx, domain.Intent{
+			IntentID:   id,
+			TaskID:     "task-1",
+			WorkerID:   "w-1",
+			TargetFile: "sub/a.txt",
+			Operation:  "write",
+			PreHash:    preHash,
+			CreatedAt:  time.Now().Unix(),
+		}, 60)
+		if err != nil {
+			t.Fatalf("AcquireLock: %v", err)
+		}
+	}
+
+	// A held lock blocks writes that do not name it.
+	hold("int-1", "stale")
+	if _, err := f.Write(ctx, "w-1", WriteRequest{Path: "sub/a.txt", Content: "x"}); engineCode(err) != domain.ErrIntentConflict.Code {
+		t.Errorf("unnamed write err = %v, want ErrIntentConflict", err)
+	}
+	if _, err := f.Write(ctx, "w-2", WriteRequest{Path: "other/c.txt", Content: "x", IntentID: "int-1"}); err == nil {
+		t.Error("w-2 wrote with w-1's intent")
+	}
+	if _, err := f.Write(ctx, "w-1", WriteRequest{Path: "sub/a.txt", Content: "x", IntentID: "int-1"}); engineCode(err) != domain.ErrIntentHashMismatch.Code {
+		t.Errorf("stale write err = %v, want ErrIntentHashMismatch", err)
+	}
+	if err := f.Intents.ReleaseLock(ctx, "int-1"); err != nil {
+		t.Fatalf("ReleaseLock: %v", err)
+	}
+
+	hold("int-2", hello)
+	result, err := f.Write(ctx, "w-1", WriteRequest{Path: "sub/a.txt", Content: "bye", IntentID: "int-2"})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if result.IntentID != "int-2" || result.PreHash != hello {
+		t.Errorf("result = %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "sub", "a.txt")); string(data) != "bye" {
+		t.Errorf("sub/a.txt = %q, want bye", data)
+	}
+}
+
+func TestFiles_WriteQueued(t *testing.T) {
+	f, workspace := setupFiles(t)
+	f.Intents.Queue = true
+	ctx := context.Background()
+
+	if err := f.Intents.AcquireLock(ctx, domain.Intent{
+		IntentID: "int-1", TaskID: "task-1", WorkerID: "w-1", TargetFile: "sub/a.txt", Operation: "write",
+	}, 60); err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	result, err := f.Write(ctx, "w-1", WriteRequest{Path: "sub/a.txt", Content: "x"})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !result.Queued || result.PostHash != "" {
+		t.Errorf("result = %+v, want queued", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "sub", "a.txt")); string(data) != "hello" {
+		t.Errorf("sub/a.txt = %q, want it untouched", data)
+	}
+}
@@ -0,0 +1,80 @@
+package sandbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// checkLive refuses workers that have finished, failed, or been cancelled.
+func checkLive(worker *domain.WorkerRef) error {
+	if worker.State != domain.WorkerCreated && worker.State != domain.WorkerRunning {
+		return domain.NewEngineError(domain.ErrForbiddenOperation.Code,
+			fmt.Sprintf("worker %s is %s", worker.WorkerID, worker.State))
+	}
+	return nil
+}
+
+// cleanRel returns p as a clean, slash-separated path relative to the
+// workspace, or ErrPermissionDenied if it is absolute or leaves it.
+func cleanRel(p string) (string, error) {
+	rel := path.Clean(filepath.ToSlash(p))
+	if path.IsAbs(rel) || filepath.IsAbs(p) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", domain.NewEngineError(domain.ErrPermissionDenied.Code,
+			fmt.Sprintf("path %q is outside the workspace", p))
+	}
+	return rel, nil
+}
+
+// workspaceRoot returns the workspace of taskID, or def for flows without
+// their own.
+func workspaceRoot(ctx context.Context, db *sql.DB, repo *store.TaskRepo, taskID, def string) (string, error) {
+	state, err := repo.GetByID(ctx, db, taskID)
+	if err != nil {
+		return "", err
+	}
+	if state.Workspace != "" {
+		return state.Workspace, nil
+	}
+	return def, nil
+}
+
+// resolve joins rel onto root and checks that symlinks do not lead out of
+// root. Only the part of the path that exists is resolved, so rel may name
+// a file yet to be written.
+func resolve(root, rel string) (string, error) {
+	full := filepath.Join(root, filepath.FromSlash(rel))
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("resolve workspace: %w", err)
+	}
+	existing, rest := full, ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", rel, err)
+	}
+	inside, err := filepath.Rel(realRoot, real)
+	if err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return "", domain.NewEngineError(domain.ErrPermissionDenied.Code,
+			fmt.Sprintf("path %q is outside the workspace", rel))
+	}
+	return filepath.Join(real, rest), nil
+}
@@ -0,0 +1,144 @@
+// Package seal encrypts payloads at rest with AES-256-GCM. Every task gets
+// its own key, derived from a master key and the task ID, and sealed values
+// are bound to their task, so a value copied into another task's row does
+// not open.
+//
+// A sealed value is text of the form "tbenc:v1:<key id>:<base64>", where the
+// key ID names the master key it was sealed with. A Keyring seals with its
+// current key and opens values sealed with any of its keys, so master keys
+// can be rotated without rewriting the database at once.
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// KeySize is the length of a master key: AES-256.
+const KeySize = 32
+
+// prefix starts every sealed value. Payloads are JSON, so no plaintext
+// starts with it.
+const prefix = "tbenc:v1:"
+
+// Keyring seals and opens payloads with a set of master keys.
+type Keyring struct {
+	current []byte
+	keys    map[string][]byte
+}
+
+// NewKeyring returns a Keyring sealing with current and opening values
+// sealed with current or any of previous. A nil current leaves new values
+// in plaintext, so a database can be decrypted by rotating to no key.
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	for i, key := range append([][]byte{current}, previous...) {
+		if key == nil && i == 0 {
+			continue
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("seal: key is %d bytes, want %d", len(key), KeySize)
+		}
+		k.keys[KeyID(key)] = key
+	}
+	k.current = current
+	return k, nil
+}
+
+// KeyID returns the ID sealed values record for master key key.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// IsSealed reports whether s is a sealed value.
+func IsSealed(s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
+
+// Seal encrypts plaintext for taskID with the current key. Empty values
+// and a Keyring without a current key leave plaintext as it is.
+func (k *Keyring) Seal(taskID, plaintext string) (string, error) {
+	if k == nil || k.current == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	aead, err := taskAEAD(k.current, taskID)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("seal: generate nonce: %w", err)
+	}
+	data := aead.Seal(nonce, nonce, []byte(plaintext), []byte(taskID))
+	return prefix + KeyID(k.current) + ":" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Open decrypts a value Seal returned for taskID. Values that are not
+// sealed are returned as they are. A value sealed with a key the Keyring
+// lacks fails with domain.ErrSealKeyMissing.
+func (k *Keyring) Open(taskID, value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", fmt.Errorf("seal: malformed sealed value")
+	}
+	var key []byte
+	if k != nil {
+		key = k.keys[id]
+	}
+	if key == nil {
+		return "", fmt.Errorf("%w: key %s", domain.ErrSealKeyMissing, id)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("seal: decode sealed value: %w", err)
+	}
+	aead, err := taskAEAD(key, taskID)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", fmt.Errorf("seal: sealed value too short")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(taskID))
+	if err != nil {
+		return "", fmt.Errorf("seal: open value of task %s: %w", taskID, err)
+	}
+	return string(plain), nil
+}
+
+// Current reports whether value is in the form Seal gives now: sealed with
+// the current key, or plaintext when there is none. Rotation rewrites the
+// values that are not.
+func (k *Keyring) Current(value string) bool {
+	if k == nil || k.current == nil {
+		return !IsSealed(value)
+	}
+	if value == "" {
+		return true
+	}
+	return strings.HasPrefix(value, prefix+KeyID(k.current)+":")
+}
+
+// taskAEAD returns the cipher of taskID's key under master key.
+func taskAEAD(master []byte, taskID string) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte("threebody payload key\x00" + taskID))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("seal: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
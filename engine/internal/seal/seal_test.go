@@ -0,0 +1,92 @@
+package seal
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestKeyring_SealOpen(t *testing.T) {
+	k, err := NewKeyring(testKey(1))
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	const plain = `{"code":"proprietary"}`
+	sealed, err := k.Seal("t1", plain)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "proprietary") {
+		t.Fatalf("sealed = %q", sealed)
+	}
+	if again, _ := k.Seal("t1", plain); again == sealed {
+		t.Error("two seals of one value are equal; want fresh nonces")
+	}
+	if got, err := k.Open("t1", sealed); err != nil || got != plain {
+		t.Errorf("Open = %q, %v", got, err)
+	}
+	if !k.Current(sealed) {
+		t.Error("Current = false for a value sealed with the current key")
+	}
+
+	// A value is bound to its task.
+	if _, err := k.Open("t2", sealed); err == nil {
+		t.Error("Open succeeded for another task")
+	}
+	// Plaintext and empty values pass through.
+	if got, err := k.Open("t1", plain); err != nil || got != plain {
+		t.Errorf("Open(plaintext) = %q, %v", got, err)
+	}
+	if got, _ := k.Seal("t1", ""); got != "" {
+		t.Errorf("Seal(\"\") = %q", got)
+	}
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	old, _ := NewKeyring(testKey(1))
+	sealed, _ := old.Seal("t1", "payload")
+
+	rotated, err := NewKeyring(testKey(2), testKey(1))
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	if got, err := rotated.Open("t1", sealed); err != nil || got != "payload" {
+		t.Errorf("Open with a previous key = %q, %v", got, err)
+	}
+	if rotated.Current(sealed) {
+		t.Error("Current = true for a value sealed with a previous key")
+	}
+
+	fresh, _ := NewKeyring(testKey(2))
+	if _, err := fresh.Open("t1", sealed); !errors.Is(err, domain.ErrSealKeyMissing) {
+		t.Errorf("Open without the key = %v, want ErrSealKeyMissing", err)
+	}
+
+	// Rotating to no key decrypts.
+	off, err := NewKeyring(nil, testKey(1))
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	if got, _ := off.Seal("t1", "payload"); got != "payload" {
+		t.Errorf("Seal without a current key = %q", got)
+	}
+	if off.Current(sealed) || !off.Current("payload") {
+		t.Error("without a current key only plaintext should be current")
+	}
+}
+
+func TestNewKeyring_BadKey(t *testing.T) {
+	if _, err := NewKeyring([]byte("short")); err == nil {
+		t.Error("NewKeyring accepted a short key")
+	}
+	if _, err := NewKeyring(testKey(1), nil); err == nil {
+		t.Error("NewKeyring accepted a nil previous key")
+	}
+}
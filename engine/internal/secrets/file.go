@@ -0,0 +1,165 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// KeySize is the length of a secrets file key: AES-256.
+const KeySize = 32
+
+// GenerateKey returns a new random key, base64-encoded.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("generate key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseKey decodes a base64 key of KeySize bytes.
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode secrets key: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secrets key is %d bytes, want %d", len(key), KeySize)
+	}
+	return key, nil
+}
+
+// sealedFile is the on-disk form of a secrets file: the JSON map of secrets
+// sealed with AES-GCM.
+type sealedFile struct {
+	Version int    `json:"version"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// File is an encrypted secrets file. A missing file holds no secrets and is
+// created by the first Set.
+type File struct {
+	path string
+	aead cipher.AEAD
+
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+// OpenFile opens the secrets file at path with key.
+func OpenFile(path string, key []byte) (*File, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("secrets key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("secrets key: %w", err)
+	}
+	f := &File{path: path, aead: aead, secrets: make(map[string]string)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secrets file: %w", err)
+	}
+	var sealed sealedFile
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, fmt.Errorf("parse secrets file: %w", err)
+	}
+	plain, err := aead.Open(nil, sealed.Nonce, sealed.Data, nil)
+	if err != nil {
+		return nil, errors.New("decrypt secrets file: wrong key or corrupted file")
+	}
+	if err := json.Unmarshal(plain, &f.secrets); err != nil {
+		return nil, fmt.Errorf("parse secrets: %w", err)
+	}
+	return f, nil
+}
+
+// Lookup returns the secret named key.
+func (f *File) Lookup(_ context.Context, key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.secrets[key]
+	if !ok {
+		return "", fmt.Errorf("no secret %q in %s", key, f.path)
+	}
+	return v, nil
+}
+
+// Names returns the names of the stored secrets, sorted.
+func (f *File) Names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, 0, len(f.secrets))
+	for name := range f.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set stores value under name and rewrites the file.
+func (f *File) Set(name, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secrets[name] = value
+	return f.save()
+}
+
+// Delete removes name and rewrites the file.
+func (f *File) Delete(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.secrets[name]; !ok {
+		return fmt.Errorf("no secret %q in %s", name, f.path)
+	}
+	delete(f.secrets, name)
+	return f.save()
+}
+
+// save seals the secrets with a fresh nonce and replaces the file
+// atomically, readable only by its owner. The caller holds mu.
+func (f *File) save() error {
+	plain, err := json.Marshal(f.secrets)
+	if err != nil {
+		return fmt.Errorf("encode secrets: %w", err)
+	}
+	sealed := sealedFile{Version: 1, Nonce: make([]byte, f.aead.NonceSize())}
+	if _, err := rand.Read(sealed.Nonce); err != nil {
+		return fmt.Errorf("generate nonce: %w", err)
+	}
+	sealed.Data = f.aead.Seal(nil, sealed.Nonce, plain, nil)
+	data, err := json.Marshal(sealed)
+	if err != nil {
+		return fmt.Errorf("encode secrets file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return fmt.Errorf("create secrets dir: %w", err)
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write secrets file: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace secrets file: %w", err)
+	}
+	return nil
+}
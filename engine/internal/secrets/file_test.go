@@ -0,0 +1,87 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	encoded, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	key, err := ParseKey(encoded)
+	if err != nil {
+		t.Fatalf("ParseKey: %v", err)
+	}
+	return key
+}
+
+func TestFile_SetReopenLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets", "store.json")
+	key := testKey(t)
+	f, err := OpenFile(path, key)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if err := f.Set("anthropic", "sk-ant-secret-value"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := f.Set("gemini", "gm-secret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if strings.Contains(string(data), "sk-ant-secret-value") {
+		t.Error("secrets file holds the value in plaintext")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm()&0o077 != 0 && os.PathSeparator == '/' {
+		t.Errorf("secrets file mode = %v, want owner-only", info.Mode().Perm())
+	}
+
+	f, err = OpenFile(path, key)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if v, err := f.Lookup(context.Background(), "anthropic"); err != nil || v != "sk-ant-secret-value" {
+		t.Errorf("Lookup = %q, %v", v, err)
+	}
+	if err := f.Delete("gemini"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if names := f.Names(); len(names) != 1 || names[0] != "anthropic" {
+		t.Errorf("Names = %v", names)
+	}
+	if _, err := f.Lookup(context.Background(), "gemini"); err == nil {
+		t.Error("deleted secret still resolves")
+	}
+}
+
+func TestFile_WrongKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	f, err := OpenFile(path, testKey(t))
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if err := f.Set("a", "b"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := OpenFile(path, testKey(t)); err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Errorf("err = %v, want wrong key error", err)
+	}
+}
+
+func TestParseKey_Invalid(t *testing.T) {
+	for _, s := range []string{"not base64!", "c2hvcnQ="} {
+		if _, err := ParseKey(s); err == nil {
+			t.Errorf("ParseKey(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DefaultKeychainService is the service name secrets are filed under.
+const DefaultKeychainService = "threebody"
+
+// Keychain reads secrets from the OS credential store through its command
+// line tool: the login Keychain via security on macOS, the Secret Service
+// (GNOME Keyring, KWallet) via secret-tool on Linux, and on Windows files
+// under %APPDATA%\threebody\secrets protected with DPAPI for the current
+// user, unprotected via PowerShell. The key is the account name.
+type Keychain struct {
+	Service string
+	// GOOS selects the store; empty means runtime.GOOS.
+	GOOS string
+	// Run runs a tool and returns its stdout; nil runs it with os/exec.
+	Run func(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// NewKeychain creates a Keychain for service on this OS.
+func NewKeychain(service string) *Keychain {
+	if service == "" {
+		service = DefaultKeychainService
+	}
+	return &Keychain{Service: service}
+}
+
+// Lookup returns the secret stored for account key.
+func (k *Keychain) Lookup(ctx context.Context, key string) (string, error) {
+	goos := k.GOOS
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	var name string
+	var args []string
+	switch goos {
+	case "darwin":
+		name, args = "security", []string{"find-generic-password", "-s", k.Service, "-a", key, "-w"}
+	case "linux", "freebsd", "openbsd", "netbsd":
+		name, args = "secret-tool", []string{"lookup", "service", k.Service, "account", key}
+	case "windows":
+		path := filepath.Join(os.Getenv("APPDATA"), k.Service, "secrets", key)
+		script := "Add-Type -AssemblyName System.Security; " +
+			"[Console]::Out.Write([Text.Encoding]::UTF8.GetString([Security.Cryptography.ProtectedData]::Unprotect(" +
+			"[IO.File]::ReadAllBytes('" + strings.ReplaceAll(path, "'", "''") + "'), $null, 'CurrentUser')))"
+		name, args = "powershell", []string{"-NoProfile", "-NonInteractive", "-Command", script}
+	default:
+		return "", fmt.Errorf("no keychain support on %s", goos)
+	}
+
+	run := k.Run
+	if run == nil {
+		run = runTool
+	}
+	out, err := run(ctx, name, args...)
+	if err != nil {
+		return "", fmt.Errorf("keychain lookup of %s/%s: %w", k.Service, key, err)
+	}
+	v := strings.TrimRight(string(out), "\r\n")
+	if v == "" {
+		return "", fmt.Errorf("keychain has no secret %s/%s", k.Service, key)
+	}
+	return v, nil
+}
+
+// runTool runs a command and returns its stdout. Its stderr is included in
+// the error, never its stdout, which holds the secret.
+func runTool(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %v: %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
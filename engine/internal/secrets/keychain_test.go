@@ -0,0 +1,52 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKeychain_Commands(t *testing.T) {
+	cases := []struct {
+		goos, tool, want string
+	}{
+		{"darwin", "security", "find-generic-password -s threebody -a anthropic -w"},
+		{"linux", "secret-tool", "lookup service threebody account anthropic"},
+		{"windows", "powershell", "ProtectedData]::Unprotect"},
+	}
+	for _, tc := range cases {
+		var gotName, gotArgs string
+		k := NewKeychain("")
+		k.GOOS = tc.goos
+		k.Run = func(_ context.Context, name string, args ...string) ([]byte, error) {
+			gotName, gotArgs = name, strings.Join(args, " ")
+			return []byte("sk-from-keychain\n"), nil
+		}
+		v, err := k.Lookup(context.Background(), "anthropic")
+		if err != nil || v != "sk-from-keychain" {
+			t.Errorf("%s: Lookup = %q, %v", tc.goos, v, err)
+		}
+		if gotName != tc.tool || !strings.Contains(gotArgs, tc.want) {
+			t.Errorf("%s: ran %s %s", tc.goos, gotName, gotArgs)
+		}
+	}
+}
+
+func TestKeychain_Errors(t *testing.T) {
+	k := NewKeychain("svc")
+	k.GOOS = "plan9"
+	if _, err := k.Lookup(context.Background(), "x"); err == nil || !strings.Contains(err.Error(), "plan9") {
+		t.Errorf("unsupported OS err = %v", err)
+	}
+
+	k.GOOS = "linux"
+	k.Run = func(context.Context, string, ...string) ([]byte, error) { return nil, errors.New("exit status 1") }
+	if _, err := k.Lookup(context.Background(), "x"); err == nil || !strings.Contains(err.Error(), "svc/x") {
+		t.Errorf("failed lookup err = %v", err)
+	}
+	k.Run = func(context.Context, string, ...string) ([]byte, error) { return nil, nil }
+	if _, err := k.Lookup(context.Background(), "x"); err == nil {
+		t.Error("empty keychain output resolved")
+	}
+}
@@ -0,0 +1,126 @@
+// Package secrets resolves references to credentials held outside the
+// config file. A provider env value of the form "${backend:key}" names a
+// secret: "${env:ANTHROPIC_API_KEY}" reads the engine's environment,
+// "${keychain:anthropic}" the OS keychain, and "${file:anthropic}" the
+// encrypted secrets file. References are resolved when a session is
+// launched, and the values go only to the child process.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// Backend looks up secrets by key.
+type Backend interface {
+	Lookup(ctx context.Context, key string) (string, error)
+}
+
+// BackendFunc adapts a function to Backend.
+type BackendFunc func(ctx context.Context, key string) (string, error)
+
+// Lookup calls f.
+func (f BackendFunc) Lookup(ctx context.Context, key string) (string, error) {
+	return f(ctx, key)
+}
+
+// refPattern matches a whole value that is a secret reference.
+var refPattern = regexp.MustCompile(`^\$\{([a-z]+):([^}]+)\}$`)
+
+// ParseRef splits a reference into its backend and key. ok is false for
+// plain values.
+func ParseRef(value string) (backend, key string, ok bool) {
+	m := refPattern.FindStringSubmatch(value)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// Env reads secrets from the engine's own environment.
+var Env = BackendFunc(func(_ context.Context, key string) (string, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", key)
+	}
+	return v, nil
+})
+
+// Resolver resolves references against its registered backends.
+type Resolver struct {
+	// OnResolve, if set, is called with every resolved value, for example
+	// to have it redacted from stored payloads.
+	OnResolve func(value string)
+
+	mu       sync.RWMutex
+	backends map[string]Backend
+}
+
+// NewResolver creates a Resolver with the env backend registered.
+func NewResolver() *Resolver {
+	r := &Resolver{backends: make(map[string]Backend)}
+	r.Register("env", Env)
+	return r
+}
+
+// Register makes b available as "${name:...}".
+func (r *Resolver) Register(name string, b Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backends[name] = b
+}
+
+// Backends returns the registered backend names, sorted.
+func (r *Resolver) Backends() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.backends))
+	for name := range r.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the secret value references, or value itself when it is
+// not a reference. Errors name the reference but never a value.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	name, key, ok := ParseRef(value)
+	if !ok {
+		return value, nil
+	}
+	r.mu.RLock()
+	b, ok := r.backends[name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("secret %s: unknown backend %q", value, name)
+	}
+	v, err := b.Lookup(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %w", value, err)
+	}
+	if r.OnResolve != nil {
+		r.OnResolve(v)
+	}
+	return v, nil
+}
+
+// ResolveEnv resolves every value of env into a new map.
+func (r *Resolver) ResolveEnv(ctx context.Context, env map[string]string) (map[string]string, error) {
+	if len(env) == 0 {
+		return env, nil
+	}
+	resolved := make(map[string]string, len(env))
+	for k, v := range env {
+		rv, err := r.Resolve(ctx, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		resolved[k] = rv
+	}
+	return resolved, nil
+}
@@ -0,0 +1,62 @@
+package secrets
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseRef(t *testing.T) {
+	cases := []struct {
+		in, backend, key string
+		ok               bool
+	}{
+		{"${env:ANTHROPIC_API_KEY}", "env", "ANTHROPIC_API_KEY", true},
+		{"${keychain:anthropic/prod}", "keychain", "anthropic/prod", true},
+		{"plain", "", "", false},
+		{"prefix-${env:X}", "", "", false},
+		{"${env:}", "", "", false},
+	}
+	for _, tc := range cases {
+		backend, key, ok := ParseRef(tc.in)
+		if backend != tc.backend || key != tc.key || ok != tc.ok {
+			t.Errorf("ParseRef(%q) = %q, %q, %v", tc.in, backend, key, ok)
+		}
+	}
+}
+
+func TestResolver_ResolveEnv(t *testing.T) {
+	t.Setenv("TB_TEST_SECRET", "from-env")
+	r := NewResolver()
+	var seen []string
+	r.OnResolve = func(v string) { seen = append(seen, v) }
+
+	got, err := r.ResolveEnv(context.Background(), map[string]string{
+		"KEY":   "${env:TB_TEST_SECRET}",
+		"PLAIN": "literal",
+	})
+	if err != nil {
+		t.Fatalf("ResolveEnv: %v", err)
+	}
+	if got["KEY"] != "from-env" || got["PLAIN"] != "literal" {
+		t.Errorf("ResolveEnv = %v", got)
+	}
+	if len(seen) != 1 || seen[0] != "from-env" {
+		t.Errorf("OnResolve saw %v, want only the secret", seen)
+	}
+}
+
+func TestResolver_Errors(t *testing.T) {
+	r := NewResolver()
+	ctx := context.Background()
+	if _, err := r.Resolve(ctx, "${vault:x}"); err == nil || !strings.Contains(err.Error(), `unknown backend "vault"`) {
+		t.Errorf("unknown backend err = %v", err)
+	}
+	_, err := r.ResolveEnv(ctx, map[string]string{"KEY": "${env:TB_TEST_UNSET_VARIABLE}"})
+	if err == nil || !strings.Contains(err.Error(), "KEY") || !strings.Contains(err.Error(), "TB_TEST_UNSET_VARIABLE") {
+		t.Errorf("unset env err = %v", err)
+	}
+	if got := r.Backends(); len(got) != 1 || got[0] != "env" {
+		t.Errorf("Backends = %v", got)
+	}
+}
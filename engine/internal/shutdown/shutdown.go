@@ -0,0 +1,95 @@
+// Package shutdown drains an engine's in-flight work before it exits, so
+// flows can be picked up again cleanly by the next engine to serve.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/mcp"
+	"github.com/anthropics/three-body-engine/internal/team"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// Coordinator drains the sessions this engine runs. Flows with sessions are
+// suspended first, so their runs are abandoned and a running flow is paused
+// for a scheduler to resume; then the sessions are asked to exit, their
+// workers are marked done, and pending cost deltas are written.
+type Coordinator struct {
+	Engine   *workflow.Engine
+	Sessions *mcp.SessionManager
+	Workers  *team.WorkerManager
+	// Costs, if set, is flushed once the sessions have stopped.
+	Costs *workflow.CostBatcher
+
+	// Instance names this engine in engine_shutdown events.
+	Instance string
+	// Grace is how long sessions get to exit after an interrupt before
+	// they are killed (default 10s).
+	Grace time.Duration
+}
+
+// New creates a Coordinator with the default grace period.
+func New(engine *workflow.Engine, sessions *mcp.SessionManager, workers *team.WorkerManager, costs *workflow.CostBatcher) *Coordinator {
+	return &Coordinator{
+		Engine:   engine,
+		Sessions: sessions,
+		Workers:  workers,
+		Costs:    costs,
+		Grace:    10 * time.Second,
+	}
+}
+
+// Drain suspends every flow with a session, stops the sessions, marks their
+// workers done, and flushes pending costs. It carries on past failures and
+// returns them joined.
+func (c *Coordinator) Drain(ctx context.Context) error {
+	sessions := c.Sessions.List()
+	byTask := make(map[string]int)
+	for _, sess := range sessions {
+		if sess.Config.TaskID != "" {
+			byTask[sess.Config.TaskID]++
+		}
+	}
+	tasks := make([]string, 0, len(byTask))
+	for taskID := range byTask {
+		tasks = append(tasks, taskID)
+	}
+	sort.Strings(tasks)
+
+	var errs []error
+	for _, taskID := range tasks {
+		payload := domain.EngineShutdownPayload{Instance: c.Instance, Sessions: byTask[taskID]}
+		if err := c.Engine.Suspend(ctx, taskID, payload); err != nil {
+			errs = append(errs, fmt.Errorf("suspend flow %s: %w", taskID, err))
+		}
+	}
+
+	grace := c.Grace
+	if grace <= 0 {
+		grace = 10 * time.Second
+	}
+	stopCtx, cancel := context.WithTimeout(ctx, grace)
+	c.Sessions.Shutdown(stopCtx)
+	cancel()
+
+	for _, sess := range sessions {
+		if sess.Config.WorkerID == "" {
+			continue
+		}
+		if err := c.Workers.Shutdown(ctx, sess.Config.WorkerID); err != nil {
+			errs = append(errs, fmt.Errorf("shut down worker %s: %w", sess.Config.WorkerID, err))
+		}
+	}
+
+	if c.Costs != nil {
+		if err := c.Costs.Flush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("flush costs: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
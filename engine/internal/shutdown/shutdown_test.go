@@ -0,0 +1,121 @@
+package shutdown
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/mcp"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/team"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+func TestCoordinator_Drain(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	reg := mcp.NewProviderRegistry()
+	if err := reg.Register(mcp.ProviderSpec{Name: domain.ProviderClaude, Command: "sh", Args: []string{"-c", "while :; do sleep 0.05; done"}}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	sessions := mcp.NewSessionManager(reg)
+	t.Cleanup(sessions.StopAll)
+	engine := workflow.NewEngine(db)
+	workers := team.NewWorkerManager(db, 5)
+	costs := workflow.NewCostBatcher(workflow.NewBudgetGovernor(db), &store.CostDeltaRepo{})
+
+	ctx := context.Background()
+	for _, taskID := range []string{"running", "blocked", "idle"} {
+		if err := engine.StartFlow(ctx, taskID, 10); err != nil {
+			t.Fatalf("StartFlow: %v", err)
+		}
+	}
+	if err := engine.Block(ctx, "blocked", "needs a human"); err != nil {
+		t.Fatalf("Block: %v", err)
+	}
+	var workerIDs []string
+	for _, taskID := range []string{"running", "running", "blocked"} {
+		w, err := workers.Spawn(ctx, domain.WorkerSpec{TaskID: taskID, Phase: domain.PhaseA, Role: "dev"})
+		if err != nil {
+			t.Fatalf("Spawn: %v", err)
+		}
+		workerIDs = append(workerIDs, w.WorkerID)
+		if _, err := sessions.Create(ctx, domain.ProviderClaude, domain.SessionConfig{TaskID: taskID, WorkerID: w.WorkerID, Workspace: t.TempDir()}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if err := costs.Add(ctx, "running", domain.CostDelta{AmountUSD: 0.5, Provider: domain.ProviderClaude, Phase: domain.PhaseA}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	c := New(engine, sessions, workers, costs)
+	c.Instance = "engine-1"
+	c.Grace = time.Second
+	if err := c.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+
+	for taskID, want := range map[string]struct {
+		status   domain.FlowStatus
+		sessions int
+	}{
+		"running": {domain.StatusPaused, 2},
+		"blocked": {domain.StatusBlocked, 1},
+	} {
+		state, err := engine.GetState(ctx, taskID)
+		if err != nil {
+			t.Fatalf("GetState: %v", err)
+		}
+		if state.Status != want.status {
+			t.Errorf("%s status = %s, want %s", taskID, state.Status, want.status)
+		}
+		ev, err := engine.EventRepo.LatestByType(ctx, db, taskID, domain.EventEngineShutdown)
+		if err != nil || ev == nil {
+			t.Fatalf("%s engine_shutdown event = %v, %v", taskID, ev, err)
+		}
+		var payload domain.EngineShutdownPayload
+		json.Unmarshal([]byte(ev.PayloadJSON), &payload)
+		if payload.Instance != "engine-1" || payload.Sessions != want.sessions {
+			t.Errorf("%s payload = %+v, want %d sessions", taskID, payload, want.sessions)
+		}
+	}
+	if state, _ := engine.GetState(ctx, "idle"); state.Status != domain.StatusRunning {
+		t.Errorf("flow without sessions = %s, want it left running", state.Status)
+	}
+	if ev, _ := engine.EventRepo.LatestByType(ctx, db, "idle", domain.EventEngineShutdown); ev != nil {
+		t.Errorf("flow without sessions got %+v", ev)
+	}
+
+	if n := len(sessions.List()); n != 0 {
+		t.Errorf("%d sessions left", n)
+	}
+	for _, id := range workerIDs {
+		w, err := workers.WorkerRepo.GetByID(ctx, db, id)
+		if err != nil || w.State != domain.WorkerDone {
+			t.Errorf("worker %s = %+v, %v, want done", id, w, err)
+		}
+	}
+	if costs.Pending() != 0 {
+		t.Errorf("%d cost deltas still pending", costs.Pending())
+	}
+	if state, _ := engine.GetState(ctx, "running"); state.BudgetUsedUSD != 0.5 {
+		t.Errorf("budget used = %v, want the flushed 0.5", state.BudgetUsedUSD)
+	}
+
+	// A scheduler resumes the paused flow once an engine serves again.
+	started, err := workflow.NewScheduler(engine, 0).Tick(ctx)
+	if err != nil || len(started) != 1 || started[0] != "running" {
+		t.Errorf("Tick = %v, %v, want the paused flow resumed", started, err)
+	}
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// ApprovalRepo handles persistence for Approval records.
+type ApprovalRepo struct{}
+
+// approvalColumns is the column list shared by every approval SELECT.
+const approvalColumns = "id, task_id, phase, round, actor, decision, comment, created_at"
+
+// scanApproval reads one approval row selected with approvalColumns.
+func scanApproval(row rowScanner) (domain.Approval, error) {
+	var a domain.Approval
+	var phase string
+	err := row.Scan(&a.ID, &a.TaskID, &phase, &a.Round, &a.Actor, &a.Decision, &a.Comment, &a.CreatedAt)
+	a.Phase = domain.Phase(phase)
+	return a, err
+}
+
+// Create inserts an approval and returns its ID.
+func (r *ApprovalRepo) Create(ctx context.Context, db *sql.DB, a domain.Approval) (int64, error) {
+	return r.create(ctx, db, a)
+}
+
+// CreateTx inserts an approval within an existing transaction and returns
+// its ID.
+func (r *ApprovalRepo) CreateTx(ctx context.Context, tx *sql.Tx, a domain.Approval) (int64, error) {
+	return r.create(ctx, tx, a)
+}
+
+func (r *ApprovalRepo) create(ctx context.Context, db execer, a domain.Approval) (int64, error) {
+	const q = `INSERT INTO approvals (task_id, phase, round, actor, decision, comment, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)`
+	res, err := db.ExecContext(ctx, q, a.TaskID, string(a.Phase), a.Round, a.Actor, a.Decision, a.Comment, a.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("create approval: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// Latest returns the most recent approval of the task's phase in round, or
+// nil if there is none.
+func (r *ApprovalRepo) Latest(ctx context.Context, db *sql.DB, taskID string, phase domain.Phase, round int) (*domain.Approval, error) {
+	q := `SELECT ` + approvalColumns + ` FROM approvals
+WHERE task_id = ? AND phase = ? AND round = ?
+ORDER BY id DESC
+LIMIT 1`
+	a, err := scanApproval(db.QueryRowContext(ctx, q, taskID, string(phase), round))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get latest approval: %w", err)
+	}
+	return &a, nil
+}
+
+// ListByTask returns a task's approvals in the order they were recorded.
+func (r *ApprovalRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]domain.Approval, error) {
+	q := `SELECT ` + approvalColumns + ` FROM approvals WHERE task_id = ? ORDER BY id`
+	rows, err := db.QueryContext(ctx, q, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list approvals: %w", err)
+	}
+	defer rows.Close()
+
+	var approvals []domain.Approval
+	for rows.Next() {
+		a, err := scanApproval(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan approval: %w", err)
+		}
+		approvals = append(approvals, a)
+	}
+	return approvals, rows.Err()
+}
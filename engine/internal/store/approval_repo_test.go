@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestApprovalRepo_LatestByRound(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &ApprovalRepo{}
+	if a, err := repo.Latest(ctx, db, "task-1", domain.PhaseF, 0); err != nil || a != nil {
+		t.Fatalf("Latest with none = %+v, %v; want nil", a, err)
+	}
+	for _, a := range []domain.Approval{
+		{TaskID: "task-1", Phase: domain.PhaseF, Round: 0, Actor: "alice", Decision: domain.ApprovalRejected, Comment: "missing docs", CreatedAt: 1},
+		{TaskID: "task-1", Phase: domain.PhaseF, Round: 1, Actor: "alice", Decision: domain.ApprovalApproved, CreatedAt: 2},
+		{TaskID: "task-2", Phase: domain.PhaseF, Round: 1, Actor: "bob", Decision: domain.ApprovalRejected, CreatedAt: 3},
+	} {
+		if _, err := repo.Create(ctx, db, a); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	a, err := repo.Latest(ctx, db, "task-1", domain.PhaseF, 0)
+	if err != nil || a == nil || a.Decision != domain.ApprovalRejected || a.Comment != "missing docs" {
+		t.Errorf("Latest round 0 = %+v, %v", a, err)
+	}
+	a, err = repo.Latest(ctx, db, "task-1", domain.PhaseF, 1)
+	if err != nil || a == nil || a.Decision != domain.ApprovalApproved {
+		t.Errorf("Latest round 1 = %+v, %v", a, err)
+	}
+	list, err := repo.ListByTask(ctx, db, "task-1")
+	if err != nil || len(list) != 2 || list[0].CreatedAt != 1 {
+		t.Errorf("ListByTask = %+v, %v", list, err)
+	}
+}
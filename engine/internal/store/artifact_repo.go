@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// ArtifactRepo handles persistence for the artifacts workers submit.
+type ArtifactRepo struct{}
+
+// artifactColumns is the column list shared by every artifact SELECT.
+const artifactColumns = "artifact_id, task_id, worker_id, phase, type, path, version, hash, created_at"
+
+// scanArtifact reads one artifact row selected with artifactColumns.
+func scanArtifact(row rowScanner) (domain.ArtifactRef, error) {
+	var a domain.ArtifactRef
+	var phase string
+	err := row.Scan(&a.ID, &a.TaskID, &a.WorkerID, &phase, &a.Type, &a.Path, &a.Version, &a.Hash, &a.CreatedAt)
+	a.Phase = domain.Phase(phase)
+	return a, err
+}
+
+// Create stores a as the next version of its task and path and returns the
+// stored artifact. If the latest version of the path already has a's hash,
+// that version is returned instead and nothing is written.
+func (r *ArtifactRepo) Create(ctx context.Context, db *sql.DB, a domain.ArtifactRef) (*domain.ArtifactRef, error) {
+	latest, err := r.Latest(ctx, db, a.TaskID, a.Path)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.Hash == a.Hash {
+		return latest, nil
+	}
+
+	a.Version = 1
+	if latest != nil {
+		a.Version = latest.Version + 1
+	}
+	const q = `INSERT INTO artifacts (` + artifactColumns + `)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = db.ExecContext(ctx, q, a.ID, a.TaskID, a.WorkerID, string(a.Phase), a.Type, a.Path, a.Version, a.Hash, a.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("create artifact: %w", err)
+	}
+	return &a, nil
+}
+
+// CreateTx inserts a exactly as given within an existing transaction, for
+// artifacts carried over from another flow.
+func (r *ArtifactRepo) CreateTx(ctx context.Context, tx *sql.Tx, a domain.ArtifactRef) error {
+	const q = `INSERT INTO artifacts (` + artifactColumns + `)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, q, a.ID, a.TaskID, a.WorkerID, string(a.Phase), a.Type, a.Path, a.Version, a.Hash, a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create artifact: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a single artifact version by its ID.
+func (r *ArtifactRepo) GetByID(ctx context.Context, db *sql.DB, artifactID string) (*domain.ArtifactRef, error) {
+	q := `SELECT ` + artifactColumns + ` FROM artifacts WHERE artifact_id = ?`
+	a, err := scanArtifact(db.QueryRowContext(ctx, q, artifactID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrArtifactNotFound
+		}
+		return nil, fmt.Errorf("get artifact: %w", err)
+	}
+	return &a, nil
+}
+
+// Latest returns the highest version of path in taskID, or nil if there is none.
+func (r *ArtifactRepo) Latest(ctx context.Context, db *sql.DB, taskID, path string) (*domain.ArtifactRef, error) {
+	q := `SELECT ` + artifactColumns + ` FROM artifacts
+WHERE task_id = ? AND path = ?
+ORDER BY version DESC LIMIT 1`
+	a, err := scanArtifact(db.QueryRowContext(ctx, q, taskID, path))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get latest artifact: %w", err)
+	}
+	return &a, nil
+}
+
+// ListByTask returns every version of a task's artifacts, ordered by path
+// and then by version.
+func (r *ArtifactRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]domain.ArtifactRef, error) {
+	q := `SELECT ` + artifactColumns + ` FROM artifacts WHERE task_id = ? ORDER BY path ASC, version ASC`
+	rows, err := db.QueryContext(ctx, q, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list artifacts: %w", err)
+	}
+	defer rows.Close()
+
+	var artifacts []domain.ArtifactRef
+	for rows.Next() {
+		a, err := scanArtifact(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan artifact: %w", err)
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, rows.Err()
+}
+
+// ListLatest returns the latest version of each of a task's artifacts,
+// ordered by path.
+func (r *ArtifactRepo) ListLatest(ctx context.Context, db *sql.DB, taskID string) ([]domain.ArtifactRef, error) {
+	return r.ListLatestAt(ctx, db, taskID, 0)
+}
+
+// ListLatestAt returns the latest version of each of a task's artifacts as
+// of at (unix seconds, inclusive; 0 for now), ordered by path.
+func (r *ArtifactRepo) ListLatestAt(ctx context.Context, db *sql.DB, taskID string, at int64) ([]domain.ArtifactRef, error) {
+	q := `SELECT ` + artifactColumns + ` FROM artifacts a
+WHERE task_id = ? AND version = (
+	SELECT MAX(version) FROM artifacts
+	WHERE task_id = a.task_id AND path = a.path AND (? = 0 OR created_at <= ?))
+ORDER BY path ASC`
+
+	rows, err := db.QueryContext(ctx, q, taskID, at, at)
+	if err != nil {
+		return nil, fmt.Errorf("list artifacts: %w", err)
+	}
+	defer rows.Close()
+
+	var artifacts []domain.ArtifactRef
+	for rows.Next() {
+		a, err := scanArtifact(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan artifact: %w", err)
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, rows.Err()
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestArtifactRepo_CreateVersions(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &ArtifactRepo{}
+	create := func(id, path, hash string) *domain.ArtifactRef {
+		t.Helper()
+		a, err := repo.Create(ctx, db, domain.ArtifactRef{ID: id, TaskID: "task-1", Type: "doc", Path: path, Hash: hash})
+		if err != nil {
+			t.Fatalf("Create %s: %v", id, err)
+		}
+		return a
+	}
+
+	if a := create("a1", "design.md", "h1"); a.Version != 1 {
+		t.Errorf("first version = %d, want 1", a.Version)
+	}
+	if a := create("a2", "design.md", "h2"); a.Version != 2 {
+		t.Errorf("second version = %d, want 2", a.Version)
+	}
+	// Resubmitting unchanged content returns the latest version as is.
+	if a := create("a3", "design.md", "h2"); a.ID != "a2" || a.Version != 2 {
+		t.Errorf("resubmission = %+v, want a2 version 2", a)
+	}
+	create("b1", "api.md", "h3")
+	if _, err := repo.Create(ctx, db, domain.ArtifactRef{ID: "c1", TaskID: "task-2", Type: "doc", Path: "design.md", Hash: "h1"}); err != nil {
+		t.Fatalf("Create other task: %v", err)
+	}
+
+	latest, err := repo.ListLatest(ctx, db, "task-1")
+	if err != nil {
+		t.Fatalf("ListLatest: %v", err)
+	}
+	if len(latest) != 2 || latest[0].ID != "b1" || latest[1].ID != "a2" {
+		t.Errorf("ListLatest = %+v, want [b1 a2]", latest)
+	}
+}
+
+func TestArtifactRepo_GetByID(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &ArtifactRepo{}
+	if _, err := repo.Create(ctx, db, domain.ArtifactRef{ID: "a1", TaskID: "task-1", Phase: domain.PhaseB, Type: "design", Path: "design.md", Hash: "h1"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	got, err := repo.GetByID(ctx, db, "a1")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Phase != domain.PhaseB || got.Path != "design.md" || got.Version != 1 {
+		t.Errorf("artifact = %+v", got)
+	}
+	if _, err := repo.GetByID(ctx, db, "missing"); err != domain.ErrArtifactNotFound {
+		t.Errorf("expected ErrArtifactNotFound, got %v", err)
+	}
+}
+
+func TestArtifactRepo_ListLatestAt(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &ArtifactRepo{}
+	for _, a := range []domain.ArtifactRef{
+		{ID: "a1", TaskID: "t1", Path: "design.md", Hash: "h1", CreatedAt: 100},
+		{ID: "a2", TaskID: "t1", Path: "design.md", Hash: "h2", CreatedAt: 200},
+		{ID: "b1", TaskID: "t1", Path: "api.md", Hash: "h3", CreatedAt: 300},
+	} {
+		if _, err := repo.Create(ctx, db, a); err != nil {
+			t.Fatalf("Create %s: %v", a.ID, err)
+		}
+	}
+
+	at, err := repo.ListLatestAt(ctx, db, "t1", 150)
+	if err != nil {
+		t.Fatalf("ListLatestAt: %v", err)
+	}
+	if len(at) != 1 || at[0].ID != "a1" {
+		t.Errorf("as of 150 = %+v, want a1 only", at)
+	}
+	now, err := repo.ListLatestAt(ctx, db, "t1", 0)
+	if err != nil {
+		t.Fatalf("ListLatestAt now: %v", err)
+	}
+	if len(now) != 2 || now[0].ID != "b1" || now[1].ID != "a2" {
+		t.Errorf("as of now = %+v, want b1 and a2", now)
+	}
+}
@@ -2,8 +2,11 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"strconv"
 
 	"github.com/anthropics/three-body-engine/internal/domain"
 )
@@ -11,33 +14,117 @@ import (
 // AuditRepo handles persistence for AuditRecord entries.
 type AuditRepo struct{}
 
-// Record inserts an audit record.
+// Record inserts an audit record at the end of its task's chain.
 func (r *AuditRepo) Record(ctx context.Context, db *sql.DB, rec domain.AuditRecord) error {
-	const q = `INSERT INTO audit_records (id, task_id, category, actor, action, request_json, decision_json, severity, created_at)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := db.ExecContext(ctx, q,
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("record audit: %w", err)
+	}
+	if err := r.RecordTx(ctx, tx, rec); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("record audit: %w", err)
+	}
+	return nil
+}
+
+// RecordTx inserts an audit record within an existing transaction. The
+// record's Seq, PrevHash, and Hash are set from the chain, not taken from
+// rec.
+func (r *AuditRepo) RecordTx(ctx context.Context, tx *sql.Tx, rec domain.AuditRecord) error {
+	const q = `INSERT INTO audit_records (id, task_id, category, actor, action, request_json, decision_json, severity, created_at, seq, prev_hash, hash)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	seq, prev, err := r.chainHead(ctx, tx, rec.TaskID)
+	if err != nil {
+		return fmt.Errorf("record audit: %w", err)
+	}
+	rec.Seq, rec.PrevHash = seq+1, prev
+	rec.RequestJSON = redactPayload(rec.RequestJSON)
+	rec.DecisionJSON = redactPayload(rec.DecisionJSON)
+	rec.Hash = auditHash(rec)
+
+	request, err := sealPayload(rec.TaskID, rec.RequestJSON)
+	if err != nil {
+		return fmt.Errorf("record audit: %w", err)
+	}
+	decision, err := sealPayload(rec.TaskID, rec.DecisionJSON)
+	if err != nil {
+		return fmt.Errorf("record audit: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, q,
 		rec.ID,
 		rec.TaskID,
 		rec.Category,
 		rec.Actor,
 		rec.Action,
-		rec.RequestJSON,
-		rec.DecisionJSON,
+		request,
+		decision,
 		rec.Severity,
 		rec.CreatedAt,
+		rec.Seq,
+		rec.PrevHash,
+		rec.Hash,
 	)
 	if err != nil {
 		return fmt.Errorf("record audit: %w", err)
 	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO audit_chains (task_id, head_seq, head_hash) VALUES (?, ?, ?)
+ON CONFLICT(task_id) DO UPDATE SET head_seq = excluded.head_seq, head_hash = excluded.head_hash`,
+		rec.TaskID, rec.Seq, rec.Hash)
+	if err != nil {
+		return fmt.Errorf("record audit: advance chain: %w", err)
+	}
 	return nil
 }
 
+// chainHead returns the position and hash of the last record in taskID's
+// chain.
+func (r *AuditRepo) chainHead(ctx context.Context, tx *sql.Tx, taskID string) (int64, string, error) {
+	var seq int64
+	var hash string
+	err := tx.QueryRowContext(ctx,
+		`SELECT head_seq, head_hash FROM audit_chains WHERE task_id = ?`,
+		taskID).Scan(&seq, &hash)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("read audit chain: %w", err)
+	}
+	return seq, hash, nil
+}
+
+// auditHash returns the chain hash of rec: the SHA-256 of its previous
+// hash and its content, with payloads in plaintext so that rotating the
+// encryption key leaves the chain intact. Each field is length-prefixed.
+func auditHash(rec domain.AuditRecord) string {
+	h := sha256.New()
+	for _, f := range []string{
+		rec.PrevHash,
+		strconv.FormatInt(rec.Seq, 10),
+		rec.ID,
+		rec.TaskID,
+		rec.Category,
+		rec.Actor,
+		rec.Action,
+		rec.RequestJSON,
+		rec.DecisionJSON,
+		rec.Severity,
+		strconv.FormatInt(rec.CreatedAt, 10),
+	} {
+		fmt.Fprintf(h, "%d:%s", len(f), f)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // ListByTask returns all audit records for a given task, ordered by creation time.
 func (r *AuditRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]domain.AuditRecord, error) {
-	const q = `SELECT id, task_id, category, actor, action, request_json, decision_json, severity, created_at
+	const q = `SELECT ` + auditColumns + `
 FROM audit_records
 WHERE task_id = ?
-ORDER BY created_at ASC`
+ORDER BY created_at ASC, seq ASC`
 
 	rows, err := db.QueryContext(ctx, q, taskID)
 	if err != nil {
@@ -47,12 +134,119 @@ ORDER BY created_at ASC`
 
 	var records []domain.AuditRecord
 	for rows.Next() {
-		var a domain.AuditRecord
-		if err := rows.Scan(&a.ID, &a.TaskID, &a.Category, &a.Actor, &a.Action,
-			&a.RequestJSON, &a.DecisionJSON, &a.Severity, &a.CreatedAt); err != nil {
-			return nil, fmt.Errorf("scan audit record: %w", err)
+		a, err := scanAudit(rows)
+		if err != nil {
+			return nil, err
 		}
 		records = append(records, a)
 	}
 	return records, rows.Err()
 }
+
+// Verify checks taskID's audit chain: that no record is missing between
+// the last pruned record and the chain's head, that each record follows the hash of
+// the one before it, and that each record's content still matches its
+// hash. Records from before chaining are counted but not checked.
+func (r *AuditRepo) Verify(ctx context.Context, db *sql.DB, taskID string) (*domain.AuditChainReport, error) {
+	report := &domain.AuditChainReport{TaskID: taskID}
+	var headSeq int64
+	var headHash string
+	err := db.QueryRowContext(ctx,
+		`SELECT head_seq, head_hash, pruned_seq, pruned_hash FROM audit_chains WHERE task_id = ?`,
+		taskID).Scan(&headSeq, &headHash, &report.PrunedThrough, &report.HeadHash)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("verify audit chain: %w", err)
+	}
+	report.HeadSeq = report.PrunedThrough
+
+	rows, err := db.QueryContext(ctx, `SELECT `+auditColumns+`
+FROM audit_records
+WHERE task_id = ?
+ORDER BY seq ASC`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("verify audit chain: %w", err)
+	}
+	defer rows.Close()
+
+	problem := func(a domain.AuditRecord, format string, args ...interface{}) {
+		report.Problems = append(report.Problems, domain.AuditChainProblem{
+			Seq: a.Seq, RecordID: a.ID, Problem: fmt.Sprintf(format, args...),
+		})
+	}
+	for rows.Next() {
+		a, err := scanAudit(rows)
+		if err != nil {
+			return nil, err
+		}
+		if a.Seq == 0 {
+			report.Unchained++
+			continue
+		}
+		report.Records++
+		switch {
+		case a.Seq > report.HeadSeq+1:
+			problem(a, "records %d to %d are missing", report.HeadSeq+1, a.Seq-1)
+		case a.PrevHash != report.HeadHash && report.HeadSeq == 0:
+			problem(a, "previous hash does not match the start of the chain")
+		case a.PrevHash != report.HeadHash:
+			problem(a, "previous hash does not match record %d", report.HeadSeq)
+		}
+		if auditHash(a) != a.Hash {
+			problem(a, "content does not match its hash")
+		}
+		report.HeadSeq, report.HeadHash = a.Seq, a.Hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("verify audit chain: %w", err)
+	}
+	switch {
+	case headSeq > report.HeadSeq:
+		problem(domain.AuditRecord{Seq: headSeq}, "records %d to %d are missing", report.HeadSeq+1, headSeq)
+	case headHash != report.HeadHash:
+		problem(domain.AuditRecord{Seq: headSeq}, "last record does not match the chain head")
+	}
+	report.Valid = len(report.Problems) == 0
+	return report, nil
+}
+
+// ListChainedTasks returns the IDs of the tasks that have audit records or
+// an audit chain, in order. A chain whose records were all deleted is
+// listed, so Verify can report them missing.
+func (r *AuditRepo) ListChainedTasks(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT task_id FROM audit_records UNION SELECT task_id FROM audit_chains ORDER BY task_id`)
+	if err != nil {
+		return nil, fmt.Errorf("list audited tasks: %w", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan audited task: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// auditColumns are the columns scanAudit reads.
+const auditColumns = `id, task_id, category, actor, action, request_json, decision_json, severity, created_at, seq, prev_hash, hash`
+
+// scanAudit reads an AuditRecord selected with auditColumns and opens its
+// payloads.
+func scanAudit(row rowScanner) (domain.AuditRecord, error) {
+	var a domain.AuditRecord
+	if err := row.Scan(&a.ID, &a.TaskID, &a.Category, &a.Actor, &a.Action,
+		&a.RequestJSON, &a.DecisionJSON, &a.Severity, &a.CreatedAt,
+		&a.Seq, &a.PrevHash, &a.Hash); err != nil {
+		return a, fmt.Errorf("scan audit record: %w", err)
+	}
+	var err error
+	if a.RequestJSON, err = openPayload(a.TaskID, a.RequestJSON); err != nil {
+		return a, fmt.Errorf("open audit record %s: %w", a.ID, err)
+	}
+	if a.DecisionJSON, err = openPayload(a.TaskID, a.DecisionJSON); err != nil {
+		return a, fmt.Errorf("open audit record %s: %w", a.ID, err)
+	}
+	return a, nil
+}
@@ -94,3 +94,106 @@ func TestAuditRepo_ListByTask_Empty(t *testing.T) {
 		t.Errorf("expected nil for empty result, got %v", got)
 	}
 }
+
+func TestAuditRepo_Verify(t *testing.T) {
+	db, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &AuditRepo{}
+	for i, id := range []string{"a1", "a2", "a3", "a4"} {
+		rec := domain.AuditRecord{ID: id, TaskID: "t1", Category: "c", Action: "a", RequestJSON: `{"n":1}`, CreatedAt: int64(i)}
+		if err := repo.Record(ctx, db, rec); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	repo.Record(ctx, db, domain.AuditRecord{ID: "b1", TaskID: "t2", Category: "c", Action: "a"})
+
+	got, _ := repo.ListByTask(ctx, db, "t1")
+	if len(got) != 4 || got[0].Seq != 1 || got[0].PrevHash != "" || got[1].PrevHash != got[0].Hash {
+		t.Fatalf("records = %+v, want a chain from seq 1", got)
+	}
+	report, err := repo.Verify(ctx, db, "t1")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !report.Valid || report.Records != 4 || report.HeadSeq != 4 || report.HeadHash != got[3].Hash {
+		t.Errorf("intact chain report = %+v", report)
+	}
+	if tasks, _ := repo.ListChainedTasks(ctx, db); len(tasks) != 2 || tasks[0] != "t1" {
+		t.Errorf("ListChainedTasks = %v", tasks)
+	}
+
+	// An altered record breaks its own hash; a deleted one leaves a gap.
+	db.Exec(`UPDATE audit_records SET decision_json = '{"allowed":true}' WHERE id = 'a2'`)
+	db.Exec(`DELETE FROM audit_records WHERE id = 'a3'`)
+	report, err = repo.Verify(ctx, db, "t1")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if report.Valid || len(report.Problems) != 2 ||
+		report.Problems[0].RecordID != "a2" || report.Problems[1].RecordID != "a4" ||
+		report.Problems[1].Problem != "records 3 to 3 are missing" {
+		t.Errorf("tampered chain report = %+v", report)
+	}
+
+	// Deleting the first or last records leaves a gap too.
+	repo.Record(ctx, db, domain.AuditRecord{ID: "b2", TaskID: "t2", Category: "c", Action: "a"})
+	repo.Record(ctx, db, domain.AuditRecord{ID: "b3", TaskID: "t2", Category: "c", Action: "a"})
+	db.Exec(`DELETE FROM audit_records WHERE id IN ('b1', 'b3')`)
+	report, _ = repo.Verify(ctx, db, "t2")
+	if report.Valid || len(report.Problems) != 2 ||
+		report.Problems[0].Problem != "records 1 to 1 are missing" ||
+		report.Problems[1].Problem != "records 3 to 3 are missing" {
+		t.Errorf("head and tail deletion report = %+v", report)
+	}
+}
+
+func TestAuditRepo_VerifyAfterRetention(t *testing.T) {
+	db, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &AuditRepo{}
+	// A record from before chaining is counted, not checked.
+	db.Exec(`INSERT INTO audit_records (id, task_id, category, action, created_at) VALUES ('old', 't1', 'c', 'a', 0)`)
+	for i, id := range []string{"a1", "a2", "a3"} {
+		repo.Record(ctx, db, domain.AuditRecord{ID: id, TaskID: "t1", Category: "c", Action: "a", CreatedAt: int64(i + 1)})
+	}
+
+	retention := &RetentionRepo{}
+	rows, err := retention.ListExpired(ctx, db, RetentionPolicy{Table: "audit_records", MaxRowsPerTask: 1}, 10, 100)
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("ListExpired = %d rows, %v", len(rows), err)
+	}
+	tx, _ := db.Begin()
+	ids := []int64{rows[0].RowID, rows[1].RowID, rows[2].RowID}
+	if _, err := retention.DeleteTx(ctx, tx, "audit_records", ids); err != nil {
+		t.Fatalf("DeleteTx: %v", err)
+	}
+	tx.Commit()
+
+	report, err := repo.Verify(ctx, db, "t1")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !report.Valid || report.PrunedThrough != 2 || report.Records != 1 || report.HeadSeq != 3 {
+		t.Errorf("pruned chain report = %+v", report)
+	}
+
+	// Once every record is pruned, the chain continues from the anchor.
+	rows, _ = retention.ListExpired(ctx, db, RetentionPolicy{Table: "audit_records", MaxAgeSec: 1}, 10, 100)
+	tx, _ = db.Begin()
+	retention.DeleteTx(ctx, tx, "audit_records", []int64{rows[0].RowID})
+	tx.Commit()
+	repo.Record(ctx, db, domain.AuditRecord{ID: "a4", TaskID: "t1", Category: "c", Action: "a", CreatedAt: 11})
+	if report, _ := repo.Verify(ctx, db, "t1"); !report.Valid || report.PrunedThrough != 3 || report.HeadSeq != 4 {
+		t.Errorf("report after full prune = %+v", report)
+	}
+}
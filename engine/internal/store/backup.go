@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"modernc.org/sqlite"
+)
+
+// backupConn is implemented by the modernc driver connection.
+type backupConn interface {
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+	NewRestore(srcURI string) (*sqlite.Backup, error)
+}
+
+// Backup copies the live database to dst with SQLite's online backup API,
+// so a consistent snapshot is taken without stopping the engine. The copy is
+// written next to dst and renamed into place once complete.
+func Backup(ctx context.Context, db *sql.DB, dst string) error {
+	tmp := dst + ".tmp"
+	_ = os.Remove(tmp)
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(dc interface{}) error {
+		bc, ok := dc.(backupConn)
+		if !ok {
+			return fmt.Errorf("driver does not support online backup")
+		}
+		b, err := bc.NewBackup(tmp)
+		if err != nil {
+			return err
+		}
+		return runBackup(b)
+	})
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("backup: %w", err)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("backup: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the database at path with the contents of the snapshot at
+// src. The snapshot must pass an integrity check first. The engine must not be
+// running against path; reopen it with NewDB afterwards to apply migrations.
+func Restore(ctx context.Context, path, src string) error {
+	if IsMemory(path) {
+		return fmt.Errorf("restore: %s is not kept between runs", path)
+	}
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	if err := CheckIntegrity(ctx, src); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)", path))
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(dc interface{}) error {
+		bc, ok := dc.(backupConn)
+		if !ok {
+			return fmt.Errorf("driver does not support online backup")
+		}
+		b, err := bc.NewRestore(src)
+		if err != nil {
+			return err
+		}
+		return runBackup(b)
+	})
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	return nil
+}
+
+// CheckIntegrity runs PRAGMA integrity_check against the database file at path.
+func CheckIntegrity(ctx context.Context, path string) error {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("integrity check %s: %w", path, err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check %s: %s", path, result)
+	}
+	return nil
+}
+
+// runBackup copies every page and releases the backup handle.
+func runBackup(b *sqlite.Backup) error {
+	for {
+		more, err := b.Step(-1)
+		if err != nil {
+			b.Finish()
+			return err
+		}
+		if !more {
+			break
+		}
+	}
+	return b.Finish()
+}
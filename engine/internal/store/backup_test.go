@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "live.db")
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+
+	ctx := context.Background()
+	audits := &AuditRepo{}
+	if err := audits.Record(ctx, db, domain.AuditRecord{ID: "before", TaskID: "t1", Category: "c", Action: "a", CreatedAt: 1}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	snapshot := filepath.Join(dir, "snapshot.db")
+	if err := Backup(ctx, db, snapshot); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if _, err := os.Stat(snapshot + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary backup file left behind: %v", err)
+	}
+	if err := CheckIntegrity(ctx, snapshot); err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+
+	// Changes made after the snapshot are lost on restore.
+	if err := audits.Record(ctx, db, domain.AuditRecord{ID: "after", TaskID: "t1", Category: "c", Action: "a", CreatedAt: 2}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	db.Close()
+
+	if err := Restore(ctx, path, snapshot); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	db, err = NewDB(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	records, err := audits.ListByTask(ctx, db, "t1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != "before" {
+		t.Errorf("records = %+v, want only the pre-snapshot record", records)
+	}
+}
+
+func TestRestore_RejectsCorruptSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.db")
+	if err := os.WriteFile(bad, []byte("not a database"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := Restore(context.Background(), filepath.Join(dir, "live.db"), bad); err == nil {
+		t.Error("expected restore from a corrupt snapshot to fail")
+	}
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// BudgetAlertRepo records the budget alerts fired for each task.
+type BudgetAlertRepo struct{}
+
+// Fire records alert and reports whether it is new. An alert already fired
+// for the task under the same name and cap is left as it was, so each
+// threshold is announced once per cap.
+func (r *BudgetAlertRepo) Fire(ctx context.Context, db *sql.DB, alert domain.BudgetAlert) (bool, error) {
+	const q = `INSERT OR IGNORE INTO budget_alerts (task_id, name, cap_usd, ratio, used_usd, fired_at)
+VALUES (?, ?, ?, ?, ?, ?)`
+	res, err := db.ExecContext(ctx, q, alert.TaskID, alert.Name, alert.CapUSD, alert.Ratio, alert.UsedUSD, alert.FiredAt)
+	if err != nil {
+		return false, fmt.Errorf("fire budget alert: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("fire budget alert: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListByTask returns the alerts fired for a task, oldest first.
+func (r *BudgetAlertRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]domain.BudgetAlert, error) {
+	const q = `SELECT task_id, name, cap_usd, ratio, used_usd, fired_at FROM budget_alerts
+WHERE task_id = ? ORDER BY fired_at ASC, ratio ASC, name ASC`
+	rows, err := db.QueryContext(ctx, q, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list budget alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var alerts []domain.BudgetAlert
+	for rows.Next() {
+		var a domain.BudgetAlert
+		if err := rows.Scan(&a.TaskID, &a.Name, &a.CapUSD, &a.Ratio, &a.UsedUSD, &a.FiredAt); err != nil {
+			return nil, fmt.Errorf("scan budget alert: %w", err)
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
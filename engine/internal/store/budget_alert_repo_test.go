@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestBudgetAlertRepo_FireOnce(t *testing.T) {
+	db, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	repo := &BudgetAlertRepo{}
+
+	alert := domain.BudgetAlert{TaskID: "t1", Name: "half", CapUSD: 10, Ratio: 0.5, UsedUSD: 5.5, FiredAt: 100}
+	if fired, err := repo.Fire(ctx, db, alert); err != nil || !fired {
+		t.Fatalf("first Fire = %v, %v; want true", fired, err)
+	}
+	alert.UsedUSD, alert.FiredAt = 6, 200
+	if fired, err := repo.Fire(ctx, db, alert); err != nil || fired {
+		t.Errorf("repeat Fire = %v, %v; want false", fired, err)
+	}
+
+	// A new cap re-arms the alert.
+	alert.CapUSD = 20
+	if fired, _ := repo.Fire(ctx, db, alert); !fired {
+		t.Error("Fire under a raised cap = false, want true")
+	}
+
+	alerts, err := repo.ListByTask(ctx, db, "t1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(alerts) != 2 || alerts[0].UsedUSD != 5.5 || alerts[1].CapUSD != 20 {
+		t.Errorf("alerts = %+v", alerts)
+	}
+	if others, _ := repo.ListByTask(ctx, db, "t2"); len(others) != 0 {
+		t.Errorf("t2 alerts = %+v", others)
+	}
+}
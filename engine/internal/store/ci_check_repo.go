@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// CICheckRepo records the latest result of each CI check on a task's branch.
+type CICheckRepo struct{}
+
+// Upsert records check, replacing the task's earlier result of the same
+// check, and reports whether its status or URL changed. An unchanged
+// result keeps the time it was first reported.
+func (r *CICheckRepo) Upsert(ctx context.Context, db *sql.DB, check domain.CICheck) (bool, error) {
+	const q = `INSERT INTO ci_checks (task_id, name, status, url, updated_at)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (task_id, name) DO UPDATE SET
+	status = excluded.status, url = excluded.url, updated_at = excluded.updated_at
+WHERE status != excluded.status OR url != excluded.url`
+	res, err := db.ExecContext(ctx, q, check.TaskID, check.Name, check.Status, check.URL, check.UpdatedAt)
+	if err != nil {
+		return false, fmt.Errorf("upsert ci check: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("upsert ci check: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Replace makes checks the task's complete set of results, dropping checks
+// no longer reported, and reports whether anything changed.
+func (r *CICheckRepo) Replace(ctx context.Context, db *sql.DB, taskID string, checks []domain.CICheck) (bool, error) {
+	changed := false
+	keep := make(map[string]bool, len(checks))
+	for _, c := range checks {
+		c.TaskID = taskID
+		ok, err := r.Upsert(ctx, db, c)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || ok
+		keep[c.Name] = true
+	}
+	existing, err := r.ListByTask(ctx, db, taskID)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range existing {
+		if keep[c.Name] {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, `DELETE FROM ci_checks WHERE task_id = ? AND name = ?`, taskID, c.Name); err != nil {
+			return false, fmt.Errorf("delete ci check: %w", err)
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// ListByTask returns a task's checks ordered by name.
+func (r *CICheckRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]domain.CICheck, error) {
+	const q = `SELECT task_id, name, status, url, updated_at FROM ci_checks
+WHERE task_id = ? ORDER BY name ASC`
+	rows, err := db.QueryContext(ctx, q, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list ci checks: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []domain.CICheck
+	for rows.Next() {
+		var c domain.CICheck
+		if err := rows.Scan(&c.TaskID, &c.Name, &c.Status, &c.URL, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan ci check: %w", err)
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
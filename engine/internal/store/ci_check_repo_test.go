@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestCICheckRepo_UpsertAndReplace(t *testing.T) {
+	db, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	repo := &CICheckRepo{}
+
+	unit := domain.CICheck{TaskID: "t1", Name: "unit", Status: domain.CIPending, UpdatedAt: 100}
+	if changed, err := repo.Upsert(ctx, db, unit); err != nil || !changed {
+		t.Fatalf("first Upsert = %v, %v; want changed", changed, err)
+	}
+	unit.UpdatedAt = 200
+	if changed, _ := repo.Upsert(ctx, db, unit); changed {
+		t.Error("repeating a result reported a change")
+	}
+	unit.Status = domain.CISuccess
+	if changed, _ := repo.Upsert(ctx, db, unit); !changed {
+		t.Error("a new status was not reported as a change")
+	}
+	repo.Upsert(ctx, db, domain.CICheck{TaskID: "t1", Name: "lint", Status: domain.CIFailure, UpdatedAt: 100})
+	repo.Upsert(ctx, db, domain.CICheck{TaskID: "t2", Name: "unit", Status: domain.CIFailure, UpdatedAt: 100})
+
+	checks, err := repo.ListByTask(ctx, db, "t1")
+	if err != nil || len(checks) != 2 || checks[0].Name != "lint" || checks[1].Status != domain.CISuccess || checks[1].UpdatedAt != 200 {
+		t.Fatalf("checks = %+v, %v", checks, err)
+	}
+
+	same := []domain.CICheck{{Name: "lint", Status: domain.CIFailure}, {Name: "unit", Status: domain.CISuccess}}
+	if changed, err := repo.Replace(ctx, db, "t1", same); err != nil || changed {
+		t.Errorf("Replace with the same set = %v, %v; want unchanged", changed, err)
+	}
+	if changed, _ := repo.Replace(ctx, db, "t1", same[1:]); !changed {
+		t.Error("dropping a check was not reported as a change")
+	}
+	if checks, _ := repo.ListByTask(ctx, db, "t1"); len(checks) != 1 || checks[0].Name != "unit" {
+		t.Errorf("checks after Replace = %+v", checks)
+	}
+	if checks, _ := repo.ListByTask(ctx, db, "t2"); len(checks) != 1 {
+		t.Errorf("another task's checks = %+v", checks)
+	}
+}
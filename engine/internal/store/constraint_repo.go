@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// ConstraintRepo handles persistence for per-task Constraint records.
+type ConstraintRepo struct{}
+
+// constraintColumns is the column list shared by every constraint SELECT.
+const constraintColumns = "constraint_id, task_id, text, status, created_at, resolved_at"
+
+// scanConstraint reads one constraint row selected with constraintColumns.
+func scanConstraint(row rowScanner) (domain.Constraint, error) {
+	var c domain.Constraint
+	err := row.Scan(&c.ConstraintID, &c.TaskID, &c.Text, &c.Status, &c.CreatedAt, &c.ResolvedAt)
+	return c, err
+}
+
+// Create inserts a new constraint. An empty status is stored as "active".
+func (r *ConstraintRepo) Create(ctx context.Context, db *sql.DB, c domain.Constraint) error {
+	return r.create(ctx, db, c)
+}
+
+// CreateTx inserts a new constraint within an existing transaction.
+func (r *ConstraintRepo) CreateTx(ctx context.Context, tx *sql.Tx, c domain.Constraint) error {
+	return r.create(ctx, tx, c)
+}
+
+func (r *ConstraintRepo) create(ctx context.Context, db execer, c domain.Constraint) error {
+	if c.Status == "" {
+		c.Status = "active"
+	}
+	const q = `INSERT INTO task_constraints (` + constraintColumns + `) VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := db.ExecContext(ctx, q, c.ConstraintID, c.TaskID, c.Text, c.Status, c.CreatedAt, c.ResolvedAt); err != nil {
+		return fmt.Errorf("create constraint: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a single constraint by its ID.
+func (r *ConstraintRepo) GetByID(ctx context.Context, db *sql.DB, constraintID string) (*domain.Constraint, error) {
+	q := `SELECT ` + constraintColumns + ` FROM task_constraints WHERE constraint_id = ?`
+	c, err := scanConstraint(db.QueryRowContext(ctx, q, constraintID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrConstraintNotFound
+		}
+		return nil, fmt.Errorf("get constraint: %w", err)
+	}
+	return &c, nil
+}
+
+// ListByTask returns a task's constraints in creation order, limited to
+// status unless it is empty.
+func (r *ConstraintRepo) ListByTask(ctx context.Context, db *sql.DB, taskID, status string) ([]domain.Constraint, error) {
+	q := `SELECT ` + constraintColumns + ` FROM task_constraints
+WHERE task_id = ? AND (? = '' OR status = ?)
+ORDER BY created_at ASC, constraint_id ASC`
+
+	rows, err := db.QueryContext(ctx, q, taskID, status, status)
+	if err != nil {
+		return nil, fmt.Errorf("list constraints: %w", err)
+	}
+	defer rows.Close()
+
+	var constraints []domain.Constraint
+	for rows.Next() {
+		c, err := scanConstraint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan constraint: %w", err)
+		}
+		constraints = append(constraints, c)
+	}
+	return constraints, rows.Err()
+}
+
+// Resolve marks an active constraint resolved at resolvedAt. Resolving a
+// resolved constraint is a no-op.
+func (r *ConstraintRepo) Resolve(ctx context.Context, db *sql.DB, constraintID string, resolvedAt int64) error {
+	const q = `UPDATE task_constraints SET status = 'resolved', resolved_at = ?
+WHERE constraint_id = ? AND status = 'active'`
+	res, err := db.ExecContext(ctx, q, resolvedAt, constraintID)
+	if err != nil {
+		return fmt.Errorf("resolve constraint: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("check rows affected: %w", err)
+	} else if n == 0 {
+		_, err := r.GetByID(ctx, db, constraintID)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestConstraintRepo_CreateAndList(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &ConstraintRepo{}
+	for _, c := range []domain.Constraint{
+		{ConstraintID: "c2", TaskID: "task-1", Text: "keep go 1.22", CreatedAt: 20},
+		{ConstraintID: "c1", TaskID: "task-1", Text: "no new dependencies", CreatedAt: 10},
+		{ConstraintID: "c3", TaskID: "task-2", Text: "other task", CreatedAt: 5},
+	} {
+		if err := repo.Create(ctx, db, c); err != nil {
+			t.Fatalf("Create %s: %v", c.ConstraintID, err)
+		}
+	}
+
+	got, err := repo.ListByTask(ctx, db, "task-1", "")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(got) != 2 || got[0].ConstraintID != "c1" || got[1].Text != "keep go 1.22" {
+		t.Errorf("ListByTask = %+v, want [c1 c2]", got)
+	}
+}
+
+func TestConstraintRepo_Resolve(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &ConstraintRepo{}
+	for _, c := range []domain.Constraint{
+		{ConstraintID: "c1", TaskID: "task-1", Text: "no new dependencies", CreatedAt: 10},
+		{ConstraintID: "c2", TaskID: "task-1", Text: "keep go 1.22", CreatedAt: 20},
+	} {
+		if err := repo.Create(ctx, db, c); err != nil {
+			t.Fatalf("Create %s: %v", c.ConstraintID, err)
+		}
+	}
+
+	if err := repo.Resolve(ctx, db, "c1", 30); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if err := repo.Resolve(ctx, db, "c1", 40); err != nil {
+		t.Fatalf("Resolve again: %v", err)
+	}
+	got, err := repo.GetByID(ctx, db, "c1")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Status != "resolved" || got.ResolvedAt != 30 {
+		t.Errorf("resolved constraint = %+v, want status resolved at 30", got)
+	}
+
+	active, err := repo.ListByTask(ctx, db, "task-1", "active")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(active) != 1 || active[0].ConstraintID != "c2" {
+		t.Errorf("active = %+v, want [c2]", active)
+	}
+
+	if err := repo.Resolve(ctx, db, "missing", 50); err != domain.ErrConstraintNotFound {
+		t.Errorf("Resolve missing = %v, want ErrConstraintNotFound", err)
+	}
+}
@@ -11,28 +11,88 @@ import (
 // CostDeltaRepo handles persistence for CostDelta records.
 type CostDeltaRepo struct{}
 
-// Create inserts a new cost delta record for a task.
+// Create inserts a new cost delta record for a task. A delta whose key was
+// already recorded for the task is ignored.
 func (r *CostDeltaRepo) Create(ctx context.Context, db *sql.DB, taskID string, delta domain.CostDelta) error {
-	const q = `INSERT INTO cost_deltas (task_id, input_tokens, output_tokens, amount_usd, provider, phase, created_at)
-VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := db.ExecContext(ctx, q,
+	_, err := r.create(ctx, db, taskID, delta)
+	return err
+}
+
+// CreateTx inserts a cost delta within an existing transaction and reports
+// whether it was inserted: false when its key was already recorded for the
+// task.
+func (r *CostDeltaRepo) CreateTx(ctx context.Context, tx *sql.Tx, taskID string, delta domain.CostDelta) (bool, error) {
+	return r.create(ctx, tx, taskID, delta)
+}
+
+func (r *CostDeltaRepo) create(ctx context.Context, db execer, taskID string, delta domain.CostDelta) (bool, error) {
+	res, err := db.ExecContext(ctx, insertCostDelta,
 		taskID,
 		delta.InputTokens,
 		delta.OutputTokens,
 		delta.AmountUSD,
 		string(delta.Provider),
 		string(delta.Phase),
+		delta.WorkerID,
+		delta.Key,
 		delta.CreatedAt,
 	)
 	if err != nil {
-		return fmt.Errorf("create cost delta: %w", err)
+		return false, fmt.Errorf("create cost delta: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("create cost delta: %w", err)
+	}
+	return n == 1, nil
+}
+
+// insertCostDelta inserts one cost delta, ignoring a duplicate key.
+const insertCostDelta = `INSERT OR IGNORE INTO cost_deltas (task_id, input_tokens, output_tokens, amount_usd, provider, phase, worker_id, dedupe_key, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// CreateBatchTx inserts several cost deltas for a task within an existing
+// transaction using a single prepared statement. Deltas whose key was
+// already recorded are ignored.
+func (r *CostDeltaRepo) CreateBatchTx(ctx context.Context, tx *sql.Tx, taskID string, deltas []domain.CostDelta) error {
+	stmt, err := tx.PrepareContext(ctx, insertCostDelta)
+	if err != nil {
+		return fmt.Errorf("prepare cost delta insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, delta := range deltas {
+		if _, err := stmt.ExecContext(ctx,
+			taskID,
+			delta.InputTokens,
+			delta.OutputTokens,
+			delta.AmountUSD,
+			string(delta.Provider),
+			string(delta.Phase),
+			delta.WorkerID,
+			delta.Key,
+			delta.CreatedAt,
+		); err != nil {
+			return fmt.Errorf("create cost delta: %w", err)
+		}
 	}
 	return nil
 }
 
+// HasKey reports whether a cost delta with the given dedupe key was
+// recorded for a task.
+func (r *CostDeltaRepo) HasKey(ctx context.Context, db *sql.DB, taskID, key string) (bool, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM cost_deltas WHERE task_id = ? AND dedupe_key = ?`, taskID, key).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("look up cost delta key: %w", err)
+	}
+	return n > 0, nil
+}
+
 // ListByTask returns all cost deltas for a task, ordered by creation time.
 func (r *CostDeltaRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]domain.CostDelta, error) {
-	const q = `SELECT input_tokens, output_tokens, amount_usd, provider, phase, created_at
+	const q = `SELECT input_tokens, output_tokens, amount_usd, provider, phase, worker_id, dedupe_key, created_at
 FROM cost_deltas
 WHERE task_id = ?
 ORDER BY created_at ASC`
@@ -47,7 +107,7 @@ ORDER BY created_at ASC`
 	for rows.Next() {
 		var d domain.CostDelta
 		var provider, phase string
-		if err := rows.Scan(&d.InputTokens, &d.OutputTokens, &d.AmountUSD, &provider, &phase, &d.CreatedAt); err != nil {
+		if err := rows.Scan(&d.InputTokens, &d.OutputTokens, &d.AmountUSD, &provider, &phase, &d.WorkerID, &d.Key, &d.CreatedAt); err != nil {
 			return nil, fmt.Errorf("scan cost delta: %w", err)
 		}
 		d.Provider = domain.Provider(provider)
@@ -56,3 +116,60 @@ ORDER BY created_at ASC`
 	}
 	return deltas, rows.Err()
 }
+
+// CostGroupings maps each grouping a breakdown accepts to the expression
+// that computes its key.
+var CostGroupings = map[string]string{
+	"phase":     "phase",
+	"provider":  "provider",
+	"worker":    "worker_id",
+	"day":       "strftime('%Y-%m-%d', created_at, 'unixepoch')",
+	"task":      "task_id",
+	"namespace": "(SELECT namespace FROM tasks WHERE tasks.task_id = cost_deltas.task_id)",
+}
+
+// CostFilter selects the cost deltas of a breakdown. Empty fields and zero
+// times do not filter; Until is exclusive.
+type CostFilter struct {
+	TaskID    string
+	Namespace string
+	Since     int64
+	Until     int64
+}
+
+// Breakdown sums the cost deltas matching filter by one of CostGroupings,
+// largest group first.
+func (r *CostDeltaRepo) Breakdown(ctx context.Context, db *sql.DB, groupBy string, filter CostFilter) (*domain.CostBreakdown, error) {
+	expr, ok := CostGroupings[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("cost breakdown: unknown grouping %q", groupBy)
+	}
+	q := `SELECT COALESCE(` + expr + `, ''), COUNT(*), SUM(input_tokens), SUM(output_tokens), SUM(amount_usd)
+FROM cost_deltas
+WHERE (? = '' OR task_id = ?)
+	AND (? = '' OR task_id IN (SELECT task_id FROM tasks WHERE namespace = ?))
+	AND (? = 0 OR created_at >= ?)
+	AND (? = 0 OR created_at < ?)
+GROUP BY 1 ORDER BY 5 DESC, 1 ASC`
+	rows, err := db.QueryContext(ctx, q,
+		filter.TaskID, filter.TaskID,
+		filter.Namespace, filter.Namespace,
+		filter.Since, filter.Since,
+		filter.Until, filter.Until,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cost breakdown: %w", err)
+	}
+	defer rows.Close()
+
+	out := &domain.CostBreakdown{GroupBy: groupBy, Groups: []domain.CostGroup{}}
+	for rows.Next() {
+		var g domain.CostGroup
+		if err := rows.Scan(&g.Key, &g.Deltas, &g.InputTokens, &g.OutputTokens, &g.AmountUSD); err != nil {
+			return nil, fmt.Errorf("scan cost group: %w", err)
+		}
+		out.TotalUSD += g.AmountUSD
+		out.Groups = append(out.Groups, g)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestCostDeltaRepo_Breakdown(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	tx, _ := db.BeginTx(ctx, nil)
+	(&TaskRepo{}).CreateTx(ctx, tx, domain.FlowState{TaskID: "t1", CurrentPhase: domain.PhaseA, Status: domain.StatusRunning})
+	(&TaskRepo{}).CreateTx(ctx, tx, domain.FlowState{TaskID: "t2", CurrentPhase: domain.PhaseA, Status: domain.StatusRunning, Namespace: "team-a"})
+	tx.Commit()
+
+	repo := &CostDeltaRepo{}
+	const day1, day2 = 1700000000, 1700000000 + 86400
+	for _, d := range []struct {
+		task  string
+		delta domain.CostDelta
+	}{
+		{"t1", domain.CostDelta{AmountUSD: 1, InputTokens: 100, Provider: "claude", Phase: domain.PhaseC, WorkerID: "w1", CreatedAt: day1}},
+		{"t1", domain.CostDelta{AmountUSD: 2, InputTokens: 50, Provider: "codex", Phase: domain.PhaseC, WorkerID: "w2", CreatedAt: day2}},
+		{"t1", domain.CostDelta{AmountUSD: 0.5, Provider: "claude", Phase: domain.PhaseE, WorkerID: "w1", CreatedAt: day2}},
+		{"t2", domain.CostDelta{AmountUSD: 4, Provider: "claude", Phase: domain.PhaseE, CreatedAt: day2}},
+	} {
+		if err := repo.Create(ctx, db, d.task, d.delta); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	got, err := repo.Breakdown(ctx, db, "worker", CostFilter{TaskID: "t1"})
+	if err != nil {
+		t.Fatalf("Breakdown: %v", err)
+	}
+	if got.TotalUSD != 3.5 || len(got.Groups) != 2 || got.Groups[0].Key != "w2" || got.Groups[1].AmountUSD != 1.5 || got.Groups[1].Deltas != 2 {
+		t.Errorf("by worker = %+v", got)
+	}
+
+	got, _ = repo.Breakdown(ctx, db, "day", CostFilter{})
+	if len(got.Groups) != 2 || got.Groups[0].Key != "2023-11-15" || got.Groups[0].AmountUSD != 6.5 {
+		t.Errorf("by day = %+v", got)
+	}
+
+	got, _ = repo.Breakdown(ctx, db, "namespace", CostFilter{Since: day2})
+	if len(got.Groups) != 2 || got.Groups[0].Key != "team-a" || got.Groups[1].Key != "default" || got.Groups[1].AmountUSD != 2.5 {
+		t.Errorf("by namespace since day 2 = %+v", got)
+	}
+
+	got, _ = repo.Breakdown(ctx, db, "phase", CostFilter{Namespace: "default", Until: day2})
+	if got.TotalUSD != 1 || len(got.Groups) != 1 || got.Groups[0].Key != "C" || got.Groups[0].InputTokens != 100 {
+		t.Errorf("by phase until day 2 = %+v", got)
+	}
+
+	if _, err := repo.Breakdown(ctx, db, "color", CostFilter{}); err == nil {
+		t.Error("unknown grouping accepted")
+	}
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// DeadLetterRepo handles persistence for session output lines that could
+// not be parsed.
+type DeadLetterRepo struct{}
+
+// deadLetterColumns is the column list shared by every dead letter SELECT.
+const deadLetterColumns = "id, session_id, task_id, worker_id, provider, adapter, raw, error, created_at, requeued_at"
+
+// scanDeadLetter reads one row selected with deadLetterColumns.
+func scanDeadLetter(row rowScanner) (domain.DeadLetterEvent, error) {
+	var d domain.DeadLetterEvent
+	var provider, adapter string
+	var raw []byte
+	err := row.Scan(&d.ID, &d.SessionID, &d.TaskID, &d.WorkerID, &provider, &adapter, &raw, &d.Error, &d.CreatedAt, &d.RequeuedAt)
+	d.Provider, d.Adapter, d.Raw = domain.Provider(provider), domain.Provider(adapter), string(raw)
+	return d, err
+}
+
+// Create stores a dead letter, with secrets in its line masked, and returns
+// its ID.
+func (r *DeadLetterRepo) Create(ctx context.Context, db *sql.DB, d domain.DeadLetterEvent) (int64, error) {
+	const q = `INSERT INTO dead_letter_events (session_id, task_id, worker_id, provider, adapter, raw, error, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := db.ExecContext(ctx, q, d.SessionID, d.TaskID, d.WorkerID, string(d.Provider), string(d.Adapter),
+		[]byte(redactPayload(d.Raw)), d.Error, d.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("create dead letter: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// CreateTx stores d within an existing transaction, keeping its requeue
+// time, for dead letters carried over from another instance. The ID is
+// assigned afresh.
+func (r *DeadLetterRepo) CreateTx(ctx context.Context, tx *sql.Tx, d domain.DeadLetterEvent) error {
+	const q = `INSERT INTO dead_letter_events (session_id, task_id, worker_id, provider, adapter, raw, error, created_at, requeued_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, q, d.SessionID, d.TaskID, d.WorkerID, string(d.Provider), string(d.Adapter),
+		[]byte(redactPayload(d.Raw)), d.Error, d.CreatedAt, d.RequeuedAt)
+	if err != nil {
+		return fmt.Errorf("create dead letter: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns one dead letter, or ErrDeadLetterNotFound.
+func (r *DeadLetterRepo) GetByID(ctx context.Context, db *sql.DB, id int64) (*domain.DeadLetterEvent, error) {
+	q := `SELECT ` + deadLetterColumns + ` FROM dead_letter_events WHERE id = ?`
+	d, err := scanDeadLetter(db.QueryRowContext(ctx, q, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get dead letter: %w", err)
+	}
+	return &d, nil
+}
+
+// DeadLetterFilter narrows List. Empty fields match everything.
+type DeadLetterFilter struct {
+	SessionID string
+	Provider  domain.Provider
+	// Pending keeps only the dead letters not yet requeued.
+	Pending bool
+	Limit   int
+}
+
+// List returns the dead letters matching f, newest first.
+func (r *DeadLetterRepo) List(ctx context.Context, db *sql.DB, f DeadLetterFilter) ([]domain.DeadLetterEvent, error) {
+	q := `SELECT ` + deadLetterColumns + ` FROM dead_letter_events
+WHERE (? = '' OR session_id = ?) AND (? = '' OR provider = ?) AND (? = 0 OR requeued_at = 0)
+ORDER BY id DESC`
+	args := []interface{}{f.SessionID, f.SessionID, string(f.Provider), string(f.Provider), f.Pending}
+	if f.Limit > 0 {
+		q += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []domain.DeadLetterEvent
+	for rows.Next() {
+		d, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan dead letter: %w", err)
+		}
+		letters = append(letters, d)
+	}
+	return letters, rows.Err()
+}
+
+// ListByTask returns a task's dead letters, oldest first.
+func (r *DeadLetterRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]domain.DeadLetterEvent, error) {
+	q := `SELECT ` + deadLetterColumns + ` FROM dead_letter_events WHERE task_id = ? ORDER BY id ASC`
+	rows, err := db.QueryContext(ctx, q, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []domain.DeadLetterEvent
+	for rows.Next() {
+		d, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan dead letter: %w", err)
+		}
+		letters = append(letters, d)
+	}
+	return letters, rows.Err()
+}
+
+// CountPending returns how many dead letters have not been requeued.
+func (r *DeadLetterRepo) CountPending(ctx context.Context, db *sql.DB) (int, error) {
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM dead_letter_events WHERE requeued_at = 0`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count dead letters: %w", err)
+	}
+	return n, nil
+}
+
+// MarkRequeued records that a dead letter was parsed at the given time.
+func (r *DeadLetterRepo) MarkRequeued(ctx context.Context, db *sql.DB, id, at int64) error {
+	if _, err := db.ExecContext(ctx, `UPDATE dead_letter_events SET requeued_at = ? WHERE id = ?`, at, id); err != nil {
+		return fmt.Errorf("mark dead letter requeued: %w", err)
+	}
+	return nil
+}
+
+// SetError replaces a dead letter's parse error after a failed requeue.
+func (r *DeadLetterRepo) SetError(ctx context.Context, db *sql.DB, id int64, msg string) error {
+	if _, err := db.ExecContext(ctx, `UPDATE dead_letter_events SET error = ? WHERE id = ?`, msg, id); err != nil {
+		return fmt.Errorf("update dead letter error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/redact"
+)
+
+func TestDeadLetterRepo_CreateAndList(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &DeadLetterRepo{}
+	letters := []domain.DeadLetterEvent{
+		{SessionID: "ses-a", TaskID: "t1", Provider: domain.ProviderClaude, Adapter: domain.ProviderClaude, Raw: "not json", Error: "bad", CreatedAt: 1},
+		{SessionID: "ses-b", TaskID: "t1", Provider: domain.ProviderCodex, Adapter: domain.ProviderCodex, Raw: "{", Error: "bad", CreatedAt: 2},
+		{SessionID: "ses-a", TaskID: "t1", Provider: domain.ProviderClaude, Adapter: domain.ProviderClaude, Raw: "also not json", Error: "bad", CreatedAt: 3},
+	}
+	var ids []int64
+	for _, d := range letters {
+		id, err := repo.Create(ctx, db, d)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	all, err := repo.List(ctx, db, DeadLetterFilter{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(all) != 3 || all[0].ID != ids[2] || all[0].Raw != "also not json" {
+		t.Errorf("List = %+v, want 3 newest first", all)
+	}
+	bySession, _ := repo.List(ctx, db, DeadLetterFilter{SessionID: "ses-a"})
+	if len(bySession) != 2 {
+		t.Errorf("List(ses-a) = %d, want 2", len(bySession))
+	}
+	byProvider, _ := repo.List(ctx, db, DeadLetterFilter{Provider: domain.ProviderCodex})
+	if len(byProvider) != 1 || byProvider[0].SessionID != "ses-b" {
+		t.Errorf("List(codex) = %+v", byProvider)
+	}
+	limited, _ := repo.List(ctx, db, DeadLetterFilter{Limit: 1})
+	if len(limited) != 1 {
+		t.Errorf("List(limit 1) = %d", len(limited))
+	}
+
+	if err := repo.MarkRequeued(ctx, db, ids[0], 10); err != nil {
+		t.Fatalf("MarkRequeued: %v", err)
+	}
+	pending, _ := repo.List(ctx, db, DeadLetterFilter{Pending: true})
+	if len(pending) != 2 {
+		t.Errorf("List(pending) = %d, want 2", len(pending))
+	}
+	if n, err := repo.CountPending(ctx, db); err != nil || n != 2 {
+		t.Errorf("CountPending = %d, %v, want 2", n, err)
+	}
+	got, err := repo.GetByID(ctx, db, ids[0])
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.RequeuedAt != 10 || got.Raw != "not json" {
+		t.Errorf("GetByID = %+v", got)
+	}
+}
+
+func TestDeadLetterRepo_RedactsAndNotFound(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	SetRedactor(redact.Default())
+	t.Cleanup(func() { SetRedactor(nil) })
+
+	ctx := context.Background()
+	repo := &DeadLetterRepo{}
+	id, err := repo.Create(ctx, db, domain.DeadLetterEvent{SessionID: "s", Raw: `{"env":"API_KEY=abcd1234efgh"`, Error: "bad", CreatedAt: 1})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	got, err := repo.GetByID(ctx, db, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if strings.Contains(got.Raw, "abcd1234efgh") {
+		t.Errorf("raw line stored unredacted: %q", got.Raw)
+	}
+	if _, err := repo.GetByID(ctx, db, id+1); err != domain.ErrDeadLetterNotFound {
+		t.Errorf("GetByID(missing) err = %v, want ErrDeadLetterNotFound", err)
+	}
+}
+
+func TestDeadLetterRepo_ListByTaskAndCreateTx(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &DeadLetterRepo{}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	for _, d := range []domain.DeadLetterEvent{
+		{SessionID: "ses-a", TaskID: "t1", Provider: domain.ProviderClaude, Adapter: domain.ProviderClaude, Raw: "first", Error: "bad", CreatedAt: 1, RequeuedAt: 5},
+		{SessionID: "ses-b", TaskID: "t2", Provider: domain.ProviderClaude, Adapter: domain.ProviderClaude, Raw: "other", Error: "bad", CreatedAt: 2},
+		{SessionID: "ses-a", TaskID: "t1", Provider: domain.ProviderClaude, Adapter: domain.ProviderClaude, Raw: "second", Error: "bad", CreatedAt: 3},
+	} {
+		if err := repo.CreateTx(ctx, tx, d); err != nil {
+			t.Fatalf("CreateTx: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	letters, err := repo.ListByTask(ctx, db, "t1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(letters) != 2 || letters[0].Raw != "first" || letters[1].Raw != "second" {
+		t.Fatalf("ListByTask = %+v, want first then second", letters)
+	}
+	if letters[0].RequeuedAt != 5 {
+		t.Errorf("RequeuedAt = %d, want the given 5", letters[0].RequeuedAt)
+	}
+	if n, err := repo.CountPending(ctx, db); err != nil || n != 2 {
+		t.Errorf("CountPending = %d, %v; want 2", n, err)
+	}
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// DecisionRepo handles persistence for Decision records.
+type DecisionRepo struct{}
+
+// decisionColumns is the column list shared by every decision SELECT.
+const decisionColumns = "decision_id, task_id, kind, subject_json, status, choice, actor, comment, created_at, resolved_at"
+
+// scanDecision reads one decision row selected with decisionColumns.
+func scanDecision(row rowScanner) (domain.Decision, error) {
+	var d domain.Decision
+	err := row.Scan(&d.DecisionID, &d.TaskID, &d.Kind, &d.SubjectJSON, &d.Status,
+		&d.Choice, &d.Actor, &d.Comment, &d.CreatedAt, &d.ResolvedAt)
+	return d, err
+}
+
+// Create inserts a new decision.
+func (r *DecisionRepo) Create(ctx context.Context, db *sql.DB, d domain.Decision) error {
+	return r.create(ctx, db, d)
+}
+
+// CreateTx inserts a new decision within an existing transaction.
+func (r *DecisionRepo) CreateTx(ctx context.Context, tx *sql.Tx, d domain.Decision) error {
+	return r.create(ctx, tx, d)
+}
+
+func (r *DecisionRepo) create(ctx context.Context, db execer, d domain.Decision) error {
+	const q = `INSERT INTO decisions (` + decisionColumns + `)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.ExecContext(ctx, q, d.DecisionID, d.TaskID, d.Kind, d.SubjectJSON, d.Status,
+		d.Choice, d.Actor, d.Comment, d.CreatedAt, d.ResolvedAt)
+	if err != nil {
+		return fmt.Errorf("create decision: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a single decision by its ID.
+func (r *DecisionRepo) GetByID(ctx context.Context, db *sql.DB, decisionID string) (*domain.Decision, error) {
+	q := `SELECT ` + decisionColumns + ` FROM decisions WHERE decision_id = ?`
+	d, err := scanDecision(db.QueryRowContext(ctx, q, decisionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrDecisionNotFound
+		}
+		return nil, fmt.Errorf("get decision: %w", err)
+	}
+	return &d, nil
+}
+
+// ListByTask returns a task's decisions in creation order, limited to status
+// unless it is empty.
+func (r *DecisionRepo) ListByTask(ctx context.Context, db *sql.DB, taskID, status string) ([]domain.Decision, error) {
+	q := `SELECT ` + decisionColumns + `
+FROM decisions
+WHERE task_id = ? AND (? = '' OR status = ?)
+ORDER BY created_at ASC, decision_id ASC`
+
+	rows, err := db.QueryContext(ctx, q, taskID, status, status)
+	if err != nil {
+		return nil, fmt.Errorf("list decisions: %w", err)
+	}
+	defer rows.Close()
+
+	var decisions []domain.Decision
+	for rows.Next() {
+		d, err := scanDecision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan decision: %w", err)
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, rows.Err()
+}
+
+// ResolveTx records the outcome of a pending decision within a transaction.
+// It returns false if the decision is no longer pending.
+func (r *DecisionRepo) ResolveTx(ctx context.Context, tx *sql.Tx, d domain.Decision) (bool, error) {
+	const q = `UPDATE decisions SET status = 'resolved', choice = ?, actor = ?, comment = ?, resolved_at = ?
+WHERE decision_id = ? AND status = 'pending'`
+	res, err := tx.ExecContext(ctx, q, d.Choice, d.Actor, d.Comment, d.ResolvedAt, d.DecisionID)
+	if err != nil {
+		return false, fmt.Errorf("resolve decision: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("check rows affected: %w", err)
+	}
+	return n > 0, nil
+}
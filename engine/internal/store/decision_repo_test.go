@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestDecisionRepo_CreateListResolve(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &DecisionRepo{}
+	for _, d := range []domain.Decision{
+		{DecisionID: "d1", TaskID: "t1", Kind: "intent_conflict", SubjectJSON: `{}`, Status: "pending", CreatedAt: 1},
+		{DecisionID: "d2", TaskID: "t1", Kind: "intent_conflict", SubjectJSON: `{}`, Status: "pending", CreatedAt: 2},
+		{DecisionID: "d3", TaskID: "t2", Kind: "intent_conflict", SubjectJSON: `{}`, Status: "pending", CreatedAt: 3},
+	} {
+		if err := repo.Create(ctx, db, d); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	ok, err := repo.ResolveTx(ctx, tx, domain.Decision{DecisionID: "d1", Choice: "i1", Actor: "alice", ResolvedAt: 5})
+	if err != nil || !ok {
+		t.Fatalf("ResolveTx = %v, %v; want true", ok, err)
+	}
+	if ok, _ := repo.ResolveTx(ctx, tx, domain.Decision{DecisionID: "d1", Choice: "i2"}); ok {
+		t.Error("ResolveTx of a resolved decision = true, want false")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	all, err := repo.ListByTask(ctx, db, "t1", "")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(all) != 2 || all[0].DecisionID != "d1" {
+		t.Errorf("all = %+v, want d1 and d2", all)
+	}
+	pending, err := repo.ListByTask(ctx, db, "t1", "pending")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(pending) != 1 || pending[0].DecisionID != "d2" {
+		t.Errorf("pending = %+v, want d2", pending)
+	}
+
+	got, err := repo.GetByID(ctx, db, "d1")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Status != "resolved" || got.Choice != "i1" || got.Actor != "alice" || got.ResolvedAt != 5 {
+		t.Errorf("d1 = %+v", got)
+	}
+	if _, err := repo.GetByID(ctx, db, "missing"); err != domain.ErrDecisionNotFound {
+		t.Errorf("GetByID(missing) = %v, want ErrDecisionNotFound", err)
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/anthropics/three-body-engine/internal/domain"
 )
@@ -11,16 +15,36 @@ import (
 // EventRepo handles persistence for WorkflowEvent records.
 type EventRepo struct{}
 
-// AppendTx inserts a workflow event within an existing transaction.
+// eventColumns is the column list shared by every workflow event SELECT.
+const eventColumns = "id, task_id, seq_no, phase, event_type, payload_json, schema_version, created_at"
+
+// scanEvent reads one row selected with eventColumns.
+func scanEvent(row rowScanner) (domain.WorkflowEvent, error) {
+	var e domain.WorkflowEvent
+	var phase string
+	err := row.Scan(&e.ID, &e.TaskID, &e.SeqNo, &phase, &e.EventType, &e.PayloadJSON, &e.SchemaVersion, &e.CreatedAt)
+	e.Phase = domain.Phase(phase)
+	return e, err
+}
+
+// AppendTx inserts a workflow event within an existing transaction. The
+// payload is validated against its type's schema, whose version is recorded
+// with the event; unknown event types and payloads that do not match return
+// ErrEventInvalid.
 func (r *EventRepo) AppendTx(ctx context.Context, tx *sql.Tx, event domain.WorkflowEvent) error {
-	const q = `INSERT INTO workflow_events (task_id, seq_no, phase, event_type, payload_json, created_at)
-VALUES (?, ?, ?, ?, ?, ?)`
-	_, err := tx.ExecContext(ctx, q,
+	version, err := domain.ValidateEvent(event.EventType, event.PayloadJSON)
+	if err != nil {
+		return err
+	}
+	const q = `INSERT INTO workflow_events (task_id, seq_no, phase, event_type, payload_json, schema_version, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, q,
 		event.TaskID,
 		event.SeqNo,
 		string(event.Phase),
-		event.EventType,
-		event.PayloadJSON,
+		string(event.EventType),
+		redactPayload(event.PayloadJSON),
+		version,
 		event.CreatedAt,
 	)
 	if err != nil {
@@ -32,12 +56,87 @@ VALUES (?, ?, ?, ?, ?, ?)`
 // ListByTask returns events for a task with sequence numbers greater than sinceSeq,
 // ordered by sequence number ascending.
 func (r *EventRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string, sinceSeq int64) ([]domain.WorkflowEvent, error) {
-	const q = `SELECT id, task_id, seq_no, phase, event_type, payload_json, created_at
+	return r.List(ctx, db, taskID, EventFilter{SinceSeq: sinceSeq})
+}
+
+// EventFilter narrows List. Empty fields match everything.
+type EventFilter struct {
+	SinceSeq int64              `json:"sinceSeq,omitempty"`
+	Types    []domain.EventType `json:"types,omitempty"`
+	Phase    domain.Phase       `json:"phase,omitempty"`
+	// Payload maps payload fields to the value they must hold. A key is a
+	// field name, or a dotted path into nested objects ("decision.allow").
+	// Values that parse as numbers or booleans match JSON numbers and
+	// booleans; others match strings.
+	Payload map[string]string `json:"payload,omitempty"`
+}
+
+// payloadKeyPattern matches the payload keys EventFilter accepts. Keys are
+// spliced into the query as JSON paths, so that expression indexes apply.
+var payloadKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// Validate reports a payload key EventFilter cannot query.
+func (f EventFilter) Validate() error {
+	for key := range f.Payload {
+		if !payloadKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid payload key %q", key)
+		}
+	}
+	return nil
+}
+
+// payloadValue converts a payload filter value to the SQL value
+// json_extract yields for the JSON value it stands for.
+func payloadValue(v string) interface{} {
+	switch v {
+	case "true":
+		return 1
+	case "false":
+		return 0
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return f
+	}
+	return v
+}
+
+// List returns a task's events matching f, ordered by sequence number
+// ascending.
+func (r *EventRepo) List(ctx context.Context, db *sql.DB, taskID string, f EventFilter) ([]domain.WorkflowEvent, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	var q strings.Builder
+	q.WriteString(`SELECT ` + eventColumns + `
 FROM workflow_events
-WHERE task_id = ? AND seq_no > ?
-ORDER BY seq_no ASC`
+WHERE task_id = ? AND seq_no > ?`)
+	args := []interface{}{taskID, f.SinceSeq}
+	if len(f.Types) > 0 {
+		q.WriteString(` AND event_type IN (?` + strings.Repeat(`, ?`, len(f.Types)-1) + `)`)
+		for _, t := range f.Types {
+			args = append(args, string(t))
+		}
+	}
+	if f.Phase != "" {
+		q.WriteString(` AND phase = ?`)
+		args = append(args, string(f.Phase))
+	}
+	keys := make([]string, 0, len(f.Payload))
+	for key := range f.Payload {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&q, ` AND json_extract(payload_json, '$.%s') = ?`, key)
+		args = append(args, payloadValue(f.Payload[key]))
+	}
+	q.WriteString(`
+ORDER BY seq_no ASC`)
 
-	rows, err := db.QueryContext(ctx, q, taskID, sinceSeq)
+	rows, err := db.QueryContext(ctx, q.String(), args...)
 	if err != nil {
 		return nil, fmt.Errorf("list events: %w", err)
 	}
@@ -45,13 +144,57 @@ ORDER BY seq_no ASC`
 
 	var events []domain.WorkflowEvent
 	for rows.Next() {
-		var e domain.WorkflowEvent
-		var phase string
-		if err := rows.Scan(&e.ID, &e.TaskID, &e.SeqNo, &phase, &e.EventType, &e.PayloadJSON, &e.CreatedAt); err != nil {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// ListRecent returns a task's last limit events, oldest first.
+func (r *EventRepo) ListRecent(ctx context.Context, db *sql.DB, taskID string, limit int) ([]domain.WorkflowEvent, error) {
+	const q = `SELECT ` + eventColumns + ` FROM (
+	SELECT ` + eventColumns + `
+	FROM workflow_events
+	WHERE task_id = ?
+	ORDER BY seq_no DESC
+	LIMIT ?)
+ORDER BY seq_no ASC`
+
+	rows, err := db.QueryContext(ctx, q, taskID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list recent events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []domain.WorkflowEvent
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan event: %w", err)
 		}
-		e.Phase = domain.Phase(phase)
 		events = append(events, e)
 	}
 	return events, rows.Err()
 }
+
+// LatestByType returns the task's most recent event of eventType, or nil if
+// it has none.
+func (r *EventRepo) LatestByType(ctx context.Context, db *sql.DB, taskID string, eventType domain.EventType) (*domain.WorkflowEvent, error) {
+	const q = `SELECT ` + eventColumns + `
+FROM workflow_events
+WHERE task_id = ? AND event_type = ?
+ORDER BY seq_no DESC
+LIMIT 1`
+
+	e, err := scanEvent(db.QueryRowContext(ctx, q, taskID, string(eventType)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get latest %s event: %w", eventType, err)
+	}
+	return &e, nil
+}
@@ -2,7 +2,10 @@ package store
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -22,9 +25,9 @@ func TestEventRepo_AppendAndList(t *testing.T) {
 	now := time.Now().Unix()
 
 	events := []domain.WorkflowEvent{
-		{TaskID: "task-1", SeqNo: 1, Phase: domain.PhaseA, EventType: "phase_start", PayloadJSON: "{}", CreatedAt: now},
-		{TaskID: "task-1", SeqNo: 2, Phase: domain.PhaseA, EventType: "phase_end", PayloadJSON: "{}", CreatedAt: now + 1},
-		{TaskID: "task-1", SeqNo: 3, Phase: domain.PhaseB, EventType: "phase_start", PayloadJSON: "{}", CreatedAt: now + 2},
+		{TaskID: "task-1", SeqNo: 1, Phase: domain.PhaseA, EventType: "x_phase_start", PayloadJSON: "{}", CreatedAt: now},
+		{TaskID: "task-1", SeqNo: 2, Phase: domain.PhaseA, EventType: "x_phase_end", PayloadJSON: "{}", CreatedAt: now + 1},
+		{TaskID: "task-1", SeqNo: 3, Phase: domain.PhaseB, EventType: "x_phase_start", PayloadJSON: "{}", CreatedAt: now + 2},
 	}
 
 	for _, e := range events {
@@ -74,7 +77,7 @@ func TestEventRepo_DuplicateSeqNo(t *testing.T) {
 
 	event := domain.WorkflowEvent{
 		TaskID: "task-dup", SeqNo: 1, Phase: domain.PhaseA,
-		EventType: "test", PayloadJSON: "{}", CreatedAt: now,
+		EventType: "x_test", PayloadJSON: "{}", CreatedAt: now,
 	}
 
 	tx, err := db.Begin()
@@ -118,3 +121,185 @@ func TestEventRepo_ListByTask_Empty(t *testing.T) {
 		t.Errorf("expected nil slice for empty result, got %v", got)
 	}
 }
+
+func TestEventRepo_ListRecent(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	repo := &EventRepo{}
+	for seq := int64(1); seq <= 5; seq++ {
+		if err := repo.AppendTx(ctx, tx, domain.WorkflowEvent{TaskID: "t1", SeqNo: seq, EventType: "x_e", PayloadJSON: "{}"}); err != nil {
+			t.Fatalf("AppendTx: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	events, err := repo.ListRecent(ctx, db, "t1", 3)
+	if err != nil {
+		t.Fatalf("ListRecent: %v", err)
+	}
+	if len(events) != 3 || events[0].SeqNo != 3 || events[2].SeqNo != 5 {
+		t.Errorf("events = %+v, want seq 3 to 5", events)
+	}
+}
+
+func TestEventRepo_AppendValidatesSchema(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	repo := &EventRepo{}
+
+	add := func(seq int64, eventType domain.EventType, payload string) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("BeginTx: %v", err)
+		}
+		defer tx.Rollback()
+		if err := repo.AppendTx(ctx, tx, domain.WorkflowEvent{TaskID: "t1", SeqNo: seq, EventType: eventType, PayloadJSON: payload}); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	isInvalid := func(err error) bool {
+		var engErr *domain.EngineError
+		return errors.As(err, &engErr) && engErr.Code == domain.ErrEventInvalid.Code
+	}
+
+	for name, tc := range map[string]struct {
+		eventType domain.EventType
+		payload   string
+	}{
+		"unknown type":   {"note", `{}`},
+		"unknown field":  {domain.EventFlowBlocked, `{"reason":"r","why":"x"}`},
+		"wrong type":     {domain.EventBudgetChanged, `{"to":"ten"}`},
+		"not an object":  {domain.EventFlowResumed, `[]`},
+		"bare prefix":    {domain.ExperimentalEventPrefix, `{}`},
+		"bad experiment": {"x_note", `{`},
+	} {
+		if err := add(1, tc.eventType, tc.payload); !isInvalid(err) {
+			t.Errorf("%s: err = %v, want ErrEventInvalid", name, err)
+		}
+	}
+
+	if err := add(1, domain.EventFlowBlocked, `{"reason":"needs review","cause":"gate"}`); err != nil {
+		t.Fatalf("append flow_blocked: %v", err)
+	}
+	if err := add(2, "x_note", `{"anything":[1,2]}`); err != nil {
+		t.Fatalf("append experimental: %v", err)
+	}
+	events, err := repo.ListByTask(ctx, db, "t1", 0)
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(events) != 2 || events[0].SchemaVersion != 1 || events[1].SchemaVersion != 0 {
+		t.Errorf("events = %+v, want schema versions 1 and 0", events)
+	}
+}
+
+func TestEventRepo_ListFilters(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	repo := &EventRepo{}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	for _, ev := range []domain.WorkflowEvent{
+		{SeqNo: 1, Phase: domain.PhaseA, EventType: domain.EventFlowStarted, PayloadJSON: `{}`},
+		{SeqNo: 2, Phase: domain.PhaseB, EventType: domain.EventPhaseTransition, PayloadJSON: `{"from":"A","to":"B","action":"advance","actor":"alice"}`},
+		{SeqNo: 3, Phase: domain.PhaseA, EventType: domain.EventPhaseTransition, PayloadJSON: `{"from":"B","to":"A","action":"rollback","actor":"bob","invalidated_intents":2}`},
+		{SeqNo: 4, Phase: domain.PhaseA, EventType: domain.EventBudgetChanged, PayloadJSON: `{"from":10,"to":20}`},
+		{SeqNo: 5, Phase: domain.PhaseA, EventType: domain.EventFlowBlocked, PayloadJSON: `{"reason":"gate","cause":"gate"}`},
+	} {
+		ev.TaskID = "t1"
+		if err := repo.AppendTx(ctx, tx, ev); err != nil {
+			t.Fatalf("AppendTx: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	seqs := func(f EventFilter) []int64 {
+		t.Helper()
+		events, err := repo.List(ctx, db, "t1", f)
+		if err != nil {
+			t.Fatalf("List(%+v): %v", f, err)
+		}
+		var out []int64
+		for _, ev := range events {
+			out = append(out, ev.SeqNo)
+		}
+		return out
+	}
+	for name, tc := range map[string]struct {
+		filter EventFilter
+		want   string
+	}{
+		"type":        {EventFilter{Types: []domain.EventType{domain.EventPhaseTransition}}, "[2 3]"},
+		"types":       {EventFilter{Types: []domain.EventType{domain.EventFlowStarted, domain.EventFlowBlocked}}, "[1 5]"},
+		"phase":       {EventFilter{Phase: domain.PhaseB}, "[2]"},
+		"since":       {EventFilter{SinceSeq: 3}, "[4 5]"},
+		"rollbacks":   {EventFilter{Payload: map[string]string{"action": "rollback"}}, "[3]"},
+		"two fields":  {EventFilter{Payload: map[string]string{"action": "advance", "actor": "bob"}}, "[]"},
+		"number":      {EventFilter{Payload: map[string]string{"to": "20"}}, "[4]"},
+		"type and id": {EventFilter{Types: []domain.EventType{domain.EventPhaseTransition}, Payload: map[string]string{"invalidated_intents": "2"}}, "[3]"},
+	} {
+		if got := fmt.Sprint(seqs(tc.filter)); got != tc.want {
+			t.Errorf("%s: seqs = %s, want %s", name, got, tc.want)
+		}
+	}
+
+	if _, err := repo.List(ctx, db, "t1", EventFilter{Payload: map[string]string{"a') OR 1=1 --": "x"}}); err == nil {
+		t.Error("List accepted a payload key that is not a field path")
+	}
+}
+
+func TestEventRepo_ListUsesIndexes(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	for q, index := range map[string]string{
+		`SELECT id FROM workflow_events WHERE task_id = 't1' AND seq_no > 0 AND event_type IN ('flow_failed')`:                       "idx_events_task_type",
+		`SELECT id FROM workflow_events WHERE task_id = 't1' AND seq_no > 0 AND json_extract(payload_json, '$.action') = 'rollback'`: "idx_events_task_action",
+	} {
+		rows, err := db.Query("EXPLAIN QUERY PLAN " + q)
+		if err != nil {
+			t.Fatalf("explain: %v", err)
+		}
+		var plan strings.Builder
+		for rows.Next() {
+			var id, parent, unused int
+			var detail string
+			if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+				t.Fatalf("scan plan: %v", err)
+			}
+			plan.WriteString(detail + "\n")
+		}
+		rows.Close()
+		if !strings.Contains(plan.String(), index) {
+			t.Errorf("plan for %s does not use %s:\n%s", q, index, plan.String())
+		}
+	}
+}
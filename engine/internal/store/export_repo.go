@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// exportSource is a table rows are exported from and its exported columns,
+// in output order.
+type exportSource struct {
+	table   string
+	columns []string
+}
+
+// exportSources maps each export kind to its source.
+var exportSources = map[string]exportSource{
+	"events": {"workflow_events", []string{"task_id", "seq_no", "phase", "event_type", "payload_json", "schema_version", "created_at"}},
+	"costs": {"cost_deltas", []string{"task_id", "phase", "provider", "worker_id", "input_tokens", "output_tokens",
+		"amount_usd", "created_at"}},
+	"audits": {"audit_records", []string{"id", "task_id", "category", "actor", "action", "severity", "request_json",
+		"decision_json", "created_at"}},
+	"scorecards": {"score_cards", []string{"review_id", "task_id", "round", "reviewer", "correctness", "security",
+		"maintainability", "cost", "delivery_risk", "verdict", "issues_json", "alternatives_json", "invalidated_at",
+		"created_at"}},
+}
+
+// ExportFilter selects the rows of an export. Empty fields and zero times do
+// not filter; Until is exclusive.
+type ExportFilter struct {
+	TaskID    string
+	Namespace string
+	Since     int64
+	Until     int64
+}
+
+// ExportRepo reads records for export.
+type ExportRepo struct{}
+
+// Columns returns the column names of kind's rows. The kinds are events,
+// costs, audits, and scorecards.
+func (r *ExportRepo) Columns(kind string) ([]string, error) {
+	src, ok := exportSources[kind]
+	if !ok {
+		return nil, fmt.Errorf("export: unknown kind %q", kind)
+	}
+	return src.columns, nil
+}
+
+// Each calls fn with every row of kind matching filter, oldest first. Text
+// columns arrive as strings, integers as int64, and reals as float64. An
+// error from fn stops the export and is returned.
+func (r *ExportRepo) Each(ctx context.Context, db *sql.DB, kind string, filter ExportFilter, fn func(row []interface{}) error) error {
+	src, ok := exportSources[kind]
+	if !ok {
+		return fmt.Errorf("export: unknown kind %q", kind)
+	}
+	q := `SELECT ` + strings.Join(src.columns, ", ") + ` FROM ` + src.table + `
+WHERE (? = '' OR task_id = ?)
+	AND (? = '' OR task_id IN (SELECT task_id FROM tasks WHERE namespace = ?))
+	AND (? = 0 OR created_at >= ?)
+	AND (? = 0 OR created_at < ?)
+ORDER BY created_at ASC, rowid ASC`
+	rows, err := db.QueryContext(ctx, q,
+		filter.TaskID, filter.TaskID,
+		filter.Namespace, filter.Namespace,
+		filter.Since, filter.Since,
+		filter.Until, filter.Until,
+	)
+	if err != nil {
+		return fmt.Errorf("export %s: %w", kind, err)
+	}
+	defer rows.Close()
+
+	// Sealed payloads are exported decrypted.
+	taskCol, sealed := -1, []int(nil)
+	for i, col := range src.columns {
+		if col == "task_id" {
+			taskCol = i
+		}
+		if isSealedColumn(src.table, col) {
+			sealed = append(sealed, i)
+		}
+	}
+
+	values := make([]interface{}, len(src.columns))
+	ptrs := make([]interface{}, len(src.columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return fmt.Errorf("scan %s export: %w", kind, err)
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		for _, i := range sealed {
+			taskID, _ := values[taskCol].(string)
+			text, _ := values[i].(string)
+			if values[i], err = openPayload(taskID, text); err != nil {
+				return fmt.Errorf("open %s export: %w", kind, err)
+			}
+		}
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
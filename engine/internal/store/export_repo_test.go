@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestExportRepo_Each(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	repo := &ExportRepo{}
+
+	costs := &CostDeltaRepo{}
+	costs.Create(ctx, db, "t1", domain.CostDelta{AmountUSD: 1.25, Provider: "claude", WorkerID: "w1", CreatedAt: 200})
+	costs.Create(ctx, db, "t1", domain.CostDelta{AmountUSD: 2, CreatedAt: 100})
+	costs.Create(ctx, db, "t2", domain.CostDelta{AmountUSD: 3, CreatedAt: 150})
+
+	columns, err := repo.Columns("costs")
+	if err != nil || columns[0] != "task_id" {
+		t.Fatalf("Columns = %v, %v", columns, err)
+	}
+	var rows [][]interface{}
+	err = repo.Each(ctx, db, "costs", ExportFilter{TaskID: "t1"}, func(row []interface{}) error {
+		rows = append(rows, append([]interface{}(nil), row...))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Each: %v", err)
+	}
+	if len(rows) != 2 || rows[0][6] != 2.0 || rows[1][2] != "claude" || rows[1][3] != "w1" || rows[1][7] != int64(200) {
+		t.Errorf("rows = %v, want t1's deltas oldest first", rows)
+	}
+
+	n := 0
+	repo.Each(ctx, db, "costs", ExportFilter{Since: 150, Until: 200}, func([]interface{}) error { n++; return nil })
+	if n != 1 {
+		t.Errorf("rows in [150, 200) = %d, want 1", n)
+	}
+
+	if _, err := repo.Columns("tasks"); err == nil {
+		t.Error("Columns accepted an unknown kind")
+	}
+	if err := repo.Each(ctx, db, "tasks", ExportFilter{}, nil); err == nil {
+		t.Error("Each accepted an unknown kind")
+	}
+}
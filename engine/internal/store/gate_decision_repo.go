@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// GateDecisionRepo handles persistence for recorded gate evaluations.
+type GateDecisionRepo struct{}
+
+// gateDecisionColumns is the column list shared by every gate decision SELECT.
+const gateDecisionColumns = "id, task_id, phase, gate, allow, blockers_json, retryable, action, actor, created_at"
+
+// scanGateRecord reads one row selected with gateDecisionColumns.
+func scanGateRecord(row rowScanner) (domain.GateRecord, error) {
+	var g domain.GateRecord
+	var phase, blockersJSON string
+	if err := row.Scan(&g.ID, &g.TaskID, &phase, &g.Gate, &g.Allow, &blockersJSON,
+		&g.Retryable, &g.Action, &g.Actor, &g.CreatedAt); err != nil {
+		return g, fmt.Errorf("scan gate decision: %w", err)
+	}
+	g.Phase = domain.Phase(phase)
+	if err := json.Unmarshal([]byte(blockersJSON), &g.Blockers); err != nil {
+		return g, fmt.Errorf("unmarshal blockers: %w", err)
+	}
+	return g, nil
+}
+
+// Create appends a gate decision and returns its ID.
+func (r *GateDecisionRepo) Create(ctx context.Context, db *sql.DB, g domain.GateRecord) (int64, error) {
+	return r.create(ctx, db, g)
+}
+
+// CreateTx appends a gate decision within an existing transaction and
+// returns its ID.
+func (r *GateDecisionRepo) CreateTx(ctx context.Context, tx *sql.Tx, g domain.GateRecord) (int64, error) {
+	return r.create(ctx, tx, g)
+}
+
+func (r *GateDecisionRepo) create(ctx context.Context, db execer, g domain.GateRecord) (int64, error) {
+	blockers := g.Blockers
+	if blockers == nil {
+		blockers = []string{}
+	}
+	blockersJSON, err := json.Marshal(blockers)
+	if err != nil {
+		return 0, fmt.Errorf("marshal blockers: %w", err)
+	}
+	const q = `INSERT INTO gate_decisions (task_id, phase, gate, allow, blockers_json, retryable, action, actor, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := db.ExecContext(ctx, q, g.TaskID, string(g.Phase), g.Gate, g.Allow, string(blockersJSON),
+		g.Retryable, g.Action, g.Actor, g.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("create gate decision: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListByTask returns a task's gate decisions, newest first, at most limit
+// of them (all when limit is 0) and, with phase set, only that phase's.
+func (r *GateDecisionRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string, phase domain.Phase, limit int) ([]domain.GateRecord, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	q := `SELECT ` + gateDecisionColumns + ` FROM gate_decisions
+WHERE task_id = ? AND (? = '' OR phase = ?)
+ORDER BY id DESC
+LIMIT ?`
+	rows, err := db.QueryContext(ctx, q, taskID, string(phase), string(phase), limit)
+	if err != nil {
+		return nil, fmt.Errorf("list gate decisions: %w", err)
+	}
+	defer rows.Close()
+
+	var records []domain.GateRecord
+	for rows.Next() {
+		g, err := scanGateRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, g)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestGateDecisionRepo_CreateList(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &GateDecisionRepo{}
+	records := []domain.GateRecord{
+		{TaskID: "task-1", Phase: domain.PhaseD, Gate: "review", Blockers: []string{"P0 risk r1: data loss"}, Action: "advance", Actor: "auto", CreatedAt: 100},
+		{TaskID: "task-1", Phase: domain.PhaseD, Gate: "review", Allow: true, Action: "advance", Actor: "alice", CreatedAt: 200},
+		{TaskID: "task-1", Phase: domain.PhaseE, Gate: "join", Retryable: true, Blockers: []string{"child flow c1 is running"}, CreatedAt: 300},
+		{TaskID: "task-2", Phase: domain.PhaseA, Gate: "default", Allow: true, CreatedAt: 400},
+	}
+	for _, g := range records {
+		if _, err := repo.Create(ctx, db, g); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	got, err := repo.ListByTask(ctx, db, "task-1", "", 0)
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(got) != 3 || got[0].Gate != "join" || !got[0].Retryable || got[2].Blockers[0] != "P0 risk r1: data loss" {
+		t.Errorf("ListByTask = %+v, want task-1's three decisions newest first", got)
+	}
+	if got[1].Blockers == nil || len(got[1].Blockers) != 0 || !got[1].Allow || got[1].Actor != "alice" {
+		t.Errorf("allowed decision = %+v", got[1])
+	}
+
+	got, err = repo.ListByTask(ctx, db, "task-1", domain.PhaseD, 1)
+	if err != nil || len(got) != 1 || got[0].CreatedAt != 200 {
+		t.Errorf("ListByTask(D, 1) = %+v, %v; want the latest phase D decision", got, err)
+	}
+}
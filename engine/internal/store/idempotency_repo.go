@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// IdempotencyRepo handles persistence for IdempotentResponse records.
+type IdempotencyRepo struct{}
+
+// Get returns the response stored under key at or after since, or nil if
+// there is none.
+func (r *IdempotencyRepo) Get(ctx context.Context, db *sql.DB, key string, since int64) (*domain.IdempotentResponse, error) {
+	const q = `SELECT key, method, path, status, body, created_at FROM idempotency_keys
+WHERE key = ? AND created_at >= ?`
+	var resp domain.IdempotentResponse
+	err := db.QueryRowContext(ctx, q, key, since).Scan(&resp.Key, &resp.Method, &resp.Path, &resp.Status, &resp.Body, &resp.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get idempotency key: %w", err)
+	}
+	return &resp, nil
+}
+
+// Save stores resp under its key, replacing an expired entry, and deletes
+// the entries created before since.
+func (r *IdempotencyRepo) Save(ctx context.Context, db *sql.DB, resp domain.IdempotentResponse, since int64) error {
+	const q = `INSERT OR REPLACE INTO idempotency_keys (key, method, path, status, body, created_at)
+VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := db.ExecContext(ctx, q, resp.Key, resp.Method, resp.Path, resp.Status, resp.Body, resp.CreatedAt); err != nil {
+		return fmt.Errorf("save idempotency key: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < ?`, since); err != nil {
+		return fmt.Errorf("prune idempotency keys: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestIdempotencyRepo_SaveAndGet(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	repo := &IdempotencyRepo{}
+
+	old := domain.IdempotentResponse{Key: "k-old", Method: "POST", Path: "/api/v1/flow", Status: 201, Body: "{}", CreatedAt: 100}
+	if err := repo.Save(ctx, db, old, 0); err != nil {
+		t.Fatalf("Save old: %v", err)
+	}
+	resp := domain.IdempotentResponse{Key: "k1", Method: "POST", Path: "/api/v1/flow", Status: 201, Body: `{"taskId":"t1"}`, CreatedAt: 500}
+	if err := repo.Save(ctx, db, resp, 200); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := repo.Get(ctx, db, "k1", 200)
+	if err != nil || got == nil || *got != resp {
+		t.Fatalf("Get = %+v, %v; want %+v", got, err, resp)
+	}
+	if got, _ := repo.Get(ctx, db, "k1", 600); got != nil {
+		t.Errorf("Get before since = %+v, want nil", got)
+	}
+	if got, _ := repo.Get(ctx, db, "k-old", 0); got != nil {
+		t.Errorf("expired key was not pruned: %+v", got)
+	}
+}
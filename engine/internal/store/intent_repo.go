@@ -11,10 +11,22 @@ import (
 // IntentRepo handles persistence for Intent records.
 type IntentRepo struct{}
 
+// intentColumns is the column list shared by every intent SELECT.
+const intentColumns = "intent_id, task_id, worker_id, target_file, operation, status, pre_hash, post_hash, payload_hash, lease_until, created_at, lease_sec, queued_at, queue_until"
+
+// scanIntent reads one intent row selected with intentColumns.
+func scanIntent(row rowScanner) (domain.Intent, error) {
+	var i domain.Intent
+	err := row.Scan(&i.IntentID, &i.TaskID, &i.WorkerID, &i.TargetFile, &i.Operation,
+		&i.Status, &i.PreHash, &i.PostHash, &i.PayloadHash, &i.LeaseUntil, &i.CreatedAt,
+		&i.LeaseSec, &i.QueuedAt, &i.QueueUntil)
+	return i, err
+}
+
 // UpsertTx inserts or updates an intent within an existing transaction.
 func (r *IntentRepo) UpsertTx(ctx context.Context, tx *sql.Tx, intent domain.Intent) error {
-	const q = `INSERT INTO intent_logs (intent_id, task_id, worker_id, target_file, operation, status, pre_hash, post_hash, payload_hash, lease_until)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	const q = `INSERT INTO intent_logs (` + intentColumns + `)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(intent_id) DO UPDATE SET
 	worker_id = excluded.worker_id,
 	target_file = excluded.target_file,
@@ -23,7 +35,10 @@ ON CONFLICT(intent_id) DO UPDATE SET
 	pre_hash = excluded.pre_hash,
 	post_hash = excluded.post_hash,
 	payload_hash = excluded.payload_hash,
-	lease_until = excluded.lease_until`
+	lease_until = excluded.lease_until,
+	lease_sec = excluded.lease_sec,
+	queued_at = excluded.queued_at,
+	queue_until = excluded.queue_until`
 
 	_, err := tx.ExecContext(ctx, q,
 		intent.IntentID,
@@ -36,6 +51,10 @@ ON CONFLICT(intent_id) DO UPDATE SET
 		intent.PostHash,
 		intent.PayloadHash,
 		intent.LeaseUntil,
+		intent.CreatedAt,
+		intent.LeaseSec,
+		intent.QueuedAt,
+		intent.QueueUntil,
 	)
 	if err != nil {
 		return fmt.Errorf("upsert intent: %w", err)
@@ -45,7 +64,7 @@ ON CONFLICT(intent_id) DO UPDATE SET
 
 // ListByTaskStatus returns intents for a task filtered by status.
 func (r *IntentRepo) ListByTaskStatus(ctx context.Context, db *sql.DB, taskID, status string) ([]domain.Intent, error) {
-	const q = `SELECT intent_id, task_id, worker_id, target_file, operation, status, pre_hash, post_hash, payload_hash, lease_until
+	q := `SELECT ` + intentColumns + `
 FROM intent_logs
 WHERE task_id = ? AND status = ?
 ORDER BY intent_id ASC`
@@ -58,9 +77,32 @@ ORDER BY intent_id ASC`
 
 	var intents []domain.Intent
 	for rows.Next() {
-		var i domain.Intent
-		if err := rows.Scan(&i.IntentID, &i.TaskID, &i.WorkerID, &i.TargetFile, &i.Operation,
-			&i.Status, &i.PreHash, &i.PostHash, &i.PayloadHash, &i.LeaseUntil); err != nil {
+		i, err := scanIntent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan intent: %w", err)
+		}
+		intents = append(intents, i)
+	}
+	return intents, rows.Err()
+}
+
+// ListByTask returns every intent for a task regardless of status.
+func (r *IntentRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]domain.Intent, error) {
+	q := `SELECT ` + intentColumns + `
+FROM intent_logs
+WHERE task_id = ?
+ORDER BY created_at ASC, intent_id ASC`
+
+	rows, err := db.QueryContext(ctx, q, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list intents: %w", err)
+	}
+	defer rows.Close()
+
+	var intents []domain.Intent
+	for rows.Next() {
+		i, err := scanIntent(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan intent: %w", err)
 		}
 		intents = append(intents, i)
@@ -70,13 +112,10 @@ ORDER BY intent_id ASC`
 
 // GetByID retrieves a single intent by its ID.
 func (r *IntentRepo) GetByID(ctx context.Context, db *sql.DB, intentID string) (*domain.Intent, error) {
-	const q = `SELECT intent_id, task_id, worker_id, target_file, operation, status, pre_hash, post_hash, payload_hash, lease_until
+	q := `SELECT ` + intentColumns + `
 FROM intent_logs WHERE intent_id = ?`
 
-	row := db.QueryRowContext(ctx, q, intentID)
-	var i domain.Intent
-	err := row.Scan(&i.IntentID, &i.TaskID, &i.WorkerID, &i.TargetFile, &i.Operation,
-		&i.Status, &i.PreHash, &i.PostHash, &i.PayloadHash, &i.LeaseUntil)
+	i, err := scanIntent(db.QueryRowContext(ctx, q, intentID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, domain.ErrIntentNotFound
@@ -88,7 +127,7 @@ FROM intent_logs WHERE intent_id = ?`
 
 // FindActiveByFile returns active (pending/running) intents for a given task and target file.
 func (r *IntentRepo) FindActiveByFile(ctx context.Context, db *sql.DB, taskID, targetFile string) ([]domain.Intent, error) {
-	const q = `SELECT intent_id, task_id, worker_id, target_file, operation, status, pre_hash, post_hash, payload_hash, lease_until
+	q := `SELECT ` + intentColumns + `
 FROM intent_logs
 WHERE task_id = ? AND target_file = ? AND status IN ('pending', 'running')
 ORDER BY intent_id ASC`
@@ -101,9 +140,116 @@ ORDER BY intent_id ASC`
 
 	var intents []domain.Intent
 	for rows.Next() {
-		var i domain.Intent
-		if err := rows.Scan(&i.IntentID, &i.TaskID, &i.WorkerID, &i.TargetFile, &i.Operation,
-			&i.Status, &i.PreHash, &i.PostHash, &i.PayloadHash, &i.LeaseUntil); err != nil {
+		i, err := scanIntent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan intent: %w", err)
+		}
+		intents = append(intents, i)
+	}
+	return intents, rows.Err()
+}
+
+// ListActive returns the active (pending/running) intents of every task.
+func (r *IntentRepo) ListActive(ctx context.Context, db *sql.DB) ([]domain.Intent, error) {
+	q := `SELECT ` + intentColumns + `
+FROM intent_logs
+WHERE status IN ('pending', 'running')
+ORDER BY task_id ASC, intent_id ASC`
+
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("list active intents: %w", err)
+	}
+	defer rows.Close()
+
+	var intents []domain.Intent
+	for rows.Next() {
+		i, err := scanIntent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan intent: %w", err)
+		}
+		intents = append(intents, i)
+	}
+	return intents, rows.Err()
+}
+
+// ListExpired returns a task's active (pending/running) intents whose lease
+// ended before now.
+func (r *IntentRepo) ListExpired(ctx context.Context, db *sql.DB, taskID string, now int64) ([]domain.Intent, error) {
+	q := `SELECT ` + intentColumns + `
+FROM intent_logs
+WHERE task_id = ? AND status IN ('pending', 'running') AND lease_until < ?
+ORDER BY lease_until ASC, intent_id ASC`
+
+	rows, err := db.QueryContext(ctx, q, taskID, now)
+	if err != nil {
+		return nil, fmt.Errorf("list expired intents: %w", err)
+	}
+	defer rows.Close()
+
+	var intents []domain.Intent
+	for rows.Next() {
+		i, err := scanIntent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan intent: %w", err)
+		}
+		intents = append(intents, i)
+	}
+	return intents, rows.Err()
+}
+
+// RenewTx extends an active intent's lease to leaseUntil within a
+// transaction. It returns false if the intent is no longer active or its
+// lease already ended before now.
+func (r *IntentRepo) RenewTx(ctx context.Context, tx *sql.Tx, intentID string, leaseUntil, now int64) (bool, error) {
+	const q = `UPDATE intent_logs SET lease_until = ?
+WHERE intent_id = ? AND status IN ('pending', 'running') AND lease_until >= ?`
+	res, err := tx.ExecContext(ctx, q, leaseUntil, intentID, now)
+	if err != nil {
+		return false, fmt.Errorf("renew intent: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("check rows affected: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ExpireTx cancels an active intent whose lease ended before now within a
+// transaction. It returns false if the intent was renewed or finished in the
+// meantime.
+func (r *IntentRepo) ExpireTx(ctx context.Context, tx *sql.Tx, intentID string, now int64) (bool, error) {
+	const q = `UPDATE intent_logs SET status = 'cancelled'
+WHERE intent_id = ? AND status IN ('pending', 'running') AND lease_until < ?`
+	res, err := tx.ExecContext(ctx, q, intentID, now)
+	if err != nil {
+		return false, fmt.Errorf("expire intent: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("check rows affected: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListWaiters returns a task's queued intents in the order they will be
+// granted, limited to targetFile unless it is empty.
+func (r *IntentRepo) ListWaiters(ctx context.Context, db *sql.DB, taskID, targetFile string) ([]domain.Intent, error) {
+	q := `SELECT ` + intentColumns + `
+FROM intent_logs
+WHERE task_id = ? AND status = 'queued' AND (? = '' OR target_file = ?)
+ORDER BY target_file ASC, queued_at ASC, intent_id ASC`
+
+	rows, err := db.QueryContext(ctx, q, taskID, targetFile, targetFile)
+	if err != nil {
+		return nil, fmt.Errorf("list waiting intents: %w", err)
+	}
+	defer rows.Close()
+
+	var intents []domain.Intent
+	for rows.Next() {
+		i, err := scanIntent(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan intent: %w", err)
 		}
 		intents = append(intents, i)
@@ -111,6 +257,38 @@ ORDER BY intent_id ASC`
 	return intents, rows.Err()
 }
 
+// GrantTx turns a queued intent into a pending one holding the lock until
+// leaseUntil, within a transaction. It returns false if the intent is no
+// longer queued.
+func (r *IntentRepo) GrantTx(ctx context.Context, tx *sql.Tx, intentID string, leaseUntil int64) (bool, error) {
+	const q = `UPDATE intent_logs SET status = 'pending', lease_until = ?
+WHERE intent_id = ? AND status = 'queued'`
+	res, err := tx.ExecContext(ctx, q, leaseUntil, intentID)
+	if err != nil {
+		return false, fmt.Errorf("grant intent: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("check rows affected: %w", err)
+	}
+	return n > 0, nil
+}
+
+// CancelQueuedTx cancels a queued intent within a transaction. It returns
+// false if the intent is no longer queued.
+func (r *IntentRepo) CancelQueuedTx(ctx context.Context, tx *sql.Tx, intentID string) (bool, error) {
+	const q = `UPDATE intent_logs SET status = 'cancelled' WHERE intent_id = ? AND status = 'queued'`
+	res, err := tx.ExecContext(ctx, q, intentID)
+	if err != nil {
+		return false, fmt.Errorf("cancel queued intent: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("check rows affected: %w", err)
+	}
+	return n > 0, nil
+}
+
 // MarkDoneTx marks an intent as done with a post-operation hash within a transaction.
 func (r *IntentRepo) MarkDoneTx(ctx context.Context, tx *sql.Tx, intentID, postHash string) error {
 	const q = `UPDATE intent_logs SET status = 'done', post_hash = ? WHERE intent_id = ?`
@@ -127,3 +305,15 @@ func (r *IntentRepo) MarkDoneTx(ctx context.Context, tx *sql.Tx, intentID, postH
 	}
 	return nil
 }
+
+// InvalidateSinceTx marks every intent of a task created at or after since as
+// invalidated within a transaction. It returns the number of intents affected.
+func (r *IntentRepo) InvalidateSinceTx(ctx context.Context, tx *sql.Tx, taskID string, since int64) (int64, error) {
+	const q = `UPDATE intent_logs SET status = 'invalidated'
+WHERE task_id = ? AND created_at >= ? AND status != 'invalidated'`
+	res, err := tx.ExecContext(ctx, q, taskID, since)
+	if err != nil {
+		return 0, fmt.Errorf("invalidate intents: %w", err)
+	}
+	return res.RowsAffected()
+}
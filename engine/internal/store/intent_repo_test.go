@@ -186,3 +186,46 @@ func TestIntentRepo_MarkDone_NotFound(t *testing.T) {
 		t.Errorf("expected ErrIntentNotFound, got %v", err)
 	}
 }
+
+func TestIntentRepo_InvalidateSince(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &IntentRepo{}
+
+	tx, _ := db.Begin()
+	repo.UpsertTx(ctx, tx, domain.Intent{IntentID: "old", TaskID: "task-1", TargetFile: "a.go", Operation: "write", Status: "done", CreatedAt: 100})
+	repo.UpsertTx(ctx, tx, domain.Intent{IntentID: "new", TaskID: "task-1", TargetFile: "b.go", Operation: "write", Status: "pending", CreatedAt: 200})
+	repo.UpsertTx(ctx, tx, domain.Intent{IntentID: "other", TaskID: "task-2", TargetFile: "b.go", Operation: "write", Status: "pending", CreatedAt: 200})
+	tx.Commit()
+
+	tx, _ = db.Begin()
+	n, err := repo.InvalidateSinceTx(ctx, tx, "task-1", 150)
+	if err != nil {
+		t.Fatalf("InvalidateSinceTx: %v", err)
+	}
+	tx.Commit()
+	if n != 1 {
+		t.Errorf("invalidated = %d, want 1", n)
+	}
+
+	got, _ := repo.GetByID(ctx, db, "new")
+	if got.Status != "invalidated" {
+		t.Errorf("new Status = %q, want invalidated", got.Status)
+	}
+	if got.CreatedAt != 200 {
+		t.Errorf("CreatedAt = %d, want 200", got.CreatedAt)
+	}
+	got, _ = repo.GetByID(ctx, db, "old")
+	if got.Status != "done" {
+		t.Errorf("old Status = %q, want done", got.Status)
+	}
+	got, _ = repo.GetByID(ctx, db, "other")
+	if got.Status != "pending" {
+		t.Errorf("other task Status = %q, want pending", got.Status)
+	}
+}
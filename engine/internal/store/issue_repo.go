@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// IssueRepo handles persistence for ReviewIssue records.
+type IssueRepo struct{}
+
+// issueColumns is the column list shared by every review issue SELECT.
+const issueColumns = `issue_id, task_id, review_id, reviewer, round, severity, location, description,
+suggestion, evidence, status, fix_intent_id, fix_commit, note, updated_by, created_at, updated_at`
+
+// scanIssue reads one review issue row selected with issueColumns.
+func scanIssue(row rowScanner) (domain.ReviewIssue, error) {
+	var i domain.ReviewIssue
+	err := row.Scan(&i.IssueID, &i.TaskID, &i.ReviewID, &i.Reviewer, &i.Round, &i.Severity, &i.Location,
+		&i.Description, &i.Suggestion, &i.Evidence, &i.Status, &i.FixIntentID, &i.FixCommit, &i.Note,
+		&i.UpdatedBy, &i.CreatedAt, &i.UpdatedAt)
+	return i, err
+}
+
+// CreateTx inserts a new review issue within a transaction. An empty status
+// is stored as "open".
+func (r *IssueRepo) CreateTx(ctx context.Context, tx *sql.Tx, i domain.ReviewIssue) error {
+	if i.Status == "" {
+		i.Status = "open"
+	}
+	const q = `INSERT INTO review_issues (` + issueColumns + `)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, q, i.IssueID, i.TaskID, i.ReviewID, i.Reviewer, i.Round, i.Severity, i.Location,
+		i.Description, i.Suggestion, i.Evidence, i.Status, i.FixIntentID, i.FixCommit, i.Note,
+		i.UpdatedBy, i.CreatedAt, i.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("create review issue: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a single review issue by its ID.
+func (r *IssueRepo) GetByID(ctx context.Context, db *sql.DB, issueID string) (*domain.ReviewIssue, error) {
+	q := `SELECT ` + issueColumns + ` FROM review_issues WHERE issue_id = ?`
+	i, err := scanIssue(db.QueryRowContext(ctx, q, issueID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrIssueNotFound
+		}
+		return nil, fmt.Errorf("get review issue: %w", err)
+	}
+	return &i, nil
+}
+
+// ListByTask returns a task's review issues, most severe first and then in
+// creation order, limited to status unless it is empty.
+func (r *IssueRepo) ListByTask(ctx context.Context, db *sql.DB, taskID, status string) ([]domain.ReviewIssue, error) {
+	q := `SELECT ` + issueColumns + ` FROM review_issues
+WHERE task_id = ? AND (? = '' OR status = ?)
+ORDER BY severity ASC, created_at ASC, issue_id ASC`
+
+	rows, err := db.QueryContext(ctx, q, taskID, status, status)
+	if err != nil {
+		return nil, fmt.Errorf("list review issues: %w", err)
+	}
+	defer rows.Close()
+
+	var issues []domain.ReviewIssue
+	for rows.Next() {
+		i, err := scanIssue(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan review issue: %w", err)
+		}
+		issues = append(issues, i)
+	}
+	return issues, rows.Err()
+}
+
+// Update stores the status, fix links, note, and updater of i.
+func (r *IssueRepo) Update(ctx context.Context, db *sql.DB, i domain.ReviewIssue) error {
+	const q = `UPDATE review_issues SET status = ?, fix_intent_id = ?, fix_commit = ?, note = ?,
+updated_by = ?, updated_at = ?
+WHERE issue_id = ?`
+	res, err := db.ExecContext(ctx, q, i.Status, i.FixIntentID, i.FixCommit, i.Note, i.UpdatedBy, i.UpdatedAt, i.IssueID)
+	if err != nil {
+		return fmt.Errorf("update review issue: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("check rows affected: %w", err)
+	} else if n == 0 {
+		return domain.ErrIssueNotFound
+	}
+	return nil
+}
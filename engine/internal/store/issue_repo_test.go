@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestIssueRepo_CreateListUpdate(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &IssueRepo{}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	for _, i := range []domain.ReviewIssue{
+		{IssueID: "i1", TaskID: "task-1", ReviewID: "rev-1", Reviewer: "lead", CreatedAt: 10,
+			Issue: domain.Issue{Severity: "P2", Location: "a.go:1", Description: "naming"}},
+		{IssueID: "i2", TaskID: "task-1", ReviewID: "rev-1", Reviewer: "lead", Round: 1, CreatedAt: 20,
+			Issue: domain.Issue{Severity: "P0", Location: "b.go:2", Description: "crash", Suggestion: "check nil"}},
+		{IssueID: "i3", TaskID: "task-2", ReviewID: "rev-2", Reviewer: "primary", CreatedAt: 5,
+			Issue: domain.Issue{Severity: "P1", Description: "other task"}},
+	} {
+		if err := repo.CreateTx(ctx, tx, i); err != nil {
+			t.Fatalf("CreateTx %s: %v", i.IssueID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	issues, err := repo.ListByTask(ctx, db, "task-1", "")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(issues) != 2 || issues[0].IssueID != "i2" || issues[1].IssueID != "i1" {
+		t.Fatalf("issues = %+v, want [i2 i1]", issues)
+	}
+	if i := issues[0]; i.Status != "open" || i.Round != 1 || i.Suggestion != "check nil" {
+		t.Errorf("i2 = %+v, want open round 1 with its suggestion", i)
+	}
+
+	fixed := issues[0]
+	fixed.Status, fixed.FixCommit, fixed.UpdatedBy, fixed.UpdatedAt = "fixed", "abc123", "alice", 30
+	if err := repo.Update(ctx, db, fixed); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := repo.GetByID(ctx, db, "i2")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Status != "fixed" || got.FixCommit != "abc123" || got.UpdatedBy != "alice" || got.UpdatedAt != 30 {
+		t.Errorf("after update = %+v", got)
+	}
+	if open, _ := repo.ListByTask(ctx, db, "task-1", "open"); len(open) != 1 || open[0].IssueID != "i1" {
+		t.Errorf("open = %+v, want [i1]", open)
+	}
+
+	if _, err := repo.GetByID(ctx, db, "missing"); err != domain.ErrIssueNotFound {
+		t.Errorf("GetByID missing: got %v, want ErrIssueNotFound", err)
+	}
+	if err := repo.Update(ctx, db, domain.ReviewIssue{IssueID: "missing"}); err != domain.ErrIssueNotFound {
+		t.Errorf("Update missing: got %v, want ErrIssueNotFound", err)
+	}
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// IssueSyncRepo handles persistence for tracking issue links and the queue
+// of changes waiting to be sent to the tracker.
+type IssueSyncRepo struct{}
+
+// SaveLink records the issue tracking a task, replacing any earlier link.
+func (r *IssueSyncRepo) SaveLink(ctx context.Context, db *sql.DB, link domain.IssueLink) error {
+	const q = `INSERT OR REPLACE INTO issue_links (task_id, provider, issue_key, issue_id, url, created_at)
+VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := db.ExecContext(ctx, q, link.TaskID, link.Provider, link.Key, link.ID, link.URL, link.CreatedAt); err != nil {
+		return fmt.Errorf("save issue link: %w", err)
+	}
+	return nil
+}
+
+// GetLink returns the issue tracking a task, or nil if none was created.
+func (r *IssueSyncRepo) GetLink(ctx context.Context, db *sql.DB, taskID string) (*domain.IssueLink, error) {
+	const q = `SELECT task_id, provider, issue_key, issue_id, url, created_at FROM issue_links WHERE task_id = ?`
+	var l domain.IssueLink
+	err := db.QueryRowContext(ctx, q, taskID).Scan(&l.TaskID, &l.Provider, &l.Key, &l.ID, &l.URL, &l.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get issue link: %w", err)
+	}
+	return &l, nil
+}
+
+// Enqueue queues op to be sent as soon as the task's earlier operations
+// are.
+func (r *IssueSyncRepo) Enqueue(ctx context.Context, db *sql.DB, op domain.IssueSyncOp) error {
+	const q = `INSERT INTO issue_sync_ops (task_id, kind, body, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?)`
+	if _, err := db.ExecContext(ctx, q, op.TaskID, op.Kind, op.Body, op.NextAttemptAt, op.CreatedAt); err != nil {
+		return fmt.Errorf("enqueue issue sync op: %w", err)
+	}
+	return nil
+}
+
+// Pending returns up to limit queued operations, oldest first.
+func (r *IssueSyncRepo) Pending(ctx context.Context, db *sql.DB, limit int) ([]domain.IssueSyncOp, error) {
+	const q = `SELECT id, task_id, kind, body, attempts, next_attempt_at, last_error, created_at
+FROM issue_sync_ops ORDER BY id ASC LIMIT ?`
+	rows, err := db.QueryContext(ctx, q, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list issue sync ops: %w", err)
+	}
+	defer rows.Close()
+
+	var ops []domain.IssueSyncOp
+	for rows.Next() {
+		var op domain.IssueSyncOp
+		if err := rows.Scan(&op.ID, &op.TaskID, &op.Kind, &op.Body, &op.Attempts, &op.NextAttemptAt, &op.LastError, &op.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan issue sync op: %w", err)
+		}
+		ops = append(ops, op)
+	}
+	return ops, rows.Err()
+}
+
+// Done removes a sent operation from the queue.
+func (r *IssueSyncRepo) Done(ctx context.Context, db *sql.DB, id int64) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM issue_sync_ops WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("delete issue sync op: %w", err)
+	}
+	return nil
+}
+
+// Retry records a failed attempt at an operation and when to try again.
+func (r *IssueSyncRepo) Retry(ctx context.Context, db *sql.DB, id, nextAttemptAt int64, lastError string) error {
+	const q = `UPDATE issue_sync_ops SET attempts = attempts + 1, next_attempt_at = ?, last_error = ? WHERE id = ?`
+	if _, err := db.ExecContext(ctx, q, nextAttemptAt, lastError, id); err != nil {
+		return fmt.Errorf("retry issue sync op: %w", err)
+	}
+	return nil
+}
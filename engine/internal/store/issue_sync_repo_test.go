@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestIssueSyncRepo_Links(t *testing.T) {
+	db, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	repo := &IssueSyncRepo{}
+
+	if link, err := repo.GetLink(ctx, db, "t1"); err != nil || link != nil {
+		t.Fatalf("GetLink before SaveLink = %+v, %v", link, err)
+	}
+	want := domain.IssueLink{TaskID: "t1", Provider: "jira", Key: "ENG-42", ID: "10042", URL: "https://acme.atlassian.net/browse/ENG-42", CreatedAt: 100}
+	if err := repo.SaveLink(ctx, db, want); err != nil {
+		t.Fatalf("SaveLink: %v", err)
+	}
+	if link, err := repo.GetLink(ctx, db, "t1"); err != nil || *link != want {
+		t.Errorf("GetLink = %+v, %v; want %+v", link, err, want)
+	}
+}
+
+func TestIssueSyncRepo_Queue(t *testing.T) {
+	db, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	repo := &IssueSyncRepo{}
+
+	repo.Enqueue(ctx, db, domain.IssueSyncOp{TaskID: "t1", Kind: domain.IssueOpCreate, CreatedAt: 100})
+	repo.Enqueue(ctx, db, domain.IssueSyncOp{TaskID: "t1", Kind: domain.IssueOpComment, Body: "A -> B", CreatedAt: 101})
+	repo.Enqueue(ctx, db, domain.IssueSyncOp{TaskID: "t2", Kind: domain.IssueOpCreate, CreatedAt: 102})
+
+	ops, err := repo.Pending(ctx, db, 10)
+	if err != nil || len(ops) != 3 || ops[0].Kind != domain.IssueOpCreate || ops[1].Body != "A -> B" || ops[2].TaskID != "t2" {
+		t.Fatalf("Pending = %+v, %v", ops, err)
+	}
+	if err := repo.Retry(ctx, db, ops[0].ID, 160, "503 Service Unavailable"); err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	if err := repo.Done(ctx, db, ops[2].ID); err != nil {
+		t.Fatalf("Done: %v", err)
+	}
+
+	ops, _ = repo.Pending(ctx, db, 1)
+	if len(ops) != 1 || ops[0].Attempts != 1 || ops[0].NextAttemptAt != 160 || ops[0].LastError != "503 Service Unavailable" {
+		t.Errorf("Pending after Retry = %+v", ops)
+	}
+	if ops, _ := repo.Pending(ctx, db, 10); len(ops) != 2 {
+		t.Errorf("Pending after Done = %d ops, want 2", len(ops))
+	}
+}
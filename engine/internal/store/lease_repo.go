@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// LeaseRepo handles persistence for Lease records.
+type LeaseRepo struct{}
+
+// Acquire takes or renews the named lease for holder until expiresAt. It
+// succeeds when the lease is free, already held by holder, or expired at
+// now, and reports whether holder now holds it. A renewal keeps the
+// original acquired_at.
+func (r *LeaseRepo) Acquire(ctx context.Context, db *sql.DB, name, holder string, now, expiresAt int64) (bool, error) {
+	const q = `INSERT INTO leases (name, holder, acquired_at, expires_at) VALUES (?, ?, ?, ?)
+ON CONFLICT(name) DO UPDATE SET
+	acquired_at = CASE WHEN leases.holder = excluded.holder THEN leases.acquired_at ELSE excluded.acquired_at END,
+	holder = excluded.holder,
+	expires_at = excluded.expires_at
+WHERE leases.holder = excluded.holder OR leases.expires_at <= ?`
+	res, err := db.ExecContext(ctx, q, name, holder, now, expiresAt, now)
+	if err != nil {
+		return false, fmt.Errorf("acquire lease %s: %w", name, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("check rows affected: %w", err)
+	}
+	return n == 1, nil
+}
+
+// Release gives up the named lease if holder holds it.
+func (r *LeaseRepo) Release(ctx context.Context, db *sql.DB, name, holder string) error {
+	const q = `DELETE FROM leases WHERE name = ? AND holder = ?`
+	if _, err := db.ExecContext(ctx, q, name, holder); err != nil {
+		return fmt.Errorf("release lease %s: %w", name, err)
+	}
+	return nil
+}
+
+// Get returns the named lease, or nil if nobody has taken it.
+func (r *LeaseRepo) Get(ctx context.Context, db *sql.DB, name string) (*domain.Lease, error) {
+	const q = `SELECT name, holder, acquired_at, expires_at FROM leases WHERE name = ?`
+	var l domain.Lease
+	err := db.QueryRowContext(ctx, q, name).Scan(&l.Name, &l.Holder, &l.AcquiredAt, &l.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get lease %s: %w", name, err)
+	}
+	return &l, nil
+}
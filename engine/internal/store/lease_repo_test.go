@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestLeaseRepo_AcquireRenewRelease(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	repo := &LeaseRepo{}
+
+	if l, err := repo.Get(ctx, db, "engine"); err != nil || l != nil {
+		t.Fatalf("Get before acquire = %+v, %v; want nil", l, err)
+	}
+	if ok, err := repo.Acquire(ctx, db, "engine", "a", 100, 115); err != nil || !ok {
+		t.Fatalf("a acquire = %v, %v", ok, err)
+	}
+	if ok, _ := repo.Acquire(ctx, db, "engine", "b", 110, 125); ok {
+		t.Error("b took a lease a still holds")
+	}
+	if ok, _ := repo.Acquire(ctx, db, "engine", "a", 110, 125); !ok {
+		t.Error("a could not renew its lease")
+	}
+	l, _ := repo.Get(ctx, db, "engine")
+	if l.Holder != "a" || l.AcquiredAt != 100 || l.ExpiresAt != 125 {
+		t.Errorf("lease after renewal = %+v", l)
+	}
+
+	// Once expired, another holder takes over.
+	if ok, _ := repo.Acquire(ctx, db, "engine", "b", 125, 140); !ok {
+		t.Fatal("b could not take the expired lease")
+	}
+	if l, _ := repo.Get(ctx, db, "engine"); l.Holder != "b" || l.AcquiredAt != 125 {
+		t.Errorf("lease after takeover = %+v", l)
+	}
+
+	if err := repo.Release(ctx, db, "engine", "a"); err != nil {
+		t.Fatalf("Release by a: %v", err)
+	}
+	if l, _ := repo.Get(ctx, db, "engine"); l == nil {
+		t.Error("a released b's lease")
+	}
+	repo.Release(ctx, db, "engine", "b")
+	if ok, _ := repo.Acquire(ctx, db, "engine", "a", 126, 141); !ok {
+		t.Error("a could not take the released lease")
+	}
+}
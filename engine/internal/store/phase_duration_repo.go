@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// PhaseDurationRepo records each flow's entry into and exit from each phase.
+type PhaseDurationRepo struct{}
+
+// phaseDurationColumns is the column list shared by every phase duration
+// SELECT. Its duration column runs an open stay to the first argument (now).
+const phaseDurationColumns = `id, task_id, phase, entered_at, exited_at,
+	(CASE WHEN exited_at > 0 THEN exited_at ELSE ? END) - entered_at, sla_sec, sla_warned_at`
+
+// EnterTx records that a flow entered phase at now, with its SLA in seconds
+// (0 for none).
+func (r *PhaseDurationRepo) EnterTx(ctx context.Context, tx *sql.Tx, taskID string, phase domain.Phase, now, slaSec int64) error {
+	const q = `INSERT INTO phase_durations (task_id, phase, entered_at, sla_sec) VALUES (?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, q, taskID, string(phase), now, slaSec); err != nil {
+		return fmt.Errorf("enter phase: %w", err)
+	}
+	return nil
+}
+
+// ExitTx records that a flow left its current phase at now.
+func (r *PhaseDurationRepo) ExitTx(ctx context.Context, tx *sql.Tx, taskID string, now int64) error {
+	const q = `UPDATE phase_durations SET exited_at = ? WHERE task_id = ? AND exited_at = 0`
+	if _, err := tx.ExecContext(ctx, q, now, taskID); err != nil {
+		return fmt.Errorf("exit phase: %w", err)
+	}
+	return nil
+}
+
+// ListByTask returns a flow's stays in order of entry.
+func (r *PhaseDurationRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string, now int64) ([]domain.PhaseDuration, error) {
+	q := `SELECT ` + phaseDurationColumns + ` FROM phase_durations WHERE task_id = ? ORDER BY id ASC`
+	return r.list(ctx, db, q, now, taskID)
+}
+
+// ListOverdue returns the open stays of running, blocked, or paused flows
+// that have run past their SLA as of now and were not flagged yet.
+func (r *PhaseDurationRepo) ListOverdue(ctx context.Context, db *sql.DB, now int64) ([]domain.PhaseDuration, error) {
+	q := `SELECT ` + phaseDurationColumns + ` FROM phase_durations
+WHERE exited_at = 0 AND sla_sec > 0 AND sla_warned_at = 0 AND ? - entered_at > sla_sec
+	AND task_id IN (SELECT task_id FROM tasks WHERE status IN (?, ?, ?))
+ORDER BY id ASC`
+	return r.list(ctx, db, q, now, now, string(domain.StatusRunning), string(domain.StatusBlocked), string(domain.StatusPaused))
+}
+
+// MarkWarned records that a stay was flagged for exceeding its SLA.
+func (r *PhaseDurationRepo) MarkWarned(ctx context.Context, db *sql.DB, id, now int64) error {
+	if _, err := db.ExecContext(ctx, `UPDATE phase_durations SET sla_warned_at = ? WHERE id = ?`, now, id); err != nil {
+		return fmt.Errorf("mark phase SLA warned: %w", err)
+	}
+	return nil
+}
+
+// Summarize returns every phase's duration statistics as of now, in phase
+// order.
+func (r *PhaseDurationRepo) Summarize(ctx context.Context, db *sql.DB, now int64) ([]domain.PhaseDurationStats, error) {
+	const q = `SELECT phase, COUNT(*),
+	COALESCE(SUM(exited_at > 0), 0),
+	COALESCE(AVG(CASE WHEN exited_at > 0 THEN exited_at - entered_at END), 0),
+	COALESCE(MAX(CASE WHEN exited_at > 0 THEN exited_at - entered_at END), 0),
+	COALESCE(SUM(sla_sec > 0 AND (CASE WHEN exited_at > 0 THEN exited_at ELSE ? END) - entered_at > sla_sec), 0)
+FROM phase_durations GROUP BY phase ORDER BY phase ASC`
+	rows, err := db.QueryContext(ctx, q, now)
+	if err != nil {
+		return nil, fmt.Errorf("summarize phase durations: %w", err)
+	}
+	defer rows.Close()
+
+	var out []domain.PhaseDurationStats
+	for rows.Next() {
+		var s domain.PhaseDurationStats
+		var phase string
+		if err := rows.Scan(&phase, &s.Entries, &s.Finished, &s.AvgSec, &s.MaxSec, &s.Breaches); err != nil {
+			return nil, fmt.Errorf("scan phase duration stats: %w", err)
+		}
+		s.Phase = domain.Phase(phase)
+		s.Open = s.Entries - s.Finished
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *PhaseDurationRepo) list(ctx context.Context, db *sql.DB, q string, args ...interface{}) ([]domain.PhaseDuration, error) {
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list phase durations: %w", err)
+	}
+	defer rows.Close()
+
+	var out []domain.PhaseDuration
+	for rows.Next() {
+		var d domain.PhaseDuration
+		var phase string
+		if err := rows.Scan(&d.ID, &d.TaskID, &phase, &d.EnteredAt, &d.ExitedAt, &d.DurationSec, &d.SLASec, &d.SLAWarnedAt); err != nil {
+			return nil, fmt.Errorf("scan phase duration: %w", err)
+		}
+		d.Phase = domain.Phase(phase)
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestPhaseDurationRepo(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	repo := &PhaseDurationRepo{}
+
+	tx, _ := db.BeginTx(ctx, nil)
+	for _, s := range []domain.FlowState{
+		{TaskID: "t1", CurrentPhase: domain.PhaseB, Status: domain.StatusRunning},
+		{TaskID: "t2", CurrentPhase: domain.PhaseA, Status: domain.StatusDone},
+	} {
+		if err := (&TaskRepo{}).CreateTx(ctx, tx, s); err != nil {
+			t.Fatalf("CreateTx: %v", err)
+		}
+	}
+	repo.EnterTx(ctx, tx, "t1", domain.PhaseA, 100, 60)
+	repo.ExitTx(ctx, tx, "t1", 200)
+	repo.EnterTx(ctx, tx, "t1", domain.PhaseB, 200, 60)
+	repo.EnterTx(ctx, tx, "t2", domain.PhaseA, 100, 10)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	stays, err := repo.ListByTask(ctx, db, "t1", 250)
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(stays) != 2 || stays[0].DurationSec != 100 || stays[1].ExitedAt != 0 || stays[1].DurationSec != 50 {
+		t.Errorf("stays = %+v", stays)
+	}
+
+	// t1's stay in B is only overdue after 60s; t2 is done and never is.
+	if overdue, _ := repo.ListOverdue(ctx, db, 250); len(overdue) != 0 {
+		t.Errorf("overdue at 250 = %+v, want none", overdue)
+	}
+	overdue, err := repo.ListOverdue(ctx, db, 300)
+	if err != nil || len(overdue) != 1 || overdue[0].Phase != domain.PhaseB {
+		t.Fatalf("overdue at 300 = %+v, %v, want t1 in B", overdue, err)
+	}
+	repo.MarkWarned(ctx, db, overdue[0].ID, 300)
+	if overdue, _ := repo.ListOverdue(ctx, db, 400); len(overdue) != 0 {
+		t.Errorf("overdue after warning = %+v, want none", overdue)
+	}
+
+	stats, err := repo.Summarize(ctx, db, 300)
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("stats = %+v, want A and B", stats)
+	}
+	a, b := stats[0], stats[1]
+	if a.Phase != domain.PhaseA || a.Entries != 2 || a.Finished != 1 || a.Open != 1 || a.AvgSec != 100 || a.MaxSec != 100 || a.Breaches != 2 {
+		t.Errorf("A = %+v", a)
+	}
+	if b.Phase != domain.PhaseB || b.Open != 1 || b.Breaches != 1 {
+		t.Errorf("B = %+v", b)
+	}
+}
+
+func TestPhaseDurations_BackfilledFromEvents(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	tx, _ := db.BeginTx(ctx, nil)
+	for _, ev := range []domain.WorkflowEvent{
+		{TaskID: "t1", SeqNo: 1, Phase: domain.PhaseA, EventType: "flow_started", PayloadJSON: "{}", CreatedAt: 100},
+		{TaskID: "t1", SeqNo: 2, Phase: domain.PhaseA, EventType: "budget_changed", PayloadJSON: "{}", CreatedAt: 105},
+		{TaskID: "t1", SeqNo: 3, Phase: domain.PhaseF, EventType: "phase_transition", PayloadJSON: "{}", CreatedAt: 110},
+		{TaskID: "t1", SeqNo: 4, Phase: domain.PhaseG, EventType: "phase_transition", PayloadJSON: "{}", CreatedAt: 140},
+		{TaskID: "t2", SeqNo: 1, Phase: domain.PhaseA, EventType: "flow_started", PayloadJSON: "{}", CreatedAt: 100},
+	} {
+		if err := (&EventRepo{}).AppendTx(ctx, tx, ev); err != nil {
+			t.Fatalf("AppendTx: %v", err)
+		}
+	}
+	tx.Commit()
+	if _, err := db.ExecContext(ctx, schemaV27); err != nil {
+		t.Fatalf("rerun schemaV27: %v", err)
+	}
+
+	repo := &PhaseDurationRepo{}
+	t1, _ := repo.ListByTask(ctx, db, "t1", 1000)
+	if len(t1) != 2 || t1[0].DurationSec != 10 || t1[1].Phase != domain.PhaseF || t1[1].ExitedAt != 140 {
+		t.Errorf("t1 = %+v, want A for 10s and F until 140", t1)
+	}
+	t2, _ := repo.ListByTask(ctx, db, "t2", 1000)
+	if len(t2) != 1 || t2[0].ExitedAt != 0 {
+		t.Errorf("t2 = %+v, want one open stay in A", t2)
+	}
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// ProviderRepo handles persistence for providers registered at runtime.
+type ProviderRepo struct{}
+
+// Upsert stores p, replacing the registration of the same name but keeping
+// its created_at. It reports whether p was new.
+func (r *ProviderRepo) Upsert(ctx context.Context, db *sql.DB, p domain.ProviderRegistration) (bool, error) {
+	args, err := json.Marshal(p.Args)
+	if err != nil {
+		return false, fmt.Errorf("marshal provider args: %w", err)
+	}
+	env, err := json.Marshal(p.Env)
+	if err != nil {
+		return false, fmt.Errorf("marshal provider env: %w", err)
+	}
+	var existing int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM providers WHERE name = ?`, string(p.Name)).Scan(&existing); err != nil {
+		return false, fmt.Errorf("look up provider: %w", err)
+	}
+	const q = `INSERT INTO providers (name, command, args_json, env_json, adapter, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(name) DO UPDATE SET
+	command = excluded.command,
+	args_json = excluded.args_json,
+	env_json = excluded.env_json,
+	adapter = excluded.adapter,
+	updated_at = excluded.updated_at`
+	if _, err := db.ExecContext(ctx, q, string(p.Name), p.Command, string(args), string(env), p.Adapter, p.CreatedAt, p.UpdatedAt); err != nil {
+		return false, fmt.Errorf("upsert provider: %w", err)
+	}
+	return existing == 0, nil
+}
+
+// Delete removes the named registration. It reports whether one existed.
+func (r *ProviderRepo) Delete(ctx context.Context, db *sql.DB, name domain.Provider) (bool, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM providers WHERE name = ?`, string(name))
+	if err != nil {
+		return false, fmt.Errorf("delete provider: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("check rows affected: %w", err)
+	}
+	return n == 1, nil
+}
+
+// List returns every registration, by name.
+func (r *ProviderRepo) List(ctx context.Context, db *sql.DB) ([]domain.ProviderRegistration, error) {
+	const q = `SELECT name, command, args_json, env_json, adapter, created_at, updated_at FROM providers ORDER BY name`
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("list providers: %w", err)
+	}
+	defer rows.Close()
+
+	var providers []domain.ProviderRegistration
+	for rows.Next() {
+		var p domain.ProviderRegistration
+		var name, args, env string
+		if err := rows.Scan(&name, &p.Command, &args, &env, &p.Adapter, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan provider: %w", err)
+		}
+		p.Name = domain.Provider(name)
+		if err := json.Unmarshal([]byte(args), &p.Args); err != nil {
+			return nil, fmt.Errorf("decode args of provider %s: %w", name, err)
+		}
+		if err := json.Unmarshal([]byte(env), &p.Env); err != nil {
+			return nil, fmt.Errorf("decode env of provider %s: %w", name, err)
+		}
+		providers = append(providers, p)
+	}
+	return providers, rows.Err()
+}
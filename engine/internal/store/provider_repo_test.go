@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestProviderRepo_UpsertListDelete(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	repo := &ProviderRepo{}
+
+	p := domain.ProviderRegistration{
+		Name:      "aider",
+		Command:   "aider",
+		Args:      []string{"--json"},
+		Env:       map[string]string{"OPENAI_API_KEY": "${keychain:openai}"},
+		CreatedAt: 100,
+		UpdatedAt: 100,
+	}
+	if created, err := repo.Upsert(ctx, db, p); err != nil || !created {
+		t.Fatalf("Upsert = %v, %v, want created", created, err)
+	}
+	p.Args, p.Adapter, p.CreatedAt, p.UpdatedAt = []string{"--json", "--yes"}, "claude", 200, 200
+	if created, err := repo.Upsert(ctx, db, p); err != nil || created {
+		t.Fatalf("second Upsert = %v, %v, want an update", created, err)
+	}
+
+	list, err := repo.List(ctx, db)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	want := p
+	want.CreatedAt = 100 // kept from the first registration
+	if len(list) != 1 || !reflect.DeepEqual(list[0], want) {
+		t.Errorf("List = %+v, want [%+v]", list, want)
+	}
+
+	if ok, err := repo.Delete(ctx, db, "aider"); err != nil || !ok {
+		t.Errorf("Delete = %v, %v", ok, err)
+	}
+	if ok, _ := repo.Delete(ctx, db, "aider"); ok {
+		t.Error("Delete of a missing provider reported one")
+	}
+	if list, _ := repo.List(ctx, db); len(list) != 0 {
+		t.Errorf("List after Delete = %+v", list)
+	}
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// purgedTables lists the tables holding a task's rows under a task_id
+// column, in the order PurgeRepo deletes them. The task row goes last.
+var purgedTables = []string{
+	"workflow_events",
+	"phase_snapshots",
+	"audit_records",
+	"audit_chains",
+	"intent_logs",
+	"workers",
+	"score_cards",
+	"cost_deltas",
+	"budget_alerts",
+	"session_events",
+	"decisions",
+	"artifacts",
+	"task_constraints",
+	"risks",
+	"review_rounds",
+	"review_issues",
+	"gate_decisions",
+	"approvals",
+	"ci_checks",
+	"issue_links",
+	"issue_sync_ops",
+	"phase_durations",
+	"dead_letter_events",
+	"tasks",
+}
+
+// TaskTables returns the tables holding a task's rows under a task_id column,
+// the task table last.
+func TaskTables() []string {
+	return append([]string(nil), purgedTables...)
+}
+
+// PurgeRepo removes every row the engine stores for a task.
+type PurgeRepo struct{}
+
+// purgeTarget selects a task's rows in one table.
+type purgeTarget struct {
+	table string
+	where string
+	args  []interface{}
+}
+
+// purgeScope returns the targets selecting taskID's rows: every purged
+// table, plus the task's rate-limit buckets, which are keyed by the bare
+// task ID or the ID and an operation.
+func purgeScope(taskID string) []purgeTarget {
+	scope := make([]purgeTarget, 0, len(purgedTables)+1)
+	for _, table := range purgedTables {
+		scope = append(scope, purgeTarget{table, "task_id = ?", []interface{}{taskID}})
+	}
+	op := taskID + "/op:"
+	return append(scope, purgeTarget{"rate_buckets", "bucket_key = ? OR substr(bucket_key, 1, length(?)) = ?", []interface{}{taskID, op, op}})
+}
+
+// Count returns the number of rows each table holds for taskID, omitting
+// tables with none.
+func (r *PurgeRepo) Count(ctx context.Context, db *sql.DB, taskID string) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for _, s := range purgeScope(taskID) {
+		var n int64
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+s.table+` WHERE `+s.where, s.args...).Scan(&n); err != nil {
+			return nil, fmt.Errorf("count %s rows: %w", s.table, err)
+		}
+		if n > 0 {
+			counts[s.table] = n
+		}
+	}
+	return counts, nil
+}
+
+// DeleteTx deletes every row of taskID within a transaction and returns the
+// number removed from each table, omitting tables with none.
+func (r *PurgeRepo) DeleteTx(ctx context.Context, tx *sql.Tx, taskID string) (map[string]int64, error) {
+	deleted := make(map[string]int64)
+	for _, s := range purgeScope(taskID) {
+		res, err := tx.ExecContext(ctx, `DELETE FROM `+s.table+` WHERE `+s.where, s.args...)
+		if err != nil {
+			return nil, fmt.Errorf("purge %s: %w", s.table, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("purge %s: %w", s.table, err)
+		}
+		if n > 0 {
+			deleted[s.table] = n
+		}
+	}
+	invalidateTask(taskID)
+	return deleted, nil
+}
+
+// ExclusiveBlobs returns the content hashes of taskID's artifacts that no
+// other task's artifact shares, in order.
+func (r *PurgeRepo) ExclusiveBlobs(ctx context.Context, db *sql.DB, taskID string) ([]string, error) {
+	const q = `SELECT DISTINCT hash FROM artifacts
+WHERE task_id = ? AND hash != ''
+AND hash NOT IN (SELECT hash FROM artifacts WHERE task_id != ?)
+ORDER BY hash`
+	rows, err := db.QueryContext(ctx, q, taskID, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list exclusive blobs: %w", err)
+	}
+	defer rows.Close()
+	var hashes []string
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			return nil, fmt.Errorf("scan blob hash: %w", err)
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, rows.Err()
+}
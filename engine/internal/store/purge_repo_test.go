@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestPurgeRepo_CoversEveryTaskTable(t *testing.T) {
+	db, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	purged := make(map[string]bool)
+	for _, table := range purgedTables {
+		purged[table] = true
+	}
+	rows, err := db.Query(`SELECT m.name FROM sqlite_master m, pragma_table_info(m.name) c
+WHERE m.type = 'table' AND c.name = 'task_id'`)
+	if err != nil {
+		t.Fatalf("list tables: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table string
+		rows.Scan(&table)
+		if !purged[table] {
+			t.Errorf("table %s has a task_id column but is not purged", table)
+		}
+	}
+}
+
+func TestPurgeRepo_DeleteTx(t *testing.T) {
+	db, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	for _, id := range []string{"t1", "t2"} {
+		tx, _ := db.Begin()
+		if err := (&TaskRepo{}).CreateTx(ctx, tx, domain.FlowState{TaskID: id, CurrentPhase: domain.PhaseA, Status: domain.StatusDone}); err != nil {
+			t.Fatalf("CreateTx: %v", err)
+		}
+		tx.Commit()
+		(&AuditRepo{}).Record(ctx, db, domain.AuditRecord{ID: "a-" + id, TaskID: id, Category: "c", Action: "a"})
+		(&ArtifactRepo{}).Create(ctx, db, domain.ArtifactRef{ID: "art-" + id, TaskID: id, Type: "doc", Path: "design.md", Hash: "shared"})
+		(&RateBucketRepo{}).Put(ctx, db, domain.RateBucket{Key: id, Tokens: 1})
+		(&RateBucketRepo{}).Put(ctx, db, domain.RateBucket{Key: id + "/op:exec", Tokens: 1})
+	}
+	(&ArtifactRepo{}).Create(ctx, db, domain.ArtifactRef{ID: "art-own", TaskID: "t1", Type: "doc", Path: "notes.md", Hash: "own"})
+	(&RateBucketRepo{}).Put(ctx, db, domain.RateBucket{Key: "t1x", Tokens: 1})
+
+	repo := &PurgeRepo{}
+	counts, err := repo.Count(ctx, db, "t1")
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if counts["tasks"] != 1 || counts["audit_records"] != 1 || counts["audit_chains"] != 1 ||
+		counts["artifacts"] != 2 || counts["rate_buckets"] != 2 || counts["workers"] != 0 {
+		t.Errorf("counts = %v", counts)
+	}
+	if blobs, err := repo.ExclusiveBlobs(ctx, db, "t1"); err != nil || len(blobs) != 1 || blobs[0] != "own" {
+		t.Errorf("ExclusiveBlobs = %v, %v; want [own]", blobs, err)
+	}
+
+	tx, _ := db.Begin()
+	deleted, err := repo.DeleteTx(ctx, tx, "t1")
+	if err != nil {
+		t.Fatalf("DeleteTx: %v", err)
+	}
+	tx.Commit()
+	if len(deleted) != len(counts) {
+		t.Errorf("deleted = %v, want %v", deleted, counts)
+	}
+	if _, err := (&TaskRepo{}).GetByID(ctx, db, "t1"); err != domain.ErrFlowNotFound {
+		t.Errorf("GetByID after purge = %v, want ErrFlowNotFound", err)
+	}
+	if left, _ := repo.Count(ctx, db, "t1"); len(left) != 0 {
+		t.Errorf("rows left = %v", left)
+	}
+	// Other tasks, and buckets that only share a prefix, are untouched.
+	if others, _ := repo.Count(ctx, db, "t2"); others["tasks"] != 1 || others["rate_buckets"] != 2 {
+		t.Errorf("t2 rows = %v", others)
+	}
+	if b, _ := (&RateBucketRepo{}).Get(ctx, db, "t1x"); b == nil {
+		t.Error("bucket t1x was purged with t1")
+	}
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// RateBucketRepo persists guard token buckets.
+type RateBucketRepo struct{}
+
+// Get returns the bucket stored under key, or nil if there is none.
+func (r *RateBucketRepo) Get(ctx context.Context, db *sql.DB, key string) (*domain.RateBucket, error) {
+	const q = `SELECT bucket_key, tokens, updated_at_ms FROM rate_buckets WHERE bucket_key = ?`
+	var b domain.RateBucket
+	err := db.QueryRowContext(ctx, q, key).Scan(&b.Key, &b.Tokens, &b.UpdatedAtMS)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get rate bucket: %w", err)
+	}
+	return &b, nil
+}
+
+// Put stores b, replacing any bucket under the same key.
+func (r *RateBucketRepo) Put(ctx context.Context, db *sql.DB, b domain.RateBucket) error {
+	const q = `INSERT INTO rate_buckets (bucket_key, tokens, updated_at_ms) VALUES (?, ?, ?)
+ON CONFLICT(bucket_key) DO UPDATE SET tokens = excluded.tokens, updated_at_ms = excluded.updated_at_ms`
+	if _, err := db.ExecContext(ctx, q, b.Key, b.Tokens, b.UpdatedAtMS); err != nil {
+		return fmt.Errorf("put rate bucket: %w", err)
+	}
+	return nil
+}
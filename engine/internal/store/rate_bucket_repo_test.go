@@ -0,0 +1,35 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestRateBucketRepo_GetPut(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &RateBucketRepo{}
+	if b, err := repo.Get(ctx, db, "task-1"); err != nil || b != nil {
+		t.Fatalf("Get missing = %+v, %v; want nil", b, err)
+	}
+	for _, tokens := range []float64{4.5, 2.25} {
+		if err := repo.Put(ctx, db, domain.RateBucket{Key: "task-1", Tokens: tokens, UpdatedAtMS: 1000}); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	b, err := repo.Get(ctx, db, "task-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if b.Tokens != 2.25 || b.UpdatedAtMS != 1000 {
+		t.Errorf("bucket = %+v, want the last Put", b)
+	}
+}
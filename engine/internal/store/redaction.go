@@ -0,0 +1,22 @@
+package store
+
+import (
+	"sync/atomic"
+
+	"github.com/anthropics/three-body-engine/internal/redact"
+)
+
+// redactor masks secrets in event, audit, and transcript payloads as they
+// are written. It is process-wide because every repo writes through it,
+// however the repo was constructed.
+var redactor atomic.Pointer[redact.Redactor]
+
+// SetRedactor installs r for all payload writes; nil turns redaction off.
+func SetRedactor(r *redact.Redactor) {
+	redactor.Store(r)
+}
+
+// redactPayload returns s with secrets masked by the installed redactor.
+func redactPayload(s string) string {
+	return redactor.Load().String(s)
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/redact"
+)
+
+func TestRedactor_MasksPayloads(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	r := redact.Default()
+	SetRedactor(r)
+	t.Cleanup(func() { SetRedactor(nil) })
+
+	ctx := context.Background()
+	secret := `{"env":"API_KEY=abcd1234efgh"}`
+
+	if err := (&AuditRepo{}).Record(ctx, db, domain.AuditRecord{
+		ID: "aud-1", TaskID: "t1", Category: "exec", Action: "exec",
+		RequestJSON: secret, DecisionJSON: secret, CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := (&EventRepo{}).AppendTx(ctx, tx, domain.WorkflowEvent{TaskID: "t1", SeqNo: 1, Phase: domain.PhaseA, EventType: "x_test", PayloadJSON: secret, CreatedAt: 1}); err != nil {
+		t.Fatalf("AppendTx: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if _, err := (&SessionEventRepo{}).Append(ctx, db, domain.SessionEvent{SessionID: "s1", TaskID: "t1", EventType: "output", PayloadJSON: secret, CreatedAt: 1}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	audits, _ := (&AuditRepo{}).ListByTask(ctx, db, "t1")
+	events, _ := (&EventRepo{}).ListByTask(ctx, db, "t1", 0)
+	sessions, _ := (&SessionEventRepo{}).ListBySession(ctx, db, "s1", 0)
+	if len(audits) != 1 || len(events) != 1 || len(sessions) != 1 {
+		t.Fatalf("stored %d audits, %d events, %d session events", len(audits), len(events), len(sessions))
+	}
+	for _, stored := range []string{audits[0].RequestJSON, audits[0].DecisionJSON, events[0].PayloadJSON, sessions[0].PayloadJSON} {
+		if strings.Contains(stored, "abcd1234efgh") || !strings.Contains(stored, redact.Mask) {
+			t.Errorf("stored payload %q is not redacted", stored)
+		}
+	}
+	if got := r.Stats().ByRule["keyword:api_key"]; got != 4 {
+		t.Errorf("api_key redactions = %d, want 4", got)
+	}
+}
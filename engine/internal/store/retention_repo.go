@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// RetainedTables lists the append-only tables a RetentionPolicy may prune.
+var RetainedTables = map[string]bool{
+	"workflow_events": true,
+	"cost_deltas":     true,
+	"audit_records":   true,
+	"gate_decisions":  true,
+}
+
+// RetentionPolicy bounds how much history one table keeps. A row expires
+// when it is older than MaxAgeSec or falls outside the newest MaxRowsPerTask
+// rows of its task. Zero disables a limit.
+type RetentionPolicy struct {
+	Table          string
+	MaxAgeSec      int64
+	MaxRowsPerTask int
+}
+
+// ExpiredRow is a row selected for removal, with its columns keyed by name.
+type ExpiredRow struct {
+	RowID   int64
+	Columns map[string]interface{}
+}
+
+// RetentionRepo selects and deletes rows that fall outside a RetentionPolicy.
+type RetentionRepo struct{}
+
+// ListExpired returns up to limit rows of the policy's table that have
+// expired as of now (unix seconds), oldest first.
+func (r *RetentionRepo) ListExpired(ctx context.Context, db *sql.DB, policy RetentionPolicy, now int64, limit int) ([]ExpiredRow, error) {
+	if !RetainedTables[policy.Table] {
+		return nil, fmt.Errorf("list expired rows: unsupported table %q", policy.Table)
+	}
+	if policy.MaxAgeSec <= 0 && policy.MaxRowsPerTask <= 0 {
+		return nil, nil
+	}
+
+	q := `SELECT * FROM (
+	SELECT rowid AS _rowid, t.*,
+		ROW_NUMBER() OVER (PARTITION BY task_id ORDER BY rowid DESC) AS _rank
+	FROM ` + policy.Table + ` AS t
+)
+WHERE (? > 0 AND created_at < ?) OR (? > 0 AND _rank > ?)
+ORDER BY _rowid
+LIMIT ?`
+	rows, err := db.QueryContext(ctx, q,
+		policy.MaxAgeSec, now-policy.MaxAgeSec,
+		policy.MaxRowsPerTask, policy.MaxRowsPerTask,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list expired %s: %w", policy.Table, err)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("list expired %s: %w", policy.Table, err)
+	}
+
+	var expired []ExpiredRow
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("scan expired %s: %w", policy.Table, err)
+		}
+
+		row := ExpiredRow{Columns: make(map[string]interface{}, len(cols))}
+		for i, col := range cols {
+			switch col {
+			case "_rowid":
+				row.RowID, _ = values[i].(int64)
+			case "_rank":
+			default:
+				if b, ok := values[i].([]byte); ok {
+					values[i] = string(b)
+				}
+				row.Columns[col] = values[i]
+			}
+		}
+		expired = append(expired, row)
+	}
+	return expired, rows.Err()
+}
+
+// DeleteTx removes the given rows from a retained table within a transaction
+// and returns the number deleted.
+func (r *RetentionRepo) DeleteTx(ctx context.Context, tx *sql.Tx, table string, rowIDs []int64) (int64, error) {
+	if !RetainedTables[table] {
+		return 0, fmt.Errorf("delete expired rows: unsupported table %q", table)
+	}
+	if len(rowIDs) == 0 {
+		return 0, nil
+	}
+
+	args := make([]interface{}, len(rowIDs))
+	for i, id := range rowIDs {
+		args[i] = id
+	}
+	in := `rowid IN (?` + strings.Repeat(",?", len(rowIDs)-1) + `)`
+	if table == "audit_records" {
+		// Record where each pruned chain now starts, so the remaining
+		// records still verify.
+		q := `INSERT INTO audit_chains (task_id, pruned_seq, pruned_hash)
+SELECT task_id, MAX(seq), hash FROM audit_records WHERE ` + in + ` AND seq > 0 GROUP BY task_id
+ON CONFLICT(task_id) DO UPDATE SET pruned_seq = excluded.pruned_seq, pruned_hash = excluded.pruned_hash
+WHERE excluded.pruned_seq > audit_chains.pruned_seq`
+		if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+			return 0, fmt.Errorf("prune audit chains: %w", err)
+		}
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE `+in, args...)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired %s: %w", table, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("delete expired %s: %w", table, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestRetentionRepo_ListExpiredByAgeAndRows(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	costs := &CostDeltaRepo{}
+	for i, ts := range []int64{100, 200, 300, 400} {
+		if err := costs.Create(ctx, db, "t1", domain.CostDelta{AmountUSD: float64(i), CreatedAt: ts}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if err := costs.Create(ctx, db, "t2", domain.CostDelta{AmountUSD: 9, CreatedAt: 400}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	repo := &RetentionRepo{}
+
+	// Older than 250s at now=400: only the first t1 row.
+	rows, err := repo.ListExpired(ctx, db, RetentionPolicy{Table: "cost_deltas", MaxAgeSec: 250}, 400, 100)
+	if err != nil {
+		t.Fatalf("ListExpired(age): %v", err)
+	}
+	if len(rows) != 1 || rows[0].Columns["created_at"] != int64(100) {
+		t.Fatalf("age policy rows = %+v, want the created_at=100 row", rows)
+	}
+	if rows[0].Columns["task_id"] != "t1" {
+		t.Errorf("task_id = %v, want t1", rows[0].Columns["task_id"])
+	}
+
+	// Keep 2 rows per task: the two oldest t1 rows expire, t2 is untouched.
+	rows, err = repo.ListExpired(ctx, db, RetentionPolicy{Table: "cost_deltas", MaxRowsPerTask: 2}, 400, 100)
+	if err != nil {
+		t.Fatalf("ListExpired(rows): %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("row policy returned %d rows, want 2", len(rows))
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	n, err := repo.DeleteTx(ctx, tx, "cost_deltas", []int64{rows[0].RowID, rows[1].RowID})
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf("DeleteTx: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("deleted %d rows, want 2", n)
+	}
+
+	left, err := costs.ListByTask(ctx, db, "t1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(left) != 2 || left[0].CreatedAt != 300 {
+		t.Errorf("remaining t1 deltas = %+v, want the two newest", left)
+	}
+}
+
+func TestRetentionRepo_RejectsUnknownTable(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	repo := &RetentionRepo{}
+	if _, err := repo.ListExpired(context.Background(), db, RetentionPolicy{Table: "tasks", MaxAgeSec: 1}, 0, 10); err == nil {
+		t.Error("expected error for a table outside RetainedTables")
+	}
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// ReviewRoundRepo handles persistence for ReviewRound records.
+type ReviewRoundRepo struct{}
+
+// StartTx opens a review round within an existing transaction.
+func (r *ReviewRoundRepo) StartTx(ctx context.Context, tx *sql.Tx, round domain.ReviewRound) error {
+	const q = `INSERT INTO review_rounds (task_id, round, phase, started_at) VALUES (?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, q, round.TaskID, round.Round, string(round.Phase), round.StartedAt); err != nil {
+		return fmt.Errorf("start review round: %w", err)
+	}
+	return nil
+}
+
+// CreateTx inserts round exactly as given, ended or not, within an existing
+// transaction, for rounds carried over from another instance.
+func (r *ReviewRoundRepo) CreateTx(ctx context.Context, tx *sql.Tx, round domain.ReviewRound) error {
+	const q = `INSERT INTO review_rounds (task_id, round, phase, started_at, ended_at, outcome) VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, q, round.TaskID, round.Round, string(round.Phase), round.StartedAt, round.EndedAt, round.Outcome); err != nil {
+		return fmt.Errorf("create review round: %w", err)
+	}
+	return nil
+}
+
+// EndTx closes a task's open review round with outcome within an existing
+// transaction. Flows created before rounds were recorded have no row to
+// close, which is not an error.
+func (r *ReviewRoundRepo) EndTx(ctx context.Context, tx *sql.Tx, taskID string, round int, endedAt int64, outcome string) error {
+	const q = `UPDATE review_rounds SET ended_at = ?, outcome = ?
+WHERE task_id = ? AND round = ? AND ended_at = 0`
+	if _, err := tx.ExecContext(ctx, q, endedAt, outcome, taskID, round); err != nil {
+		return fmt.Errorf("end review round: %w", err)
+	}
+	return nil
+}
+
+// ListByTask returns a task's review rounds in order.
+func (r *ReviewRoundRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]domain.ReviewRound, error) {
+	const q = `SELECT task_id, round, phase, started_at, ended_at, outcome
+FROM review_rounds WHERE task_id = ? ORDER BY round ASC`
+
+	rows, err := db.QueryContext(ctx, q, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list review rounds: %w", err)
+	}
+	defer rows.Close()
+
+	var rounds []domain.ReviewRound
+	for rows.Next() {
+		var rr domain.ReviewRound
+		var phase string
+		if err := rows.Scan(&rr.TaskID, &rr.Round, &phase, &rr.StartedAt, &rr.EndedAt, &rr.Outcome); err != nil {
+			return nil, fmt.Errorf("scan review round: %w", err)
+		}
+		rr.Phase = domain.Phase(phase)
+		rounds = append(rounds, rr)
+	}
+	return rounds, rows.Err()
+}
+
+// CountReviewed returns how many of a task's ended rounds received at least
+// one score card, i.e. the review cycles the flow has been sent back from.
+func (r *ReviewRoundRepo) CountReviewed(ctx context.Context, db *sql.DB, taskID string) (int, error) {
+	const q = `SELECT COUNT(*) FROM review_rounds rr
+WHERE rr.task_id = ? AND rr.ended_at > 0
+AND EXISTS (SELECT 1 FROM score_cards s WHERE s.task_id = rr.task_id AND s.round = rr.round)`
+	var n int
+	if err := db.QueryRowContext(ctx, q, taskID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count reviewed rounds: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestReviewRoundRepo_CountReviewed(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &ReviewRoundRepo{}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	// Round 0 is reviewed and sent back; round 1 is rolled back before any
+	// review; round 2 is still open.
+	for i, outcome := range []string{"rework", "rollback", ""} {
+		if err := repo.StartTx(ctx, tx, domain.ReviewRound{TaskID: "task-1", Round: i, Phase: domain.PhaseC, StartedAt: int64(10 * i)}); err != nil {
+			t.Fatalf("StartTx %d: %v", i, err)
+		}
+		if outcome != "" {
+			if err := repo.EndTx(ctx, tx, "task-1", i, int64(10*i+5), outcome); err != nil {
+				t.Fatalf("EndTx %d: %v", i, err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	cards := &ScoreCardRepo{}
+	for _, c := range []domain.ScoreCard{
+		{ReviewID: "rev-1", TaskID: "task-1", Reviewer: "a", Verdict: "REJECT", Round: 0, CreatedAt: 1},
+		{ReviewID: "rev-2", TaskID: "task-1", Reviewer: "a", Verdict: "APPROVE", Round: 2, CreatedAt: 21},
+	} {
+		if err := cards.Create(ctx, db, c); err != nil {
+			t.Fatalf("Create card: %v", err)
+		}
+	}
+
+	rounds, err := repo.ListByTask(ctx, db, "task-1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(rounds) != 3 || rounds[0].Outcome != "rework" || rounds[0].EndedAt != 5 || rounds[2].EndedAt != 0 {
+		t.Errorf("rounds = %+v", rounds)
+	}
+	n, err := repo.CountReviewed(ctx, db, "task-1")
+	if err != nil {
+		t.Fatalf("CountReviewed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("CountReviewed = %d, want 1", n)
+	}
+
+	history, err := cards.ListHistory(ctx, db, "task-1")
+	if err != nil {
+		t.Fatalf("ListHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].Round != 0 || history[1].Round != 2 {
+		t.Errorf("history = %+v", history)
+	}
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// RiskRepo handles persistence for Risk records.
+type RiskRepo struct{}
+
+// riskColumns is the column list shared by every risk SELECT.
+const riskColumns = "risk_id, task_id, phase, severity, text, status, actor, created_at, resolved_by, resolved_at"
+
+// scanRisk reads one risk row selected with riskColumns.
+func scanRisk(row rowScanner) (domain.Risk, error) {
+	var k domain.Risk
+	var phase string
+	err := row.Scan(&k.RiskID, &k.TaskID, &phase, &k.Severity, &k.Text, &k.Status,
+		&k.Actor, &k.CreatedAt, &k.ResolvedBy, &k.ResolvedAt)
+	k.Phase = domain.Phase(phase)
+	return k, err
+}
+
+// Create inserts a new risk. An empty status is stored as "open".
+func (r *RiskRepo) Create(ctx context.Context, db *sql.DB, k domain.Risk) error {
+	return r.create(ctx, db, k)
+}
+
+// CreateTx inserts a new risk within an existing transaction.
+func (r *RiskRepo) CreateTx(ctx context.Context, tx *sql.Tx, k domain.Risk) error {
+	return r.create(ctx, tx, k)
+}
+
+func (r *RiskRepo) create(ctx context.Context, db execer, k domain.Risk) error {
+	if k.Status == "" {
+		k.Status = "open"
+	}
+	const q = `INSERT INTO risks (` + riskColumns + `)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.ExecContext(ctx, q, k.RiskID, k.TaskID, string(k.Phase), k.Severity, k.Text, k.Status,
+		k.Actor, k.CreatedAt, k.ResolvedBy, k.ResolvedAt)
+	if err != nil {
+		return fmt.Errorf("create risk: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a single risk by its ID.
+func (r *RiskRepo) GetByID(ctx context.Context, db *sql.DB, riskID string) (*domain.Risk, error) {
+	q := `SELECT ` + riskColumns + ` FROM risks WHERE risk_id = ?`
+	k, err := scanRisk(db.QueryRowContext(ctx, q, riskID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrRiskNotFound
+		}
+		return nil, fmt.Errorf("get risk: %w", err)
+	}
+	return &k, nil
+}
+
+// ListByTask returns a task's risks, most severe first and then in creation
+// order, limited to status unless it is empty.
+func (r *RiskRepo) ListByTask(ctx context.Context, db *sql.DB, taskID, status string) ([]domain.Risk, error) {
+	q := `SELECT ` + riskColumns + ` FROM risks
+WHERE task_id = ? AND (? = '' OR status = ?)
+ORDER BY severity ASC, created_at ASC, risk_id ASC`
+
+	rows, err := db.QueryContext(ctx, q, taskID, status, status)
+	if err != nil {
+		return nil, fmt.Errorf("list risks: %w", err)
+	}
+	defer rows.Close()
+
+	var risks []domain.Risk
+	for rows.Next() {
+		k, err := scanRisk(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan risk: %w", err)
+		}
+		risks = append(risks, k)
+	}
+	return risks, rows.Err()
+}
+
+// Resolve marks an open risk resolved by actor at resolvedAt. Resolving a
+// resolved risk is a no-op.
+func (r *RiskRepo) Resolve(ctx context.Context, db *sql.DB, riskID, actor string, resolvedAt int64) error {
+	const q = `UPDATE risks SET status = 'resolved', resolved_by = ?, resolved_at = ?
+WHERE risk_id = ? AND status = 'open'`
+	res, err := db.ExecContext(ctx, q, actor, resolvedAt, riskID)
+	if err != nil {
+		return fmt.Errorf("resolve risk: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("check rows affected: %w", err)
+	} else if n == 0 {
+		_, err := r.GetByID(ctx, db, riskID)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestRiskRepo_CreateListResolve(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &RiskRepo{}
+	for _, k := range []domain.Risk{
+		{RiskID: "r1", TaskID: "task-1", Phase: domain.PhaseB, Severity: "P2", Text: "slow tests", CreatedAt: 10},
+		{RiskID: "r2", TaskID: "task-1", Phase: domain.PhaseC, Severity: "P0", Text: "data loss on migrate", CreatedAt: 20},
+		{RiskID: "r3", TaskID: "task-2", Phase: domain.PhaseB, Severity: "P1", Text: "other task", CreatedAt: 5},
+	} {
+		if err := repo.Create(ctx, db, k); err != nil {
+			t.Fatalf("Create %s: %v", k.RiskID, err)
+		}
+	}
+
+	open, err := repo.ListByTask(ctx, db, "task-1", "open")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(open) != 2 || open[0].RiskID != "r2" || open[1].RiskID != "r1" {
+		t.Fatalf("open = %+v, want [r2 r1]", open)
+	}
+	if open[0].Phase != domain.PhaseC || open[0].Status != "open" {
+		t.Errorf("r2 = %+v, want phase C status open", open[0])
+	}
+
+	if err := repo.Resolve(ctx, db, "r2", "alice", 30); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if err := repo.Resolve(ctx, db, "r2", "bob", 40); err != nil {
+		t.Fatalf("Resolve again: %v", err)
+	}
+	got, err := repo.GetByID(ctx, db, "r2")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Status != "resolved" || got.ResolvedBy != "alice" || got.ResolvedAt != 30 {
+		t.Errorf("resolved risk = %+v, want resolved by alice at 30", got)
+	}
+
+	open, err = repo.ListByTask(ctx, db, "task-1", "open")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(open) != 1 || open[0].RiskID != "r1" {
+		t.Errorf("open after resolve = %+v, want [r1]", open)
+	}
+	all, err := repo.ListByTask(ctx, db, "task-1", "")
+	if err != nil {
+		t.Fatalf("ListByTask all: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("all = %d risks, want 2", len(all))
+	}
+
+	if err := repo.Resolve(ctx, db, "missing", "alice", 50); err != domain.ErrRiskNotFound {
+		t.Errorf("Resolve missing = %v, want ErrRiskNotFound", err)
+	}
+}
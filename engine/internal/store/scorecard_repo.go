@@ -12,8 +12,42 @@ import (
 // ScoreCardRepo handles persistence for ScoreCard records.
 type ScoreCardRepo struct{}
 
+// scoreCardColumns is the column list shared by every score card SELECT.
+const scoreCardColumns = "review_id, task_id, reviewer, correctness, security, maintainability, cost, delivery_risk, issues_json, alternatives_json, verdict, created_at, round, invalidated_at"
+
+// scanScoreCard reads one score card row selected with scoreCardColumns.
+func scanScoreCard(row rowScanner) (domain.ScoreCard, error) {
+	var c domain.ScoreCard
+	var issuesJSON, altsJSON string
+	if err := row.Scan(
+		&c.ReviewID, &c.TaskID, &c.Reviewer,
+		&c.Scores.Correctness, &c.Scores.Security, &c.Scores.Maintainability,
+		&c.Scores.Cost, &c.Scores.DeliveryRisk,
+		&issuesJSON, &altsJSON,
+		&c.Verdict, &c.CreatedAt, &c.Round, &c.InvalidatedAt,
+	); err != nil {
+		return c, fmt.Errorf("scan score card: %w", err)
+	}
+	if err := json.Unmarshal([]byte(issuesJSON), &c.Issues); err != nil {
+		return c, fmt.Errorf("unmarshal issues: %w", err)
+	}
+	if err := json.Unmarshal([]byte(altsJSON), &c.Alternatives); err != nil {
+		return c, fmt.Errorf("unmarshal alternatives: %w", err)
+	}
+	return c, nil
+}
+
 // Create inserts a new score card record.
 func (r *ScoreCardRepo) Create(ctx context.Context, db *sql.DB, card domain.ScoreCard) error {
+	return r.create(ctx, db, card)
+}
+
+// CreateTx inserts a new score card record within an existing transaction.
+func (r *ScoreCardRepo) CreateTx(ctx context.Context, tx *sql.Tx, card domain.ScoreCard) error {
+	return r.create(ctx, tx, card)
+}
+
+func (r *ScoreCardRepo) create(ctx context.Context, db execer, card domain.ScoreCard) error {
 	issuesJSON, err := json.Marshal(card.Issues)
 	if err != nil {
 		return fmt.Errorf("marshal issues: %w", err)
@@ -23,8 +57,8 @@ func (r *ScoreCardRepo) Create(ctx context.Context, db *sql.DB, card domain.Scor
 		return fmt.Errorf("marshal alternatives: %w", err)
 	}
 
-	const q = `INSERT INTO score_cards (review_id, task_id, reviewer, correctness, security, maintainability, cost, delivery_risk, issues_json, alternatives_json, verdict, created_at)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	const q = `INSERT INTO score_cards (review_id, task_id, reviewer, correctness, security, maintainability, cost, delivery_risk, issues_json, alternatives_json, verdict, created_at, round)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	_, err = db.ExecContext(ctx, q,
 		card.ReviewID,
 		card.TaskID,
@@ -38,6 +72,7 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 		string(altsJSON),
 		card.Verdict,
 		card.CreatedAt,
+		card.Round,
 	)
 	if err != nil {
 		return fmt.Errorf("create score card: %w", err)
@@ -45,14 +80,24 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	return nil
 }
 
-// ListByTask returns all score cards for a task, ordered by creation time.
+// ListByTask returns the valid score cards for a task, ordered by creation time.
+// Cards invalidated by a rollback are excluded.
 func (r *ScoreCardRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]domain.ScoreCard, error) {
-	const q = `SELECT review_id, task_id, reviewer, correctness, security, maintainability, cost, delivery_risk, issues_json, alternatives_json, verdict, created_at
-FROM score_cards
+	return r.list(ctx, db, `SELECT `+scoreCardColumns+` FROM score_cards
+WHERE task_id = ? AND invalidated_at = 0
+ORDER BY created_at ASC`, taskID)
+}
+
+// ListHistory returns every score card of a task, including invalidated
+// ones, ordered by round and then creation time.
+func (r *ScoreCardRepo) ListHistory(ctx context.Context, db *sql.DB, taskID string) ([]domain.ScoreCard, error) {
+	return r.list(ctx, db, `SELECT `+scoreCardColumns+` FROM score_cards
 WHERE task_id = ?
-ORDER BY created_at ASC`
+ORDER BY round ASC, created_at ASC`, taskID)
+}
 
-	rows, err := db.QueryContext(ctx, q, taskID)
+func (r *ScoreCardRepo) list(ctx context.Context, db *sql.DB, q string, args ...interface{}) ([]domain.ScoreCard, error) {
+	rows, err := db.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list score cards: %w", err)
 	}
@@ -60,24 +105,23 @@ ORDER BY created_at ASC`
 
 	var cards []domain.ScoreCard
 	for rows.Next() {
-		var c domain.ScoreCard
-		var issuesJSON, altsJSON string
-		if err := rows.Scan(
-			&c.ReviewID, &c.TaskID, &c.Reviewer,
-			&c.Scores.Correctness, &c.Scores.Security, &c.Scores.Maintainability,
-			&c.Scores.Cost, &c.Scores.DeliveryRisk,
-			&issuesJSON, &altsJSON,
-			&c.Verdict, &c.CreatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("scan score card: %w", err)
-		}
-		if err := json.Unmarshal([]byte(issuesJSON), &c.Issues); err != nil {
-			return nil, fmt.Errorf("unmarshal issues: %w", err)
-		}
-		if err := json.Unmarshal([]byte(altsJSON), &c.Alternatives); err != nil {
-			return nil, fmt.Errorf("unmarshal alternatives: %w", err)
+		c, err := scanScoreCard(rows)
+		if err != nil {
+			return nil, err
 		}
 		cards = append(cards, c)
 	}
 	return cards, rows.Err()
 }
+
+// InvalidateSinceTx marks every score card of a task created at or after since
+// as invalidated within a transaction. It returns the number of cards affected.
+func (r *ScoreCardRepo) InvalidateSinceTx(ctx context.Context, tx *sql.Tx, taskID string, since, now int64) (int64, error) {
+	const q = `UPDATE score_cards SET invalidated_at = ?
+WHERE task_id = ? AND created_at >= ? AND invalidated_at = 0`
+	res, err := tx.ExecContext(ctx, q, now, taskID, since)
+	if err != nil {
+		return 0, fmt.Errorf("invalidate score cards: %w", err)
+	}
+	return res.RowsAffected()
+}
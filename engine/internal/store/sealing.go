@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/anthropics/three-body-engine/internal/seal"
+)
+
+// keyring encrypts transcript, snapshot, and audit payloads at rest. Like
+// the redactor it is process-wide, so every repo seals and opens payloads
+// however it was constructed.
+var keyring atomic.Pointer[seal.Keyring]
+
+// SetKeyring installs k for all sealed payload columns; nil stores new
+// payloads in plaintext and fails to open sealed ones.
+func SetKeyring(k *seal.Keyring) {
+	keyring.Store(k)
+}
+
+// sealPayload encrypts s for taskID with the installed keyring.
+func sealPayload(taskID, s string) (string, error) {
+	return keyring.Load().Seal(taskID, s)
+}
+
+// openPayload decrypts s, a payload of taskID, with the installed keyring.
+func openPayload(taskID, s string) (string, error) {
+	return keyring.Load().Open(taskID, s)
+}
+
+// sealedColumns lists, per table, the payload columns stored sealed.
+var sealedColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"audit_records", []string{"request_json", "decision_json"}},
+	{"phase_snapshots", []string{"snapshot_json"}},
+	{"session_events", []string{"payload_json"}},
+}
+
+// isSealedColumn reports whether column of table is stored sealed.
+func isSealedColumn(table, column string) bool {
+	for _, src := range sealedColumns {
+		if src.table != table {
+			continue
+		}
+		for _, c := range src.columns {
+			if c == column {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ResealStats counts the payload rows a reseal rewrote, by table.
+type ResealStats map[string]int64
+
+// rotateBatch is how many rows Reseal reads and rewrites per transaction.
+const rotateBatch = 500
+
+// Reseal rewrites every sealed payload column not in the installed
+// keyring's current form: values sealed with a previous key, or in
+// plaintext, are sealed with the current key, or decrypted when there is
+// none. Rows are rewritten in small transactions, so the engine may keep
+// running.
+func Reseal(ctx context.Context, db *sql.DB) (ResealStats, error) {
+	k := keyring.Load()
+	stats := make(ResealStats)
+	for _, src := range sealedColumns {
+		var after int64
+		for {
+			n, last, err := resealBatch(ctx, db, k, src.table, src.columns, after)
+			if err != nil {
+				return stats, fmt.Errorf("reseal %s: %w", src.table, err)
+			}
+			stats[src.table] += n
+			if last == 0 {
+				break
+			}
+			after = last
+		}
+	}
+	return stats, nil
+}
+
+// resealBatch rewrites the stale rows among the rotateBatch rows of table
+// after rowid after. It returns the number rewritten and the last rowid
+// read, or 0 at the end of the table.
+func resealBatch(ctx context.Context, db *sql.DB, k *seal.Keyring, table string, columns []string, after int64) (int64, int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	sel := `SELECT rowid, task_id`
+	upd := `UPDATE ` + table + ` SET `
+	for i, col := range columns {
+		sel += ", " + col
+		if i > 0 {
+			upd += ", "
+		}
+		upd += col + " = ?"
+	}
+	sel += ` FROM ` + table + ` WHERE rowid > ? ORDER BY rowid LIMIT ?`
+	upd += ` WHERE rowid = ?`
+
+	type row struct {
+		id     int64
+		taskID string
+		values []string
+	}
+	rows, err := tx.QueryContext(ctx, sel, after, rotateBatch)
+	if err != nil {
+		return 0, 0, err
+	}
+	var batch []row
+	for rows.Next() {
+		r := row{values: make([]string, len(columns))}
+		dest := []interface{}{&r.id, &r.taskID}
+		for i := range r.values {
+			dest = append(dest, &r.values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	if len(batch) == 0 {
+		return 0, 0, nil
+	}
+
+	var n int64
+	for _, r := range batch {
+		stale := false
+		for _, v := range r.values {
+			stale = stale || !k.Current(v)
+		}
+		if !stale {
+			continue
+		}
+		args := make([]interface{}, 0, len(columns)+1)
+		for _, v := range r.values {
+			plain, err := k.Open(r.taskID, v)
+			if err != nil {
+				return 0, 0, fmt.Errorf("row %d: %w", r.id, err)
+			}
+			sealed, err := k.Seal(r.taskID, plain)
+			if err != nil {
+				return 0, 0, err
+			}
+			args = append(args, sealed)
+		}
+		if _, err := tx.ExecContext(ctx, upd, append(args, r.id)...); err != nil {
+			return 0, 0, err
+		}
+		n++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return n, batch[len(batch)-1].id, nil
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/seal"
+)
+
+func installKeyring(t *testing.T, current []byte, previous ...[]byte) {
+	t.Helper()
+	k, err := seal.NewKeyring(current, previous...)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	SetKeyring(k)
+	t.Cleanup(func() { SetKeyring(nil) })
+}
+
+// rawPayloads returns the stored payload columns of every sealed table.
+func rawPayloads(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	var out []string
+	for _, q := range []string{
+		`SELECT request_json FROM audit_records UNION ALL SELECT decision_json FROM audit_records`,
+		`SELECT snapshot_json FROM phase_snapshots`,
+		`SELECT payload_json FROM session_events`,
+	} {
+		rows, err := db.Query(q)
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		for rows.Next() {
+			var s string
+			rows.Scan(&s)
+			out = append(out, s)
+		}
+		rows.Close()
+	}
+	return out
+}
+
+func TestSealing_Payloads(t *testing.T) {
+	db, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	key1, key2 := bytes.Repeat([]byte{1}, seal.KeySize), bytes.Repeat([]byte{2}, seal.KeySize)
+	installKeyring(t, key1)
+
+	const secret = `{"code":"proprietary"}`
+	if err := (&AuditRepo{}).Record(ctx, db, domain.AuditRecord{ID: "a1", TaskID: "t1", Category: "c", Action: "a", RequestJSON: secret, DecisionJSON: secret, CreatedAt: 1}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	tx, _ := db.Begin()
+	if err := (&SnapshotRepo{}).SaveTx(ctx, tx, domain.PhaseSnapshot{TaskID: "t1", Phase: domain.PhaseA, SnapshotJSON: secret, CreatedAt: 1}); err != nil {
+		t.Fatalf("SaveTx: %v", err)
+	}
+	tx.Commit()
+	if _, err := (&SessionEventRepo{}).Append(ctx, db, domain.SessionEvent{SessionID: "s1", TaskID: "t1", EventType: "message", PayloadJSON: secret, CreatedAt: 1}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	raw := rawPayloads(t, db)
+	if len(raw) != 4 {
+		t.Fatalf("got %d stored payloads, want 4", len(raw))
+	}
+	for _, s := range raw {
+		if !strings.HasPrefix(s, "tbenc:v1:"+seal.KeyID(key1)+":") {
+			t.Errorf("stored payload %q is not sealed with the key", s)
+		}
+	}
+
+	// Repos and exports return plaintext.
+	audits, err := (&AuditRepo{}).ListByTask(ctx, db, "t1")
+	if err != nil || len(audits) != 1 || audits[0].RequestJSON != secret || audits[0].DecisionJSON != secret {
+		t.Errorf("audits = %+v, %v", audits, err)
+	}
+	snap, err := (&SnapshotRepo{}).GetLatest(ctx, db, "t1", domain.PhaseA)
+	if err != nil || snap == nil || snap.SnapshotJSON != secret {
+		t.Errorf("snapshot = %+v, %v", snap, err)
+	}
+	events, err := (&SessionEventRepo{}).ListBySession(ctx, db, "s1", 0)
+	if err != nil || len(events) != 1 || events[0].PayloadJSON != secret {
+		t.Errorf("session events = %+v, %v", events, err)
+	}
+	var exported []interface{}
+	err = (&ExportRepo{}).Each(ctx, db, "audits", ExportFilter{TaskID: "t1"}, func(row []interface{}) error {
+		exported = append(exported, row...)
+		return nil
+	})
+	if err != nil || exported[6] != secret || exported[7] != secret {
+		t.Errorf("exported = %v, %v", exported, err)
+	}
+
+	// Without the key, sealed payloads do not open.
+	SetKeyring(nil)
+	if _, err := (&AuditRepo{}).ListByTask(ctx, db, "t1"); err == nil || !strings.Contains(err.Error(), domain.ErrSealKeyMissing.Message) {
+		t.Errorf("ListByTask without a key = %v, want ErrSealKeyMissing", err)
+	}
+
+	// Rotating reseals everything with the new key.
+	installKeyring(t, key2, key1)
+	stats, err := Reseal(ctx, db)
+	if err != nil {
+		t.Fatalf("Reseal: %v", err)
+	}
+	if stats["audit_records"] != 1 || stats["phase_snapshots"] != 1 || stats["session_events"] != 1 {
+		t.Errorf("stats = %v, want one row per table", stats)
+	}
+	for _, s := range rawPayloads(t, db) {
+		if !strings.HasPrefix(s, "tbenc:v1:"+seal.KeyID(key2)+":") {
+			t.Errorf("stored payload %q is not sealed with the new key", s)
+		}
+	}
+	if stats, _ := Reseal(ctx, db); stats["audit_records"]+stats["phase_snapshots"]+stats["session_events"] != 0 {
+		t.Errorf("second reseal rewrote %v", stats)
+	}
+
+	// Rotating to no key decrypts.
+	installKeyring(t, nil, key2)
+	if _, err := Reseal(ctx, db); err != nil {
+		t.Fatalf("Reseal: %v", err)
+	}
+	for _, s := range rawPayloads(t, db) {
+		if s != secret {
+			t.Errorf("stored payload %q, want it decrypted", s)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// SessionEventRepo handles persistence for session transcript events.
+type SessionEventRepo struct{}
+
+// Append inserts a session event, allocating the next sequence number for the
+// session. The allocated sequence number is returned.
+func (r *SessionEventRepo) Append(ctx context.Context, db *sql.DB, ev domain.SessionEvent) (int64, error) {
+	const q = `INSERT INTO session_events (session_id, task_id, seq_no, event_type, provider, payload_json, created_at)
+SELECT ?, ?, COALESCE(MAX(seq_no), 0) + 1, ?, ?, ?, ?
+FROM session_events WHERE session_id = ?
+RETURNING seq_no`
+
+	payload, err := sealPayload(ev.TaskID, redactPayload(ev.PayloadJSON))
+	if err != nil {
+		return 0, fmt.Errorf("append session event: %w", err)
+	}
+	var seq int64
+	err = db.QueryRowContext(ctx, q,
+		ev.SessionID,
+		ev.TaskID,
+		ev.EventType,
+		string(ev.Provider),
+		payload,
+		ev.CreatedAt,
+		ev.SessionID,
+	).Scan(&seq)
+	if err != nil {
+		return 0, fmt.Errorf("append session event: %w", err)
+	}
+	return seq, nil
+}
+
+// ListBySession returns events for a session with sequence numbers greater than
+// sinceSeq, ordered by sequence number ascending.
+func (r *SessionEventRepo) ListBySession(ctx context.Context, db *sql.DB, sessionID string, sinceSeq int64) ([]domain.SessionEvent, error) {
+	const q = `SELECT id, session_id, task_id, seq_no, event_type, provider, payload_json, created_at
+FROM session_events
+WHERE session_id = ? AND seq_no > ?
+ORDER BY seq_no ASC`
+
+	rows, err := db.QueryContext(ctx, q, sessionID, sinceSeq)
+	if err != nil {
+		return nil, fmt.Errorf("list session events: %w", err)
+	}
+	defer rows.Close()
+	return scanSessionEvents(rows)
+}
+
+// scanSessionEvents reads session event rows, opening sealed payloads.
+func scanSessionEvents(rows *sql.Rows) ([]domain.SessionEvent, error) {
+	var events []domain.SessionEvent
+	for rows.Next() {
+		var e domain.SessionEvent
+		var provider string
+		if err := rows.Scan(&e.ID, &e.SessionID, &e.TaskID, &e.SeqNo, &e.EventType, &provider, &e.PayloadJSON, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan session event: %w", err)
+		}
+		e.Provider = domain.Provider(provider)
+		var err error
+		if e.PayloadJSON, err = openPayload(e.TaskID, e.PayloadJSON); err != nil {
+			return nil, fmt.Errorf("open session event %d: %w", e.ID, err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// ListByTask returns every event of a task's sessions, ordered by session and
+// then by sequence number.
+func (r *SessionEventRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]domain.SessionEvent, error) {
+	const q = `SELECT id, session_id, task_id, seq_no, event_type, provider, payload_json, created_at
+FROM session_events
+WHERE task_id = ?
+ORDER BY session_id ASC, seq_no ASC`
+
+	rows, err := db.QueryContext(ctx, q, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list session events: %w", err)
+	}
+	defer rows.Close()
+	return scanSessionEvents(rows)
+}
+
+// CreateTx inserts ev with its own sequence number within an existing
+// transaction, for transcripts carried over from another instance. The row
+// ID is assigned afresh.
+func (r *SessionEventRepo) CreateTx(ctx context.Context, tx *sql.Tx, ev domain.SessionEvent) error {
+	const q = `INSERT INTO session_events (session_id, task_id, seq_no, event_type, provider, payload_json, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	payload, err := sealPayload(ev.TaskID, redactPayload(ev.PayloadJSON))
+	if err != nil {
+		return fmt.Errorf("create session event: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, q, ev.SessionID, ev.TaskID, ev.SeqNo, ev.EventType, string(ev.Provider), payload, ev.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create session event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestSessionEventRepo_AppendAllocatesSequence(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &SessionEventRepo{}
+	now := time.Now().Unix()
+
+	for i, sid := range []string{"ses-a", "ses-a", "ses-b", "ses-a"} {
+		seq, err := repo.Append(ctx, db, domain.SessionEvent{
+			SessionID:   sid,
+			TaskID:      "task-1",
+			EventType:   "message",
+			Provider:    domain.ProviderClaude,
+			PayloadJSON: `{"text":"hi"}`,
+			CreatedAt:   now,
+		})
+		if err != nil {
+			t.Fatalf("Append %d: %v", i, err)
+		}
+		want := map[int]int64{0: 1, 1: 2, 2: 1, 3: 3}[i]
+		if seq != want {
+			t.Errorf("Append %d seq = %d, want %d", i, seq, want)
+		}
+	}
+
+	got, err := repo.ListBySession(ctx, db, "ses-a", 0)
+	if err != nil {
+		t.Fatalf("ListBySession: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(got))
+	}
+	if got[0].Provider != domain.ProviderClaude || got[0].PayloadJSON != `{"text":"hi"}` {
+		t.Errorf("unexpected event: %+v", got[0])
+	}
+
+	got, err = repo.ListBySession(ctx, db, "ses-a", 2)
+	if err != nil {
+		t.Fatalf("ListBySession since 2: %v", err)
+	}
+	if len(got) != 1 || got[0].SeqNo != 3 {
+		t.Errorf("since 2: got %+v, want only seq 3", got)
+	}
+}
+
+func TestSessionEventRepo_ListByTaskAndCreateTx(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &SessionEventRepo{}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	for _, ev := range []domain.SessionEvent{
+		{SessionID: "ses-b", TaskID: "task-1", SeqNo: 1, EventType: "message", PayloadJSON: `{"n":3}`, CreatedAt: 1},
+		{SessionID: "ses-a", TaskID: "task-1", SeqNo: 7, EventType: "message", PayloadJSON: `{"n":2}`, CreatedAt: 1},
+		{SessionID: "ses-a", TaskID: "task-1", SeqNo: 3, EventType: "message", PayloadJSON: `{"n":1}`, CreatedAt: 1},
+		{SessionID: "ses-c", TaskID: "task-2", SeqNo: 1, EventType: "message", PayloadJSON: `{}`, CreatedAt: 1},
+	} {
+		if err := repo.CreateTx(ctx, tx, ev); err != nil {
+			t.Fatalf("CreateTx: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	events, err := repo.ListByTask(ctx, db, "task-1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+	for i, want := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		if events[i].PayloadJSON != want {
+			t.Errorf("events[%d] = %+v, want payload %s", i, events[i], want)
+		}
+	}
+	if events[1].SeqNo != 7 {
+		t.Errorf("SeqNo = %d, want the given 7", events[1].SeqNo)
+	}
+	if seq, err := repo.Append(ctx, db, domain.SessionEvent{SessionID: "ses-a", TaskID: "task-1", EventType: "message", CreatedAt: 2}); err != nil || seq != 8 {
+		t.Errorf("Append after CreateTx = %d, %v; want 8", seq, err)
+	}
+}
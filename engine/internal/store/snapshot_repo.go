@@ -11,17 +11,37 @@ import (
 // SnapshotRepo handles persistence for PhaseSnapshot records.
 type SnapshotRepo struct{}
 
+// snapshotColumns is the column list shared by every snapshot SELECT.
+const snapshotColumns = "id, task_id, phase, round, snapshot_json, checksum, created_at, kind"
+
+// scanSnapshot reads one snapshot row selected with snapshotColumns.
+func scanSnapshot(row rowScanner) (domain.PhaseSnapshot, error) {
+	var s domain.PhaseSnapshot
+	var p string
+	err := row.Scan(&s.ID, &s.TaskID, &p, &s.Round, &s.SnapshotJSON, &s.Checksum, &s.CreatedAt, &s.Kind)
+	s.Phase = domain.Phase(p)
+	if err == nil {
+		s.SnapshotJSON, err = openPayload(s.TaskID, s.SnapshotJSON)
+	}
+	return s, err
+}
+
 // SaveTx inserts a phase snapshot within an existing transaction.
 func (r *SnapshotRepo) SaveTx(ctx context.Context, tx *sql.Tx, snap domain.PhaseSnapshot) error {
-	const q = `INSERT INTO phase_snapshots (task_id, phase, round, snapshot_json, checksum, created_at)
-VALUES (?, ?, ?, ?, ?, ?)`
-	_, err := tx.ExecContext(ctx, q,
+	const q = `INSERT INTO phase_snapshots (task_id, phase, round, snapshot_json, checksum, created_at, kind)
+VALUES (?, ?, ?, ?, ?, ?, ?)`
+	data, err := sealPayload(snap.TaskID, snap.SnapshotJSON)
+	if err != nil {
+		return fmt.Errorf("save snapshot: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, q,
 		snap.TaskID,
 		string(snap.Phase),
 		snap.Round,
-		snap.SnapshotJSON,
+		data,
 		snap.Checksum,
 		snap.CreatedAt,
+		snap.Kind,
 	)
 	if err != nil {
 		return fmt.Errorf("save snapshot: %w", err)
@@ -29,26 +49,51 @@ VALUES (?, ?, ?, ?, ?, ?)`
 	return nil
 }
 
-// GetLatest returns the most recent snapshot for a task and phase.
-// Returns nil if no snapshot exists.
+// GetLatest returns the most recent transition snapshot for a task and
+// phase. Returns nil if no snapshot exists.
 func (r *SnapshotRepo) GetLatest(ctx context.Context, db *sql.DB, taskID string, phase domain.Phase) (*domain.PhaseSnapshot, error) {
-	const q = `SELECT id, task_id, phase, round, snapshot_json, checksum, created_at
+	return r.GetLatestKind(ctx, db, taskID, phase, "")
+}
+
+// GetLatestKind returns the most recent snapshot of kind for a task and
+// phase, or nil if there is none.
+func (r *SnapshotRepo) GetLatestKind(ctx context.Context, db *sql.DB, taskID string, phase domain.Phase, kind string) (*domain.PhaseSnapshot, error) {
+	const q = `SELECT ` + snapshotColumns + `
 FROM phase_snapshots
-WHERE task_id = ? AND phase = ?
-ORDER BY created_at DESC
+WHERE task_id = ? AND phase = ? AND kind = ?
+ORDER BY created_at DESC, id DESC
 LIMIT 1`
 
-	row := db.QueryRowContext(ctx, q, taskID, string(phase))
-
-	var s domain.PhaseSnapshot
-	var p string
-	err := row.Scan(&s.ID, &s.TaskID, &p, &s.Round, &s.SnapshotJSON, &s.Checksum, &s.CreatedAt)
+	s, err := scanSnapshot(db.QueryRowContext(ctx, q, taskID, string(phase), kind))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("get latest snapshot: %w", err)
 	}
-	s.Phase = domain.Phase(p)
 	return &s, nil
 }
+
+// ListByTask returns every snapshot for a task, oldest first.
+func (r *SnapshotRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]domain.PhaseSnapshot, error) {
+	const q = `SELECT ` + snapshotColumns + `
+FROM phase_snapshots
+WHERE task_id = ?
+ORDER BY created_at ASC, id ASC`
+
+	rows, err := db.QueryContext(ctx, q, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snaps []domain.PhaseSnapshot
+	for rows.Next() {
+		s, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan snapshot: %w", err)
+		}
+		snaps = append(snaps, s)
+	}
+	return snaps, rows.Err()
+}
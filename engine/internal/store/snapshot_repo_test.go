@@ -126,3 +126,38 @@ func TestSnapshotRepo_DifferentPhases(t *testing.T) {
 		t.Errorf("phase B checksum = %q, want %q", gotB.Checksum, "b1")
 	}
 }
+
+func TestSnapshotRepo_GetLatestKind(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &SnapshotRepo{}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	for _, snap := range []domain.PhaseSnapshot{
+		{TaskID: "task-1", Phase: domain.PhaseC, SnapshotJSON: `{"transition":true}`, CreatedAt: 10},
+		{TaskID: "task-1", Phase: domain.PhaseC, SnapshotJSON: `{"compacted":true}`, CreatedAt: 11, Kind: domain.SnapshotCompaction},
+	} {
+		if err := repo.SaveTx(ctx, tx, snap); err != nil {
+			t.Fatalf("SaveTx: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	latest, err := repo.GetLatest(ctx, db, "task-1", domain.PhaseC)
+	if err != nil || latest == nil || latest.CreatedAt != 10 {
+		t.Errorf("GetLatest = %+v, %v; want the transition snapshot", latest, err)
+	}
+	compacted, err := repo.GetLatestKind(ctx, db, "task-1", domain.PhaseC, domain.SnapshotCompaction)
+	if err != nil || compacted == nil || compacted.Kind != domain.SnapshotCompaction {
+		t.Errorf("GetLatestKind = %+v, %v", compacted, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync/atomic"
 
 	_ "modernc.org/sqlite"
 )
@@ -116,15 +117,510 @@ CREATE TABLE IF NOT EXISTS cost_deltas (
 CREATE INDEX IF NOT EXISTS idx_cost_deltas_task ON cost_deltas(task_id);
 `
 
+// schemaV2 adds the per-session transcript of normalized agent events.
+const schemaV2 = `
+CREATE TABLE IF NOT EXISTS session_events (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id   TEXT NOT NULL,
+	task_id      TEXT NOT NULL,
+	seq_no       INTEGER NOT NULL,
+	event_type   TEXT NOT NULL,
+	provider     TEXT NOT NULL DEFAULT '',
+	payload_json TEXT NOT NULL DEFAULT '{}',
+	created_at   INTEGER NOT NULL,
+	UNIQUE(session_id, seq_no)
+);
+CREATE INDEX IF NOT EXISTS idx_session_events_task ON session_events(task_id);
+`
+
+// schemaV3 adds the per-task auto-advance flag.
+const schemaV3 = `
+ALTER TABLE tasks ADD COLUMN auto_advance INTEGER NOT NULL DEFAULT 0;
+`
+
+// schemaV4 adds the timestamps rollback uses to invalidate intents and score
+// cards recorded after the restored phase boundary.
+const schemaV4 = `
+ALTER TABLE intent_logs ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE score_cards ADD COLUMN invalidated_at INTEGER NOT NULL DEFAULT 0;
+`
+
+// schemaV5 links child flows to their parent task.
+const schemaV5 = `
+ALTER TABLE tasks ADD COLUMN parent_task_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_tasks_parent ON tasks(parent_task_id);
+`
+
+// schemaV6 adds the earliest start time of queued flows.
+const schemaV6 = `
+ALTER TABLE tasks ADD COLUMN start_at INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status, start_at);
+`
+
+// schemaV7 adds flow priorities used by the scheduler and worker pool.
+const schemaV7 = `
+ALTER TABLE tasks ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+`
+
+// schemaV8 records each flow's isolated workspace directory.
+const schemaV8 = `
+ALTER TABLE tasks ADD COLUMN workspace TEXT NOT NULL DEFAULT '';
+`
+
+// schemaV9 lets intents wait in a per-file queue instead of failing on a
+// conflicting lock.
+const schemaV9 = `
+ALTER TABLE intent_logs ADD COLUMN lease_sec INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE intent_logs ADD COLUMN queued_at INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE intent_logs ADD COLUMN queue_until INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_intents_file ON intent_logs(task_id, target_file, status);
+`
+
+// schemaV10 stores decisions escalated to a human.
+const schemaV10 = `
+CREATE TABLE IF NOT EXISTS decisions (
+	decision_id  TEXT PRIMARY KEY,
+	task_id      TEXT NOT NULL,
+	kind         TEXT NOT NULL,
+	subject_json TEXT NOT NULL DEFAULT '{}',
+	status       TEXT NOT NULL DEFAULT 'pending',
+	choice       TEXT NOT NULL DEFAULT '',
+	actor        TEXT NOT NULL DEFAULT '',
+	comment      TEXT NOT NULL DEFAULT '',
+	created_at   INTEGER NOT NULL,
+	resolved_at  INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_decisions_task ON decisions(task_id, status);
+`
+
+// schemaV11 links replacement workers to the worker they replace, so the
+// supervisor can bound how often one worker is respawned.
+const schemaV11 = `
+ALTER TABLE workers ADD COLUMN lineage_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE workers ADD COLUMN generation INTEGER NOT NULL DEFAULT 0;
+`
+
+// schemaV12 stores the latest progress report of each worker.
+const schemaV12 = `
+ALTER TABLE workers ADD COLUMN progress_percent INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE workers ADD COLUMN progress_file TEXT NOT NULL DEFAULT '';
+ALTER TABLE workers ADD COLUMN progress_note TEXT NOT NULL DEFAULT '';
+ALTER TABLE workers ADD COLUMN progress_at INTEGER NOT NULL DEFAULT 0;
+`
+
+// schemaV13 adds the artifacts workers submit as deliverables.
+const schemaV13 = `
+CREATE TABLE IF NOT EXISTS artifacts (
+	artifact_id TEXT PRIMARY KEY,
+	task_id     TEXT NOT NULL,
+	worker_id   TEXT NOT NULL DEFAULT '',
+	phase       TEXT NOT NULL DEFAULT '',
+	type        TEXT NOT NULL,
+	path        TEXT NOT NULL,
+	version     INTEGER NOT NULL,
+	hash        TEXT NOT NULL,
+	created_at  INTEGER NOT NULL,
+	UNIQUE (task_id, path, version)
+);
+CREATE INDEX IF NOT EXISTS idx_artifacts_task ON artifacts(task_id, path);
+`
+
+// schemaV14 adds task metadata and the constraints workers must respect,
+// both of which feed context digests.
+const schemaV14 = `
+ALTER TABLE tasks ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN acceptance_criteria TEXT NOT NULL DEFAULT '';
+CREATE TABLE IF NOT EXISTS task_constraints (
+	constraint_id TEXT PRIMARY KEY,
+	task_id       TEXT NOT NULL,
+	text          TEXT NOT NULL,
+	created_at    INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_task_constraints_task ON task_constraints(task_id);
+`
+
+// schemaV15 separates compaction snapshots from the transition snapshots
+// rollback restores.
+const schemaV15 = `
+ALTER TABLE phase_snapshots ADD COLUMN kind TEXT NOT NULL DEFAULT '';
+`
+
+// schemaV16 tracks open risks and lets constraints be resolved.
+const schemaV16 = `
+ALTER TABLE task_constraints ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE task_constraints ADD COLUMN resolved_at INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS risks (
+	risk_id     TEXT PRIMARY KEY,
+	task_id     TEXT NOT NULL,
+	phase       TEXT NOT NULL DEFAULT '',
+	severity    TEXT NOT NULL,
+	text        TEXT NOT NULL,
+	status      TEXT NOT NULL DEFAULT 'open',
+	actor       TEXT NOT NULL DEFAULT '',
+	created_at  INTEGER NOT NULL,
+	resolved_by TEXT NOT NULL DEFAULT '',
+	resolved_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_risks_task ON risks(task_id, status);
+`
+
+// schemaV17 records review rounds and the round of each score card.
+const schemaV17 = `
+ALTER TABLE score_cards ADD COLUMN round INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS review_rounds (
+	task_id    TEXT NOT NULL,
+	round      INTEGER NOT NULL,
+	phase      TEXT NOT NULL DEFAULT '',
+	started_at INTEGER NOT NULL,
+	ended_at   INTEGER NOT NULL DEFAULT 0,
+	outcome    TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (task_id, round)
+);
+`
+
+// schemaV18 stores per-flow consensus settings.
+const schemaV18 = `
+ALTER TABLE tasks ADD COLUMN consensus_json TEXT NOT NULL DEFAULT '';
+`
+
+// schemaV19 promotes score card issues to tracked records.
+const schemaV19 = `
+CREATE TABLE IF NOT EXISTS review_issues (
+	issue_id      TEXT PRIMARY KEY,
+	task_id       TEXT NOT NULL,
+	review_id     TEXT NOT NULL,
+	reviewer      TEXT NOT NULL,
+	round         INTEGER NOT NULL DEFAULT 0,
+	severity      TEXT NOT NULL,
+	location      TEXT NOT NULL DEFAULT '',
+	description   TEXT NOT NULL DEFAULT '',
+	suggestion    TEXT NOT NULL DEFAULT '',
+	evidence      TEXT NOT NULL DEFAULT '',
+	status        TEXT NOT NULL DEFAULT 'open',
+	fix_intent_id TEXT NOT NULL DEFAULT '',
+	fix_commit    TEXT NOT NULL DEFAULT '',
+	note          TEXT NOT NULL DEFAULT '',
+	updated_by    TEXT NOT NULL DEFAULT '',
+	created_at    INTEGER NOT NULL,
+	updated_at    INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_review_issues_task ON review_issues(task_id, status);
+`
+
+// schemaV20 stores the capability sheet a worker was spawned with.
+const schemaV20 = `
+ALTER TABLE workers ADD COLUMN capabilities_json TEXT NOT NULL DEFAULT '';
+`
+
+// schemaV21 persists guard rate limit buckets across restarts.
+const schemaV21 = `
+CREATE TABLE IF NOT EXISTS rate_buckets (
+	bucket_key    TEXT PRIMARY KEY,
+	tokens        REAL NOT NULL,
+	updated_at_ms INTEGER NOT NULL
+);
+`
+
+// schemaV22 keeps every gate evaluation, so a blocked flow's history can be
+// explained.
+const schemaV22 = `
+CREATE TABLE IF NOT EXISTS gate_decisions (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id       TEXT NOT NULL,
+	phase         TEXT NOT NULL,
+	gate          TEXT NOT NULL,
+	allow         INTEGER NOT NULL,
+	blockers_json TEXT NOT NULL DEFAULT '[]',
+	retryable     INTEGER NOT NULL DEFAULT 0,
+	action        TEXT NOT NULL DEFAULT '',
+	actor         TEXT NOT NULL DEFAULT '',
+	created_at    INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_gate_decisions_task ON gate_decisions(task_id, id);
+`
+
+// schemaV23 records human approvals of phase exits.
+const schemaV23 = `
+CREATE TABLE IF NOT EXISTS approvals (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id    TEXT NOT NULL,
+	phase      TEXT NOT NULL,
+	round      INTEGER NOT NULL DEFAULT 0,
+	actor      TEXT NOT NULL,
+	decision   TEXT NOT NULL,
+	comment    TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_approvals_task ON approvals(task_id, phase, round);
+`
+
+// schemaV24 places each task in a namespace. Rows in the other tables belong
+// to the namespace of their task.
+const schemaV24 = `
+ALTER TABLE tasks ADD COLUMN namespace TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS idx_tasks_namespace ON tasks(namespace, status);
+`
+
+// schemaV25 adds named leases, held by one engine instance at a time.
+const schemaV25 = `
+CREATE TABLE IF NOT EXISTS leases (
+	name        TEXT PRIMARY KEY,
+	holder      TEXT NOT NULL,
+	acquired_at INTEGER NOT NULL,
+	expires_at  INTEGER NOT NULL
+);
+`
+
+// schemaV26 stores API responses by idempotency key so a retried request
+// is answered without running again.
+const schemaV26 = `
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key        TEXT PRIMARY KEY,
+	method     TEXT NOT NULL,
+	path       TEXT NOT NULL,
+	status     INTEGER NOT NULL,
+	body       TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_created ON idempotency_keys(created_at);
+`
+
+// schemaV27 records when each flow entered and left each phase, with the
+// SLA in force on entry, and backfills the rows from the event log.
+const schemaV27 = `
+CREATE TABLE IF NOT EXISTS phase_durations (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id       TEXT NOT NULL,
+	phase         TEXT NOT NULL,
+	entered_at    INTEGER NOT NULL,
+	exited_at     INTEGER NOT NULL DEFAULT 0,
+	sla_sec       INTEGER NOT NULL DEFAULT 0,
+	sla_warned_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_phase_durations_task ON phase_durations(task_id, id);
+CREATE INDEX IF NOT EXISTS idx_phase_durations_open ON phase_durations(exited_at);
+
+INSERT INTO phase_durations (task_id, phase, entered_at, exited_at)
+SELECT task_id, phase, created_at, COALESCE(left_at, 0) FROM (
+	SELECT task_id, seq_no, phase, created_at,
+		LEAD(created_at) OVER (PARTITION BY task_id ORDER BY seq_no) AS left_at
+	FROM workflow_events WHERE event_type IN ('flow_started', 'phase_transition')
+)
+WHERE phase != 'G'
+ORDER BY task_id, seq_no;
+`
+
+// schemaV28 records which worker incurred each cost delta.
+const schemaV28 = `
+ALTER TABLE cost_deltas ADD COLUMN worker_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_cost_deltas_created ON cost_deltas(created_at);
+`
+
+// schemaV29 keeps the session output lines no adapter could parse.
+const schemaV29 = `
+CREATE TABLE IF NOT EXISTS dead_letter_events (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id  TEXT NOT NULL,
+	task_id     TEXT NOT NULL,
+	worker_id   TEXT NOT NULL DEFAULT '',
+	provider    TEXT NOT NULL,
+	adapter     TEXT NOT NULL,
+	raw         BLOB NOT NULL,
+	error       TEXT NOT NULL,
+	created_at  INTEGER NOT NULL,
+	requeued_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_dead_letter_events_pending ON dead_letter_events(requeued_at, id);
+`
+
+// schemaV30 records the payload schema version of each workflow event.
+// Events written before it used the first version of every schema.
+const schemaV30 = `
+ALTER TABLE workflow_events ADD COLUMN schema_version INTEGER NOT NULL DEFAULT 1;
+`
+
+// schemaV31 indexes workflow events for filtered listing: by type, and by
+// the payload fields rollbacks and manual actions are looked up by.
+const schemaV31 = `
+CREATE INDEX IF NOT EXISTS idx_events_task_type ON workflow_events(task_id, event_type, seq_no);
+CREATE INDEX IF NOT EXISTS idx_events_task_action ON workflow_events(task_id, json_extract(payload_json, '$.action'), seq_no);
+CREATE INDEX IF NOT EXISTS idx_events_task_actor ON workflow_events(task_id, json_extract(payload_json, '$.actor'), seq_no);
+`
+
+// schemaV32 stores providers registered at runtime through the API.
+const schemaV32 = `
+CREATE TABLE IF NOT EXISTS providers (
+	name TEXT PRIMARY KEY,
+	command TEXT NOT NULL,
+	args_json TEXT NOT NULL DEFAULT '[]',
+	env_json TEXT NOT NULL DEFAULT '{}',
+	adapter TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+`
+
+// schemaV33 adds the dedupe key of cost deltas. A provider's cost line
+// that is emitted twice carries the same key and is recorded once.
+const schemaV33 = `
+ALTER TABLE cost_deltas ADD COLUMN dedupe_key TEXT NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cost_deltas_key ON cost_deltas(task_id, dedupe_key) WHERE dedupe_key != '';
+`
+
+// schemaV34 chains each task's audit records: every record stores its
+// position in the chain, the hash of the record before it, and its own
+// hash. audit_chains keeps each chain's head, so records deleted from the
+// end are noticed, and the last record retention pruned, so verification
+// can start where the remaining records do.
+const schemaV34 = `
+ALTER TABLE audit_records ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE audit_records ADD COLUMN prev_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_records ADD COLUMN hash TEXT NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_task_seq ON audit_records(task_id, seq) WHERE seq > 0;
+
+CREATE TABLE IF NOT EXISTS audit_chains (
+	task_id     TEXT PRIMARY KEY,
+	head_seq    INTEGER NOT NULL DEFAULT 0,
+	head_hash   TEXT NOT NULL DEFAULT '',
+	pruned_seq  INTEGER NOT NULL DEFAULT 0,
+	pruned_hash TEXT NOT NULL DEFAULT ''
+);
+`
+
+// schemaV35 records the budget alerts fired for each task. An alert fires
+// once per budget cap, so raising a task's cap re-arms its alerts.
+const schemaV35 = `
+CREATE TABLE IF NOT EXISTS budget_alerts (
+	task_id     TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	cap_usd     REAL NOT NULL,
+	ratio       REAL NOT NULL,
+	used_usd    REAL NOT NULL,
+	fired_at    INTEGER NOT NULL,
+	PRIMARY KEY (task_id, name, cap_usd)
+);
+`
+
+// schemaV36 records the latest result of each CI check on a task's branch.
+const schemaV36 = `
+CREATE TABLE IF NOT EXISTS ci_checks (
+	task_id     TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	status      TEXT NOT NULL,
+	url         TEXT NOT NULL DEFAULT '',
+	updated_at  INTEGER NOT NULL,
+	PRIMARY KEY (task_id, name)
+);
+`
+
+// schemaV37 links tasks to their tracking issues and queues the changes
+// still to be sent to the tracker.
+const schemaV37 = `
+CREATE TABLE IF NOT EXISTS issue_links (
+	task_id     TEXT PRIMARY KEY,
+	provider    TEXT NOT NULL,
+	issue_key   TEXT NOT NULL,
+	issue_id    TEXT NOT NULL DEFAULT '',
+	url         TEXT NOT NULL DEFAULT '',
+	created_at  INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS issue_sync_ops (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id          TEXT NOT NULL,
+	kind             TEXT NOT NULL,
+	body             TEXT NOT NULL DEFAULT '',
+	attempts         INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at  INTEGER NOT NULL DEFAULT 0,
+	last_error       TEXT NOT NULL DEFAULT '',
+	created_at       INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_issue_sync_ops_task ON issue_sync_ops(task_id, id);
+`
+
+// migrations lists schema versions in order. The number of applied
+// migrations is tracked in PRAGMA user_version.
+var migrations = []string{
+	schemaV1,
+	schemaV2,
+	schemaV3,
+	schemaV4,
+	schemaV5,
+	schemaV6,
+	schemaV7,
+	schemaV8,
+	schemaV9,
+	schemaV10,
+	schemaV11,
+	schemaV12,
+	schemaV13,
+	schemaV14,
+	schemaV15,
+	schemaV16,
+	schemaV17,
+	schemaV18,
+	schemaV19,
+	schemaV20,
+	schemaV21,
+	schemaV22,
+	schemaV23,
+	schemaV24,
+	schemaV25,
+	schemaV26,
+	schemaV27,
+	schemaV28,
+	schemaV29,
+	schemaV30,
+	schemaV31,
+	schemaV32,
+	schemaV33,
+	schemaV34,
+	schemaV35,
+	schemaV36,
+	schemaV37,
+}
+
+// MemoryPath is the db_path that keeps the database in memory instead of on
+// disk, for tests and demos. Its contents are lost when the engine exits.
+const MemoryPath = ":memory:"
+
+// memorySeq names each in-memory database, so every NewDB(MemoryPath) opens
+// a fresh one.
+var memorySeq atomic.Int64
+
+// IsMemory reports whether path selects an in-memory database.
+func IsMemory(path string) bool {
+	return path == MemoryPath
+}
+
 // NewDB opens a SQLite database at the given path with recommended pragmas
-// and runs the V1 schema migration.
+// and runs any pending schema migrations. MemoryPath opens a new, empty
+// in-memory database instead.
 func NewDB(path string) (*sql.DB, error) {
+	return NewDBWith(path, DBOptions{})
+}
+
+// NewDBWith is NewDB with opts.
+func NewDBWith(path string, opts DBOptions) (*sql.DB, error) {
 	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=foreign_keys(ON)&_pragma=busy_timeout(5000)", path)
+	if IsMemory(path) {
+		// A named shared-cache database lives as long as a connection to it,
+		// and the pool below keeps its one connection open.
+		dsn = fmt.Sprintf("file:threebody-mem-%d?mode=memory&cache=shared&_pragma=foreign_keys(ON)&_pragma=busy_timeout(5000)", memorySeq.Add(1))
+	}
 
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
+	if opts.BeforeWrite != nil {
+		// Opening connects nothing yet, so db is only asked for its driver.
+		drv := db.Driver()
+		db.Close()
+		db = sql.OpenDB(writeHookConnector{dsn: dsn, drv: drv, before: opts.BeforeWrite})
+	}
 
 	// Limit connections to 1 for SQLite (WAL allows concurrent reads but single writer).
 	db.SetMaxOpenConns(1)
@@ -137,7 +633,67 @@ func NewDB(path string) (*sql.DB, error) {
 	return db, nil
 }
 
+// NewReadDB opens a pool of query-only connections to a database already
+// opened (and migrated) by NewDB. WAL mode lets these readers run alongside
+// the single writer connection, so list and stream queries are not queued
+// behind writes. maxConns <= 0 leaves the pool size unlimited. An in-memory
+// database has no read pool: its readers share the writer connection.
+func NewReadDB(path string, maxConns int) (*sql.DB, error) {
+	if IsMemory(path) {
+		return nil, fmt.Errorf("open read database: %s has no read pool", path)
+	}
+	dsn := fmt.Sprintf("file:%s?_pragma=query_only(ON)&_pragma=busy_timeout(5000)", path)
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open read database: %w", err)
+	}
+	if maxConns > 0 {
+		db.SetMaxOpenConns(maxConns)
+		db.SetMaxIdleConns(maxConns)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open read database: %w", err)
+	}
+	return db, nil
+}
+
+// migrate applies every migration newer than the database's user_version,
+// each in its own transaction.
 func migrate(db *sql.DB) error {
-	_, err := db.ExecContext(context.Background(), schemaV1)
-	return err
+	ctx := context.Background()
+
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the number of migrations applied to the database and
+// the number this build expects.
+func SchemaVersion(ctx context.Context, db *sql.DB) (current, latest int, err error) {
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&current); err != nil {
+		return 0, 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return current, len(migrations), nil
 }
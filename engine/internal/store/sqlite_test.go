@@ -1,8 +1,11 @@
 package store
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
 )
 
 func TestNewDB(t *testing.T) {
@@ -65,3 +68,97 @@ func TestNewDB_IdempotentMigration(t *testing.T) {
 	}
 	db2.Close()
 }
+
+func TestNewDB_RecordsSchemaVersion(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	current, latest, err := SchemaVersion(context.Background(), db)
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if current != latest {
+		t.Errorf("current = %d, latest = %d; want equal", current, latest)
+	}
+}
+
+func TestNewReadDB_SeesWritesAndRejectsThem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	rdb, err := NewReadDB(path, 4)
+	if err != nil {
+		t.Fatalf("NewReadDB: %v", err)
+	}
+	defer rdb.Close()
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, `INSERT INTO tasks (task_id) VALUES ('t1')`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	// Hold a write transaction open; readers must not wait on it.
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO tasks (task_id) VALUES ('t2')`); err != nil {
+		t.Fatalf("insert in tx: %v", err)
+	}
+
+	var n int
+	if err := rdb.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks`).Scan(&n); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("count = %d, want 1 (committed rows only)", n)
+	}
+
+	if _, err := rdb.ExecContext(ctx, `INSERT INTO tasks (task_id) VALUES ('t3')`); err == nil {
+		t.Error("expected write through the read pool to fail")
+	}
+}
+
+func TestNewDB_Memory(t *testing.T) {
+	ctx := context.Background()
+	a, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer a.Close()
+	b, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer b.Close()
+
+	if current, latest, _ := SchemaVersion(ctx, a); current != latest {
+		t.Errorf("schema version = %d, want %d", current, latest)
+	}
+	tx, _ := a.Begin()
+	if err := (&TaskRepo{}).CreateTx(ctx, tx, domain.FlowState{TaskID: "t1", CurrentPhase: domain.PhaseA, Status: domain.StatusRunning, StateVersion: 1}); err != nil {
+		t.Fatalf("CreateTx: %v", err)
+	}
+	tx.Commit()
+	if _, err := (&TaskRepo{}).GetByID(ctx, a, "t1"); err != nil {
+		t.Errorf("GetByID: %v", err)
+	}
+	if _, err := (&TaskRepo{}).GetByID(ctx, b, "t1"); err != domain.ErrFlowNotFound {
+		t.Errorf("second memory database: err = %v, want it empty", err)
+	}
+
+	if _, err := NewReadDB(MemoryPath, 4); err == nil {
+		t.Error("NewReadDB(MemoryPath) succeeded, want no read pool")
+	}
+	if err := Restore(ctx, MemoryPath, filepath.Join(t.TempDir(), "snap.db")); err == nil {
+		t.Error("Restore(MemoryPath) succeeded, want an error")
+	}
+}
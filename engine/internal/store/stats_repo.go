@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// StatsRepo computes the fleet-wide counters of domain.EngineStats.
+type StatsRepo struct{}
+
+// Compute returns every counter except AdvanceP95MS, which is not stored.
+// Spend counts the cost deltas recorded at or after since.
+func (r *StatsRepo) Compute(ctx context.Context, db *sql.DB, since int64) (*domain.EngineStats, error) {
+	stats := &domain.EngineStats{
+		FlowsByStatus:     map[domain.FlowStatus]int{},
+		FlowsByPhase:      map[domain.Phase]int{},
+		PhaseDurationsSec: map[domain.Phase]float64{},
+		FailuresByCause:   map[domain.FailureCause]int{},
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT status, current_phase, COUNT(*) FROM tasks GROUP BY status, current_phase`)
+	if err != nil {
+		return nil, fmt.Errorf("count flows: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status, phase string
+		var n int
+		if err := rows.Scan(&status, &phase, &n); err != nil {
+			return nil, fmt.Errorf("scan flow count: %w", err)
+		}
+		stats.FlowsByStatus[domain.FlowStatus(status)] += n
+		if s := domain.FlowStatus(status); s != domain.StatusDone && s != domain.StatusFailed {
+			stats.FlowsByPhase[domain.Phase(phase)] += n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount_usd), 0) FROM cost_deltas WHERE created_at >= ?`,
+		since).Scan(&stats.SpendTodayUSD); err != nil {
+		return nil, fmt.Errorf("sum spend: %w", err)
+	}
+
+	durations, err := db.QueryContext(ctx, `SELECT phase, AVG(exited_at - entered_at) FROM phase_durations WHERE exited_at > 0 GROUP BY phase`)
+	if err != nil {
+		return nil, fmt.Errorf("average phase durations: %w", err)
+	}
+	defer durations.Close()
+	for durations.Next() {
+		var phase string
+		var avg float64
+		if err := durations.Scan(&phase, &avg); err != nil {
+			return nil, fmt.Errorf("scan phase duration: %w", err)
+		}
+		stats.PhaseDurationsSec[domain.Phase(phase)] = avg
+	}
+	if err := durations.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(AVG(CASE WHEN allow THEN 0.0 ELSE 1.0 END), 0) FROM gate_decisions`).
+		Scan(&stats.GateBlockRate); err != nil {
+		return nil, fmt.Errorf("gate block rate: %w", err)
+	}
+
+	// A worker replaced after a hard timeout is left in state replaced.
+	const timeouts = `SELECT COALESCE(AVG(CASE WHEN state IN (?, ?, ?) THEN 1.0 ELSE 0.0 END), 0) FROM workers`
+	if err := db.QueryRowContext(ctx, timeouts, string(domain.WorkerSoftTimeout), string(domain.WorkerHardTimeout),
+		string(domain.WorkerReplaced)).Scan(&stats.WorkerTimeoutRate); err != nil {
+		return nil, fmt.Errorf("worker timeout rate: %w", err)
+	}
+
+	// A flow's failure cause is on its latest flow_failed event.
+	const failures = `SELECT json_extract(e.payload_json, '$.cause'), COUNT(*)
+FROM tasks t JOIN workflow_events e ON e.task_id = t.task_id
+WHERE t.status = ? AND e.event_type = 'flow_failed' AND e.seq_no = (
+	SELECT MAX(seq_no) FROM workflow_events WHERE task_id = t.task_id AND event_type = 'flow_failed')
+GROUP BY 1`
+	causes, err := db.QueryContext(ctx, failures, string(domain.StatusFailed))
+	if err != nil {
+		return nil, fmt.Errorf("count failure causes: %w", err)
+	}
+	defer causes.Close()
+	for causes.Next() {
+		var cause sql.NullString
+		var n int
+		if err := causes.Scan(&cause, &n); err != nil {
+			return nil, fmt.Errorf("scan failure cause: %w", err)
+		}
+		stats.FailuresByCause[domain.FailureCause(cause.String)] += n
+	}
+	if err := causes.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestStatsRepo_Compute(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	tasks := &TaskRepo{}
+	for _, s := range []domain.FlowState{
+		{TaskID: "t1", CurrentPhase: domain.PhaseC, Status: domain.StatusRunning},
+		{TaskID: "t2", CurrentPhase: domain.PhaseG, Status: domain.StatusDone},
+		{TaskID: "t3", CurrentPhase: domain.PhaseC, Status: domain.StatusBlocked},
+		{TaskID: "t4", CurrentPhase: domain.PhaseD, Status: domain.StatusFailed},
+		{TaskID: "t5", CurrentPhase: domain.PhaseE, Status: domain.StatusFailed},
+	} {
+		if err := tasks.CreateTx(ctx, tx, s); err != nil {
+			t.Fatalf("CreateTx: %v", err)
+		}
+	}
+	// t4 failed on its budget; t5 was failed by hand, then failed again on
+	// its gate.
+	events := &EventRepo{}
+	for i, ev := range []domain.WorkflowEvent{
+		{TaskID: "t4", SeqNo: 1, EventType: "flow_failed", PayloadJSON: `{"cause":"budget"}`},
+		{TaskID: "t5", SeqNo: 1, EventType: "flow_failed", PayloadJSON: `{"cause":"manual"}`},
+		{TaskID: "t5", SeqNo: 2, EventType: "flow_failed", PayloadJSON: `{"cause":"gate"}`},
+	} {
+		if err := events.AppendTx(ctx, tx, ev); err != nil {
+			t.Fatalf("AppendTx %d: %v", i, err)
+		}
+	}
+	// t1 spent 10s in A and 30s in B and is in C; t2 spent 20s in A.
+	durations := &PhaseDurationRepo{}
+	for _, stay := range []struct {
+		task        string
+		phase       domain.Phase
+		enter, exit int64
+	}{
+		{"t1", domain.PhaseA, 100, 110},
+		{"t1", domain.PhaseB, 110, 140},
+		{"t1", domain.PhaseC, 140, 0},
+		{"t2", domain.PhaseA, 100, 120},
+	} {
+		if err := durations.ExitTx(ctx, tx, stay.task, stay.enter); err != nil {
+			t.Fatalf("ExitTx: %v", err)
+		}
+		if err := durations.EnterTx(ctx, tx, stay.task, stay.phase, stay.enter, 0); err != nil {
+			t.Fatalf("EnterTx: %v", err)
+		}
+		if stay.exit > 0 {
+			durations.ExitTx(ctx, tx, stay.task, stay.exit)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	costs := &CostDeltaRepo{}
+	costs.Create(ctx, db, "t1", domain.CostDelta{AmountUSD: 1.5, CreatedAt: 50})
+	costs.Create(ctx, db, "t1", domain.CostDelta{AmountUSD: 2, CreatedAt: 1000})
+	costs.Create(ctx, db, "t2", domain.CostDelta{AmountUSD: 0.5, CreatedAt: 2000})
+
+	gates := &GateDecisionRepo{}
+	gates.Create(ctx, db, domain.GateRecord{TaskID: "t1", Gate: "default", Allow: true})
+	gates.Create(ctx, db, domain.GateRecord{TaskID: "t3", Gate: "review"})
+
+	workers := &WorkerRepo{}
+	for id, state := range map[string]domain.WorkerState{"w1": domain.WorkerDone, "w2": domain.WorkerReplaced, "w3": domain.WorkerRunning, "w4": domain.WorkerDone} {
+		if err := workers.Create(ctx, db, domain.WorkerRef{WorkerID: id, TaskID: "t1", Phase: domain.PhaseC, State: state}); err != nil {
+			t.Fatalf("Create worker: %v", err)
+		}
+	}
+
+	stats, err := (&StatsRepo{}).Compute(ctx, db, 1000)
+	if err != nil {
+		t.Fatalf("Compute: %v", err)
+	}
+	if stats.FlowsByStatus[domain.StatusRunning] != 1 || stats.FlowsByStatus[domain.StatusDone] != 1 || stats.FlowsByStatus[domain.StatusBlocked] != 1 ||
+		stats.FlowsByStatus[domain.StatusFailed] != 2 {
+		t.Errorf("FlowsByStatus = %v", stats.FlowsByStatus)
+	}
+	if stats.FlowsByPhase[domain.PhaseC] != 2 || stats.FlowsByPhase[domain.PhaseG] != 0 {
+		t.Errorf("FlowsByPhase = %v, want 2 unfinished flows in C", stats.FlowsByPhase)
+	}
+	if stats.SpendTodayUSD != 2.5 {
+		t.Errorf("SpendTodayUSD = %v, want 2.5", stats.SpendTodayUSD)
+	}
+	if stats.PhaseDurationsSec[domain.PhaseA] != 15 || stats.PhaseDurationsSec[domain.PhaseB] != 30 || len(stats.PhaseDurationsSec) != 2 {
+		t.Errorf("PhaseDurationsSec = %v, want A 15 and B 30", stats.PhaseDurationsSec)
+	}
+	if stats.GateBlockRate != 0.5 || stats.WorkerTimeoutRate != 0.25 {
+		t.Errorf("rates = %v, %v, want 0.5 and 0.25", stats.GateBlockRate, stats.WorkerTimeoutRate)
+	}
+	if len(stats.FailuresByCause) != 2 || stats.FailuresByCause[domain.FailureBudget] != 1 || stats.FailuresByCause[domain.FailureGate] != 1 {
+		t.Errorf("FailuresByCause = %v, want one budget and one gate failure", stats.FailuresByCause)
+	}
+}
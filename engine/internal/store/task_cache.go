@@ -0,0 +1,118 @@
+package store
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// TaskCache holds the task states TaskRepo.GetByID reads from one database,
+// so the guard, gates, and bridge do not each query the row on every
+// operation. Every TaskRepo write drops the entry of the task it changes,
+// so all TaskRepo values share the cache registered for a database.
+type TaskCache struct {
+	db   *sql.DB
+	size int
+
+	mu      sync.Mutex
+	entries map[string]domain.FlowState
+	// gen counts invalidations, so a load that raced a write is not cached.
+	gen uint64
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// TaskCacheStats counts task reads served from the cache and from the
+// database.
+type TaskCacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+// taskCaches maps each database with a cache to its TaskCache.
+var taskCaches sync.Map
+
+// CacheTasks caches up to size task states read from db and returns the
+// cache. When full, an arbitrary entry makes room for a new one. Only the
+// writer database should be cached: a read-only pool may return a row a
+// write has not yet committed to.
+func CacheTasks(db *sql.DB, size int) *TaskCache {
+	c := &TaskCache{db: db, size: size, entries: make(map[string]domain.FlowState)}
+	taskCaches.Store(db, c)
+	return c
+}
+
+// Close stops caching reads from the cache's database.
+func (c *TaskCache) Close() {
+	taskCaches.CompareAndDelete(c.db, c)
+}
+
+// Stats returns the cache counters. A nil cache reports zeros.
+func (c *TaskCache) Stats() TaskCacheStats {
+	if c == nil {
+		return TaskCacheStats{}
+	}
+	c.mu.Lock()
+	n := len(c.entries)
+	c.mu.Unlock()
+	return TaskCacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Entries: n}
+}
+
+// taskCacheFor returns the cache of db, or nil.
+func taskCacheFor(db *sql.DB) *TaskCache {
+	c, ok := taskCaches.Load(db)
+	if !ok {
+		return nil
+	}
+	return c.(*TaskCache)
+}
+
+// get returns a copy of the cached state of taskID and the generation a
+// load on a miss must pass to put.
+func (c *TaskCache) get(taskID string) (*domain.FlowState, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.entries[taskID]; ok {
+		c.hits.Add(1)
+		return &s, c.gen
+	}
+	c.misses.Add(1)
+	return nil, c.gen
+}
+
+// put caches s unless an invalidation arrived since gen.
+func (c *TaskCache) put(gen uint64, s domain.FlowState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return
+	}
+	if _, ok := c.entries[s.TaskID]; !ok && len(c.entries) >= c.size {
+		for id := range c.entries {
+			delete(c.entries, id)
+			break
+		}
+	}
+	c.entries[s.TaskID] = s
+}
+
+// invalidate drops the cached state of taskID.
+func (c *TaskCache) invalidate(taskID string) {
+	c.mu.Lock()
+	delete(c.entries, taskID)
+	c.gen++
+	c.mu.Unlock()
+}
+
+// invalidateTask drops taskID from every cache. Writes run in transactions,
+// which do not name their database.
+func invalidateTask(taskID string) {
+	taskCaches.Range(func(_, c interface{}) bool {
+		c.(*TaskCache).invalidate(taskID)
+		return true
+	})
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestTaskCache(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	cache := CacheTasks(db, 2)
+	defer cache.Close()
+
+	ctx := context.Background()
+	repo := &TaskRepo{}
+	create := func(id string) {
+		tx, _ := db.Begin()
+		if err := repo.CreateTx(ctx, tx, domain.FlowState{TaskID: id, CurrentPhase: domain.PhaseA, Status: domain.StatusRunning, StateVersion: 1, BudgetCapUSD: 5}); err != nil {
+			t.Fatalf("CreateTx: %v", err)
+		}
+		tx.Commit()
+	}
+	create("t1")
+
+	first, err := repo.GetByID(ctx, db, "t1")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	first.BudgetUsedUSD = 99 // Callers get their own copy.
+	second, _ := repo.GetByID(ctx, db, "t1")
+	if second.BudgetUsedUSD != 0 {
+		t.Errorf("cached state = %+v, changed through an earlier copy", second)
+	}
+	if st := cache.Stats(); st.Hits != 1 || st.Misses != 1 || st.Entries != 1 {
+		t.Errorf("Stats = %+v, want one hit and one miss", st)
+	}
+
+	// Writes drop the entry, so the next read sees them.
+	second.CurrentPhase = domain.PhaseB
+	tx, _ := db.Begin()
+	if err := repo.UpdateStateTx(ctx, tx, *second); err != nil {
+		t.Fatalf("UpdateStateTx: %v", err)
+	}
+	tx.Commit()
+	if s, _ := repo.GetByID(ctx, db, "t1"); s.CurrentPhase != domain.PhaseB || s.StateVersion != 2 {
+		t.Errorf("after update = %+v, want phase B at version 2", s)
+	}
+	tx, _ = db.Begin()
+	repo.AddBudgetUsedTx(ctx, tx, "t1", 1.5)
+	tx.Commit()
+	if s, _ := repo.GetByID(ctx, db, "t1"); s.BudgetUsedUSD != 1.5 {
+		t.Errorf("after spend = %+v, want 1.5 used", s)
+	}
+
+	// A load that raced a write is not cached.
+	_, gen := cache.get("t2")
+	invalidateTask("t2")
+	cache.put(gen, domain.FlowState{TaskID: "t2"})
+	if s, _ := cache.get("t2"); s != nil {
+		t.Errorf("stale load was cached: %+v", s)
+	}
+
+	create("t2")
+	create("t3")
+	repo.GetByID(ctx, db, "t2")
+	repo.GetByID(ctx, db, "t3")
+	if st := cache.Stats(); st.Entries != 2 {
+		t.Errorf("Entries = %d, want the cache bounded at 2", st.Entries)
+	}
+	if _, err := repo.GetByID(ctx, db, "missing"); err != domain.ErrFlowNotFound {
+		t.Errorf("missing task: err = %v, want ErrFlowNotFound", err)
+	}
+
+	cache.Close()
+	before := cache.Stats()
+	repo.GetByID(ctx, db, "t1")
+	if after := cache.Stats(); after.Hits != before.Hits || after.Misses != before.Misses {
+		t.Errorf("closed cache still counted reads: %+v", after)
+	}
+}
@@ -3,7 +3,9 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/anthropics/three-body-engine/internal/domain"
 )
@@ -11,10 +13,25 @@ import (
 // TaskRepo handles persistence for FlowState records.
 type TaskRepo struct{}
 
+// taskColumns lists the tasks columns in the order scanned by scanTask.
+const taskColumns = `task_id, current_phase, status, state_version, round, budget_used_usd, budget_cap_usd, last_event_seq, updated_at_unix, auto_advance, parent_task_id, start_at, priority, workspace, title, description, acceptance_criteria, consensus_json, namespace`
+
 // CreateTx inserts a new task within an existing transaction.
 func (r *TaskRepo) CreateTx(ctx context.Context, tx *sql.Tx, state domain.FlowState) error {
-	const q = `INSERT INTO tasks (task_id, current_phase, status, state_version, round, budget_used_usd, budget_cap_usd, last_event_seq, updated_at_unix)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	const q = `INSERT INTO tasks (` + taskColumns + `)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	var consensusJSON string
+	if state.Consensus != nil {
+		data, err := json.Marshal(state.Consensus)
+		if err != nil {
+			return fmt.Errorf("marshal consensus settings: %w", err)
+		}
+		consensusJSON = string(data)
+	}
+	if state.Namespace == "" {
+		state.Namespace = domain.DefaultNamespace
+	}
+	invalidateTask(state.TaskID)
 	_, err := tx.ExecContext(ctx, q,
 		state.TaskID,
 		string(state.CurrentPhase),
@@ -25,6 +42,16 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 		state.BudgetCapUSD,
 		state.LastEventSeq,
 		state.UpdatedAtUnix,
+		state.AutoAdvance,
+		state.ParentTaskID,
+		state.StartAt,
+		state.Priority,
+		state.Workspace,
+		state.Title,
+		state.Description,
+		state.AcceptanceCriteria,
+		consensusJSON,
+		state.Namespace,
 	)
 	if err != nil {
 		return fmt.Errorf("create task: %w", err)
@@ -43,9 +70,11 @@ func (r *TaskRepo) UpdateStateTx(ctx context.Context, tx *sql.Tx, state domain.F
 		budget_used_usd = ?,
 		budget_cap_usd = ?,
 		last_event_seq = ?,
-		updated_at_unix = ?
+		updated_at_unix = ?,
+		auto_advance = ?
 	WHERE task_id = ? AND state_version = ?`
 
+	invalidateTask(state.TaskID)
 	res, err := tx.ExecContext(ctx, q,
 		string(state.CurrentPhase),
 		string(state.Status),
@@ -54,6 +83,7 @@ func (r *TaskRepo) UpdateStateTx(ctx context.Context, tx *sql.Tx, state domain.F
 		state.BudgetCapUSD,
 		state.LastEventSeq,
 		state.UpdatedAtUnix,
+		state.AutoAdvance,
 		state.TaskID,
 		state.StateVersion,
 	)
@@ -71,23 +101,248 @@ func (r *TaskRepo) UpdateStateTx(ctx context.Context, tx *sql.Tx, state domain.F
 	return nil
 }
 
-// GetByID retrieves a task by its ID.
+// AddBudgetUsedTx adds amountUSD to a task's spend in one statement, so
+// concurrent cost reports cannot overwrite each other, and returns the
+// task's spend and cap after the update. The state version is bumped, as
+// for any other change to the task.
+func (r *TaskRepo) AddBudgetUsedTx(ctx context.Context, tx *sql.Tx, taskID string, amountUSD float64) (used, budgetCap float64, err error) {
+	const q = `UPDATE tasks SET
+		budget_used_usd = budget_used_usd + ?,
+		state_version = state_version + 1
+	WHERE task_id = ?
+	RETURNING budget_used_usd, budget_cap_usd`
+	invalidateTask(taskID)
+	err = tx.QueryRowContext(ctx, q, amountUSD, taskID).Scan(&used, &budgetCap)
+	if err == sql.ErrNoRows {
+		return 0, 0, domain.ErrFlowNotFound
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("add task budget used: %w", err)
+	}
+	return used, budgetCap, nil
+}
+
+// GetByID retrieves a task by its ID, from db's TaskCache when it has one.
 func (r *TaskRepo) GetByID(ctx context.Context, db *sql.DB, taskID string) (*domain.FlowState, error) {
-	const q = `SELECT task_id, current_phase, status, state_version, round, budget_used_usd, budget_cap_usd, last_event_seq, updated_at_unix
-FROM tasks WHERE task_id = ?`
+	cache := taskCacheFor(db)
+	var gen uint64
+	if cache != nil {
+		var s *domain.FlowState
+		if s, gen = cache.get(taskID); s != nil {
+			return s, nil
+		}
+	}
 
-	row := db.QueryRowContext(ctx, q, taskID)
+	const q = `SELECT ` + taskColumns + `
+FROM tasks WHERE task_id = ?`
 
-	var s domain.FlowState
-	var phase, status string
-	err := row.Scan(&s.TaskID, &phase, &status, &s.StateVersion, &s.Round,
-		&s.BudgetUsedUSD, &s.BudgetCapUSD, &s.LastEventSeq, &s.UpdatedAtUnix)
+	s, err := scanTask(db.QueryRowContext(ctx, q, taskID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, domain.ErrFlowNotFound
 		}
 		return nil, fmt.Errorf("get task: %w", err)
 	}
+	if cache != nil {
+		cache.put(gen, *s)
+	}
+	return s, nil
+}
+
+// ListChildren returns the child flows spawned from a parent task, ordered by task ID.
+func (r *TaskRepo) ListChildren(ctx context.Context, db *sql.DB, parentTaskID string) ([]*domain.FlowState, error) {
+	const q = `SELECT ` + taskColumns + `
+FROM tasks WHERE parent_task_id = ?
+ORDER BY task_id ASC`
+
+	return r.list(ctx, db, q, parentTaskID)
+}
+
+// ListQueued returns queued tasks whose start time is at or before now, in
+// start order (highest priority first, then earliest start_at, then queue order).
+func (r *TaskRepo) ListQueued(ctx context.Context, db *sql.DB, now int64) ([]*domain.FlowState, error) {
+	const q = `SELECT ` + taskColumns + `
+FROM tasks WHERE status = ? AND start_at <= ?
+ORDER BY priority DESC, start_at ASC, updated_at_unix ASC, rowid ASC`
+
+	return r.list(ctx, db, q, string(domain.StatusQueued), now)
+}
+
+// ListByStatus returns all tasks with the given status, in start order.
+func (r *TaskRepo) ListByStatus(ctx context.Context, db *sql.DB, status domain.FlowStatus) ([]*domain.FlowState, error) {
+	const q = `SELECT ` + taskColumns + `
+FROM tasks WHERE status = ?
+ORDER BY priority DESC, start_at ASC, updated_at_unix ASC, rowid ASC`
+
+	return r.list(ctx, db, q, string(status))
+}
+
+// ListByNamespace returns the tasks of a namespace, ordered by task ID.
+func (r *TaskRepo) ListByNamespace(ctx context.Context, db *sql.DB, namespace string) ([]*domain.FlowState, error) {
+	const q = `SELECT ` + taskColumns + `
+FROM tasks WHERE namespace = ?
+ORDER BY task_id ASC`
+
+	return r.list(ctx, db, q, namespace)
+}
+
+// allocatedExpr sums the budget held by tasks: the cap of each unfinished
+// top-level task, which covers its children, and the spend of each
+// completed task and of the children of completed tasks.
+const allocatedExpr = `COALESCE(SUM(CASE
+	WHEN parent_task_id = '' AND status != ? THEN budget_cap_usd
+	WHEN parent_task_id = '' OR parent_task_id IN (SELECT task_id FROM tasks WHERE status = ?) THEN budget_used_usd
+	ELSE 0 END), 0)`
+
+// AllocatedBudget returns the budget held by a namespace's tasks; see
+// allocatedExpr.
+func (r *TaskRepo) AllocatedBudget(ctx context.Context, db *sql.DB, namespace string) (float64, error) {
+	const q = `SELECT ` + allocatedExpr + ` FROM tasks WHERE namespace = ?`
+
+	done := string(domain.StatusDone)
+	var allocated float64
+	if err := db.QueryRowContext(ctx, q, done, done, namespace).Scan(&allocated); err != nil {
+		return 0, fmt.Errorf("allocated budget: %w", err)
+	}
+	return allocated, nil
+}
+
+// SummarizeNamespaces returns a summary of every namespace that has tasks,
+// ordered by name. BudgetUSD is left for the caller to fill.
+func (r *TaskRepo) SummarizeNamespaces(ctx context.Context, db *sql.DB) ([]domain.NamespaceSummary, error) {
+	const q = `SELECT namespace, COUNT(*),
+	COALESCE(SUM(CASE WHEN status != ? THEN 1 ELSE 0 END), 0),
+	` + allocatedExpr + `,
+	COALESCE(SUM(budget_used_usd), 0)
+FROM tasks GROUP BY namespace ORDER BY namespace ASC`
+
+	done := string(domain.StatusDone)
+	rows, err := db.QueryContext(ctx, q, done, done, done)
+	if err != nil {
+		return nil, fmt.Errorf("summarize namespaces: %w", err)
+	}
+	defer rows.Close()
+
+	var out []domain.NamespaceSummary
+	for rows.Next() {
+		var s domain.NamespaceSummary
+		if err := rows.Scan(&s.Namespace, &s.Flows, &s.Active, &s.AllocatedUSD, &s.UsedUSD); err != nil {
+			return nil, fmt.Errorf("scan namespace summary: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// CountByStatus returns the number of tasks in any of the given statuses.
+func (r *TaskRepo) CountByStatus(ctx context.Context, db *sql.DB, statuses ...domain.FlowStatus) (int, error) {
+	return r.countByStatus(ctx, db, statuses)
+}
+
+// CountByStatusTx is CountByStatus within a transaction.
+func (r *TaskRepo) CountByStatusTx(ctx context.Context, tx *sql.Tx, statuses ...domain.FlowStatus) (int, error) {
+	return r.countByStatus(ctx, tx, statuses)
+}
+
+func (r *TaskRepo) countByStatus(ctx context.Context, db queryRower, statuses []domain.FlowStatus) (int, error) {
+	if len(statuses) == 0 {
+		return 0, nil
+	}
+	q := `SELECT COUNT(*) FROM tasks WHERE status IN (?` + strings.Repeat(", ?", len(statuses)-1) + `)`
+	args := make([]interface{}, len(statuses))
+	for i, s := range statuses {
+		args[i] = string(s)
+	}
+
+	var n int
+	if err := db.QueryRowContext(ctx, q, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count tasks by status: %w", err)
+	}
+	return n, nil
+}
+
+// WorkspaceHolder returns the ID of a started, unfinished task other than
+// taskID whose workspace is workspace, or "" if there is none. With orUnset,
+// tasks that name no workspace count as holding it too. Queued tasks hold no
+// workspace.
+func (r *TaskRepo) WorkspaceHolder(ctx context.Context, db *sql.DB, taskID, workspace string, orUnset bool) (string, error) {
+	return r.workspaceHolder(ctx, db, taskID, workspace, orUnset)
+}
+
+// WorkspaceHolderTx is WorkspaceHolder within a transaction.
+func (r *TaskRepo) WorkspaceHolderTx(ctx context.Context, tx *sql.Tx, taskID, workspace string, orUnset bool) (string, error) {
+	return r.workspaceHolder(ctx, tx, taskID, workspace, orUnset)
+}
+
+func (r *TaskRepo) workspaceHolder(ctx context.Context, db queryRower, taskID, workspace string, orUnset bool) (string, error) {
+	const q = `SELECT task_id FROM tasks
+WHERE task_id != ? AND status IN (?, ?, ?) AND (workspace = ? OR (? AND workspace = ''))
+ORDER BY rowid ASC LIMIT 1`
+
+	var holder string
+	err := db.QueryRowContext(ctx, q, taskID,
+		string(domain.StatusRunning), string(domain.StatusBlocked), string(domain.StatusPaused),
+		workspace, orUnset).Scan(&holder)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("workspace holder: %w", err)
+	}
+	return holder, nil
+}
+
+// list runs a task SELECT and scans every row.
+func (r *TaskRepo) list(ctx context.Context, db *sql.DB, q string, args ...interface{}) ([]*domain.FlowState, error) {
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []*domain.FlowState
+	for rows.Next() {
+		s, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan task: %w", err)
+		}
+		tasks = append(tasks, s)
+	}
+	return tasks, rows.Err()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx, letting an insert be
+// shared between its plain and Tx variants.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// scanTask reads a FlowState from a row selected with taskColumns.
+func scanTask(row rowScanner) (*domain.FlowState, error) {
+	var s domain.FlowState
+	var phase, status, consensusJSON string
+	err := row.Scan(&s.TaskID, &phase, &status, &s.StateVersion, &s.Round,
+		&s.BudgetUsedUSD, &s.BudgetCapUSD, &s.LastEventSeq, &s.UpdatedAtUnix, &s.AutoAdvance, &s.ParentTaskID, &s.StartAt, &s.Priority, &s.Workspace,
+		&s.Title, &s.Description, &s.AcceptanceCriteria, &consensusJSON, &s.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if consensusJSON != "" {
+		s.Consensus = &domain.ConsensusSettings{}
+		if err := json.Unmarshal([]byte(consensusJSON), s.Consensus); err != nil {
+			return nil, fmt.Errorf("unmarshal consensus settings: %w", err)
+		}
+	}
 	s.CurrentPhase = domain.Phase(phase)
 	s.Status = domain.FlowStatus(status)
 	return &s, nil
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
 	"testing"
 
@@ -131,6 +132,37 @@ func TestTaskRepo_UpdateState_OptimisticLock(t *testing.T) {
 	}
 }
 
+func TestTaskRepo_AddBudgetUsedTx(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &TaskRepo{}
+	tx, _ := db.Begin()
+	if err := repo.CreateTx(ctx, tx, domain.FlowState{TaskID: "t1", CurrentPhase: domain.PhaseA, Status: domain.StatusRunning, StateVersion: 1, BudgetCapUSD: 5, BudgetUsedUSD: 1}); err != nil {
+		t.Fatalf("CreateTx: %v", err)
+	}
+	tx.Commit()
+
+	tx, _ = db.Begin()
+	used, budgetCap, err := repo.AddBudgetUsedTx(ctx, tx, "t1", 1.5)
+	if err != nil || used != 2.5 || budgetCap != 5 {
+		t.Errorf("AddBudgetUsedTx = %v, %v, %v, want 2.5 of 5", used, budgetCap, err)
+	}
+	if _, _, err := repo.AddBudgetUsedTx(ctx, tx, "missing", 1); err != domain.ErrFlowNotFound {
+		t.Errorf("missing task: err = %v, want ErrFlowNotFound", err)
+	}
+	tx.Commit()
+
+	state, _ := repo.GetByID(ctx, db, "t1")
+	if state.BudgetUsedUSD != 2.5 || state.StateVersion != 2 {
+		t.Errorf("state = %+v, want 2.5 used at version 2", state)
+	}
+}
+
 func TestTaskRepo_DuplicateCreate(t *testing.T) {
 	dir := t.TempDir()
 	db, err := NewDB(filepath.Join(dir, "test.db"))
@@ -169,3 +201,167 @@ func TestTaskRepo_DuplicateCreate(t *testing.T) {
 		t.Error("expected error on duplicate create, got nil")
 	}
 }
+
+func TestTaskRepo_ListChildren(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &TaskRepo{}
+
+	tx, _ := db.Begin()
+	for _, s := range []domain.FlowState{
+		{TaskID: "parent", CurrentPhase: domain.PhaseC, Status: domain.StatusRunning, StateVersion: 1},
+		{TaskID: "child-b", CurrentPhase: domain.PhaseA, Status: domain.StatusRunning, StateVersion: 1, ParentTaskID: "parent"},
+		{TaskID: "child-a", CurrentPhase: domain.PhaseA, Status: domain.StatusRunning, StateVersion: 1, ParentTaskID: "parent"},
+	} {
+		if err := repo.CreateTx(ctx, tx, s); err != nil {
+			t.Fatalf("CreateTx %s: %v", s.TaskID, err)
+		}
+	}
+	tx.Commit()
+
+	children, err := repo.ListChildren(ctx, db, "parent")
+	if err != nil {
+		t.Fatalf("ListChildren: %v", err)
+	}
+	if len(children) != 2 || children[0].TaskID != "child-a" || children[1].TaskID != "child-b" {
+		t.Fatalf("children = %+v, want child-a, child-b", children)
+	}
+	if children[0].ParentTaskID != "parent" {
+		t.Errorf("ParentTaskID = %q, want parent", children[0].ParentTaskID)
+	}
+}
+
+func TestTaskRepo_Namespaces(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &TaskRepo{}
+
+	tx, _ := db.Begin()
+	for _, s := range []domain.FlowState{
+		{TaskID: "a1", Namespace: "team-a", Status: domain.StatusRunning, CurrentPhase: domain.PhaseA, StateVersion: 1, BudgetCapUSD: 10, BudgetUsedUSD: 2},
+		{TaskID: "a1-child", Namespace: "team-a", Status: domain.StatusRunning, CurrentPhase: domain.PhaseA, StateVersion: 1, BudgetCapUSD: 5, BudgetUsedUSD: 1, ParentTaskID: "a1"},
+		{TaskID: "a2", Namespace: "team-a", Status: domain.StatusDone, CurrentPhase: domain.PhaseG, StateVersion: 1, BudgetCapUSD: 20, BudgetUsedUSD: 3},
+		{TaskID: "d1", Status: domain.StatusRunning, CurrentPhase: domain.PhaseA, StateVersion: 1, BudgetCapUSD: 7},
+	} {
+		if err := repo.CreateTx(ctx, tx, s); err != nil {
+			t.Fatalf("CreateTx %s: %v", s.TaskID, err)
+		}
+	}
+	tx.Commit()
+
+	if got, _ := repo.GetByID(ctx, db, "d1"); got.Namespace != domain.DefaultNamespace {
+		t.Errorf("Namespace = %q, want %q", got.Namespace, domain.DefaultNamespace)
+	}
+	flows, err := repo.ListByNamespace(ctx, db, "team-a")
+	if err != nil {
+		t.Fatalf("ListByNamespace: %v", err)
+	}
+	if len(flows) != 3 || flows[0].TaskID != "a1" {
+		t.Fatalf("flows = %+v, want a1, a1-child, a2", flows)
+	}
+
+	// a1's cap covers its child; a2 is done and holds only its spend.
+	allocated, err := repo.AllocatedBudget(ctx, db, "team-a")
+	if err != nil {
+		t.Fatalf("AllocatedBudget: %v", err)
+	}
+	if allocated != 13 {
+		t.Errorf("allocated = %.2f, want 13", allocated)
+	}
+
+	summaries, err := repo.SummarizeNamespaces(ctx, db)
+	if err != nil {
+		t.Fatalf("SummarizeNamespaces: %v", err)
+	}
+	want := []domain.NamespaceSummary{
+		{Namespace: "default", Flows: 1, Active: 1, AllocatedUSD: 7},
+		{Namespace: "team-a", Flows: 3, Active: 2, AllocatedUSD: 13, UsedUSD: 6},
+	}
+	if len(summaries) != len(want) || summaries[0] != want[0] || summaries[1] != want[1] {
+		t.Errorf("summaries = %+v, want %+v", summaries, want)
+	}
+}
+
+func BenchmarkTaskRepo_GetByID(b *testing.B) {
+	for _, size := range []int{0, 1024} {
+		b.Run(fmt.Sprintf("cache=%d", size), func(b *testing.B) {
+			db, err := NewDB(filepath.Join(b.TempDir(), "bench.db"))
+			if err != nil {
+				b.Fatalf("NewDB: %v", err)
+			}
+			defer db.Close()
+			if size > 0 {
+				defer CacheTasks(db, size).Close()
+			}
+
+			ctx := context.Background()
+			repo := &TaskRepo{}
+			tx, _ := db.Begin()
+			if err := repo.CreateTx(ctx, tx, domain.FlowState{TaskID: "task-1", CurrentPhase: domain.PhaseA, Status: domain.StatusRunning, StateVersion: 1, BudgetCapUSD: 10}); err != nil {
+				b.Fatalf("CreateTx: %v", err)
+			}
+			tx.Commit()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := repo.GetByID(ctx, db, "task-1"); err != nil {
+						b.Errorf("GetByID: %v", err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestTaskRepo_WorkspaceHolder(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &TaskRepo{}
+
+	tx, _ := db.Begin()
+	for _, s := range []domain.FlowState{
+		{TaskID: "done", Status: domain.StatusDone, StateVersion: 1, Workspace: "/ws/a"},
+		{TaskID: "queued", Status: domain.StatusQueued, StateVersion: 1, Workspace: "/ws/a"},
+		{TaskID: "paused", Status: domain.StatusPaused, StateVersion: 1, Workspace: "/ws/a"},
+		{TaskID: "shared", Status: domain.StatusBlocked, StateVersion: 1},
+	} {
+		s.CurrentPhase = domain.PhaseA
+		if err := repo.CreateTx(ctx, tx, s); err != nil {
+			t.Fatalf("CreateTx %s: %v", s.TaskID, err)
+		}
+	}
+	tx.Commit()
+
+	for _, tc := range []struct {
+		taskID, workspace string
+		orUnset           bool
+		want              string
+	}{
+		{"new", "/ws/a", false, "paused"},
+		{"paused", "/ws/a", false, ""},
+		{"new", "/ws/b", false, ""},
+		{"new", "/ws/b", true, "shared"},
+		{"shared", "/ws/b", true, ""},
+	} {
+		got, err := repo.WorkspaceHolder(ctx, db, tc.taskID, tc.workspace, tc.orUnset)
+		if err != nil || got != tc.want {
+			t.Errorf("WorkspaceHolder(%s, %s, %v) = %q, %v; want %q", tc.taskID, tc.workspace, tc.orUnset, got, err, tc.want)
+		}
+	}
+}
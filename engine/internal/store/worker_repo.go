@@ -12,15 +12,57 @@ import (
 // WorkerRepo handles persistence for WorkerRef records.
 type WorkerRepo struct{}
 
+// workerColumns is the column list shared by every worker SELECT.
+const workerColumns = "worker_id, task_id, phase, role, state, file_ownership, soft_timeout_sec, hard_timeout_sec, last_heartbeat, created_at_unix, lineage_id, generation, progress_percent, progress_file, progress_note, progress_at, capabilities_json"
+
+// scanWorker reads one worker row selected with workerColumns.
+func scanWorker(row rowScanner) (*domain.WorkerRef, error) {
+	var w domain.WorkerRef
+	var phase, state, ownershipJSON, capabilitiesJSON string
+	if err := row.Scan(&w.WorkerID, &w.TaskID, &phase, &w.Role, &state, &ownershipJSON,
+		&w.SoftTimeoutSec, &w.HardTimeoutSec, &w.LastHeartbeat, &w.CreatedAtUnix,
+		&w.LineageID, &w.Generation, &w.Progress.Percent, &w.Progress.CurrentFile,
+		&w.Progress.Note, &w.Progress.UpdatedAt, &capabilitiesJSON); err != nil {
+		return nil, err
+	}
+	w.Phase = domain.Phase(phase)
+	w.State = domain.WorkerState(state)
+	if err := json.Unmarshal([]byte(ownershipJSON), &w.FileOwnership); err != nil {
+		return nil, fmt.Errorf("unmarshal file_ownership: %w", err)
+	}
+	if capabilitiesJSON != "" {
+		w.Capabilities = &domain.CapabilitySheet{}
+		if err := json.Unmarshal([]byte(capabilitiesJSON), w.Capabilities); err != nil {
+			return nil, fmt.Errorf("unmarshal capabilities: %w", err)
+		}
+	}
+	return &w, nil
+}
+
 // Create inserts a new worker record.
 func (r *WorkerRepo) Create(ctx context.Context, db *sql.DB, w domain.WorkerRef) error {
+	return r.create(ctx, db, w)
+}
+
+// CreateTx inserts a new worker record within an existing transaction.
+func (r *WorkerRepo) CreateTx(ctx context.Context, tx *sql.Tx, w domain.WorkerRef) error {
+	return r.create(ctx, tx, w)
+}
+
+func (r *WorkerRepo) create(ctx context.Context, db execer, w domain.WorkerRef) error {
 	ownership, err := json.Marshal(w.FileOwnership)
 	if err != nil {
 		return fmt.Errorf("marshal file_ownership: %w", err)
 	}
+	var capabilities []byte
+	if w.Capabilities != nil {
+		if capabilities, err = json.Marshal(w.Capabilities); err != nil {
+			return fmt.Errorf("marshal capabilities: %w", err)
+		}
+	}
 
-	const q = `INSERT INTO workers (worker_id, task_id, phase, role, state, file_ownership, soft_timeout_sec, hard_timeout_sec, last_heartbeat, created_at_unix)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	const q = `INSERT INTO workers (` + workerColumns + `)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	_, err = db.ExecContext(ctx, q,
 		w.WorkerID,
 		w.TaskID,
@@ -32,6 +74,13 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 		w.HardTimeoutSec,
 		w.LastHeartbeat,
 		w.CreatedAtUnix,
+		w.LineageID,
+		w.Generation,
+		w.Progress.Percent,
+		w.Progress.CurrentFile,
+		w.Progress.Note,
+		w.Progress.UpdatedAt,
+		string(capabilities),
 	)
 	if err != nil {
 		return fmt.Errorf("create worker: %w", err)
@@ -58,33 +107,22 @@ func (r *WorkerRepo) UpdateState(ctx context.Context, db *sql.DB, workerID strin
 
 // GetByID retrieves a worker by its ID.
 func (r *WorkerRepo) GetByID(ctx context.Context, db *sql.DB, workerID string) (*domain.WorkerRef, error) {
-	const q = `SELECT worker_id, task_id, phase, role, state, file_ownership, soft_timeout_sec, hard_timeout_sec, last_heartbeat, created_at_unix
+	const q = `SELECT ` + workerColumns + `
 FROM workers WHERE worker_id = ?`
 
-	row := db.QueryRowContext(ctx, q, workerID)
-
-	var w domain.WorkerRef
-	var phase, state, ownershipJSON string
-	err := row.Scan(&w.WorkerID, &w.TaskID, &phase, &w.Role, &state, &ownershipJSON,
-		&w.SoftTimeoutSec, &w.HardTimeoutSec, &w.LastHeartbeat, &w.CreatedAtUnix)
+	w, err := scanWorker(db.QueryRowContext(ctx, q, workerID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, domain.ErrWorkerNotFound
 		}
 		return nil, fmt.Errorf("get worker: %w", err)
 	}
-	w.Phase = domain.Phase(phase)
-	w.State = domain.WorkerState(state)
-
-	if err := json.Unmarshal([]byte(ownershipJSON), &w.FileOwnership); err != nil {
-		return nil, fmt.Errorf("unmarshal file_ownership: %w", err)
-	}
-	return &w, nil
+	return w, nil
 }
 
 // ListActive returns workers for a task that are in created or running state.
 func (r *WorkerRepo) ListActive(ctx context.Context, db *sql.DB, taskID string) ([]*domain.WorkerRef, error) {
-	const q = `SELECT worker_id, task_id, phase, role, state, file_ownership, soft_timeout_sec, hard_timeout_sec, last_heartbeat, created_at_unix
+	const q = `SELECT ` + workerColumns + `
 FROM workers WHERE task_id = ? AND state IN ('created', 'running')
 ORDER BY created_at_unix ASC`
 
@@ -96,25 +134,18 @@ ORDER BY created_at_unix ASC`
 
 	var workers []*domain.WorkerRef
 	for rows.Next() {
-		var w domain.WorkerRef
-		var phase, state, ownershipJSON string
-		if err := rows.Scan(&w.WorkerID, &w.TaskID, &phase, &w.Role, &state, &ownershipJSON,
-			&w.SoftTimeoutSec, &w.HardTimeoutSec, &w.LastHeartbeat, &w.CreatedAtUnix); err != nil {
+		w, err := scanWorker(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan worker: %w", err)
 		}
-		w.Phase = domain.Phase(phase)
-		w.State = domain.WorkerState(state)
-		if err := json.Unmarshal([]byte(ownershipJSON), &w.FileOwnership); err != nil {
-			return nil, fmt.Errorf("unmarshal file_ownership: %w", err)
-		}
-		workers = append(workers, &w)
+		workers = append(workers, w)
 	}
 	return workers, rows.Err()
 }
 
 // ListByTask returns all workers for a task regardless of state, ordered by creation time.
 func (r *WorkerRepo) ListByTask(ctx context.Context, db *sql.DB, taskID string) ([]*domain.WorkerRef, error) {
-	const q = `SELECT worker_id, task_id, phase, role, state, file_ownership, soft_timeout_sec, hard_timeout_sec, last_heartbeat, created_at_unix
+	const q = `SELECT ` + workerColumns + `
 FROM workers WHERE task_id = ?
 ORDER BY created_at_unix ASC`
 
@@ -126,18 +157,11 @@ ORDER BY created_at_unix ASC`
 
 	var workers []*domain.WorkerRef
 	for rows.Next() {
-		var w domain.WorkerRef
-		var phase, state, ownershipJSON string
-		if err := rows.Scan(&w.WorkerID, &w.TaskID, &phase, &w.Role, &state, &ownershipJSON,
-			&w.SoftTimeoutSec, &w.HardTimeoutSec, &w.LastHeartbeat, &w.CreatedAtUnix); err != nil {
+		w, err := scanWorker(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan worker: %w", err)
 		}
-		w.Phase = domain.Phase(phase)
-		w.State = domain.WorkerState(state)
-		if err := json.Unmarshal([]byte(ownershipJSON), &w.FileOwnership); err != nil {
-			return nil, fmt.Errorf("unmarshal file_ownership: %w", err)
-		}
-		workers = append(workers, &w)
+		workers = append(workers, w)
 	}
 	return workers, rows.Err()
 }
@@ -169,3 +193,43 @@ func (r *WorkerRepo) CountActive(ctx context.Context, db *sql.DB, taskID string)
 	}
 	return count, nil
 }
+
+// UpdateProgress records a worker's latest progress report. A report also
+// counts as a heartbeat.
+func (r *WorkerRepo) UpdateProgress(ctx context.Context, db *sql.DB, workerID string, p domain.WorkerProgress) error {
+	const q = `UPDATE workers SET progress_percent = ?, progress_file = ?, progress_note = ?, progress_at = ?, last_heartbeat = ?
+WHERE worker_id = ?`
+	res, err := db.ExecContext(ctx, q, p.Percent, p.CurrentFile, p.Note, p.UpdatedAt, p.UpdatedAt, workerID)
+	if err != nil {
+		return fmt.Errorf("update progress: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check rows affected: %w", err)
+	}
+	if n == 0 {
+		return domain.ErrWorkerNotFound
+	}
+	return nil
+}
+
+// CountActiveByRole returns the number of active (created or running) workers
+// with the given role for a task.
+func (r *WorkerRepo) CountActiveByRole(ctx context.Context, db *sql.DB, taskID, role string) (int, error) {
+	const q = `SELECT COUNT(*) FROM workers WHERE task_id = ? AND role = ? AND state IN ('created', 'running')`
+	var count int
+	if err := db.QueryRowContext(ctx, q, taskID, role).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count active workers by role: %w", err)
+	}
+	return count, nil
+}
+
+// CountAllActive returns the number of active (created or running) workers across all tasks.
+func (r *WorkerRepo) CountAllActive(ctx context.Context, db *sql.DB) (int, error) {
+	const q = `SELECT COUNT(*) FROM workers WHERE state IN ('created', 'running')`
+	var count int
+	if err := db.QueryRowContext(ctx, q).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count all active workers: %w", err)
+	}
+	return count, nil
+}
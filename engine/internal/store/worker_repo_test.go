@@ -197,3 +197,79 @@ func TestWorkerRepo_GetByID_NotFound(t *testing.T) {
 		t.Errorf("expected ErrWorkerNotFound, got %v", err)
 	}
 }
+
+func TestWorkerRepo_UpdateProgress(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &WorkerRepo{}
+	if err := repo.Create(ctx, db, domain.WorkerRef{
+		WorkerID: "w-1", TaskID: "task-1", Phase: domain.PhaseC, Role: "coder",
+		State: domain.WorkerRunning, LastHeartbeat: 100, CreatedAtUnix: 100,
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	p := domain.WorkerProgress{Percent: 40, CurrentFile: "main.go", Note: "parsing", UpdatedAt: 200}
+	if err := repo.UpdateProgress(ctx, db, "w-1", p); err != nil {
+		t.Fatalf("UpdateProgress: %v", err)
+	}
+	got, err := repo.GetByID(ctx, db, "w-1")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Progress != p {
+		t.Errorf("Progress = %+v, want %+v", got.Progress, p)
+	}
+	if got.LastHeartbeat != 200 {
+		t.Errorf("LastHeartbeat = %d, want 200", got.LastHeartbeat)
+	}
+
+	if err := repo.UpdateProgress(ctx, db, "nonexistent", p); err != domain.ErrWorkerNotFound {
+		t.Errorf("expected ErrWorkerNotFound, got %v", err)
+	}
+}
+
+func TestWorkerRepo_Capabilities(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := &WorkerRepo{}
+	sheet := &domain.CapabilitySheet{
+		TaskID:          "task-1",
+		AllowedPaths:    []string{"./"},
+		AllowedCommands: []string{"read"},
+		CreatedAtUnix:   1,
+	}
+	if err := repo.Create(ctx, db, domain.WorkerRef{WorkerID: "w-1", TaskID: "task-1", State: domain.WorkerCreated, Capabilities: sheet}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := repo.Create(ctx, db, domain.WorkerRef{WorkerID: "w-2", TaskID: "task-1", State: domain.WorkerCreated}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, db, "w-1")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Capabilities == nil || len(got.Capabilities.AllowedCommands) != 1 || got.Capabilities.AllowedCommands[0] != "read" {
+		t.Errorf("Capabilities = %+v, want the reviewer sheet", got.Capabilities)
+	}
+	got, err = repo.GetByID(ctx, db, "w-2")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Capabilities != nil {
+		t.Errorf("Capabilities = %+v, want nil", got.Capabilities)
+	}
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+)
+
+// DBOptions tune a database opened by NewDBWith.
+type DBOptions struct {
+	// BeforeWrite, if set, runs before every statement executed (rather than
+	// queried) on the database, migrations included, and may block, such as
+	// to inject write latency for chaos testing.
+	BeforeWrite func(ctx context.Context)
+}
+
+// writeHookConnector opens SQLite connections that call before ahead of
+// every Exec.
+type writeHookConnector struct {
+	dsn    string
+	drv    driver.Driver
+	before func(ctx context.Context)
+}
+
+func (c writeHookConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.drv.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	sc, ok := conn.(sqliteConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("open database: unexpected driver connection %T", conn)
+	}
+	return &writeHookConn{sqliteConn: sc, before: c.before}, nil
+}
+
+func (c writeHookConnector) Driver() driver.Driver { return c.drv }
+
+// sqliteConn is what database/sql, Backup, and Restore use of a modernc
+// driver connection, all of which writeHookConn passes through.
+type sqliteConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.SessionResetter
+	driver.Validator
+	driver.Pinger
+	backupConn
+}
+
+// writeHookConn is a SQLite connection whose Exec calls before first.
+type writeHookConn struct {
+	sqliteConn
+	before func(ctx context.Context)
+}
+
+func (c *writeHookConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.before(ctx)
+	return c.sqliteConn.ExecContext(ctx, query, args)
+}
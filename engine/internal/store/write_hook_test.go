@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestNewDBWith_BeforeWrite(t *testing.T) {
+	var writes atomic.Int64
+	path := filepath.Join(t.TempDir(), "hooked.db")
+	db, err := NewDBWith(path, DBOptions{BeforeWrite: func(context.Context) { writes.Add(1) }})
+	if err != nil {
+		t.Fatalf("NewDBWith: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	audits := &AuditRepo{}
+	before := writes.Load()
+	if before == 0 {
+		t.Error("migrations ran without the hook")
+	}
+	if err := audits.Record(ctx, db, domain.AuditRecord{ID: "a1", TaskID: "t1", Category: "c", Action: "a", CreatedAt: 1}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if writes.Load() == before {
+		t.Error("Record ran without the hook")
+	}
+	before = writes.Load()
+	if _, err := audits.ListByTask(ctx, db, "t1"); err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if writes.Load() != before {
+		t.Error("a query ran the write hook")
+	}
+
+	// Backups reach the driver connection through the hook.
+	if err := Backup(ctx, db, filepath.Join(t.TempDir(), "snap.db")); err != nil {
+		t.Errorf("Backup: %v", err)
+	}
+}
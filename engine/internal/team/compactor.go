@@ -0,0 +1,174 @@
+package team
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// phaseExitRequirements lists what each phase must produce before the flow
+// can move on; they fill CompactionSlots.NextPhaseReqs.
+var phaseExitRequirements = map[domain.Phase][]string{
+	domain.PhaseA: {"goal and acceptance criteria agreed with the user"},
+	domain.PhaseB: {"exploration findings submitted as artifacts"},
+	domain.PhaseC: {"design document submitted as an artifact"},
+	domain.PhaseD: {"scorecards from every reviewer", "no unresolved blocking issues"},
+	domain.PhaseE: {"implementation complete", "no pending intents"},
+	domain.PhaseF: {"acceptance verdicts from every reviewer"},
+}
+
+// Compactor assembles the CompactionSlots of a flow from the database and
+// persists them as a compaction snapshot when the flow enters a phase, so
+// workers spawned in that phase start from the compacted context.
+type Compactor struct {
+	DB             *sql.DB
+	SnapshotRepo   *store.SnapshotRepo
+	WorkerRepo     *store.WorkerRepo
+	IntentRepo     *store.IntentRepo
+	ArtifactRepo   *store.ArtifactRepo
+	ConstraintRepo *store.ConstraintRepo
+	RiskRepo       *store.RiskRepo
+}
+
+// NewCompactor creates a Compactor with default repos.
+func NewCompactor(db *sql.DB) *Compactor {
+	return &Compactor{
+		DB:             db,
+		SnapshotRepo:   &store.SnapshotRepo{},
+		WorkerRepo:     &store.WorkerRepo{},
+		IntentRepo:     &store.IntentRepo{},
+		ArtifactRepo:   &store.ArtifactRepo{},
+		ConstraintRepo: &store.ConstraintRepo{},
+		RiskRepo:       &store.RiskRepo{},
+	}
+}
+
+// OnTransition compacts a flow that has entered a new phase. It has the
+// signature of a workflow transition listener and ignores status changes
+// within a phase; failures are dropped, as workers can start without a
+// compacted context.
+func (c *Compactor) OnTransition(ctx context.Context, state domain.FlowState, from domain.Phase) {
+	if state.CurrentPhase == from {
+		return
+	}
+	_, _ = c.Compact(ctx, state)
+}
+
+// Compact assembles the flow's slots and saves them as a compaction
+// snapshot of its current phase.
+func (c *Compactor) Compact(ctx context.Context, state domain.FlowState) (*domain.CompactionSlots, error) {
+	slots, err := c.Assemble(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(slots)
+	if err != nil {
+		return nil, fmt.Errorf("marshal slots: %w", err)
+	}
+	sum := sha256.Sum256(data)
+
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := c.SnapshotRepo.SaveTx(ctx, tx, domain.PhaseSnapshot{
+		TaskID:       state.TaskID,
+		Phase:        state.CurrentPhase,
+		Round:        state.Round,
+		SnapshotJSON: string(data),
+		Checksum:     hex.EncodeToString(sum[:]),
+		CreatedAt:    time.Now().Unix(),
+		Kind:         domain.SnapshotCompaction,
+	}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return &slots, nil
+}
+
+// Assemble fills the compaction slots of a flow from its metadata, active
+// constraints, open risks, active workers, artifacts, and pending intents.
+// Constraints and risks belong to the task rather than a phase, so
+// unresolved ones carry into every later phase. It has the
+// signature of CompactionGate.SlotsFn.
+func (c *Compactor) Assemble(ctx context.Context, state domain.FlowState) (domain.CompactionSlots, error) {
+	constraints, err := c.ConstraintRepo.ListByTask(ctx, c.DB, state.TaskID, "active")
+	if err != nil {
+		return domain.CompactionSlots{}, fmt.Errorf("list constraints: %w", err)
+	}
+	risks, err := c.RiskRepo.ListByTask(ctx, c.DB, state.TaskID, "open")
+	if err != nil {
+		return domain.CompactionSlots{}, fmt.Errorf("list risks: %w", err)
+	}
+	workers, err := c.WorkerRepo.ListActive(ctx, c.DB, state.TaskID)
+	if err != nil {
+		return domain.CompactionSlots{}, fmt.Errorf("list active workers: %w", err)
+	}
+	artifacts, err := c.ArtifactRepo.ListLatest(ctx, c.DB, state.TaskID)
+	if err != nil {
+		return domain.CompactionSlots{}, fmt.Errorf("list artifacts: %w", err)
+	}
+	intents, err := c.IntentRepo.ListByTaskStatus(ctx, c.DB, state.TaskID, "pending")
+	if err != nil {
+		return domain.CompactionSlots{}, fmt.Errorf("list pending intents: %w", err)
+	}
+
+	slots := domain.CompactionSlots{
+		TaskSpec:           strings.TrimSpace(state.Title + "\n\n" + state.Description),
+		AcceptanceCriteria: state.AcceptanceCriteria,
+		CurrentPhase:       string(state.CurrentPhase),
+		ArtifactRefs:       artifacts,
+		NextPhaseReqs:      phaseExitRequirements[state.CurrentPhase],
+	}
+	for _, con := range constraints {
+		slots.ActiveConstraints = append(slots.ActiveConstraints, con.Text)
+	}
+	for _, k := range risks {
+		slots.OpenRisks = append(slots.OpenRisks, fmt.Sprintf("[%s] %s", k.Severity, k.Text))
+	}
+	owned := make(map[string]bool)
+	for _, w := range workers {
+		for _, p := range w.FileOwnership {
+			if !owned[p] {
+				owned[p] = true
+				slots.FileOwnership = append(slots.FileOwnership, p)
+			}
+		}
+	}
+	sort.Strings(slots.FileOwnership)
+	for _, intent := range intents {
+		slots.PendingIntents = append(slots.PendingIntents, fmt.Sprintf("%s %s %s", intent.IntentID, intent.Operation, intent.TargetFile))
+	}
+	return slots, nil
+}
+
+// Latest returns the slots compacted when the flow last entered phase, or
+// nil if it never was.
+func (c *Compactor) Latest(ctx context.Context, taskID string, phase domain.Phase) (*domain.CompactionSlots, error) {
+	return latestCompaction(ctx, c.DB, c.SnapshotRepo, taskID, phase)
+}
+
+func latestCompaction(ctx context.Context, db *sql.DB, repo *store.SnapshotRepo, taskID string, phase domain.Phase) (*domain.CompactionSlots, error) {
+	snap, err := repo.GetLatestKind(ctx, db, taskID, phase, domain.SnapshotCompaction)
+	if err != nil || snap == nil {
+		return nil, err
+	}
+	var slots domain.CompactionSlots
+	if err := json.Unmarshal([]byte(snap.SnapshotJSON), &slots); err != nil {
+		return nil, fmt.Errorf("decode compaction snapshot %d: %w", snap.ID, err)
+	}
+	return &slots, nil
+}
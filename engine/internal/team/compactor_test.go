@@ -0,0 +1,121 @@
+package team
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+func TestCompactor_CompactOnPhaseEntry(t *testing.T) {
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	state := domain.FlowState{
+		TaskID:             "task-1",
+		CurrentPhase:       domain.PhaseE,
+		Status:             domain.StatusRunning,
+		StateVersion:       1,
+		UpdatedAtUnix:      time.Now().Unix(),
+		Title:              "rate limiter",
+		Description:        "Token bucket per client.",
+		AcceptanceCriteria: "429 after the burst",
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	if err := (&store.TaskRepo{}).CreateTx(ctx, tx, state); err != nil {
+		t.Fatalf("CreateTx: %v", err)
+	}
+	if err := (&store.IntentRepo{}).UpsertTx(ctx, tx, domain.Intent{
+		IntentID: "int-1", TaskID: "task-1", TargetFile: "limiter.go", Operation: "write", Status: "pending",
+	}); err != nil {
+		t.Fatalf("UpsertTx: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if err := (&store.ConstraintRepo{}).Create(ctx, db, domain.Constraint{
+		ConstraintID: "con-1", TaskID: "task-1", Text: "no new dependencies", CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("Create constraint: %v", err)
+	}
+	if err := (&store.ConstraintRepo{}).Create(ctx, db, domain.Constraint{
+		ConstraintID: "con-2", TaskID: "task-1", Text: "lifted", Status: "resolved", CreatedAt: 2,
+	}); err != nil {
+		t.Fatalf("Create constraint: %v", err)
+	}
+	risks := &store.RiskRepo{}
+	for _, k := range []domain.Risk{
+		{RiskID: "risk-1", TaskID: "task-1", Phase: domain.PhaseB, Severity: "P1", Text: "clock skew", CreatedAt: 1},
+		{RiskID: "risk-2", TaskID: "task-1", Phase: domain.PhaseC, Severity: "P0", Text: "done", Status: "resolved", CreatedAt: 2},
+	} {
+		if err := risks.Create(ctx, db, k); err != nil {
+			t.Fatalf("Create risk: %v", err)
+		}
+	}
+	if _, err := (&store.ArtifactRepo{}).Create(ctx, db, domain.ArtifactRef{
+		ID: "art-1", TaskID: "task-1", Type: "design", Path: "design.md", Hash: "h1",
+	}); err != nil {
+		t.Fatalf("Create artifact: %v", err)
+	}
+	mgr := NewWorkerManager(db, 10)
+	for _, own := range [][]string{{"b/", "a/"}, {"a/"}} {
+		if _, err := mgr.Spawn(ctx, domain.WorkerSpec{TaskID: "task-1", Phase: domain.PhaseE, Role: "coder", FileOwnership: own}); err != nil {
+			t.Fatalf("Spawn: %v", err)
+		}
+	}
+
+	c := NewCompactor(db)
+	// A status change within a phase is not a phase boundary.
+	c.OnTransition(ctx, state, domain.PhaseE)
+	if slots, err := c.Latest(ctx, "task-1", domain.PhaseE); err != nil || slots != nil {
+		t.Fatalf("Latest after same-phase notify = %+v, %v; want nil", slots, err)
+	}
+
+	c.OnTransition(ctx, state, domain.PhaseD)
+	slots, err := c.Latest(ctx, "task-1", domain.PhaseE)
+	if err != nil || slots == nil {
+		t.Fatalf("Latest = %+v, %v", slots, err)
+	}
+	if slots.TaskSpec != "rate limiter\n\nToken bucket per client." || slots.AcceptanceCriteria != "429 after the burst" {
+		t.Errorf("spec = %q, criteria = %q", slots.TaskSpec, slots.AcceptanceCriteria)
+	}
+	if len(slots.ActiveConstraints) != 1 || len(slots.ArtifactRefs) != 1 || len(slots.PendingIntents) != 1 {
+		t.Errorf("slots = %+v", slots)
+	}
+	if len(slots.OpenRisks) != 1 || slots.OpenRisks[0] != "[P1] clock skew" {
+		t.Errorf("open risks = %v, want [[P1] clock skew]", slots.OpenRisks)
+	}
+	if len(slots.FileOwnership) != 2 || slots.FileOwnership[0] != "a/" {
+		t.Errorf("FileOwnership = %v, want [a/ b/]", slots.FileOwnership)
+	}
+	if len(slots.NextPhaseReqs) == 0 {
+		t.Error("expected next-phase requirements for phase E")
+	}
+	if err := (&CompactionValidator{}).Validate(ctx, *slots); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	// Compaction snapshots must not be mistaken for the transition snapshot
+	// rollback restores.
+	if snap, err := c.SnapshotRepo.GetLatest(ctx, db, "task-1", domain.PhaseE); err != nil || snap != nil {
+		t.Errorf("transition snapshot = %+v, %v; want nil", snap, err)
+	}
+
+	digest, err := NewDigestBuilder(db).Build(ctx, "task-1", domain.PhaseE, domain.WorkerSpec{TaskID: "task-1", Role: "coder"})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if digest.Compacted == nil || digest.Compacted.TaskSpec != slots.TaskSpec {
+		t.Errorf("digest.Compacted = %+v", digest.Compacted)
+	}
+}
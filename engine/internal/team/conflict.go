@@ -3,6 +3,9 @@ package team
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/anthropics/three-body-engine/internal/domain"
 	"github.com/anthropics/three-body-engine/internal/store"
@@ -18,6 +21,8 @@ const (
 )
 
 // FileConflict describes a conflict between two intents on the same file.
+// For conflicts found by DetectAcrossTasks, File is the absolute path and the
+// intents belong to different tasks.
 type FileConflict struct {
 	File    string
 	IntentA domain.Intent
@@ -25,10 +30,47 @@ type FileConflict struct {
 	Type    ConflictType
 }
 
+// Conflict resolution strategies accepted by Resolve.
+const (
+	// StrategyFail leaves both intents alone and reports ErrIntentConflict.
+	StrategyFail = "fail"
+	// StrategyPhasePriority keeps the intent of the worker in the later
+	// phase and cancels the other; workers in the same phase fall back to
+	// StrategyFirstAcquired.
+	StrategyPhasePriority = "phase-priority"
+	// StrategyFirstAcquired keeps the intent created first and requeues the
+	// other behind it.
+	StrategyFirstAcquired = "first-acquired"
+	// StrategyEscalate records a pending "intent_conflict" decision for a
+	// human and reports ErrConflictEscalated; Decide settles it.
+	StrategyEscalate = "escalate"
+)
+
 // ConflictDetector finds and classifies conflicts between active intents.
 type ConflictDetector struct {
-	IntentRepo *store.IntentRepo
-	DB         *sql.DB
+	IntentRepo   *store.IntentRepo
+	DB           *sql.DB
+	WorkerRepo   *store.WorkerRepo
+	AuditRepo    *store.AuditRepo
+	DecisionRepo *store.DecisionRepo
+	// Shared, if set, lets DetectAcrossTasks compare intents of flows that
+	// share a workspace.
+	Shared *SharedWorkspaces
+	// Strategy returns the strategy Resolve applies to a task's conflicts.
+	// A nil func or an empty result means StrategyFail.
+	Strategy func(taskID string) string
+}
+
+// NewConflictDetector creates a ConflictDetector with default repos and the
+// fail strategy.
+func NewConflictDetector(db *sql.DB) *ConflictDetector {
+	return &ConflictDetector{
+		IntentRepo:   &store.IntentRepo{},
+		DB:           db,
+		WorkerRepo:   &store.WorkerRepo{},
+		AuditRepo:    &store.AuditRepo{},
+		DecisionRepo: &store.DecisionRepo{},
+	}
 }
 
 // Detect scans all pending and running intents for a task and returns any file conflicts.
@@ -65,32 +107,278 @@ func (d *ConflictDetector) Detect(ctx context.Context, taskID string) ([]FileCon
 	return conflicts, nil
 }
 
+// DetectAcrossTasks returns conflicts between active intents of different
+// tasks on the same absolute path. It finds nothing unless Shared is set.
+func (d *ConflictDetector) DetectAcrossTasks(ctx context.Context) ([]FileConflict, error) {
+	if d.Shared == nil {
+		return nil, nil
+	}
+	paths, err := d.Shared.byPath(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []FileConflict
+	for p, intents := range paths {
+		for i := 0; i < len(intents); i++ {
+			for j := i + 1; j < len(intents); j++ {
+				if intents[i].TaskID != intents[j].TaskID {
+					conflicts = append(conflicts, FileConflict{
+						File:    p,
+						IntentA: intents[i],
+						IntentB: intents[j],
+						Type:    conflictType(intents[i], intents[j]),
+					})
+				}
+			}
+		}
+	}
+	return conflicts, nil
+}
+
 // DetectBetween checks two intents for a conflict.
 // Returns nil if the intents target different files.
 func (d *ConflictDetector) DetectBetween(a, b domain.Intent) *FileConflict {
 	if a.TargetFile != b.TargetFile {
 		return nil
 	}
+	return &FileConflict{
+		File:    a.TargetFile,
+		IntentA: a,
+		IntentB: b,
+		Type:    conflictType(a, b),
+	}
+}
 
-	var ctype ConflictType
+// conflictType classifies two intents on the same file by their operations.
+func conflictType(a, b domain.Intent) ConflictType {
 	switch {
 	case a.Operation == "delete" || b.Operation == "delete":
-		ctype = ConflictDelete
+		return ConflictDelete
 	case a.Operation == "create" && b.Operation == "create":
-		ctype = ConflictCreate
+		return ConflictCreate
 	default:
-		ctype = ConflictOverlap
+		return ConflictOverlap
 	}
+}
 
-	return &FileConflict{
-		File:    a.TargetFile,
-		IntentA: a,
-		IntentB: b,
-		Type:    ctype,
+// Resolve settles a file conflict with the strategy configured for the
+// task (see the Strategy constants). It returns nil once one intent holds the
+// file, ErrIntentConflict under StrategyFail, and ErrConflictEscalated when a
+// human decision was requested.
+func (d *ConflictDetector) Resolve(ctx context.Context, conflict FileConflict) error {
+	strategy := StrategyFail
+	if d.Strategy != nil {
+		if s := d.Strategy(conflict.IntentA.TaskID); s != "" {
+			strategy = s
+		}
+	}
+
+	switch strategy {
+	case StrategyFail:
+		return domain.ErrIntentConflict
+	case StrategyPhasePriority:
+		a, err := d.WorkerRepo.GetByID(ctx, d.DB, conflict.IntentA.WorkerID)
+		if err != nil {
+			return fmt.Errorf("get worker: %w", err)
+		}
+		b, err := d.WorkerRepo.GetByID(ctx, d.DB, conflict.IntentB.WorkerID)
+		if err != nil {
+			return fmt.Errorf("get worker: %w", err)
+		}
+		// Phases are single letters in pipeline order.
+		switch {
+		case a.Phase > b.Phase:
+			return d.settle(ctx, strategy, conflict.IntentA, conflict.IntentB, false)
+		case b.Phase > a.Phase:
+			return d.settle(ctx, strategy, conflict.IntentB, conflict.IntentA, false)
+		}
+		keep, other := firstAcquired(conflict.IntentA, conflict.IntentB)
+		return d.settle(ctx, strategy, keep, other, true)
+	case StrategyFirstAcquired:
+		keep, other := firstAcquired(conflict.IntentA, conflict.IntentB)
+		return d.settle(ctx, strategy, keep, other, true)
+	case StrategyEscalate:
+		return d.escalate(ctx, conflict)
+	default:
+		return fmt.Errorf("unknown conflict strategy %q", strategy)
 	}
 }
 
-// Resolve attempts to resolve a file conflict. In MVP this always returns an error.
-func (d *ConflictDetector) Resolve(ctx context.Context, conflict FileConflict) error {
-	return domain.ErrIntentConflict
+// firstAcquired orders two intents by creation time, then by ID.
+func firstAcquired(a, b domain.Intent) (first, second domain.Intent) {
+	if b.CreatedAt < a.CreatedAt || b.CreatedAt == a.CreatedAt && b.IntentID < a.IntentID {
+		return b, a
+	}
+	return a, b
+}
+
+// settle keeps one intent and either requeues the other behind it, to be
+// granted by the IntentResolver queue once the file is free, or cancels it.
+func (d *ConflictDetector) settle(ctx context.Context, strategy string, keep, other domain.Intent, requeue bool) error {
+	action := "cancelled"
+	if requeue {
+		action = "requeued"
+		if other.LeaseSec == 0 {
+			other.LeaseSec = other.LeaseUntil - other.CreatedAt
+		}
+		other.Status = "queued"
+		other.QueuedAt = time.Now().UnixNano()
+	} else {
+		other.Status = "cancelled"
+	}
+
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := d.IntentRepo.UpsertTx(ctx, tx, other); err != nil {
+		return fmt.Errorf("upsert intent: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	d.audit(ctx, keep.TaskID, "conflict_resolved", map[string]string{
+		"strategy": strategy,
+		"file":     keep.TargetFile,
+		"kept":     keep.IntentID,
+		action:     other.IntentID,
+	})
+	return nil
+}
+
+// intentConflictSubject is the SubjectJSON of an "intent_conflict" decision.
+type intentConflictSubject struct {
+	File    string       `json:"file"`
+	Type    ConflictType `json:"type"`
+	Intents []string     `json:"intents"`
+}
+
+// escalate records a pending decision asking a human which intent to keep,
+// unless one is already pending for the same pair of intents.
+func (d *ConflictDetector) escalate(ctx context.Context, conflict FileConflict) error {
+	pending, err := d.DecisionRepo.ListByTask(ctx, d.DB, conflict.IntentA.TaskID, "pending")
+	if err != nil {
+		return err
+	}
+	for _, p := range pending {
+		var s intentConflictSubject
+		if p.Kind != "intent_conflict" || json.Unmarshal([]byte(p.SubjectJSON), &s) != nil || len(s.Intents) != 2 {
+			continue
+		}
+		a, b := conflict.IntentA.IntentID, conflict.IntentB.IntentID
+		if s.Intents[0] == a && s.Intents[1] == b || s.Intents[0] == b && s.Intents[1] == a {
+			return domain.ErrConflictEscalated
+		}
+	}
+
+	subject, _ := json.Marshal(intentConflictSubject{
+		File:    conflict.File,
+		Type:    conflict.Type,
+		Intents: []string{conflict.IntentA.IntentID, conflict.IntentB.IntentID},
+	})
+	now := time.Now()
+	decision := domain.Decision{
+		DecisionID:  fmt.Sprintf("dec-%d", now.UnixNano()),
+		TaskID:      conflict.IntentA.TaskID,
+		Kind:        "intent_conflict",
+		SubjectJSON: string(subject),
+		Status:      "pending",
+		CreatedAt:   now.Unix(),
+	}
+	if err := d.DecisionRepo.Create(ctx, d.DB, decision); err != nil {
+		return err
+	}
+	d.audit(ctx, decision.TaskID, "conflict_escalated", map[string]string{
+		"decision": decision.DecisionID,
+		"file":     conflict.File,
+	})
+	return domain.ErrConflictEscalated
+}
+
+// Decide resolves a pending "intent_conflict" decision by keeping the intent
+// keepIntentID and cancelling the other.
+func (d *ConflictDetector) Decide(ctx context.Context, decisionID, keepIntentID, actor, comment string) error {
+	// Read before tx to avoid deadlock.
+	decision, err := d.DecisionRepo.GetByID(ctx, d.DB, decisionID)
+	if err != nil {
+		return err
+	}
+	if decision.Status != "pending" {
+		return domain.ErrDecisionResolved
+	}
+	var subject intentConflictSubject
+	if decision.Kind != "intent_conflict" || json.Unmarshal([]byte(decision.SubjectJSON), &subject) != nil {
+		return domain.ErrDecisionInvalid
+	}
+	var otherID string
+	switch {
+	case len(subject.Intents) != 2:
+		return domain.ErrDecisionInvalid
+	case subject.Intents[0] == keepIntentID:
+		otherID = subject.Intents[1]
+	case subject.Intents[1] == keepIntentID:
+		otherID = subject.Intents[0]
+	default:
+		return domain.ErrDecisionInvalid
+	}
+	other, err := d.IntentRepo.GetByID(ctx, d.DB, otherID)
+	if err != nil {
+		return err
+	}
+	other.Status = "cancelled"
+
+	decision.Choice = keepIntentID
+	decision.Actor = actor
+	decision.Comment = comment
+	decision.ResolvedAt = time.Now().Unix()
+
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	resolved, err := d.DecisionRepo.ResolveTx(ctx, tx, *decision)
+	if err != nil {
+		return err
+	}
+	if !resolved {
+		return domain.ErrDecisionResolved
+	}
+	if err := d.IntentRepo.UpsertTx(ctx, tx, *other); err != nil {
+		return fmt.Errorf("upsert intent: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	d.audit(ctx, decision.TaskID, "conflict_decided", map[string]string{
+		"decision":  decisionID,
+		"actor":     actor,
+		"kept":      keepIntentID,
+		"cancelled": otherID,
+	})
+	return nil
+}
+
+func (d *ConflictDetector) audit(ctx context.Context, taskID, action string, detail map[string]string) {
+	if d.AuditRepo == nil {
+		return
+	}
+	data, _ := json.Marshal(detail)
+	now := time.Now()
+	_ = d.AuditRepo.Record(ctx, d.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-%d", now.UnixNano()),
+		TaskID:       taskID,
+		Category:     "conflict",
+		Actor:        "system",
+		Action:       action,
+		DecisionJSON: string(data),
+		Severity:     "warning",
+		CreatedAt:    now.Unix(),
+	})
 }
@@ -2,6 +2,7 @@ package team
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
 	"testing"
 
@@ -204,3 +205,123 @@ func TestResolve_AlwaysErrors(t *testing.T) {
 		t.Errorf("expected ErrIntentConflict, got %v", err)
 	}
 }
+
+// conflictPair stores two overlapping intents from workers in the given
+// phases and returns a detector using strategy with the detected conflict.
+func conflictPair(t *testing.T, strategy string, phaseA, phaseB domain.Phase) (*ConflictDetector, FileConflict) {
+	t.Helper()
+	detector := NewConflictDetector(newConflictTestDB(t).DB)
+	detector.Strategy = func(string) string { return strategy }
+	ctx := context.Background()
+
+	for i, phase := range []domain.Phase{phaseA, phaseB} {
+		w := domain.WorkerRef{
+			WorkerID: fmt.Sprintf("w-%d", i+1), TaskID: "task-1", Phase: phase,
+			Role: "coder", State: domain.WorkerRunning, FileOwnership: []string{"main.go"},
+		}
+		if err := detector.WorkerRepo.Create(ctx, detector.DB, w); err != nil {
+			t.Fatalf("create worker: %v", err)
+		}
+		insertTestIntent(t, detector, domain.Intent{
+			IntentID:   fmt.Sprintf("int-%d", i+1),
+			TaskID:     "task-1",
+			WorkerID:   w.WorkerID,
+			TargetFile: "main.go",
+			Operation:  "write",
+			Status:     "pending",
+			CreatedAt:  int64(100 + i),
+			LeaseUntil: int64(160 + i),
+		})
+	}
+	conflicts, err := detector.Detect(ctx, "task-1")
+	if err != nil || len(conflicts) != 1 {
+		t.Fatalf("Detect = %d conflicts, %v", len(conflicts), err)
+	}
+	return detector, conflicts[0]
+}
+
+func intentStatus(t *testing.T, d *ConflictDetector, intentID string) string {
+	t.Helper()
+	intent, err := d.IntentRepo.GetByID(context.Background(), d.DB, intentID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	return intent.Status
+}
+
+func TestResolve_PhasePriority(t *testing.T) {
+	detector, conflict := conflictPair(t, StrategyPhasePriority, domain.PhaseC, domain.PhaseD)
+
+	if err := detector.Resolve(context.Background(), conflict); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if got := intentStatus(t, detector, "int-2"); got != "pending" {
+		t.Errorf("later-phase intent status = %q, want pending", got)
+	}
+	if got := intentStatus(t, detector, "int-1"); got != "cancelled" {
+		t.Errorf("earlier-phase intent status = %q, want cancelled", got)
+	}
+}
+
+func TestResolve_FirstAcquiredRequeues(t *testing.T) {
+	detector, conflict := conflictPair(t, StrategyFirstAcquired, domain.PhaseC, domain.PhaseC)
+	ctx := context.Background()
+
+	if err := detector.Resolve(ctx, conflict); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if got := intentStatus(t, detector, "int-1"); got != "pending" {
+		t.Errorf("first intent status = %q, want pending", got)
+	}
+	waiters, err := detector.IntentRepo.ListWaiters(ctx, detector.DB, "task-1", "main.go")
+	if err != nil {
+		t.Fatalf("ListWaiters: %v", err)
+	}
+	if len(waiters) != 1 || waiters[0].IntentID != "int-2" || waiters[0].LeaseSec != 60 {
+		t.Errorf("waiters = %+v, want int-2 with a 60s lease", waiters)
+	}
+	if conflicts, _ := detector.Detect(ctx, "task-1"); len(conflicts) != 0 {
+		t.Errorf("conflicts after resolve = %d, want 0", len(conflicts))
+	}
+}
+
+func TestResolve_EscalateAndDecide(t *testing.T) {
+	detector, conflict := conflictPair(t, StrategyEscalate, domain.PhaseC, domain.PhaseC)
+	ctx := context.Background()
+
+	if err := detector.Resolve(ctx, conflict); err != domain.ErrConflictEscalated {
+		t.Fatalf("Resolve = %v, want ErrConflictEscalated", err)
+	}
+	// A second pass must not record a duplicate decision.
+	if err := detector.Resolve(ctx, conflict); err != domain.ErrConflictEscalated {
+		t.Fatalf("second Resolve = %v, want ErrConflictEscalated", err)
+	}
+	decisions, err := detector.DecisionRepo.ListByTask(ctx, detector.DB, "task-1", "pending")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(decisions) != 1 || decisions[0].Kind != "intent_conflict" {
+		t.Fatalf("pending decisions = %+v, want one intent_conflict", decisions)
+	}
+	id := decisions[0].DecisionID
+
+	if err := detector.Decide(ctx, id, "int-9", "alice", ""); err != domain.ErrDecisionInvalid {
+		t.Errorf("Decide(unknown intent) = %v, want ErrDecisionInvalid", err)
+	}
+	if err := detector.Decide(ctx, id, "int-2", "alice", "keep the refactor"); err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if got := intentStatus(t, detector, "int-1"); got != "cancelled" {
+		t.Errorf("rejected intent status = %q, want cancelled", got)
+	}
+	d, err := detector.DecisionRepo.GetByID(ctx, detector.DB, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if d.Status != "resolved" || d.Choice != "int-2" || d.Actor != "alice" {
+		t.Errorf("decision = %+v", d)
+	}
+	if err := detector.Decide(ctx, id, "int-1", "bob", ""); err != domain.ErrDecisionResolved {
+		t.Errorf("second Decide = %v, want ErrDecisionResolved", err)
+	}
+}
@@ -4,26 +4,41 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"os"
+	"text/template"
 
 	"github.com/anthropics/three-body-engine/internal/domain"
 	"github.com/anthropics/three-body-engine/internal/store"
 )
 
-// DigestBuilder constructs lightweight context digests for workers.
+// DigestBuilder constructs lightweight context digests for workers. The
+// objective is rendered from the phase's template in Objectives and the
+// coding standards are read from StandardsFile when it is set.
 type DigestBuilder struct {
-	DB           *sql.DB
-	TaskRepo     *store.TaskRepo
-	SnapshotRepo *store.SnapshotRepo
-	IntentRepo   *store.IntentRepo
+	DB             *sql.DB
+	TaskRepo       *store.TaskRepo
+	SnapshotRepo   *store.SnapshotRepo
+	IntentRepo     *store.IntentRepo
+	ArtifactRepo   *store.ArtifactRepo
+	ConstraintRepo *store.ConstraintRepo
+	ScoreCardRepo  *store.ScoreCardRepo
+	Objectives     map[domain.Phase]*template.Template
+	StandardsFile  string
 }
 
-// NewDigestBuilder creates a DigestBuilder with default repos.
+// NewDigestBuilder creates a DigestBuilder with default repos and the
+// built-in objective templates.
 func NewDigestBuilder(db *sql.DB) *DigestBuilder {
+	objectives, _ := ParseObjectiveTemplates(nil)
 	return &DigestBuilder{
-		DB:           db,
-		TaskRepo:     &store.TaskRepo{},
-		SnapshotRepo: &store.SnapshotRepo{},
-		IntentRepo:   &store.IntentRepo{},
+		DB:             db,
+		TaskRepo:       &store.TaskRepo{},
+		SnapshotRepo:   &store.SnapshotRepo{},
+		IntentRepo:     &store.IntentRepo{},
+		ArtifactRepo:   &store.ArtifactRepo{},
+		ConstraintRepo: &store.ConstraintRepo{},
+		ScoreCardRepo:  &store.ScoreCardRepo{},
+		Objectives:     objectives,
 	}
 }
 
@@ -39,42 +54,97 @@ func (b *DigestBuilder) Build(ctx context.Context, taskID string, phase domain.P
 		return nil, fmt.Errorf("get snapshot: %w", err)
 	}
 
-	intents, err := b.IntentRepo.ListByTaskStatus(ctx, b.DB, taskID, "pending")
+	artifacts, err := b.ArtifactRepo.ListLatest(ctx, b.DB, taskID)
 	if err != nil {
-		return nil, fmt.Errorf("list pending intents: %w", err)
+		return nil, fmt.Errorf("list artifacts: %w", err)
+	}
+	if spec.ArtifactPhase != "" {
+		var inPhase []domain.ArtifactRef
+		for _, a := range artifacts {
+			if a.Phase == spec.ArtifactPhase {
+				inPhase = append(inPhase, a)
+			}
+		}
+		artifacts = inPhase
+	}
+
+	taskConstraints, err := b.ConstraintRepo.ListByTask(ctx, b.DB, taskID, "active")
+	if err != nil {
+		return nil, fmt.Errorf("list constraints: %w", err)
+	}
+
+	objective, err := b.objective(task, phase, spec.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	var standards string
+	if b.StandardsFile != "" {
+		data, err := os.ReadFile(b.StandardsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read coding standards: %w", err)
+		}
+		standards = string(data)
 	}
 
 	digest := &domain.ContextDigest{
-		TaskID:        taskID,
-		PhaseID:       string(phase),
-		Objective:     fmt.Sprintf("[%s] worker in phase %s", spec.Role, string(phase)),
-		FileOwnership: spec.FileOwnership,
+		TaskID:          taskID,
+		PhaseID:         string(phase),
+		Role:            spec.Role,
+		Objective:       objective,
+		CodingStandards: standards,
+		FileOwnership:   spec.FileOwnership,
 		Deadline: domain.Deadline{
 			Soft: fmt.Sprintf("%ds", spec.SoftTimeoutSec),
 			Hard: fmt.Sprintf("%ds", spec.HardTimeoutSec),
 		},
+		ArtifactRefs: artifacts,
+		Capabilities: spec.Capabilities,
 	}
 
-	constraints := []string{
+	var constraints []string
+	for _, c := range taskConstraints {
+		constraints = append(constraints, c.Text)
+	}
+	constraints = append(constraints,
 		fmt.Sprintf("budget_used=%.2f", task.BudgetUsedUSD),
 		fmt.Sprintf("budget_cap=%.2f", task.BudgetCapUSD),
 		fmt.Sprintf("phase=%s", string(task.CurrentPhase)),
-	}
+	)
 	if snap != nil {
 		constraints = append(constraints, fmt.Sprintf("snapshot_round=%d", snap.Round))
 	}
 	digest.Constraints = constraints
 
-	var refs []domain.ArtifactRef
-	for i, intent := range intents {
-		refs = append(refs, domain.ArtifactRef{
-			ID:   intent.IntentID,
-			Type: intent.Operation,
-			Path: intent.TargetFile,
-			Version: i + 1,
-		})
+	digest.Round = task.Round
+	if task.Round > 0 {
+		if digest.PriorIssues, err = b.priorIssues(ctx, taskID, task.Round-1); err != nil {
+			return nil, err
+		}
+	}
+
+	if digest.Compacted, err = latestCompaction(ctx, b.DB, b.SnapshotRepo, taskID, phase); err != nil {
+		return nil, err
 	}
-	digest.ArtifactRefs = refs
 
 	return digest, nil
 }
+
+// priorIssues lists the issues raised by the score cards of round as
+// "reviewer [severity] location: description".
+func (b *DigestBuilder) priorIssues(ctx context.Context, taskID string, round int) ([]string, error) {
+	cards, err := b.ScoreCardRepo.ListHistory(ctx, b.DB, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list score cards: %w", err)
+	}
+	var issues []string
+	for _, c := range cards {
+		if c.Round != round {
+			continue
+		}
+		for _, issue := range c.Issues {
+			issues = append(issues, fmt.Sprintf("%s [%s] %s: %s", c.Reviewer, issue.Severity, issue.Location, issue.Description))
+		}
+	}
+	return issues, nil
+}
@@ -64,25 +64,18 @@ func TestDigestBuilder_Build(t *testing.T) {
 		t.Fatalf("Commit: %v", err)
 	}
 
-	// Create a pending intent
-	tx3, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		t.Fatalf("BeginTx: %v", err)
-	}
-	intentRepo := &store.IntentRepo{}
-	err = intentRepo.UpsertTx(ctx, tx3, domain.Intent{
-		IntentID:   "int-1",
-		TaskID:     "task-1",
-		WorkerID:   "w-1",
-		TargetFile: "main.go",
-		Operation:  "write",
-		Status:     "pending",
-	})
-	if err != nil {
-		t.Fatalf("UpsertTx: %v", err)
-	}
-	if err := tx3.Commit(); err != nil {
-		t.Fatalf("Commit: %v", err)
+	// Submit an artifact
+	artifactRepo := &store.ArtifactRepo{}
+	if _, err := artifactRepo.Create(ctx, db, domain.ArtifactRef{
+		ID:        "art-1",
+		TaskID:    "task-1",
+		WorkerID:  "w-1",
+		Type:      "source",
+		Path:      "main.go",
+		Hash:      "h1",
+		CreatedAt: now,
+	}); err != nil {
+		t.Fatalf("Create artifact: %v", err)
 	}
 
 	builder := NewDigestBuilder(db)
@@ -166,11 +159,11 @@ func TestDigestBuilder_MissingSnapshot(t *testing.T) {
 		t.Errorf("TaskID = %q, want %q", digest.TaskID, "task-2")
 	}
 	if len(digest.ArtifactRefs) != 0 {
-		t.Errorf("expected 0 artifact refs with no intents, got %d", len(digest.ArtifactRefs))
+		t.Errorf("expected 0 artifact refs with no artifacts, got %d", len(digest.ArtifactRefs))
 	}
 }
 
-func TestDigestBuilder_PendingIntentsAsRefs(t *testing.T) {
+func TestDigestBuilder_LatestArtifactVersions(t *testing.T) {
 	dir := t.TempDir()
 	db, err := store.NewDB(filepath.Join(dir, "test.db"))
 	if err != nil {
@@ -187,10 +180,10 @@ func TestDigestBuilder_PendingIntentsAsRefs(t *testing.T) {
 	}
 	taskRepo := &store.TaskRepo{}
 	err = taskRepo.CreateTx(ctx, tx, domain.FlowState{
-		TaskID:       "task-3",
-		CurrentPhase: domain.PhaseC,
-		Status:       domain.StatusRunning,
-		StateVersion: 1,
+		TaskID:        "task-3",
+		CurrentPhase:  domain.PhaseC,
+		Status:        domain.StatusRunning,
+		StateVersion:  1,
 		UpdatedAtUnix: now,
 	})
 	if err != nil {
@@ -200,22 +193,15 @@ func TestDigestBuilder_PendingIntentsAsRefs(t *testing.T) {
 		t.Fatalf("Commit: %v", err)
 	}
 
-	intents := []domain.Intent{
-		{IntentID: "int-a", TaskID: "task-3", TargetFile: "a.go", Operation: "write", Status: "pending"},
-		{IntentID: "int-b", TaskID: "task-3", TargetFile: "b.go", Operation: "create", Status: "pending"},
-		{IntentID: "int-c", TaskID: "task-3", TargetFile: "c.go", Operation: "write", Status: "done"},
-	}
-	for _, intent := range intents {
-		tx2, err := db.BeginTx(ctx, nil)
-		if err != nil {
-			t.Fatalf("BeginTx: %v", err)
-		}
-		intentRepo := &store.IntentRepo{}
-		if err := intentRepo.UpsertTx(ctx, tx2, intent); err != nil {
-			t.Fatalf("UpsertTx: %v", err)
-		}
-		if err := tx2.Commit(); err != nil {
-			t.Fatalf("Commit: %v", err)
+	artifactRepo := &store.ArtifactRepo{}
+	artifacts := []domain.ArtifactRef{
+		{ID: "art-a1", TaskID: "task-3", Type: "source", Path: "a.go", Hash: "a1"},
+		{ID: "art-b1", TaskID: "task-3", Type: "design", Path: "b.md", Hash: "b1"},
+		{ID: "art-a2", TaskID: "task-3", Type: "source", Path: "a.go", Hash: "a2"},
+	}
+	for _, a := range artifacts {
+		if _, err := artifactRepo.Create(ctx, db, a); err != nil {
+			t.Fatalf("Create artifact: %v", err)
 		}
 	}
 
@@ -227,14 +213,56 @@ func TestDigestBuilder_PendingIntentsAsRefs(t *testing.T) {
 		t.Fatalf("Build: %v", err)
 	}
 
-	// Only pending intents should appear (int-a, int-b, not int-c)
+	// Only the latest version of each path should appear, ordered by path.
 	if len(digest.ArtifactRefs) != 2 {
-		t.Fatalf("expected 2 artifact refs for pending intents, got %d", len(digest.ArtifactRefs))
+		t.Fatalf("expected 2 artifact refs, got %d", len(digest.ArtifactRefs))
+	}
+	if ref := digest.ArtifactRefs[0]; ref.ID != "art-a2" || ref.Version != 2 {
+		t.Errorf("first ref = %+v, want art-a2 version 2", ref)
+	}
+	if ref := digest.ArtifactRefs[1]; ref.Path != "b.md" || ref.Version != 1 {
+		t.Errorf("second ref = %+v, want b.md version 1", ref)
+	}
+}
+
+func TestDigestBuilder_PriorIssues(t *testing.T) {
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	if err := (&store.TaskRepo{}).CreateTx(ctx, tx, domain.FlowState{
+		TaskID: "task-1", CurrentPhase: domain.PhaseC, Status: domain.StatusRunning, StateVersion: 1, Round: 2,
+	}); err != nil {
+		t.Fatalf("CreateTx: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	cards := &store.ScoreCardRepo{}
+	for _, c := range []domain.ScoreCard{
+		{ReviewID: "rev-0", TaskID: "task-1", Reviewer: "a", Round: 0, Issues: []domain.Issue{{Severity: "P1", Location: "old.go", Description: "stale"}}},
+		{ReviewID: "rev-1", TaskID: "task-1", Reviewer: "b", Round: 1, Issues: []domain.Issue{{Severity: "P0", Location: "db.go", Description: "leaks rows"}}},
+	} {
+		if err := cards.Create(ctx, db, c); err != nil {
+			t.Fatalf("Create card: %v", err)
+		}
+	}
+
+	digest, err := NewDigestBuilder(db).Build(ctx, "task-1", domain.PhaseC, domain.WorkerSpec{Role: "reviewer"})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
 	}
-	if digest.ArtifactRefs[0].Path != "a.go" {
-		t.Errorf("first ref path = %q, want %q", digest.ArtifactRefs[0].Path, "a.go")
+	if digest.Round != 2 {
+		t.Errorf("Round = %d, want 2", digest.Round)
 	}
-	if digest.ArtifactRefs[1].Path != "b.go" {
-		t.Errorf("second ref path = %q, want %q", digest.ArtifactRefs[1].Path, "b.go")
+	if len(digest.PriorIssues) != 1 || digest.PriorIssues[0] != "b [P0] db.go: leaks rows" {
+		t.Errorf("PriorIssues = %v", digest.PriorIssues)
 	}
 }
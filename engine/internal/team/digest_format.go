@@ -0,0 +1,114 @@
+package team
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// Digest file formats.
+const (
+	DigestJSON     = "json"
+	DigestMarkdown = "markdown"
+)
+
+// DigestFormatFor returns the format of a digest file by its extension:
+// Markdown for .md, JSON otherwise.
+func DigestFormatFor(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".md") {
+		return DigestMarkdown
+	}
+	return DigestJSON
+}
+
+// RenderDigest encodes d in format, which is DigestJSON or DigestMarkdown.
+func RenderDigest(d *domain.ContextDigest, format string) ([]byte, error) {
+	switch format {
+	case DigestJSON:
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("marshal digest: %w", err)
+		}
+		return data, nil
+	case DigestMarkdown:
+		return digestMarkdown(d), nil
+	default:
+		return nil, fmt.Errorf("unknown digest format %q", format)
+	}
+}
+
+// WriteDigest writes d to path in the format its extension selects,
+// creating the parent directory.
+func WriteDigest(path string, d *domain.ContextDigest) error {
+	data, err := RenderDigest(d, DigestFormatFor(path))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create digest dir: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write digest: %w", err)
+	}
+	return nil
+}
+
+// digestMarkdown lays d out as a Markdown brief, one section per field and
+// empty sections left out.
+func digestMarkdown(d *domain.ContextDigest) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Task %s, phase %s\n\n", d.TaskID, d.PhaseID)
+	if d.Role != "" {
+		fmt.Fprintf(&b, "Role: %s\n\n", d.Role)
+	}
+	if d.Round > 0 {
+		fmt.Fprintf(&b, "Review round: %d\n\n", d.Round)
+	}
+	fmt.Fprintf(&b, "## Objective\n\n%s\n", d.Objective)
+	fmt.Fprintf(&b, "\n## Deadline\n\n- Soft: %s\n- Hard: %s\n", d.Deadline.Soft, d.Deadline.Hard)
+	writeList(&b, "Constraints", d.Constraints)
+	writeList(&b, "Issues from the previous round", d.PriorIssues)
+	writeList(&b, "File ownership", d.FileOwnership)
+	if c := d.Capabilities; c != nil {
+		b.WriteString("\n## Capabilities\n\n")
+		fmt.Fprintf(&b, "- Paths: %s\n- Commands: %s\n", strings.Join(c.AllowedPaths, ", "), strings.Join(c.AllowedCommands, ", "))
+		if len(c.DeniedPatterns) > 0 {
+			fmt.Fprintf(&b, "- Denied: %s\n", strings.Join(c.DeniedPatterns, ", "))
+		}
+	}
+	if len(d.ArtifactRefs) > 0 {
+		b.WriteString("\n## Artifacts\n\n")
+		for _, a := range d.ArtifactRefs {
+			fmt.Fprintf(&b, "- `%s` (%s, v%d, %s)\n", a.Path, a.Type, a.Version, a.Hash)
+		}
+	}
+	if d.CodingStandards != "" {
+		fmt.Fprintf(&b, "\n## Coding standards\n\n%s\n", d.CodingStandards)
+	}
+	if c := d.Compacted; c != nil {
+		if c.TaskSpec != "" {
+			fmt.Fprintf(&b, "\n## Task spec\n\n%s\n", c.TaskSpec)
+		}
+		if c.AcceptanceCriteria != "" {
+			fmt.Fprintf(&b, "\n## Acceptance criteria\n\n%s\n", c.AcceptanceCriteria)
+		}
+		writeList(&b, "Open risks", c.OpenRisks)
+		writeList(&b, "Pending intents", c.PendingIntents)
+		writeList(&b, "Before the next phase", c.NextPhaseReqs)
+	}
+	return []byte(b.String())
+}
+
+func writeList(b *strings.Builder, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n## %s\n\n", title)
+	for _, item := range items {
+		fmt.Fprintf(b, "- %s\n", item)
+	}
+}
@@ -0,0 +1,78 @@
+package team
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func testDigest() *domain.ContextDigest {
+	return &domain.ContextDigest{
+		TaskID:        "task-1",
+		PhaseID:       "C",
+		Role:          "coder",
+		Objective:     "[coder] worker in phase C",
+		Constraints:   []string{"budget_cap=10.00"},
+		FileOwnership: []string{"src/"},
+		Deadline:      domain.Deadline{Soft: "300s", Hard: "600s"},
+		ArtifactRefs:  []domain.ArtifactRef{{ID: "art-1", Type: "design", Path: "design.md", Version: 2, Hash: "abc"}},
+	}
+}
+
+func TestDigestFormatFor(t *testing.T) {
+	for path, want := range map[string]string{
+		"digest.md":   DigestMarkdown,
+		"digest.MD":   DigestMarkdown,
+		"digest.json": DigestJSON,
+		"digest":      DigestJSON,
+	} {
+		if got := DigestFormatFor(path); got != want {
+			t.Errorf("DigestFormatFor(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRenderDigest_Markdown(t *testing.T) {
+	data, err := RenderDigest(testDigest(), DigestMarkdown)
+	if err != nil {
+		t.Fatalf("RenderDigest: %v", err)
+	}
+	md := string(data)
+	for _, want := range []string{"# Task task-1, phase C", "## Objective", "- src/", "`design.md` (design, v2, abc)"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "Coding standards") {
+		t.Error("empty coding standards section should be omitted")
+	}
+	if _, err := RenderDigest(testDigest(), "yaml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestWriteDigest_ByExtension(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "nested", "digest.json")
+	if err := WriteDigest(jsonPath, testDigest()); err != nil {
+		t.Fatalf("WriteDigest json: %v", err)
+	}
+	data, _ := os.ReadFile(jsonPath)
+	var got domain.ContextDigest
+	if err := json.Unmarshal(data, &got); err != nil || got.TaskID != "task-1" {
+		t.Errorf("json digest = %s (%v)", data, err)
+	}
+
+	mdPath := filepath.Join(dir, "digest.md")
+	if err := WriteDigest(mdPath, testDigest()); err != nil {
+		t.Fatalf("WriteDigest markdown: %v", err)
+	}
+	data, _ = os.ReadFile(mdPath)
+	if !strings.HasPrefix(string(data), "# Task task-1") {
+		t.Errorf("markdown digest = %s", data)
+	}
+}
@@ -0,0 +1,192 @@
+package team
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+)
+
+// enqueue records intent as waiting for its file and grants it at once if
+// the file turns out to be free with no earlier waiter. The caller holds
+// queueMu.
+func (r *IntentResolver) enqueue(ctx context.Context, intent domain.Intent, leaseDurationSec int) error {
+	now := r.now()
+	intent.Status = "queued"
+	intent.LeaseSec = int64(leaseDurationSec)
+	intent.QueuedAt = now.UnixNano()
+	intent.QueueUntil = 0
+	if r.QueueTimeoutSec > 0 {
+		intent.QueueUntil = now.Unix() + int64(r.QueueTimeoutSec)
+	}
+	if intent.CreatedAt == 0 {
+		intent.CreatedAt = now.Unix()
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := r.IntentRepo.UpsertTx(ctx, tx, intent); err != nil {
+		return fmt.Errorf("upsert intent: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	position, _ := r.Position(ctx, intent.IntentID)
+	r.auditQueue(ctx, intent, intent.WorkerID, "lock_queued", "info", map[string]interface{}{
+		"file":     intent.TargetFile,
+		"position": position,
+	})
+
+	granted, err := r.grantLocked(ctx, intent.TaskID, intent.TargetFile, now.Unix())
+	if err != nil {
+		return err
+	}
+	if granted == intent.IntentID {
+		return nil
+	}
+	return domain.ErrIntentQueued
+}
+
+// Position returns the 1-based place of a queued intent among the waiters
+// on its file, or 0 if the intent is not queued.
+func (r *IntentResolver) Position(ctx context.Context, intentID string) (int, error) {
+	intent, err := r.IntentRepo.GetByID(ctx, r.DB, intentID)
+	if err != nil {
+		return 0, err
+	}
+	if intent.Status != "queued" {
+		return 0, nil
+	}
+	waiters, err := r.IntentRepo.ListWaiters(ctx, r.DB, intent.TaskID, intent.TargetFile)
+	if err != nil {
+		return 0, err
+	}
+	for i, w := range waiters {
+		if w.IntentID == intentID {
+			return i + 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// grantNext hands a free file to its first waiter as of nowUnix. Errors are
+// dropped: the waiters stay queued and the next release or reaper pass
+// retries.
+func (r *IntentResolver) grantNext(ctx context.Context, taskID, file string, nowUnix int64) {
+	r.queueMu.Lock()
+	defer r.queueMu.Unlock()
+	_, _ = r.grantLocked(ctx, taskID, file, nowUnix)
+}
+
+// grantLocked cancels the file's waiters whose queue timeout passed before
+// nowUnix and, if no intent holds the file, grants it to the earliest
+// remaining waiter. It returns the ID of the granted intent, if any. The
+// caller holds queueMu.
+func (r *IntentResolver) grantLocked(ctx context.Context, taskID, file string, nowUnix int64) (string, error) {
+	// Read before tx to avoid deadlock.
+	active, err := r.IntentRepo.FindActiveByFile(ctx, r.DB, taskID, file)
+	if err != nil {
+		return "", fmt.Errorf("find active intents: %w", err)
+	}
+	waiters, err := r.IntentRepo.ListWaiters(ctx, r.DB, taskID, file)
+	if err != nil {
+		return "", err
+	}
+
+	var timedOut []domain.Intent
+	var next *domain.Intent
+	for i, w := range waiters {
+		if w.QueueUntil > 0 && w.QueueUntil < nowUnix {
+			timedOut = append(timedOut, w)
+			continue
+		}
+		if next == nil && len(active) == 0 {
+			next = &waiters[i]
+		}
+	}
+	if len(timedOut) == 0 && next == nil {
+		return "", nil
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var cancelled []domain.Intent
+	for _, w := range timedOut {
+		ok, err := r.IntentRepo.CancelQueuedTx(ctx, tx, w.IntentID)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			cancelled = append(cancelled, w)
+		}
+	}
+	if next != nil {
+		next.LeaseUntil = nowUnix + next.LeaseSec
+		ok, err := r.IntentRepo.GrantTx(ctx, tx, next.IntentID, next.LeaseUntil)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			next = nil
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("commit: %w", err)
+	}
+
+	for _, w := range cancelled {
+		r.auditQueue(ctx, w, "system", "queue_timeout", "warning", map[string]interface{}{"file": w.TargetFile})
+		if r.Events != nil {
+			_ = r.Events.AppendEvent(ctx, w.TaskID, domain.EventIntentQueueTimeout, domain.IntentEventPayload{
+				Intent: w.IntentID,
+				Worker: w.WorkerID,
+				File:   w.TargetFile,
+			})
+		}
+	}
+	if next == nil {
+		return "", nil
+	}
+	waited := time.Duration(r.now().UnixNano() - next.QueuedAt).Round(time.Millisecond)
+	r.auditQueue(ctx, *next, "system", "lock_granted", "info", map[string]interface{}{
+		"file":   file,
+		"waited": waited.String(),
+	})
+	if r.Events != nil {
+		_ = r.Events.AppendEvent(ctx, taskID, domain.EventIntentGranted, domain.IntentEventPayload{
+			Intent: next.IntentID,
+			Worker: next.WorkerID,
+			File:   file,
+		})
+	}
+	r.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicIntentGranted, TaskID: taskID})
+	return next.IntentID, nil
+}
+
+func (r *IntentResolver) auditQueue(ctx context.Context, intent domain.Intent, actor, action, severity string, detail map[string]interface{}) {
+	detail["intent"] = intent.IntentID
+	data, _ := json.Marshal(detail)
+	now := r.now()
+	_ = r.AuditRepo.Record(ctx, r.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-%d", time.Now().UnixNano()),
+		TaskID:       intent.TaskID,
+		Category:     "intent",
+		Actor:        actor,
+		Action:       action,
+		DecisionJSON: string(data),
+		Severity:     severity,
+		CreatedAt:    now.Unix(),
+	})
+}
@@ -3,44 +3,124 @@ package team
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
 	"github.com/anthropics/three-body-engine/internal/store"
 )
 
+// IntentCommitter records the file change of an executed intent, for example
+// as a commit on the flow's branch.
+type IntentCommitter interface {
+	CommitIntent(ctx context.Context, intent domain.Intent) error
+}
+
+// EventAppender appends an event to a flow's event log, for example
+// workflow.Engine.
+type EventAppender interface {
+	AppendEvent(ctx context.Context, taskID string, eventType domain.EventType, payload interface{}) error
+}
+
 // IntentResolver handles acquiring, releasing, and executing file-level intent locks.
 type IntentResolver struct {
 	DB         *sql.DB
 	IntentRepo *store.IntentRepo
 	WorkerRepo *store.WorkerRepo
 	AuditRepo  *store.AuditRepo
+	// Bus, if set, receives a TopicIntentDone signal after each Execute.
+	Bus *eventbus.Bus
+	// Committer, if set, records each executed intent's change. A failed
+	// commit is audited but does not fail Execute.
+	Committer IntentCommitter
+	// Events, if set, receives an "intent_expired" event for each intent
+	// cancelled by ReapExpired, so workers waiting on the file can retry,
+	// and the queue events described on Queue.
+	Events EventAppender
+	// Queue makes AcquireLock queue an intent behind a conflicting one
+	// instead of failing with ErrIntentConflict. Waiters on a file are
+	// granted in arrival order when the holder is released, executed, or
+	// reaped, with an "intent_granted" event; waiters still queued after
+	// QueueTimeoutSec (0 = no limit) are cancelled with an
+	// "intent_queue_timeout" event.
+	Queue           bool
+	QueueTimeoutSec int
+	// Shared, if set, makes AcquireLock fail with ErrCrossTaskConflict when
+	// another task holds an active intent on the same absolute path.
+	Shared *SharedWorkspaces
+	// Clock dates leases and queue deadlines; nil means clock.System.
+	// Audit record IDs stay on the wall clock so they remain unique.
+	Clock clock.Clock
+
+	// queueMu serialises queue changes so two waiters are never granted
+	// the same file.
+	queueMu sync.Mutex
+}
+
+// now returns the time on r's clock.
+func (r *IntentResolver) now() time.Time {
+	return clock.Or(r.Clock).Now()
 }
 
 // AcquireLock claims an intent lock on a file within a transaction.
-// It verifies no conflicting active intents exist and that the worker owns the target file.
+// It verifies no conflicting active intents exist and that the worker owns the target file,
+// where a more specific ownership pattern held by another active worker takes precedence.
+// In queue mode a conflicting intent is queued instead and ErrIntentQueued is returned.
 func (r *IntentResolver) AcquireLock(ctx context.Context, intent domain.Intent, leaseDurationSec int) error {
+	if r.Queue {
+		r.queueMu.Lock()
+		defer r.queueMu.Unlock()
+	}
+
 	// All reads happen before BeginTx to avoid SQLite single-conn deadlock.
 	active, err := r.IntentRepo.FindActiveByFile(ctx, r.DB, intent.TaskID, intent.TargetFile)
 	if err != nil {
 		return fmt.Errorf("find active intents: %w", err)
 	}
-	if len(active) > 0 {
+	if len(active) > 0 && !r.Queue {
 		return domain.ErrIntentConflict
 	}
+	var waiters []domain.Intent
+	if r.Queue {
+		if waiters, err = r.IntentRepo.ListWaiters(ctx, r.DB, intent.TaskID, intent.TargetFile); err != nil {
+			return err
+		}
+	}
 
 	worker, err := r.WorkerRepo.GetByID(ctx, r.DB, intent.WorkerID)
 	if err != nil {
 		return fmt.Errorf("get worker: %w", err)
 	}
 
-	if !ownsFile(worker.FileOwnership, intent.TargetFile) {
+	peers, err := r.WorkerRepo.ListActive(ctx, r.DB, intent.TaskID)
+	if err != nil {
+		return fmt.Errorf("list workers: %w", err)
+	}
+	owned, err := ownsFile(worker, peers, intent.TargetFile)
+	if err != nil {
+		return fmt.Errorf("match ownership: %w", err)
+	}
+	if !owned {
 		return domain.ErrFileOwnership
 	}
+	if r.Shared != nil {
+		if err := r.checkShared(ctx, intent); err != nil {
+			return err
+		}
+	}
+	if len(active) > 0 || len(waiters) > 0 {
+		return r.enqueue(ctx, intent, leaseDurationSec)
+	}
 
 	intent.Status = "pending"
-	intent.LeaseUntil = time.Now().Unix() + int64(leaseDurationSec)
+	intent.LeaseUntil = r.now().Unix() + int64(leaseDurationSec)
+	if intent.CreatedAt == 0 {
+		intent.CreatedAt = r.now().Unix()
+	}
 
 	tx, err := r.DB.BeginTx(ctx, nil)
 	if err != nil {
@@ -56,9 +136,9 @@ func (r *IntentResolver) AcquireLock(ctx context.Context, intent domain.Intent,
 		return fmt.Errorf("commit: %w", err)
 	}
 
-	now := time.Now()
+	now := r.now()
 	_ = r.AuditRepo.Record(ctx, r.DB, domain.AuditRecord{
-		ID:        fmt.Sprintf("aud-%d", now.UnixNano()),
+		ID:        fmt.Sprintf("aud-%d", time.Now().UnixNano()),
 		TaskID:    intent.TaskID,
 		Category:  "intent",
 		Actor:     intent.WorkerID,
@@ -70,6 +150,38 @@ func (r *IntentResolver) AcquireLock(ctx context.Context, intent domain.Intent,
 	return nil
 }
 
+// checkShared returns ErrCrossTaskConflict, after auditing both tasks and
+// the path, if another task holds an active intent on intent's file.
+func (r *IntentResolver) checkShared(ctx context.Context, intent domain.Intent) error {
+	holders, err := r.Shared.Holders(ctx, intent)
+	if err != nil || len(holders) == 0 {
+		return err
+	}
+	path, err := r.Shared.Path(ctx, intent)
+	if err != nil {
+		return err
+	}
+	data, _ := json.Marshal(map[string]string{
+		"path":         path,
+		"task":         intent.TaskID,
+		"intent":       intent.IntentID,
+		"other_task":   holders[0].TaskID,
+		"other_intent": holders[0].IntentID,
+	})
+	now := r.now()
+	_ = r.AuditRepo.Record(ctx, r.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-%d", time.Now().UnixNano()),
+		TaskID:       intent.TaskID,
+		Category:     "intent",
+		Actor:        intent.WorkerID,
+		Action:       "cross_task_conflict",
+		DecisionJSON: string(data),
+		Severity:     "warning",
+		CreatedAt:    now.Unix(),
+	})
+	return domain.ErrCrossTaskConflict
+}
+
 // ReleaseLock cancels an existing intent lock.
 func (r *IntentResolver) ReleaseLock(ctx context.Context, intentID string) error {
 	// Read before tx to avoid deadlock.
@@ -94,9 +206,9 @@ func (r *IntentResolver) ReleaseLock(ctx context.Context, intentID string) error
 		return fmt.Errorf("commit: %w", err)
 	}
 
-	now := time.Now()
+	now := r.now()
 	_ = r.AuditRepo.Record(ctx, r.DB, domain.AuditRecord{
-		ID:        fmt.Sprintf("aud-%d", now.UnixNano()),
+		ID:        fmt.Sprintf("aud-%d", time.Now().UnixNano()),
 		TaskID:    existing.TaskID,
 		Category:  "intent",
 		Actor:     existing.WorkerID,
@@ -104,10 +216,129 @@ func (r *IntentResolver) ReleaseLock(ctx context.Context, intentID string) error
 		Severity:  "info",
 		CreatedAt: now.Unix(),
 	})
+	r.grantNext(ctx, existing.TaskID, existing.TargetFile, r.now().Unix())
 
 	return nil
 }
 
+// Renew extends an active intent's lease to leaseDurationSec from now. An
+// intent whose lease has already ended cannot be renewed, since the reaper
+// may have released the file to another worker.
+func (r *IntentResolver) Renew(ctx context.Context, intentID string, leaseDurationSec int) error {
+	// Read before tx to avoid deadlock.
+	existing, err := r.IntentRepo.GetByID(ctx, r.DB, intentID)
+	if err != nil {
+		return err
+	}
+	if existing.Status != "pending" && existing.Status != "running" {
+		return domain.ErrIntentNotActive
+	}
+	now := r.now()
+	if existing.LeaseUntil < now.Unix() {
+		return domain.ErrLeaseExpired
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	renewed, err := r.IntentRepo.RenewTx(ctx, tx, intentID, now.Unix()+int64(leaseDurationSec), now.Unix())
+	if err != nil {
+		return err
+	}
+	if !renewed {
+		return domain.ErrLeaseExpired
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// ReapExpired cancels a task's active intents whose lease ended before
+// nowUnix, freeing their files for new locks. Each cancelled intent is
+// audited, announced on the bus, and appended to the flow's event log. The
+// freed files, and files with queued intents past their queue timeout, are
+// then handed to their next waiter. It returns the intents it cancelled.
+func (r *IntentResolver) ReapExpired(ctx context.Context, taskID string, nowUnix int64) ([]domain.Intent, error) {
+	// Read before tx to avoid deadlock.
+	expired, err := r.IntentRepo.ListExpired(ctx, r.DB, taskID, nowUnix)
+	if err != nil {
+		return nil, err
+	}
+	waiters, err := r.IntentRepo.ListWaiters(ctx, r.DB, taskID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var reaped []domain.Intent
+	if len(expired) > 0 {
+		tx, err := r.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("begin tx: %w", err)
+		}
+		defer tx.Rollback()
+
+		for _, intent := range expired {
+			ok, err := r.IntentRepo.ExpireTx(ctx, tx, intent.IntentID, nowUnix)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				intent.Status = "cancelled"
+				reaped = append(reaped, intent)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("commit: %w", err)
+		}
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, intent := range reaped {
+		payload := domain.IntentEventPayload{
+			Intent: intent.IntentID,
+			Worker: intent.WorkerID,
+			File:   intent.TargetFile,
+		}
+		data, _ := json.Marshal(payload)
+		now := r.now()
+		_ = r.AuditRepo.Record(ctx, r.DB, domain.AuditRecord{
+			ID:           fmt.Sprintf("aud-%d", time.Now().UnixNano()),
+			TaskID:       intent.TaskID,
+			Category:     "intent",
+			Actor:        "system",
+			Action:       "lease_expired",
+			DecisionJSON: string(data),
+			Severity:     "warning",
+			CreatedAt:    now.Unix(),
+		})
+		if r.Events != nil {
+			_ = r.Events.AppendEvent(ctx, intent.TaskID, domain.EventIntentExpired, payload)
+		}
+		if !seen[intent.TargetFile] {
+			seen[intent.TargetFile] = true
+			files = append(files, intent.TargetFile)
+		}
+	}
+	for _, w := range waiters {
+		if w.QueueUntil > 0 && w.QueueUntil < nowUnix && !seen[w.TargetFile] {
+			seen[w.TargetFile] = true
+			files = append(files, w.TargetFile)
+		}
+	}
+	for _, file := range files {
+		r.grantNext(ctx, taskID, file, nowUnix)
+	}
+	if len(reaped) > 0 {
+		r.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicIntentExpired, TaskID: taskID})
+	}
+	return reaped, nil
+}
+
 // Execute completes an intent by verifying the lease and pre-hash, then marking it done.
 func (r *IntentResolver) Execute(ctx context.Context, intentID, currentHash, postHash string) error {
 	// Read before tx to avoid deadlock.
@@ -116,7 +347,7 @@ func (r *IntentResolver) Execute(ctx context.Context, intentID, currentHash, pos
 		return err
 	}
 
-	if existing.LeaseUntil < time.Now().Unix() {
+	if existing.LeaseUntil < r.now().Unix() {
 		return domain.ErrLeaseExpired
 	}
 
@@ -138,9 +369,9 @@ func (r *IntentResolver) Execute(ctx context.Context, intentID, currentHash, pos
 		return fmt.Errorf("commit: %w", err)
 	}
 
-	now := time.Now()
+	now := r.now()
 	_ = r.AuditRepo.Record(ctx, r.DB, domain.AuditRecord{
-		ID:        fmt.Sprintf("aud-%d", now.UnixNano()),
+		ID:        fmt.Sprintf("aud-%d", time.Now().UnixNano()),
 		TaskID:    existing.TaskID,
 		Category:  "intent",
 		Actor:     existing.WorkerID,
@@ -148,15 +379,24 @@ func (r *IntentResolver) Execute(ctx context.Context, intentID, currentHash, pos
 		Severity:  "info",
 		CreatedAt: now.Unix(),
 	})
-
-	return nil
-}
-
-func ownsFile(ownership []string, target string) bool {
-	for _, f := range ownership {
-		if f == target {
-			return true
+	if r.Committer != nil {
+		existing.PostHash = postHash
+		if err := r.Committer.CommitIntent(ctx, *existing); err != nil {
+			data, _ := json.Marshal(map[string]string{"intent": intentID, "error": err.Error()})
+			_ = r.AuditRepo.Record(ctx, r.DB, domain.AuditRecord{
+				ID:           fmt.Sprintf("aud-%d", time.Now().UnixNano()),
+				TaskID:       existing.TaskID,
+				Category:     "intent",
+				Actor:        existing.WorkerID,
+				Action:       "commit_failed",
+				DecisionJSON: string(data),
+				Severity:     "warning",
+				CreatedAt:    now.Unix(),
+			})
 		}
 	}
-	return false
+	r.grantNext(ctx, existing.TaskID, existing.TargetFile, r.now().Unix())
+	r.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicIntentDone, TaskID: existing.TaskID})
+
+	return nil
 }
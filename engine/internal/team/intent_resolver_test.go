@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
 	"github.com/anthropics/three-body-engine/internal/store"
 )
@@ -123,6 +124,46 @@ func TestAcquireLock_FileOwnershipViolation(t *testing.T) {
 	}
 }
 
+func TestAcquireLock_DirectoryOwnership(t *testing.T) {
+	resolver, mgr := newResolverTestDB(t)
+	ctx := context.Background()
+	w := spawnTestWorker(t, mgr, []string{"src/"})
+
+	intent := domain.Intent{
+		IntentID:   "int-1",
+		TaskID:     "task-1",
+		WorkerID:   w.WorkerID,
+		TargetFile: "src/main.go",
+		Operation:  "write",
+	}
+	if err := resolver.AcquireLock(ctx, intent, 60); err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+}
+
+func TestAcquireLock_MoreSpecificOwnerWins(t *testing.T) {
+	resolver, mgr := newResolverTestDB(t)
+	ctx := context.Background()
+	broad := spawnTestWorker(t, mgr, []string{"src/"})
+	narrow := spawnTestWorker(t, mgr, []string{"src/main.go"})
+
+	intent := domain.Intent{
+		IntentID:   "int-1",
+		TaskID:     "task-1",
+		WorkerID:   broad.WorkerID,
+		TargetFile: "src/main.go",
+		Operation:  "write",
+	}
+	if err := resolver.AcquireLock(ctx, intent, 60); err != domain.ErrFileOwnership {
+		t.Fatalf("broad owner: expected ErrFileOwnership, got %v", err)
+	}
+
+	intent.WorkerID = narrow.WorkerID
+	if err := resolver.AcquireLock(ctx, intent, 60); err != nil {
+		t.Fatalf("narrow owner: %v", err)
+	}
+}
+
 func TestReleaseLock_Success(t *testing.T) {
 	resolver, mgr := newResolverTestDB(t)
 	ctx := context.Background()
@@ -195,6 +236,51 @@ func TestExecute_Success(t *testing.T) {
 	}
 }
 
+type recordingCommitter struct {
+	intents []domain.Intent
+}
+
+func (c *recordingCommitter) CommitIntent(_ context.Context, intent domain.Intent) error {
+	c.intents = append(c.intents, intent)
+	return nil
+}
+
+func TestExecute_Commits(t *testing.T) {
+	resolver, mgr := newResolverTestDB(t)
+	committer := &recordingCommitter{}
+	resolver.Committer = committer
+	ctx := context.Background()
+	w := spawnTestWorker(t, mgr, []string{"main.go"})
+
+	intent := domain.Intent{
+		IntentID:   "int-1",
+		TaskID:     "task-1",
+		WorkerID:   w.WorkerID,
+		TargetFile: "main.go",
+		Operation:  "write",
+		PreHash:    "hash-before",
+	}
+	if err := resolver.AcquireLock(ctx, intent, 120); err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	if err := resolver.Execute(ctx, "int-1", "wrong-hash", "hash-after"); err != domain.ErrIntentHashMismatch {
+		t.Fatalf("expected ErrIntentHashMismatch, got %v", err)
+	}
+	if len(committer.intents) != 0 {
+		t.Fatal("failed Execute must not commit")
+	}
+
+	if err := resolver.Execute(ctx, "int-1", "hash-before", "hash-after"); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if len(committer.intents) != 1 {
+		t.Fatalf("commits = %d, want 1", len(committer.intents))
+	}
+	if got := committer.intents[0]; got.IntentID != "int-1" || got.WorkerID != w.WorkerID || got.PostHash != "hash-after" {
+		t.Errorf("committed intent = %+v", got)
+	}
+}
+
 func TestExecute_LeaseExpired(t *testing.T) {
 	resolver, mgr := newResolverTestDB(t)
 	ctx := context.Background()
@@ -208,13 +294,13 @@ func TestExecute_LeaseExpired(t *testing.T) {
 		Operation:  "write",
 		PreHash:    "hash-before",
 	}
-	// Use a lease of 0 seconds so it expires immediately.
-	if err := resolver.AcquireLock(ctx, intent, 0); err != nil {
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	resolver.Clock = clk
+	if err := resolver.AcquireLock(ctx, intent, 30); err != nil {
 		t.Fatalf("AcquireLock: %v", err)
 	}
 
-	// Wait a moment to ensure expiry.
-	time.Sleep(1100 * time.Millisecond)
+	clk.Advance(31 * time.Second)
 
 	err := resolver.Execute(ctx, "int-1", "hash-before", "hash-after")
 	if err != domain.ErrLeaseExpired {
@@ -244,3 +330,174 @@ func TestExecute_HashMismatch(t *testing.T) {
 		t.Errorf("expected ErrIntentHashMismatch, got %v", err)
 	}
 }
+
+type recordingEvents struct {
+	types []domain.EventType
+}
+
+func (e *recordingEvents) AppendEvent(_ context.Context, _ string, eventType domain.EventType, _ interface{}) error {
+	e.types = append(e.types, eventType)
+	return nil
+}
+
+func TestRenew(t *testing.T) {
+	resolver, mgr := newResolverTestDB(t)
+	ctx := context.Background()
+	w := spawnTestWorker(t, mgr, []string{"main.go", "other.go"})
+
+	intent := domain.Intent{IntentID: "int-1", TaskID: "task-1", WorkerID: w.WorkerID, TargetFile: "main.go", Operation: "write"}
+	if err := resolver.AcquireLock(ctx, intent, 10); err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	if err := resolver.Renew(ctx, "int-1", 600); err != nil {
+		t.Fatalf("Renew: %v", err)
+	}
+	got, _ := resolver.IntentRepo.GetByID(ctx, resolver.DB, "int-1")
+	if got.LeaseUntil < time.Now().Unix()+500 {
+		t.Errorf("LeaseUntil = %d, want about 600s from now", got.LeaseUntil)
+	}
+
+	// An already expired lease cannot be renewed.
+	stale := domain.Intent{IntentID: "int-2", TaskID: "task-1", WorkerID: w.WorkerID, TargetFile: "other.go", Operation: "write"}
+	if err := resolver.AcquireLock(ctx, stale, -10); err != nil {
+		t.Fatalf("AcquireLock stale: %v", err)
+	}
+	if err := resolver.Renew(ctx, "int-2", 600); err != domain.ErrLeaseExpired {
+		t.Errorf("Renew expired: got %v, want ErrLeaseExpired", err)
+	}
+
+	// Nor can a released one.
+	if err := resolver.ReleaseLock(ctx, "int-1"); err != nil {
+		t.Fatalf("ReleaseLock: %v", err)
+	}
+	if err := resolver.Renew(ctx, "int-1", 600); err != domain.ErrIntentNotActive {
+		t.Errorf("Renew released: got %v, want ErrIntentNotActive", err)
+	}
+	if err := resolver.Renew(ctx, "missing", 600); err != domain.ErrIntentNotFound {
+		t.Errorf("Renew missing: got %v, want ErrIntentNotFound", err)
+	}
+}
+
+func TestReapExpired_FreesFile(t *testing.T) {
+	resolver, mgr := newResolverTestDB(t)
+	events := &recordingEvents{}
+	resolver.Events = events
+	ctx := context.Background()
+	w := spawnTestWorker(t, mgr, []string{"main.go", "live.go"})
+
+	expired := domain.Intent{IntentID: "int-1", TaskID: "task-1", WorkerID: w.WorkerID, TargetFile: "main.go", Operation: "write"}
+	if err := resolver.AcquireLock(ctx, expired, -10); err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	live := domain.Intent{IntentID: "int-2", TaskID: "task-1", WorkerID: w.WorkerID, TargetFile: "live.go", Operation: "write"}
+	if err := resolver.AcquireLock(ctx, live, 600); err != nil {
+		t.Fatalf("AcquireLock live: %v", err)
+	}
+
+	// The stale lease blocks new locks until it is reaped.
+	retry := domain.Intent{IntentID: "int-3", TaskID: "task-1", WorkerID: w.WorkerID, TargetFile: "main.go", Operation: "write"}
+	if err := resolver.AcquireLock(ctx, retry, 60); err != domain.ErrIntentConflict {
+		t.Fatalf("expected ErrIntentConflict before reaping, got %v", err)
+	}
+
+	reaped, err := resolver.ReapExpired(ctx, "task-1", time.Now().Unix())
+	if err != nil {
+		t.Fatalf("ReapExpired: %v", err)
+	}
+	if len(reaped) != 1 || reaped[0].IntentID != "int-1" || reaped[0].Status != "cancelled" {
+		t.Fatalf("reaped = %+v, want int-1 cancelled", reaped)
+	}
+	if len(events.types) != 1 || events.types[0] != "intent_expired" {
+		t.Errorf("events = %v, want [intent_expired]", events.types)
+	}
+	if got, _ := resolver.IntentRepo.GetByID(ctx, resolver.DB, "int-2"); got.Status != "pending" {
+		t.Errorf("live intent status = %q, want pending", got.Status)
+	}
+
+	if err := resolver.AcquireLock(ctx, retry, 60); err != nil {
+		t.Fatalf("AcquireLock after reaping: %v", err)
+	}
+
+	// A second pass finds nothing.
+	if again, _ := resolver.ReapExpired(ctx, "task-1", time.Now().Unix()); len(again) != 0 {
+		t.Errorf("second reap = %+v, want none", again)
+	}
+}
+
+func TestAcquireLock_QueueGrantsInOrder(t *testing.T) {
+	resolver, mgr := newResolverTestDB(t)
+	resolver.Queue = true
+	events := &recordingEvents{}
+	resolver.Events = events
+	ctx := context.Background()
+	w := spawnTestWorker(t, mgr, []string{"main.go"})
+
+	newIntent := func(id string) domain.Intent {
+		return domain.Intent{IntentID: id, TaskID: "task-1", WorkerID: w.WorkerID, TargetFile: "main.go", Operation: "write", PreHash: "h"}
+	}
+	if err := resolver.AcquireLock(ctx, newIntent("int-1"), 60); err != nil {
+		t.Fatalf("AcquireLock holder: %v", err)
+	}
+	for _, id := range []string{"int-2", "int-3"} {
+		if err := resolver.AcquireLock(ctx, newIntent(id), 60); err != domain.ErrIntentQueued {
+			t.Fatalf("AcquireLock %s: got %v, want ErrIntentQueued", id, err)
+		}
+	}
+	for id, want := range map[string]int{"int-1": 0, "int-2": 1, "int-3": 2} {
+		if pos, err := resolver.Position(ctx, id); err != nil || pos != want {
+			t.Errorf("Position(%s) = %d, %v; want %d", id, pos, err, want)
+		}
+	}
+
+	// Executing the holder grants the first waiter with a fresh lease.
+	if err := resolver.Execute(ctx, "int-1", "h", "h2"); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	got, _ := resolver.IntentRepo.GetByID(ctx, resolver.DB, "int-2")
+	if got.Status != "pending" || got.LeaseUntil < time.Now().Unix()+50 {
+		t.Fatalf("int-2 = %+v, want pending with a 60s lease", got)
+	}
+	if pos, _ := resolver.Position(ctx, "int-3"); pos != 1 {
+		t.Errorf("int-3 position = %d, want 1", pos)
+	}
+
+	// Releasing it grants the next one.
+	if err := resolver.ReleaseLock(ctx, "int-2"); err != nil {
+		t.Fatalf("ReleaseLock: %v", err)
+	}
+	if got, _ := resolver.IntentRepo.GetByID(ctx, resolver.DB, "int-3"); got.Status != "pending" {
+		t.Errorf("int-3 status = %q, want pending", got.Status)
+	}
+	if len(events.types) != 2 || events.types[0] != "intent_granted" {
+		t.Errorf("events = %v, want two intent_granted", events.types)
+	}
+}
+
+func TestAcquireLock_QueueTimeout(t *testing.T) {
+	resolver, mgr := newResolverTestDB(t)
+	resolver.Queue = true
+	resolver.QueueTimeoutSec = 1
+	ctx := context.Background()
+	w := spawnTestWorker(t, mgr, []string{"main.go"})
+
+	holder := domain.Intent{IntentID: "int-1", TaskID: "task-1", WorkerID: w.WorkerID, TargetFile: "main.go", Operation: "write"}
+	if err := resolver.AcquireLock(ctx, holder, 600); err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	waiter := holder
+	waiter.IntentID = "int-2"
+	if err := resolver.AcquireLock(ctx, waiter, 60); err != domain.ErrIntentQueued {
+		t.Fatalf("AcquireLock waiter: got %v, want ErrIntentQueued", err)
+	}
+
+	if _, err := resolver.ReapExpired(ctx, "task-1", time.Now().Unix()+5); err != nil {
+		t.Fatalf("ReapExpired: %v", err)
+	}
+	// The holder's lease is still valid, so only the waiter is affected.
+	if got, _ := resolver.IntentRepo.GetByID(ctx, resolver.DB, "int-1"); got.Status != "pending" {
+		t.Errorf("holder status = %q, want pending", got.Status)
+	}
+	if got, _ := resolver.IntentRepo.GetByID(ctx, resolver.DB, "int-2"); got.Status != "cancelled" {
+		t.Errorf("waiter status = %q, want cancelled", got.Status)
+	}
+}
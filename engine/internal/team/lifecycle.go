@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
 	"github.com/anthropics/three-body-engine/internal/store"
 )
@@ -19,6 +21,33 @@ type WorkerManager struct {
 	WorkerRepo *store.WorkerRepo
 	AuditRepo  *store.AuditRepo
 	MaxWorkers int
+
+	// PoolSize caps active workers across all tasks. Zero means unlimited.
+	PoolSize int
+	// ReservedSlots is the number of pool slots only specs with a positive
+	// Priority may use, so urgent flows are not starved by routine ones.
+	ReservedSlots int
+	// RoleLimits caps active workers per role within one task, e.g.
+	// {"reviewer": 2}. Roles without a positive limit are unrestricted.
+	RoleLimits map[string]int
+
+	// Queue makes SpawnWait wait for a free slot instead of failing with
+	// ErrWorkerLimitReached. Waiters are served in arrival order whenever a
+	// worker finishes, and at least every PollInterval (default 1s) for
+	// slots freed elsewhere.
+	Queue        bool
+	PollInterval time.Duration
+
+	// Clock dates workers' creation and first heartbeat and the audit
+	// records of spawns and shutdowns. Worker and audit record IDs stay on
+	// the wall clock so they remain unique.
+	Clock clock.Clock
+
+	limitsMu sync.RWMutex
+
+	queueMu sync.Mutex
+	waiters []*spawnWaiter
+	stats   WorkerQueueStats
 }
 
 // NewWorkerManager creates a WorkerManager with the given database and max worker limit.
@@ -28,20 +57,64 @@ func NewWorkerManager(db *sql.DB, maxWorkers int) *WorkerManager {
 		WorkerRepo: &store.WorkerRepo{},
 		AuditRepo:  &store.AuditRepo{},
 		MaxWorkers: maxWorkers,
+		Clock:      clock.System,
 	}
 }
 
-// Spawn creates a new worker from the given spec, enforcing the max worker limit.
+// SetLimits replaces the per-task, pool, and reserved worker limits at
+// runtime. Workers already running are not affected.
+func (m *WorkerManager) SetLimits(maxWorkers, poolSize, reservedSlots int) {
+	m.limitsMu.Lock()
+	m.MaxWorkers, m.PoolSize, m.ReservedSlots = maxWorkers, poolSize, reservedSlots
+	m.limitsMu.Unlock()
+}
+
+// SetRoleLimits replaces the per-role worker limits at runtime.
+func (m *WorkerManager) SetRoleLimits(limits map[string]int) {
+	m.limitsMu.Lock()
+	m.RoleLimits = limits
+	m.limitsMu.Unlock()
+}
+
+// Spawn creates a new worker from the given spec, enforcing the per-task,
+// per-role, and pool limits.
 func (m *WorkerManager) Spawn(ctx context.Context, spec domain.WorkerSpec) (*domain.WorkerRef, error) {
+	m.limitsMu.RLock()
+	maxWorkers, poolSize, reservedSlots := m.MaxWorkers, m.PoolSize, m.ReservedSlots
+	roleLimit := m.RoleLimits[spec.Role]
+	m.limitsMu.RUnlock()
+
 	count, err := m.WorkerRepo.CountActive(ctx, m.DB, spec.TaskID)
 	if err != nil {
 		return nil, fmt.Errorf("count active workers: %w", err)
 	}
-	if count >= m.MaxWorkers {
+	if count >= maxWorkers {
 		return nil, domain.ErrWorkerLimitReached
 	}
+	if roleLimit > 0 {
+		n, err := m.WorkerRepo.CountActiveByRole(ctx, m.DB, spec.TaskID, spec.Role)
+		if err != nil {
+			return nil, fmt.Errorf("count role workers: %w", err)
+		}
+		if n >= roleLimit {
+			return nil, domain.ErrWorkerLimitReached
+		}
+	}
+	if poolSize > 0 {
+		total, err := m.WorkerRepo.CountAllActive(ctx, m.DB)
+		if err != nil {
+			return nil, fmt.Errorf("count pool workers: %w", err)
+		}
+		limit := poolSize
+		if spec.Priority <= 0 {
+			limit -= reservedSlots
+		}
+		if total >= limit {
+			return nil, domain.ErrWorkerLimitReached
+		}
+	}
 
-	now := time.Now()
+	now := m.Clock.Now()
 	seq := workerSeq.Add(1)
 
 	ownership := spec.FileOwnership
@@ -50,7 +123,7 @@ func (m *WorkerManager) Spawn(ctx context.Context, spec domain.WorkerSpec) (*dom
 	}
 
 	w := domain.WorkerRef{
-		WorkerID:       fmt.Sprintf("w-%d-%d", now.UnixNano(), seq),
+		WorkerID:       fmt.Sprintf("w-%d-%d", time.Now().UnixNano(), seq),
 		TaskID:         spec.TaskID,
 		Phase:          spec.Phase,
 		Role:           spec.Role,
@@ -60,6 +133,9 @@ func (m *WorkerManager) Spawn(ctx context.Context, spec domain.WorkerSpec) (*dom
 		HardTimeoutSec: spec.HardTimeoutSec,
 		LastHeartbeat:  now.Unix(),
 		CreatedAtUnix:  now.Unix(),
+		LineageID:      spec.LineageID,
+		Generation:     spec.Generation,
+		Capabilities:   spec.Capabilities,
 	}
 
 	if err := m.WorkerRepo.Create(ctx, m.DB, w); err != nil {
@@ -67,7 +143,7 @@ func (m *WorkerManager) Spawn(ctx context.Context, spec domain.WorkerSpec) (*dom
 	}
 
 	_ = m.AuditRepo.Record(ctx, m.DB, domain.AuditRecord{
-		ID:        fmt.Sprintf("aud-%d", now.UnixNano()),
+		ID:        fmt.Sprintf("aud-%d", time.Now().UnixNano()),
 		TaskID:    spec.TaskID,
 		Category:  "worker",
 		Actor:     "system",
@@ -90,10 +166,17 @@ func (m *WorkerManager) UpdateState(ctx context.Context, workerID string, state
 		return domain.ErrWorkerAlreadyDone
 	}
 
-	return m.WorkerRepo.UpdateState(ctx, m.DB, workerID, state)
+	if err := m.WorkerRepo.UpdateState(ctx, m.DB, workerID, state); err != nil {
+		return err
+	}
+	if isTerminal(state) {
+		m.wake()
+	}
+	return nil
 }
 
-// Replace marks an existing worker as replaced and spawns a new one with the same spec.
+// Replace marks an existing worker as replaced and spawns a new one with the
+// same spec, one generation further down the original worker's lineage.
 func (m *WorkerManager) Replace(ctx context.Context, workerID string) (*domain.WorkerRef, error) {
 	old, err := m.WorkerRepo.GetByID(ctx, m.DB, workerID)
 	if err != nil {
@@ -104,6 +187,10 @@ func (m *WorkerManager) Replace(ctx context.Context, workerID string) (*domain.W
 		return nil, fmt.Errorf("mark worker as replaced: %w", err)
 	}
 
+	lineage := old.LineageID
+	if lineage == "" {
+		lineage = old.WorkerID
+	}
 	spec := domain.WorkerSpec{
 		TaskID:         old.TaskID,
 		Phase:          old.Phase,
@@ -111,6 +198,9 @@ func (m *WorkerManager) Replace(ctx context.Context, workerID string) (*domain.W
 		FileOwnership:  old.FileOwnership,
 		SoftTimeoutSec: old.SoftTimeoutSec,
 		HardTimeoutSec: old.HardTimeoutSec,
+		LineageID:      lineage,
+		Generation:     old.Generation + 1,
+		Capabilities:   old.Capabilities,
 	}
 
 	return m.Spawn(ctx, spec)
@@ -127,9 +217,9 @@ func (m *WorkerManager) Shutdown(ctx context.Context, workerID string) error {
 		return fmt.Errorf("shutdown worker: %w", err)
 	}
 
-	now := time.Now()
+	now := m.Clock.Now()
 	_ = m.AuditRepo.Record(ctx, m.DB, domain.AuditRecord{
-		ID:        fmt.Sprintf("aud-%d", now.UnixNano()),
+		ID:        fmt.Sprintf("aud-%d", time.Now().UnixNano()),
 		TaskID:    existing.TaskID,
 		Category:  "worker",
 		Actor:     "system",
@@ -137,6 +227,7 @@ func (m *WorkerManager) Shutdown(ctx context.Context, workerID string) error {
 		Severity:  "info",
 		CreatedAt: now.Unix(),
 	})
+	m.wake()
 
 	return nil
 }
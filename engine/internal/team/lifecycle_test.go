@@ -4,7 +4,9 @@ import (
 	"context"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
 	"github.com/anthropics/three-body-engine/internal/store"
 )
@@ -29,6 +31,7 @@ func TestWorkerManager_Spawn(t *testing.T) {
 	defer db.Close()
 
 	mgr := NewWorkerManager(db, 4)
+	mgr.Clock = clock.NewFake(time.Unix(1_700_000_000, 0))
 	ctx := context.Background()
 
 	w, err := mgr.Spawn(ctx, testSpec())
@@ -44,6 +47,9 @@ func TestWorkerManager_Spawn(t *testing.T) {
 	if w.TaskID != "task-1" {
 		t.Errorf("TaskID = %q, want %q", w.TaskID, "task-1")
 	}
+	if w.CreatedAtUnix != 1_700_000_000 || w.LastHeartbeat != 1_700_000_000 {
+		t.Errorf("CreatedAtUnix, LastHeartbeat = %d, %d; want the clock's time", w.CreatedAtUnix, w.LastHeartbeat)
+	}
 }
 
 func TestWorkerManager_SpawnRespectsLimit(t *testing.T) {
@@ -199,3 +205,90 @@ func TestWorkerManager_ListActive(t *testing.T) {
 		t.Errorf("expected 1 active worker, got %d", len(active))
 	}
 }
+
+func TestWorkerManager_ReservedSlotsNeedPriority(t *testing.T) {
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	mgr := NewWorkerManager(db, 5)
+	mgr.PoolSize = 2
+	mgr.ReservedSlots = 1
+
+	low := testSpec()
+	if _, err := mgr.Spawn(ctx, low); err != nil {
+		t.Fatalf("first Spawn: %v", err)
+	}
+	low.TaskID = "task-2"
+	if _, err := mgr.Spawn(ctx, low); err != domain.ErrWorkerLimitReached {
+		t.Fatalf("expected ErrWorkerLimitReached for reserved slot, got %v", err)
+	}
+
+	high := testSpec()
+	high.TaskID = "task-3"
+	high.Priority = 10
+	if _, err := mgr.Spawn(ctx, high); err != nil {
+		t.Fatalf("priority Spawn: %v", err)
+	}
+	if _, err := mgr.Spawn(ctx, high); err != domain.ErrWorkerLimitReached {
+		t.Fatalf("expected ErrWorkerLimitReached when pool is full, got %v", err)
+	}
+}
+
+func TestWorkerManager_SetLimits(t *testing.T) {
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	mgr := NewWorkerManager(db, 5)
+	if _, err := mgr.Spawn(ctx, testSpec()); err != nil {
+		t.Fatalf("first Spawn: %v", err)
+	}
+
+	mgr.SetLimits(1, 0, 0)
+	if _, err := mgr.Spawn(ctx, testSpec()); err != domain.ErrWorkerLimitReached {
+		t.Fatalf("expected ErrWorkerLimitReached after lowering limit, got %v", err)
+	}
+
+	mgr.SetLimits(2, 0, 0)
+	if _, err := mgr.Spawn(ctx, testSpec()); err != nil {
+		t.Fatalf("Spawn after raising limit: %v", err)
+	}
+}
+
+func TestWorkerManager_RoleLimits(t *testing.T) {
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	mgr := NewWorkerManager(db, 10)
+	mgr.SetRoleLimits(map[string]int{"reviewer": 2})
+	ctx := context.Background()
+
+	reviewer := testSpec()
+	reviewer.Role = "reviewer"
+	for i := 0; i < 2; i++ {
+		if _, err := mgr.Spawn(ctx, reviewer); err != nil {
+			t.Fatalf("Spawn reviewer %d: %v", i, err)
+		}
+	}
+	if _, err := mgr.Spawn(ctx, reviewer); err != domain.ErrWorkerLimitReached {
+		t.Errorf("third reviewer: expected ErrWorkerLimitReached, got %v", err)
+	}
+	if _, err := mgr.Spawn(ctx, testSpec()); err != nil {
+		t.Errorf("coder should be unaffected by the reviewer limit: %v", err)
+	}
+	other := reviewer
+	other.TaskID = "task-2"
+	if _, err := mgr.Spawn(ctx, other); err != nil {
+		t.Errorf("role limits apply per task: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package team
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// ObjectiveData is the data an objective template is executed with.
+type ObjectiveData struct {
+	TaskID             string
+	Title              string
+	Description        string
+	AcceptanceCriteria string
+	Role               string
+	Phase              domain.Phase
+	// Task names the task in prose: its Title, or its TaskID when untitled.
+	Task string
+}
+
+// objectiveDetails follows every default objective with the task's
+// description and acceptance criteria when they are set.
+const objectiveDetails = `{{if .Description}}
+
+{{.Description}}{{end}}{{if .AcceptanceCriteria}}
+
+Acceptance criteria:
+{{.AcceptanceCriteria}}{{end}}`
+
+// defaultObjectives holds the built-in objective template of each phase.
+var defaultObjectives = map[domain.Phase]string{
+	domain.PhaseA: "As {{.Role}}, clarify the goal and acceptance criteria of {{.Task}}.",
+	domain.PhaseB: "As {{.Role}}, explore the codebase and collect what is needed to design {{.Task}}.",
+	domain.PhaseC: "As {{.Role}}, design the solution for {{.Task}}.",
+	domain.PhaseD: "As {{.Role}}, review the design of {{.Task}} and score it.",
+	domain.PhaseE: "As {{.Role}}, implement {{.Task}} following the reviewed design.",
+	domain.PhaseF: "As {{.Role}}, check the implementation of {{.Task}} against its acceptance criteria.",
+	domain.PhaseG: "As {{.Role}}, test and deliver {{.Task}}.",
+}
+
+// ParseObjectiveTemplates parses per-phase objective templates keyed by
+// phase letter. Phases without an entry use the built-in template.
+func ParseObjectiveTemplates(texts map[string]string) (map[domain.Phase]*template.Template, error) {
+	templates := make(map[domain.Phase]*template.Template, len(defaultObjectives))
+	for phase, text := range defaultObjectives {
+		templates[phase] = template.Must(template.New(string(phase)).Parse(text + objectiveDetails))
+	}
+	for key, text := range texts {
+		phase := domain.Phase(key)
+		if _, ok := defaultObjectives[phase]; !ok {
+			return nil, fmt.Errorf("objective template for unknown phase %q", key)
+		}
+		t, err := template.New(string(phase)).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("objective template for phase %s: %w", phase, err)
+		}
+		templates[phase] = t
+	}
+	return templates, nil
+}
+
+// objective renders the objective of a role-R worker in phase for task.
+func (b *DigestBuilder) objective(task *domain.FlowState, phase domain.Phase, role string) (string, error) {
+	t, ok := b.Objectives[phase]
+	if !ok {
+		return fmt.Sprintf("[%s] worker in phase %s", role, string(phase)), nil
+	}
+	data := ObjectiveData{
+		TaskID:             task.TaskID,
+		Title:              task.Title,
+		Description:        task.Description,
+		AcceptanceCriteria: task.AcceptanceCriteria,
+		Role:               role,
+		Phase:              phase,
+		Task:               task.Title,
+	}
+	if data.Task == "" {
+		data.Task = "task " + task.TaskID
+	}
+	var out strings.Builder
+	if err := t.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("render objective: %w", err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,94 @@
+package team
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+func TestParseObjectiveTemplates_UnknownPhase(t *testing.T) {
+	if _, err := ParseObjectiveTemplates(map[string]string{"Z": "x"}); err == nil {
+		t.Error("expected error for unknown phase")
+	}
+	if _, err := ParseObjectiveTemplates(map[string]string{"C": "{{.Role"}); err == nil {
+		t.Error("expected error for malformed template")
+	}
+}
+
+func TestDigestBuilder_TaskMetadata(t *testing.T) {
+	dir := t.TempDir()
+	db, err := store.NewDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	err = (&store.TaskRepo{}).CreateTx(ctx, tx, domain.FlowState{
+		TaskID:             "task-1",
+		CurrentPhase:       domain.PhaseE,
+		Status:             domain.StatusRunning,
+		StateVersion:       1,
+		UpdatedAtUnix:      time.Now().Unix(),
+		Title:              "rate limiter",
+		Description:        "Add a token bucket in front of the API.",
+		AcceptanceCriteria: "429 after the burst is spent",
+	})
+	if err != nil {
+		t.Fatalf("CreateTx: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if err := (&store.ConstraintRepo{}).Create(ctx, db, domain.Constraint{
+		ConstraintID: "con-1", TaskID: "task-1", Text: "do not change the public API", CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("Create constraint: %v", err)
+	}
+	standards := filepath.Join(dir, "STANDARDS.md")
+	if err := os.WriteFile(standards, []byte("Wrap errors with %w."), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	builder := NewDigestBuilder(db)
+	builder.StandardsFile = standards
+	spec := domain.WorkerSpec{TaskID: "task-1", Phase: domain.PhaseE, Role: "coder"}
+
+	digest, err := builder.Build(ctx, "task-1", domain.PhaseE, spec)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	for _, want := range []string{"As coder, implement rate limiter", "token bucket", "Acceptance criteria:\n429"} {
+		if !strings.Contains(digest.Objective, want) {
+			t.Errorf("Objective missing %q:\n%s", want, digest.Objective)
+		}
+	}
+	if digest.Constraints[0] != "do not change the public API" {
+		t.Errorf("Constraints = %v, want task constraint first", digest.Constraints)
+	}
+	if digest.CodingStandards != "Wrap errors with %w." {
+		t.Errorf("CodingStandards = %q", digest.CodingStandards)
+	}
+
+	builder.Objectives, err = ParseObjectiveTemplates(map[string]string{"E": "Build {{.Task}} ({{.TaskID}})"})
+	if err != nil {
+		t.Fatalf("ParseObjectiveTemplates: %v", err)
+	}
+	digest, err = builder.Build(ctx, "task-1", domain.PhaseE, spec)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if digest.Objective != "Build rate limiter (task-1)" {
+		t.Errorf("custom Objective = %q", digest.Objective)
+	}
+}
@@ -0,0 +1,140 @@
+package team
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ListFiles returns the files under root as slash-separated relative paths,
+// skipping hidden directories such as .git and .threebody.
+func ListFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+// dirNode is one directory of the tree being partitioned.
+type dirNode struct {
+	path     string // "" for the root, otherwise "a/b"
+	direct   int    // files directly inside
+	total    int    // files in the whole subtree
+	children map[string]*dirNode
+}
+
+// cluster is a unit of ownership: a whole directory subtree ("src/") or the
+// files directly inside one directory ("src/*").
+type cluster struct {
+	pattern string
+	files   int
+	node    *dirNode // set when the cluster is a whole subtree
+}
+
+// PartitionOwnership splits files into n non-overlapping FileOwnership sets
+// of roughly equal size. Directories are kept whole where possible: the
+// largest directory is repeatedly split into its subdirectories (plus a
+// "dir/*" glob for its own files) until there are at least n clusters and
+// none larger than an even share, and the clusters are then assigned largest
+// first to the least loaded set.
+// Sets may be empty when the tree has fewer clusters than n. The result is
+// deterministic for a given file list.
+func PartitionOwnership(files []string, n int) [][]string {
+	if n <= 0 {
+		return nil
+	}
+	sets := make([][]string, n)
+	for i := range sets {
+		sets[i] = []string{}
+	}
+	if len(files) == 0 {
+		return sets
+	}
+
+	root := &dirNode{children: make(map[string]*dirNode)}
+	for _, f := range files {
+		node := root
+		node.total++
+		parts := strings.Split(normalizePath(f), "/")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node.children[part]
+			if !ok {
+				child = &dirNode{path: strings.TrimPrefix(node.path+"/"+part, "/"), children: make(map[string]*dirNode)}
+				node.children[part] = child
+			}
+			node = child
+			node.total++
+		}
+		node.direct++
+	}
+
+	share := (root.total + n - 1) / n
+	clusters := []cluster{{pattern: "./", files: root.total, node: root}}
+	for {
+		split := -1
+		for i, c := range clusters {
+			if c.node != nil && len(c.node.children) > 0 && (split < 0 || c.files > clusters[split].files) {
+				split = i
+			}
+		}
+		if split < 0 || (len(clusters) >= n && clusters[split].files <= share) {
+			break
+		}
+		node := clusters[split].node
+		clusters = append(clusters[:split], clusters[split+1:]...)
+		if node.direct > 0 {
+			pattern := "*"
+			if node.path != "" {
+				pattern = node.path + "/*"
+			}
+			clusters = append(clusters, cluster{pattern: pattern, files: node.direct})
+		}
+		names := make([]string, 0, len(node.children))
+		for name := range node.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := node.children[name]
+			clusters = append(clusters, cluster{pattern: child.path + "/", files: child.total, node: child})
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].files != clusters[j].files {
+			return clusters[i].files > clusters[j].files
+		}
+		return clusters[i].pattern < clusters[j].pattern
+	})
+	load := make([]int, n)
+	for _, c := range clusters {
+		least := 0
+		for i := range load {
+			if load[i] < load[least] {
+				least = i
+			}
+		}
+		sets[least] = append(sets[least], c.pattern)
+		load[least] += c.files
+	}
+	for _, s := range sets {
+		sort.Strings(s)
+	}
+	return sets
+}
@@ -0,0 +1,85 @@
+package team
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPartitionOwnership_SplitsByDirectory(t *testing.T) {
+	files := []string{
+		"go.mod", "README.md",
+		"cmd/app/main.go",
+		"internal/api/handler.go", "internal/api/routes.go",
+		"internal/store/db.go", "internal/store/repo.go", "internal/store/cache.go",
+		"internal/doc.go",
+	}
+	sets := PartitionOwnership(files, 3)
+	want := [][]string{
+		{"internal/store/"},
+		{"*", "cmd/"},
+		{"internal/*", "internal/api/"},
+	}
+	if !reflect.DeepEqual(sets, want) {
+		t.Fatalf("sets = %v, want %v", sets, want)
+	}
+	assertPartition(t, files, sets)
+}
+
+func TestPartitionOwnership_FewerClustersThanWorkers(t *testing.T) {
+	sets := PartitionOwnership([]string{"a.go", "b.go"}, 3)
+	if len(sets) != 3 {
+		t.Fatalf("len = %d, want 3", len(sets))
+	}
+	if !reflect.DeepEqual(sets[0], []string{"./"}) || len(sets[1]) != 0 || len(sets[2]) != 0 {
+		t.Errorf("sets = %v, want everything in the first set", sets)
+	}
+}
+
+func TestPartitionOwnership_Deterministic(t *testing.T) {
+	files := []string{"a/1", "b/1", "c/1", "d/1", "e/1", "f/1"}
+	first := PartitionOwnership(files, 4)
+	for i := 0; i < 20; i++ {
+		if again := PartitionOwnership(files, 4); !reflect.DeepEqual(again, first) {
+			t.Fatalf("run %d = %v, want %v", i, again, first)
+		}
+	}
+	assertPartition(t, files, first)
+}
+
+func TestListFiles_SkipsHiddenDirectories(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"main.go", "pkg/util.go", ".git/config", ".threebody/t1/digest.json", ".env"} {
+		path := filepath.Join(root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := ListFiles(root)
+	if err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+	if want := []string{".env", "main.go", "pkg/util.go"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+}
+
+// assertPartition checks that every file is owned by exactly one set.
+func assertPartition(t *testing.T, files []string, sets [][]string) {
+	t.Helper()
+	for _, f := range files {
+		owners := 0
+		for _, set := range sets {
+			if m, _ := bestMatch(set, f); m.kind != matchNone {
+				owners++
+			}
+		}
+		if owners != 1 {
+			t.Errorf("%s has %d owners, want 1", f, owners)
+		}
+	}
+}
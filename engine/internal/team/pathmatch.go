@@ -0,0 +1,161 @@
+package team
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// matchKind says how a path pattern matched a file. Kinds are ordered by
+// precedence: when several patterns match, the higher kind is more specific.
+type matchKind int
+
+const (
+	matchNone matchKind = iota
+	// matchDir: the pattern names a directory containing the file, either
+	// with a trailing slash ("src/") or as a plain ancestor path ("src").
+	// "." and "./" contain every relative path.
+	matchDir
+	// matchGlob: the pattern contains *, ? or [ and matches per path.Match;
+	// a "**" segment matches any number of segments, including none.
+	matchGlob
+	// matchExact: the pattern names the file itself.
+	matchExact
+)
+
+// pathMatch is the result of matching one pattern against a file.
+type pathMatch struct {
+	kind matchKind
+	// weight breaks ties within a kind: the number of literal characters in
+	// the pattern, so "src/internal/" beats "src/" and "src/*.go" beats "*.go".
+	weight int
+}
+
+// beats reports whether m takes precedence over other.
+func (m pathMatch) beats(other pathMatch) bool {
+	if m.kind != other.kind {
+		return m.kind > other.kind
+	}
+	return m.weight > other.weight
+}
+
+// normalizePath converts p to a clean slash-separated relative form.
+func normalizePath(p string) string {
+	p = path.Clean(filepath.ToSlash(p))
+	return strings.TrimPrefix(p, "./")
+}
+
+// matchPath matches target against pattern using the ownership and
+// permission semantics described on matchKind. Both are normalised first, so
+// "./src/" and "src" name the same directory. It returns an error only for a
+// malformed glob.
+func matchPath(pattern, target string) (pathMatch, error) {
+	target = normalizePath(target)
+	isDir := strings.HasSuffix(pattern, "/")
+	pattern = normalizePath(pattern)
+
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, err := matchSegments(strings.Split(pattern, "/"), strings.Split(target, "/"))
+		if err != nil || !ok {
+			return pathMatch{}, err
+		}
+		return pathMatch{kind: matchGlob, weight: literalLen(pattern)}, nil
+	}
+	if pattern == target && !isDir {
+		return pathMatch{kind: matchExact, weight: len(pattern)}, nil
+	}
+	if pattern == "." {
+		if target == ".." || strings.HasPrefix(target, "../") || strings.HasPrefix(target, "/") {
+			return pathMatch{}, nil
+		}
+		return pathMatch{kind: matchDir}, nil
+	}
+	if pattern == target || strings.HasPrefix(target, strings.TrimSuffix(pattern, "/")+"/") {
+		return pathMatch{kind: matchDir, weight: len(pattern)}, nil
+	}
+	return pathMatch{}, nil
+}
+
+// matchSegments matches path segments against pattern segments, expanding "**".
+func matchSegments(pattern, segs []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				ok, err := matchSegments(pattern[1:], segs[i:])
+				if err != nil || ok {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(segs) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pattern[0], segs[0])
+		if err != nil || !ok {
+			return false, err
+		}
+		pattern, segs = pattern[1:], segs[1:]
+	}
+	return len(segs) == 0, nil
+}
+
+// literalLen counts the characters of a glob that are not wildcards.
+func literalLen(pattern string) int {
+	n := 0
+	inClass := false
+	for _, c := range pattern {
+		switch {
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+		case c != '*' && c != '?':
+			n++
+		}
+	}
+	return n
+}
+
+// bestMatch returns the most specific match of target among patterns.
+func bestMatch(patterns []string, target string) (pathMatch, error) {
+	var best pathMatch
+	for _, p := range patterns {
+		m, err := matchPath(p, target)
+		if err != nil {
+			return pathMatch{}, err
+		}
+		if m.beats(best) {
+			best = m
+		}
+	}
+	return best, nil
+}
+
+// ownsFile reports whether worker may modify target. One of the worker's
+// ownership patterns must match, and no other worker in peers may hold a
+// strictly more specific match: an exact path beats a glob, a glob beats a
+// directory, and within a kind the pattern with more literal characters wins.
+// Workers whose best matches are equally specific share the file; intent
+// locks still serialise their writes.
+func ownsFile(worker *domain.WorkerRef, peers []*domain.WorkerRef, target string) (bool, error) {
+	own, err := bestMatch(worker.FileOwnership, target)
+	if err != nil || own.kind == matchNone {
+		return false, err
+	}
+	for _, p := range peers {
+		if p.WorkerID == worker.WorkerID {
+			continue
+		}
+		other, err := bestMatch(p.FileOwnership, target)
+		if err != nil {
+			return false, err
+		}
+		if other.beats(own) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
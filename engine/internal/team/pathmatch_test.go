@@ -0,0 +1,88 @@
+package team
+
+import (
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, target string
+		want            matchKind
+	}{
+		{"src/main.go", "src/main.go", matchExact},
+		{"./src/main.go", "src/main.go", matchExact},
+		{"src/main.go", "src/main.go.bak", matchNone},
+		{"src/", "src/main.go", matchDir},
+		{"src", "src/pkg/util.go", matchDir},
+		{"src/", "srcfoo/main.go", matchNone},
+		{"./", "any/file.go", matchDir},
+		{"./", "../outside.go", matchNone},
+		{"/workspace/", "/workspace/a/b.go", matchDir},
+		{"src/*.go", "src/main.go", matchGlob},
+		{"src/*.go", "src/pkg/util.go", matchNone},
+		{"src/**/*.go", "src/main.go", matchGlob},
+		{"src/**/*.go", "src/a/b/c.go", matchGlob},
+		{"src/**", "src/a/b/c.go", matchGlob},
+		{"**/*_test.go", "a/b_test.go", matchGlob},
+		{"src/[ab].go", "src/a.go", matchGlob},
+	}
+	for _, tt := range tests {
+		got, err := matchPath(tt.pattern, tt.target)
+		if err != nil {
+			t.Errorf("matchPath(%q, %q): %v", tt.pattern, tt.target, err)
+			continue
+		}
+		if got.kind != tt.want {
+			t.Errorf("matchPath(%q, %q) kind = %d, want %d", tt.pattern, tt.target, got.kind, tt.want)
+		}
+	}
+
+	if _, err := matchPath("src/[", "src/a"); err == nil {
+		t.Error("expected error for malformed glob")
+	}
+}
+
+func TestOwnsFile_Precedence(t *testing.T) {
+	dir := &domain.WorkerRef{WorkerID: "dir", FileOwnership: []string{"src/"}}
+	nested := &domain.WorkerRef{WorkerID: "nested", FileOwnership: []string{"src/pkg/"}}
+	glob := &domain.WorkerRef{WorkerID: "glob", FileOwnership: []string{"src/**/*_test.go"}}
+	exact := &domain.WorkerRef{WorkerID: "exact", FileOwnership: []string{"src/pkg/util.go"}}
+	shared := &domain.WorkerRef{WorkerID: "shared", FileOwnership: []string{"src"}}
+	peers := []*domain.WorkerRef{dir, nested, glob, exact}
+
+	tests := []struct {
+		worker *domain.WorkerRef
+		peers  []*domain.WorkerRef
+		target string
+		want   bool
+	}{
+		// Only the directory owner matches.
+		{dir, peers, "src/main.go", true},
+		// A deeper directory beats a shallower one.
+		{dir, peers, "src/pkg/io.go", false},
+		{nested, peers, "src/pkg/io.go", true},
+		// A glob beats any directory.
+		{nested, peers, "src/pkg/io_test.go", false},
+		{glob, peers, "src/pkg/io_test.go", true},
+		// An exact path beats everything.
+		{glob, peers, "src/pkg/util.go", false},
+		{nested, peers, "src/pkg/util.go", false},
+		{exact, peers, "src/pkg/util.go", true},
+		// Equally specific owners share the file.
+		{dir, []*domain.WorkerRef{dir, shared}, "src/main.go", true},
+		{shared, []*domain.WorkerRef{dir, shared}, "src/main.go", true},
+		// No matching pattern.
+		{exact, peers, "README.md", false},
+	}
+	for _, tt := range tests {
+		got, err := ownsFile(tt.worker, tt.peers, tt.target)
+		if err != nil {
+			t.Fatalf("ownsFile(%s, %q): %v", tt.worker.WorkerID, tt.target, err)
+		}
+		if got != tt.want {
+			t.Errorf("ownsFile(%s, %q) = %v, want %v", tt.worker.WorkerID, tt.target, got, tt.want)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"fmt"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/anthropics/three-body-engine/internal/domain"
@@ -16,6 +15,10 @@ import (
 type PermissionBroker struct {
 	AuditRepo *store.AuditRepo
 	DB        *sql.DB
+	// Policy, if set, returns the configured policy for a task. Its denied
+	// patterns add to domain.DefaultDeniedPatterns, and its allowed paths and
+	// commands bound every sheet checked for the task.
+	Policy func(taskID string) domain.CapabilityPolicy
 }
 
 // NewPermissionBroker creates a PermissionBroker with default repos.
@@ -26,60 +29,89 @@ func NewPermissionBroker(db *sql.DB) *PermissionBroker {
 	}
 }
 
-// defaultDeniedPatterns are file patterns that are always denied.
-var defaultDeniedPatterns = []string{".env", "*.key", ".git/*"}
+// EffectivePolicy returns the policy applied to taskID: the default denied
+// patterns plus any configured ones, and the configured allowed paths and
+// commands. Empty allowed lists leave the sheet's own lists unbounded.
+func (p *PermissionBroker) EffectivePolicy(taskID string) domain.CapabilityPolicy {
+	var cfg domain.CapabilityPolicy
+	if p.Policy != nil {
+		cfg = p.Policy(taskID)
+	}
+	policy := domain.CapabilityPolicy{
+		TaskID:          taskID,
+		DeniedPatterns:  append(append([]string(nil), domain.DefaultDeniedPatterns...), cfg.DeniedPatterns...),
+		AllowedPaths:    cfg.AllowedPaths,
+		AllowedCommands: cfg.AllowedCommands,
+	}
+	return policy
+}
 
 // BuildCapabilitySheet creates a capability sheet with the given allowed paths and commands,
-// plus default denied patterns.
+// plus the task's effective denied patterns.
 func (p *PermissionBroker) BuildCapabilitySheet(taskID string, paths, commands []string) *domain.CapabilitySheet {
 	return &domain.CapabilitySheet{
 		TaskID:          taskID,
 		AllowedPaths:    paths,
 		AllowedCommands: commands,
-		DeniedPatterns:  defaultDeniedPatterns,
+		DeniedPatterns:  p.EffectivePolicy(taskID).DeniedPatterns,
 		CreatedAtUnix:   time.Now().Unix(),
 	}
 }
 
 // CheckPermission verifies whether a path and command are allowed by the capability sheet.
+// The task's effective policy applies on top of the sheet, so a sheet stored before the
+// policy changed cannot allow what the policy denies.
 // Returns (true, nil) if allowed, (false, nil) if denied. Denied attempts are audited.
 func (p *PermissionBroker) CheckPermission(ctx context.Context, sheet *domain.CapabilitySheet, path, command string) (bool, error) {
-	for _, pattern := range sheet.DeniedPatterns {
+	reason, err := p.DenialReason(sheet, path, command)
+	if err != nil {
+		return false, err
+	}
+	if reason != "" {
+		p.auditDenial(ctx, sheet.TaskID, path, command, reason)
+		return false, nil
+	}
+	return true, nil
+}
+
+// DenialReason applies the checks of CheckPermission without auditing and
+// returns why the path and command are denied, or "" if they are allowed.
+func (p *PermissionBroker) DenialReason(sheet *domain.CapabilitySheet, path, command string) (string, error) {
+	policy := p.EffectivePolicy(sheet.TaskID)
+	for _, pattern := range append(policy.DeniedPatterns, sheet.DeniedPatterns...) {
 		matched, err := matchPattern(pattern, path)
 		if err != nil {
-			return false, fmt.Errorf("match denied pattern %q: %w", pattern, err)
+			return "", fmt.Errorf("match denied pattern %q: %w", pattern, err)
 		}
 		if matched {
-			p.auditDenial(ctx, sheet.TaskID, path, command, "denied by pattern: "+pattern)
-			return false, nil
+			return "denied by pattern: " + pattern, nil
 		}
 	}
 
-	pathAllowed := false
-	for _, allowed := range sheet.AllowedPaths {
-		if strings.HasPrefix(path, allowed) {
-			pathAllowed = true
-			break
-		}
+	best, err := bestMatch(sheet.AllowedPaths, path)
+	if err != nil {
+		return "", fmt.Errorf("match allowed paths: %w", err)
 	}
-	if !pathAllowed {
-		p.auditDenial(ctx, sheet.TaskID, path, command, "path not in allowed list")
-		return false, nil
+	if best.kind == matchNone {
+		return "path not in allowed list", nil
 	}
-
-	cmdAllowed := false
-	for _, allowed := range sheet.AllowedCommands {
-		if command == allowed {
-			cmdAllowed = true
-			break
+	if len(policy.AllowedPaths) > 0 {
+		if best, err = bestMatch(policy.AllowedPaths, path); err != nil {
+			return "", fmt.Errorf("match policy paths: %w", err)
+		}
+		if best.kind == matchNone {
+			return "path not allowed by policy", nil
 		}
 	}
-	if !cmdAllowed {
-		p.auditDenial(ctx, sheet.TaskID, path, command, "command not in allowed list")
-		return false, nil
+	if len(policy.AllowedCommands) > 0 && !contains(policy.AllowedCommands, command) {
+		return "command not allowed by policy", nil
 	}
 
-	return true, nil
+	if !contains(sheet.AllowedCommands, command) {
+		return "command not in allowed list", nil
+	}
+
+	return "", nil
 }
 
 func (p *PermissionBroker) auditDenial(ctx context.Context, taskID, path, command, reason string) {
@@ -97,33 +129,26 @@ func (p *PermissionBroker) auditDenial(ctx context.Context, taskID, path, comman
 	})
 }
 
-// matchPattern checks if a path matches a denied pattern.
-// Supports exact match (e.g., ".env"), glob match via filepath.Match, and prefix match for directory patterns.
+// matchPattern checks if a path matches a denied pattern. A pattern matches
+// if matchPath accepts the full path, or if it matches the path's base name
+// exactly or as a glob, so ".env" and "*.key" apply in every directory.
 func matchPattern(pattern, path string) (bool, error) {
-	// Exact match
-	if path == pattern {
-		return true, nil
-	}
-
-	// Base name match (e.g., ".env" matches "some/dir/.env")
-	base := filepath.Base(path)
-	if base == pattern {
-		return true, nil
-	}
-
-	// Glob match on the full path
-	matched, err := filepath.Match(pattern, path)
+	m, err := matchPath(pattern, path)
 	if err != nil {
 		return false, err
 	}
-	if matched {
+	if m.kind != matchNone {
 		return true, nil
 	}
+	return filepath.Match(pattern, filepath.Base(path))
+}
 
-	// Glob match on the base name
-	matched, err = filepath.Match(pattern, base)
-	if err != nil {
-		return false, err
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
 	}
-	return matched, nil
+	return false
 }
@@ -53,7 +53,7 @@ func TestPermissionBroker_AllowsValidPathAndCommand(t *testing.T) {
 		TaskID:          "task-1",
 		AllowedPaths:    []string{"src/"},
 		AllowedCommands: []string{"read", "write"},
-		DeniedPatterns:  defaultDeniedPatterns,
+		DeniedPatterns:  domain.DefaultDeniedPatterns,
 	}
 
 	allowed, err := broker.CheckPermission(context.Background(), sheet, "src/main.go", "read")
@@ -78,7 +78,7 @@ func TestPermissionBroker_DeniesPathNotInAllowed(t *testing.T) {
 		TaskID:          "task-1",
 		AllowedPaths:    []string{"src/"},
 		AllowedCommands: []string{"read"},
-		DeniedPatterns:  defaultDeniedPatterns,
+		DeniedPatterns:  domain.DefaultDeniedPatterns,
 	}
 
 	allowed, err := broker.CheckPermission(context.Background(), sheet, "secret/data.txt", "read")
@@ -103,7 +103,7 @@ func TestPermissionBroker_DeniesCommandNotInAllowed(t *testing.T) {
 		TaskID:          "task-1",
 		AllowedPaths:    []string{"src/"},
 		AllowedCommands: []string{"read"},
-		DeniedPatterns:  defaultDeniedPatterns,
+		DeniedPatterns:  domain.DefaultDeniedPatterns,
 	}
 
 	allowed, err := broker.CheckPermission(context.Background(), sheet, "src/main.go", "delete")
@@ -128,7 +128,7 @@ func TestPermissionBroker_DeniesEnvPaths(t *testing.T) {
 		TaskID:          "task-1",
 		AllowedPaths:    []string{"./"},
 		AllowedCommands: []string{"read"},
-		DeniedPatterns:  defaultDeniedPatterns,
+		DeniedPatterns:  domain.DefaultDeniedPatterns,
 	}
 
 	allowed, err := broker.CheckPermission(context.Background(), sheet, ".env", "read")
@@ -153,7 +153,7 @@ func TestPermissionBroker_AuditsDenials(t *testing.T) {
 		TaskID:          "task-1",
 		AllowedPaths:    []string{"src/"},
 		AllowedCommands: []string{"read"},
-		DeniedPatterns:  defaultDeniedPatterns,
+		DeniedPatterns:  domain.DefaultDeniedPatterns,
 	}
 
 	_, _ = broker.CheckPermission(context.Background(), sheet, "forbidden/file.go", "read")
@@ -177,3 +177,122 @@ func TestPermissionBroker_AuditsDenials(t *testing.T) {
 		t.Error("expected audit record with action=permission_denied and severity=warning")
 	}
 }
+
+func TestPermissionBroker_DenialReasonDoesNotAudit(t *testing.T) {
+	dir := t.TempDir()
+	db, err := store.NewDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	broker := NewPermissionBroker(db)
+	sheet := &domain.CapabilitySheet{
+		TaskID:          "task-1",
+		AllowedPaths:    []string{"src/"},
+		AllowedCommands: []string{"read"},
+	}
+
+	for _, tc := range []struct{ path, command, want string }{
+		{"src/main.go", "read", ""},
+		{"forbidden/file.go", "read", "path not in allowed list"},
+		{"src/main.go", "rm", "command not in allowed list"},
+		{"src/.env", "read", "denied by pattern: .env"},
+	} {
+		reason, err := broker.DenialReason(sheet, tc.path, tc.command)
+		if err != nil || reason != tc.want {
+			t.Errorf("DenialReason(%q, %q) = %q, %v; want %q", tc.path, tc.command, reason, err, tc.want)
+		}
+	}
+
+	records, _ := (&store.AuditRepo{}).ListByTask(context.Background(), db, "task-1")
+	if len(records) != 0 {
+		t.Errorf("audit records = %d, want none", len(records))
+	}
+}
+
+func TestPermissionBroker_GlobAllowedPath(t *testing.T) {
+	dir := t.TempDir()
+	db, err := store.NewDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	broker := NewPermissionBroker(db)
+	sheet := &domain.CapabilitySheet{
+		TaskID:          "task-1",
+		AllowedPaths:    []string{"src/**/*.go"},
+		AllowedCommands: []string{"read"},
+		DeniedPatterns:  domain.DefaultDeniedPatterns,
+	}
+
+	for path, want := range map[string]bool{
+		"src/main.go":     true,
+		"src/pkg/util.go": true,
+		"src/README.md":   false,
+		"srcfoo/main.go":  false,
+	} {
+		allowed, err := broker.CheckPermission(context.Background(), sheet, path, "read")
+		if err != nil {
+			t.Fatalf("CheckPermission(%q): %v", path, err)
+		}
+		if allowed != want {
+			t.Errorf("CheckPermission(%q) = %v, want %v", path, allowed, want)
+		}
+	}
+}
+
+func TestPermissionBroker_Policy(t *testing.T) {
+	dir := t.TempDir()
+	db, err := store.NewDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	broker := NewPermissionBroker(db)
+	broker.Policy = func(taskID string) domain.CapabilityPolicy {
+		return domain.CapabilityPolicy{
+			DeniedPatterns:  []string{"secrets/"},
+			AllowedPaths:    []string{"src/", "docs/"},
+			AllowedCommands: []string{"read"},
+		}
+	}
+
+	policy := broker.EffectivePolicy("task-1")
+	if len(policy.DeniedPatterns) != len(domain.DefaultDeniedPatterns)+1 || policy.TaskID != "task-1" {
+		t.Errorf("EffectivePolicy = %+v, want defaults plus secrets/", policy)
+	}
+
+	// A sheet stored before the policy allows everything; the policy still
+	// bounds it.
+	sheet := &domain.CapabilitySheet{
+		TaskID:          "task-1",
+		AllowedPaths:    []string{"./"},
+		AllowedCommands: []string{"read", "write"},
+	}
+	for _, tc := range []struct {
+		path, command string
+		want          bool
+	}{
+		{"src/main.go", "read", true},
+		{"src/main.go", "write", false},
+		{"secrets/prod.json", "read", false},
+		{"build/out.bin", "read", false},
+		{"docs/.env", "read", false},
+	} {
+		allowed, err := broker.CheckPermission(context.Background(), sheet, tc.path, tc.command)
+		if err != nil {
+			t.Fatalf("CheckPermission(%q): %v", tc.path, err)
+		}
+		if allowed != tc.want {
+			t.Errorf("CheckPermission(%q, %q) = %v, want %v", tc.path, tc.command, allowed, tc.want)
+		}
+	}
+
+	built := broker.BuildCapabilitySheet("task-1", []string{"./"}, []string{"read"})
+	if built.DeniedPatterns[len(built.DeniedPatterns)-1] != "secrets/" {
+		t.Errorf("DeniedPatterns = %v, want policy patterns", built.DeniedPatterns)
+	}
+}
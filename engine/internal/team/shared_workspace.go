@@ -0,0 +1,120 @@
+package team
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// SharedWorkspaces resolves intents to absolute paths so that flows working
+// in the same directory see each other's file locks. Intent target files are
+// relative to the flow's own workspace, or to Default for flows without one.
+type SharedWorkspaces struct {
+	DB         *sql.DB
+	TaskRepo   *store.TaskRepo
+	IntentRepo *store.IntentRepo
+	Default    string
+}
+
+// NewSharedWorkspaces creates a SharedWorkspaces for flows that default to
+// the workspace dir.
+func NewSharedWorkspaces(db *sql.DB, dir string) *SharedWorkspaces {
+	return &SharedWorkspaces{
+		DB:         db,
+		TaskRepo:   &store.TaskRepo{},
+		IntentRepo: &store.IntentRepo{},
+		Default:    dir,
+	}
+}
+
+// Path returns the absolute path of intent's target file.
+func (s *SharedWorkspaces) Path(ctx context.Context, intent domain.Intent) (string, error) {
+	root, err := s.root(ctx, intent.TaskID, nil)
+	if err != nil {
+		return "", err
+	}
+	return absPath(root, intent.TargetFile)
+}
+
+// Holders returns the active intents of other tasks on the same absolute
+// path as intent.
+func (s *SharedWorkspaces) Holders(ctx context.Context, intent domain.Intent) ([]domain.Intent, error) {
+	target, err := s.Path(ctx, intent)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := s.byPath(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var holders []domain.Intent
+	for _, other := range paths[target] {
+		if other.TaskID != intent.TaskID {
+			holders = append(holders, other)
+		}
+	}
+	return holders, nil
+}
+
+// byPath groups every task's active intents by absolute path.
+func (s *SharedWorkspaces) byPath(ctx context.Context) (map[string][]domain.Intent, error) {
+	active, err := s.IntentRepo.ListActive(ctx, s.DB)
+	if err != nil {
+		return nil, err
+	}
+	roots := make(map[string]string)
+	paths := make(map[string][]domain.Intent)
+	for _, intent := range active {
+		p, err := s.abs(ctx, intent, roots)
+		if err != nil {
+			return nil, err
+		}
+		paths[p] = append(paths[p], intent)
+	}
+	return paths, nil
+}
+
+func (s *SharedWorkspaces) abs(ctx context.Context, intent domain.Intent, roots map[string]string) (string, error) {
+	root, err := s.root(ctx, intent.TaskID, roots)
+	if err != nil {
+		return "", err
+	}
+	return absPath(root, intent.TargetFile)
+}
+
+// root returns the workspace of taskID, memoised in cache when non-nil.
+// Intents of unknown tasks are resolved against Default.
+func (s *SharedWorkspaces) root(ctx context.Context, taskID string, cache map[string]string) (string, error) {
+	if root, ok := cache[taskID]; ok {
+		return root, nil
+	}
+	root := s.Default
+	state, err := s.TaskRepo.GetByID(ctx, s.DB, taskID)
+	switch {
+	case err == nil:
+		if state.Workspace != "" {
+			root = state.Workspace
+		}
+	case err != domain.ErrFlowNotFound:
+		return "", fmt.Errorf("get task: %w", err)
+	}
+	if cache != nil {
+		cache[taskID] = root
+	}
+	return root, nil
+}
+
+func absPath(root, target string) (string, error) {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	p, err := filepath.Abs(target)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", target, err)
+	}
+	return p, nil
+}
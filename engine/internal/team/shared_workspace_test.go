@@ -0,0 +1,94 @@
+package team
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// sharedResolver returns a resolver whose flows default to root, with
+// task-2 working explicitly in root and task-3 in a worktree of its own.
+func sharedResolver(t *testing.T, root string) (*IntentResolver, map[string]*domain.WorkerRef) {
+	t.Helper()
+	resolver, mgr := newResolverTestDB(t)
+	resolver.Shared = NewSharedWorkspaces(resolver.DB, root)
+	ctx := context.Background()
+
+	tx, err := resolver.DB.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	for taskID, ws := range map[string]string{"task-2": root, "task-3": filepath.Join(root, "worktree")} {
+		state := domain.FlowState{TaskID: taskID, CurrentPhase: domain.PhaseC, Status: domain.StatusRunning, StateVersion: 1, BudgetCapUSD: 10, Workspace: ws}
+		if err := resolver.Shared.TaskRepo.CreateTx(ctx, tx, state); err != nil {
+			t.Fatalf("CreateTx: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	workers := make(map[string]*domain.WorkerRef)
+	for _, taskID := range []string{"task-1", "task-2", "task-3"} {
+		w, err := mgr.Spawn(ctx, domain.WorkerSpec{TaskID: taskID, Phase: domain.PhaseC, Role: "coder", FileOwnership: []string{"./"}})
+		if err != nil {
+			t.Fatalf("Spawn: %v", err)
+		}
+		workers[taskID] = w
+	}
+	return resolver, workers
+}
+
+func TestAcquireLock_CrossTaskConflict(t *testing.T) {
+	root := t.TempDir()
+	resolver, workers := sharedResolver(t, root)
+	ctx := context.Background()
+
+	lock := func(id, taskID, file string) error {
+		return resolver.AcquireLock(ctx, domain.Intent{
+			IntentID: id, TaskID: taskID, WorkerID: workers[taskID].WorkerID,
+			TargetFile: file, Operation: "write",
+		}, 60)
+	}
+	if err := lock("int-1", "task-1", "src/main.go"); err != nil {
+		t.Fatalf("AcquireLock task-1: %v", err)
+	}
+	// task-2 names the same file by another relative path.
+	if err := lock("int-2", "task-2", "./src/../src/main.go"); err != domain.ErrCrossTaskConflict {
+		t.Fatalf("AcquireLock task-2 = %v, want ErrCrossTaskConflict", err)
+	}
+	// task-3 works in its own directory.
+	if err := lock("int-3", "task-3", "src/main.go"); err != nil {
+		t.Fatalf("AcquireLock task-3: %v", err)
+	}
+
+	records, err := resolver.AuditRepo.ListByTask(ctx, resolver.DB, "task-2")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	found := false
+	for _, r := range records {
+		if r.Action == "cross_task_conflict" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected a cross_task_conflict audit record on task-2")
+	}
+
+	detector := NewConflictDetector(resolver.DB)
+	detector.Shared = resolver.Shared
+	insertTestIntent(t, detector, domain.Intent{
+		IntentID: "int-4", TaskID: "task-2", WorkerID: workers["task-2"].WorkerID,
+		TargetFile: filepath.Join(root, "src", "main.go"), Operation: "delete", Status: "pending",
+	})
+	conflicts, err := detector.DetectAcrossTasks(ctx)
+	if err != nil {
+		t.Fatalf("DetectAcrossTasks: %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Type != ConflictDelete || conflicts[0].File != filepath.Join(root, "src", "main.go") {
+		t.Errorf("conflicts = %+v, want one delete conflict on src/main.go", conflicts)
+	}
+}
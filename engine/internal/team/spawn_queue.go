@@ -0,0 +1,148 @@
+package team
+
+import (
+	"context"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// WorkerQueueStats describes the spawn queue of a WorkerManager.
+type WorkerQueueStats struct {
+	// Waiting is the current queue depth and ByRole splits it by role.
+	Waiting int            `json:"waiting"`
+	ByRole  map[string]int `json:"byRole"`
+	// Peak is the deepest the queue has been since start.
+	Peak int `json:"peak"`
+	// Granted counts waiters that got a worker; AvgWaitMS is their mean
+	// time in the queue.
+	Granted   int64 `json:"granted"`
+	AvgWaitMS int64 `json:"avgWaitMs"`
+
+	totalWait time.Duration
+}
+
+// spawnWaiter is one SpawnWait call waiting for a slot.
+type spawnWaiter struct {
+	spec     domain.WorkerSpec
+	queuedAt time.Time
+	done     chan spawnResult
+}
+
+type spawnResult struct {
+	worker *domain.WorkerRef
+	err    error
+}
+
+// SpawnWait spawns a worker like Spawn but, when Queue is set and a limit is
+// reached, waits in line behind earlier waiters for a slot until ctx is done.
+func (m *WorkerManager) SpawnWait(ctx context.Context, spec domain.WorkerSpec) (*domain.WorkerRef, error) {
+	if !m.Queue {
+		return m.Spawn(ctx, spec)
+	}
+
+	m.queueMu.Lock()
+	idle := len(m.waiters) == 0
+	m.queueMu.Unlock()
+	if idle {
+		worker, err := m.Spawn(ctx, spec)
+		if err != domain.ErrWorkerLimitReached {
+			return worker, err
+		}
+	}
+
+	w := &spawnWaiter{spec: spec, queuedAt: time.Now(), done: make(chan spawnResult, 1)}
+	m.queueMu.Lock()
+	m.waiters = append(m.waiters, w)
+	if len(m.waiters) > m.stats.Peak {
+		m.stats.Peak = len(m.waiters)
+	}
+	m.queueMu.Unlock()
+	m.drain()
+
+	interval := m.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case r := <-w.done:
+			return r.worker, r.err
+		case <-ticker.C:
+			m.drain()
+		case <-ctx.Done():
+			m.queueMu.Lock()
+			removed := m.removeWaiter(w)
+			m.queueMu.Unlock()
+			if !removed {
+				// drain served the waiter before it could leave the queue.
+				r := <-w.done
+				return r.worker, r.err
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// QueueStats returns a snapshot of the spawn queue.
+func (m *WorkerManager) QueueStats() WorkerQueueStats {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	stats := m.stats
+	stats.Waiting = len(m.waiters)
+	stats.ByRole = make(map[string]int)
+	for _, w := range m.waiters {
+		stats.ByRole[w.spec.Role]++
+	}
+	if stats.Granted > 0 {
+		stats.AvgWaitMS = (stats.totalWait / time.Duration(stats.Granted)).Milliseconds()
+	}
+	return stats
+}
+
+// wake serves queued waiters after a worker has finished.
+func (m *WorkerManager) wake() {
+	if m.Queue {
+		m.drain()
+	}
+}
+
+// drain offers a slot to each waiter in arrival order. Waiters still blocked
+// by a limit stay queued; a waiter blocked only by its own task's limits does
+// not hold up waiters of other tasks.
+func (m *WorkerManager) drain() {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+
+	remaining := m.waiters[:0]
+	for _, w := range m.waiters {
+		worker, err := m.Spawn(context.Background(), w.spec)
+		if err == domain.ErrWorkerLimitReached {
+			remaining = append(remaining, w)
+			continue
+		}
+		if err == nil {
+			m.stats.Granted++
+			m.stats.totalWait += time.Since(w.queuedAt)
+		}
+		w.done <- spawnResult{worker: worker, err: err}
+	}
+	for i := len(remaining); i < len(m.waiters); i++ {
+		m.waiters[i] = nil
+	}
+	m.waiters = remaining
+}
+
+// removeWaiter drops w from the queue, reporting whether it was still
+// there. The caller holds queueMu.
+func (m *WorkerManager) removeWaiter(w *spawnWaiter) bool {
+	for i, q := range m.waiters {
+		if q == w {
+			m.waiters = append(m.waiters[:i], m.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
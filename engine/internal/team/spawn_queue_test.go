@@ -0,0 +1,114 @@
+package team
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+func newQueueTestManager(t *testing.T) *WorkerManager {
+	t.Helper()
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	mgr := NewWorkerManager(db, 10)
+	mgr.SetLimits(10, 1, 0)
+	mgr.Queue = true
+	mgr.PollInterval = time.Hour
+	return mgr
+}
+
+// waitForDepth polls until the spawn queue holds n waiters.
+func waitForDepth(t *testing.T, mgr *WorkerManager, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for mgr.QueueStats().Waiting != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue depth = %d, want %d", mgr.QueueStats().Waiting, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSpawnWait_GrantsInOrderOnShutdown(t *testing.T) {
+	mgr := newQueueTestManager(t)
+	ctx := context.Background()
+
+	first, err := mgr.SpawnWait(ctx, testSpec())
+	if err != nil {
+		t.Fatalf("SpawnWait: %v", err)
+	}
+
+	type result struct {
+		role   string
+		worker *domain.WorkerRef
+	}
+	results := make(chan result, 2)
+	for i, role := range []string{"reviewer", "tester"} {
+		spec := testSpec()
+		spec.Role = role
+		go func() {
+			w, err := mgr.SpawnWait(ctx, spec)
+			if err != nil {
+				t.Errorf("SpawnWait %s: %v", role, err)
+			}
+			results <- result{role, w}
+		}()
+		waitForDepth(t, mgr, i+1)
+	}
+	if stats := mgr.QueueStats(); stats.ByRole["reviewer"] != 1 || stats.ByRole["tester"] != 1 || stats.Peak != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	if err := mgr.Shutdown(ctx, first.WorkerID); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	got := <-results
+	if got.role != "reviewer" {
+		t.Fatalf("first granted = %s, want reviewer", got.role)
+	}
+	if stats := mgr.QueueStats(); stats.Waiting != 1 || stats.Granted != 1 {
+		t.Errorf("after one grant: %+v", stats)
+	}
+
+	if err := mgr.UpdateState(ctx, got.worker.WorkerID, domain.WorkerDone); err != nil {
+		t.Fatalf("UpdateState: %v", err)
+	}
+	if got := <-results; got.role != "tester" {
+		t.Errorf("second granted = %s, want tester", got.role)
+	}
+}
+
+func TestSpawnWait_ContextCancelLeavesQueue(t *testing.T) {
+	mgr := newQueueTestManager(t)
+	if _, err := mgr.Spawn(context.Background(), testSpec()); err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := mgr.SpawnWait(ctx, testSpec()); err != context.DeadlineExceeded {
+		t.Fatalf("SpawnWait = %v, want DeadlineExceeded", err)
+	}
+	if stats := mgr.QueueStats(); stats.Waiting != 0 || stats.Peak != 1 {
+		t.Errorf("stats = %+v, want empty queue with peak 1", stats)
+	}
+}
+
+func TestSpawnWait_WithoutQueueFailsFast(t *testing.T) {
+	mgr := newQueueTestManager(t)
+	mgr.Queue = false
+	if _, err := mgr.Spawn(context.Background(), testSpec()); err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	if _, err := mgr.SpawnWait(context.Background(), testSpec()); err != domain.ErrWorkerLimitReached {
+		t.Errorf("SpawnWait = %v, want ErrWorkerLimitReached", err)
+	}
+}
@@ -3,35 +3,67 @@ package team
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/anthropics/three-body-engine/internal/chaos"
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
 	"github.com/anthropics/three-body-engine/internal/store"
 )
 
 // TimeoutAction records a timeout action taken against a worker.
 type TimeoutAction struct {
 	WorkerID string
-	Type     string // "soft" or "hard"
+	Type     string // "soft", "hard", or "escalated"
+}
+
+// FlowBlocker blocks a flow for human attention, for example
+// workflow.Engine.
+type FlowBlocker interface {
+	Block(ctx context.Context, taskID, reason string) error
 }
 
 // SupervisorConfig holds tunable parameters for the supervisor loop.
 type SupervisorConfig struct {
 	CheckIntervalSec int
 	HeartbeatMaxAge  int
+	// MaxReplacements bounds how many times a worker lineage is replaced
+	// after hard timeouts; 0 means unlimited. A worker that times out with
+	// the limit spent is not replaced and its flow is escalated instead.
+	MaxReplacements int
+	// ProgressMaxAge is how long, in seconds, a worker may go without
+	// reporting progress before it is soft timed out even while its
+	// heartbeats are fresh; 0 disables the check.
+	ProgressMaxAge int
 }
 
-// Supervisor monitors worker heartbeats and handles timeouts.
+// Supervisor monitors worker heartbeats and handles timeouts. When Intents is
+// set it also reaps intents whose lease has expired, and when Conflicts is set
+// it resolves conflicting intents with the task's configured strategy.
+// Escalated flows are blocked through Flows and announced on Bus when set.
 type Supervisor struct {
 	DB            *sql.DB
+	TaskRepo      *store.TaskRepo
 	WorkerRepo    *store.WorkerRepo
 	AuditRepo     *store.AuditRepo
 	WorkerManager *WorkerManager
+	Intents       *IntentResolver
+	Conflicts     *ConflictDetector
+	Flows         FlowBlocker
+	Bus           *eventbus.Bus
 	Config        SupervisorConfig
-	stopCh        chan struct{}
-	stopOnce      sync.Once
+	// Clock dates heartbeats and timeouts and paces the monitoring loop.
+	// Audit record IDs stay on the wall clock so they remain unique.
+	Clock clock.Clock
+	// Chaos, if set, drops heartbeats at its rate.
+	Chaos *chaos.Monkey
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
 }
 
 // NewSupervisor creates a Supervisor with sensible defaults for zero-value config fields.
@@ -44,17 +76,22 @@ func NewSupervisor(db *sql.DB, wm *WorkerManager, cfg SupervisorConfig) *Supervi
 	}
 	return &Supervisor{
 		DB:            db,
+		TaskRepo:      &store.TaskRepo{},
 		WorkerRepo:    wm.WorkerRepo,
 		AuditRepo:     wm.AuditRepo,
 		WorkerManager: wm,
 		Config:        cfg,
+		Clock:         clock.System,
 		stopCh:        make(chan struct{}),
 	}
 }
 
 // Heartbeat updates the heartbeat timestamp for a worker.
 func (s *Supervisor) Heartbeat(ctx context.Context, workerID string) error {
-	return s.WorkerRepo.UpdateHeartbeat(ctx, s.DB, workerID, time.Now().Unix())
+	if s.Chaos.DropHeartbeat() {
+		return nil
+	}
+	return s.WorkerRepo.UpdateHeartbeat(ctx, s.DB, workerID, s.Clock.Now().Unix())
 }
 
 // CheckTimeouts inspects all active workers for a task and returns actions for any that
@@ -69,14 +106,18 @@ func (s *Supervisor) CheckTimeouts(ctx context.Context, taskID string, nowUnix i
 	for _, w := range workers {
 		age := nowUnix - w.LastHeartbeat
 
-		if w.HardTimeoutSec > 0 && age > int64(w.HardTimeoutSec) {
+		if w.HardTimeoutSec > 0 && age > int64(w.HardTimeoutSec) && s.Config.MaxReplacements > 0 && w.Generation >= s.Config.MaxReplacements {
+			_ = s.WorkerManager.UpdateState(ctx, w.WorkerID, domain.WorkerHardTimeout)
+			s.escalate(ctx, w)
+			actions = append(actions, TimeoutAction{WorkerID: w.WorkerID, Type: "escalated"})
+		} else if w.HardTimeoutSec > 0 && age > int64(w.HardTimeoutSec) {
 			_ = s.WorkerManager.UpdateState(ctx, w.WorkerID, domain.WorkerHardTimeout)
 			_, _ = s.WorkerManager.Replace(ctx, w.WorkerID)
 			actions = append(actions, TimeoutAction{WorkerID: w.WorkerID, Type: "hard"})
 
-			now := time.Now()
+			now := s.Clock.Now()
 			_ = s.AuditRepo.Record(ctx, s.DB, domain.AuditRecord{
-				ID:        fmt.Sprintf("aud-%d", now.UnixNano()),
+				ID:        fmt.Sprintf("aud-%d", time.Now().UnixNano()),
 				TaskID:    w.TaskID,
 				Category:  "supervisor",
 				Actor:     "system",
@@ -84,38 +125,121 @@ func (s *Supervisor) CheckTimeouts(ctx context.Context, taskID string, nowUnix i
 				Severity:  "warning",
 				CreatedAt: now.Unix(),
 			})
-		} else if w.SoftTimeoutSec > 0 && age > int64(w.SoftTimeoutSec) {
+		} else if reason := s.softTimeoutReason(w, age, nowUnix); reason != "" {
 			_ = s.WorkerManager.UpdateState(ctx, w.WorkerID, domain.WorkerSoftTimeout)
 			actions = append(actions, TimeoutAction{WorkerID: w.WorkerID, Type: "soft"})
 
-			now := time.Now()
+			data, _ := json.Marshal(map[string]string{"worker": w.WorkerID, "reason": reason})
+			now := s.Clock.Now()
 			_ = s.AuditRepo.Record(ctx, s.DB, domain.AuditRecord{
-				ID:        fmt.Sprintf("aud-%d", now.UnixNano()),
-				TaskID:    w.TaskID,
-				Category:  "supervisor",
-				Actor:     "system",
-				Action:    "soft_timeout",
-				Severity:  "warning",
-				CreatedAt: now.Unix(),
+				ID:           fmt.Sprintf("aud-%d", time.Now().UnixNano()),
+				TaskID:       w.TaskID,
+				Category:     "supervisor",
+				Actor:        "system",
+				Action:       "soft_timeout",
+				DecisionJSON: string(data),
+				Severity:     "warning",
+				CreatedAt:    now.Unix(),
 			})
 		}
 	}
 	return actions, nil
 }
 
-// StartMonitoring spawns a goroutine that periodically checks for worker timeouts.
-func (s *Supervisor) StartMonitoring(ctx context.Context, taskID string) {
-	ticker := time.NewTicker(time.Duration(s.Config.CheckIntervalSec) * time.Second)
+// softTimeoutReason says why w, whose last heartbeat is age seconds old, is
+// soft timed out: "no_heartbeat" past its soft timeout, "no_progress" when it
+// has not reported progress within ProgressMaxAge, or "" when it is healthy.
+// A worker that never reported is measured from its creation.
+func (s *Supervisor) softTimeoutReason(w *domain.WorkerRef, age, nowUnix int64) string {
+	if w.SoftTimeoutSec > 0 && age > int64(w.SoftTimeoutSec) {
+		return "no_heartbeat"
+	}
+	if s.Config.ProgressMaxAge > 0 {
+		last := w.Progress.UpdatedAt
+		if last == 0 {
+			last = w.CreatedAtUnix
+		}
+		if nowUnix-last > int64(s.Config.ProgressMaxAge) {
+			return "no_progress"
+		}
+	}
+	return ""
+}
+
+// escalate records that w's lineage used up its replacements and blocks the
+// flow so a human can step in.
+func (s *Supervisor) escalate(ctx context.Context, w *domain.WorkerRef) {
+	lineage := w.LineageID
+	if lineage == "" {
+		lineage = w.WorkerID
+	}
+	reason := fmt.Sprintf("worker %s (%s) timed out after %d replacements", w.WorkerID, w.Role, w.Generation)
+	detail := map[string]interface{}{
+		"worker":       w.WorkerID,
+		"lineage":      lineage,
+		"role":         w.Role,
+		"replacements": w.Generation,
+		"reason":       reason,
+	}
+	if s.Flows != nil {
+		if err := s.Flows.Block(ctx, w.TaskID, reason); err != nil {
+			detail["block_error"] = err.Error()
+		}
+	}
+	data, _ := json.Marshal(detail)
+	now := s.Clock.Now()
+	_ = s.AuditRepo.Record(ctx, s.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-%d", time.Now().UnixNano()),
+		TaskID:       w.TaskID,
+		Category:     "supervisor",
+		Actor:        "system",
+		Action:       "escalation",
+		DecisionJSON: string(data),
+		Severity:     "critical",
+		CreatedAt:    now.Unix(),
+	})
+	s.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicFlowBlocked, TaskID: w.TaskID})
+}
+
+// monitoredStatuses are the flow statuses whose workers and intents the
+// supervisor checks each tick.
+var monitoredStatuses = []domain.FlowStatus{domain.StatusRunning, domain.StatusPaused, domain.StatusBlocked}
+
+// Tick runs one supervision pass over every running, paused, or blocked flow:
+// it handles worker timeouts, reaps expired intent leases, and resolves
+// intent conflicts. A failure on one flow does not stop the others.
+func (s *Supervisor) Tick(ctx context.Context, nowUnix int64) error {
+	var tasks []*domain.FlowState
+	for _, status := range monitoredStatuses {
+		batch, err := s.TaskRepo.ListByStatus(ctx, s.DB, status)
+		if err != nil {
+			return fmt.Errorf("list %s flows: %w", status, err)
+		}
+		tasks = append(tasks, batch...)
+	}
+	for _, task := range tasks {
+		_, _ = s.CheckTimeouts(ctx, task.TaskID, nowUnix)
+		if s.Intents != nil {
+			_, _ = s.Intents.ReapExpired(ctx, task.TaskID, nowUnix)
+		}
+		s.resolveConflicts(ctx, task.TaskID)
+	}
+	return nil
+}
+
+// StartMonitoring spawns a goroutine that calls Tick every CheckIntervalSec
+// until ctx is done or StopMonitoring is called.
+func (s *Supervisor) StartMonitoring(ctx context.Context) {
+	interval := time.Duration(s.Config.CheckIntervalSec) * time.Second
 	go func() {
-		defer ticker.Stop()
 		for {
 			select {
 			case <-s.stopCh:
 				return
 			case <-ctx.Done():
 				return
-			case <-ticker.C:
-				_, _ = s.CheckTimeouts(ctx, taskID, time.Now().Unix())
+			case now := <-s.Clock.After(interval):
+				_ = s.Tick(ctx, now.Unix())
 			}
 		}
 	}()
@@ -125,3 +249,18 @@ func (s *Supervisor) StartMonitoring(ctx context.Context, taskID string) {
 func (s *Supervisor) StopMonitoring() {
 	s.stopOnce.Do(func() { close(s.stopCh) })
 }
+
+// resolveConflicts applies the conflict strategy to every conflict currently
+// detected for taskID. Failures are left for the next tick.
+func (s *Supervisor) resolveConflicts(ctx context.Context, taskID string) {
+	if s.Conflicts == nil {
+		return
+	}
+	conflicts, err := s.Conflicts.Detect(ctx, taskID)
+	if err != nil {
+		return
+	}
+	for _, c := range conflicts {
+		_ = s.Conflicts.Resolve(ctx, c)
+	}
+}
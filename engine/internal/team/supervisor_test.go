@@ -3,9 +3,12 @@ package team
 import (
 	"context"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/anthropics/three-body-engine/internal/chaos"
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
 	"github.com/anthropics/three-body-engine/internal/store"
 )
@@ -75,6 +78,30 @@ func TestHeartbeat_Success(t *testing.T) {
 	}
 }
 
+func TestHeartbeat_ChaosDrops(t *testing.T) {
+	sup, mgr := newSupervisorTestDB(t)
+	ctx := context.Background()
+	clk := clock.NewFake(time.Now().Add(time.Hour))
+	sup.Clock = clk
+	sup.Chaos = chaos.New(chaos.Config{DropHeartbeatRate: 1})
+
+	w, err := mgr.Spawn(ctx, domain.WorkerSpec{TaskID: "task-1", Phase: domain.PhaseC, Role: "coder", SoftTimeoutSec: 300})
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	before, _ := sup.WorkerRepo.GetByID(ctx, sup.DB, w.WorkerID)
+	if err := sup.Heartbeat(ctx, w.WorkerID); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	got, _ := sup.WorkerRepo.GetByID(ctx, sup.DB, w.WorkerID)
+	if got.LastHeartbeat != before.LastHeartbeat {
+		t.Errorf("LastHeartbeat = %d, want the dropped heartbeat unrecorded (%d)", got.LastHeartbeat, before.LastHeartbeat)
+	}
+	if sup.Chaos.Stats().HeartbeatsDropped != 1 {
+		t.Errorf("Stats = %+v, want one heartbeat dropped", sup.Chaos.Stats())
+	}
+}
+
 func TestHeartbeat_WorkerNotFound(t *testing.T) {
 	sup, _ := newSupervisorTestDB(t)
 	ctx := context.Background()
@@ -226,15 +253,204 @@ func TestCheckTimeouts_MixedTimeouts(t *testing.T) {
 }
 
 func TestStartStopMonitoring(t *testing.T) {
-	sup, _ := newSupervisorTestDB(t)
+	sup, mgr := newSupervisorTestDB(t)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	sup.StartMonitoring(ctx, "task-1")
+	tx, _ := sup.DB.Begin()
+	if err := sup.TaskRepo.CreateTx(ctx, tx, domain.FlowState{TaskID: "task-1", CurrentPhase: domain.PhaseC, Status: domain.StatusRunning, StateVersion: 1, BudgetCapUSD: 10}); err != nil {
+		t.Fatalf("CreateTx: %v", err)
+	}
+	tx.Commit()
+	w, err := mgr.Spawn(ctx, domain.WorkerSpec{TaskID: "task-1", Phase: domain.PhaseC, Role: "coder", SoftTimeoutSec: 5, HardTimeoutSec: 600})
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+
+	clk := clock.NewFake(time.Unix(w.LastHeartbeat, 0))
+	sup.Clock = clk
+	sup.StartMonitoring(ctx)
+	defer sup.StopMonitoring()
+
+	// Once the loop waits on the clock, a minute passes: the tick it fires
+	// finds the worker's heartbeat stale.
+	deadline := time.Now().Add(5 * time.Second)
+	for clk.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("monitoring loop never waited on the clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	clk.Advance(time.Minute)
+	for {
+		got, err := sup.WorkerRepo.GetByID(ctx, sup.DB, w.WorkerID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.State == domain.WorkerSoftTimeout {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("worker state = %q, want a tick to soft time it out", got.State)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// recordingBlocker records the flows blocked through it.
+type recordingBlocker struct {
+	blocked map[string]string
+}
+
+func (b *recordingBlocker) Block(_ context.Context, taskID, reason string) error {
+	b.blocked[taskID] = reason
+	return nil
+}
+
+func TestCheckTimeouts_EscalatesAfterMaxReplacements(t *testing.T) {
+	sup, mgr := newSupervisorTestDB(t)
+	sup.Config.MaxReplacements = 1
+	blocker := &recordingBlocker{blocked: map[string]string{}}
+	sup.Flows = blocker
+	ctx := context.Background()
+
+	w, err := mgr.Spawn(ctx, domain.WorkerSpec{
+		TaskID: "task-1", Phase: domain.PhaseC, Role: "coder",
+		SoftTimeoutSec: 5, HardTimeoutSec: 10,
+	})
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+
+	actions, err := sup.CheckTimeouts(ctx, "task-1", w.LastHeartbeat+100)
+	if err != nil {
+		t.Fatalf("CheckTimeouts: %v", err)
+	}
+	if len(actions) != 1 || actions[0].Type != "hard" {
+		t.Fatalf("first actions = %+v, want one hard timeout", actions)
+	}
+	active, _ := mgr.ListActive(ctx, "task-1")
+	if len(active) != 1 || active[0].LineageID != w.WorkerID || active[0].Generation != 1 {
+		t.Fatalf("replacement = %+v, want generation 1 of %s", active, w.WorkerID)
+	}
+
+	actions, err = sup.CheckTimeouts(ctx, "task-1", active[0].LastHeartbeat+100)
+	if err != nil {
+		t.Fatalf("CheckTimeouts: %v", err)
+	}
+	if len(actions) != 1 || actions[0].Type != "escalated" {
+		t.Fatalf("second actions = %+v, want one escalation", actions)
+	}
+	if left, _ := mgr.ListActive(ctx, "task-1"); len(left) != 0 {
+		t.Errorf("active workers after escalation = %d, want 0", len(left))
+	}
+	if _, ok := blocker.blocked["task-1"]; !ok {
+		t.Error("expected task-1 to be blocked")
+	}
+
+	records, err := sup.AuditRepo.ListByTask(ctx, sup.DB, "task-1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	var escalations int
+	for _, r := range records {
+		if r.Action == "escalation" && r.Severity == "critical" {
+			escalations++
+		}
+	}
+	if escalations != 1 {
+		t.Errorf("escalation audit records = %d, want 1", escalations)
+	}
+}
 
-	// Let the ticker fire at least once.
-	time.Sleep(1500 * time.Millisecond)
+func TestTick_ChecksEveryActiveFlow(t *testing.T) {
+	sup, mgr := newSupervisorTestDB(t)
+	ctx := context.Background()
+
+	tx, err := sup.DB.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	for taskID, status := range map[string]domain.FlowStatus{
+		"task-1": domain.StatusRunning,
+		"task-2": domain.StatusRunning,
+		"task-3": domain.StatusDone,
+	} {
+		state := domain.FlowState{TaskID: taskID, CurrentPhase: domain.PhaseC, Status: status, StateVersion: 1, BudgetCapUSD: 10}
+		if err := sup.TaskRepo.CreateTx(ctx, tx, state); err != nil {
+			t.Fatalf("CreateTx: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	var workers []*domain.WorkerRef
+	for _, taskID := range []string{"task-1", "task-2", "task-3"} {
+		w, err := mgr.Spawn(ctx, domain.WorkerSpec{TaskID: taskID, Phase: domain.PhaseC, Role: "coder", SoftTimeoutSec: 5, HardTimeoutSec: 600})
+		if err != nil {
+			t.Fatalf("Spawn: %v", err)
+		}
+		workers = append(workers, w)
+	}
+
+	if err := sup.Tick(ctx, workers[0].LastHeartbeat+60); err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	for i, want := range []domain.WorkerState{domain.WorkerSoftTimeout, domain.WorkerSoftTimeout, domain.WorkerCreated} {
+		got, err := sup.WorkerRepo.GetByID(ctx, sup.DB, workers[i].WorkerID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.State != want {
+			t.Errorf("%s worker state = %q, want %q", got.TaskID, got.State, want)
+		}
+	}
+}
 
-	sup.StopMonitoring()
-	// No panic or hang means success.
+func TestCheckTimeouts_NoProgress(t *testing.T) {
+	sup, mgr := newSupervisorTestDB(t)
+	sup.Config.ProgressMaxAge = 60
+	ctx := context.Background()
+
+	stalled, err := mgr.Spawn(ctx, domain.WorkerSpec{TaskID: "task-1", Phase: domain.PhaseC, Role: "coder", SoftTimeoutSec: 300, HardTimeoutSec: 600})
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	busy, err := mgr.Spawn(ctx, domain.WorkerSpec{TaskID: "task-1", Phase: domain.PhaseC, Role: "coder", SoftTimeoutSec: 300, HardTimeoutSec: 600})
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+
+	now := time.Now().Unix() + 90
+	for _, id := range []string{stalled.WorkerID, busy.WorkerID} {
+		if err := sup.Heartbeat(ctx, id); err != nil {
+			t.Fatalf("Heartbeat: %v", err)
+		}
+	}
+	if err := sup.WorkerRepo.UpdateProgress(ctx, sup.DB, busy.WorkerID, domain.WorkerProgress{Percent: 50, UpdatedAt: now - 10}); err != nil {
+		t.Fatalf("UpdateProgress: %v", err)
+	}
+
+	actions, err := sup.CheckTimeouts(ctx, "task-1", now)
+	if err != nil {
+		t.Fatalf("CheckTimeouts: %v", err)
+	}
+	if len(actions) != 1 || actions[0].WorkerID != stalled.WorkerID || actions[0].Type != "soft" {
+		t.Fatalf("actions = %+v, want one soft timeout for %s", actions, stalled.WorkerID)
+	}
+
+	records, err := sup.AuditRepo.ListByTask(ctx, sup.DB, "task-1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	found := false
+	for _, r := range records {
+		if r.Action == "soft_timeout" && strings.Contains(r.DecisionJSON, "no_progress") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a soft_timeout audit with reason no_progress, got %+v", records)
+	}
 }
@@ -0,0 +1,269 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// Issue describes a tracking issue to create.
+type Issue struct {
+	// Project is the Jira project key or Linear team ID.
+	Project     string
+	Title       string
+	Description string
+}
+
+// Client creates, comments on, and closes issues in a tracker. Create
+// returns the new issue's key, ID, and URL.
+type Client interface {
+	Create(ctx context.Context, issue Issue) (domain.IssueLink, error)
+	Comment(ctx context.Context, link domain.IssueLink, body string) error
+	Close(ctx context.Context, link domain.IssueLink) error
+}
+
+// Jira tracks flows as issues through the Jira REST API (version 2, which
+// takes plain-text descriptions and comments).
+type Jira struct {
+	// URL is the site, such as https://acme.atlassian.net.
+	URL string
+	// Email and Token authenticate to Jira Cloud; with no Email, Token is
+	// sent as a bearer token, as Jira Data Center expects.
+	Email string
+	Token string
+	// IssueType names the type of created issues, such as Task.
+	IssueType string
+	// Labels are added to created issues.
+	Labels []string
+	// DoneTransition names the workflow transition, or the status it leads
+	// to, that closes an issue.
+	DoneTransition string
+	HTTP           *http.Client
+}
+
+// Create implements Client.
+func (j *Jira) Create(ctx context.Context, issue Issue) (domain.IssueLink, error) {
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": issue.Project},
+		"summary":     issue.Title,
+		"description": issue.Description,
+		"issuetype":   map[string]string{"name": j.IssueType},
+	}
+	if len(j.Labels) > 0 {
+		fields["labels"] = j.Labels
+	}
+	var resp struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := j.call(ctx, http.MethodPost, "/rest/api/2/issue", map[string]interface{}{"fields": fields}, &resp); err != nil {
+		return domain.IssueLink{}, err
+	}
+	return domain.IssueLink{
+		Provider: "jira",
+		Key:      resp.Key,
+		ID:       resp.ID,
+		URL:      strings.TrimSuffix(j.URL, "/") + "/browse/" + resp.Key,
+	}, nil
+}
+
+// Comment implements Client.
+func (j *Jira) Comment(ctx context.Context, link domain.IssueLink, body string) error {
+	path := "/rest/api/2/issue/" + url.PathEscape(link.Key) + "/comment"
+	return j.call(ctx, http.MethodPost, path, map[string]string{"body": body}, nil)
+}
+
+// Close implements Client by taking the issue through DoneTransition.
+func (j *Jira) Close(ctx context.Context, link domain.IssueLink) error {
+	path := "/rest/api/2/issue/" + url.PathEscape(link.Key) + "/transitions"
+	var resp struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	if err := j.call(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return err
+	}
+	for _, t := range resp.Transitions {
+		if strings.EqualFold(t.Name, j.DoneTransition) || strings.EqualFold(t.To.Name, j.DoneTransition) {
+			return j.call(ctx, http.MethodPost, path, map[string]interface{}{"transition": map[string]string{"id": t.ID}}, nil)
+		}
+	}
+	return fmt.Errorf("jira: %s has no transition to %q", link.Key, j.DoneTransition)
+}
+
+func (j *Jira) call(ctx context.Context, method, path string, body, out interface{}) error {
+	auth := "Bearer " + j.Token
+	if j.Email != "" {
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(j.Email+":"+j.Token))
+	}
+	endpoint := strings.TrimSuffix(j.URL, "/") + path
+	if err := send(ctx, j.HTTP, method, endpoint, map[string]string{"Authorization": auth}, body, out); err != nil {
+		return fmt.Errorf("jira: %w", err)
+	}
+	return nil
+}
+
+// Linear tracks flows as issues through the Linear GraphQL API.
+type Linear struct {
+	// APIURL defaults to https://api.linear.app/graphql.
+	APIURL string
+	// Token is a personal API key or an OAuth access token prefixed with
+	// "Bearer ".
+	Token string
+	// LabelIDs are added to created issues.
+	LabelIDs []string
+	// DoneStateID is the workflow state that closes an issue.
+	DoneStateID string
+	HTTP        *http.Client
+}
+
+// Create implements Client.
+func (l *Linear) Create(ctx context.Context, issue Issue) (domain.IssueLink, error) {
+	const q = `mutation($input: IssueCreateInput!) { issueCreate(input: $input) { success issue { id identifier url } } }`
+	input := map[string]interface{}{
+		"teamId":      issue.Project,
+		"title":       issue.Title,
+		"description": issue.Description,
+	}
+	if len(l.LabelIDs) > 0 {
+		input["labelIds"] = l.LabelIDs
+	}
+	var data struct {
+		IssueCreate struct {
+			Success bool `json:"success"`
+			Issue   struct {
+				ID         string `json:"id"`
+				Identifier string `json:"identifier"`
+				URL        string `json:"url"`
+			} `json:"issue"`
+		} `json:"issueCreate"`
+	}
+	if err := l.query(ctx, q, map[string]interface{}{"input": input}, &data); err != nil {
+		return domain.IssueLink{}, err
+	}
+	if !data.IssueCreate.Success {
+		return domain.IssueLink{}, fmt.Errorf("linear: issueCreate was not successful")
+	}
+	created := data.IssueCreate.Issue
+	return domain.IssueLink{Provider: "linear", Key: created.Identifier, ID: created.ID, URL: created.URL}, nil
+}
+
+// Comment implements Client.
+func (l *Linear) Comment(ctx context.Context, link domain.IssueLink, body string) error {
+	const q = `mutation($input: CommentCreateInput!) { commentCreate(input: $input) { success } }`
+	var data struct {
+		CommentCreate struct {
+			Success bool `json:"success"`
+		} `json:"commentCreate"`
+	}
+	if err := l.query(ctx, q, map[string]interface{}{"input": map[string]string{"issueId": link.ID, "body": body}}, &data); err != nil {
+		return err
+	}
+	if !data.CommentCreate.Success {
+		return fmt.Errorf("linear: commentCreate was not successful")
+	}
+	return nil
+}
+
+// Close implements Client by moving the issue to DoneStateID.
+func (l *Linear) Close(ctx context.Context, link domain.IssueLink) error {
+	const q = `mutation($id: String!, $input: IssueUpdateInput!) { issueUpdate(id: $id, input: $input) { success } }`
+	var data struct {
+		IssueUpdate struct {
+			Success bool `json:"success"`
+		} `json:"issueUpdate"`
+	}
+	vars := map[string]interface{}{"id": link.ID, "input": map[string]string{"stateId": l.DoneStateID}}
+	if err := l.query(ctx, q, vars, &data); err != nil {
+		return err
+	}
+	if !data.IssueUpdate.Success {
+		return fmt.Errorf("linear: issueUpdate was not successful")
+	}
+	return nil
+}
+
+// query runs a GraphQL request and decodes its data into out.
+func (l *Linear) query(ctx context.Context, q string, vars map[string]interface{}, out interface{}) error {
+	api := l.APIURL
+	if api == "" {
+		api = "https://api.linear.app/graphql"
+	}
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	body := map[string]interface{}{"query": q, "variables": vars}
+	if err := send(ctx, l.HTTP, http.MethodPost, api, map[string]string{"Authorization": l.Token}, body, &resp); err != nil {
+		return fmt.Errorf("linear: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		msgs := make([]string, len(resp.Errors))
+		for i, e := range resp.Errors {
+			msgs[i] = e.Message
+		}
+		return fmt.Errorf("linear: %s", strings.Join(msgs, "; "))
+	}
+	if err := json.Unmarshal(resp.Data, out); err != nil {
+		return fmt.Errorf("linear: decode data: %w", err)
+	}
+	return nil
+}
+
+// send makes a JSON request and decodes a 2xx response into out, if set.
+func send(ctx context.Context, client *http.Client, method, endpoint string, headers map[string]string, body, out interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
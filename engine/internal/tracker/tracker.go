@@ -0,0 +1,302 @@
+// Package tracker mirrors flows into Jira or Linear: it creates a tracking
+// issue when a flow starts, comments on it with each phase transition and
+// review consensus, and closes it when the flow reaches phase G. Changes
+// are queued in the database and retried with backoff, so a tracker outage
+// delays them without losing any.
+package tracker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+	"github.com/anthropics/three-body-engine/internal/review"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// Syncer keeps each flow's tracking issue up to date.
+type Syncer struct {
+	DB            *sql.DB
+	Engine        *workflow.Engine
+	Client        Client
+	Repo          *store.IssueSyncRepo
+	TaskRepo      *store.TaskRepo
+	ScoreCardRepo *store.ScoreCardRepo
+	Consensus     *review.ConsensusEngine
+	AuditRepo     *store.AuditRepo
+	Clock         clock.Clock
+	// Project is the project issues are created in; Projects overrides it
+	// for flows in the named namespaces.
+	Project  string
+	Projects map[string]string
+	// Interval is how often queued changes are sent, and the first retry
+	// delay of a failed one. Each further failure doubles the delay up to
+	// MaxBackoff.
+	Interval   time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts drops a change that failed this many times; 0 retries
+	// forever.
+	MaxAttempts int
+
+	wake chan struct{}
+}
+
+// New creates a Syncer with default repositories and consensus weights.
+func New(db *sql.DB, engine *workflow.Engine, client Client, project string) *Syncer {
+	return &Syncer{
+		DB:            db,
+		Engine:        engine,
+		Client:        client,
+		Repo:          &store.IssueSyncRepo{},
+		TaskRepo:      &store.TaskRepo{},
+		ScoreCardRepo: &store.ScoreCardRepo{},
+		Consensus:     review.NewConsensusEngine(review.DefaultWeights()),
+		AuditRepo:     &store.AuditRepo{},
+		Project:       project,
+		Interval:      10 * time.Second,
+		MaxBackoff:    time.Hour,
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Start queues a change for every new flow and phase transition. Queued
+// changes are sent by Run, which may run on another instance sharing the
+// database.
+func (s *Syncer) Start(ctx context.Context, bus *eventbus.Bus) {
+	s.Engine.AddListener(func(_ context.Context, state domain.FlowState, from domain.Phase) {
+		if state.CurrentPhase == from {
+			return // a status change, not a transition
+		}
+		s.transitioned(ctx, state, from)
+	})
+
+	signals, unsubscribe := bus.Subscribe(64)
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				if sig.Topic == eventbus.TopicFlowCreated {
+					s.enqueue(ctx, sig.TaskID, domain.IssueOpCreate, "")
+				}
+			}
+		}
+	}()
+}
+
+// Run sends queued changes every Interval, and soon after one is queued,
+// until ctx is cancelled.
+func (s *Syncer) Run(ctx context.Context) {
+	go func() {
+		for {
+			if err := s.Flush(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("issue sync: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+			case <-clock.Or(s.Clock).After(s.Interval):
+			}
+		}
+	}()
+}
+
+// transitioned queues the comments for a committed transition: the move
+// itself, the consensus that let the flow leave a review phase, and, on
+// reaching phase G, the closing of the issue.
+func (s *Syncer) transitioned(ctx context.Context, state domain.FlowState, from domain.Phase) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Phase %s -> %s (round %d, $%.2f of $%.2f spent).", from, state.CurrentPhase, state.Round, state.BudgetUsedUSD, state.BudgetCapUSD)
+	if (from == domain.PhaseD || from == domain.PhaseF) && state.CurrentPhase > from {
+		if c := s.consensus(ctx, state, from); c != "" {
+			b.WriteString("\n\n" + c)
+		}
+	}
+	s.enqueue(ctx, state.TaskID, domain.IssueOpComment, b.String())
+	if state.CurrentPhase == domain.PhaseG {
+		s.enqueue(ctx, state.TaskID, domain.IssueOpClose, "")
+	}
+}
+
+// consensus describes the review consensus of phase, or returns "" if
+// there is none to report.
+func (s *Syncer) consensus(ctx context.Context, state domain.FlowState, phase domain.Phase) string {
+	cards, err := s.ScoreCardRepo.ListByTask(ctx, s.DB, state.TaskID)
+	if err != nil || len(cards) == 0 {
+		return ""
+	}
+	result, err := s.Consensus.For(state.Consensus).EvaluatePhase(cards, phase)
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Phase %s consensus: %s (weighted score %.2f, %d score cards)", phase, result.FinalVerdict, result.WeightedScore, len(cards))
+	for _, reason := range result.BlockReasons {
+		fmt.Fprintf(&b, "\n- %s", reason)
+	}
+	return b.String()
+}
+
+func (s *Syncer) enqueue(ctx context.Context, taskID, kind, body string) {
+	err := s.Repo.Enqueue(ctx, s.DB, domain.IssueSyncOp{
+		TaskID:    taskID,
+		Kind:      kind,
+		Body:      body,
+		CreatedAt: clock.Or(s.Clock).Now().Unix(),
+	})
+	if err != nil {
+		s.audit(taskID, "issue_sync_failed", "warning", map[string]string{"kind": kind, "error": err.Error()})
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Flush sends the queued changes that are due, in order per flow. A flow
+// whose change fails keeps its later changes queued behind it.
+func (s *Syncer) Flush(ctx context.Context) error {
+	ops, err := s.Repo.Pending(ctx, s.DB, 100)
+	if err != nil {
+		return err
+	}
+	now := clock.Or(s.Clock).Now()
+	held := make(map[string]bool)
+	for _, op := range ops {
+		if held[op.TaskID] {
+			continue
+		}
+		if op.NextAttemptAt > now.Unix() {
+			held[op.TaskID] = true
+			continue
+		}
+		err := s.send(ctx, op)
+		switch {
+		case err == nil:
+		case errors.Is(err, domain.ErrFlowNotFound):
+			// The flow was purged; its issue is left as it is.
+		case s.MaxAttempts > 0 && op.Attempts+1 >= s.MaxAttempts:
+			s.audit(op.TaskID, "issue_sync_dropped", "warning", map[string]string{"kind": op.Kind, "error": err.Error()})
+		default:
+			held[op.TaskID] = true
+			if op.Attempts == 0 {
+				s.audit(op.TaskID, "issue_sync_failed", "warning", map[string]string{"kind": op.Kind, "error": err.Error()})
+			}
+			next := now.Add(s.backoff(op.Attempts + 1)).Unix()
+			if err := s.Repo.Retry(ctx, s.DB, op.ID, next, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.Repo.Done(ctx, s.DB, op.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backoff is the delay before the given attempt at a failed change.
+func (s *Syncer) backoff(attempt int) time.Duration {
+	d := s.Interval
+	for i := 1; i < attempt && d < s.MaxBackoff; i++ {
+		d *= 2
+	}
+	if s.MaxBackoff > 0 && d > s.MaxBackoff {
+		d = s.MaxBackoff
+	}
+	return d
+}
+
+// send applies one change, creating the flow's issue first if it has none.
+func (s *Syncer) send(ctx context.Context, op domain.IssueSyncOp) error {
+	link, err := s.link(ctx, op.TaskID)
+	if err != nil {
+		return err
+	}
+	switch op.Kind {
+	case domain.IssueOpComment:
+		return s.Client.Comment(ctx, *link, op.Body)
+	case domain.IssueOpClose:
+		if err := s.Client.Close(ctx, *link); err != nil {
+			return err
+		}
+		s.audit(op.TaskID, "issue_closed", "info", map[string]string{"key": link.Key})
+	}
+	return nil
+}
+
+// link returns the flow's issue, creating it if there is none yet.
+func (s *Syncer) link(ctx context.Context, taskID string) (*domain.IssueLink, error) {
+	link, err := s.Repo.GetLink(ctx, s.DB, taskID)
+	if err != nil || link != nil {
+		return link, err
+	}
+	state, err := s.TaskRepo.GetByID(ctx, s.DB, taskID)
+	if err != nil {
+		return nil, err
+	}
+	project := s.Project
+	if p, ok := s.Projects[state.Namespace]; ok {
+		project = p
+	}
+	title := state.Title
+	if title == "" {
+		title = taskID
+	}
+	created, err := s.Client.Create(ctx, Issue{
+		Project:     project,
+		Title:       "threebody: " + title,
+		Description: Describe(*state),
+	})
+	if err != nil {
+		return nil, err
+	}
+	created.TaskID = taskID
+	created.CreatedAt = clock.Or(s.Clock).Now().Unix()
+	if err := s.Repo.SaveLink(ctx, s.DB, created); err != nil {
+		return nil, err
+	}
+	s.audit(taskID, "issue_created", "info", map[string]string{"key": created.Key, "url": created.URL})
+	return &created, nil
+}
+
+// Describe renders a tracking issue's description from the flow's spec.
+func Describe(state domain.FlowState) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tracked by the Three-Body Engine as task %s in namespace %s, with a budget of $%.2f.\n", state.TaskID, state.Namespace, state.BudgetCapUSD)
+	if state.Description != "" {
+		b.WriteString("\n" + state.Description + "\n")
+	}
+	if state.AcceptanceCriteria != "" {
+		b.WriteString("\nAcceptance criteria:\n" + state.AcceptanceCriteria + "\n")
+	}
+	return b.String()
+}
+
+func (s *Syncer) audit(taskID, action, severity string, detail map[string]string) {
+	data, _ := json.Marshal(detail)
+	now := time.Now()
+	_ = s.AuditRepo.Record(context.Background(), s.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-issue-%d", now.UnixNano()),
+		TaskID:       taskID,
+		Category:     "issue_sync",
+		Actor:        "system",
+		Action:       action,
+		DecisionJSON: string(data),
+		Severity:     severity,
+		CreatedAt:    now.Unix(),
+	})
+}
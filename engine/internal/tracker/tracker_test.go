@@ -0,0 +1,309 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// fakeClient records calls and fails them while err is set.
+type fakeClient struct {
+	mu     sync.Mutex
+	err    error
+	issues []Issue
+	calls  []string
+}
+
+func (f *fakeClient) Create(ctx context.Context, issue Issue) (domain.IssueLink, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "create")
+	if f.err != nil {
+		return domain.IssueLink{}, f.err
+	}
+	f.issues = append(f.issues, issue)
+	return domain.IssueLink{Provider: "fake", Key: "ENG-1", URL: "https://tracker/ENG-1"}, nil
+}
+
+func (f *fakeClient) Comment(ctx context.Context, link domain.IssueLink, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "comment "+link.Key+": "+body)
+	return f.err
+}
+
+func (f *fakeClient) Close(ctx context.Context, link domain.IssueLink) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "close "+link.Key)
+	return f.err
+}
+
+func (f *fakeClient) called() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func newSyncer(t *testing.T) (*Syncer, *fakeClient) {
+	t.Helper()
+	db, err := store.NewDB(store.MemoryPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	eng := workflow.NewEngine(db)
+	eng.Bus = eventbus.New()
+	client := &fakeClient{}
+	return New(db, eng, client, "ENG"), client
+}
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSyncer_FlowLifecycle(t *testing.T) {
+	s, client := newSyncer(t)
+	s.Projects = map[string]string{"payments": "PAY"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx, s.Engine.Bus)
+
+	err := s.Engine.StartFlowWithOptions(ctx, "t1", 10, workflow.FlowOptions{Title: "Add retries", Description: "Retry failed uploads.", Namespace: "payments"})
+	if err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	waitFor(t, "the create to be queued", func() bool {
+		ops, _ := s.Repo.Pending(ctx, s.DB, 10)
+		return len(ops) == 1
+	})
+	for i := 0; i < 6; i++ {
+		if i == 3 {
+			card := domain.ScoreCard{
+				ReviewID: "r1", TaskID: "t1", Reviewer: "primary", Verdict: "pass", CreatedAt: 1,
+				Scores: domain.Scores{Correctness: 5, Security: 5, Maintainability: 5, Cost: 5, DeliveryRisk: 5},
+				Issues: []domain.Issue{}, Alternatives: []string{},
+			}
+			if err := s.ScoreCardRepo.Create(ctx, s.DB, card); err != nil {
+				t.Fatalf("create card: %v", err)
+			}
+		}
+		if err := s.Engine.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "test"}); err != nil {
+			t.Fatalf("advance %d: %v", i, err)
+		}
+	}
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	calls := client.called()
+	if len(calls) != 8 || calls[0] != "create" || calls[7] != "close ENG-1" {
+		t.Fatalf("calls = %q", calls)
+	}
+	if !strings.HasPrefix(calls[1], "comment ENG-1: Phase A -> B (round 0") {
+		t.Errorf("first comment = %q", calls[1])
+	}
+	if !strings.Contains(calls[4], "Phase D -> E") || !strings.Contains(calls[4], "Phase D consensus: pass") {
+		t.Errorf("D -> E comment = %q, want the consensus", calls[4])
+	}
+	if issue := client.issues[0]; issue.Project != "PAY" || issue.Title != "threebody: Add retries" || !strings.Contains(issue.Description, "Retry failed uploads.") {
+		t.Errorf("issue = %+v", issue)
+	}
+	if link, _ := s.Repo.GetLink(ctx, s.DB, "t1"); link == nil || link.Key != "ENG-1" || link.TaskID != "t1" {
+		t.Errorf("link = %+v", link)
+	}
+	if ops, _ := s.Repo.Pending(ctx, s.DB, 10); len(ops) != 0 {
+		t.Errorf("%d changes still queued", len(ops))
+	}
+}
+
+func TestSyncer_RetriesInOrder(t *testing.T) {
+	s, client := newSyncer(t)
+	ctx := context.Background()
+	fake := clock.NewFake(time.Unix(1000, 0))
+	s.Clock = fake
+	s.Interval = 10 * time.Second
+	s.MaxBackoff = 15 * time.Second
+	s.Engine.StartFlow(ctx, "t1", 10)
+	s.Engine.StartFlow(ctx, "t2", 10)
+	s.enqueue(ctx, "t1", domain.IssueOpComment, "first")
+	s.enqueue(ctx, "t1", domain.IssueOpComment, "second")
+
+	client.err = errors.New("503 Service Unavailable")
+	s.Flush(ctx)
+	if calls := client.called(); len(calls) != 1 {
+		t.Fatalf("calls during the outage = %q, want one attempt", calls)
+	}
+	ops, _ := s.Repo.Pending(ctx, s.DB, 10)
+	if len(ops) != 2 || ops[0].Attempts != 1 || ops[0].NextAttemptAt != 1010 || ops[0].LastError != "503 Service Unavailable" {
+		t.Fatalf("queue = %+v", ops)
+	}
+
+	s.Flush(ctx)
+	fake.Advance(10 * time.Second)
+	s.Flush(ctx)
+	if ops, _ := s.Repo.Pending(ctx, s.DB, 10); ops[0].NextAttemptAt != 1025 {
+		t.Errorf("second retry at %d, want the backoff capped at 15s", ops[0].NextAttemptAt)
+	}
+
+	client.err = nil
+	s.enqueue(ctx, "t2", domain.IssueOpCreate, "")
+	fake.Advance(15 * time.Second)
+	s.Flush(ctx)
+	calls := client.called()
+	if want := []string{"create", "create", "create", "comment ENG-1: first", "comment ENG-1: second", "create"}; strings.Join(calls, "|") != strings.Join(want, "|") {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+
+	audits, _ := s.AuditRepo.ListByTask(ctx, s.DB, "t1")
+	if len(audits) != 2 || audits[0].Action != "issue_sync_failed" || audits[1].Action != "issue_created" {
+		t.Errorf("audits = %+v, want the first failure and the creation", audits)
+	}
+}
+
+func TestSyncer_MaxAttempts(t *testing.T) {
+	s, client := newSyncer(t)
+	ctx := context.Background()
+	s.Interval = 0
+	s.MaxAttempts = 2
+	s.Engine.StartFlow(ctx, "t1", 10)
+	s.enqueue(ctx, "t1", domain.IssueOpCreate, "")
+	s.enqueue(ctx, "gone", domain.IssueOpCreate, "")
+
+	client.err = errors.New("400 Bad Request: project ENG does not exist")
+	s.Flush(ctx)
+	s.Flush(ctx)
+	if ops, _ := s.Repo.Pending(ctx, s.DB, 10); len(ops) != 0 {
+		t.Errorf("queue = %+v, want the failing change dropped and the purged flow's skipped", ops)
+	}
+	if audits, _ := s.AuditRepo.ListByTask(ctx, s.DB, "t1"); len(audits) != 2 || audits[1].Action != "issue_sync_dropped" {
+		t.Errorf("audits = %+v", audits)
+	}
+}
+
+func TestJira(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "bot@acme.com" || pass != "s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		data, _ := json.Marshal(body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(data))
+		switch {
+		case r.URL.Path == "/rest/api/2/issue":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "10042", "key": "ENG-42"}`))
+		case strings.HasSuffix(r.URL.Path, "/transitions") && r.Method == http.MethodGet:
+			w.Write([]byte(`{"transitions": [{"id": "11", "name": "Start", "to": {"name": "In Progress"}}, {"id": "31", "name": "Resolve", "to": {"name": "Done"}}]}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	j := &Jira{URL: srv.URL + "/", Email: "bot@acme.com", Token: "s3cret", IssueType: "Task", Labels: []string{"threebody"}, DoneTransition: "done"}
+	ctx := context.Background()
+	link, err := j.Create(ctx, Issue{Project: "ENG", Title: "threebody: t1", Description: "text"})
+	if err != nil || link.Key != "ENG-42" || link.ID != "10042" || link.URL != srv.URL+"/browse/ENG-42" {
+		t.Fatalf("Create = %+v, %v", link, err)
+	}
+	if err := j.Comment(ctx, link, "Phase A done"); err != nil {
+		t.Fatalf("Comment: %v", err)
+	}
+	if err := j.Close(ctx, link); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	want := []string{
+		`POST /rest/api/2/issue {"fields":{"description":"text","issuetype":{"name":"Task"},"labels":["threebody"],"project":{"key":"ENG"},"summary":"threebody: t1"}}`,
+		`POST /rest/api/2/issue/ENG-42/comment {"body":"Phase A done"}`,
+		`GET /rest/api/2/issue/ENG-42/transitions null`,
+		`POST /rest/api/2/issue/ENG-42/transitions {"transition":{"id":"31"}}`,
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests:\n%s\nwant:\n%s", strings.Join(requests, "\n"), strings.Join(want, "\n"))
+	}
+
+	j.DoneTransition = "Closed"
+	if err := j.Close(ctx, link); err == nil || !strings.Contains(err.Error(), `no transition to "Closed"`) {
+		t.Errorf("Close with no matching transition = %v", err)
+	}
+	j.Token = "wrong"
+	if err := j.Comment(ctx, link, "x"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Comment unauthorized = %v", err)
+	}
+}
+
+func TestLinear(t *testing.T) {
+	var vars []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "lin_api_key" {
+			w.Write([]byte(`{"errors": [{"message": "Authentication required"}]}`))
+			return
+		}
+		var body struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		vars = append(vars, body.Variables)
+		switch {
+		case strings.Contains(body.Query, "issueCreate"):
+			w.Write([]byte(`{"data": {"issueCreate": {"success": true, "issue": {"id": "uuid-1", "identifier": "ENG-7", "url": "https://linear.app/acme/issue/ENG-7"}}}}`))
+		case strings.Contains(body.Query, "commentCreate"):
+			w.Write([]byte(`{"data": {"commentCreate": {"success": true}}}`))
+		case strings.Contains(body.Query, "issueUpdate"):
+			w.Write([]byte(`{"data": {"issueUpdate": {"success": false}}}`))
+		}
+	}))
+	defer srv.Close()
+
+	l := &Linear{APIURL: srv.URL, Token: "lin_api_key", LabelIDs: []string{"label-1"}, DoneStateID: "state-done"}
+	ctx := context.Background()
+	link, err := l.Create(ctx, Issue{Project: "team-1", Title: "threebody: t1"})
+	if err != nil || link.Key != "ENG-7" || link.ID != "uuid-1" || link.URL != "https://linear.app/acme/issue/ENG-7" {
+		t.Fatalf("Create = %+v, %v", link, err)
+	}
+	if input := vars[0]["input"].(map[string]interface{}); input["teamId"] != "team-1" || len(input["labelIds"].([]interface{})) != 1 {
+		t.Errorf("create input = %v", input)
+	}
+	if err := l.Comment(ctx, link, "Phase A -> B"); err != nil {
+		t.Fatalf("Comment: %v", err)
+	}
+	if input := vars[1]["input"].(map[string]interface{}); input["issueId"] != "uuid-1" {
+		t.Errorf("comment input = %v", input)
+	}
+	if err := l.Close(ctx, link); err == nil || !strings.Contains(err.Error(), "not successful") {
+		t.Errorf("Close = %v, want the unsuccessful update reported", err)
+	}
+	if vars[2]["id"] != "uuid-1" {
+		t.Errorf("close variables = %v", vars[2])
+	}
+
+	l.Token = "wrong"
+	if err := l.Comment(ctx, link, "x"); err == nil || !strings.Contains(err.Error(), "Authentication required") {
+		t.Errorf("Comment unauthorized = %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/notify"
+)
+
+// Notification events announced for flows.
+const (
+	NotifyFlowBlocked   = "flow_blocked"
+	NotifyFlowCompleted = "flow_completed"
+	NotifyFlowFailed    = "flow_failed"
+)
+
+// AnnounceFlows announces through notifier's routes each flow that blocks,
+// completes, or fails.
+func AnnounceFlows(engine *Engine, notifier *notify.Dispatcher) {
+	engine.AddListener(func(ctx context.Context, state domain.FlowState, from domain.Phase) {
+		var n notify.Notification
+		switch state.Status {
+		case domain.StatusBlocked:
+			var p domain.FlowBlockedPayload
+			latestPayload(ctx, engine, state.TaskID, domain.EventFlowBlocked, &p)
+			n = notify.Notification{
+				Event:   NotifyFlowBlocked,
+				Subject: fmt.Sprintf("%s is blocked in phase %s", state.TaskID, state.CurrentPhase),
+				Text:    p.Reason,
+				Data:    map[string]interface{}{"phase": string(state.CurrentPhase), "reason": p.Reason, "cause": p.Cause},
+			}
+		case domain.StatusDone:
+			n = notify.Notification{
+				Event:   NotifyFlowCompleted,
+				Subject: fmt.Sprintf("%s completed, spending $%.2f of $%.2f", state.TaskID, state.BudgetUsedUSD, state.BudgetCapUSD),
+				Data:    map[string]interface{}{"usedUsd": state.BudgetUsedUSD, "capUsd": state.BudgetCapUSD, "round": state.Round},
+			}
+		case domain.StatusFailed:
+			var pm domain.PostMortem
+			latestPayload(ctx, engine, state.TaskID, domain.EventFlowFailed, &pm)
+			n = notify.Notification{
+				Event:   NotifyFlowFailed,
+				Subject: fmt.Sprintf("%s failed in phase %s: %s", state.TaskID, state.CurrentPhase, pm.Cause),
+				Text:    pm.Detail,
+				Data:    map[string]interface{}{"phase": string(state.CurrentPhase), "cause": string(pm.Cause), "detail": pm.Detail, "actor": pm.Actor},
+			}
+		default:
+			return
+		}
+		n.TaskID = state.TaskID
+		if state.Title != "" {
+			n.Data["title"] = state.Title
+		}
+		n.Time = engine.Clock.Now().Unix()
+		notifier.Announce(n)
+	})
+}
+
+// latestPayload decodes the payload of the flow's latest event of type t
+// into v, leaving v as it is if there is none.
+func latestPayload(ctx context.Context, engine *Engine, taskID string, t domain.EventType, v interface{}) {
+	ev, err := engine.EventRepo.LatestByType(ctx, engine.DB, taskID, t)
+	if err != nil || ev == nil {
+		return
+	}
+	_ = json.Unmarshal([]byte(ev.PayloadJSON), v)
+}
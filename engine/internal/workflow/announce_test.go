@@ -0,0 +1,56 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/notify"
+)
+
+func TestAnnounceFlows(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	ops := &alertChannel{}
+	d := notify.NewDispatcher(map[string]notify.Channel{"ops": ops})
+	d.SetRoutes(map[string][]string{
+		NotifyFlowBlocked:   {"ops"},
+		NotifyFlowCompleted: {"ops"},
+		NotifyFlowFailed:    {"ops"},
+	})
+	AnnounceFlows(eng, d)
+
+	eng.StartFlow(ctx, "t1", 10)
+	eng.StartFlow(ctx, "t2", 10)
+	if err := eng.Block(ctx, "t1", "tests failing"); err != nil {
+		t.Fatalf("Block: %v", err)
+	}
+	eng.Unblock(ctx, "t1", "test")
+	for i := 0; i < 6; i++ {
+		if err := eng.Advance(ctx, "t1", domain.TransitionTrigger{Action: "advance", Actor: "test"}); err != nil {
+			t.Fatalf("advance %d: %v", i, err)
+		}
+	}
+	if _, err := eng.Fail(ctx, "t2", domain.FailureBudget, "cap reached", "ops"); err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+	d.Wait()
+
+	// Deliveries run concurrently, so index them by event.
+	sent := make(map[string]notify.Notification)
+	for _, n := range ops.sent {
+		sent[n.Event] = n
+	}
+	if len(ops.sent) != 3 || len(sent) != 3 {
+		t.Fatalf("sent %+v, want blocked, completed, and failed", ops.sent)
+	}
+	if n := sent[NotifyFlowBlocked]; n.TaskID != "t1" || n.Text != "tests failing" || n.Data["phase"] != "A" {
+		t.Errorf("blocked notification = %+v", n)
+	}
+	if n := sent[NotifyFlowCompleted]; n.TaskID != "t1" || n.Subject != "t1 completed, spending $0.00 of $10.00" {
+		t.Errorf("completed notification = %+v", n)
+	}
+	if n := sent[NotifyFlowFailed]; n.Subject != "t2 failed in phase A: budget" || n.Data["detail"] != "cap reached" {
+		t.Errorf("failed notification = %+v", n)
+	}
+}
@@ -0,0 +1,171 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// ApprovalGate wraps an inner gate and holds a flow in its phase until a
+// human approves it leaving. Only approvals of the flow's current round
+// count, so a flow sent back for rework needs a fresh one. Rollback and
+// rework triggers pass without an approval.
+type ApprovalGate struct {
+	Inner Gate
+	DB    *sql.DB
+	Repo  *store.ApprovalRepo
+}
+
+// Name returns the gate name.
+func (g *ApprovalGate) Name() string {
+	return "approval"
+}
+
+// Evaluate checks the inner gate first, then the phase's latest approval.
+func (g *ApprovalGate) Evaluate(ctx context.Context, state domain.FlowState) (domain.GateDecision, error) {
+	inner, err := g.Inner.Evaluate(ctx, state)
+	if err != nil || !inner.Allow {
+		return inner, err
+	}
+	if trigger, ok := triggerFrom(ctx); ok && trigger.Action != "advance" {
+		return inner, nil
+	}
+
+	approval, err := g.Repo.Latest(ctx, g.DB, state.TaskID, state.CurrentPhase, state.Round)
+	if err != nil {
+		return domain.GateDecision{}, err
+	}
+	switch {
+	case approval == nil:
+		return domain.GateDecision{
+			Allow:     false,
+			Blockers:  []string{fmt.Sprintf("phase %s is waiting for a human approval", state.CurrentPhase)},
+			Retryable: true,
+		}, nil
+	case approval.Decision == domain.ApprovalRejected:
+		return domain.GateDecision{
+			Allow:    false,
+			Blockers: []string{fmt.Sprintf("approval rejected by %s: %s", approval.Actor, approval.Comment)},
+		}, nil
+	}
+	return inner, nil
+}
+
+// ApprovalOutcome is the result of recording an approval. For a rejection,
+// ReworkTo is the phase the flow was sent back to, or ReworkError says why
+// it could not be.
+type ApprovalOutcome struct {
+	Approval    domain.Approval `json:"approval"`
+	ReworkTo    domain.Phase    `json:"reworkTo,omitempty"`
+	ReworkError string          `json:"reworkError,omitempty"`
+}
+
+// Approvals records human decisions on the phases that require one and
+// sends a flow back when its work is rejected.
+type Approvals struct {
+	Engine *Engine
+	Repo   *store.ApprovalRepo
+	// Phases are the phases whose exit needs an approval.
+	Phases map[domain.Phase]bool
+}
+
+// NewApprovals creates an Approvals for phases and registers an
+// ApprovalGate over the engine's gate for each.
+func NewApprovals(engine *Engine, phases []domain.Phase) *Approvals {
+	a := &Approvals{Engine: engine, Repo: &store.ApprovalRepo{}, Phases: make(map[domain.Phase]bool)}
+	for _, phase := range phases {
+		inner, err := engine.GateRegistry.Get(phase)
+		if err != nil {
+			continue
+		}
+		engine.GateRegistry.Register(phase, &ApprovalGate{Inner: inner, DB: engine.DB, Repo: a.Repo})
+		a.Phases[phase] = true
+	}
+	return a
+}
+
+// Record stores actor's decision on the flow's current phase. An approval
+// lets the phase gate pass and is announced on the bus so waiting flows
+// advance. A rejection sends the flow back: phase F is reworked, D rolled
+// back to C, and any other phase rolled back to the one before it, with
+// comment in the transition payload. A flow blocked on its gate is
+// unblocked first.
+func (a *Approvals) Record(ctx context.Context, taskID, actor, decision, comment string) (*ApprovalOutcome, error) {
+	if actor == "" {
+		return nil, domain.NewEngineError(domain.ErrApprovalInvalid.Code, "actor is required")
+	}
+	if decision != domain.ApprovalApproved && decision != domain.ApprovalRejected {
+		return nil, domain.NewEngineError(domain.ErrApprovalInvalid.Code,
+			fmt.Sprintf("decision must be %q or %q", domain.ApprovalApproved, domain.ApprovalRejected))
+	}
+	state, err := a.Engine.TaskRepo.GetByID(ctx, a.Engine.DB, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if !a.Phases[state.CurrentPhase] {
+		return nil, domain.NewEngineError(domain.ErrApprovalInvalid.Code,
+			fmt.Sprintf("phase %s does not require approval", state.CurrentPhase))
+	}
+	if state.Status == domain.StatusDone {
+		return nil, domain.ErrFlowAlreadyDone
+	}
+
+	approval := domain.Approval{
+		TaskID:    taskID,
+		Phase:     state.CurrentPhase,
+		Round:     state.Round,
+		Actor:     actor,
+		Decision:  decision,
+		Comment:   comment,
+		CreatedAt: a.Engine.Clock.Now().Unix(),
+	}
+	if approval.ID, err = a.Repo.Create(ctx, a.Engine.DB, approval); err != nil {
+		return nil, err
+	}
+	out := &ApprovalOutcome{Approval: approval}
+	if decision == domain.ApprovalApproved {
+		a.Engine.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicApprovalRecorded, TaskID: taskID})
+		return out, nil
+	}
+
+	trigger, ok := reworkTrigger(state.CurrentPhase)
+	if !ok {
+		return out, nil
+	}
+	trigger.Actor = actor
+	trigger.Payload, _ = json.Marshal(map[string]string{"comment": comment})
+	if state.Status == domain.StatusBlocked {
+		if err := a.Engine.Unblock(ctx, taskID, actor); err != nil {
+			out.ReworkError = err.Error()
+			return out, nil
+		}
+	}
+	if err := a.Engine.Advance(ctx, taskID, trigger); err != nil {
+		out.ReworkError = err.Error()
+		return out, nil
+	}
+	out.ReworkTo, _ = resolveNextPhase(state.CurrentPhase, trigger)
+	return out, nil
+}
+
+// reworkTrigger returns the trigger that sends a flow in phase back for
+// rework, or false for phase A, which has nothing before it.
+func reworkTrigger(phase domain.Phase) (domain.TransitionTrigger, bool) {
+	switch phase {
+	case domain.PhaseF:
+		return domain.TransitionTrigger{Action: "rework"}, true
+	case domain.PhaseD:
+		return domain.TransitionTrigger{Action: "rollback"}, true
+	}
+	for p, i := range phaseOrder {
+		if i == phaseOrder[phase]-1 {
+			return domain.TransitionTrigger{Action: "rollback", RollbackTo: p}, true
+		}
+	}
+	return domain.TransitionTrigger{}, false
+}
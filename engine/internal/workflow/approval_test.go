@@ -0,0 +1,104 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// advanceTo moves task-1 forward until it reaches phase.
+func advanceTo(t *testing.T, eng *Engine, phase domain.Phase) {
+	t.Helper()
+	ctx := context.Background()
+	for {
+		state, err := eng.GetState(ctx, "task-1")
+		if err != nil {
+			t.Fatalf("GetState: %v", err)
+		}
+		if state.CurrentPhase == phase {
+			return
+		}
+		if err := eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "test"}); err != nil {
+			t.Fatalf("Advance from %s: %v", state.CurrentPhase, err)
+		}
+	}
+}
+
+func TestApprovals_ApproveLetsFlowLeave(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	approvals := NewApprovals(eng, []domain.Phase{domain.PhaseF})
+	eng.StartFlow(ctx, "task-1", 10.0)
+	advanceTo(t, eng, domain.PhaseF)
+	eng.Clock = clock.NewFake(time.Unix(1_700_000_000, 0))
+
+	trigger := domain.TransitionTrigger{Action: "advance", Actor: "test"}
+	if err := eng.Advance(ctx, "task-1", trigger); err == nil {
+		t.Fatal("expected F to wait for an approval")
+	}
+	if _, err := approvals.Record(ctx, "task-1", "alice", "maybe", ""); err == nil {
+		t.Error("expected an unknown decision to be refused")
+	}
+	out, err := approvals.Record(ctx, "task-1", "alice", domain.ApprovalApproved, "")
+	if err != nil || out.Approval.ID == 0 || out.Approval.Phase != domain.PhaseF || out.ReworkTo != "" {
+		t.Fatalf("Record = %+v, %v", out, err)
+	}
+	if out.Approval.CreatedAt != 1_700_000_000 {
+		t.Errorf("CreatedAt = %d, want the engine clock's time", out.Approval.CreatedAt)
+	}
+	if err := eng.Advance(ctx, "task-1", trigger); err != nil {
+		t.Fatalf("Advance after approval: %v", err)
+	}
+	if _, err := approvals.Record(ctx, "task-1", "alice", domain.ApprovalApproved, ""); err == nil {
+		t.Error("expected phase G to need no approval")
+	}
+}
+
+func TestApprovals_RejectTriggersRework(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	approvals := NewApprovals(eng, []domain.Phase{domain.PhaseF})
+	eng.StartFlow(ctx, "task-1", 10.0)
+	advanceTo(t, eng, domain.PhaseF)
+	if err := eng.BlockOnGate(ctx, "task-1", "waiting for approval"); err != nil {
+		t.Fatalf("BlockOnGate: %v", err)
+	}
+
+	out, err := approvals.Record(ctx, "task-1", "alice", domain.ApprovalRejected, "error handling is missing")
+	if err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if out.ReworkTo != domain.PhaseE || out.ReworkError != "" {
+		t.Fatalf("outcome = %+v, want rework to E", out)
+	}
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.CurrentPhase != domain.PhaseE || state.Status != domain.StatusRunning || state.Round != 1 {
+		t.Fatalf("state = %s/%s round %d, want running in E round 1", state.CurrentPhase, state.Status, state.Round)
+	}
+
+	// The rejection belonged to the earlier round, so F waits again.
+	advanceTo(t, eng, domain.PhaseF)
+	err = eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "test"})
+	if err == nil {
+		t.Fatal("expected F to wait for a fresh approval")
+	}
+}
+
+func TestReworkTrigger(t *testing.T) {
+	cases := map[domain.Phase]domain.TransitionTrigger{
+		domain.PhaseF: {Action: "rework"},
+		domain.PhaseD: {Action: "rollback"},
+		domain.PhaseC: {Action: "rollback", RollbackTo: domain.PhaseB},
+	}
+	for phase, want := range cases {
+		if got, ok := reworkTrigger(phase); !ok || got.Action != want.Action || got.RollbackTo != want.RollbackTo {
+			t.Errorf("reworkTrigger(%s) = %+v, %v; want %+v", phase, got, ok, want)
+		}
+	}
+	if _, ok := reworkTrigger(domain.PhaseA); ok {
+		t.Error("expected no rework before phase A")
+	}
+}
@@ -0,0 +1,76 @@
+package workflow
+
+import (
+	"context"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+)
+
+// AutoAdvancer re-evaluates the current phase gate of auto-advance flows when
+// relevant signals arrive and advances them without a human trigger.
+type AutoAdvancer struct {
+	Engine *Engine
+	Bus    *eventbus.Bus
+}
+
+// NewAutoAdvancer creates an AutoAdvancer for the given engine and bus.
+func NewAutoAdvancer(engine *Engine, bus *eventbus.Bus) *AutoAdvancer {
+	return &AutoAdvancer{Engine: engine, Bus: bus}
+}
+
+// autoAdvanceTopics are the signals that can satisfy a previously blocked gate.
+var autoAdvanceTopics = map[eventbus.Topic]bool{
+	eventbus.TopicReviewSubmitted:  true,
+	eventbus.TopicIntentDone:       true,
+	eventbus.TopicChildDone:        true,
+	eventbus.TopicApprovalRecorded: true,
+	eventbus.TopicCustomEvent:      true,
+	eventbus.TopicCIReported:       true,
+}
+
+// Start subscribes to the bus and processes signals until ctx is cancelled.
+// A child flow reaching a terminal phase signals its parent, whose join gate
+// may now be satisfied.
+func (a *AutoAdvancer) Start(ctx context.Context) {
+	a.Engine.AddListener(func(_ context.Context, state domain.FlowState, _ domain.Phase) {
+		if state.ParentTaskID != "" && (state.Status == domain.StatusDone || state.Status == domain.StatusFailed) {
+			a.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicChildDone, TaskID: state.ParentTaskID})
+		}
+	})
+
+	signals, unsubscribe := a.Bus.Subscribe(64)
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				if autoAdvanceTopics[sig.Topic] {
+					_, _ = a.TryAdvance(ctx, sig.TaskID)
+				}
+			}
+		}
+	}()
+}
+
+// TryAdvance advances the flow if the task has auto-advance enabled and its
+// current gate allows it. The gate is evaluated once, by the transition,
+// which also records an "auto_advanced" event carrying the gate decision.
+func (a *AutoAdvancer) TryAdvance(ctx context.Context, taskID string) (bool, error) {
+	state, err := a.Engine.GetState(ctx, taskID)
+	if err != nil {
+		return false, err
+	}
+	if !state.AutoAdvance || state.Status != domain.StatusRunning || state.CurrentPhase == domain.PhaseG {
+		return false, nil
+	}
+
+	trigger := domain.TransitionTrigger{Action: "advance", Actor: "auto"}
+	err = a.Engine.advance(ctx, taskID, trigger, true)
+	if engErr, ok := err.(*domain.EngineError); ok && engErr.Code == domain.ErrPhaseGateFailed.Code {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,113 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+)
+
+func TestAutoAdvancer_SkipsFlowsWithoutOptIn(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+
+	a := NewAutoAdvancer(eng, eventbus.New())
+	advanced, err := a.TryAdvance(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("TryAdvance: %v", err)
+	}
+	if advanced {
+		t.Error("expected no advance for a flow without auto_advance")
+	}
+}
+
+func TestAutoAdvancer_BlockedGateStaysInPhase(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlowWithOptions(ctx, "task-1", 10.0, FlowOptions{AutoAdvance: true})
+	eng.GateRegistry.Register(domain.PhaseA, &stubGate{name: "stub", allow: false, blockers: []string{"pending"}})
+
+	a := NewAutoAdvancer(eng, eventbus.New())
+	advanced, err := a.TryAdvance(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("TryAdvance: %v", err)
+	}
+	if advanced {
+		t.Error("expected no advance while gate blocks")
+	}
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.CurrentPhase != domain.PhaseA {
+		t.Errorf("Phase = %q, want A", state.CurrentPhase)
+	}
+}
+
+func TestAutoAdvancer_AdvancesOnSignal(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := eng.StartFlowWithOptions(ctx, "task-1", 10.0, FlowOptions{AutoAdvance: true}); err != nil {
+		t.Fatalf("StartFlowWithOptions: %v", err)
+	}
+	state, _ := eng.GetState(ctx, "task-1")
+	if !state.AutoAdvance {
+		t.Fatal("expected AutoAdvance to be persisted")
+	}
+
+	bus := eventbus.New()
+	NewAutoAdvancer(eng, bus).Start(ctx)
+	bus.Publish(eventbus.Signal{Topic: eventbus.TopicReviewSubmitted, TaskID: "task-1"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		state, _ = eng.GetState(ctx, "task-1")
+		if state.CurrentPhase == domain.PhaseB {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if state.CurrentPhase != domain.PhaseB {
+		t.Fatalf("Phase = %q, want B", state.CurrentPhase)
+	}
+
+	var events []domain.WorkflowEvent
+	for time.Now().Before(deadline) {
+		events, _ = eng.EventRepo.ListByTask(ctx, eng.DB, "task-1", 0)
+		if len(events) > 0 && events[len(events)-1].EventType == "auto_advanced" {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected trailing auto_advanced event, got %d events", len(events))
+}
+
+func TestAutoAdvancer_RecordsDecisionWithTransition(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlowWithOptions(ctx, "task-1", 10.0, FlowOptions{AutoAdvance: true})
+
+	advanced, err := NewAutoAdvancer(eng, eventbus.New()).TryAdvance(ctx, "task-1")
+	if err != nil || !advanced {
+		t.Fatalf("TryAdvance = %v, %v; want true", advanced, err)
+	}
+
+	records, _ := eng.GateRepo.ListByTask(ctx, eng.DB, "task-1", domain.PhaseA, 0)
+	if len(records) != 1 {
+		t.Errorf("gate decisions = %d, want one evaluation", len(records))
+	}
+	events, _ := eng.EventRepo.ListByTask(ctx, eng.DB, "task-1", 0)
+	if len(events) != 3 || events[1].EventType != domain.EventPhaseTransition || events[2].EventType != domain.EventAutoAdvanced {
+		t.Fatalf("events = %+v, want flow_started, phase_transition, auto_advanced", events)
+	}
+	if want := `"from":"A","to":"B"`; !strings.Contains(events[2].PayloadJSON, want) {
+		t.Errorf("auto_advanced payload = %s, want %s", events[2].PayloadJSON, want)
+	}
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.LastEventSeq != events[2].SeqNo {
+		t.Errorf("LastEventSeq = %d, want %d", state.LastEventSeq, events[2].SeqNo)
+	}
+}
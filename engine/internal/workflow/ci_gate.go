@@ -0,0 +1,168 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// CISource polls a CI provider for the checks on a branch.
+type CISource interface {
+	Checks(ctx context.Context, ref string) ([]domain.CICheck, error)
+}
+
+// CIGate wraps an inner gate and holds a flow in its phase until CI reports
+// success for the flow's branch. With a Source the checks are polled on each
+// evaluation; without one they are the results reported through the CI
+// webhook. Failing and pending checks are reported by name as blockers.
+// Only advances are held; rollbacks and rework pass through.
+type CIGate struct {
+	Inner     Gate
+	DB        *sql.DB
+	CheckRepo *store.CICheckRepo
+	TaskRepo  *store.TaskRepo
+	Source    CISource
+	// Branch names the branch CI runs on for a task.
+	Branch func(taskID string) string
+	// Required names the checks that must pass. Empty means every check
+	// reported, of which there must be at least one.
+	Required []string
+	Clock    clock.Clock
+}
+
+// NewCIGate creates a CIGate wrapping inner.
+func NewCIGate(db *sql.DB, inner Gate, branch func(taskID string) string) *CIGate {
+	return &CIGate{
+		Inner:     inner,
+		DB:        db,
+		CheckRepo: &store.CICheckRepo{},
+		TaskRepo:  &store.TaskRepo{},
+		Branch:    branch,
+	}
+}
+
+// Name returns the gate name.
+func (g *CIGate) Name() string {
+	return "ci"
+}
+
+// Evaluate checks the inner gate first, then the flow's CI checks. A dry
+// run polls the provider but does not store the results.
+func (g *CIGate) Evaluate(ctx context.Context, state domain.FlowState) (domain.GateDecision, error) {
+	inner, err := g.Inner.Evaluate(ctx, state)
+	if err != nil || !inner.Allow {
+		return inner, err
+	}
+	if trigger, ok := triggerFrom(ctx); ok && trigger.Action != "advance" {
+		return inner, nil
+	}
+
+	var checks []domain.CICheck
+	if g.Source != nil {
+		checks, err = g.poll(ctx, state.TaskID)
+		if err != nil {
+			return domain.GateDecision{Allow: false, Retryable: true, Blockers: []string{"CI status unavailable: " + err.Error()}}, nil
+		}
+		if !IsDryRun(ctx) {
+			if _, err := g.CheckRepo.Replace(ctx, g.DB, state.TaskID, checks); err != nil {
+				return domain.GateDecision{}, err
+			}
+		}
+	} else if checks, err = g.CheckRepo.ListByTask(ctx, g.DB, state.TaskID); err != nil {
+		return domain.GateDecision{}, err
+	}
+
+	if blockers := g.blockers(state.TaskID, checks); len(blockers) > 0 {
+		return domain.GateDecision{Allow: false, Retryable: true, Blockers: blockers}, nil
+	}
+	return inner, nil
+}
+
+// blockers lists what keeps checks from counting as a CI success.
+func (g *CIGate) blockers(taskID string, checks []domain.CICheck) []string {
+	byName := make(map[string]domain.CICheck, len(checks))
+	for _, c := range checks {
+		byName[c.Name] = c
+	}
+	names := g.Required
+	if len(names) == 0 {
+		if len(checks) == 0 {
+			return []string{"no CI checks reported for branch " + g.Branch(taskID)}
+		}
+		names = make([]string, len(checks))
+		for i, c := range checks {
+			names[i] = c.Name
+		}
+	}
+
+	var blockers []string
+	for _, name := range names {
+		c, ok := byName[name]
+		switch {
+		case !ok:
+			blockers = append(blockers, fmt.Sprintf("CI check %s has not reported", name))
+		case c.Status == domain.CIFailure:
+			blockers = append(blockers, fmt.Sprintf("CI check %s failed", name))
+		case c.Status != domain.CISuccess:
+			blockers = append(blockers, fmt.Sprintf("CI check %s is pending", name))
+		}
+	}
+	return blockers
+}
+
+// poll reads the checks on a task's branch from the Source.
+func (g *CIGate) poll(ctx context.Context, taskID string) ([]domain.CICheck, error) {
+	checks, err := g.Source.Checks(ctx, g.Branch(taskID))
+	if err != nil {
+		return nil, err
+	}
+	now := clock.Or(g.Clock).Now().Unix()
+	for i := range checks {
+		checks[i].TaskID = taskID
+		checks[i].UpdatedAt = now
+	}
+	return checks, nil
+}
+
+// Poll refreshes the stored checks of every running or blocked flow in
+// phase E every interval, until ctx is cancelled, and announces the flows
+// whose checks changed so their gates are evaluated again. It does nothing
+// without a Source.
+func (g *CIGate) Poll(ctx context.Context, bus *eventbus.Bus, interval time.Duration) {
+	if g.Source == nil {
+		return
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-clock.Or(g.Clock).After(interval):
+			}
+			for _, status := range []domain.FlowStatus{domain.StatusRunning, domain.StatusBlocked} {
+				flows, err := g.TaskRepo.ListByStatus(ctx, g.DB, status)
+				if err != nil {
+					continue
+				}
+				for _, state := range flows {
+					if state.CurrentPhase != domain.PhaseE {
+						continue
+					}
+					checks, err := g.poll(ctx, state.TaskID)
+					if err != nil {
+						continue
+					}
+					if changed, err := g.CheckRepo.Replace(ctx, g.DB, state.TaskID, checks); err == nil && changed {
+						bus.Publish(eventbus.Signal{Topic: eventbus.TopicCIReported, TaskID: state.TaskID})
+					}
+				}
+			}
+		}
+	}()
+}
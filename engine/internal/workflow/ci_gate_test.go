@@ -0,0 +1,184 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+)
+
+// fakeCI is a CISource returning fixed checks.
+type fakeCI struct {
+	mu     sync.Mutex
+	refs   []string
+	checks []domain.CICheck
+	err    error
+}
+
+func (f *fakeCI) Checks(ctx context.Context, ref string) ([]domain.CICheck, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refs = append(f.refs, ref)
+	return append([]domain.CICheck(nil), f.checks...), f.err
+}
+
+func (f *fakeCI) set(checks ...domain.CICheck) {
+	f.mu.Lock()
+	f.checks = checks
+	f.mu.Unlock()
+}
+
+func newCIGate(t *testing.T, eng *Engine, source CISource) *CIGate {
+	t.Helper()
+	inner, _ := eng.GateRegistry.Get(domain.PhaseE)
+	gate := NewCIGate(eng.DB, inner, func(taskID string) string { return "threebody/" + taskID })
+	if source != nil {
+		gate.Source = source
+	}
+	eng.GateRegistry.Register(domain.PhaseE, gate)
+	return gate
+}
+
+// toPhaseE starts a flow and advances it to phase E.
+func toPhaseE(t *testing.T, eng *Engine, taskID string) {
+	t.Helper()
+	ctx := context.Background()
+	eng.StartFlow(ctx, taskID, 10.0)
+	for i := 0; i < 4; i++ {
+		if err := eng.Advance(ctx, taskID, domain.TransitionTrigger{Action: "advance", Actor: "test"}); err != nil {
+			t.Fatalf("advance to E: %v", err)
+		}
+	}
+}
+
+func TestCIGate_Polling(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	source := &fakeCI{checks: []domain.CICheck{
+		{Name: "unit", Status: domain.CISuccess},
+		{Name: "lint", Status: domain.CIFailure},
+		{Name: "e2e", Status: domain.CIPending},
+	}}
+	gate := newCIGate(t, eng, source)
+	toPhaseE(t, eng, "task-1")
+	state, _ := eng.GetState(ctx, "task-1")
+
+	decision, err := gate.Evaluate(WithDryRun(ctx), *state)
+	want := []string{"CI check lint failed", "CI check e2e is pending"}
+	if err != nil || decision.Allow || !reflect.DeepEqual(decision.Blockers, want) || !decision.Retryable {
+		t.Fatalf("decision = %+v, %v; want blockers %v", decision, err, want)
+	}
+	if source.refs[0] != "threebody/task-1" {
+		t.Errorf("polled ref %q", source.refs[0])
+	}
+	if checks, _ := gate.CheckRepo.ListByTask(ctx, eng.DB, "task-1"); len(checks) != 0 {
+		t.Errorf("dry run stored checks %+v", checks)
+	}
+
+	err = eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "test"})
+	if err == nil {
+		t.Fatal("Advance passed failing CI")
+	}
+	if checks, _ := gate.CheckRepo.ListByTask(ctx, eng.DB, "task-1"); len(checks) != 3 {
+		t.Errorf("stored checks = %+v", checks)
+	}
+
+	gate.Required = []string{"unit", "build"}
+	decision, _ = gate.Evaluate(ctx, *state)
+	if !reflect.DeepEqual(decision.Blockers, []string{"CI check build has not reported"}) {
+		t.Errorf("blockers with required checks = %v", decision.Blockers)
+	}
+
+	source.set(domain.CICheck{Name: "unit", Status: domain.CISuccess}, domain.CICheck{Name: "build", Status: domain.CISuccess})
+	if err := eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "test"}); err != nil {
+		t.Fatalf("Advance once CI passes: %v", err)
+	}
+
+	source.err = errors.New("connection refused")
+	toPhaseE(t, eng, "task-2")
+	state, _ = eng.GetState(ctx, "task-2")
+	decision, _ = gate.Evaluate(ctx, *state)
+	if decision.Allow || decision.Blockers[0] != "CI status unavailable: connection refused" {
+		t.Errorf("decision when CI is down = %+v", decision)
+	}
+}
+
+func TestCIGate_WebhookResultsAndRollback(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	gate := newCIGate(t, eng, nil)
+	toPhaseE(t, eng, "task-1")
+	state, _ := eng.GetState(ctx, "task-1")
+
+	decision, _ := gate.Evaluate(ctx, *state)
+	if decision.Allow || decision.Blockers[0] != "no CI checks reported for branch threebody/task-1" {
+		t.Errorf("decision with no reports = %+v", decision)
+	}
+	if err := eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "rollback", Actor: "test", RollbackTo: domain.PhaseC}); err != nil {
+		t.Errorf("rollback held by the CI gate: %v", err)
+	}
+
+	toPhaseE(t, eng, "task-2")
+	gate.CheckRepo.Upsert(ctx, eng.DB, domain.CICheck{TaskID: "task-2", Name: "unit", Status: domain.CISuccess})
+	if err := eng.Advance(ctx, "task-2", domain.TransitionTrigger{Action: "advance", Actor: "test"}); err != nil {
+		t.Errorf("Advance with reported success: %v", err)
+	}
+}
+
+func TestCIGate_PollAnnouncesChanges(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := &fakeCI{checks: []domain.CICheck{{Name: "unit", Status: domain.CIPending}}}
+	gate := newCIGate(t, eng, source)
+	fake := clock.NewFake(time.Unix(1000, 0))
+	gate.Clock = fake
+	toPhaseE(t, eng, "task-1")
+	eng.StartFlow(ctx, "task-2", 10.0)
+
+	bus := eventbus.New()
+	signals, unsubscribe := bus.Subscribe(8)
+	defer unsubscribe()
+	gate.Poll(ctx, bus, time.Minute)
+
+	tick := func() {
+		deadline := time.Now().Add(time.Second)
+		for fake.Waiters() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("poller is not waiting")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		fake.Advance(time.Minute)
+	}
+	expect := func(want bool) {
+		t.Helper()
+		select {
+		case sig := <-signals:
+			if !want || sig.Topic != eventbus.TopicCIReported || sig.TaskID != "task-1" {
+				t.Errorf("signal = %+v, want one: %v", sig, want)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if want {
+				t.Error("no signal for changed checks")
+			}
+		}
+	}
+
+	tick()
+	expect(true)
+	tick()
+	expect(false)
+	source.set(domain.CICheck{Name: "unit", Status: domain.CISuccess})
+	tick()
+	expect(true)
+	if len(source.refs) != 3 {
+		t.Errorf("polled refs = %v, want only task-1's branch each tick", source.refs)
+	}
+}
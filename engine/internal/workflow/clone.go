@@ -0,0 +1,104 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// CloneFlow creates flow taskID from the snapshot source took on entering
+// fromPhase, so a failed run can be retried without starting again from A.
+// The clone starts running in fromPhase with source's spec (title,
+// description, acceptance criteria, consensus, namespace, priority, and
+// auto-advance) and the latest version of each artifact source had when
+// it entered fromPhase. Its budget is fresh: budgetCapUSD, or source's cap
+// when 0. Phase A needs no snapshot; every other phase needs one.
+func (e *Engine) CloneFlow(ctx context.Context, sourceID, taskID string, fromPhase domain.Phase, budgetCapUSD float64) error {
+	if _, ok := phaseOrder[fromPhase]; !ok || fromPhase == domain.PhaseG {
+		return domain.NewEngineError(domain.ErrCloneInvalid.Code,
+			fmt.Sprintf("cannot clone from phase %q; want A through F", fromPhase))
+	}
+	source, err := e.TaskRepo.GetByID(ctx, e.DB, sourceID)
+	if err != nil {
+		return err
+	}
+	if _, err := e.TaskRepo.GetByID(ctx, e.DB, taskID); err == nil {
+		return domain.ErrDuplicateTask
+	}
+	if budgetCapUSD == 0 {
+		budgetCapUSD = source.BudgetCapUSD
+	}
+	if budgetCapUSD < 0 {
+		return domain.NewEngineError(domain.ErrInvalidBudget.Code,
+			fmt.Sprintf("budget cap %.2f must be positive", budgetCapUSD))
+	}
+
+	snap, err := e.SnapshotRepo.GetLatest(ctx, e.DB, sourceID, fromPhase)
+	if err != nil {
+		return err
+	}
+	if snap == nil && fromPhase != domain.PhaseA {
+		return domain.NewEngineError(domain.ErrCloneInvalid.Code,
+			fmt.Sprintf("flow %s has no snapshot of phase %s", sourceID, fromPhase))
+	}
+
+	now := e.Clock.Now().Unix()
+	payload := domain.FlowClonedPayload{Source: sourceID, Phase: fromPhase}
+	var seed flowSeed
+	if snap != nil {
+		payload.Snapshot = snap.ID
+		artifacts, err := e.ArtifactRepo.ListLatestAt(ctx, e.DB, sourceID, snap.CreatedAt)
+		if err != nil {
+			return err
+		}
+		for i, a := range artifacts {
+			a.ID = fmt.Sprintf("%s-%s", a.ID, taskID)
+			a.TaskID = taskID
+			artifacts[i] = a
+		}
+		seed.artifacts = artifacts
+		snapJSON, err := json.Marshal(map[string]interface{}{"cloned_from": sourceID, "snapshot": snap.ID})
+		if err != nil {
+			return fmt.Errorf("marshal snapshot: %w", err)
+		}
+		seed.snapshot = &domain.PhaseSnapshot{
+			TaskID:       taskID,
+			Phase:        fromPhase,
+			SnapshotJSON: string(snapJSON),
+			CreatedAt:    now,
+		}
+	}
+	payload.Artifacts = len(seed.artifacts)
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal clone payload: %w", err)
+	}
+	seed.payload = string(data)
+
+	state := domain.FlowState{
+		TaskID:        taskID,
+		CurrentPhase:  fromPhase,
+		Status:        domain.StatusRunning,
+		StateVersion:  1,
+		BudgetCapUSD:  budgetCapUSD,
+		LastEventSeq:  1, // The initial flow_cloned event uses seq 1.
+		UpdatedAtUnix: now,
+		AutoAdvance:   source.AutoAdvance,
+		Priority:      source.Priority,
+
+		Title:              source.Title,
+		Description:        source.Description,
+		AcceptanceCriteria: source.AcceptanceCriteria,
+		Consensus:          source.Consensus,
+		Namespace:          source.Namespace,
+	}
+	if err := e.insertFlow(ctx, state, domain.EventFlowCloned, seed); err != nil {
+		return err
+	}
+	return e.AppendEvent(ctx, sourceID, domain.EventCloneCreated, domain.CloneCreatedPayload{
+		Clone: taskID,
+		Phase: fromPhase,
+	})
+}
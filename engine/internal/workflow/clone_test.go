@@ -0,0 +1,105 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestEngine_CloneFlow(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+
+	opts := FlowOptions{Title: "Add search", AcceptanceCriteria: "results in 100ms", Priority: 3}
+	if err := eng.StartFlowWithOptions(ctx, "src", 20, opts); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	if _, err := eng.ArtifactRepo.Create(ctx, eng.DB, domain.ArtifactRef{ID: "a1", TaskID: "src", Path: "spec.md", Hash: "h1", CreatedAt: 1}); err != nil {
+		t.Fatalf("create artifact: %v", err)
+	}
+	trigger := domain.TransitionTrigger{Action: "advance", Actor: "test"}
+	for i := 0; i < 3; i++ {
+		if err := eng.Advance(ctx, "src", trigger); err != nil {
+			t.Fatalf("Advance: %v", err)
+		}
+	}
+	// Produced after the flow entered C, so not carried into the clone.
+	later := time.Now().Unix() + 60
+	if _, err := eng.ArtifactRepo.Create(ctx, eng.DB, domain.ArtifactRef{ID: "a2", TaskID: "src", Path: "spec.md", Hash: "h2", CreatedAt: later}); err != nil {
+		t.Fatalf("create artifact: %v", err)
+	}
+
+	if err := eng.CloneFlow(ctx, "src", "retry", domain.PhaseC, 0); err != nil {
+		t.Fatalf("CloneFlow: %v", err)
+	}
+	clone, err := eng.GetState(ctx, "retry")
+	if err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if clone.CurrentPhase != domain.PhaseC || clone.Status != domain.StatusRunning || clone.BudgetCapUSD != 20 || clone.BudgetUsedUSD != 0 {
+		t.Errorf("clone = %+v, want running in C with a fresh $20 budget", clone)
+	}
+	if clone.Title != "Add search" || clone.AcceptanceCriteria != "results in 100ms" || clone.Priority != 3 {
+		t.Errorf("clone spec = %+v", clone)
+	}
+
+	artifacts, err := eng.ArtifactRepo.ListLatest(ctx, eng.DB, "retry")
+	if err != nil {
+		t.Fatalf("ListLatest: %v", err)
+	}
+	if len(artifacts) != 1 || artifacts[0].Hash != "h1" || artifacts[0].TaskID != "retry" {
+		t.Errorf("clone artifacts = %+v, want spec.md as of phase C", artifacts)
+	}
+	stays, err := eng.PhaseDurations(ctx, "retry")
+	if err != nil {
+		t.Fatalf("PhaseDurations: %v", err)
+	}
+	if len(stays) != 1 || stays[0].Phase != domain.PhaseC {
+		t.Errorf("clone stays = %+v, want one in C", stays)
+	}
+	ev, err := eng.EventRepo.LatestByType(ctx, eng.DB, "src", "clone_created")
+	if err != nil || ev == nil {
+		t.Errorf("source clone_created event = %v, %v", ev, err)
+	}
+
+	// The clone advances like any other flow.
+	if err := eng.Advance(ctx, "retry", trigger); err != nil {
+		t.Fatalf("Advance clone: %v", err)
+	}
+}
+
+func TestEngine_CloneFlowInvalid(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	if err := eng.StartFlow(ctx, "src", 10); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+
+	isCode := func(err error, want *domain.EngineError) bool {
+		var engErr *domain.EngineError
+		return errors.As(err, &engErr) && engErr.Code == want.Code
+	}
+	if err := eng.CloneFlow(ctx, "src", "c1", domain.PhaseG, 0); !isCode(err, domain.ErrCloneInvalid) {
+		t.Errorf("from G: err = %v, want ErrCloneInvalid", err)
+	}
+	if err := eng.CloneFlow(ctx, "src", "c1", domain.PhaseD, 0); !isCode(err, domain.ErrCloneInvalid) {
+		t.Errorf("from a phase never entered: err = %v, want ErrCloneInvalid", err)
+	}
+	if err := eng.CloneFlow(ctx, "missing", "c1", domain.PhaseA, 0); !isCode(err, domain.ErrFlowNotFound) {
+		t.Errorf("missing source: err = %v, want ErrFlowNotFound", err)
+	}
+	if err := eng.CloneFlow(ctx, "src", "src", domain.PhaseA, 0); !isCode(err, domain.ErrDuplicateTask) {
+		t.Errorf("existing task: err = %v, want ErrDuplicateTask", err)
+	}
+
+	// Phase A needs no snapshot.
+	if err := eng.CloneFlow(ctx, "src", "c1", domain.PhaseA, 5); err != nil {
+		t.Fatalf("clone from A: %v", err)
+	}
+	if state, _ := eng.GetState(ctx, "c1"); state == nil || state.BudgetCapUSD != 5 {
+		t.Errorf("clone from A = %+v, want a $5 cap", state)
+	}
+}
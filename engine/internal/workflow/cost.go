@@ -3,55 +3,95 @@ package workflow
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"sync"
 
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+	"github.com/anthropics/three-body-engine/internal/notify"
 	"github.com/anthropics/three-body-engine/internal/store"
 )
 
+// BudgetAlertRule is a named budget threshold announced to notification
+// channels the first time a task's spend reaches Ratio of its cap.
+type BudgetAlertRule struct {
+	Name     string
+	Ratio    float64
+	Channels []string
+}
+
 // BudgetGovernor enforces budget limits for workflow tasks.
 type BudgetGovernor struct {
-	DB       *sql.DB
-	TaskRepo *store.TaskRepo
+	DB            *sql.DB
+	TaskRepo      *store.TaskRepo
+	CostDeltaRepo *store.CostDeltaRepo
 
 	// WarnRatio is the fraction of budget at which a warning is issued (default 0.8).
 	WarnRatio float64
 	// HaltRatio is the fraction of budget at which execution is halted (default 1.0).
 	HaltRatio float64
+	// Bus, when set, receives a TopicFlowUpdated signal after usage is recorded.
+	Bus *eventbus.Bus
+	// AlertRepo records which budget alerts have fired.
+	AlertRepo *store.BudgetAlertRepo
+	// Notifier, when set, delivers budget alerts to their channels.
+	Notifier *notify.Dispatcher
+	Clock    clock.Clock
+
+	mu     sync.RWMutex
+	alerts []BudgetAlertRule
 }
 
 // NewBudgetGovernor creates a governor with standard thresholds.
 func NewBudgetGovernor(db *sql.DB) *BudgetGovernor {
 	return &BudgetGovernor{
-		DB:        db,
-		TaskRepo:  &store.TaskRepo{},
-		WarnRatio: 0.8,
-		HaltRatio: 1.0,
+		DB:            db,
+		TaskRepo:      &store.TaskRepo{},
+		CostDeltaRepo: &store.CostDeltaRepo{},
+		AlertRepo:     &store.BudgetAlertRepo{},
+		WarnRatio:     0.8,
+		HaltRatio:     1.0,
 	}
 }
 
-// RecordUsage adds a cost delta to the task's budget and returns the resulting action.
+// RecordUsage records a cost delta and adds it to the task's budget,
+// returning the resulting action. The delta and the spend are written in one
+// transaction, and the spend is incremented in the database rather than
+// rewritten, so concurrent deltas for one task are all counted. A delta
+// whose key was already recorded for the task leaves the budget unchanged,
+// so a re-emitted cost report is counted once, even when both copies
+// arrive at the same time.
 func (g *BudgetGovernor) RecordUsage(ctx context.Context, taskID string, delta domain.CostDelta) (domain.CostAction, error) {
-	state, err := g.TaskRepo.GetByID(ctx, g.DB, taskID)
+	tx, err := g.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return domain.CostContinue, err
 	}
+	defer tx.Rollback()
 
-	state.BudgetUsedUSD += delta.AmountUSD
-
-	tx, err := g.DB.BeginTx(ctx, nil)
+	inserted, err := g.CostDeltaRepo.CreateTx(ctx, tx, taskID, delta)
 	if err != nil {
 		return domain.CostContinue, err
 	}
-	defer tx.Rollback()
-
-	if err := g.TaskRepo.UpdateStateTx(ctx, tx, *state); err != nil {
+	if !inserted {
+		tx.Rollback()
+		state, err := g.TaskRepo.GetByID(ctx, g.DB, taskID)
+		if err != nil {
+			return domain.CostContinue, err
+		}
+		return g.evaluate(state.BudgetUsedUSD, state.BudgetCapUSD), nil
+	}
+	used, budgetCap, err := g.TaskRepo.AddBudgetUsedTx(ctx, tx, taskID, delta.AmountUSD)
+	if err != nil {
 		return domain.CostContinue, err
 	}
 	if err := tx.Commit(); err != nil {
 		return domain.CostContinue, err
 	}
+	g.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicFlowUpdated, TaskID: taskID})
+	g.fireAlerts(ctx, taskID, used, budgetCap)
 
-	return g.evaluate(state.BudgetUsedUSD, state.BudgetCapUSD), nil
+	return g.evaluate(used, budgetCap), nil
 }
 
 // CheckBudget evaluates the current budget status without modifying it.
@@ -59,15 +99,69 @@ func (g *BudgetGovernor) CheckBudget(ctx context.Context, state domain.FlowState
 	return g.evaluate(state.BudgetUsedUSD, state.BudgetCapUSD), nil
 }
 
+// SetThresholds replaces the warn and halt ratios at runtime.
+func (g *BudgetGovernor) SetThresholds(warn, halt float64) {
+	g.mu.Lock()
+	g.WarnRatio, g.HaltRatio = warn, halt
+	g.mu.Unlock()
+}
+
+// SetAlerts replaces the budget alert rules at runtime.
+func (g *BudgetGovernor) SetAlerts(rules []BudgetAlertRule) {
+	g.mu.Lock()
+	g.alerts = append([]BudgetAlertRule(nil), rules...)
+	g.mu.Unlock()
+}
+
+// fireAlerts announces each alert rule the task's spend has reached and
+// that has not fired under its current cap. Alerts are best effort: one
+// that cannot be recorded is tried again on the task's next cost delta.
+func (g *BudgetGovernor) fireAlerts(ctx context.Context, taskID string, used, cap float64) {
+	if cap <= 0 {
+		return
+	}
+	g.mu.RLock()
+	rules := g.alerts
+	g.mu.RUnlock()
+
+	now := clock.Or(g.Clock).Now().Unix()
+	for _, rule := range rules {
+		if used/cap < rule.Ratio {
+			continue
+		}
+		alert := domain.BudgetAlert{TaskID: taskID, Name: rule.Name, CapUSD: cap, Ratio: rule.Ratio, UsedUSD: used, FiredAt: now}
+		fired, err := g.AlertRepo.Fire(ctx, g.DB, alert)
+		if err != nil || !fired {
+			continue
+		}
+		g.Notifier.Notify(rule.Channels, notify.Notification{
+			Event:   "budget_alert",
+			TaskID:  taskID,
+			Subject: fmt.Sprintf("Budget alert %s: %s has spent $%.2f of $%.2f (%.0f%%)", rule.Name, taskID, used, cap, 100*used/cap),
+			Data: map[string]interface{}{
+				"name":    rule.Name,
+				"ratio":   rule.Ratio,
+				"usedUsd": used,
+				"capUsd":  cap,
+			},
+			Time: now,
+		})
+	}
+}
+
 func (g *BudgetGovernor) evaluate(used, cap float64) domain.CostAction {
 	if cap <= 0 {
 		return domain.CostContinue
 	}
+	g.mu.RLock()
+	warn, halt := g.WarnRatio, g.HaltRatio
+	g.mu.RUnlock()
+
 	ratio := used / cap
-	if ratio >= g.HaltRatio {
+	if ratio >= halt {
 		return domain.CostHalt
 	}
-	if ratio >= g.WarnRatio {
+	if ratio >= warn {
 		return domain.CostWarn
 	}
 	return domain.CostContinue
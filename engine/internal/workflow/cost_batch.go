@@ -0,0 +1,199 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// CostBatcher buffers cost deltas from chatty sessions and writes them, along
+// with the matching task budget increments, in one transaction per flush.
+// Budget checks lag behind by at most one flush interval.
+type CostBatcher struct {
+	Governor      *BudgetGovernor
+	CostDeltaRepo *store.CostDeltaRepo
+
+	// MaxBatch triggers a flush once this many deltas are pending (default 50).
+	MaxBatch int
+	// Interval is the longest a delta waits before being flushed (default 500ms).
+	Interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string][]domain.CostDelta
+	count   int
+
+	flushMu sync.Mutex
+}
+
+// NewCostBatcher creates a CostBatcher with default thresholds.
+func NewCostBatcher(gov *BudgetGovernor, repo *store.CostDeltaRepo) *CostBatcher {
+	return &CostBatcher{
+		Governor:      gov,
+		CostDeltaRepo: repo,
+		MaxBatch:      50,
+		Interval:      500 * time.Millisecond,
+		pending:       make(map[string][]domain.CostDelta),
+	}
+}
+
+// Add queues a cost delta for a task, flushing immediately when the batch is full.
+func (b *CostBatcher) Add(ctx context.Context, taskID string, delta domain.CostDelta) error {
+	b.mu.Lock()
+	b.pending[taskID] = append(b.pending[taskID], delta)
+	b.count++
+	full := b.count >= b.MaxBatch
+	b.mu.Unlock()
+
+	if full {
+		return b.Flush(ctx)
+	}
+	return nil
+}
+
+// Pending returns the number of deltas waiting to be flushed.
+func (b *CostBatcher) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.count
+}
+
+// Start flushes pending deltas every Interval until ctx is cancelled, then
+// performs a final flush.
+func (b *CostBatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(b.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				_ = b.Flush(context.Background())
+				return
+			case <-ticker.C:
+				_ = b.Flush(ctx)
+			}
+		}
+	}()
+}
+
+// Flush writes every pending delta and the resulting budget totals in a
+// single transaction. On failure the deltas are re-queued.
+func (b *CostBatcher) Flush(ctx context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	batch := b.pending
+	n := b.count
+	b.pending = make(map[string][]domain.CostDelta)
+	b.count = 0
+	b.mu.Unlock()
+
+	if n == 0 {
+		return nil
+	}
+
+	if err := b.write(ctx, batch); err != nil {
+		b.requeue(batch, n)
+		return fmt.Errorf("flush cost batch: %w", err)
+	}
+	return nil
+}
+
+// write applies one batch. Keys are checked before the transaction because
+// the single SQLite connection cannot serve reads while it is open; each
+// task's spend is then incremented in place, as RecordUsage does.
+func (b *CostBatcher) write(ctx context.Context, batch map[string][]domain.CostDelta) error {
+	taskIDs := make([]string, 0, len(batch))
+	for taskID := range batch {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+
+	fresh := make(map[string][]domain.CostDelta, len(taskIDs))
+	for _, taskID := range taskIDs {
+		deltas, err := b.dedupe(ctx, taskID, batch[taskID])
+		if err != nil {
+			return err
+		}
+		fresh[taskID] = deltas
+	}
+
+	tx, err := b.Governor.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	type spend struct {
+		taskID    string
+		used, cap float64
+	}
+	updated := make([]spend, 0, len(taskIDs))
+	for _, taskID := range taskIDs {
+		var sum float64
+		for _, d := range fresh[taskID] {
+			sum += d.AmountUSD
+		}
+		used, budgetCap, err := b.Governor.TaskRepo.AddBudgetUsedTx(ctx, tx, taskID, sum)
+		if err == domain.ErrFlowNotFound {
+			// Deltas for unknown tasks are dropped, as RecordUsage would.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := b.CostDeltaRepo.CreateBatchTx(ctx, tx, taskID, fresh[taskID]); err != nil {
+			return err
+		}
+		updated = append(updated, spend{taskID, used, budgetCap})
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, u := range updated {
+		b.Governor.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicFlowUpdated, TaskID: u.taskID})
+		b.Governor.fireAlerts(ctx, u.taskID, u.used, u.cap)
+	}
+	return nil
+}
+
+// dedupe drops the deltas whose key was recorded for the task or appears
+// earlier in deltas.
+func (b *CostBatcher) dedupe(ctx context.Context, taskID string, deltas []domain.CostDelta) ([]domain.CostDelta, error) {
+	out := deltas[:0:0]
+	seen := make(map[string]bool)
+	for _, d := range deltas {
+		if d.Key != "" {
+			if seen[d.Key] {
+				continue
+			}
+			seen[d.Key] = true
+			recorded, err := b.CostDeltaRepo.HasKey(ctx, b.Governor.DB, taskID, d.Key)
+			if err != nil {
+				return nil, err
+			}
+			if recorded {
+				continue
+			}
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// requeue puts a failed batch back in front of anything added since.
+func (b *CostBatcher) requeue(batch map[string][]domain.CostDelta, n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for taskID, deltas := range b.pending {
+		batch[taskID] = append(batch[taskID], deltas...)
+	}
+	b.pending = batch
+	b.count += n
+}
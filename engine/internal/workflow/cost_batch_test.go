@@ -0,0 +1,112 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+func TestCostBatcher_FlushWritesDeltasAndBudget(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	eng.StartFlow(ctx, "task-2", 10.0)
+
+	repo := &store.CostDeltaRepo{}
+	b := NewCostBatcher(NewBudgetGovernor(eng.DB), repo)
+	for i := 0; i < 3; i++ {
+		b.Add(ctx, "task-1", domain.CostDelta{AmountUSD: 0.5, CreatedAt: time.Now().Unix()})
+	}
+	b.Add(ctx, "task-2", domain.CostDelta{AmountUSD: 2.0})
+
+	if got := b.Pending(); got != 4 {
+		t.Fatalf("Pending = %d, want 4", got)
+	}
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.BudgetUsedUSD != 0 {
+		t.Fatalf("BudgetUsedUSD before flush = %f, want 0", state.BudgetUsedUSD)
+	}
+
+	if err := b.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := b.Pending(); got != 0 {
+		t.Errorf("Pending after flush = %d, want 0", got)
+	}
+
+	state, _ = eng.GetState(ctx, "task-1")
+	if state.BudgetUsedUSD != 1.5 {
+		t.Errorf("task-1 BudgetUsedUSD = %f, want 1.5", state.BudgetUsedUSD)
+	}
+	state, _ = eng.GetState(ctx, "task-2")
+	if state.BudgetUsedUSD != 2.0 {
+		t.Errorf("task-2 BudgetUsedUSD = %f, want 2.0", state.BudgetUsedUSD)
+	}
+	deltas, _ := repo.ListByTask(ctx, eng.DB, "task-1")
+	if len(deltas) != 3 {
+		t.Errorf("task-1 deltas = %d, want 3", len(deltas))
+	}
+}
+
+func TestCostBatcher_DedupesKeys(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+
+	repo := &store.CostDeltaRepo{}
+	if err := repo.Create(ctx, eng.DB, "task-1", domain.CostDelta{AmountUSD: 1.0, Key: "k1"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	b := NewCostBatcher(NewBudgetGovernor(eng.DB), repo)
+	b.Add(ctx, "task-1", domain.CostDelta{AmountUSD: 1.0, Key: "k1"})
+	b.Add(ctx, "task-1", domain.CostDelta{AmountUSD: 0.5, Key: "k2"})
+	b.Add(ctx, "task-1", domain.CostDelta{AmountUSD: 0.5, Key: "k2"})
+	b.Add(ctx, "task-1", domain.CostDelta{AmountUSD: 0.25})
+	if err := b.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.BudgetUsedUSD != 0.75 {
+		t.Errorf("BudgetUsedUSD = %f, want only k2 and the unkeyed delta", state.BudgetUsedUSD)
+	}
+	if deltas, _ := repo.ListByTask(ctx, eng.DB, "task-1"); len(deltas) != 3 {
+		t.Errorf("deltas = %d, want 3", len(deltas))
+	}
+}
+
+func TestCostBatcher_FlushesWhenFull(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+
+	b := NewCostBatcher(NewBudgetGovernor(eng.DB), &store.CostDeltaRepo{})
+	b.MaxBatch = 2
+	b.Add(ctx, "task-1", domain.CostDelta{AmountUSD: 1.0})
+	b.Add(ctx, "task-1", domain.CostDelta{AmountUSD: 1.0})
+
+	if got := b.Pending(); got != 0 {
+		t.Errorf("Pending = %d, want 0 after size-triggered flush", got)
+	}
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.BudgetUsedUSD != 2.0 {
+		t.Errorf("BudgetUsedUSD = %f, want 2.0", state.BudgetUsedUSD)
+	}
+}
+
+func TestCostBatcher_DropsUnknownTasks(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+
+	b := NewCostBatcher(NewBudgetGovernor(eng.DB), &store.CostDeltaRepo{})
+	b.Add(ctx, "missing", domain.CostDelta{AmountUSD: 1.0})
+	if err := b.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := b.Pending(); got != 0 {
+		t.Errorf("Pending = %d, want 0", got)
+	}
+}
@@ -3,9 +3,13 @@ package workflow
 import (
 	"context"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
 
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/notify"
 	"github.com/anthropics/three-body-engine/internal/store"
 )
 
@@ -150,3 +154,183 @@ func TestBudgetGovernor_CustomThresholds(t *testing.T) {
 		t.Errorf("action = %q at 50%% with 50%% threshold, want warn", action)
 	}
 }
+
+func TestBudgetGovernor_SetThresholds(t *testing.T) {
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	gov := NewBudgetGovernor(db)
+	gov.SetThresholds(0.3, 0.6)
+
+	state := domain.FlowState{BudgetUsedUSD: 6.0, BudgetCapUSD: 10.0}
+	action, err := gov.CheckBudget(context.Background(), state)
+	if err != nil {
+		t.Fatalf("CheckBudget: %v", err)
+	}
+	if action != domain.CostHalt {
+		t.Errorf("action = %q at 60%% with 60%% halt threshold, want halt", action)
+	}
+}
+
+func TestBudgetGovernor_RecordUsage_DedupesKeys(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+
+	gov := NewBudgetGovernor(eng.DB)
+	delta := domain.CostDelta{AmountUSD: 2.0, Key: "ses-1/msg-1"}
+	for i := 0; i < 2; i++ {
+		if _, err := gov.RecordUsage(ctx, "task-1", delta); err != nil {
+			t.Fatalf("RecordUsage: %v", err)
+		}
+	}
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.BudgetUsedUSD != 2.0 {
+		t.Errorf("BudgetUsedUSD = %f, want the repeated report counted once", state.BudgetUsedUSD)
+	}
+	if deltas, _ := gov.CostDeltaRepo.ListByTask(ctx, eng.DB, "task-1"); len(deltas) != 1 || deltas[0].Key != "ses-1/msg-1" {
+		t.Errorf("deltas = %+v, want one keyed delta", deltas)
+	}
+}
+
+func TestBudgetGovernor_RecordUsage_Concurrent(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 100.0)
+
+	gov := NewBudgetGovernor(eng.DB)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := gov.RecordUsage(ctx, "task-1", domain.CostDelta{AmountUSD: 0.5}); err != nil {
+				t.Errorf("RecordUsage: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.BudgetUsedUSD != 10.0 {
+		t.Errorf("BudgetUsedUSD = %f, want every concurrent delta counted", state.BudgetUsedUSD)
+	}
+}
+
+func TestBudgetGovernor_RecordUsage_ConcurrentDuplicates(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 100.0)
+
+	gov := NewBudgetGovernor(eng.DB)
+	delta := domain.CostDelta{AmountUSD: 1.5, Key: "ses-1/msg-1"}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := gov.RecordUsage(ctx, "task-1", delta); err != nil {
+				t.Errorf("RecordUsage: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.BudgetUsedUSD != 1.5 {
+		t.Errorf("BudgetUsedUSD = %f, want the duplicated report counted once", state.BudgetUsedUSD)
+	}
+	if deltas, _ := gov.CostDeltaRepo.ListByTask(ctx, eng.DB, "task-1"); len(deltas) != 1 {
+		t.Errorf("deltas = %+v, want one keyed delta", deltas)
+	}
+}
+
+func BenchmarkBudgetGovernor_RecordUsage(b *testing.B) {
+	db, err := store.NewDB(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	eng := NewEngine(db)
+	if err := eng.StartFlow(ctx, "task-1", 1e9); err != nil {
+		b.Fatalf("StartFlow: %v", err)
+	}
+
+	gov := NewBudgetGovernor(db)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := gov.RecordUsage(ctx, "task-1", domain.CostDelta{AmountUSD: 0.001}); err != nil {
+				b.Errorf("RecordUsage: %v", err)
+			}
+		}
+	})
+}
+
+type alertChannel struct {
+	mu   sync.Mutex
+	sent []notify.Notification
+}
+
+func (c *alertChannel) Send(ctx context.Context, n notify.Notification) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, n)
+	return nil
+}
+
+func TestBudgetGovernor_Alerts(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+
+	ops := &alertChannel{}
+	gov := NewBudgetGovernor(eng.DB)
+	gov.Notifier = notify.NewDispatcher(map[string]notify.Channel{"ops": ops})
+	gov.SetAlerts([]BudgetAlertRule{
+		{Name: "half", Ratio: 0.5, Channels: []string{"ops"}},
+		{Name: "most", Ratio: 0.75, Channels: []string{"ops"}},
+		{Name: "nearly", Ratio: 0.9, Channels: []string{"ops"}},
+	})
+
+	sent := func() []string {
+		gov.Notifier.Wait()
+		ops.mu.Lock()
+		defer ops.mu.Unlock()
+		var names []string
+		for _, n := range ops.sent {
+			names = append(names, n.Data["name"].(string))
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	gov.RecordUsage(ctx, "task-1", domain.CostDelta{AmountUSD: 4})
+	if got := sent(); len(got) != 0 {
+		t.Fatalf("alerts at 40%% = %v", got)
+	}
+	// One delta crossing two thresholds fires both; later deltas fire
+	// neither again.
+	gov.RecordUsage(ctx, "task-1", domain.CostDelta{AmountUSD: 4})
+	gov.RecordUsage(ctx, "task-1", domain.CostDelta{AmountUSD: 0.5})
+	if got := sent(); !reflect.DeepEqual(got, []string{"half", "most"}) {
+		t.Fatalf("alerts at 85%% = %v, want half and most", got)
+	}
+
+	// Raising the cap re-arms the alerts under the new cap.
+	if err := eng.SetBudgetCap(ctx, "task-1", 11.0); err != nil {
+		t.Fatalf("SetBudgetCap: %v", err)
+	}
+	gov.RecordUsage(ctx, "task-1", domain.CostDelta{AmountUSD: 0.1})
+	if got := sent(); !reflect.DeepEqual(got, []string{"half", "half", "most", "most"}) {
+		t.Errorf("alerts after raising the cap = %v", got)
+	}
+	fired, _ := gov.AlertRepo.ListByTask(ctx, eng.DB, "task-1")
+	if len(fired) != 4 {
+		t.Errorf("fired alerts = %+v", fired)
+	}
+}
@@ -0,0 +1,65 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// Fail marks an unfinished flow failed with cause, recording a post-mortem
+// with the flow's last PostMortemEvents events in a flow_failed event, and
+// returns it. Listeners are notified so in-flight sessions stop. The flow
+// keeps its phase, so it can be cloned from there.
+func (e *Engine) Fail(ctx context.Context, taskID string, cause domain.FailureCause, detail, actor string) (*domain.PostMortem, error) {
+	if !domain.ValidFailureCauses[cause] {
+		return nil, domain.NewEngineError(domain.ErrFailureInvalid.Code,
+			fmt.Sprintf("unknown failure cause %q", cause))
+	}
+	events, err := e.EventRepo.ListRecent(ctx, e.DB, taskID, e.PostMortemEvents)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []domain.WorkflowEvent{}
+	}
+
+	pm := &domain.PostMortem{TaskID: taskID, Cause: cause, Detail: detail, Actor: actor, Events: events}
+	state, err := e.update(ctx, taskID, domain.EventFlowFailed, pm, func(state *domain.FlowState) error {
+		if state.Status == domain.StatusDone || state.Status == domain.StatusFailed {
+			return domain.NewEngineError(domain.ErrInvalidTransition.Code,
+				fmt.Sprintf("flow %s is already %s", taskID, state.Status))
+		}
+		pm.Phase, pm.Round, pm.Status = state.CurrentPhase, state.Round, state.Status
+		pm.BudgetUsedUSD = state.BudgetUsedUSD
+		pm.FailedAt = e.Clock.Now().Unix()
+		state.Status = domain.StatusFailed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.notify(ctx, *state, state.CurrentPhase)
+	return pm, nil
+}
+
+// PostMortem returns the post-mortem recorded when a flow last failed.
+func (e *Engine) PostMortem(ctx context.Context, taskID string) (*domain.PostMortem, error) {
+	db := e.Reader()
+	if _, err := e.TaskRepo.GetByID(ctx, db, taskID); err != nil {
+		return nil, err
+	}
+	ev, err := e.EventRepo.LatestByType(ctx, db, taskID, domain.EventFlowFailed)
+	if err != nil {
+		return nil, err
+	}
+	if ev == nil {
+		return nil, domain.ErrPostMortemNotFound
+	}
+	var pm domain.PostMortem
+	if err := json.Unmarshal([]byte(ev.PayloadJSON), &pm); err != nil {
+		return nil, fmt.Errorf("decode post-mortem: %w", err)
+	}
+	return &pm, nil
+}
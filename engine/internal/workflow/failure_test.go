@@ -0,0 +1,83 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestEngine_Fail(t *testing.T) {
+	eng := newTestEngine(t)
+	eng.PostMortemEvents = 2
+	ctx := context.Background()
+	if err := eng.StartFlow(ctx, "t1", 10); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	trigger := domain.TransitionTrigger{Action: "advance", Actor: "test"}
+	for i := 0; i < 2; i++ {
+		if err := eng.Advance(ctx, "t1", trigger); err != nil {
+			t.Fatalf("Advance: %v", err)
+		}
+	}
+	var notified domain.FlowStatus
+	eng.AddListener(func(_ context.Context, state domain.FlowState, _ domain.Phase) { notified = state.Status })
+
+	pm, err := eng.Fail(ctx, "t1", domain.FailureProvider, "codex exited 1", "supervisor")
+	if err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+	if pm.Phase != domain.PhaseC || pm.Status != domain.StatusRunning || pm.Cause != domain.FailureProvider || pm.Actor != "supervisor" {
+		t.Errorf("post-mortem = %+v", pm)
+	}
+	if len(pm.Events) != 2 || pm.Events[1].EventType != "phase_transition" {
+		t.Errorf("post-mortem events = %+v, want the last two", pm.Events)
+	}
+	if notified != domain.StatusFailed {
+		t.Errorf("listener saw %q, want failed", notified)
+	}
+	state, _ := eng.GetState(ctx, "t1")
+	if state.Status != domain.StatusFailed || state.CurrentPhase != domain.PhaseC {
+		t.Errorf("state = %+v, want failed in C", state)
+	}
+	stays, _ := eng.PhaseDurations(ctx, "t1")
+	if last := stays[len(stays)-1]; last.ExitedAt == 0 {
+		t.Errorf("stay in C = %+v, want it closed", last)
+	}
+
+	stored, err := eng.PostMortem(ctx, "t1")
+	if err != nil {
+		t.Fatalf("PostMortem: %v", err)
+	}
+	if stored.Cause != domain.FailureProvider || stored.Detail != "codex exited 1" || len(stored.Events) != 2 {
+		t.Errorf("stored post-mortem = %+v", stored)
+	}
+
+	var engErr *domain.EngineError
+	if _, err := eng.Fail(ctx, "t1", domain.FailureManual, "", ""); !errors.As(err, &engErr) || engErr.Code != domain.ErrInvalidTransition.Code {
+		t.Errorf("failing twice: err = %v, want ErrInvalidTransition", err)
+	}
+	if err := eng.Advance(ctx, "t1", trigger); err == nil {
+		t.Error("a failed flow advanced")
+	}
+}
+
+func TestEngine_FailInvalid(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	if err := eng.StartFlow(ctx, "t1", 10); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+
+	var engErr *domain.EngineError
+	if _, err := eng.Fail(ctx, "t1", "bad luck", "", ""); !errors.As(err, &engErr) || engErr.Code != domain.ErrFailureInvalid.Code {
+		t.Errorf("unknown cause: err = %v, want ErrFailureInvalid", err)
+	}
+	if _, err := eng.PostMortem(ctx, "t1"); err != domain.ErrPostMortemNotFound {
+		t.Errorf("PostMortem before failing: err = %v, want ErrPostMortemNotFound", err)
+	}
+	if _, err := eng.Fail(ctx, "missing", domain.FailureManual, "", ""); err != domain.ErrFlowNotFound {
+		t.Errorf("missing flow: err = %v, want ErrFlowNotFound", err)
+	}
+}
@@ -3,10 +3,14 @@ package workflow
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/anthropics/three-body-engine/internal/clock"
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
 	"github.com/anthropics/three-body-engine/internal/store"
 )
 
@@ -30,133 +34,834 @@ func IsValidTransition(from, to domain.Phase) bool {
 	return targets[to]
 }
 
+// phaseOrder gives each phase its position in the forward path.
+var phaseOrder = map[domain.Phase]int{
+	domain.PhaseA: 0,
+	domain.PhaseB: 1,
+	domain.PhaseC: 2,
+	domain.PhaseD: 3,
+	domain.PhaseE: 4,
+	domain.PhaseF: 5,
+	domain.PhaseG: 6,
+}
+
+// IsValidRollback checks if an explicit rollback from one phase to another is
+// legal: the target must be an earlier phase and the flow must not be done.
+func IsValidRollback(from, to domain.Phase) bool {
+	fromIdx, ok := phaseOrder[from]
+	if !ok || from == domain.PhaseG {
+		return false
+	}
+	toIdx, ok := phaseOrder[to]
+	return ok && toIdx < fromIdx
+}
+
+// TransitionListener is notified after a phase transition has been committed.
+// state is the post-transition FlowState and from is the phase that was exited.
+type TransitionListener func(ctx context.Context, state domain.FlowState, from domain.Phase)
+
+// WorkspaceProvisioner creates the isolated working directory of a new flow
+// and removes it again if the flow cannot be created.
+type WorkspaceProvisioner interface {
+	Provision(ctx context.Context, taskID string) (string, error)
+	Discard(ctx context.Context, taskID, path string) error
+}
+
 // Engine is the FSM that manages workflow state transitions.
 type Engine struct {
-	DB           *sql.DB
+	DB *sql.DB
+	// ReadDB, when set, serves read-only queries so they do not wait on the
+	// single writer connection. Reads that precede a write stay on DB.
+	ReadDB       *sql.DB
 	TaskRepo     *store.TaskRepo
 	EventRepo    *store.EventRepo
 	SnapshotRepo *store.SnapshotRepo
+	IntentRepo   *store.IntentRepo
+	ReviewRepo   *store.ScoreCardRepo
+	RoundRepo    *store.ReviewRoundRepo
+	GateRepo     *store.GateDecisionRepo
+	DurationRepo *store.PhaseDurationRepo
+	ArtifactRepo *store.ArtifactRepo
 	GateRegistry *PhaseGateRegistry
+	// Workspaces, when set, provisions a per-task workspace for every new
+	// flow that does not name one in FlowOptions.
+	Workspaces WorkspaceProvisioner
+	// DefaultWorkspace is the workspace of flows that name none and get
+	// none provisioned.
+	DefaultWorkspace string
+	// WorkspaceConflict decides what happens to a flow that would start in
+	// a workspace held by another flow: every running, blocked, or paused
+	// flow holds the lease on its workspace. WorkspaceConflictFail rejects
+	// the new flow with ErrWorkspaceInUse, WorkspaceConflictQueue queues it
+	// until the workspace is free, and WorkspaceConflictShare or "" lets
+	// the flows share it. FlowOptions.ShareWorkspace overrides the lease.
+	WorkspaceConflict string
+	// MaxConcurrentFlows caps the running and blocked flows: a flow created
+	// while the cap is reached is queued for the Scheduler. Zero means
+	// unlimited.
+	MaxConcurrentFlows int
+	// AuditRepo records workspace lease overrides.
+	AuditRepo *store.AuditRepo
+	// Bus, when set, receives a TopicFlowCreated signal for every new flow
+	// and a TopicFlowUpdated signal after every status change and
+	// transition.
+	Bus *eventbus.Bus
+	// NamespaceBudgets limits the budget each namespace's flows may hold at
+	// once (see TaskRepo.AllocatedBudget). Namespaces not listed are
+	// unlimited.
+	NamespaceBudgets map[string]float64
+	// PhaseSLA is how long a flow should stay in each phase; an SLAMonitor
+	// flags stays that run longer. Phases not listed have no SLA.
+	PhaseSLA map[domain.Phase]time.Duration
+	// PostMortemEvents is how many of a failing flow's last events its
+	// post-mortem keeps.
+	PostMortemEvents int
+
+	// RetryAttempts bounds how many times Advance retries after an
+	// optimistic lock conflict (including the first attempt).
+	RetryAttempts int
+	// RetryBackoff is the base delay between attempts; attempt n waits n times it.
+	RetryBackoff time.Duration
+
+	// Clock stamps events, transitions, and phase durations, and times the
+	// retry backoff.
+	Clock clock.Clock
+
+	listenersMu sync.RWMutex
+	listeners   []TransitionListener
+
+	advanceLatency latencyWindow
 }
 
 // NewEngine creates a new FSM engine with all dependencies.
 func NewEngine(db *sql.DB) *Engine {
 	gov := NewBudgetGovernor(db)
+	taskRepo := &store.TaskRepo{}
+
+	// Every phase gate also waits for child flows to finish.
+	registry := NewPhaseGateRegistry(gov)
+	for phase := range phaseOrder {
+		inner, _ := registry.Get(phase)
+		registry.Register(phase, &JoinGate{Inner: inner, DB: db, TaskRepo: taskRepo})
+	}
+	// Open P0 risks hold the flow in review and acceptance; leaving
+	// acceptance also needs every P0 and P1 review issue closed.
+	risks := RiskBlockers(db, &store.RiskRepo{})
+	diffs := ScoreCardDiffs(db, &store.ScoreCardRepo{})
+	inner, _ := registry.Get(domain.PhaseD)
+	registry.Register(domain.PhaseD, &ReviewGate{Inner: inner, BlockersFn: risks, DiffsFn: diffs})
+	inner, _ = registry.Get(domain.PhaseF)
+	registry.Register(domain.PhaseF, &ReviewGate{
+		Inner:      inner,
+		BlockersFn: AllBlockers(risks, IssueBlockers(db, &store.IssueRepo{})),
+		DiffsFn:    diffs,
+	})
+
 	return &Engine{
-		DB:           db,
-		TaskRepo:     &store.TaskRepo{},
-		EventRepo:    &store.EventRepo{},
-		SnapshotRepo: &store.SnapshotRepo{},
-		GateRegistry: NewPhaseGateRegistry(gov),
+		DB:            db,
+		TaskRepo:      taskRepo,
+		EventRepo:     &store.EventRepo{},
+		SnapshotRepo:  &store.SnapshotRepo{},
+		IntentRepo:    &store.IntentRepo{},
+		ReviewRepo:    &store.ScoreCardRepo{},
+		RoundRepo:     &store.ReviewRoundRepo{},
+		GateRepo:      &store.GateDecisionRepo{},
+		DurationRepo:  &store.PhaseDurationRepo{},
+		ArtifactRepo:  &store.ArtifactRepo{},
+		AuditRepo:     &store.AuditRepo{},
+		GateRegistry:  registry,
+		RetryAttempts: 3,
+		RetryBackoff:  25 * time.Millisecond,
+		Clock:         clock.System,
+
+		PostMortemEvents: 20,
 	}
 }
 
+// FlowOptions holds optional per-task settings chosen at flow creation.
+type FlowOptions struct {
+	// AutoAdvance lets the AutoAdvancer move the flow forward without a
+	// human trigger whenever the current phase gate is satisfied.
+	AutoAdvance bool
+
+	// Queued creates the flow in the queued status; the Scheduler starts it
+	// once a flow slot is free.
+	Queued bool
+	// StartAt (unix seconds) delays a queued flow's start. A non-zero value
+	// implies Queued.
+	StartAt int64
+	// Priority orders queued flows and lets a flow preempt lower-priority
+	// ones (higher is more urgent).
+	Priority int
+	// Workspace is the flow's working directory. Empty lets the engine's
+	// WorkspaceProvisioner create one, or shares the global workspace.
+	Workspace string
+	// Title, Description, and AcceptanceCriteria are stored on the flow and
+	// passed to workers in their context digests.
+	Title              string
+	Description        string
+	AcceptanceCriteria string
+	// Consensus overrides the configured consensus settings for the flow.
+	Consensus *domain.ConsensusSettings
+	// Namespace places the flow in a team's namespace. Empty means
+	// domain.DefaultNamespace; child flows always share their parent's.
+	Namespace string
+	// ShareWorkspace starts the flow even if another flow holds its
+	// workspace lease; the override is audited.
+	ShareWorkspace bool
+}
+
 // StartFlow creates a new workflow at Phase A with the given budget cap.
 func (e *Engine) StartFlow(ctx context.Context, taskID string, budgetCapUSD float64) error {
+	return e.StartFlowWithOptions(ctx, taskID, budgetCapUSD, FlowOptions{})
+}
+
+// StartFlowWithOptions creates a new workflow at Phase A with the given budget
+// cap and per-task options.
+func (e *Engine) StartFlowWithOptions(ctx context.Context, taskID string, budgetCapUSD float64, opts FlowOptions) error {
+	return e.startFlow(ctx, taskID, budgetCapUSD, opts, "")
+}
+
+// Activate starts a queued flow: its status becomes running, a
+// flow_started event is recorded, and listeners are notified so the first
+// phase's work starts. It returns ErrWorkspaceInUse while another flow
+// holds the flow's workspace lease.
+func (e *Engine) Activate(ctx context.Context, taskID string) error {
+	if err := e.checkWorkspace(ctx, taskID); err != nil {
+		return err
+	}
+	state, err := e.setStatus(ctx, taskID, domain.StatusQueued, domain.StatusRunning, domain.EventFlowStarted, domain.FlowStartedPayload{})
+	if err != nil {
+		return err
+	}
+	e.notify(ctx, *state, state.CurrentPhase)
+	return nil
+}
+
+// Pause suspends a running flow on behalf of a higher-priority flow. The flow
+// keeps its phase and budget; listeners are notified so in-flight sessions
+// can be stopped.
+func (e *Engine) Pause(ctx context.Context, taskID, preemptedBy string) error {
+	state, err := e.setStatus(ctx, taskID, domain.StatusRunning, domain.StatusPaused, domain.EventFlowPreempted,
+		domain.FlowPreemptedPayload{PreemptedBy: preemptedBy})
+	if err != nil {
+		return err
+	}
+	e.notify(ctx, *state, state.CurrentPhase)
+	return nil
+}
+
+// Suspend records in an engine_shutdown event that the engine shutting down
+// stopped the sessions of a flow. A running flow is paused, so a scheduler
+// resumes it, restarting its phase's work, once an engine serves again;
+// other unfinished flows keep their status. Listeners are notified of a
+// pause so in-flight work is abandoned.
+func (e *Engine) Suspend(ctx context.Context, taskID string, payload domain.EngineShutdownPayload) error {
+	state, err := e.update(ctx, taskID, domain.EventEngineShutdown, &payload, func(state *domain.FlowState) error {
+		if state.Status == domain.StatusDone || state.Status == domain.StatusFailed {
+			return domain.NewEngineError(domain.ErrInvalidTransition.Code,
+				fmt.Sprintf("flow %s is already %s", taskID, state.Status))
+		}
+		payload.Status = state.Status
+		if state.Status == domain.StatusRunning {
+			state.Status = domain.StatusPaused
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if payload.Status == domain.StatusRunning {
+		e.notify(ctx, *state, state.CurrentPhase)
+	}
+	return nil
+}
+
+// Resume restarts a paused flow in the phase it was paused in and notifies
+// listeners so the phase's work can be restarted.
+func (e *Engine) Resume(ctx context.Context, taskID string) error {
+	state, err := e.setStatus(ctx, taskID, domain.StatusPaused, domain.StatusRunning, domain.EventFlowResumed, struct{}{})
+	if err != nil {
+		return err
+	}
+	e.notify(ctx, *state, state.CurrentPhase)
+	return nil
+}
+
+// Block stops a running flow that needs a human, recording reason in a
+// flow_blocked event. Listeners are notified so in-flight sessions stop.
+func (e *Engine) Block(ctx context.Context, taskID, reason string) error {
+	state, err := e.setStatus(ctx, taskID, domain.StatusRunning, domain.StatusBlocked, domain.EventFlowBlocked,
+		domain.FlowBlockedPayload{Reason: reason})
+	if err != nil {
+		return err
+	}
+	e.notify(ctx, *state, state.CurrentPhase)
+	return nil
+}
+
+// BlockCauseGate marks a flow_blocked event recorded by BlockOnGate.
+const BlockCauseGate = "gate"
+
+// BlockOnGate blocks a running flow whose phase work is finished but whose
+// gate refused to let it advance, recording reason in a flow_blocked event
+// with cause "gate". Unlike Block, listeners are not notified, as no work
+// is in flight; an UnblockManager advances the flow once the gate allows.
+func (e *Engine) BlockOnGate(ctx context.Context, taskID, reason string) error {
+	_, err := e.setStatus(ctx, taskID, domain.StatusRunning, domain.StatusBlocked, domain.EventFlowBlocked,
+		domain.FlowBlockedPayload{Reason: reason, Cause: BlockCauseGate})
+	if err == nil {
+		e.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicFlowBlocked, TaskID: taskID})
+	}
+	return err
+}
+
+// Unblock returns a blocked flow to running in the same phase, recording
+// actor in a flow_unblocked event, and announces it on the bus. Listeners
+// are not notified: the phase's work is not restarted.
+func (e *Engine) Unblock(ctx context.Context, taskID, actor string) error {
+	_, err := e.setStatus(ctx, taskID, domain.StatusBlocked, domain.StatusRunning, domain.EventFlowUnblocked,
+		domain.FlowUnblockedPayload{Actor: actor})
+	if err == nil {
+		e.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicFlowUnblocked, TaskID: taskID})
+	}
+	return err
+}
+
+// SetBudgetCap changes a flow's budget cap, recording the old and new caps
+// in a budget_changed event. The cap may not be set below what the flow
+// has already spent.
+func (e *Engine) SetBudgetCap(ctx context.Context, taskID string, capUSD float64) error {
+	payload := &domain.BudgetChangedPayload{To: capUSD}
+	_, err := e.update(ctx, taskID, domain.EventBudgetChanged, payload, func(state *domain.FlowState) error {
+		if capUSD <= 0 || capUSD < state.BudgetUsedUSD {
+			return domain.NewEngineError(domain.ErrInvalidBudget.Code,
+				fmt.Sprintf("budget cap %.2f must be positive and at least the %.2f spent", capUSD, state.BudgetUsedUSD))
+		}
+		if state.ParentTaskID == "" && state.Status != domain.StatusDone && capUSD > state.BudgetCapUSD {
+			if err := e.checkNamespaceBudget(ctx, state.Namespace, capUSD-state.BudgetCapUSD); err != nil {
+				return err
+			}
+		}
+		payload.From = state.BudgetCapUSD
+		state.BudgetCapUSD = capUSD
+		return nil
+	})
+	if err == nil {
+		e.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicBudgetChanged, TaskID: taskID})
+	}
+	return err
+}
+
+// setStatus moves a flow from one status to another in the same phase,
+// recording eventType with payload. It returns the committed state.
+func (e *Engine) setStatus(ctx context.Context, taskID string, from, to domain.FlowStatus, eventType domain.EventType, payload interface{}) (*domain.FlowState, error) {
+	return e.update(ctx, taskID, eventType, payload, func(state *domain.FlowState) error {
+		if state.Status != from {
+			return domain.NewEngineError(domain.ErrInvalidTransition.Code,
+				fmt.Sprintf("flow %s is not %s (status=%s)", taskID, from, state.Status))
+		}
+		state.Status = to
+		return nil
+	})
+}
+
+// update applies change to a flow's state in its current phase and records
+// eventType with payload in the same transaction. It returns the committed
+// state. An error from change is returned before anything is written.
+func (e *Engine) update(ctx context.Context, taskID string, eventType domain.EventType, payload interface{}, change func(state *domain.FlowState) error) (*domain.FlowState, error) {
+	state, err := e.TaskRepo.GetByID(ctx, e.DB, taskID)
+	if err != nil {
+		return nil, err
+	}
+	updated := *state
+	if err := change(&updated); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal event payload: %w", err)
+	}
+
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := e.Clock.Now().Unix()
+	updated.LastEventSeq = state.LastEventSeq + 1
+	updated.UpdatedAtUnix = now
+
+	event := domain.WorkflowEvent{
+		TaskID:      taskID,
+		SeqNo:       updated.LastEventSeq,
+		Phase:       state.CurrentPhase,
+		EventType:   eventType,
+		PayloadJSON: string(data),
+		CreatedAt:   now,
+	}
+	if err := e.TaskRepo.UpdateStateTx(ctx, tx, updated); err != nil {
+		return nil, err
+	}
+	if err := e.EventRepo.AppendTx(ctx, tx, event); err != nil {
+		return nil, fmt.Errorf("append %s event: %w", eventType, err)
+	}
+	// A queued flow's time in its first phase starts when it runs, and a
+	// failed flow's ends.
+	if state.Status == domain.StatusQueued && updated.Status == domain.StatusRunning {
+		if err := e.enterPhaseTx(ctx, tx, taskID, updated.CurrentPhase, now); err != nil {
+			return nil, err
+		}
+	}
+	if state.Status != domain.StatusFailed && updated.Status == domain.StatusFailed {
+		if err := e.DurationRepo.ExitTx(ctx, tx, taskID, now); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	updated.StateVersion++
+	e.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicFlowUpdated, TaskID: taskID})
+	return &updated, nil
+}
+
+// SpawnChild creates a child flow of a running parent. The child's budget cap
+// is budgetFraction of the parent's cap, and the caps of all children of a
+// parent may not exceed the parent's cap. The parent's gates hold it in its
+// current phase until every child reaches a terminal state.
+func (e *Engine) SpawnChild(ctx context.Context, parentID, childID string, budgetFraction float64, opts FlowOptions) error {
+	if budgetFraction <= 0 || budgetFraction > 1 {
+		return domain.NewEngineError(domain.ErrInvalidChildFlow.Code,
+			fmt.Sprintf("budget fraction %.2f must be in (0, 1]", budgetFraction))
+	}
+
+	parent, err := e.TaskRepo.GetByID(ctx, e.DB, parentID)
+	if err != nil {
+		return err
+	}
+	if parent.Status != domain.StatusRunning {
+		return domain.NewEngineError(domain.ErrInvalidChildFlow.Code,
+			fmt.Sprintf("parent %s is not running (status=%s)", parentID, parent.Status))
+	}
+
+	children, err := e.TaskRepo.ListChildren(ctx, e.DB, parentID)
+	if err != nil {
+		return err
+	}
+	childCap := parent.BudgetCapUSD * budgetFraction
+	allocated := childCap
+	for _, c := range children {
+		allocated += c.BudgetCapUSD
+	}
+	if allocated > parent.BudgetCapUSD {
+		return domain.NewEngineError(domain.ErrBudgetExceeded.Code,
+			fmt.Sprintf("child budgets %.2f would exceed parent cap %.2f", allocated, parent.BudgetCapUSD))
+	}
+
+	if opts.Priority == 0 {
+		opts.Priority = parent.Priority
+	}
+	opts.Namespace = parent.Namespace
+	if err := e.startFlow(ctx, childID, childCap, opts, parentID); err != nil {
+		return err
+	}
+	return e.AppendEvent(ctx, parentID, domain.EventChildSpawned, domain.ChildSpawnedPayload{
+		Child:          childID,
+		BudgetFraction: budgetFraction,
+		BudgetCapUSD:   childCap,
+	})
+}
+
+// ListChildren returns the child flows spawned from a parent task.
+func (e *Engine) ListChildren(ctx context.Context, parentID string) ([]*domain.FlowState, error) {
+	return e.TaskRepo.ListChildren(ctx, e.Reader(), parentID)
+}
+
+// startFlow creates a new workflow at Phase A, optionally linked to a parent.
+func (e *Engine) startFlow(ctx context.Context, taskID string, budgetCapUSD float64, opts FlowOptions, parentID string) error {
+	status, eventType := domain.StatusRunning, domain.EventFlowStarted
+	if opts.Queued || opts.StartAt > 0 {
+		status, eventType = domain.StatusQueued, domain.EventFlowQueued
+	}
+
 	state := domain.FlowState{
 		TaskID:        taskID,
 		CurrentPhase:  domain.PhaseA,
-		Status:        domain.StatusRunning,
+		Status:        status,
 		StateVersion:  1,
 		Round:         0,
 		BudgetCapUSD:  budgetCapUSD,
 		BudgetUsedUSD: 0,
-		LastEventSeq:  1, // The initial flow_started event uses seq 1.
-		UpdatedAtUnix: time.Now().Unix(),
+		LastEventSeq:  1, // The initial flow_started/flow_queued event uses seq 1.
+		UpdatedAtUnix: e.Clock.Now().Unix(),
+		AutoAdvance:   opts.AutoAdvance,
+		ParentTaskID:  parentID,
+		StartAt:       opts.StartAt,
+		Priority:      opts.Priority,
+		Workspace:     opts.Workspace,
+
+		Title:              opts.Title,
+		Description:        opts.Description,
+		AcceptanceCriteria: opts.AcceptanceCriteria,
+		Consensus:          opts.Consensus,
+		Namespace:          opts.Namespace,
+	}
+	if state.Namespace == "" {
+		state.Namespace = domain.DefaultNamespace
 	}
+	seed := flowSeed{start: &domain.FlowStartedPayload{Parent: parentID, ShareWorkspace: opts.ShareWorkspace}}
+	return e.insertFlow(ctx, state, eventType, seed)
+}
+
+// flowSeed is what a new flow starts with besides its state.
+type flowSeed struct {
+	// payload is the JSON payload of the flow's first event, unless start
+	// is set.
+	payload string
+	// start is the payload of a started or queued flow's first event.
+	start *domain.FlowStartedPayload
+	// artifacts are carried into the flow as they are.
+	artifacts []domain.ArtifactRef
+	// snapshot, when set, is saved as the snapshot of the flow's first
+	// phase, so a later rollback to that phase restores to the flow's start.
+	snapshot *domain.PhaseSnapshot
+}
 
+// insertFlow creates a flow from state after checking its namespace budget,
+// provisioning its workspace if it names none.
+func (e *Engine) insertFlow(ctx context.Context, state domain.FlowState, eventType domain.EventType, seed flowSeed) error {
+	taskID := state.TaskID
+	if state.ParentTaskID == "" {
+		if err := e.checkNamespaceBudget(ctx, state.Namespace, state.BudgetCapUSD); err != nil {
+			return err
+		}
+	}
+
+	if state.Workspace == "" && e.Workspaces != nil {
+		// Never provision over the workspace of an existing task.
+		if _, err := e.TaskRepo.GetByID(ctx, e.DB, taskID); err == nil {
+			return domain.ErrDuplicateTask
+		}
+		path, err := e.Workspaces.Provision(ctx, taskID)
+		if err != nil {
+			return fmt.Errorf("provision workspace: %w", err)
+		}
+		state.Workspace = path
+		if err := e.createFlow(ctx, state, eventType, seed); err != nil {
+			_ = e.Workspaces.Discard(context.Background(), taskID, path)
+			return err
+		}
+		return nil
+	}
+	return e.createFlow(ctx, state, eventType, seed)
+}
+
+// createFlow inserts a new flow, its start event, and its seed in one
+// transaction, after settling its workspace lease and flow slot. Listeners are notified
+// of a flow created running, so its first phase's work starts.
+func (e *Engine) createFlow(ctx context.Context, state domain.FlowState, eventType domain.EventType, seed flowSeed) error {
+	taskID := state.TaskID
 	tx, err := e.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer tx.Rollback()
 
+	if err := e.leaseWorkspaceTx(ctx, tx, &state, &eventType, seed.start); err != nil {
+		return err
+	}
+	if err := e.claimSlotTx(ctx, tx, &state, &eventType, seed.start); err != nil {
+		return err
+	}
+	if seed.start != nil {
+		data, err := json.Marshal(seed.start)
+		if err != nil {
+			return fmt.Errorf("marshal start payload: %w", err)
+		}
+		seed.payload = string(data)
+	}
 	if err := e.TaskRepo.CreateTx(ctx, tx, state); err != nil {
 		return fmt.Errorf("create task: %w", err)
 	}
 
-	now := time.Now().Unix()
+	now := e.Clock.Now().Unix()
 	event := domain.WorkflowEvent{
 		TaskID:      taskID,
 		SeqNo:       1,
-		Phase:       domain.PhaseA,
-		EventType:   "flow_started",
-		PayloadJSON: "{}",
+		Phase:       state.CurrentPhase,
+		EventType:   eventType,
+		PayloadJSON: seed.payload,
 		CreatedAt:   now,
 	}
 	if err := e.EventRepo.AppendTx(ctx, tx, event); err != nil {
 		return fmt.Errorf("append start event: %w", err)
 	}
+	if state.Status == domain.StatusRunning {
+		if err := e.enterPhaseTx(ctx, tx, taskID, state.CurrentPhase, now); err != nil {
+			return err
+		}
+	}
+	if err := e.RoundRepo.StartTx(ctx, tx, domain.ReviewRound{
+		TaskID:    taskID,
+		Round:     state.Round,
+		Phase:     state.CurrentPhase,
+		StartedAt: now,
+	}); err != nil {
+		return err
+	}
+	for _, a := range seed.artifacts {
+		if err := e.ArtifactRepo.CreateTx(ctx, tx, a); err != nil {
+			return err
+		}
+	}
+	if seed.snapshot != nil {
+		if err := e.SnapshotRepo.SaveTx(ctx, tx, *seed.snapshot); err != nil {
+			return err
+		}
+	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	e.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicFlowCreated, TaskID: taskID})
+	if state.Status == domain.StatusRunning {
+		e.notify(ctx, state, state.CurrentPhase)
+	}
+	return nil
 }
 
 // Advance moves a workflow to the next phase based on the trigger.
-// The entire transition is performed in a single transaction with optimistic locking.
+// The entire transition is performed in a single transaction with optimistic
+// locking. If a concurrent write bumps the state version, the state is
+// reloaded and the transition retried up to RetryAttempts times, as long as
+// the flow is still in the phase first observed; otherwise
+// ErrTransitionSuperseded is returned.
 func (e *Engine) Advance(ctx context.Context, taskID string, trigger domain.TransitionTrigger) error {
-	// Load current state.
-	state, err := e.TaskRepo.GetByID(ctx, e.DB, taskID)
-	if err != nil {
-		return err
+	return e.advance(ctx, taskID, trigger, false)
+}
+
+// advance is Advance; with auto, a forward transition also records an
+// auto_advanced event carrying the gate decision that allowed it.
+func (e *Engine) advance(ctx context.Context, taskID string, trigger domain.TransitionTrigger, auto bool) error {
+	defer func(start time.Time) { e.advanceLatency.record(time.Since(start)) }(time.Now())
+	attempts := e.RetryAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var seen domain.Phase
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-e.Clock.After(e.RetryBackoff * time.Duration(attempt)):
+			}
+		}
+		seen, err = e.advanceOnce(ctx, taskID, trigger, seen, auto)
+		if err != domain.ErrOptimisticLock {
+			return err
+		}
 	}
+	return err
+}
 
-	if state.Status == domain.StatusDone {
+// checkRunning returns the error for transitioning a flow that is not
+// running: ErrFlowAlreadyDone, ErrFlowBlocked, or ErrFlowNotRunning.
+func checkRunning(state *domain.FlowState) error {
+	switch state.Status {
+	case domain.StatusRunning:
+		return nil
+	case domain.StatusDone:
 		return domain.ErrFlowAlreadyDone
+	case domain.StatusBlocked:
+		return domain.ErrFlowBlocked
 	}
+	return domain.NewEngineError(domain.ErrFlowNotRunning.Code,
+		fmt.Sprintf("flow %s is %s, not running", state.TaskID, state.Status))
+}
 
-	// Evaluate the gate for the current phase.
-	gate, err := e.GateRegistry.Get(state.CurrentPhase)
+// advanceOnce performs a single transition attempt. expected is the phase
+// observed by an earlier attempt ("" on the first). It returns the phase the
+// attempt observed.
+func (e *Engine) advanceOnce(ctx context.Context, taskID string, trigger domain.TransitionTrigger, expected domain.Phase, auto bool) (domain.Phase, error) {
+	// Load current state.
+	state, err := e.TaskRepo.GetByID(ctx, e.DB, taskID)
 	if err != nil {
-		return err
+		return expected, err
 	}
 
-	decision, err := gate.Evaluate(ctx, *state)
-	if err != nil {
-		return fmt.Errorf("evaluate gate: %w", err)
+	if expected != "" && state.CurrentPhase != expected {
+		return expected, domain.NewEngineError(domain.ErrTransitionSuperseded.Code,
+			fmt.Sprintf("flow moved from %s to %s concurrently", expected, state.CurrentPhase))
 	}
+	seen := state.CurrentPhase
 
-	if !decision.Allow {
-		return domain.NewEngineError(
-			domain.ErrPhaseGateFailed.Code,
-			fmt.Sprintf("gate blocked transition: %v", decision.Blockers),
-		)
+	if err := checkRunning(state); err != nil {
+		return seen, err
 	}
 
-	// Determine the target phase from the trigger action.
-	nextPhase, err := resolveNextPhase(state.CurrentPhase, trigger.Action)
+	nextPhase, err := targetPhase(state.CurrentPhase, trigger)
 	if err != nil {
-		return err
+		return seen, err
 	}
+	backward := phaseOrder[nextPhase] < phaseOrder[state.CurrentPhase]
 
-	// Validate the transition is legal.
-	if !IsValidTransition(state.CurrentPhase, nextPhase) {
-		return domain.NewEngineError(
-			domain.ErrInvalidTransition.Code,
-			fmt.Sprintf("illegal transition %s -> %s", state.CurrentPhase, nextPhase),
-		)
+	// Only a forward move exits the current phase through its gate; a
+	// rollback or rework leaves without evaluating it.
+	var gate Gate
+	var decision domain.GateDecision
+	if !backward {
+		gate, decision, err = e.EvaluateGate(ctx, state, trigger)
+		if err != nil {
+			return seen, err
+		}
+		if !decision.Allow {
+			return seen, domain.NewEngineError(
+				domain.ErrPhaseGateFailed.Code,
+				fmt.Sprintf("gate blocked transition: %v", decision.Blockers),
+			)
+		}
+	}
+
+	// A backward move restores the target phase's snapshot: everything
+	// recorded since the flow last entered that phase is invalidated.
+	var restored *domain.PhaseSnapshot
+	if backward {
+		restored, err = e.SnapshotRepo.GetLatest(ctx, e.DB, taskID, nextPhase)
+		if err != nil {
+			return seen, err
+		}
 	}
 
 	// Perform the transition in a single transaction.
 	tx, err := e.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
+		return seen, fmt.Errorf("begin tx: %w", err)
 	}
 	defer tx.Rollback()
 
-	now := time.Now().Unix()
+	now := e.Clock.Now().Unix()
 	newSeq := state.LastEventSeq + 1
 
+	// Update the state with optimistic locking first, so a concurrent writer
+	// surfaces as ErrOptimisticLock rather than an event sequence collision.
+	updatedState := *state
+	updatedState.CurrentPhase = nextPhase
+	updatedState.LastEventSeq = newSeq
+	updatedState.UpdatedAtUnix = now
+	auto = auto && !backward
+	if auto {
+		updatedState.LastEventSeq++
+	}
+
+	// If transitioning to phase G, mark as done.
+	if nextPhase == domain.PhaseG {
+		updatedState.Status = domain.StatusDone
+	}
+
+	// Track rollback/rework rounds.
+	if backward {
+		updatedState.Round = state.Round + 1
+	}
+
+	if err := e.TaskRepo.UpdateStateTx(ctx, tx, updatedState); err != nil {
+		return seen, err
+	}
+
+	payload := domain.PhaseTransitionPayload{
+		From:   state.CurrentPhase,
+		To:     nextPhase,
+		Action: trigger.Action,
+		Actor:  trigger.Actor,
+	}
+	snapPayload := map[string]interface{}{
+		"from_phase": state.CurrentPhase,
+		"to_phase":   nextPhase,
+		"trigger":    trigger.Action,
+	}
+
+	if backward {
+		// Without a snapshot (phase A) the flow restarts from its creation.
+		var since int64
+		if restored != nil {
+			since = restored.CreatedAt
+			payload.RestoredSnapshot = restored.ID
+			snapPayload["restored_from"] = restored.ID
+		}
+		intents, err := e.IntentRepo.InvalidateSinceTx(ctx, tx, taskID, since)
+		if err != nil {
+			return seen, err
+		}
+		reviews, err := e.ReviewRepo.InvalidateSinceTx(ctx, tx, taskID, since, now)
+		if err != nil {
+			return seen, err
+		}
+		payload.InvalidatedIntents = intents
+		payload.InvalidatedReviews = reviews
+
+		// The rollback ends the current review round and starts the next.
+		if err := e.RoundRepo.EndTx(ctx, tx, taskID, state.Round, now, trigger.Action); err != nil {
+			return seen, err
+		}
+		if err := e.RoundRepo.StartTx(ctx, tx, domain.ReviewRound{
+			TaskID:    taskID,
+			Round:     updatedState.Round,
+			Phase:     nextPhase,
+			StartedAt: now,
+		}); err != nil {
+			return seen, err
+		}
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return seen, fmt.Errorf("marshal transition payload: %w", err)
+	}
+	snapJSON, err := json.Marshal(snapPayload)
+	if err != nil {
+		return seen, fmt.Errorf("marshal snapshot: %w", err)
+	}
+
 	// Append the transition event.
 	event := domain.WorkflowEvent{
 		TaskID:      taskID,
 		SeqNo:       newSeq,
 		Phase:       nextPhase,
-		EventType:   "phase_transition",
-		PayloadJSON: fmt.Sprintf(`{"from":"%s","to":"%s","action":"%s","actor":"%s"}`, state.CurrentPhase, nextPhase, trigger.Action, trigger.Actor),
+		EventType:   domain.EventPhaseTransition,
+		PayloadJSON: string(payloadJSON),
 		CreatedAt:   now,
 	}
 	if err := e.EventRepo.AppendTx(ctx, tx, event); err != nil {
-		return fmt.Errorf("append transition event: %w", err)
+		return seen, fmt.Errorf("append transition event: %w", err)
+	}
+	if auto {
+		decision.NextPhase = nextPhase
+		autoJSON, err := json.Marshal(domain.AutoAdvancedPayload{
+			From:     state.CurrentPhase,
+			To:       nextPhase,
+			Gate:     gate.Name(),
+			Decision: decision,
+		})
+		if err != nil {
+			return seen, fmt.Errorf("marshal auto_advanced payload: %w", err)
+		}
+		event.SeqNo, event.EventType, event.PayloadJSON = newSeq+1, domain.EventAutoAdvanced, string(autoJSON)
+		if err := e.EventRepo.AppendTx(ctx, tx, event); err != nil {
+			return seen, fmt.Errorf("append auto_advanced event: %w", err)
+		}
+	}
+	if err := e.DurationRepo.ExitTx(ctx, tx, taskID, now); err != nil {
+		return seen, err
+	}
+	if nextPhase != domain.PhaseG {
+		if err := e.enterPhaseTx(ctx, tx, taskID, nextPhase, now); err != nil {
+			return seen, err
+		}
 	}
 
 	// Save a snapshot at the phase boundary.
@@ -164,49 +869,189 @@ func (e *Engine) Advance(ctx context.Context, taskID string, trigger domain.Tran
 		TaskID:       taskID,
 		Phase:        nextPhase,
 		Round:        state.Round,
-		SnapshotJSON: fmt.Sprintf(`{"from_phase":"%s","to_phase":"%s","trigger":"%s"}`, state.CurrentPhase, nextPhase, trigger.Action),
+		SnapshotJSON: string(snapJSON),
 		Checksum:     "",
 		CreatedAt:    now,
 	}
 	if err := e.SnapshotRepo.SaveTx(ctx, tx, snap); err != nil {
-		return fmt.Errorf("save snapshot: %w", err)
+		return seen, fmt.Errorf("save snapshot: %w", err)
 	}
 
-	// Update the state with optimistic locking.
-	updatedState := *state
-	updatedState.CurrentPhase = nextPhase
-	updatedState.LastEventSeq = newSeq
-	updatedState.UpdatedAtUnix = now
+	if err := tx.Commit(); err != nil {
+		return seen, err
+	}
 
-	// If transitioning to phase G, mark as done.
-	if nextPhase == domain.PhaseG {
-		updatedState.Status = domain.StatusDone
+	updatedState.StateVersion++
+	e.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicFlowUpdated, TaskID: taskID})
+	e.notify(ctx, updatedState, state.CurrentPhase)
+	return seen, nil
+}
+
+// AddListener registers a listener that is called after every committed transition.
+func (e *Engine) AddListener(l TransitionListener) {
+	e.listenersMu.Lock()
+	defer e.listenersMu.Unlock()
+	e.listeners = append(e.listeners, l)
+}
+
+// notify calls every registered listener in registration order.
+func (e *Engine) notify(ctx context.Context, state domain.FlowState, from domain.Phase) {
+	e.listenersMu.RLock()
+	listeners := append([]TransitionListener(nil), e.listeners...)
+	e.listenersMu.RUnlock()
+
+	for _, l := range listeners {
+		l(ctx, state, from)
 	}
+}
 
-	// Track rollback/rework rounds.
-	if (state.CurrentPhase == domain.PhaseD && nextPhase == domain.PhaseC) ||
-		(state.CurrentPhase == domain.PhaseF && nextPhase == domain.PhaseE) {
-		updatedState.Round = state.Round + 1
+// AppendEvent records a workflow event at the task's next sequence number,
+// tagged with the current phase. payload is marshalled to JSON.
+func (e *Engine) AppendEvent(ctx context.Context, taskID string, eventType domain.EventType, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal event payload: %w", err)
 	}
+	_, err = e.appendEvent(ctx, taskID, eventType, string(data))
+	return err
+}
 
-	if err := e.TaskRepo.UpdateStateTx(ctx, tx, updatedState); err != nil {
-		return err
+// IngestEvent records a custom event reported by an external system, such
+// as CI, and announces it so gates waiting on it are evaluated again. The
+// event type must be a configured custom type and the payload must match
+// its schema. It returns the stored event.
+func (e *Engine) IngestEvent(ctx context.Context, taskID string, eventType domain.EventType, payloadJSON string) (*domain.WorkflowEvent, error) {
+	if !eventType.Custom() {
+		return nil, domain.NewEngineError(domain.ErrEventInvalid.Code,
+			fmt.Sprintf("event type %q is not a custom type such as \"ci.build\"", eventType))
+	}
+	version, err := domain.ValidateEvent(eventType, payloadJSON)
+	if err != nil {
+		return nil, err
+	}
+	event, err := e.appendEvent(ctx, taskID, eventType, payloadJSON)
+	if err != nil {
+		return nil, err
+	}
+	event.SchemaVersion = version
+	e.Bus.Publish(eventbus.Signal{Topic: eventbus.TopicCustomEvent, TaskID: taskID})
+	return event, nil
+}
+
+// appendEvent records an event with a JSON payload at the task's next
+// sequence number.
+func (e *Engine) appendEvent(ctx context.Context, taskID string, eventType domain.EventType, payloadJSON string) (*domain.WorkflowEvent, error) {
+	state, err := e.TaskRepo.GetByID(ctx, e.DB, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
 	}
+	defer tx.Rollback()
 
-	return tx.Commit()
+	now := e.Clock.Now().Unix()
+	updated := *state
+	updated.LastEventSeq = state.LastEventSeq + 1
+	updated.UpdatedAtUnix = now
+
+	event := domain.WorkflowEvent{
+		TaskID:      taskID,
+		SeqNo:       updated.LastEventSeq,
+		Phase:       state.CurrentPhase,
+		EventType:   eventType,
+		PayloadJSON: payloadJSON,
+		CreatedAt:   now,
+	}
+	if err := e.TaskRepo.UpdateStateTx(ctx, tx, updated); err != nil {
+		return nil, err
+	}
+	if err := e.EventRepo.AppendTx(ctx, tx, event); err != nil {
+		return nil, fmt.Errorf("append %s event: %w", eventType, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &event, nil
 }
 
 // GetState returns the current state of a workflow.
 func (e *Engine) GetState(ctx context.Context, taskID string) (*domain.FlowState, error) {
-	return e.TaskRepo.GetByID(ctx, e.DB, taskID)
+	return e.TaskRepo.GetByID(ctx, e.Reader(), taskID)
 }
 
-// resolveNextPhase determines the target phase from the trigger action.
-func resolveNextPhase(current domain.Phase, action string) (domain.Phase, error) {
-	switch action {
+// Reader returns the connection pool for read-only queries: ReadDB when
+// configured, otherwise DB.
+func (e *Engine) Reader() *sql.DB {
+	if e.ReadDB != nil {
+		return e.ReadDB
+	}
+	return e.DB
+}
+
+// EvaluateGate evaluates the gate of state's current phase for trigger
+// and, unless ctx is a dry run, records the decision in the flow's gate
+// history. Gates can read trigger with triggerFrom.
+func (e *Engine) EvaluateGate(ctx context.Context, state *domain.FlowState, trigger domain.TransitionTrigger) (Gate, domain.GateDecision, error) {
+	gate, err := e.GateRegistry.Get(state.CurrentPhase)
+	if err != nil {
+		return nil, domain.GateDecision{}, err
+	}
+	decision, err := gate.Evaluate(context.WithValue(ctx, triggerKey{}, trigger), *state)
+	if err != nil {
+		return gate, decision, fmt.Errorf("evaluate gate: %w", err)
+	}
+	if IsDryRun(ctx) {
+		return gate, decision, nil
+	}
+	_, err = e.GateRepo.Create(ctx, e.DB, domain.GateRecord{
+		TaskID:    state.TaskID,
+		Phase:     state.CurrentPhase,
+		Gate:      gate.Name(),
+		Allow:     decision.Allow,
+		Blockers:  decision.Blockers,
+		Retryable: decision.Retryable,
+		Action:    trigger.Action,
+		Actor:     trigger.Actor,
+		CreatedAt: e.Clock.Now().Unix(),
+	})
+	if err != nil {
+		return gate, decision, err
+	}
+	return gate, decision, nil
+}
+
+// targetPhase resolves the phase trigger moves a flow in current to and
+// checks the transition is legal.
+func targetPhase(current domain.Phase, trigger domain.TransitionTrigger) (domain.Phase, error) {
+	next, err := resolveNextPhase(current, trigger)
+	if err != nil {
+		return "", err
+	}
+	valid := IsValidTransition(current, next)
+	if trigger.Action == "rollback" && trigger.RollbackTo != "" {
+		valid = IsValidRollback(current, next)
+	}
+	if !valid {
+		return "", domain.NewEngineError(
+			domain.ErrInvalidTransition.Code,
+			fmt.Sprintf("illegal transition %s -> %s", current, next),
+		)
+	}
+	return next, nil
+}
+
+// resolveNextPhase determines the target phase from the trigger.
+func resolveNextPhase(current domain.Phase, trigger domain.TransitionTrigger) (domain.Phase, error) {
+	switch action := trigger.Action; action {
 	case "advance":
 		return nextPhaseForward(current)
 	case "rollback":
+		if trigger.RollbackTo != "" {
+			return trigger.RollbackTo, nil
+		}
 		if current == domain.PhaseD {
 			return domain.PhaseC, nil
 		}
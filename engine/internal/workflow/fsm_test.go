@@ -3,17 +3,17 @@ package workflow
 import (
 	"context"
 	"fmt"
-	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
 	"github.com/anthropics/three-body-engine/internal/store"
 )
 
 func newTestEngine(t *testing.T) *Engine {
 	t.Helper()
-	dir := t.TempDir()
-	db, err := store.NewDB(filepath.Join(dir, "test.db"))
+	db, err := store.NewDB(store.MemoryPath)
 	if err != nil {
 		t.Fatalf("NewDB: %v", err)
 	}
@@ -114,6 +114,32 @@ func TestEngine_AdvanceFromDone(t *testing.T) {
 	}
 }
 
+func TestEngine_AdvanceRequiresRunning(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	trigger := domain.TransitionTrigger{Action: "advance", Actor: "test"}
+
+	eng.StartFlowWithOptions(ctx, "queued", 100.0, FlowOptions{Queued: true})
+	eng.StartFlow(ctx, "paused", 100.0)
+	eng.Pause(ctx, "paused", "other")
+	eng.StartFlow(ctx, "blocked", 100.0)
+	eng.Block(ctx, "blocked", "waiting")
+
+	for id, want := range map[string]int{
+		"queued":  domain.ErrFlowNotRunning.Code,
+		"paused":  domain.ErrFlowNotRunning.Code,
+		"blocked": domain.ErrFlowBlocked.Code,
+	} {
+		err := eng.Advance(ctx, id, trigger)
+		if engErr, ok := err.(*domain.EngineError); !ok || engErr.Code != want {
+			t.Errorf("Advance(%s) = %v, want code %d", id, err, want)
+		}
+		if state, _ := eng.GetState(ctx, id); state.CurrentPhase != domain.PhaseA {
+			t.Errorf("%s Phase = %q, want A", id, state.CurrentPhase)
+		}
+	}
+}
+
 func TestEngine_Rollback_D_to_C(t *testing.T) {
 	eng := newTestEngine(t)
 	ctx := context.Background()
@@ -148,6 +174,95 @@ func TestEngine_Rollback_D_to_C(t *testing.T) {
 	}
 }
 
+func TestEngine_RollbackSkipsExitGate(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+
+	eng.StartFlow(ctx, "task-1", 100.0)
+	advanceTrigger := domain.TransitionTrigger{Action: "advance", Actor: "test"}
+	for i := 0; i < 3; i++ {
+		eng.Advance(ctx, "task-1", advanceTrigger)
+	}
+	eng.GateRegistry.Register(domain.PhaseD, &stubGate{name: "blocking", blockers: []string{"never"}})
+
+	if err := eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "rollback", Actor: "test"}); err != nil {
+		t.Fatalf("Rollback D->C past a refusing gate: %v", err)
+	}
+	records, err := eng.GateRepo.ListByTask(ctx, eng.DB, "task-1", domain.PhaseD, 0)
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("gate decisions for D = %+v, want none", records)
+	}
+}
+
+func TestEngine_RollbackTo_InvalidatesLaterWork(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+
+	eng.StartFlow(ctx, "task-1", 100.0)
+	advanceTrigger := domain.TransitionTrigger{Action: "advance", Actor: "test"}
+
+	// Advance to E: A -> B -> C -> D -> E
+	for i := 0; i < 4; i++ {
+		if err := eng.Advance(ctx, "task-1", advanceTrigger); err != nil {
+			t.Fatalf("Advance step %d: %v", i, err)
+		}
+	}
+
+	// One intent and one review predate phase B; one of each follows it.
+	later := time.Now().Unix() + 5
+	tx, _ := eng.DB.Begin()
+	eng.IntentRepo.UpsertTx(ctx, tx, domain.Intent{IntentID: "int-old", TaskID: "task-1", TargetFile: "a.go", Operation: "write", Status: "done", CreatedAt: 1})
+	eng.IntentRepo.UpsertTx(ctx, tx, domain.Intent{IntentID: "int-new", TaskID: "task-1", TargetFile: "b.go", Operation: "write", Status: "pending", CreatedAt: later})
+	tx.Commit()
+	eng.ReviewRepo.Create(ctx, eng.DB, domain.ScoreCard{ReviewID: "r-old", TaskID: "task-1", Reviewer: "x", Verdict: "pass", CreatedAt: 1})
+	eng.ReviewRepo.Create(ctx, eng.DB, domain.ScoreCard{ReviewID: "r-new", TaskID: "task-1", Reviewer: "y", Verdict: "fail", CreatedAt: later})
+
+	rollback := domain.TransitionTrigger{Action: "rollback", Actor: "test", RollbackTo: domain.PhaseB}
+	if err := eng.Advance(ctx, "task-1", rollback); err != nil {
+		t.Fatalf("Rollback E->B: %v", err)
+	}
+
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.CurrentPhase != domain.PhaseB {
+		t.Errorf("Phase = %q after rollback, want B", state.CurrentPhase)
+	}
+	if state.Round != 1 {
+		t.Errorf("Round = %d after rollback, want 1", state.Round)
+	}
+
+	intent, _ := eng.IntentRepo.GetByID(ctx, eng.DB, "int-new")
+	if intent.Status != "invalidated" {
+		t.Errorf("int-new Status = %q, want invalidated", intent.Status)
+	}
+	intent, _ = eng.IntentRepo.GetByID(ctx, eng.DB, "int-old")
+	if intent.Status != "done" {
+		t.Errorf("int-old Status = %q, want done", intent.Status)
+	}
+
+	cards, _ := eng.ReviewRepo.ListByTask(ctx, eng.DB, "task-1")
+	if len(cards) != 1 || cards[0].ReviewID != "r-old" {
+		t.Errorf("cards = %+v, want only r-old", cards)
+	}
+}
+
+func TestEngine_RollbackTo_RejectsForwardTarget(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+
+	eng.StartFlow(ctx, "task-1", 100.0)
+	eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "test"})
+
+	for _, target := range []domain.Phase{domain.PhaseB, domain.PhaseD, "Z"} {
+		trigger := domain.TransitionTrigger{Action: "rollback", Actor: "test", RollbackTo: target}
+		if err := eng.Advance(ctx, "task-1", trigger); err == nil {
+			t.Errorf("rollback_to %q from B: expected error", target)
+		}
+	}
+}
+
 func TestEngine_Rework_F_to_E(t *testing.T) {
 	eng := newTestEngine(t)
 	ctx := context.Background()
@@ -266,3 +381,302 @@ func TestIsValidTransition(t *testing.T) {
 		})
 	}
 }
+
+func TestEngine_ListenersNotifiedAfterCommit(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 100.0)
+
+	var got []string
+	eng.AddListener(func(ctx context.Context, state domain.FlowState, from domain.Phase) {
+		persisted, err := eng.GetState(ctx, state.TaskID)
+		if err != nil {
+			t.Errorf("GetState in listener: %v", err)
+			return
+		}
+		if persisted.StateVersion != state.StateVersion {
+			t.Errorf("listener StateVersion = %d, persisted = %d", state.StateVersion, persisted.StateVersion)
+		}
+		got = append(got, fmt.Sprintf("%s->%s", from, state.CurrentPhase))
+	})
+
+	trigger := domain.TransitionTrigger{Action: "advance", Actor: "test"}
+	eng.Advance(ctx, "task-1", trigger)
+	eng.Advance(ctx, "task-1", trigger)
+
+	if len(got) != 2 || got[0] != "A->B" || got[1] != "B->C" {
+		t.Errorf("notifications = %v, want [A->B B->C]", got)
+	}
+}
+
+func TestEngine_SpawnChild_InheritsBudgetFraction(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "parent", 100.0)
+
+	if err := eng.SpawnChild(ctx, "parent", "child-1", 0.4, FlowOptions{}); err != nil {
+		t.Fatalf("SpawnChild: %v", err)
+	}
+	child, err := eng.GetState(ctx, "child-1")
+	if err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if child.BudgetCapUSD != 40.0 {
+		t.Errorf("child BudgetCapUSD = %f, want 40", child.BudgetCapUSD)
+	}
+	if child.ParentTaskID != "parent" {
+		t.Errorf("ParentTaskID = %q, want parent", child.ParentTaskID)
+	}
+
+	// 0.4 + 0.7 exceeds the parent cap.
+	if err := eng.SpawnChild(ctx, "parent", "child-2", 0.7, FlowOptions{}); err == nil {
+		t.Error("expected error when child budgets exceed parent cap")
+	}
+	if err := eng.SpawnChild(ctx, "parent", "child-3", 0, FlowOptions{}); err == nil {
+		t.Error("expected error for zero budget fraction")
+	}
+}
+
+func TestEngine_ParentWaitsForChildren(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	advanceTrigger := domain.TransitionTrigger{Action: "advance", Actor: "test"}
+
+	eng.StartFlow(ctx, "parent", 100.0)
+	eng.SpawnChild(ctx, "parent", "child-1", 0.5, FlowOptions{})
+
+	if err := eng.Advance(ctx, "parent", advanceTrigger); err == nil {
+		t.Fatal("expected join gate to block parent while child is running")
+	}
+
+	// Drive the child to G (completed).
+	for i := 0; i < 6; i++ {
+		if err := eng.Advance(ctx, "child-1", advanceTrigger); err != nil {
+			t.Fatalf("child Advance step %d: %v", i, err)
+		}
+	}
+
+	if err := eng.Advance(ctx, "parent", advanceTrigger); err != nil {
+		t.Fatalf("parent Advance after child completed: %v", err)
+	}
+}
+
+// racingGate performs a concurrent write on its first evaluation so the
+// caller's optimistic lock fails.
+type racingGate struct {
+	calls int
+	race  func()
+}
+
+func (g *racingGate) Name() string { return "racing" }
+func (g *racingGate) Evaluate(_ context.Context, _ domain.FlowState) (domain.GateDecision, error) {
+	g.calls++
+	if g.calls == 1 {
+		g.race()
+	}
+	return domain.GateDecision{Allow: true}, nil
+}
+
+func TestEngine_Advance_RetriesAfterConcurrentWrite(t *testing.T) {
+	eng := newTestEngine(t)
+	eng.RetryBackoff = time.Millisecond
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+
+	gate := &racingGate{race: func() {
+		if err := eng.AppendEvent(ctx, "task-1", "x_note", map[string]string{}); err != nil {
+			t.Fatalf("AppendEvent: %v", err)
+		}
+	}}
+	eng.GateRegistry.Register(domain.PhaseA, gate)
+
+	if err := eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "test"}); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if gate.calls != 2 {
+		t.Errorf("gate calls = %d, want 2", gate.calls)
+	}
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.CurrentPhase != domain.PhaseB {
+		t.Errorf("Phase = %q, want B", state.CurrentPhase)
+	}
+}
+
+func TestEngine_Advance_SupersededByConcurrentTransition(t *testing.T) {
+	eng := newTestEngine(t)
+	eng.RetryBackoff = time.Millisecond
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+
+	// The racing writer moves the flow to B behind the caller's back.
+	gate := &racingGate{race: func() {
+		state, _ := eng.GetState(ctx, "task-1")
+		state.CurrentPhase = domain.PhaseB
+		tx, _ := eng.DB.Begin()
+		if err := eng.TaskRepo.UpdateStateTx(ctx, tx, *state); err != nil {
+			t.Fatalf("UpdateStateTx: %v", err)
+		}
+		tx.Commit()
+	}}
+	eng.GateRegistry.Register(domain.PhaseA, gate)
+
+	err := eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "test"})
+	engErr, ok := err.(*domain.EngineError)
+	if !ok || engErr.Code != domain.ErrTransitionSuperseded.Code {
+		t.Fatalf("expected ErrTransitionSuperseded, got %v", err)
+	}
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.CurrentPhase != domain.PhaseB {
+		t.Errorf("Phase = %q, want B (no double advance)", state.CurrentPhase)
+	}
+}
+
+func TestEngine_Block(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	if err := eng.StartFlow(ctx, "task-1", 10.0); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	var notified domain.FlowStatus
+	eng.AddListener(func(_ context.Context, state domain.FlowState, _ domain.Phase) {
+		notified = state.Status
+	})
+
+	if err := eng.Block(ctx, "task-1", "worker timed out"); err != nil {
+		t.Fatalf("Block: %v", err)
+	}
+	state, err := eng.GetState(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if state.Status != domain.StatusBlocked || notified != domain.StatusBlocked {
+		t.Errorf("status = %q, notified %q; want blocked", state.Status, notified)
+	}
+	if err := eng.Block(ctx, "task-1", "again"); err == nil {
+		t.Error("expected blocking a blocked flow to fail")
+	}
+}
+
+func TestEngine_RollbackStartsReviewRound(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+
+	eng.StartFlow(ctx, "task-1", 100.0)
+	for i := 0; i < 3; i++ {
+		if err := eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "test"}); err != nil {
+			t.Fatalf("Advance: %v", err)
+		}
+	}
+	if err := eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "rollback", Actor: "test"}); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	rounds, err := eng.RoundRepo.ListByTask(ctx, eng.DB, "task-1")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(rounds) != 2 {
+		t.Fatalf("rounds = %+v, want 2", rounds)
+	}
+	if rounds[0].Phase != domain.PhaseA || rounds[0].Outcome != "rollback" || rounds[0].EndedAt == 0 {
+		t.Errorf("round 0 = %+v, want ended by rollback", rounds[0])
+	}
+	if rounds[1].Round != 1 || rounds[1].Phase != domain.PhaseC || rounds[1].EndedAt != 0 {
+		t.Errorf("round 1 = %+v, want open in phase C", rounds[1])
+	}
+}
+
+func TestEngine_PublishesFlowUpdated(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	if err := eng.StartFlow(ctx, "task-1", 10.0); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	bus := eventbus.New()
+	eng.Bus = bus
+	ch, unsubscribe := bus.Subscribe(4)
+	defer unsubscribe()
+
+	if err := eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance", Actor: "test"}); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if err := eng.Block(ctx, "task-1", "waiting"); err != nil {
+		t.Fatalf("Block: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case sig := <-ch:
+			if sig.Topic != eventbus.TopicFlowUpdated || sig.TaskID != "task-1" {
+				t.Errorf("signal %d = %+v", i, sig)
+			}
+		default:
+			t.Fatalf("signal %d not published", i)
+		}
+	}
+}
+
+func TestEngine_RecordsGateDecisions(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	eng.GateRegistry.Register(domain.PhaseB, &stubGate{name: "blocking", blockers: []string{"never"}})
+
+	trigger := domain.TransitionTrigger{Action: "advance", Actor: "alice"}
+	if err := eng.Advance(ctx, "task-1", trigger); err != nil {
+		t.Fatalf("Advance A: %v", err)
+	}
+	if err := eng.Advance(ctx, "task-1", trigger); err == nil {
+		t.Fatal("expected the phase B gate to block")
+	}
+	if _, err := eng.Preview(ctx, "task-1", trigger); err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+
+	records, err := eng.GateRepo.ListByTask(ctx, eng.DB, "task-1", "", 0)
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("records = %+v, want two (the preview is not recorded)", records)
+	}
+	if r := records[0]; r.Phase != domain.PhaseB || r.Gate != "blocking" || r.Allow ||
+		len(r.Blockers) != 1 || r.Action != "advance" || r.Actor != "alice" {
+		t.Errorf("blocked record = %+v", r)
+	}
+	if r := records[1]; r.Phase != domain.PhaseA || !r.Allow {
+		t.Errorf("allowed record = %+v", r)
+	}
+}
+
+func TestEngine_IngestEvent(t *testing.T) {
+	eng := newTestEngine(t)
+	eng.Bus = eventbus.New()
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	domain.CustomEventSchemas = map[domain.EventType]domain.CustomEventSchema{
+		"ci.build": {Version: 1, Fields: map[string]string{"status": "string"}},
+	}
+	t.Cleanup(func() { domain.CustomEventSchemas = map[domain.EventType]domain.CustomEventSchema{} })
+	signals, unsubscribe := eng.Bus.Subscribe(4)
+	defer unsubscribe()
+
+	event, err := eng.IngestEvent(ctx, "task-1", "ci.build", `{"status": "success"}`)
+	if err != nil {
+		t.Fatalf("IngestEvent: %v", err)
+	}
+	if event.SeqNo != 2 || event.SchemaVersion != 1 {
+		t.Errorf("event = %+v", event)
+	}
+	select {
+	case sig := <-signals:
+		if sig.Topic != eventbus.TopicCustomEvent || sig.TaskID != "task-1" {
+			t.Errorf("signal = %+v", sig)
+		}
+	default:
+		t.Error("no signal announced the custom event")
+	}
+
+	if _, err := eng.IngestEvent(ctx, "task-1", "x_note", `{}`); err == nil {
+		t.Error("IngestEvent accepted an experimental event type")
+	}
+}
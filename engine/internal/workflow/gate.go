@@ -3,8 +3,12 @@ package workflow
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 
 	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/review"
+	"github.com/anthropics/three-body-engine/internal/store"
 	"github.com/anthropics/three-body-engine/internal/team"
 )
 
@@ -14,6 +18,15 @@ type Gate interface {
 	Evaluate(ctx context.Context, state domain.FlowState) (domain.GateDecision, error)
 }
 
+type triggerKey struct{}
+
+// triggerFrom returns the transition trigger a gate is evaluated for, when
+// the evaluation came through Engine.EvaluateGate.
+func triggerFrom(ctx context.Context) (domain.TransitionTrigger, bool) {
+	t, ok := ctx.Value(triggerKey{}).(domain.TransitionTrigger)
+	return t, ok
+}
+
 // DefaultGate is a basic gate that checks running status and budget.
 type DefaultGate struct {
 	Governor *BudgetGovernor
@@ -120,9 +133,11 @@ func (g *CompactionGate) Evaluate(ctx context.Context, state domain.FlowState) (
 }
 
 // ReviewGate wraps an inner gate and checks for unresolved blockers.
+// When DiffsFn is set, its score card diffs are attached to every decision.
 type ReviewGate struct {
 	Inner      Gate
 	BlockersFn func(ctx context.Context, state domain.FlowState) ([]string, error)
+	DiffsFn    func(ctx context.Context, state domain.FlowState) ([]domain.ScoreCardDiff, error)
 }
 
 // Name returns the gate name.
@@ -136,19 +151,137 @@ func (g *ReviewGate) Evaluate(ctx context.Context, state domain.FlowState) (doma
 	if err != nil {
 		return inner, err
 	}
+
+	decision := inner
+	if inner.Allow {
+		blockers, err := g.BlockersFn(ctx, state)
+		if err != nil {
+			return domain.GateDecision{}, err
+		}
+		if len(blockers) > 0 {
+			decision = domain.GateDecision{
+				Allow:    false,
+				Blockers: blockers,
+			}
+		}
+	}
+
+	if g.DiffsFn != nil {
+		if decision.ReviewDiffs, err = g.DiffsFn(ctx, state); err != nil {
+			return domain.GateDecision{}, err
+		}
+	}
+	return decision, nil
+}
+
+// RiskBlockers returns a ReviewGate BlockersFn that reports each open P0 risk
+// of the flow as a blocker.
+func RiskBlockers(db *sql.DB, repo *store.RiskRepo) func(ctx context.Context, state domain.FlowState) ([]string, error) {
+	return func(ctx context.Context, state domain.FlowState) ([]string, error) {
+		risks, err := repo.ListByTask(ctx, db, state.TaskID, "open")
+		if err != nil {
+			return nil, fmt.Errorf("list risks: %w", err)
+		}
+		var blockers []string
+		for _, k := range risks {
+			if k.Severity == "P0" {
+				blockers = append(blockers, fmt.Sprintf("P0 risk %s: %s", k.RiskID, k.Text))
+			}
+		}
+		return blockers, nil
+	}
+}
+
+// IssueBlockers returns a ReviewGate BlockersFn that reports each P0 or P1
+// review issue of the flow that is not yet fixed or marked wont_fix.
+func IssueBlockers(db *sql.DB, repo *store.IssueRepo) func(ctx context.Context, state domain.FlowState) ([]string, error) {
+	return func(ctx context.Context, state domain.FlowState) ([]string, error) {
+		issues, err := repo.ListByTask(ctx, db, state.TaskID, "")
+		if err != nil {
+			return nil, fmt.Errorf("list review issues: %w", err)
+		}
+		var blockers []string
+		for _, i := range issues {
+			if !i.Closed() && (i.Severity == "P0" || i.Severity == "P1") {
+				blockers = append(blockers, fmt.Sprintf("%s issue %s from %s at %s is %s: %s",
+					i.Severity, i.IssueID, i.Reviewer, i.Location, i.Status, i.Description))
+			}
+		}
+		return blockers, nil
+	}
+}
+
+// AllBlockers returns a ReviewGate BlockersFn that collects the blockers of
+// every fn in order.
+func AllBlockers(fns ...func(ctx context.Context, state domain.FlowState) ([]string, error)) func(ctx context.Context, state domain.FlowState) ([]string, error) {
+	return func(ctx context.Context, state domain.FlowState) ([]string, error) {
+		var blockers []string
+		for _, fn := range fns {
+			b, err := fn(ctx, state)
+			if err != nil {
+				return nil, err
+			}
+			blockers = append(blockers, b...)
+		}
+		return blockers, nil
+	}
+}
+
+// ScoreCardDiffs returns a ReviewGate DiffsFn that compares each reviewer's
+// score cards in the flow's current round with their previous round. Flows
+// still in their first round have nothing to compare.
+func ScoreCardDiffs(db *sql.DB, repo *store.ScoreCardRepo) func(ctx context.Context, state domain.FlowState) ([]domain.ScoreCardDiff, error) {
+	return func(ctx context.Context, state domain.FlowState) ([]domain.ScoreCardDiff, error) {
+		if state.Round == 0 {
+			return nil, nil
+		}
+		cards, err := repo.ListHistory(ctx, db, state.TaskID)
+		if err != nil {
+			return nil, fmt.Errorf("list score cards: %w", err)
+		}
+		return review.DiffRounds(cards, -1, state.Round), nil
+	}
+}
+
+// JoinGate wraps an inner gate and holds a parent flow until every child flow
+// spawned from it has reached a terminal state (completed or failed).
+type JoinGate struct {
+	Inner    Gate
+	DB       *sql.DB
+	TaskRepo *store.TaskRepo
+}
+
+// Name returns the gate name.
+func (g *JoinGate) Name() string {
+	return "join"
+}
+
+// Evaluate checks the inner gate first, then blocks on unfinished children.
+func (g *JoinGate) Evaluate(ctx context.Context, state domain.FlowState) (domain.GateDecision, error) {
+	inner, err := g.Inner.Evaluate(ctx, state)
+	if err != nil {
+		return inner, err
+	}
 	if !inner.Allow {
 		return inner, nil
 	}
 
-	blockers, err := g.BlockersFn(ctx, state)
+	children, err := g.TaskRepo.ListChildren(ctx, g.DB, state.TaskID)
 	if err != nil {
 		return domain.GateDecision{}, err
 	}
 
+	var blockers []string
+	for _, child := range children {
+		if child.Status != domain.StatusDone && child.Status != domain.StatusFailed {
+			blockers = append(blockers, "child flow "+child.TaskID+" is "+string(child.Status))
+		}
+	}
 	if len(blockers) > 0 {
 		return domain.GateDecision{
-			Allow:    false,
-			Blockers: blockers,
+			Allow:     false,
+			Blockers:  blockers,
+			Retryable: true,
 		}, nil
 	}
 
@@ -346,3 +346,164 @@ func TestCompositeGate_PropagatesError(t *testing.T) {
 		t.Errorf("expected testErr, got %v", err)
 	}
 }
+
+// --- JoinGate tests ---
+
+func TestJoinGate_BlocksOnRunningChild(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "parent", 10.0)
+	eng.SpawnChild(ctx, "parent", "child-1", 0.5, FlowOptions{})
+
+	gate := &JoinGate{Inner: &stubGate{name: "inner", allow: true}, DB: eng.DB, TaskRepo: eng.TaskRepo}
+	parent, _ := eng.GetState(ctx, "parent")
+	decision, err := gate.Evaluate(ctx, *parent)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if decision.Allow {
+		t.Error("expected Allow=false with a running child")
+	}
+	if len(decision.Blockers) != 1 {
+		t.Errorf("expected 1 blocker, got %v", decision.Blockers)
+	}
+}
+
+func TestJoinGate_AllowsWithoutChildren(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "parent", 10.0)
+
+	gate := &JoinGate{Inner: &stubGate{name: "inner", allow: true}, DB: eng.DB, TaskRepo: eng.TaskRepo}
+	parent, _ := eng.GetState(ctx, "parent")
+	decision, err := gate.Evaluate(ctx, *parent)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if !decision.Allow {
+		t.Errorf("expected Allow=true, blockers: %v", decision.Blockers)
+	}
+}
+
+func TestReviewGate_BlocksOnOpenP0Risk(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+
+	risks := &store.RiskRepo{}
+	for _, k := range []domain.Risk{
+		{RiskID: "risk-1", TaskID: "task-1", Severity: "P0", Text: "data loss", CreatedAt: 1},
+		{RiskID: "risk-2", TaskID: "task-1", Severity: "P1", Text: "slow", CreatedAt: 2},
+	} {
+		if err := risks.Create(ctx, eng.DB, k); err != nil {
+			t.Fatalf("Create risk: %v", err)
+		}
+	}
+
+	gate, err := eng.GateRegistry.Get(domain.PhaseD)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	state, _ := eng.GetState(ctx, "task-1")
+	decision, err := gate.Evaluate(ctx, *state)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if decision.Allow || len(decision.Blockers) != 1 || decision.Blockers[0] != "P0 risk risk-1: data loss" {
+		t.Errorf("decision = %+v, want one P0 risk blocker", decision)
+	}
+
+	if err := risks.Resolve(ctx, eng.DB, "risk-1", "alice", 3); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	decision, err = gate.Evaluate(ctx, *state)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if !decision.Allow {
+		t.Errorf("expected Allow=true after resolving, blockers: %v", decision.Blockers)
+	}
+}
+
+func TestReviewGate_AttachesScoreCardDiffs(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+
+	cards := &store.ScoreCardRepo{}
+	for _, c := range []domain.ScoreCard{
+		{ReviewID: "r0", TaskID: "task-1", Reviewer: "lead", Verdict: "fail", Round: 0, CreatedAt: 1,
+			Scores: domain.Scores{Correctness: 2}, Issues: []domain.Issue{{Severity: "P0", Location: "a.go", Description: "crash"}}},
+		{ReviewID: "r1", TaskID: "task-1", Reviewer: "lead", Verdict: "pass", Round: 1, CreatedAt: 2,
+			Scores: domain.Scores{Correctness: 4}},
+	} {
+		if err := cards.Create(ctx, eng.DB, c); err != nil {
+			t.Fatalf("Create card: %v", err)
+		}
+	}
+
+	gate, _ := eng.GateRegistry.Get(domain.PhaseF)
+	state, _ := eng.GetState(ctx, "task-1")
+	decision, err := gate.Evaluate(ctx, *state)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if len(decision.ReviewDiffs) != 0 {
+		t.Errorf("round 0 diffs = %+v, want none", decision.ReviewDiffs)
+	}
+
+	state.Round = 1
+	decision, err = gate.Evaluate(ctx, *state)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if len(decision.ReviewDiffs) != 1 {
+		t.Fatalf("diffs = %+v, want one", decision.ReviewDiffs)
+	}
+	if d := decision.ReviewDiffs[0]; d.Reviewer != "lead" || d.ScoreDeltas.Correctness != 2 || len(d.Resolved) != 1 {
+		t.Errorf("diff = %+v, want lead +2 with the crash resolved", d)
+	}
+}
+
+func TestReviewGate_FBlocksOnOpenP0P1Issues(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+
+	issues := &store.IssueRepo{}
+	tx, _ := eng.DB.BeginTx(ctx, nil)
+	for _, i := range []domain.ReviewIssue{
+		{IssueID: "i1", TaskID: "task-1", ReviewID: "r1", Reviewer: "lead", Status: "acknowledged", CreatedAt: 1,
+			Issue: domain.Issue{Severity: "P1", Location: "a.go", Description: "leak"}},
+		{IssueID: "i2", TaskID: "task-1", ReviewID: "r1", Reviewer: "lead", CreatedAt: 2,
+			Issue: domain.Issue{Severity: "P2", Location: "b.go", Description: "style"}},
+	} {
+		if err := issues.CreateTx(ctx, tx, i); err != nil {
+			t.Fatalf("CreateTx: %v", err)
+		}
+	}
+	tx.Commit()
+
+	state, _ := eng.GetState(ctx, "task-1")
+	gateF, _ := eng.GateRegistry.Get(domain.PhaseF)
+	decision, err := gateF.Evaluate(ctx, *state)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if decision.Allow || len(decision.Blockers) != 1 || decision.Blockers[0] != "P1 issue i1 from lead at a.go is acknowledged: leak" {
+		t.Errorf("decision = %+v, want one P1 issue blocker", decision)
+	}
+	gateD, _ := eng.GateRegistry.Get(domain.PhaseD)
+	if decision, _ := gateD.Evaluate(ctx, *state); !decision.Allow {
+		t.Errorf("phase D blocked by review issues: %v", decision.Blockers)
+	}
+
+	fixed, _ := issues.GetByID(ctx, eng.DB, "i1")
+	fixed.Status = "fixed"
+	if err := issues.Update(ctx, eng.DB, *fixed); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if decision, _ := gateF.Evaluate(ctx, *state); !decision.Allow {
+		t.Errorf("expected Allow=true once fixed, blockers: %v", decision.Blockers)
+	}
+}
@@ -0,0 +1,53 @@
+package workflow
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyWindowSize is how many recent samples a latencyWindow keeps.
+const latencyWindowSize = 1024
+
+// latencyWindow keeps the most recent call durations. Its zero value is
+// ready to use.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+// record adds a sample, overwriting the oldest once the window is full.
+func (w *latencyWindow) record(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+// percentile returns the p-th percentile (0 < p <= 1) of the samples, zero
+// when there are none.
+func (w *latencyWindow) percentile(p float64) time.Duration {
+	w.mu.Lock()
+	sorted := append([]time.Duration(nil), w.samples...)
+	w.mu.Unlock()
+	if len(sorted) == 0 {
+		return 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// AdvanceLatency returns the p-th percentile (0 < p <= 1) of the last 1024
+// Advance calls' durations, retries included.
+func (e *Engine) AdvanceLatency(p float64) time.Duration {
+	return e.advanceLatency.percentile(p)
+}
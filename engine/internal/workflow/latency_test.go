@@ -0,0 +1,27 @@
+package workflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyWindow(t *testing.T) {
+	var w latencyWindow
+	if got := w.percentile(0.95); got != 0 {
+		t.Errorf("empty p95 = %v, want 0", got)
+	}
+	for i := 1; i <= 100; i++ {
+		w.record(time.Duration(i) * time.Millisecond)
+	}
+	if got := w.percentile(0.95); got != 95*time.Millisecond {
+		t.Errorf("p95 = %v, want 95ms", got)
+	}
+
+	// Once full, new samples replace the oldest.
+	for i := 0; i < latencyWindowSize; i++ {
+		w.record(time.Second)
+	}
+	if got := w.percentile(0.5); got != time.Second {
+		t.Errorf("p50 after overwrite = %v, want 1s", got)
+	}
+}
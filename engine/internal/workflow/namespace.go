@@ -0,0 +1,53 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// checkNamespaceBudget returns ErrBudgetExceeded if holding extra more
+// budget would take namespace over its limit in NamespaceBudgets.
+func (e *Engine) checkNamespaceBudget(ctx context.Context, namespace string, extra float64) error {
+	limit, ok := e.NamespaceBudgets[namespace]
+	if !ok || limit <= 0 {
+		return nil
+	}
+	allocated, err := e.TaskRepo.AllocatedBudget(ctx, e.DB, namespace)
+	if err != nil {
+		return err
+	}
+	if allocated+extra > limit {
+		return domain.NewEngineError(domain.ErrBudgetExceeded.Code,
+			fmt.Sprintf("namespace %s budget %.2f would be exceeded (%.2f allocated, %.2f requested)", namespace, limit, allocated, extra))
+	}
+	return nil
+}
+
+// ListFlows returns the flows of a namespace, ordered by task ID.
+func (e *Engine) ListFlows(ctx context.Context, namespace string) ([]*domain.FlowState, error) {
+	return e.TaskRepo.ListByNamespace(ctx, e.Reader(), namespace)
+}
+
+// Namespaces summarizes every namespace that has flows or a budget limit,
+// ordered by name.
+func (e *Engine) Namespaces(ctx context.Context) ([]domain.NamespaceSummary, error) {
+	summaries, err := e.TaskRepo.SummarizeNamespaces(ctx, e.Reader())
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(summaries))
+	for i := range summaries {
+		summaries[i].BudgetUSD = e.NamespaceBudgets[summaries[i].Namespace]
+		seen[summaries[i].Namespace] = true
+	}
+	for name, limit := range e.NamespaceBudgets {
+		if !seen[name] {
+			summaries = append(summaries, domain.NamespaceSummary{Namespace: name, BudgetUSD: limit})
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Namespace < summaries[j].Namespace })
+	return summaries, nil
+}
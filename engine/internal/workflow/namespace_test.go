@@ -0,0 +1,61 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestEngine_NamespaceBudget(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.NamespaceBudgets = map[string]float64{"team-a": 20}
+
+	if err := eng.StartFlowWithOptions(ctx, "a1", 15, FlowOptions{Namespace: "team-a"}); err != nil {
+		t.Fatalf("start a1: %v", err)
+	}
+	err := eng.StartFlowWithOptions(ctx, "a2", 10, FlowOptions{Namespace: "team-a"})
+	if engErr, ok := err.(*domain.EngineError); !ok || engErr.Code != domain.ErrBudgetExceeded.Code {
+		t.Fatalf("start a2 err = %v, want ErrBudgetExceeded", err)
+	}
+	if err := eng.SetBudgetCap(ctx, "a1", 25); err == nil {
+		t.Error("raising a1 over the namespace budget succeeded")
+	}
+	if err := eng.StartFlowWithOptions(ctx, "a2", 5, FlowOptions{Namespace: "team-a"}); err != nil {
+		t.Fatalf("start a2 within budget: %v", err)
+	}
+	// Children are carved out of their parent's cap.
+	if err := eng.SpawnChild(ctx, "a1", "a1-child", 0.5, FlowOptions{Namespace: "other"}); err != nil {
+		t.Fatalf("SpawnChild: %v", err)
+	}
+	child, _ := eng.GetState(ctx, "a1-child")
+	if child.Namespace != "team-a" {
+		t.Errorf("child namespace = %q, want team-a", child.Namespace)
+	}
+
+	// Other namespaces are unlimited.
+	if err := eng.StartFlow(ctx, "d1", 100); err != nil {
+		t.Fatalf("start d1: %v", err)
+	}
+
+	flows, err := eng.ListFlows(ctx, "team-a")
+	if err != nil || len(flows) != 3 {
+		t.Fatalf("ListFlows = %d flows, %v; want 3", len(flows), err)
+	}
+
+	eng.NamespaceBudgets["team-b"] = 50
+	summaries, err := eng.Namespaces(ctx)
+	if err != nil {
+		t.Fatalf("Namespaces: %v", err)
+	}
+	if len(summaries) != 3 || summaries[0].Namespace != "default" || summaries[2].Namespace != "team-b" {
+		t.Fatalf("summaries = %+v", summaries)
+	}
+	if a := summaries[1]; a.Flows != 3 || a.AllocatedUSD != 20 || a.BudgetUSD != 20 {
+		t.Errorf("team-a = %+v, want 3 flows with 20 of 20 allocated", a)
+	}
+	if b := summaries[2]; b.Flows != 0 || b.BudgetUSD != 50 {
+		t.Errorf("team-b = %+v, want an empty namespace with budget 50", b)
+	}
+}
@@ -0,0 +1,55 @@
+package workflow
+
+import (
+	"context"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+type dryRunKey struct{}
+
+// WithDryRun marks ctx as a dry run: gates evaluated under it must not
+// write anything or run side-effecting work such as tests.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun reports whether ctx was marked by WithDryRun.
+func IsDryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+	return dry
+}
+
+// Preview resolves the transition trigger asks for and, for a forward move,
+// evaluates the gate of the flow's current phase, as Advance would, but
+// changes nothing. Gates run as a dry run; a rollback or rework is allowed
+// without one. A flow that is not running or an illegal trigger is reported
+// in the preview's Error rather than returned.
+func (e *Engine) Preview(ctx context.Context, taskID string, trigger domain.TransitionTrigger) (*domain.AdvancePreview, error) {
+	state, err := e.TaskRepo.GetByID(ctx, e.DB, taskID)
+	if err != nil {
+		return nil, err
+	}
+	preview := &domain.AdvancePreview{TaskID: taskID, From: state.CurrentPhase}
+	if err := checkRunning(state); err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+
+	preview.To, err = targetPhase(state.CurrentPhase, trigger)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+	if phaseOrder[preview.To] < phaseOrder[state.CurrentPhase] {
+		preview.Allow = true
+		return preview, nil
+	}
+
+	_, preview.Decision, err = e.EvaluateGate(WithDryRun(ctx), state, trigger)
+	if err != nil {
+		return nil, err
+	}
+	preview.Allow = preview.Decision.Allow
+	return preview, nil
+}
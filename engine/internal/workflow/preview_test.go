@@ -0,0 +1,66 @@
+package workflow
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestEngine_Preview(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	before, _ := eng.GetState(ctx, "task-1")
+
+	preview, err := eng.Preview(ctx, "task-1", domain.TransitionTrigger{Action: "advance"})
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if !preview.Allow || preview.From != domain.PhaseA || preview.To != domain.PhaseB || preview.Error != "" {
+		t.Errorf("preview = %+v, want allowed A -> B", preview)
+	}
+
+	preview, err = eng.Preview(ctx, "task-1", domain.TransitionTrigger{Action: "rework"})
+	if err != nil {
+		t.Fatalf("Preview rework: %v", err)
+	}
+	if preview.Allow || preview.Error == "" || preview.Decision.Allow {
+		t.Errorf("rework preview = %+v, want transition refused before the gate", preview)
+	}
+
+	after, _ := eng.GetState(ctx, "task-1")
+	if after.CurrentPhase != before.CurrentPhase || after.LastEventSeq != before.LastEventSeq {
+		t.Errorf("state changed by preview: %+v -> %+v", before, after)
+	}
+
+	if _, err := eng.Preview(ctx, "missing", domain.TransitionTrigger{Action: "advance"}); err != domain.ErrFlowNotFound {
+		t.Errorf("missing flow err = %v, want ErrFlowNotFound", err)
+	}
+}
+
+func TestEngine_Preview_TestGateDoesNotRun(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	marker := filepath.Join(t.TempDir(), "ran")
+
+	gate := newTestGate(t, eng, "touch "+marker+"; exit 1", 0)
+	eng.GateRegistry.Register(domain.PhaseA, gate)
+
+	preview, err := eng.Preview(ctx, "task-1", domain.TransitionTrigger{Action: "advance"})
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if !preview.Allow || len(preview.Decision.RequireOps) != 1 || preview.Decision.RequireOps[0] != "test" {
+		t.Errorf("preview = %+v, want allowed with test required", preview)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("test command ran during a dry run")
+	}
+	if refs, _ := gate.ArtifactRepo.ListLatest(ctx, eng.DB, "task-1"); len(refs) != 0 {
+		t.Errorf("artifacts = %+v, want none", refs)
+	}
+}
@@ -0,0 +1,223 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// Scheduler starts queued flows once their start time has passed and a flow
+// slot is free, highest priority first. Running and blocked flows occupy a
+// slot; paused flows do not and are resumed like queued ones. Queued flows
+// whose workspace another flow holds are passed over until it is free.
+type Scheduler struct {
+	Engine *Engine
+	// MaxConcurrent caps the number of active flows. Zero means unlimited.
+	MaxConcurrent int
+	// Preempt lets a waiting flow pause the lowest-priority running flow
+	// when no slot is free and the waiting flow's priority is strictly higher.
+	Preempt bool
+	// Interval is how often queued flows are re-examined (default 5s).
+	Interval time.Duration
+
+	mu sync.Mutex
+	// freed is signalled when a flow completes; it is created with its
+	// listener on the first Start, so a restarted scheduler reuses both.
+	freed     chan struct{}
+	freedOnce sync.Once
+}
+
+// QueueStatus summarizes the scheduler's view of flow slots.
+type QueueStatus struct {
+	Active        int                 `json:"active"`
+	MaxConcurrent int                 `json:"maxConcurrent"`
+	Queued        []*domain.FlowState `json:"queued"`
+	Paused        []*domain.FlowState `json:"paused"`
+}
+
+// NewScheduler creates a Scheduler with the given flow limit.
+func NewScheduler(engine *Engine, maxConcurrent int) *Scheduler {
+	return &Scheduler{
+		Engine:        engine,
+		MaxConcurrent: maxConcurrent,
+		Interval:      5 * time.Second,
+	}
+}
+
+// Start runs Tick on every interval and whenever a flow completes or fails,
+// until ctx is cancelled. It may be started again after ctx ends.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.freedOnce.Do(func() {
+		s.freed = make(chan struct{}, 1)
+		s.Engine.AddListener(func(_ context.Context, state domain.FlowState, _ domain.Phase) {
+			if state.Status != domain.StatusDone && state.Status != domain.StatusFailed {
+				return
+			}
+			select {
+			case s.freed <- struct{}{}:
+			default:
+			}
+		})
+	})
+	freed := s.freed
+
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			_, _ = s.Tick(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-freed:
+			}
+		}
+	}()
+}
+
+// Tick starts (or resumes) as many due flows as there are free slots,
+// preempting lower-priority flows if enabled, and returns the IDs of the
+// flows it started.
+func (s *Scheduler) Tick(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	candidates, err := s.candidates(ctx)
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+
+	free := len(candidates)
+	if s.MaxConcurrent > 0 {
+		active, err := s.active(ctx)
+		if err != nil {
+			return nil, err
+		}
+		free = s.MaxConcurrent - active
+	}
+
+	var started []string
+	for _, c := range candidates {
+		if c.Status == domain.StatusQueued {
+			err := s.Engine.checkWorkspace(ctx, c.TaskID)
+			if engErr, ok := err.(*domain.EngineError); ok && engErr.Code == domain.ErrWorkspaceInUse.Code {
+				continue
+			}
+			if err != nil {
+				return started, err
+			}
+		}
+		if free <= 0 {
+			if !s.Preempt {
+				break
+			}
+			preempted, err := s.preemptFor(ctx, c)
+			if err != nil {
+				return started, err
+			}
+			if !preempted {
+				break
+			}
+			free++
+		}
+		if err := s.start(ctx, c); err != nil {
+			return started, err
+		}
+		started = append(started, c.TaskID)
+		free--
+	}
+	return started, nil
+}
+
+// candidates returns due queued flows and paused flows, highest priority
+// first. On equal priority paused flows come first since they already ran.
+func (s *Scheduler) candidates(ctx context.Context) ([]*domain.FlowState, error) {
+	paused, err := s.Engine.TaskRepo.ListByStatus(ctx, s.Engine.DB, domain.StatusPaused)
+	if err != nil {
+		return nil, err
+	}
+	queued, err := s.Engine.TaskRepo.ListQueued(ctx, s.Engine.DB, s.Engine.Clock.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	all := append(paused, queued...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Priority > all[j].Priority })
+	return all, nil
+}
+
+// preemptFor pauses the lowest-priority running flow if it ranks strictly
+// below c. It reports whether a flow was paused.
+func (s *Scheduler) preemptFor(ctx context.Context, c *domain.FlowState) (bool, error) {
+	running, err := s.Engine.TaskRepo.ListByStatus(ctx, s.Engine.DB, domain.StatusRunning)
+	if err != nil || len(running) == 0 {
+		return false, err
+	}
+	victim := running[len(running)-1]
+	if victim.Priority >= c.Priority {
+		return false, nil
+	}
+	if err := s.Engine.Pause(ctx, victim.TaskID, c.TaskID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// claimSlotTx queues, in its creation transaction, a top-level flow about to
+// start running when Engine.MaxConcurrentFlows flows are already active, so
+// the Scheduler starts it once a slot is free. Child flows are not queued,
+// since their parent may be waiting on them, though they occupy a slot; nor
+// are flows without a start payload, such as clones.
+func (e *Engine) claimSlotTx(ctx context.Context, tx *sql.Tx, state *domain.FlowState, eventType *domain.EventType, start *domain.FlowStartedPayload) error {
+	if e.MaxConcurrentFlows <= 0 || state.Status != domain.StatusRunning || start == nil || start.Parent != "" {
+		return nil
+	}
+	active, err := e.TaskRepo.CountByStatusTx(ctx, tx, domain.StatusRunning, domain.StatusBlocked)
+	if err != nil {
+		return err
+	}
+	if active >= e.MaxConcurrentFlows {
+		state.Status, *eventType = domain.StatusQueued, domain.EventFlowQueued
+	}
+	return nil
+}
+
+// start activates a queued flow or resumes a paused one.
+func (s *Scheduler) start(ctx context.Context, state *domain.FlowState) error {
+	if state.Status == domain.StatusPaused {
+		return s.Engine.Resume(ctx, state.TaskID)
+	}
+	return s.Engine.Activate(ctx, state.TaskID)
+}
+
+// Status reports active flows, the limit, every queued flow (including ones
+// whose start time has not yet arrived), and paused flows.
+func (s *Scheduler) Status(ctx context.Context) (*QueueStatus, error) {
+	active, err := s.active(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queued, err := s.Engine.TaskRepo.ListByStatus(ctx, s.Engine.Reader(), domain.StatusQueued)
+	if err != nil {
+		return nil, err
+	}
+	paused, err := s.Engine.TaskRepo.ListByStatus(ctx, s.Engine.Reader(), domain.StatusPaused)
+	if err != nil {
+		return nil, err
+	}
+	if queued == nil {
+		queued = []*domain.FlowState{}
+	}
+	if paused == nil {
+		paused = []*domain.FlowState{}
+	}
+	return &QueueStatus{Active: active, MaxConcurrent: s.MaxConcurrent, Queued: queued, Paused: paused}, nil
+}
+
+func (s *Scheduler) active(ctx context.Context) (int, error) {
+	return s.Engine.TaskRepo.CountByStatus(ctx, s.Engine.DB, domain.StatusRunning, domain.StatusBlocked)
+}
@@ -0,0 +1,211 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestScheduler_RespectsMaxConcurrent(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+
+	eng.StartFlow(ctx, "running", 10.0)
+	eng.StartFlowWithOptions(ctx, "q1", 10.0, FlowOptions{Queued: true})
+	eng.StartFlowWithOptions(ctx, "q2", 10.0, FlowOptions{Queued: true})
+
+	s := NewScheduler(eng, 2)
+	started, err := s.Tick(ctx)
+	if err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	if len(started) != 1 || started[0] != "q1" {
+		t.Fatalf("started = %v, want [q1]", started)
+	}
+
+	state, _ := eng.GetState(ctx, "q1")
+	if state.Status != domain.StatusRunning {
+		t.Errorf("q1 Status = %q, want running", state.Status)
+	}
+	state, _ = eng.GetState(ctx, "q2")
+	if state.Status != domain.StatusQueued {
+		t.Errorf("q2 Status = %q, want queued", state.Status)
+	}
+}
+
+func TestEngine_QueuesFlowsBeyondMaxConcurrent(t *testing.T) {
+	eng := newTestEngine(t)
+	eng.MaxConcurrentFlows = 1
+	ctx := context.Background()
+
+	eng.StartFlow(ctx, "a", 10.0)
+	if err := eng.StartFlow(ctx, "b", 10.0); err != nil {
+		t.Fatalf("StartFlow b: %v", err)
+	}
+	if state, _ := eng.GetState(ctx, "b"); state.Status != domain.StatusQueued {
+		t.Fatalf("b Status = %q, want queued", state.Status)
+	}
+
+	if err := eng.SpawnChild(ctx, "a", "a-child", 0.5, FlowOptions{}); err != nil {
+		t.Fatalf("SpawnChild: %v", err)
+	}
+	if state, _ := eng.GetState(ctx, "a-child"); state.Status != domain.StatusRunning {
+		t.Errorf("child Status = %q, want running", state.Status)
+	}
+
+	s := NewScheduler(eng, 1)
+	if started, _ := s.Tick(ctx); len(started) != 0 {
+		t.Fatalf("started = %v while a holds the slot", started)
+	}
+	eng.Fail(ctx, "a-child", domain.FailureBudget, "out of money", "test")
+	eng.Fail(ctx, "a", domain.FailureBudget, "out of money", "test")
+	if started, err := s.Tick(ctx); err != nil || len(started) != 1 || started[0] != "b" {
+		t.Fatalf("Tick = %v, %v; want [b]", started, err)
+	}
+}
+
+func TestScheduler_WaitsForStartAt(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+
+	eng.StartFlowWithOptions(ctx, "later", 10.0, FlowOptions{StartAt: time.Now().Unix() + 3600})
+
+	s := NewScheduler(eng, 0)
+	started, err := s.Tick(ctx)
+	if err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	if len(started) != 0 {
+		t.Errorf("started = %v, want none before start_at", started)
+	}
+
+	status, err := s.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(status.Queued) != 1 {
+		t.Errorf("queued = %d, want 1", len(status.Queued))
+	}
+}
+
+func TestScheduler_StartsQueuedFlowWhenSlotFrees(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eng.StartFlow(ctx, "first", 10.0)
+	eng.StartFlowWithOptions(ctx, "second", 10.0, FlowOptions{Queued: true})
+
+	s := NewScheduler(eng, 1)
+	s.Interval = time.Hour
+	s.Start(ctx)
+
+	trigger := domain.TransitionTrigger{Action: "advance", Actor: "test"}
+	for i := 0; i < 6; i++ {
+		if err := eng.Advance(ctx, "first", trigger); err != nil {
+			t.Fatalf("Advance step %d: %v", i, err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		state, _ := eng.GetState(ctx, "second")
+		if state.Status == domain.StatusRunning {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("queued flow was not started after the running flow completed")
+}
+
+func TestEngine_ActivateRejectsRunningFlow(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+
+	if err := eng.Activate(ctx, "task-1"); err == nil {
+		t.Error("expected error activating a running flow")
+	}
+}
+
+func TestScheduler_OrdersByPriority(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+
+	eng.StartFlowWithOptions(ctx, "low", 10.0, FlowOptions{Queued: true})
+	eng.StartFlowWithOptions(ctx, "high", 10.0, FlowOptions{Queued: true, Priority: 5})
+
+	started, err := NewScheduler(eng, 1).Tick(ctx)
+	if err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	if len(started) != 1 || started[0] != "high" {
+		t.Fatalf("started = %v, want [high]", started)
+	}
+}
+
+func TestScheduler_PreemptsLowerPriority(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+
+	eng.StartFlow(ctx, "low", 10.0)
+	eng.StartFlowWithOptions(ctx, "high", 10.0, FlowOptions{Queued: true, Priority: 5})
+
+	s := NewScheduler(eng, 1)
+	s.Preempt = true
+	started, err := s.Tick(ctx)
+	if err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	if len(started) != 1 || started[0] != "high" {
+		t.Fatalf("started = %v, want [high]", started)
+	}
+
+	low, _ := eng.GetState(ctx, "low")
+	if low.Status != domain.StatusPaused {
+		t.Fatalf("low Status = %q, want paused", low.Status)
+	}
+	events, _ := eng.EventRepo.ListByTask(ctx, eng.DB, "low", 0)
+	if last := events[len(events)-1]; last.EventType != "flow_preempted" {
+		t.Errorf("last event = %q, want flow_preempted", last.EventType)
+	}
+
+	// Once the high-priority flow completes, the paused flow resumes.
+	trigger := domain.TransitionTrigger{Action: "advance", Actor: "test"}
+	for i := 0; i < 6; i++ {
+		if err := eng.Advance(ctx, "high", trigger); err != nil {
+			t.Fatalf("Advance step %d: %v", i, err)
+		}
+	}
+	started, err = s.Tick(ctx)
+	if err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	if len(started) != 1 || started[0] != "low" {
+		t.Fatalf("started = %v, want [low]", started)
+	}
+	low, _ = eng.GetState(ctx, "low")
+	if low.Status != domain.StatusRunning {
+		t.Errorf("low Status = %q, want running", low.Status)
+	}
+}
+
+func TestScheduler_NoPreemptionOfEqualPriority(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+
+	eng.StartFlow(ctx, "first", 10.0)
+	eng.StartFlowWithOptions(ctx, "second", 10.0, FlowOptions{Queued: true})
+
+	s := NewScheduler(eng, 1)
+	s.Preempt = true
+	started, err := s.Tick(ctx)
+	if err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	if len(started) != 0 {
+		t.Errorf("started = %v, want none", started)
+	}
+}
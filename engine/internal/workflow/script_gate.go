@@ -0,0 +1,207 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// DefaultScriptEvents is how many recent events a script gate sees.
+const DefaultScriptEvents = 50
+
+// ScriptGate wraps an inner gate and evaluates an expr-lang expression
+// (https://expr-lang.org) against the flow before it may leave the phase.
+// The expression sees:
+//
+//	state   the flow state, with the API's field names (state.round, state.budgetUsedUsd)
+//	events  the flow's recent events, oldest first: seqNo, phase, eventType, payload, createdAt
+//	cost    usedUsd, capUsd, ratio, and byPhase and byProvider totals
+//	now     the current Unix time
+//
+// A true result or an empty string or list allows the flow to advance.
+// False blocks it with Message; a string or list of strings blocks it with
+// those blockers. An expression that fails to evaluate blocks the flow.
+type ScriptGate struct {
+	Inner     Gate
+	DB        *sql.DB
+	EventRepo *store.EventRepo
+	CostRepo  *store.CostDeltaRepo
+	Clock     clock.Clock
+	// Source is the expression, kept for messages.
+	Source string
+	// Message is the blocker reported when the expression is false.
+	Message string
+	// Events is how many recent events the expression sees.
+	Events int
+
+	program *vm.Program
+}
+
+// scriptEnv declares the variables a gate script may use.
+var scriptEnv = map[string]interface{}{
+	"state":  map[string]interface{}{},
+	"events": []interface{}{},
+	"cost":   map[string]interface{}{},
+	"now":    int64(0),
+}
+
+// NewScriptGate compiles source into a gate wrapping inner.
+func NewScriptGate(db *sql.DB, inner Gate, source string) (*ScriptGate, error) {
+	program, err := expr.Compile(source, expr.Env(scriptEnv))
+	if err != nil {
+		return nil, fmt.Errorf("compile gate script: %w", err)
+	}
+	return &ScriptGate{
+		Inner:     inner,
+		DB:        db,
+		EventRepo: &store.EventRepo{},
+		CostRepo:  &store.CostDeltaRepo{},
+		Source:    source,
+		Events:    DefaultScriptEvents,
+		program:   program,
+	}, nil
+}
+
+// Name returns the gate name.
+func (g *ScriptGate) Name() string {
+	return "script"
+}
+
+// Evaluate checks the inner gate first, then runs the script. Scripts only
+// read, so dry runs run them too.
+func (g *ScriptGate) Evaluate(ctx context.Context, state domain.FlowState) (domain.GateDecision, error) {
+	inner, err := g.Inner.Evaluate(ctx, state)
+	if err != nil || !inner.Allow {
+		return inner, err
+	}
+	env, err := g.env(ctx, state)
+	if err != nil {
+		return domain.GateDecision{}, err
+	}
+
+	out, err := vm.Run(g.program, env)
+	if err != nil {
+		return domain.GateDecision{Allow: false, Blockers: []string{"gate script failed: " + err.Error()}}, nil
+	}
+	blockers, err := g.blockers(out)
+	if err != nil {
+		return domain.GateDecision{Allow: false, Blockers: []string{"gate script failed: " + err.Error()}}, nil
+	}
+	if len(blockers) > 0 {
+		return domain.GateDecision{Allow: false, Blockers: blockers}, nil
+	}
+	return inner, nil
+}
+
+// blockers interprets a script's result.
+func (g *ScriptGate) blockers(out interface{}) ([]string, error) {
+	switch v := out.(type) {
+	case bool:
+		if v {
+			return nil, nil
+		}
+		if g.Message != "" {
+			return []string{g.Message}, nil
+		}
+		return []string{"exit criteria not met: " + strings.TrimSpace(g.Source)}, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		return []string{v}, nil
+	case []interface{}:
+		blockers := make([]string, 0, len(v))
+		for _, b := range v {
+			s, ok := b.(string)
+			if !ok {
+				return nil, fmt.Errorf("result list holds a %T, want strings", b)
+			}
+			blockers = append(blockers, s)
+		}
+		return blockers, nil
+	case []string:
+		return v, nil
+	case nil:
+		return nil, fmt.Errorf("result is nil, want a bool, string, or list of strings")
+	}
+	return nil, fmt.Errorf("result is a %T, want a bool, string, or list of strings", out)
+}
+
+// env gathers the variables the script sees.
+func (g *ScriptGate) env(ctx context.Context, state domain.FlowState) (map[string]interface{}, error) {
+	stateVars, err := toVars(state)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := g.Events
+	if limit <= 0 {
+		limit = DefaultScriptEvents
+	}
+	recent, err := g.EventRepo.ListRecent(ctx, g.DB, state.TaskID, limit)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]interface{}, len(recent))
+	for i, e := range recent {
+		var payload interface{}
+		_ = json.Unmarshal([]byte(e.PayloadJSON), &payload)
+		events[i] = map[string]interface{}{
+			"seqNo":     e.SeqNo,
+			"phase":     string(e.Phase),
+			"eventType": string(e.EventType),
+			"payload":   payload,
+			"createdAt": e.CreatedAt,
+		}
+	}
+
+	cost := map[string]interface{}{
+		"usedUsd": state.BudgetUsedUSD,
+		"capUsd":  state.BudgetCapUSD,
+		"ratio":   0.0,
+	}
+	if state.BudgetCapUSD > 0 {
+		cost["ratio"] = state.BudgetUsedUSD / state.BudgetCapUSD
+	}
+	for key, groupBy := range map[string]string{"byPhase": "phase", "byProvider": "provider"} {
+		breakdown, err := g.CostRepo.Breakdown(ctx, g.DB, groupBy, store.CostFilter{TaskID: state.TaskID})
+		if err != nil {
+			return nil, err
+		}
+		totals := make(map[string]interface{}, len(breakdown.Groups))
+		for _, grp := range breakdown.Groups {
+			totals[grp.Key] = grp.AmountUSD
+		}
+		cost[key] = totals
+	}
+
+	return map[string]interface{}{
+		"state":  stateVars,
+		"events": events,
+		"cost":   cost,
+		"now":    clock.Or(g.Clock).Now().Unix(),
+	}, nil
+}
+
+// toVars converts v to the generic map a script sees, keyed by its JSON
+// field names.
+func toVars(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal script variables: %w", err)
+	}
+	var vars map[string]interface{}
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return nil, fmt.Errorf("unmarshal script variables: %w", err)
+	}
+	return vars, nil
+}
@@ -0,0 +1,104 @@
+package workflow
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+func newScriptGate(t *testing.T, eng *Engine, source string) *ScriptGate {
+	t.Helper()
+	inner, _ := eng.GateRegistry.Get(domain.PhaseE)
+	gate, err := NewScriptGate(eng.DB, inner, source)
+	if err != nil {
+		t.Fatalf("NewScriptGate: %v", err)
+	}
+	return gate
+}
+
+func TestScriptGate_Results(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	(&store.CostDeltaRepo{}).Create(ctx, eng.DB, "task-1", domain.CostDelta{AmountUSD: 1.5, Provider: "claude", Phase: domain.PhaseA})
+	(&store.CostDeltaRepo{}).Create(ctx, eng.DB, "task-1", domain.CostDelta{AmountUSD: 0.5, Provider: "codex", Phase: domain.PhaseA})
+	state, _ := eng.GetState(ctx, "task-1")
+	state.BudgetUsedUSD = 2
+
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{`state.currentPhase == "A" && state.round == 0`, nil},
+		{`cost.ratio < 0.1`, []string{`exit criteria not met: cost.ratio < 0.1`}},
+		{`cost.byPhase.A > 1.9 && cost.byProvider.codex == 0.5 ? "" : "unexpected totals"`, nil},
+		{`any(events, .eventType == "flow_started") ? [] : ["no start event"]`, nil},
+		{`filter(["tests missing", "docs missing"], # startsWith "docs")`, []string{"docs missing"}},
+		{`now - state.updatedAtUnix < 60`, nil},
+		{`1 + 1`, []string{"gate script failed: result is a int, want a bool, string, or list of strings"}},
+	}
+	for _, tt := range tests {
+		gate := newScriptGate(t, eng, tt.source)
+		decision, err := gate.Evaluate(ctx, *state)
+		if err != nil {
+			t.Fatalf("%s: Evaluate: %v", tt.source, err)
+		}
+		if decision.Allow != (len(tt.want) == 0) || !reflect.DeepEqual(decision.Blockers, tt.want) {
+			t.Errorf("%s: decision = %+v, want blockers %v", tt.source, decision, tt.want)
+		}
+	}
+}
+
+func TestScriptGate_MessageAndClock(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	state, _ := eng.GetState(ctx, "task-1")
+
+	gate := newScriptGate(t, eng, `now - state.updatedAtUnix >= 3600`)
+	gate.Message = "phase needs an hour of soak time"
+	fake := clock.NewFake(time.Unix(state.UpdatedAtUnix, 0))
+	gate.Clock = fake
+
+	decision, _ := gate.Evaluate(ctx, *state)
+	if decision.Allow || len(decision.Blockers) != 1 || decision.Blockers[0] != gate.Message {
+		t.Errorf("decision = %+v, want the configured message", decision)
+	}
+	fake.Advance(time.Hour)
+	if decision, _ := gate.Evaluate(ctx, *state); !decision.Allow {
+		t.Errorf("decision after an hour = %+v", decision)
+	}
+}
+
+func TestScriptGate_InnerAndErrors(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	state, _ := eng.GetState(ctx, "task-1")
+
+	gate := newScriptGate(t, eng, `true`)
+	paused := *state
+	paused.Status = domain.StatusPaused
+	if decision, _ := gate.Evaluate(ctx, paused); decision.Allow {
+		t.Error("script gate allowed a flow its inner gate blocks")
+	}
+
+	gate = newScriptGate(t, eng, `events[99].eventType == "x"`)
+	decision, _ := gate.Evaluate(ctx, *state)
+	if decision.Allow || !strings.HasPrefix(decision.Blockers[0], "gate script failed: ") {
+		t.Errorf("decision = %+v, want a failure blocker", decision)
+	}
+
+	if _, err := NewScriptGate(eng.DB, gate.Inner, `stat.round > 1`); err == nil {
+		t.Error("NewScriptGate accepted an unknown variable")
+	}
+	if _, err := NewScriptGate(eng.DB, gate.Inner, `state.round >`); err == nil {
+		t.Error("NewScriptGate accepted a syntax error")
+	}
+}
@@ -0,0 +1,93 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// enterPhaseTx records that a flow entered phase at now, with the phase's
+// SLA.
+func (e *Engine) enterPhaseTx(ctx context.Context, tx *sql.Tx, taskID string, phase domain.Phase, now int64) error {
+	return e.DurationRepo.EnterTx(ctx, tx, taskID, phase, now, int64(e.PhaseSLA[phase]/time.Second))
+}
+
+// PhaseDurations returns a flow's stays in each phase, in order of entry.
+func (e *Engine) PhaseDurations(ctx context.Context, taskID string) ([]domain.PhaseDuration, error) {
+	if _, err := e.GetState(ctx, taskID); err != nil {
+		return nil, err
+	}
+	return e.DurationRepo.ListByTask(ctx, e.Reader(), taskID, e.Clock.Now().Unix())
+}
+
+// PhaseDurationStats returns the duration statistics of every phase any
+// flow has entered, with the phase's SLA.
+func (e *Engine) PhaseDurationStats(ctx context.Context) ([]domain.PhaseDurationStats, error) {
+	stats, err := e.DurationRepo.Summarize(ctx, e.Reader(), e.Clock.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		stats[i].SLASec = int64(e.PhaseSLA[stats[i].Phase] / time.Second)
+	}
+	return stats, nil
+}
+
+// SLAMonitor flags flows that stay in a phase longer than its SLA: each
+// such stay gets one phase_sla_exceeded event.
+type SLAMonitor struct {
+	Engine   *Engine
+	Interval time.Duration
+}
+
+// NewSLAMonitor creates an SLAMonitor that checks every interval.
+func NewSLAMonitor(engine *Engine, interval time.Duration) *SLAMonitor {
+	return &SLAMonitor{Engine: engine, Interval: interval}
+}
+
+// Start checks every Interval until ctx is cancelled.
+func (m *SLAMonitor) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(m.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				_, _ = m.Check(ctx, now)
+			}
+		}
+	}()
+}
+
+// Check records a phase_sla_exceeded event for every stay that ran past its
+// SLA as of now and was not flagged yet, and returns the stays flagged.
+func (m *SLAMonitor) Check(ctx context.Context, now time.Time) ([]domain.PhaseDuration, error) {
+	e := m.Engine
+	overdue, err := e.DurationRepo.ListOverdue(ctx, e.DB, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	var flagged []domain.PhaseDuration
+	for _, stay := range overdue {
+		payload := domain.PhaseSLAExceededPayload{
+			Phase:      stay.Phase,
+			EnteredAt:  stay.EnteredAt,
+			ElapsedSec: stay.DurationSec,
+			SLASec:     stay.SLASec,
+			OverrunSec: stay.DurationSec - stay.SLASec,
+		}
+		if err := e.AppendEvent(ctx, stay.TaskID, domain.EventPhaseSLAExceeded, payload); err != nil {
+			continue
+		}
+		if err := e.DurationRepo.MarkWarned(ctx, e.DB, stay.ID, now.Unix()); err != nil {
+			return flagged, err
+		}
+		stay.SLAWarnedAt = now.Unix()
+		flagged = append(flagged, stay)
+	}
+	return flagged, nil
+}
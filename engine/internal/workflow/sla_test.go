@@ -0,0 +1,99 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func TestEngine_PhaseDurations(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	if err := eng.StartFlow(ctx, "task-1", 10); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	if err := eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance"}); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	stays, err := eng.PhaseDurations(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("PhaseDurations: %v", err)
+	}
+	if len(stays) != 2 || stays[0].Phase != domain.PhaseA || stays[0].ExitedAt == 0 || stays[1].Phase != domain.PhaseB || stays[1].ExitedAt != 0 {
+		t.Errorf("stays = %+v, want A finished and B open", stays)
+	}
+
+	// A queued flow's first stay starts when it runs.
+	if err := eng.StartFlowWithOptions(ctx, "task-2", 10, FlowOptions{Queued: true}); err != nil {
+		t.Fatalf("StartFlowWithOptions: %v", err)
+	}
+	if stays, _ := eng.PhaseDurations(ctx, "task-2"); len(stays) != 0 {
+		t.Errorf("queued stays = %+v, want none", stays)
+	}
+	if err := eng.Activate(ctx, "task-2"); err != nil {
+		t.Fatalf("Activate: %v", err)
+	}
+	if stays, _ := eng.PhaseDurations(ctx, "task-2"); len(stays) != 1 || stays[0].Phase != domain.PhaseA {
+		t.Errorf("activated stays = %+v, want one in A", stays)
+	}
+
+	if _, err := eng.PhaseDurations(ctx, "missing"); err == nil {
+		t.Error("PhaseDurations of a missing flow succeeded")
+	}
+}
+
+func TestEngine_PhaseDurations_Clock(t *testing.T) {
+	eng := newTestEngine(t)
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	eng.Clock = clk
+	ctx := context.Background()
+	if err := eng.StartFlow(ctx, "task-1", 10); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	clk.Advance(90 * time.Second)
+	if err := eng.Advance(ctx, "task-1", domain.TransitionTrigger{Action: "advance"}); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	clk.Advance(30 * time.Second)
+
+	stays, err := eng.PhaseDurations(ctx, "task-1")
+	if err != nil || len(stays) != 2 {
+		t.Fatalf("PhaseDurations = %+v, %v", stays, err)
+	}
+	if stays[0].EnteredAt != 1_700_000_000 || stays[0].DurationSec != 90 || stays[1].DurationSec != 30 {
+		t.Errorf("stays = %+v, want 90s in A and 30s so far in B", stays)
+	}
+}
+
+func TestSLAMonitor_Check(t *testing.T) {
+	eng := newTestEngine(t)
+	eng.PhaseSLA = map[domain.Phase]time.Duration{domain.PhaseA: time.Minute}
+	ctx := context.Background()
+	if err := eng.StartFlow(ctx, "task-1", 10); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	m := NewSLAMonitor(eng, time.Second)
+
+	if flagged, _ := m.Check(ctx, time.Now()); len(flagged) != 0 {
+		t.Errorf("flagged within SLA = %+v", flagged)
+	}
+	flagged, err := m.Check(ctx, time.Now().Add(2*time.Minute))
+	if err != nil || len(flagged) != 1 || flagged[0].SLASec != 60 {
+		t.Fatalf("Check = %+v, %v, want task-1's stay in A", flagged, err)
+	}
+	ev, err := eng.EventRepo.LatestByType(ctx, eng.DB, "task-1", "phase_sla_exceeded")
+	if err != nil || ev == nil {
+		t.Fatalf("phase_sla_exceeded event = %v, %v", ev, err)
+	}
+	if flagged, _ := m.Check(ctx, time.Now().Add(3*time.Minute)); len(flagged) != 0 {
+		t.Errorf("flagged twice: %+v", flagged)
+	}
+
+	stats, err := eng.PhaseDurationStats(ctx)
+	if err != nil || len(stats) != 1 || stats[0].SLASec != 60 {
+		t.Errorf("PhaseDurationStats = %+v, %v", stats, err)
+	}
+}
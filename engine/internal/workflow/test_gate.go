@@ -0,0 +1,223 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/artifact"
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// DefaultCoveragePattern matches the per-package coverage lines of go test.
+const DefaultCoveragePattern = `coverage: ([0-9.]+)%`
+
+// maxTestOutput bounds the output kept in a TestReport; the tail is kept.
+const maxTestOutput = 64 << 10
+
+var (
+	goTestResult  = regexp.MustCompile(`(?m)^\s*--- (PASS|FAIL):`)
+	summaryPassed = regexp.MustCompile(`(\d+) passed`)
+	summaryFailed = regexp.MustCompile(`(\d+) failed`)
+)
+
+// TestGate wraps an inner gate and runs the project's test command in the
+// flow's workspace before the flow may leave the phase. Every run is stored
+// as a "test_report" artifact. The gate blocks when the command fails or,
+// with MinCoverage set, when coverage is missing or below it.
+type TestGate struct {
+	Inner        Gate
+	DB           *sql.DB
+	ArtifactRepo *store.ArtifactRepo
+	// Blobs, when set, stores the report content.
+	Blobs   *artifact.Blobs
+	Command string
+	Args    []string
+	// Workspace is the working directory of flows without their own.
+	Workspace string
+	Timeout   time.Duration
+	// MinCoverage is the lowest acceptable coverage percentage; 0 turns the
+	// check off. Coverage is the mean of the CoveragePattern matches.
+	MinCoverage     float64
+	CoveragePattern *regexp.Regexp
+	// Clock dates test reports and their artifacts; nil means the wall
+	// clock. Run durations and artifact IDs stay on the wall clock.
+	Clock clock.Clock
+}
+
+// Name returns the gate name.
+func (g *TestGate) Name() string {
+	return "test"
+}
+
+// Evaluate checks the inner gate first, then runs the tests. A dry run
+// does not run them; the decision lists "test" in RequireOps instead.
+func (g *TestGate) Evaluate(ctx context.Context, state domain.FlowState) (domain.GateDecision, error) {
+	inner, err := g.Inner.Evaluate(ctx, state)
+	if err != nil || !inner.Allow {
+		return inner, err
+	}
+	if IsDryRun(ctx) {
+		inner.RequireOps = append(inner.RequireOps, g.Name())
+		return inner, nil
+	}
+
+	report := g.Run(ctx, state)
+	if err := g.record(ctx, state, report); err != nil {
+		return domain.GateDecision{}, err
+	}
+
+	var blockers []string
+	switch {
+	case report.TimedOut:
+		blockers = append(blockers, fmt.Sprintf("tests timed out after %s", g.Timeout))
+	case report.ExitCode != 0:
+		blockers = append(blockers, fmt.Sprintf("tests failed: %d of %d failed (exit code %d)",
+			report.Failed, report.Passed+report.Failed, report.ExitCode))
+	}
+	if g.MinCoverage > 0 {
+		switch {
+		case report.Coverage == nil:
+			blockers = append(blockers, "tests reported no coverage")
+		case *report.Coverage < g.MinCoverage:
+			blockers = append(blockers, fmt.Sprintf("coverage %.1f%% is below %.1f%%", *report.Coverage, g.MinCoverage))
+		}
+	}
+	if len(blockers) > 0 {
+		return domain.GateDecision{Allow: false, Blockers: blockers}, nil
+	}
+	return inner, nil
+}
+
+// Run executes the test command for the flow and parses its output. A
+// command that cannot be started is reported with exit code -1.
+func (g *TestGate) Run(ctx context.Context, state domain.FlowState) domain.TestReport {
+	if g.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.Timeout)
+		defer cancel()
+	}
+	dir := state.Workspace
+	if dir == "" {
+		dir = g.Workspace
+	}
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, g.Command, g.Args...)
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	start := time.Now()
+	err := cmd.Run()
+
+	report := domain.TestReport{
+		Command:    strings.TrimSpace(g.Command + " " + strings.Join(g.Args, " ")),
+		DurationMS: time.Since(start).Milliseconds(),
+		TimedOut:   ctx.Err() == context.DeadlineExceeded,
+		CreatedAt:  clock.Or(g.Clock).Now().Unix(),
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		report.ExitCode = exitErr.ExitCode()
+	default:
+		report.ExitCode = -1
+		fmt.Fprintf(&out, "\n%v\n", err)
+	}
+
+	output := out.String()
+	report.Passed, report.Failed = countTests(output)
+	report.Coverage = g.coverage(output)
+	if len(output) > maxTestOutput {
+		output = output[len(output)-maxTestOutput:]
+	}
+	report.Output = output
+	return report
+}
+
+// countTests counts go test's "--- PASS" and "--- FAIL" lines or, when there
+// are none, reads "N passed" and "N failed" from a summary line.
+func countTests(output string) (passed, failed int) {
+	for _, m := range goTestResult.FindAllStringSubmatch(output, -1) {
+		if m[1] == "PASS" {
+			passed++
+		} else {
+			failed++
+		}
+	}
+	if passed+failed > 0 {
+		return passed, failed
+	}
+	if m := summaryPassed.FindAllStringSubmatch(output, -1); len(m) > 0 {
+		passed, _ = strconv.Atoi(m[len(m)-1][1])
+	}
+	if m := summaryFailed.FindAllStringSubmatch(output, -1); len(m) > 0 {
+		failed, _ = strconv.Atoi(m[len(m)-1][1])
+	}
+	return passed, failed
+}
+
+// coverage averages the percentages captured by the coverage pattern.
+func (g *TestGate) coverage(output string) *float64 {
+	pattern := g.CoveragePattern
+	if pattern == nil {
+		pattern = regexp.MustCompile(DefaultCoveragePattern)
+	}
+	var sum float64
+	var n int
+	for _, m := range pattern.FindAllStringSubmatch(output, -1) {
+		if len(m) < 2 {
+			continue
+		}
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			continue
+		}
+		sum += v
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	mean := sum / float64(n)
+	return &mean
+}
+
+// record stores report as a test_report artifact of the flow's phase.
+func (g *TestGate) record(ctx context.Context, state domain.FlowState, report domain.TestReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal test report: %w", err)
+	}
+	hash := artifact.Hash(data)
+	if g.Blobs != nil {
+		if _, err := g.Blobs.Put(data); err != nil {
+			return fmt.Errorf("store test report: %w", err)
+		}
+	}
+	now := clock.Or(g.Clock).Now()
+	_, err = g.ArtifactRepo.Create(ctx, g.DB, domain.ArtifactRef{
+		ID:        fmt.Sprintf("art-test-%d", time.Now().UnixNano()),
+		TaskID:    state.TaskID,
+		Phase:     state.CurrentPhase,
+		Type:      "test_report",
+		Path:      "test-report.json",
+		Hash:      hash,
+		CreatedAt: now.Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("record test report: %w", err)
+	}
+	return nil
+}
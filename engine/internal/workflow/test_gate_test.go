@@ -0,0 +1,135 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/artifact"
+	"github.com/anthropics/three-body-engine/internal/clock"
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+)
+
+// newTestGate returns a TestGate over eng's phase E gate that runs script
+// with sh.
+func newTestGate(t *testing.T, eng *Engine, script string, minCoverage float64) *TestGate {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("test gate scripts need sh")
+	}
+	inner, _ := eng.GateRegistry.Get(domain.PhaseE)
+	return &TestGate{
+		Inner:        inner,
+		DB:           eng.DB,
+		ArtifactRepo: &store.ArtifactRepo{},
+		Blobs:        artifact.NewBlobs(t.TempDir()),
+		Command:      "sh",
+		Args:         []string{"-c", script},
+		Workspace:    t.TempDir(),
+		Timeout:      5 * time.Second,
+		MinCoverage:  minCoverage,
+	}
+}
+
+func TestTestGate_PassRecordsReport(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	state, _ := eng.GetState(ctx, "task-1")
+
+	gate := newTestGate(t, eng, `echo '--- PASS: TestA'; echo '--- PASS: TestB'; echo 'ok pkg/a 0.1s coverage: 80.0% of statements'; echo 'ok pkg/b 0.1s coverage: 90.0% of statements'`, 75)
+	gate.Clock = clock.NewFake(time.Unix(1_700_000_000, 0))
+	decision, err := gate.Evaluate(ctx, *state)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if !decision.Allow {
+		t.Fatalf("expected Allow=true, blockers: %v", decision.Blockers)
+	}
+
+	refs, err := gate.ArtifactRepo.ListLatest(ctx, eng.DB, "task-1")
+	if err != nil || len(refs) != 1 || refs[0].Type != "test_report" {
+		t.Fatalf("artifacts = %+v, %v; want one test_report", refs, err)
+	}
+	if refs[0].CreatedAt != 1_700_000_000 {
+		t.Errorf("artifact CreatedAt = %d, want the gate clock's time", refs[0].CreatedAt)
+	}
+	f, err := gate.Blobs.Open(refs[0].Hash)
+	if err != nil {
+		t.Fatalf("Open blob: %v", err)
+	}
+	defer f.Close()
+	var report domain.TestReport
+	if err := json.NewDecoder(f).Decode(&report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.Passed != 2 || report.Failed != 0 || report.Coverage == nil || *report.Coverage != 85 {
+		t.Errorf("report = %+v, want 2 passed at 85%% coverage", report)
+	}
+	if report.CreatedAt != 1_700_000_000 {
+		t.Errorf("report CreatedAt = %d, want the gate clock's time", report.CreatedAt)
+	}
+}
+
+func TestTestGate_BlocksOnFailure(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	state, _ := eng.GetState(ctx, "task-1")
+
+	gate := newTestGate(t, eng, `echo '3 passed, 1 failed'; exit 1`, 0)
+	decision, err := gate.Evaluate(ctx, *state)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if decision.Allow || len(decision.Blockers) != 1 || decision.Blockers[0] != "tests failed: 1 of 4 failed (exit code 1)" {
+		t.Errorf("decision = %+v, want one failure blocker", decision)
+	}
+}
+
+func TestTestGate_BlocksOnLowCoverage(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	state, _ := eng.GetState(ctx, "task-1")
+
+	gate := newTestGate(t, eng, `echo 'coverage: 61.5% of statements'`, 80)
+	decision, _ := gate.Evaluate(ctx, *state)
+	if decision.Allow || len(decision.Blockers) != 1 || decision.Blockers[0] != "coverage 61.5% is below 80.0%" {
+		t.Errorf("decision = %+v, want a coverage blocker", decision)
+	}
+
+	gate = newTestGate(t, eng, `true`, 80)
+	decision, _ = gate.Evaluate(ctx, *state)
+	if decision.Allow || len(decision.Blockers) != 1 || decision.Blockers[0] != "tests reported no coverage" {
+		t.Errorf("decision = %+v, want a missing coverage blocker", decision)
+	}
+}
+
+func TestTestGate_RunsInFlowWorkspace(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	state, _ := eng.GetState(ctx, "task-1")
+	state.Workspace = t.TempDir()
+	if err := os.WriteFile(filepath.Join(state.Workspace, "marker"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	gate := newTestGate(t, eng, `test -f marker`, 0)
+	if report := gate.Run(ctx, *state); report.ExitCode != 0 {
+		t.Errorf("exit code = %d, want 0 in the flow workspace: %s", report.ExitCode, report.Output)
+	}
+
+	gate.Command, gate.Args = "no-such-test-command", nil
+	report := gate.Run(ctx, *state)
+	if report.ExitCode != -1 || !strings.Contains(report.Output, "no-such-test-command") {
+		t.Errorf("report = %+v, want exit code -1 for a missing command", report)
+	}
+}
@@ -0,0 +1,105 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+)
+
+// UnblockManager re-evaluates the gates of flows blocked by BlockOnGate
+// when a signal may have cleared their blockers: a review submitted, an
+// issue or risk closed, an approval recorded, a child finished, or the
+// flow's budget cap changed.
+// Once a dry run of the gate allows, the flow is unblocked and advanced,
+// which evaluates the gate for real. Flows blocked for any other reason are
+// left for a human.
+type UnblockManager struct {
+	Engine *Engine
+	Bus    *eventbus.Bus
+}
+
+// NewUnblockManager creates an UnblockManager for the given engine and bus.
+func NewUnblockManager(engine *Engine, bus *eventbus.Bus) *UnblockManager {
+	return &UnblockManager{Engine: engine, Bus: bus}
+}
+
+// unblockTopics are the signals that can clear a gate's blockers.
+var unblockTopics = map[eventbus.Topic]bool{
+	eventbus.TopicReviewSubmitted:  true,
+	eventbus.TopicIntentDone:       true,
+	eventbus.TopicChildDone:        true,
+	eventbus.TopicBudgetChanged:    true,
+	eventbus.TopicApprovalRecorded: true,
+	eventbus.TopicCustomEvent:      true,
+	eventbus.TopicCIReported:       true,
+}
+
+// Start re-checks every blocked flow once, to catch up on signals missed
+// while the engine was down, then processes signals until ctx is cancelled.
+func (m *UnblockManager) Start(ctx context.Context) {
+	signals, unsubscribe := m.Bus.Subscribe(64)
+	go func() {
+		defer unsubscribe()
+		if blocked, err := m.Engine.TaskRepo.ListByStatus(ctx, m.Engine.DB, domain.StatusBlocked); err == nil {
+			for _, state := range blocked {
+				_, _ = m.TryUnblock(ctx, state.TaskID)
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				if unblockTopics[sig.Topic] {
+					_, _ = m.TryUnblock(ctx, sig.TaskID)
+				}
+			}
+		}
+	}()
+}
+
+// TryUnblock evaluates, as a dry run, the gate of a flow blocked by
+// BlockOnGate as if it were running and, if the gate allows, unblocks and
+// advances the flow. Only the advance evaluates the gate for real, running
+// its tests and recording its decision; if that evaluation refuses, the
+// flow is blocked again. It reports whether the flow advanced.
+func (m *UnblockManager) TryUnblock(ctx context.Context, taskID string) (bool, error) {
+	state, err := m.Engine.GetState(ctx, taskID)
+	if err != nil {
+		return false, err
+	}
+	if state.Status != domain.StatusBlocked {
+		return false, nil
+	}
+	blocked, err := m.Engine.EventRepo.LatestByType(ctx, m.Engine.DB, taskID, domain.EventFlowBlocked)
+	if err != nil || blocked == nil {
+		return false, err
+	}
+	var payload map[string]string
+	if err := json.Unmarshal([]byte(blocked.PayloadJSON), &payload); err != nil {
+		return false, fmt.Errorf("decode flow_blocked event: %w", err)
+	}
+	if payload["cause"] != BlockCauseGate {
+		return false, nil
+	}
+
+	running := *state
+	running.Status = domain.StatusRunning
+	trigger := domain.TransitionTrigger{Action: "advance", Actor: "unblock"}
+	_, decision, err := m.Engine.EvaluateGate(WithDryRun(ctx), &running, trigger)
+	if err != nil || !decision.Allow {
+		return false, err
+	}
+
+	if err := m.Engine.Unblock(ctx, taskID, trigger.Actor); err != nil {
+		return false, err
+	}
+	err = m.Engine.Advance(ctx, taskID, trigger)
+	if engErr, ok := err.(*domain.EngineError); ok && engErr.Code == domain.ErrPhaseGateFailed.Code {
+		return false, m.Engine.BlockOnGate(ctx, taskID, engErr.Message)
+	}
+	return err == nil, err
+}
@@ -0,0 +1,137 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/eventbus"
+)
+
+func TestUnblockManager_TryUnblock(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	gate := &stubGate{name: "signoff", blockers: []string{"waiting on sign-off"}}
+	eng.GateRegistry.Register(domain.PhaseA, gate)
+	if err := eng.BlockOnGate(ctx, "task-1", "gate blocked transition"); err != nil {
+		t.Fatalf("BlockOnGate: %v", err)
+	}
+
+	m := NewUnblockManager(eng, nil)
+	if ok, err := m.TryUnblock(ctx, "task-1"); ok || err != nil {
+		t.Fatalf("TryUnblock while blocked = %v, %v; want false", ok, err)
+	}
+
+	gate.allow, gate.blockers = true, nil
+	if ok, err := m.TryUnblock(ctx, "task-1"); !ok || err != nil {
+		t.Fatalf("TryUnblock once allowed = %v, %v; want true", ok, err)
+	}
+	state, _ := eng.GetState(ctx, "task-1")
+	if state.Status != domain.StatusRunning || state.CurrentPhase != domain.PhaseB {
+		t.Errorf("state = %s in %s, want running in B", state.Status, state.CurrentPhase)
+	}
+	if ev, _ := eng.EventRepo.LatestByType(ctx, eng.DB, "task-1", "flow_unblocked"); ev == nil {
+		t.Error("expected a flow_unblocked event")
+	}
+}
+
+// testsGate stands in for a gate whose checks only run outside a dry run.
+type testsGate struct {
+	pass bool
+	runs int
+}
+
+func (g *testsGate) Name() string { return "tests" }
+func (g *testsGate) Evaluate(ctx context.Context, _ domain.FlowState) (domain.GateDecision, error) {
+	if IsDryRun(ctx) {
+		return domain.GateDecision{Allow: true, RequireOps: []string{"tests"}}, nil
+	}
+	g.runs++
+	if !g.pass {
+		return domain.GateDecision{Blockers: []string{"tests failed"}}, nil
+	}
+	return domain.GateDecision{Allow: true}, nil
+}
+
+func TestUnblockManager_EvaluatesGateOnce(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	gate := &testsGate{}
+	eng.GateRegistry.Register(domain.PhaseA, gate)
+	eng.BlockOnGate(ctx, "task-1", "tests failed")
+
+	m := NewUnblockManager(eng, nil)
+	if ok, err := m.TryUnblock(ctx, "task-1"); ok || err != nil {
+		t.Fatalf("TryUnblock with failing tests = %v, %v; want false", ok, err)
+	}
+	if state, _ := eng.GetState(ctx, "task-1"); state.Status != domain.StatusBlocked || state.CurrentPhase != domain.PhaseA {
+		t.Errorf("state = %s in %s, want blocked in A again", state.Status, state.CurrentPhase)
+	}
+
+	gate.pass = true
+	if ok, err := m.TryUnblock(ctx, "task-1"); !ok || err != nil {
+		t.Fatalf("TryUnblock with passing tests = %v, %v; want true", ok, err)
+	}
+	if gate.runs != 2 {
+		t.Errorf("gate ran %d times, want once per TryUnblock", gate.runs)
+	}
+	records, _ := eng.GateRepo.ListByTask(ctx, eng.DB, "task-1", domain.PhaseA, 0)
+	if len(records) != 2 {
+		t.Errorf("gate decisions = %d, want 2", len(records))
+	}
+}
+
+func TestUnblockManager_LeavesOtherBlocksAlone(t *testing.T) {
+	eng := newTestEngine(t)
+	ctx := context.Background()
+	eng.StartFlow(ctx, "task-1", 10.0)
+	if err := eng.Block(ctx, "task-1", "worker timed out"); err != nil {
+		t.Fatalf("Block: %v", err)
+	}
+
+	if ok, err := NewUnblockManager(eng, nil).TryUnblock(ctx, "task-1"); ok || err != nil {
+		t.Errorf("TryUnblock = %v, %v; want the flow left for a human", ok, err)
+	}
+}
+
+func TestUnblockManager_BudgetRaiseUnblocks(t *testing.T) {
+	eng := newTestEngine(t)
+	bus := eventbus.New()
+	eng.Bus = bus
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eng.StartFlow(ctx, "task-1", 10.0)
+	if _, err := NewBudgetGovernor(eng.DB).RecordUsage(ctx, "task-1", domain.CostDelta{AmountUSD: 10.0}); err != nil {
+		t.Fatalf("RecordUsage: %v", err)
+	}
+	if err := eng.BlockOnGate(ctx, "task-1", "budget exhausted"); err != nil {
+		t.Fatalf("BlockOnGate: %v", err)
+	}
+	notified, unsubscribe := bus.Subscribe(16)
+	defer unsubscribe()
+	NewUnblockManager(eng, bus).Start(ctx)
+
+	if err := eng.SetBudgetCap(ctx, "task-1", 5.0); err == nil {
+		t.Fatal("expected a cap below the spend to be refused")
+	}
+	if err := eng.SetBudgetCap(ctx, "task-1", 20.0); err != nil {
+		t.Fatalf("SetBudgetCap: %v", err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case sig := <-notified:
+			if sig.Topic == eventbus.TopicFlowUnblocked && sig.TaskID == "task-1" {
+				return
+			}
+		case <-deadline:
+			state, _ := eng.GetState(ctx, "task-1")
+			t.Fatalf("flow not unblocked: %+v", state)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+// Workspace conflict policies; see Engine.WorkspaceConflict.
+const (
+	WorkspaceConflictShare = "share"
+	WorkspaceConflictFail  = "fail"
+	WorkspaceConflictQueue = "queue"
+)
+
+// leasesWorkspaces reports whether flows lease their workspaces.
+func (e *Engine) leasesWorkspaces() bool {
+	return e.WorkspaceConflict == WorkspaceConflictFail || e.WorkspaceConflict == WorkspaceConflictQueue
+}
+
+// workspaceOf returns the workspace state's flow works in and whether it
+// is the shared DefaultWorkspace, or "" if the engine does not know it.
+func (e *Engine) workspaceOf(state domain.FlowState) (string, bool) {
+	if state.Workspace != "" {
+		ws := filepath.Clean(state.Workspace)
+		return ws, e.DefaultWorkspace != "" && ws == filepath.Clean(e.DefaultWorkspace)
+	}
+	if e.DefaultWorkspace == "" {
+		return "", false
+	}
+	return filepath.Clean(e.DefaultWorkspace), true
+}
+
+// WorkspaceHolder returns the started, unfinished flow other than taskID
+// that holds taskID's workspace, or "" if there is none.
+func (e *Engine) WorkspaceHolder(ctx context.Context, taskID string) (string, error) {
+	state, err := e.TaskRepo.GetByID(ctx, e.DB, taskID)
+	if err != nil {
+		return "", err
+	}
+	ws, shared := e.workspaceOf(*state)
+	if ws == "" {
+		return "", nil
+	}
+	return e.TaskRepo.WorkspaceHolder(ctx, e.DB, taskID, ws, shared)
+}
+
+// leaseWorkspaceTx settles, in its creation transaction, the workspace
+// lease of a flow about to start running. If another flow holds the
+// workspace, the flow starts anyway when start allows sharing it, recording
+// the override in the audit log; otherwise it is rejected with
+// ErrWorkspaceInUse or, under WorkspaceConflictQueue, queued until the
+// workspace is free. Flows without a start payload, such as clones, cannot
+// wait and are rejected.
+func (e *Engine) leaseWorkspaceTx(ctx context.Context, tx *sql.Tx, state *domain.FlowState, eventType *domain.EventType, start *domain.FlowStartedPayload) error {
+	if !e.leasesWorkspaces() || state.Status != domain.StatusRunning {
+		return nil
+	}
+	ws, shared := e.workspaceOf(*state)
+	if ws == "" {
+		return nil
+	}
+	holder, err := e.TaskRepo.WorkspaceHolderTx(ctx, tx, state.TaskID, ws, shared)
+	if err != nil || holder == "" {
+		return err
+	}
+	switch {
+	case start != nil && start.ShareWorkspace:
+		return e.auditWorkspaceTx(ctx, tx, state.TaskID, "workspace_lease_overridden", "warning",
+			map[string]string{"workspace": ws, "heldBy": holder})
+	case start != nil && e.WorkspaceConflict == WorkspaceConflictQueue:
+		state.Status, *eventType = domain.StatusQueued, domain.EventFlowQueued
+		start.WorkspaceHeldBy = holder
+		return nil
+	}
+	return domain.NewEngineError(domain.ErrWorkspaceInUse.Code,
+		fmt.Sprintf("workspace %s is held by flow %s", ws, holder))
+}
+
+// checkWorkspace returns ErrWorkspaceInUse if a queued flow cannot start
+// because another flow holds its workspace and the flow was not created to
+// share it.
+func (e *Engine) checkWorkspace(ctx context.Context, taskID string) error {
+	if !e.leasesWorkspaces() {
+		return nil
+	}
+	holder, err := e.WorkspaceHolder(ctx, taskID)
+	if err != nil || holder == "" {
+		return err
+	}
+	var start domain.FlowStartedPayload
+	ev, err := e.EventRepo.LatestByType(ctx, e.DB, taskID, domain.EventFlowQueued)
+	if err != nil {
+		return err
+	}
+	if ev != nil {
+		_ = json.Unmarshal([]byte(ev.PayloadJSON), &start)
+	}
+	if start.ShareWorkspace {
+		return nil
+	}
+	return domain.NewEngineError(domain.ErrWorkspaceInUse.Code,
+		fmt.Sprintf("workspace of flow %s is held by flow %s", taskID, holder))
+}
+
+func (e *Engine) auditWorkspaceTx(ctx context.Context, tx *sql.Tx, taskID, action, severity string, detail map[string]string) error {
+	data, _ := json.Marshal(detail)
+	now := e.Clock.Now()
+	return e.AuditRepo.RecordTx(ctx, tx, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-ws-%s-%d", taskID, now.UnixNano()),
+		TaskID:       taskID,
+		Category:     "workspace",
+		Actor:        "operator",
+		Action:       action,
+		RequestJSON:  "{}",
+		DecisionJSON: string(data),
+		Severity:     severity,
+		CreatedAt:    now.Unix(),
+	})
+}
@@ -0,0 +1,100 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+)
+
+func isWorkspaceInUse(err error) bool {
+	engErr, ok := err.(*domain.EngineError)
+	return ok && engErr.Code == domain.ErrWorkspaceInUse.Code
+}
+
+func TestEngine_WorkspaceConflictFail(t *testing.T) {
+	eng := newTestEngine(t)
+	eng.DefaultWorkspace = "/srv/ws"
+	eng.WorkspaceConflict = WorkspaceConflictFail
+	ctx := context.Background()
+
+	if err := eng.StartFlow(ctx, "a", 10); err != nil {
+		t.Fatalf("StartFlow a: %v", err)
+	}
+	err := eng.StartFlow(ctx, "b", 10)
+	if !isWorkspaceInUse(err) || !strings.Contains(err.Error(), "held by flow a") {
+		t.Fatalf("StartFlow b = %v, want ErrWorkspaceInUse naming a", err)
+	}
+	if _, err := eng.GetState(ctx, "b"); err == nil {
+		t.Error("rejected flow b was created")
+	}
+	if err := eng.StartFlowWithOptions(ctx, "c", 10, FlowOptions{Workspace: "/srv/other"}); err != nil {
+		t.Errorf("StartFlow in another workspace: %v", err)
+	}
+	if err := eng.StartFlowWithOptions(ctx, "d", 10, FlowOptions{Workspace: "/srv/ws/"}); !isWorkspaceInUse(err) {
+		t.Errorf("StartFlow naming the default workspace = %v, want ErrWorkspaceInUse", err)
+	}
+
+	if err := eng.StartFlowWithOptions(ctx, "e", 10, FlowOptions{ShareWorkspace: true}); err != nil {
+		t.Fatalf("StartFlow with override: %v", err)
+	}
+	records, err := eng.AuditRepo.ListByTask(ctx, eng.DB, "e")
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(records) != 1 || records[0].Action != "workspace_lease_overridden" || !strings.Contains(records[0].DecisionJSON, `"heldBy":"a"`) {
+		t.Errorf("audit records = %+v", records)
+	}
+}
+
+func TestEngine_WorkspaceConflictQueue(t *testing.T) {
+	eng := newTestEngine(t)
+	eng.DefaultWorkspace = "/srv/ws"
+	eng.WorkspaceConflict = WorkspaceConflictQueue
+	ctx := context.Background()
+
+	eng.StartFlow(ctx, "a", 10)
+	if err := eng.StartFlow(ctx, "b", 10); err != nil {
+		t.Fatalf("StartFlow b: %v", err)
+	}
+	state, _ := eng.GetState(ctx, "b")
+	if state.Status != domain.StatusQueued {
+		t.Fatalf("b Status = %q, want queued", state.Status)
+	}
+	ev, _ := eng.EventRepo.LatestByType(ctx, eng.DB, "b", domain.EventFlowQueued)
+	if ev == nil || ev.PayloadJSON != `{"workspaceHeldBy":"a"}` {
+		t.Errorf("flow_queued event = %+v", ev)
+	}
+	if err := eng.Activate(ctx, "b"); !isWorkspaceInUse(err) {
+		t.Errorf("Activate b = %v, want ErrWorkspaceInUse", err)
+	}
+
+	s := NewScheduler(eng, 0)
+	if started, err := s.Tick(ctx); err != nil || len(started) != 0 {
+		t.Fatalf("Tick = %v, %v; want nothing started while a holds the workspace", started, err)
+	}
+	if _, err := eng.Fail(ctx, "a", domain.FailureBudget, "out of money", "test"); err != nil {
+		t.Fatalf("Fail a: %v", err)
+	}
+	if started, err := s.Tick(ctx); err != nil || len(started) != 1 || started[0] != "b" {
+		t.Fatalf("Tick = %v, %v; want [b]", started, err)
+	}
+	if holder, err := eng.WorkspaceHolder(ctx, "a"); err != nil || holder != "b" {
+		t.Errorf("WorkspaceHolder(a) = %q, %v; want b", holder, err)
+	}
+}
+
+func TestEngine_WorkspaceConflictShare(t *testing.T) {
+	eng := newTestEngine(t)
+	eng.DefaultWorkspace = "/srv/ws"
+	ctx := context.Background()
+
+	eng.StartFlow(ctx, "a", 10)
+	if err := eng.StartFlow(ctx, "b", 10); err != nil {
+		t.Fatalf("StartFlow b: %v", err)
+	}
+	if state, _ := eng.GetState(ctx, "b"); state.Status != domain.StatusRunning {
+		t.Errorf("b Status = %q, want running", state.Status)
+	}
+}
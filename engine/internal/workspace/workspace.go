@@ -0,0 +1,281 @@
+// Package workspace provisions an isolated working directory for each flow,
+// optionally as a git worktree of the project repository, and removes or
+// archives it once the flow completes.
+package workspace
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+// Completion policies applied to a flow's workspace when it completes.
+const (
+	Keep    = "keep"
+	Delete  = "delete"
+	Archive = "archive"
+)
+
+// Manager creates per-task workspaces under Root.
+type Manager struct {
+	DB        *sql.DB
+	AuditRepo *store.AuditRepo
+	// Root is the parent directory of the per-task workspaces.
+	Root string
+	// Repo is the project repository worktrees are added from when Worktree
+	// is set.
+	Repo     string
+	Worktree bool
+	// OnComplete is Keep, Delete, or Archive.
+	OnComplete string
+	// ArchiveDir receives <task>-<unix>.tar.gz files under the Archive policy.
+	ArchiveDir string
+}
+
+// NewManager creates a Manager that keeps completed workspaces.
+func NewManager(db *sql.DB, root string) *Manager {
+	return &Manager{
+		DB:         db,
+		AuditRepo:  &store.AuditRepo{},
+		Root:       root,
+		OnComplete: Keep,
+		ArchiveDir: filepath.Join(root, "archive"),
+	}
+}
+
+// Start releases each flow's workspace according to OnComplete once the flow
+// completes.
+func (m *Manager) Start(engine *workflow.Engine) {
+	engine.AddListener(func(_ context.Context, state domain.FlowState, _ domain.Phase) {
+		if state.Status != domain.StatusDone || state.Workspace == "" {
+			return
+		}
+		go func() {
+			archive, err := m.Release(context.Background(), state.TaskID, state.Workspace)
+			if err != nil {
+				m.audit(state.TaskID, "workspace_release_failed", "warning", map[string]string{
+					"workspace": state.Workspace,
+					"error":     err.Error(),
+				})
+				return
+			}
+			m.audit(state.TaskID, "workspace_released", "info", map[string]string{
+				"workspace": state.Workspace,
+				"policy":    m.OnComplete,
+				"archive":   archive,
+			})
+		}()
+	})
+}
+
+// Provision creates the workspace for taskID and returns its absolute path.
+func (m *Manager) Provision(ctx context.Context, taskID string) (string, error) {
+	if taskID == "" || taskID != filepath.Base(taskID) || strings.HasPrefix(taskID, ".") {
+		return "", fmt.Errorf("task id %q is not a valid directory name", taskID)
+	}
+	root, err := filepath.Abs(m.Root)
+	if err != nil {
+		return "", fmt.Errorf("resolve workspace root: %w", err)
+	}
+	path := filepath.Join(root, taskID)
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("workspace %s already exists", path)
+	}
+
+	if !m.Worktree {
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			return "", fmt.Errorf("create workspace: %w", err)
+		}
+		return path, nil
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return "", fmt.Errorf("create workspace root: %w", err)
+	}
+	if err := m.git(ctx, "worktree", "add", "--detach", path, "HEAD"); err != nil {
+		return "", fmt.Errorf("add worktree: %w", err)
+	}
+	return path, nil
+}
+
+// Discard removes a workspace regardless of OnComplete. The engine calls it
+// when a flow could not be created after its workspace was provisioned.
+func (m *Manager) Discard(ctx context.Context, taskID, path string) error {
+	return m.remove(ctx, path)
+}
+
+// Release applies OnComplete to a finished flow's workspace. It returns the
+// archive path under the Archive policy.
+func (m *Manager) Release(ctx context.Context, taskID, path string) (string, error) {
+	switch m.OnComplete {
+	case Delete:
+		return "", m.remove(ctx, path)
+	case Archive:
+		archive, err := m.archive(taskID, path)
+		if err != nil {
+			return "", err
+		}
+		return archive, m.remove(ctx, path)
+	default:
+		return "", nil
+	}
+}
+
+// Purge removes taskID's workspace at path, when it is the task's own
+// workspace under Root, and the task's archives in ArchiveDir. It returns
+// the paths removed or, with dryRun set, the paths it would remove.
+func (m *Manager) Purge(ctx context.Context, taskID, path string, dryRun bool) ([]string, error) {
+	var paths []string
+	root, err := filepath.Abs(m.Root)
+	if err != nil {
+		return nil, fmt.Errorf("resolve workspace root: %w", err)
+	}
+	if path != "" && path == filepath.Join(root, taskID) {
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+			if !dryRun {
+				if err := m.remove(ctx, path); err != nil {
+					return paths, err
+				}
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(m.ArchiveDir)
+	if err != nil && !os.IsNotExist(err) {
+		return paths, fmt.Errorf("list workspace archives: %w", err)
+	}
+	for _, e := range entries {
+		if !isArchiveOf(e.Name(), taskID) {
+			continue
+		}
+		archive := filepath.Join(m.ArchiveDir, e.Name())
+		paths = append(paths, archive)
+		if dryRun {
+			continue
+		}
+		if err := os.Remove(archive); err != nil {
+			return paths, fmt.Errorf("remove workspace archive: %w", err)
+		}
+	}
+	return paths, nil
+}
+
+// isArchiveOf reports whether name is an archive of taskID's workspace:
+// <task>-<unix>.tar.gz.
+func isArchiveOf(name, taskID string) bool {
+	stamp, ok := strings.CutPrefix(name, taskID+"-")
+	if !ok {
+		return false
+	}
+	stamp, ok = strings.CutSuffix(stamp, ".tar.gz")
+	if !ok || stamp == "" {
+		return false
+	}
+	_, err := strconv.ParseInt(stamp, 10, 64)
+	return err == nil
+}
+
+// remove deletes a workspace, unregistering it first if it is a worktree.
+func (m *Manager) remove(ctx context.Context, path string) error {
+	if m.Worktree {
+		if err := m.git(ctx, "worktree", "remove", "--force", path); err != nil {
+			return fmt.Errorf("remove worktree: %w", err)
+		}
+		return nil
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("remove workspace: %w", err)
+	}
+	return nil
+}
+
+// archive writes path as a gzip-compressed tarball into ArchiveDir.
+func (m *Manager) archive(taskID, path string) (string, error) {
+	if err := os.MkdirAll(m.ArchiveDir, 0o755); err != nil {
+		return "", fmt.Errorf("create archive dir: %w", err)
+	}
+	dst := filepath.Join(m.ArchiveDir, fmt.Sprintf("%s-%d.tar.gz", taskID, time.Now().Unix()))
+	f, err := os.Create(dst)
+	if err != nil {
+		return "", fmt.Errorf("create archive: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil || rel == "." {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(taskID, rel))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		os.Remove(dst)
+		return "", fmt.Errorf("archive workspace: %w", err)
+	}
+	return dst, nil
+}
+
+func (m *Manager) git(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", m.Repo}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (m *Manager) audit(taskID, action, severity string, detail map[string]string) {
+	data, _ := json.Marshal(detail)
+	now := time.Now()
+	_ = m.AuditRepo.Record(context.Background(), m.DB, domain.AuditRecord{
+		ID:           fmt.Sprintf("aud-ws-%d", now.UnixNano()),
+		TaskID:       taskID,
+		Category:     "workspace",
+		Actor:        "system",
+		Action:       action,
+		DecisionJSON: string(data),
+		Severity:     severity,
+		CreatedAt:    now.Unix(),
+	})
+}
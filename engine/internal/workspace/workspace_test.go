@@ -0,0 +1,165 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/three-body-engine/internal/domain"
+	"github.com/anthropics/three-body-engine/internal/store"
+	"github.com/anthropics/three-body-engine/internal/workflow"
+)
+
+func newDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := store.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestStartFlow_ProvisionsWorkspace(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	m := NewManager(db, t.TempDir())
+	engine := workflow.NewEngine(db)
+	engine.Workspaces = m
+
+	if err := engine.StartFlow(ctx, "t1", 10); err != nil {
+		t.Fatalf("StartFlow: %v", err)
+	}
+	state, err := engine.GetState(ctx, "t1")
+	if err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if state.Workspace != filepath.Join(m.Root, "t1") {
+		t.Errorf("Workspace = %q, want %q", state.Workspace, filepath.Join(m.Root, "t1"))
+	}
+	if info, err := os.Stat(state.Workspace); err != nil || !info.IsDir() {
+		t.Fatalf("workspace directory missing: %v", err)
+	}
+
+	// A duplicate task must not touch the existing task's workspace.
+	if err := engine.StartFlow(ctx, "t1", 10); !errors.Is(err, domain.ErrDuplicateTask) {
+		t.Fatalf("duplicate StartFlow error = %v, want ErrDuplicateTask", err)
+	}
+	if _, err := os.Stat(state.Workspace); err != nil {
+		t.Errorf("workspace removed by duplicate StartFlow: %v", err)
+	}
+}
+
+func TestProvision_RejectsUnsafeTaskID(t *testing.T) {
+	m := NewManager(newDB(t), t.TempDir())
+	for _, id := range []string{"", "../escape", "a/b", ".hidden"} {
+		if _, err := m.Provision(context.Background(), id); err == nil {
+			t.Errorf("Provision(%q) succeeded, want error", id)
+		}
+	}
+}
+
+func TestRelease_Archive(t *testing.T) {
+	m := NewManager(newDB(t), t.TempDir())
+	m.OnComplete = Archive
+	ctx := context.Background()
+
+	path, err := m.Provision(ctx, "t1")
+	if err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	archive, err := m.Release(ctx, "t1", path)
+	if err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if !strings.HasPrefix(filepath.Base(archive), "t1-") {
+		t.Errorf("archive = %q, want t1-<unix>.tar.gz", archive)
+	}
+	if info, err := os.Stat(archive); err != nil || info.Size() == 0 {
+		t.Errorf("archive missing or empty: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("workspace still exists after archive: %v", err)
+	}
+}
+
+func TestProvision_Worktree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	m := NewManager(newDB(t), t.TempDir())
+	m.Repo = repo
+	m.Worktree = true
+	m.OnComplete = Delete
+	ctx := context.Background()
+
+	path, err := m.Provision(ctx, "t1")
+	if err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		t.Fatalf("worktree has no .git: %v", err)
+	}
+	if _, err := m.Release(ctx, "t1", path); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("worktree still exists after delete: %v", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	root := t.TempDir()
+	m := NewManager(newDB(t), root)
+	ctx := context.Background()
+	path, _ := m.Provision(ctx, "t1")
+	os.MkdirAll(m.ArchiveDir, 0o755)
+	for _, name := range []string{"t1-100.tar.gz", "t1-200.tar.gz", "t1-x-300.tar.gz", "t10-100.tar.gz"} {
+		os.WriteFile(filepath.Join(m.ArchiveDir, name), []byte("x"), 0o644)
+	}
+
+	dry, err := m.Purge(ctx, "t1", path, true)
+	if err != nil || len(dry) != 3 {
+		t.Fatalf("dry run = %v, %v; want the workspace and two archives", dry, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("dry run removed the workspace: %v", err)
+	}
+
+	removed, err := m.Purge(ctx, "t1", path, false)
+	if err != nil || len(removed) != 3 {
+		t.Fatalf("Purge = %v, %v", removed, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("workspace still exists: %v", err)
+	}
+	left, _ := os.ReadDir(m.ArchiveDir)
+	if len(left) != 2 {
+		t.Errorf("archives left = %d, want those of t1-x and t10", len(left))
+	}
+
+	// A workspace outside Root, such as the shared one, is never removed.
+	shared := t.TempDir()
+	if removed, _ := m.Purge(ctx, "t1", shared, false); len(removed) != 0 {
+		t.Errorf("Purge removed %v outside Root", removed)
+	}
+}
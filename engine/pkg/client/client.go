@@ -0,0 +1,333 @@
+// Package client is a Go client for the Three-Body Engine HTTP API.
+//
+// Requests that fail with a network error, 429, 502, 503, or 504 are
+// retried. Every write carries an Idempotency-Key that is reused across its
+// retries, so the engine applies it at most once.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IdempotencyHeader is the request header carrying a write's idempotency key.
+const IdempotencyHeader = "Idempotency-Key"
+
+// Client calls one engine.
+type Client struct {
+	// BaseURL is the engine's address, e.g. http://localhost:9800.
+	BaseURL string
+	// Namespace, when set, scopes every flow call to that namespace.
+	Namespace  string
+	HTTPClient *http.Client
+	// MaxRetries bounds the retries of a failed request (default 3).
+	MaxRetries int
+	// RetryBackoff is the base delay between retries; retry n waits n times
+	// it unless the engine sent Retry-After (default 500ms).
+	RetryBackoff time.Duration
+}
+
+// New creates a Client for the engine at baseURL.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		HTTPClient:   http.DefaultClient,
+		MaxRetries:   3,
+		RetryBackoff: 500 * time.Millisecond,
+	}
+}
+
+// Error is an error response from the engine. Code is the engine error code
+// (see the README), or the HTTP status when the engine sent none.
+type Error struct {
+	Status  int    `json:"-"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("engine: %d %s (code %d)", e.Status, e.Message, e.Code)
+}
+
+// CreateFlow starts a flow and returns its state.
+func (c *Client) CreateFlow(ctx context.Context, req CreateFlowRequest) (*Flow, error) {
+	var flow Flow
+	if err := c.do(ctx, http.MethodPost, c.flowPath(), req, &flow); err != nil {
+		return nil, err
+	}
+	return &flow, nil
+}
+
+// GetFlow returns a flow's state.
+func (c *Client) GetFlow(ctx context.Context, taskID string) (*Flow, error) {
+	var flow Flow
+	if err := c.do(ctx, http.MethodGet, c.flowPath(taskID), nil, &flow); err != nil {
+		return nil, err
+	}
+	return &flow, nil
+}
+
+// ListFlows returns the flows of the client's namespace.
+func (c *Client) ListFlows(ctx context.Context) ([]Flow, error) {
+	var flows []Flow
+	if err := c.do(ctx, http.MethodGet, c.flowPath(), nil, &flows); err != nil {
+		return nil, err
+	}
+	return flows, nil
+}
+
+// Advance moves a flow as req asks.
+func (c *Client) Advance(ctx context.Context, taskID string, req AdvanceRequest) error {
+	return c.do(ctx, http.MethodPost, c.flowPath(taskID, "advance"), req, nil)
+}
+
+// SubmitScoreCard records a review of the flow's current round and returns
+// the card as stored.
+func (c *Client) SubmitScoreCard(ctx context.Context, taskID string, card ScoreCard) (*ScoreCard, error) {
+	var stored ScoreCard
+	if err := c.do(ctx, http.MethodPost, c.flowPath(taskID, "reviews"), card, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// ListEvents returns a flow's events after sinceSeq.
+func (c *Client) ListEvents(ctx context.Context, taskID string, sinceSeq int64) ([]Event, error) {
+	path := c.flowPath(taskID, "events") + "?since_seq=" + strconv.FormatInt(sinceSeq, 10)
+	var events []Event
+	if err := c.do(ctx, http.MethodGet, path, nil, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// AppendEvent records a custom event, such as "ci.build", on a flow and
+// returns it as stored. payload is marshalled to JSON and must match the
+// schema the engine has configured for eventType.
+func (c *Client) AppendEvent(ctx context.Context, taskID, eventType string, payload interface{}) (*Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req := appendEventRequest{Type: eventType, Payload: data}
+	var event Event
+	if err := c.do(ctx, http.MethodPost, c.flowPath(taskID, "events"), req, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// StreamEvents delivers a flow's events, from the first, until ctx is
+// cancelled. A dropped stream is reopened after the last event delivered,
+// until MaxRetries attempts in a row fail; the channel is then closed. The
+// error reports only a failure to open the first stream.
+func (c *Client) StreamEvents(ctx context.Context, taskID string) (<-chan Event, error) {
+	path := c.flowPath(taskID, "events", "stream")
+	body, err := c.openStream(ctx, path, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		lastSeq, failures := int64(0), 0
+		for {
+			delivered := readEvents(ctx, body, events, &lastSeq)
+			body.Close()
+			if delivered {
+				failures = 0
+			}
+			for {
+				if ctx.Err() != nil || failures >= c.MaxRetries {
+					return
+				}
+				failures++
+				if !sleep(ctx, c.RetryBackoff*time.Duration(failures)) {
+					return
+				}
+				if body, err = c.openStream(ctx, path, lastSeq); err == nil {
+					break
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// openStream opens an SSE stream after event lastSeq, retrying like any
+// other request.
+func (c *Client) openStream(ctx context.Context, path string, lastSeq int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	if lastSeq > 0 {
+		header.Set("Last-Event-ID", strconv.FormatInt(lastSeq, 10))
+	}
+	resp, err := c.send(ctx, http.MethodGet, path, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// readEvents sends the events read from an SSE body that are newer than
+// *lastSeq, until the body ends or ctx is cancelled. It reports whether any
+// event was delivered.
+func readEvents(ctx context.Context, body io.Reader, out chan<- Event, lastSeq *int64) bool {
+	delivered := false
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	eventType := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			eventType = ""
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: ") && eventType == "":
+			var ev Event
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev); err != nil || ev.SeqNo <= *lastSeq {
+				continue
+			}
+			select {
+			case out <- ev:
+				*lastSeq = ev.SeqNo
+				delivered = true
+			case <-ctx.Done():
+				return delivered
+			}
+		}
+	}
+	return delivered
+}
+
+// flowPath joins parts onto the flow collection of the client's namespace,
+// escaping each.
+func (c *Client) flowPath(parts ...string) string {
+	path := "/api/v1/flow"
+	if c.Namespace != "" {
+		path = "/api/v1/ns/" + url.PathEscape(c.Namespace) + "/flow"
+	}
+	for _, p := range parts {
+		path += "/" + url.PathEscape(p)
+	}
+	return path
+}
+
+// do sends a request with in as its JSON body and decodes the response into
+// out, if both are set.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+	header := http.Header{}
+	if method != http.MethodGet {
+		header.Set(IdempotencyHeader, newKey())
+	}
+	resp, err := c.send(ctx, method, path, body, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// send performs a request with header, retrying transient failures with
+// the same header, so the same idempotency key. A non-2xx response is
+// returned as an *Error.
+func (c *Client) send(ctx context.Context, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+
+		resp, err := c.HTTPClient.Do(req)
+		wait := c.RetryBackoff * time.Duration(attempt+1)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+		case resp.StatusCode < 300:
+			return resp, nil
+		default:
+			apiErr := readError(resp)
+			if !retryable(resp.StatusCode) {
+				return nil, apiErr
+			}
+			if s, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+				wait = time.Duration(s) * time.Second
+			}
+			err = apiErr
+		}
+		if attempt >= c.MaxRetries || !sleep(ctx, wait) {
+			return nil, err
+		}
+	}
+}
+
+// readError consumes an error response.
+func readError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	apiErr := &Error{Status: resp.StatusCode}
+	if json.NewDecoder(resp.Body).Decode(apiErr) != nil || apiErr.Message == "" {
+		apiErr.Code, apiErr.Message = resp.StatusCode, http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+// retryable reports whether a response status may succeed on retry.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// sleep waits for d and reports false if ctx ends first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// newKey returns a random idempotency key.
+func newKey() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
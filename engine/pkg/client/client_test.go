@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, h http.Handler) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c := New(srv.URL)
+	c.RetryBackoff = time.Millisecond
+	return c
+}
+
+func TestClient_CreateFlowRetriesWithSameKey(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get(IdempotencyHeader))
+		attempt := len(keys)
+		mu.Unlock()
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/ns/team-a/flow" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if attempt == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var req CreateFlowRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Flow{TaskID: req.TaskID, Namespace: "team-a", BudgetCapUSD: req.BudgetCapUSD})
+	}))
+	c.Namespace = "team-a"
+
+	flow, err := c.CreateFlow(context.Background(), CreateFlowRequest{TaskID: "t1", BudgetCapUSD: 5})
+	if err != nil {
+		t.Fatalf("CreateFlow: %v", err)
+	}
+	if flow.TaskID != "t1" || flow.BudgetCapUSD != 5 {
+		t.Errorf("flow = %+v", flow)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("idempotency keys = %q, want one key reused on retry", keys)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	calls := 0
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"code":-32012,"message":"workflow not found"}`)
+	}))
+
+	_, err := c.GetFlow(context.Background(), "missing")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || apiErr.Code != -32012 {
+		t.Fatalf("err = %v, want a 404 engine error", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want no retry of a 404", calls)
+	}
+}
+
+func TestClient_AppendEvent(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req appendEventRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/flow/t1/events" || req.Type != "ci.build" {
+			t.Errorf("request = %s %s %+v", r.Method, r.URL.Path, req)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Event{TaskID: "t1", SeqNo: 7, EventType: req.Type, PayloadJSON: string(req.Payload)})
+	}))
+
+	event, err := c.AppendEvent(context.Background(), "t1", "ci.build", map[string]string{"status": "success"})
+	if err != nil {
+		t.Fatalf("AppendEvent: %v", err)
+	}
+	if event.SeqNo != 7 || event.PayloadJSON != `{"status":"success"}` {
+		t.Errorf("event = %+v", event)
+	}
+}
+
+func TestClient_StreamEventsResumes(t *testing.T) {
+	events := []Event{{TaskID: "t1", SeqNo: 1, EventType: "flow_started"}, {TaskID: "t1", SeqNo: 2, EventType: "phase_advanced"}}
+	var mu sync.Mutex
+	conns := 0
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/flow/t1/events/stream" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		mu.Lock()
+		conns++
+		n := conns
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		// The first connection drops after one event. The reconnect names
+		// it as the last event seen, but is sent the whole log again, so
+		// the client must skip what it already delivered.
+		send := events
+		if n == 1 {
+			send = events[:1]
+		} else if id := r.Header.Get("Last-Event-ID"); id != "1" {
+			t.Errorf("reconnect Last-Event-ID = %q, want 1", id)
+		}
+		for _, ev := range send {
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		w.(http.Flusher).Flush()
+		if n > 1 {
+			<-r.Context().Done()
+		}
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := c.StreamEvents(ctx, "t1")
+	if err != nil {
+		t.Fatalf("StreamEvents: %v", err)
+	}
+	var got []int64
+	for len(got) < 2 {
+		select {
+		case ev := <-ch:
+			got = append(got, ev.SeqNo)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out with events %v", got)
+		}
+	}
+	if got[0] != 1 || got[1] != 2 {
+		t.Errorf("events = %v, want 1, 2 once each", got)
+	}
+	cancel()
+	for range ch {
+	}
+}
@@ -0,0 +1,102 @@
+package client
+
+import "encoding/json"
+
+// Flow is the state of a workflow.
+type Flow struct {
+	TaskID             string          `json:"taskId"`
+	Namespace          string          `json:"namespace"`
+	CurrentPhase       string          `json:"currentPhase"`
+	Status             string          `json:"status"`
+	StateVersion       int64           `json:"stateVersion"`
+	Round              int             `json:"round"`
+	BudgetUsedUSD      float64         `json:"budgetUsedUsd"`
+	BudgetCapUSD       float64         `json:"budgetCapUsd"`
+	LastEventSeq       int64           `json:"lastEventSeq"`
+	UpdatedAtUnix      int64           `json:"updatedAtUnix"`
+	AutoAdvance        bool            `json:"autoAdvance"`
+	ParentTaskID       string          `json:"parentTaskId,omitempty"`
+	StartAt            int64           `json:"startAt,omitempty"`
+	Priority           int             `json:"priority"`
+	Workspace          string          `json:"workspace,omitempty"`
+	Title              string          `json:"title,omitempty"`
+	Description        string          `json:"description,omitempty"`
+	AcceptanceCriteria string          `json:"acceptanceCriteria,omitempty"`
+	Consensus          json.RawMessage `json:"consensus,omitempty"`
+}
+
+// CreateFlowRequest describes a new flow. BudgetCapUSD is required.
+type CreateFlowRequest struct {
+	TaskID             string  `json:"task_id"`
+	BudgetCapUSD       float64 `json:"budget_cap_usd"`
+	AutoAdvance        bool    `json:"auto_advance,omitempty"`
+	Queued             bool    `json:"queued,omitempty"`
+	StartAt            int64   `json:"start_at,omitempty"`
+	Priority           int     `json:"priority,omitempty"`
+	Title              string  `json:"title,omitempty"`
+	Description        string  `json:"description,omitempty"`
+	AcceptanceCriteria string  `json:"acceptance_criteria,omitempty"`
+	// Consensus overrides the engine's consensus weights and thresholds.
+	Consensus json.RawMessage `json:"consensus,omitempty"`
+}
+
+// AdvanceRequest moves a flow: Action is advance, rollback (optionally to
+// RollbackTo), or rework.
+type AdvanceRequest struct {
+	Action     string `json:"action"`
+	Actor      string `json:"actor"`
+	RollbackTo string `json:"rollback_to,omitempty"`
+}
+
+// appendEventRequest is the body of AppendEvent.
+type appendEventRequest struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Event is an entry in a flow's event log.
+type Event struct {
+	ID          int64  `json:"id"`
+	TaskID      string `json:"taskId"`
+	SeqNo       int64  `json:"seqNo"`
+	Phase       string `json:"phase"`
+	EventType   string `json:"eventType"`
+	PayloadJSON string `json:"payloadJson"`
+	// SchemaVersion is the version of the payload schema of EventType;
+	// 0 for experimental (x_) event types.
+	SchemaVersion int   `json:"schemaVersion"`
+	CreatedAt     int64 `json:"createdAt"`
+}
+
+// Scores rates a change from 1 to 5 on each axis.
+type Scores struct {
+	Correctness     int `json:"correctness"`
+	Security        int `json:"security"`
+	Maintainability int `json:"maintainability"`
+	Cost            int `json:"cost"`
+	DeliveryRisk    int `json:"deliveryRisk"`
+}
+
+// Issue is a problem a reviewer found. Severity is P0 to P3.
+type Issue struct {
+	Severity    string `json:"severity"`
+	Location    string `json:"location"`
+	Description string `json:"description"`
+	Suggestion  string `json:"suggestion"`
+	Evidence    string `json:"evidence"`
+}
+
+// ScoreCard is a reviewer's structured review. The engine fills TaskID,
+// Round, and CreatedAt.
+type ScoreCard struct {
+	ReviewID      string   `json:"reviewId"`
+	TaskID        string   `json:"taskId"`
+	Reviewer      string   `json:"reviewer"`
+	Scores        Scores   `json:"scores"`
+	Issues        []Issue  `json:"issues"`
+	Alternatives  []string `json:"alternatives"`
+	Verdict       string   `json:"verdict"`
+	CreatedAt     int64    `json:"createdAt"`
+	Round         int      `json:"round"`
+	InvalidatedAt int64    `json:"invalidatedAt,omitempty"`
+}